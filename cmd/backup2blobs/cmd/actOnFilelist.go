@@ -281,9 +281,10 @@ func parseTime(timeStr string) (time.Time, error) {
 }
 
 var actOnFilelistCmd = &cobra.Command{
-	Use:   "filelist-actions",
-	Short: "perform various operations on a list of files",
-	Long:  "perform various operations on a list of files",
+	Use:        "filelist-actions",
+	Deprecated: `use "datamon archive generate" to filter files by modification time, and "datamon archive upload --unlink" to remove archived files`,
+	Short:      "perform various operations on a list of files",
+	Long:       "perform various operations on a list of files",
 	Run: func(cmd *cobra.Command, args []string) {
 		var action filelistAction
 		var filter filelistFilter
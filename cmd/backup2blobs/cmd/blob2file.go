@@ -122,9 +122,10 @@ func startBlob2File(input string, destination storage.Store, backupStore storage
 }
 
 var download = &cobra.Command{
-	Use:   "blob2file",
-	Short: "Download files stored in blobs",
-	Long:  "Download files that were migrated to a cafs blob store back to a non cafs blob store",
+	Use:        "blob2file",
+	Deprecated: `use "datamon archive restore" instead`,
+	Short:      "Download files stored in blobs",
+	Long:       "Download files that were migrated to a cafs blob store back to a non cafs blob store",
 	Run: func(cmd *cobra.Command, args []string) {
 		// Create CAFS based on the blob store
 		localStore := localfs.New(afero.NewBasePathFs(afero.NewOsFs(), b2fParams.destination))
@@ -152,9 +152,10 @@ func ProcessFiles(fileList string, sourceStore storage.Store, backupStore storag
 }
 
 var upload = &cobra.Command{
-	Use:   "upload2blob",
-	Short: " Upload a files in a new line separated fileList",
-	Long:  `Tool to bulk import files into CAFS with a record of the files in the backing store.`,
+	Use:        "upload2blob",
+	Deprecated: `use "datamon archive upload" instead, which uploads files as a bundle`,
+	Short:      " Upload a files in a new line separated fileList",
+	Long:       `Tool to bulk import files into CAFS with a record of the files in the backing store.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		localStore := localfs.New(afero.NewBasePathFs(afero.NewOsFs(), params.pathToMount))
 		backupStore, err := gcs.New(context.TODO(), params.backendStoreBucket, "")
@@ -181,7 +182,9 @@ var upload = &cobra.Command{
 var rootCmd = &cobra.Command{
 	Use:   "migrate",
 	Short: "Commands to help migrate data to datamon",
-	Long:  "This tools helps generate a list of files then uploads it to CAFS based FS",
+	Long: `This tools helps generate a list of files then uploads it to CAFS based FS.
+
+Deprecated: this tool is superseded by the "datamon archive" commands, which archive files as bundles to any datamon context.`,
 }
 
 var logger *zap.Logger
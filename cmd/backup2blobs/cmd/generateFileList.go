@@ -252,9 +252,10 @@ func processDir(dirChan chan *task, fileChan chan string) {
 }
 
 var generateFileListCmd = &cobra.Command{
-	Use:   "generate",
-	Short: "Generate a list of files to upload to blobs",
-	Long:  "This command takes a parent directory generates a list of all the files. Change current working dir to the top of the tree to be captured for generating relative paths.",
+	Use:        "generate",
+	Deprecated: `use "datamon archive generate" instead`,
+	Short:      "Generate a list of files to upload to blobs",
+	Long:       "This command takes a parent directory generates a list of all the files. Change current working dir to the top of the tree to be captured for generating relative paths.",
 	Run: func(cmd *cobra.Command, args []string) {
 		logError := log.New(os.Stderr, "", 0)
 		log := log.New(os.Stdout, "", 0)
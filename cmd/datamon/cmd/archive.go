@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/oneconcern/datamon/pkg/archive"
+	"github.com/spf13/cobra"
+)
+
+// archiveCmd is a group of commands to archive large file trees as bundles
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Commands to archive large file trees as bundles",
+	Long: `Commands to archive large file trees, such as NFS volumes, as bundles.
+
+Archival runs in steps:
+  1. generate the list of files to archive, possibly filtered by modification time
+  2. upload the listed files as a bundle: an interrupted upload resumes where it stopped
+  3. restore some or all of the archived files from this bundle
+
+Archives are regular bundles: they may be labelled, and their blobs are retained by purge as long as the bundle exists.
+`,
+}
+
+func init() {
+	rootCmd.AddCommand(archiveCmd)
+}
+
+// archiveFilters builds the modification time filters from flags
+func archiveFilters() ([]archive.Filter, error) {
+	var filters []archive.Filter
+
+	if datamonFlags.archive.ModifiedBefore != "" {
+		t, err := archive.ParseTime(datamonFlags.archive.ModifiedBefore)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, archive.ModifiedBefore(t))
+	}
+
+	if datamonFlags.archive.ModifiedAfter != "" {
+		t, err := archive.ParseTime(datamonFlags.archive.ModifiedAfter)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, archive.ModifiedAfter(t))
+	}
+
+	return filters, nil
+}
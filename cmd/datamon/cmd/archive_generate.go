@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/archive"
+	"github.com/spf13/cobra"
+)
+
+var archiveGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate the list of files to archive",
+	Long: `Generate the list of files to archive from a local directory.
+
+Files are listed one per line, with paths relative to the directory, in lexical order.
+Files may be selected by their modification time (local time).
+
+Files or directories which cannot be read are skipped and reported.
+`,
+	Example: `# List files last modified before 2020
+% datamon archive generate --path /nfs/project --modified-before 2020-01-01 --out files.txt
+INFO: listed 120334 files (filtered: 4021, errors: 0)`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+
+		defer func(t0 time.Time) {
+			cliUsage(t0, "archive generate", err)
+		}(time.Now())
+
+		filters, err := archiveFilters()
+		if err != nil {
+			wrapFatalWithCodef(2, "invalid modification time: %v", err)
+			return
+		}
+
+		optionInputs := newCliOptionInputs(config, &datamonFlags)
+		logger, err := optionInputs.getLogger()
+		if err != nil {
+			wrapFatalln("get logger", err)
+			return
+		}
+
+		var out io.Writer = os.Stdout
+		if datamonFlags.archive.Out != "-" {
+			var file *os.File
+			file, err = os.Create(datamonFlags.archive.Out)
+			if err != nil {
+				wrapFatalln("create list of files", err)
+				return
+			}
+			defer func() {
+				_ = file.Close()
+			}()
+			out = file
+		}
+
+		summary, err := archive.GenerateFileList(datamonFlags.bundle.DataPath, out,
+			archive.WithFilters(filters...),
+			archive.WithFollowSymlinks(datamonFlags.archive.FollowSymlinks),
+			archive.WithParallelism(datamonFlags.archive.ReadDirWorkers),
+			archive.WithLogger(logger),
+		)
+		if err != nil {
+			wrapFatalln("generate list of files", err)
+			return
+		}
+
+		infoLogger.Printf("listed %d files (filtered: %d, errors: %d)", summary.Listed, summary.Filtered, summary.Errors)
+	},
+}
+
+func init() {
+	requireFlags(archiveGenerateCmd,
+		addArchivePathFlag(archiveGenerateCmd),
+	)
+
+	addArchiveOutFlag(archiveGenerateCmd)
+	addArchiveModifiedBeforeFlag(archiveGenerateCmd)
+	addArchiveModifiedAfterFlag(archiveGenerateCmd)
+	addArchiveFollowSymlinksFlag(archiveGenerateCmd)
+	addReadDirWorkersFlag(archiveGenerateCmd, 1)
+
+	archiveCmd.AddCommand(archiveGenerateCmd)
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/archive"
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/spf13/cobra"
+)
+
+var archiveRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore archived files from a bundle",
+	Long: `Restore archived files from a bundle to some destination.
+
+All files are restored, unless selected by a list of files (e.g. generated by "datamon archive generate"), or by a regular expression.
+When both are specified, files must satisfy both to be restored.
+
+If --bundle is not specified, the latest bundle or the bundle with --label is used.
+`,
+	Example: `# Restore a subdirectory of an archive
+% datamon archive restore --repo project-archive --label 2019 --destination /nfs/restored --name-filter '^reports/'
+Using bundle: 1INzQ5TV4vAAfU2PbRFgPfnzEwR`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+
+		defer func(t0 time.Time) {
+			cliUsage(t0, "archive restore", err)
+		}(time.Now())
+
+		ctx := cmd.Context()
+		optionInputs := newCliOptionInputs(config, &datamonFlags)
+
+		selected, err := archiveSelection()
+		if err != nil {
+			wrapFatalln("select files to restore", err)
+			return
+		}
+
+		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
+		if err != nil {
+			wrapFatalln("create remote stores", err)
+			return
+		}
+		var destStatus DestT
+		if datamonFlags.bundle.ForceDest {
+			destStatus = destTMaybeNonEmpty
+		} else {
+			destStatus = destTEmpty
+		}
+		destinationStore, err := optionInputs.destStore(destStatus, "")
+		if err != nil {
+			wrapFatalln("create destination store", err)
+			return
+		}
+		if err = setLatestOrLabelledBundle(ctx, remoteStores); err != nil {
+			wrapFatalln("determine bundle id", err)
+			return
+		}
+		logger, err := optionInputs.getLogger()
+		if err != nil {
+			wrapFatalln("get logger", err)
+			return
+		}
+
+		bundleOpts, err := optionInputs.bundleOpts(ctx, ReadOnlyContext())
+		if err != nil {
+			wrapFatalln("failed to initialize bundle options", err)
+			return
+		}
+		bundleOpts = append(bundleOpts,
+			core.Repo(datamonFlags.repo.RepoName),
+			core.BundleID(datamonFlags.bundle.ID),
+			core.ConsumableStore(destinationStore),
+			core.ConcurrentFileDownloads(datamonFlags.bundle.ConcurrencyFactor/fileDownloadsByConcurrencyFactor),
+			core.ConcurrentFilelistDownloads(datamonFlags.bundle.ConcurrencyFactor/filelistDownloadsByConcurrencyFactor),
+			core.Logger(logger),
+			core.BundleWithMetrics(datamonFlags.root.metrics.IsEnabled()),
+			core.BundleWithVerifyHash(datamonFlags.fs.WithVerifyHash),
+		)
+		bundle := core.NewBundle(bundleOpts...)
+
+		if selected == nil {
+			err = core.Publish(ctx, bundle)
+		} else {
+			err = core.PublishSelectBundleEntries(ctx, bundle, selected)
+		}
+		if err != nil {
+			wrapFatalln("restore archived files", err)
+			return
+		}
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+// archiveSelection selects the files to restore from a list of files and a regular expression.
+//
+// It yields nil when all files are restored.
+func archiveSelection() (func(string) (bool, error), error) {
+	var (
+		listed      map[string]struct{}
+		nameFilter  *regexp.Regexp
+		err         error
+		isSelection bool
+	)
+
+	if datamonFlags.bundle.FileList != "" {
+		isSelection = true
+		file, erf := os.Open(datamonFlags.bundle.FileList)
+		if erf != nil {
+			return nil, erf
+		}
+		files, erf := archive.ReadFileList(file)
+		_ = file.Close()
+		if erf != nil {
+			return nil, erf
+		}
+		listed = make(map[string]struct{}, len(files))
+		for _, name := range files {
+			listed[name] = struct{}{}
+		}
+	}
+
+	if datamonFlags.bundle.NameFilter != "" {
+		isSelection = true
+		nameFilter, err = regexp.Compile(datamonFlags.bundle.NameFilter)
+		if err != nil {
+			return nil, fmt.Errorf("name filter regexp %s didn't build: %w", datamonFlags.bundle.NameFilter, err)
+		}
+	}
+
+	if !isSelection {
+		return nil, nil
+	}
+
+	return func(name string) (bool, error) {
+		if listed != nil {
+			if _, ok := listed[name]; !ok {
+				return false, nil
+			}
+		}
+		return nameFilter == nil || nameFilter.MatchString(name), nil
+	}, nil
+}
+
+func init() {
+	requireFlags(archiveRestoreCmd,
+		addRepoNameOptionFlag(archiveRestoreCmd),
+		addDataPathFlag(archiveRestoreCmd),
+	)
+
+	addBundleFlag(archiveRestoreCmd)
+	addLabelNameFlag(archiveRestoreCmd)
+	addFileListFlag(archiveRestoreCmd)
+	addNameFilterFlag(archiveRestoreCmd)
+	addForceDestFlag(archiveRestoreCmd)
+	addConcurrencyFactorFlag(archiveRestoreCmd, 100)
+	addVerifyHashFlag(archiveRestoreCmd)
+
+	archiveCmd.AddCommand(archiveRestoreCmd)
+}
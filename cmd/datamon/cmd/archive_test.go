@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive(t *testing.T) {
+	cleanup := setupTests(t)
+	defer cleanup()
+
+	const repo = "archive-test-repo"
+	runCmd(t, []string{"repo",
+		"create",
+		"--description", "testing",
+		"--repo", repo,
+	}, "create test repo", false)
+
+	tmp, err := os.MkdirTemp("", "test-archive-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+	source, restored, list := filepath.Join(tmp, "source"), filepath.Join(tmp, "restored"), filepath.Join(tmp, "files.txt")
+
+	old := time.Now().Add(-72 * time.Hour)
+	for _, file := range []string{"a", "b/c", "b/d", "recent"} {
+		path := filepath.Join(source, filepath.FromSlash(file))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte("content of "+file), 0600))
+		if file != "recent" {
+			require.NoError(t, os.Chtimes(path, old, old))
+		}
+	}
+
+	runCmd(t, []string{"archive",
+		"generate",
+		"--path", source,
+		"--out", list,
+		"--modified-before", time.Now().Add(-24 * time.Hour).Format(time.RFC3339),
+	}, "generate list of files", false)
+	b, err := ioutil.ReadFile(list)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b/c", "b/d"}, strings.Fields(string(b)))
+
+	runCmd(t, []string{"archive",
+		"upload",
+		"--path", source,
+		"--files", list,
+		"--repo", repo,
+		"--message", "archived files",
+		"--label", "archived",
+		"--unlink",
+		"--concurrency-factor", concurrencyFactor,
+	}, "upload archive", false)
+	assert.NoFileExists(t, list+".checkpoint", "checkpoint is removed after upload")
+	assert.NoFileExists(t, filepath.Join(source, "a"), "archived files are unlinked")
+	assert.FileExists(t, filepath.Join(source, "recent"), "files not archived are kept")
+
+	bundles, err := listBundles(t, repo)
+	require.NoError(t, err)
+	require.Equal(t, 1, bundles.Len())
+	assert.Len(t, listBundleFiles(t, repo, bundles.Last().hash), 3)
+
+	runCmd(t, []string{"archive",
+		"restore",
+		"--repo", repo,
+		"--label", "archived",
+		"--destination", restored,
+		"--name-filter", "^b/",
+		"--concurrency-factor", concurrencyFactor,
+	}, "restore archive", false)
+	content, err := ioutil.ReadFile(filepath.Join(restored, "b", "c"))
+	require.NoError(t, err)
+	assert.Equal(t, "content of b/c", string(content))
+	assert.FileExists(t, filepath.Join(restored, "b", "d"))
+	assert.NoFileExists(t, filepath.Join(restored, "a"), "files are selected by name filter")
+}
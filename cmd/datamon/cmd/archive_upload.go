@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/archive"
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var archiveUploadCmd = &cobra.Command{
+	Use:   "upload",
+	Short: "Upload a list of files as a bundle",
+	Long: `Upload the files listed by "datamon archive generate" as a bundle.
+
+Uploaded files are journaled in a local checkpoint file. When an upload is interrupted, running the same command again
+resumes the upload: files already uploaded are not read again, unless their size or modification time has changed since.
+The checkpoint is removed once the bundle is uploaded.
+
+With --unlink, archived files are removed from the source once the bundle is uploaded and labelled.
+`,
+	Example: `% datamon archive upload --path /nfs/project --files files.txt --repo project-archive --message "archive 2019" --label 2019
+Uploaded bundle id:1INzQ5TV4vAAfU2PbRFgPfnzEwR
+set label '2019'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+
+		defer func(t0 time.Time) {
+			cliUsage(t0, "archive upload", err)
+		}(time.Now())
+
+		ctx := cmd.Context()
+
+		optionInputs := newCliOptionInputs(config, &datamonFlags)
+		contributor, err := optionInputs.contributor()
+		if err != nil {
+			wrapFatalln("populate contributor struct", err)
+			return
+		}
+
+		listFile, err := os.Open(datamonFlags.bundle.FileList)
+		if err != nil {
+			wrapFatalln("open list of files", err)
+			return
+		}
+		files, err := archive.ReadFileList(listFile)
+		_ = listFile.Close()
+		if err != nil {
+			wrapFatalln("read list of files", err)
+			return
+		}
+
+		sourceStore, err := optionInputs.srcStore(ctx, false)
+		if err != nil {
+			wrapFatalln("create source store", err)
+			return
+		}
+		logger, err := optionInputs.getLogger()
+		if err != nil {
+			wrapFatalln("get logger", err)
+			return
+		}
+
+		bundleOpts, err := optionInputs.bundleOpts(ctx)
+		if err != nil {
+			wrapFatalln("failed to initialize bundle options", err)
+			return
+		}
+		bundleOpts = append(bundleOpts,
+			core.BundleDescriptor(model.NewBundleDescriptor(
+				model.Message(datamonFlags.bundle.Message),
+				model.BundleContributor(contributor),
+			)),
+			core.ConsumableStore(sourceStore),
+			core.Repo(datamonFlags.repo.RepoName),
+			core.SkipMissing(datamonFlags.bundle.SkipOnError),
+			core.ConcurrentFileUploads(getConcurrencyFactor(fileUploadsByConcurrencyFactor)),
+			core.Logger(logger),
+			core.BundleWithMetrics(datamonFlags.root.metrics.IsEnabled()),
+			core.BundleWithRetry(datamonFlags.fs.WithRetry),
+			core.BundleWithVerifyHash(datamonFlags.fs.WithVerifyHash),
+			core.BundleWithVerifyBlobHash(datamonFlags.fs.WithVerifyBlobHash),
+		)
+		bundle := core.NewBundle(bundleOpts...)
+
+		checkpoint := datamonFlags.archive.Checkpoint
+		if checkpoint == "" {
+			checkpoint = datamonFlags.bundle.FileList + ".checkpoint"
+		}
+
+		err = core.UploadSpecificKeys(ctx, bundle, func() ([]string, error) {
+			return files, nil
+		}, core.WithUploadCheckpoint(checkpoint))
+		if err != nil {
+			wrapFatalln("upload archive", err)
+			return
+		}
+
+		var labelSet string
+		if datamonFlags.label.Name != "" {
+			label := core.NewLabel(
+				core.LabelWithMetrics(datamonFlags.root.metrics.IsEnabled()),
+				core.LabelDescriptor(
+					model.NewLabelDescriptor(
+						model.LabelContributor(contributor),
+						model.LabelName(datamonFlags.label.Name),
+					),
+				))
+			err = label.UploadDescriptor(ctx, bundle)
+			if err != nil {
+				wrapFatalln("upload label", err)
+				return
+			}
+			labelSet = datamonFlags.label.Name
+		}
+
+		var buf bytes.Buffer
+		if err = uploadTemplate(datamonFlags).Execute(&buf, struct {
+			core.Bundle
+			Label string
+		}{Bundle: *bundle, Label: labelSet}); err != nil {
+			wrapFatalln("executing template", err)
+			return
+		}
+		log.Println(buf.String())
+
+		if datamonFlags.archive.Unlink {
+			// only the files recorded by the uploaded bundle are removed: files skipped by the upload are kept
+			var stores context2.Stores
+			stores, err = optionInputs.datamonContext(ctx)
+			if err != nil {
+				wrapFatalln("create remote stores", err)
+				return
+			}
+			archived := core.NewBundle(
+				core.Repo(datamonFlags.repo.RepoName),
+				core.BundleID(bundle.BundleID),
+				core.ContextStores(stores),
+				core.Logger(logger),
+			)
+			if err = core.PopulateFiles(ctx, archived); err != nil {
+				wrapFatalln("read archived files", err)
+				return
+			}
+			names := make([]string, 0, len(archived.BundleEntries))
+			for _, entry := range archived.BundleEntries {
+				names = append(names, entry.NameWithPath)
+			}
+			if err = unlinkArchivedFiles(ctx, sourceStore, names, getConcurrencyFactor(fileUploadsByConcurrencyFactor), logger); err != nil {
+				wrapFatalln("unlink archived files", err)
+				return
+			}
+			infoLogger.Printf("unlinked %d archived files", len(names))
+		}
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+// unlinkArchivedFiles removes archived files from their source.
+//
+// All files are attempted, and the last error is reported.
+func unlinkArchivedFiles(ctx context.Context, store storage.Store, files []string, concurrency int, logger *zap.Logger) error {
+	var (
+		wg       sync.WaitGroup
+		mx       sync.Mutex
+		lastErr  error
+		failures uint64
+	)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	todo := make(chan string)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range todo {
+				if err := store.Delete(ctx, file); err != nil {
+					logger.Warn("could not unlink archived file", zap.String("file", file), zap.Error(err))
+					atomic.AddUint64(&failures, 1)
+					mx.Lock()
+					lastErr = err
+					mx.Unlock()
+				}
+			}
+		}()
+	}
+
+	for _, file := range files {
+		todo <- file
+	}
+	close(todo)
+	wg.Wait()
+
+	if lastErr != nil {
+		logger.Error("some archived files could not be unlinked", zap.Uint64("failures", failures))
+	}
+
+	return lastErr
+}
+
+func init() {
+	requireFlags(archiveUploadCmd,
+		addRepoNameOptionFlag(archiveUploadCmd),
+		addPathFlag(archiveUploadCmd),
+		addFileListFlag(archiveUploadCmd),
+		addCommitMessageFlag(archiveUploadCmd),
+	)
+
+	addLabelNameFlag(archiveUploadCmd)
+	addArchiveCheckpointFlag(archiveUploadCmd)
+	addArchiveUnlinkFlag(archiveUploadCmd)
+	addSkipMissingFlag(archiveUploadCmd)
+	addConcurrencyFactorFlag(archiveUploadCmd, 100)
+	addRetryFlag(archiveUploadCmd)
+	addVerifyHashFlag(archiveUploadCmd)
+	addVerifyBlobHashFlag(archiveUploadCmd)
+
+	archiveCmd.AddCommand(archiveUploadCmd)
+}
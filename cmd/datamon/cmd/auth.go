@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"github.com/oneconcern/datamon/pkg/auth"
+	awsauth "github.com/oneconcern/datamon/pkg/auth/aws"
+	gauth "github.com/oneconcern/datamon/pkg/auth/google"
+	oidcauth "github.com/oneconcern/datamon/pkg/auth/oidc"
+	staticauth "github.com/oneconcern/datamon/pkg/auth/static"
+	"github.com/oneconcern/datamon/pkg/model"
+)
+
+// authConfig resolves the authentication provider settings.
+//
+// The provider is selected by the current context. When the context doesn't specify any,
+// the provider from the local config is used, and eventually defaults to google.
+func (in *cliOptionInputs) authConfig() model.AuthConfig {
+	if in.params.context.Descriptor.Auth.Provider != "" {
+		return in.params.context.Descriptor.Auth
+	}
+	if in.config != nil && in.config.Auth.Provider != "" {
+		return in.config.Auth
+	}
+	return model.AuthConfig{Provider: model.AuthGoogle}
+}
+
+// newAuthorizer builds an authentication provider from its settings
+func newAuthorizer(cfg model.AuthConfig, localConfig *CLIConfig) auth.Authable {
+	switch cfg.Provider {
+	case model.AuthOIDC:
+		return oidcauth.New(
+			oidcauth.TokenFile(cfg.TokenFile),
+			oidcauth.TokenEnv(cfg.TokenEnv),
+		)
+	case model.AuthAWS:
+		return awsauth.New(
+			awsauth.Region(cfg.Region),
+			awsauth.EmailDomain(cfg.Domain),
+		)
+	case model.AuthStatic:
+		opts := []staticauth.Option{staticauth.Keyring(cfg.Keyring)}
+		if localConfig != nil {
+			opts = append(opts, staticauth.Email(localConfig.Email), staticauth.Name(localConfig.Name))
+		}
+		return staticauth.New(opts...)
+	default:
+		return gauth.New()
+	}
+}
@@ -111,3 +111,25 @@ func getConcurrencyFactor(batchSize int) int {
 	}
 	return concurrency
 }
+
+// setBundleOrDiamondPreview determines the bundle to work with, or the preview of a diamond when the --diamond flag is set
+func setBundleOrDiamondPreview(ctx context.Context, remote context2.Stores) (core.BundleOption, error) {
+	if datamonFlags.diamond.diamondID == "" {
+		if err := setLatestOrLabelledBundle(ctx, remote); err != nil {
+			return nil, err
+		}
+		return core.BundleID(datamonFlags.bundle.ID), nil
+	}
+
+	if datamonFlags.bundle.ID != "" || datamonFlags.label.Name != "" {
+		return nil, fmt.Errorf("--%s is mutually exclusive with --%s and --%s",
+			addDiamondPreviewFlag(nil),
+			addBundleFlag(nil),
+			addLabelNameFlag(nil))
+	}
+
+	if datamonFlags.core.Template == "" {
+		log.Printf("Using preview of diamond: %s", datamonFlags.diamond.diamondID)
+	}
+	return core.BundlePreviewDiamond(datamonFlags.diamond.diamondID), nil
+}
@@ -4,7 +4,6 @@ package cmd
 
 import (
 	"bytes"
-	"context"
 	"text/template"
 	"time"
 
@@ -43,7 +42,7 @@ var bundleDiffCmd = &cobra.Command{
 			cliUsage(t0, "bundle diff", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 
 		datamonFlagsPtr := &datamonFlags
 		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
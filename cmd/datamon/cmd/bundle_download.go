@@ -3,7 +3,6 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"regexp"
 	"time"
@@ -26,6 +25,9 @@ that is part of a bundle.
 
 If --bundle is not specified, the latest bundle (aka "commit") will be downloaded.
 
+With --diamond, a preview of a diamond which is not committed yet is downloaded instead.
+Files from all splits done so far are merged as they would be when committing the diamond.
+
 This is analogous to the git command "git checkout {commit-ish}".`,
 	Example: `# Download a bundle by hash
 % datamon bundle download --repo ritesh-test-repo --destination /path/to/folder/to/download --bundle 1INzQ5TV4vAAfU2PbRFgPfnzEwR
@@ -34,6 +36,11 @@ This is analogous to the git command "git checkout {commit-ish}".`,
 % datamon bundle download --repo ritesh-test-repo --destination /path/to/folder/to/download --label init
 Using bundle: 1UZ6kpHe3EBoZUTkKPHSf8s2beh
 ...
+
+# Download a preview of an uncommitted diamond
+% datamon bundle download --repo ritesh-test-repo --destination /path/to/folder/to/download --diamond 1ySIsDlHSX0ZG3ZtYByPqv7dgvU
+Using preview of diamond: 1ySIsDlHSX0ZG3ZtYByPqv7dgvU
+...
 `,
 	Run: func(cmd *cobra.Command, args []string) {
 		var err error
@@ -42,7 +49,7 @@ Using bundle: 1UZ6kpHe3EBoZUTkKPHSf8s2beh
 			cliUsage(t0, "bundle download", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
 		if err != nil {
@@ -60,7 +67,7 @@ Using bundle: 1UZ6kpHe3EBoZUTkKPHSf8s2beh
 			wrapFatalln("create destination store", err)
 			return
 		}
-		err = setLatestOrLabelledBundle(ctx, remoteStores)
+		bundleOrPreview, err := setBundleOrDiamondPreview(ctx, remoteStores)
 		if err != nil {
 			wrapFatalln("determine bundle id", err)
 			return
@@ -72,7 +79,7 @@ Using bundle: 1UZ6kpHe3EBoZUTkKPHSf8s2beh
 		}
 		bundleOpts = append(bundleOpts, core.Repo(datamonFlags.repo.RepoName))
 		bundleOpts = append(bundleOpts, core.ConsumableStore(destinationStore))
-		bundleOpts = append(bundleOpts, core.BundleID(datamonFlags.bundle.ID))
+		bundleOpts = append(bundleOpts, bundleOrPreview)
 		bundleOpts = append(bundleOpts, core.ConcurrentFileDownloads(
 			datamonFlags.bundle.ConcurrencyFactor/fileDownloadsByConcurrencyFactor))
 		bundleOpts = append(bundleOpts, core.ConcurrentFilelistDownloads(
@@ -85,6 +92,18 @@ Using bundle: 1UZ6kpHe3EBoZUTkKPHSf8s2beh
 		bundleOpts = append(bundleOpts, core.Logger(logger))
 		bundleOpts = append(bundleOpts, core.BundleWithMetrics(datamonFlags.root.metrics.IsEnabled()))
 		bundleOpts = append(bundleOpts, core.BundleWithVerifyHash(datamonFlags.fs.WithVerifyHash))
+		leafCache, err := optionInputs.leafCache()
+		if err != nil {
+			wrapFatalln("open disk cache", err)
+			return
+		}
+		bundleOpts = append(bundleOpts, core.BundleWithLeafCache(leafCache))
+		progress, err := optionInputs.progress()
+		if err != nil {
+			wrapFatalln("set up progress reporting", err)
+			return
+		}
+		bundleOpts = append(bundleOpts, core.BundleWithProgress(progress))
 
 		bundle := core.NewBundle(
 			bundleOpts...,
@@ -131,12 +150,16 @@ func init() {
 	addBundleFlag(BundleDownloadCmd)
 
 	addLabelNameFlag(BundleDownloadCmd)
+	addDiamondPreviewFlag(BundleDownloadCmd)
 
 	addConcurrencyFactorFlag(BundleDownloadCmd, 100)
 
 	addNameFilterFlag(BundleDownloadCmd)
 	addForceDestFlag(BundleDownloadCmd)
 	addVerifyHashFlag(BundleDownloadCmd)
+	addDiskCacheFlag(BundleDownloadCmd)
+	addDiskCacheSizeFlag(BundleDownloadCmd)
+	addProgressFlag(BundleDownloadCmd)
 
 	bundleCmd.AddCommand(BundleDownloadCmd)
 }
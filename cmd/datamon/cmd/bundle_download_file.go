@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"time"
 
 	"github.com/oneconcern/datamon/pkg/core"
@@ -24,7 +23,7 @@ You may use the "--label" flag as an alternate way to specify a particular bundl
 			cliUsage(t0, "bundle download file", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
@@ -53,6 +52,12 @@ You may use the "--label" flag as an alternate way to specify a particular bundl
 		bundleOpts = append(bundleOpts, core.ConsumableStore(destinationStore))
 		bundleOpts = append(bundleOpts, core.BundleID(datamonFlags.bundle.ID))
 		bundleOpts = append(bundleOpts, core.BundleWithMetrics(datamonFlags.root.metrics.IsEnabled()))
+		leafCache, err := optionInputs.leafCache()
+		if err != nil {
+			wrapFatalln("open disk cache", err)
+			return
+		}
+		bundleOpts = append(bundleOpts, core.BundleWithLeafCache(leafCache))
 
 		bundle := core.NewBundle(
 			bundleOpts...,
@@ -80,6 +85,8 @@ func init() {
 
 	addLabelNameFlag(bundleDownloadFileCmd)
 	addBundleFlag(bundleDownloadFileCmd)
+	addDiskCacheFlag(bundleDownloadFileCmd)
+	addDiskCacheSizeFlag(bundleDownloadFileCmd)
 
 	BundleDownloadCmd.AddCommand(bundleDownloadFileCmd)
 }
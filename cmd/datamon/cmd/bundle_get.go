@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"bytes"
-	"context"
 	"time"
 
 	"github.com/oneconcern/datamon/pkg/core"
@@ -28,7 +27,7 @@ exits with ENOENT status otherwise.`,
 			cliUsage(t0, "bundle get", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		datamonFlagsPtr := &datamonFlags
 		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
 		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
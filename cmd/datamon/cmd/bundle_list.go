@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"bytes"
-	"context"
 	"fmt"
 	"time"
 
@@ -39,7 +38,7 @@ This is analogous to the "git log" command. The bundle ID works like a git commi
 			cliUsage(t0, "bundle list", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		datamonFlagsPtr := &datamonFlags
 		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
 		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"bytes"
-	"context"
 	"text/template"
 	"time"
 
@@ -33,7 +32,7 @@ name:bundle_upload.go, size:4021, hash:b9258e91eb29fe42c70262dd2da46dd71385995db
 			cliUsage(t0, "bundle list files", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		datamonFlagsPtr := &datamonFlags
 		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
 		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
@@ -56,7 +55,7 @@ name:bundle_upload.go, size:4021, hash:b9258e91eb29fe42c70262dd2da46dd71385995db
 		bundle := core.NewBundle(
 			bundleOpts...,
 		)
-		err = core.PopulateFiles(context.Background(), bundle)
+		err = core.PopulateFiles(ctx, bundle)
 		if err != nil {
 			wrapFatalln("download filelist", err)
 			return
@@ -9,11 +9,13 @@ import (
 
 	daemonizer "github.com/jacobsa/daemonize"
 
+	context2 "github.com/oneconcern/datamon/pkg/context"
 	"github.com/oneconcern/datamon/pkg/core"
 	"github.com/oneconcern/datamon/pkg/fuse"
 	"github.com/oneconcern/datamon/pkg/metrics"
 
 	"github.com/spf13/cobra"
+	"go.uber.org/zap"
 )
 
 func undaemonizeArgs(args []string) []string {
@@ -83,7 +85,28 @@ func onDaemonError(msg string, err error) {
 var mountBundleCmd = &cobra.Command{
 	Use:   "mount",
 	Short: "Mount a bundle",
-	Long:  "Mount a readonly, non-interactive view of the entire data that is part of a bundle",
+	Long: `Mount a readonly, non-interactive view of the entire data that is part of a bundle.
+
+With --diamond, a preview of a diamond which is not committed yet is mounted instead.
+
+With --lazy, the mount is usable as soon as the bundle descriptor is retrieved: the list of files
+is loaded as directories are looked up or listed, and spilled to a local index (see --index-dir).
+This keeps the memory footprint bounded when mounting bundles with many files.
+
+Files expose their provenance as extended attributes: user.datamon.hash, user.datamon.size,
+user.datamon.bundle, user.datamon.repo and user.datamon.label (when mounted with --label).
+The root of the mount exposes the attributes of the bundle.
+
+With --follow, the mount keeps following the label: the label is checked periodically (see --follow-interval),
+and the mount switches to the new bundle whenever the label moves. Files opened before the switch keep
+serving the content of the previous bundle. A followed mount is always streamed.`,
+	Example: `# Mount a bundle, then retrieve the provenance of a file
+% datamon bundle mount --repo ritesh-test-repo --label v1 --mount /path/to/mount
+% getfattr -d /path/to/mount/some/file
+
+# Mount the latest bundle labelled "production", and switch whenever the label moves
+% datamon bundle mount --repo ritesh-test-repo --label production --follow --mount /path/to/mount
+`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if datamonFlags.root.metrics.IsEnabled() {
 			// do not record timings or failures for long running or daemonized commands, do not wait for completion to report
@@ -91,7 +114,7 @@ var mountBundleCmd = &cobra.Command{
 			metrics.Flush()
 		}
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 
 		// cf. comments on runDaemonized
 		if datamonFlags.bundle.Daemonize {
@@ -104,13 +127,17 @@ var mountBundleCmd = &cobra.Command{
 			onDaemonError("create remote stores", err)
 			return
 		}
+		if datamonFlags.fs.Follow {
+			mountFollowedLabel(ctx, optionInputs, remoteStores)
+			return
+		}
 		consumableStore, err := optionInputs.destStore(destTEmpty, "datamon-mount-destination")
 		if err != nil {
 			onDaemonError("create destination store", err)
 			return
 		}
 
-		err = setLatestOrLabelledBundle(ctx, remoteStores)
+		bundleOrPreview, err := setBundleOrDiamondPreview(ctx, remoteStores)
 		if err != nil {
 			onDaemonError("determine bundle id", err)
 			return
@@ -121,7 +148,7 @@ var mountBundleCmd = &cobra.Command{
 		}
 		bundleOpts = append(bundleOpts, core.Repo(datamonFlags.repo.RepoName))
 		bundleOpts = append(bundleOpts, core.ConsumableStore(consumableStore))
-		bundleOpts = append(bundleOpts, core.BundleID(datamonFlags.bundle.ID))
+		bundleOpts = append(bundleOpts, bundleOrPreview)
 		bundleOpts = append(bundleOpts, core.ConcurrentFilelistDownloads(getConcurrencyFactor(filelistDownloadsByConcurrencyFactor)))
 		logger, err := optionInputs.getLogger()
 		if err != nil {
@@ -132,14 +159,25 @@ var mountBundleCmd = &cobra.Command{
 		bundleOpts = append(bundleOpts, core.BundleWithMetrics(datamonFlags.root.metrics.IsEnabled()))
 
 		bundle := core.NewBundle(bundleOpts...)
+		leafCache, err := optionInputs.leafCache()
+		if err != nil {
+			onDaemonError("open disk cache", err)
+			return
+		}
 		var fsOpts []fuse.Option
 		fsOpts = append(fsOpts, fuse.Streaming(datamonFlags.fs.Stream))
 		fsOpts = append(fsOpts, fuse.Logger(logger))
+		fsOpts = append(fsOpts, fuse.Label(datamonFlags.label.Name))
 		if datamonFlags.fs.Stream {
 			fsOpts = append(fsOpts, fuse.CacheSize(int(datamonFlags.fs.CacheSize)))
 			fsOpts = append(fsOpts, fuse.Prefetch(datamonFlags.fs.WithPrefetch))
+			fsOpts = append(fsOpts, fuse.ReadAhead(datamonFlags.fs.ReadAhead))
+			fsOpts = append(fsOpts, fuse.MaxPrefetches(datamonFlags.fs.MaxPrefetches))
 			fsOpts = append(fsOpts, fuse.VerifyHash(datamonFlags.fs.WithVerifyHash))
+			fsOpts = append(fsOpts, fuse.DiskCache(leafCache))
 			fsOpts = append(fsOpts, fuse.WithMetrics(datamonFlags.root.metrics.IsEnabled()))
+			fsOpts = append(fsOpts, fuse.LazyPopulate(datamonFlags.fs.Lazy))
+			fsOpts = append(fsOpts, fuse.IndexDir(datamonFlags.fs.IndexDir))
 		}
 		fs, err := fuse.NewReadOnlyFS(bundle, fsOpts...)
 		if err != nil {
@@ -168,6 +206,65 @@ var mountBundleCmd = &cobra.Command{
 	},
 }
 
+// mountFollowedLabel mounts the bundle pointed to by a label, and follows the label as it moves
+func mountFollowedLabel(ctx context.Context, optionInputs *cliOptionInputs, remoteStores context2.Stores) {
+	if datamonFlags.label.Name == "" || datamonFlags.bundle.ID != "" || datamonFlags.diamond.diamondID != "" {
+		onDaemonError("follow label", fmt.Errorf("--%s requires --%s, and is mutually exclusive with --%s and --%s",
+			addFollowFlag(nil),
+			addLabelNameFlag(nil),
+			addBundleFlag(nil),
+			addDiamondPreviewFlag(nil)))
+		return
+	}
+	logger, err := optionInputs.getLogger()
+	if err != nil {
+		onDaemonError("get logger", err)
+		return
+	}
+	leafCache, err := optionInputs.leafCache()
+	if err != nil {
+		onDaemonError("open disk cache", err)
+		return
+	}
+
+	var fsOpts []fuse.Option
+	fsOpts = append(fsOpts, fuse.Logger(logger))
+	fsOpts = append(fsOpts, fuse.FollowInterval(datamonFlags.fs.FollowInterval))
+	fsOpts = append(fsOpts, fuse.OnLabelSwitch(func(event fuse.LabelSwitch) {
+		logger.Info("label moved: mount switched to new bundle",
+			zap.String("label", event.Label), zap.String("previous", event.Previous), zap.String("current", event.Current))
+	}))
+	fsOpts = append(fsOpts, fuse.CacheSize(int(datamonFlags.fs.CacheSize)))
+	fsOpts = append(fsOpts, fuse.Prefetch(datamonFlags.fs.WithPrefetch))
+	fsOpts = append(fsOpts, fuse.ReadAhead(datamonFlags.fs.ReadAhead))
+	fsOpts = append(fsOpts, fuse.MaxPrefetches(datamonFlags.fs.MaxPrefetches))
+	fsOpts = append(fsOpts, fuse.VerifyHash(datamonFlags.fs.WithVerifyHash))
+	fsOpts = append(fsOpts, fuse.DiskCache(leafCache))
+	fsOpts = append(fsOpts, fuse.WithMetrics(datamonFlags.root.metrics.IsEnabled()))
+	fsOpts = append(fsOpts, fuse.LazyPopulate(datamonFlags.fs.Lazy))
+	fsOpts = append(fsOpts, fuse.IndexDir(datamonFlags.fs.IndexDir))
+
+	fs, err := fuse.NewFollowFS(remoteStores, datamonFlags.repo.RepoName, datamonFlags.label.Name, fsOpts...)
+	if err != nil {
+		onDaemonError("create followed filesystem", err)
+		return
+	}
+	if err = fs.MountReadOnly(datamonFlags.fs.MountPath); err != nil {
+		onDaemonError("mount followed filesystem", err)
+		return
+	}
+
+	registerSIGINTHandlerMount(datamonFlags.fs.MountPath)
+	if err = daemonizer.SignalOutcome(nil); err != nil {
+		wrapFatalln("send event from possibly daemonized process", err)
+		return
+	}
+	if err = fs.JoinMount(ctx); err != nil {
+		wrapFatalln("block on os mount", err)
+		return
+	}
+}
+
 func init() {
 	requireFlags(mountBundleCmd,
 		addRepoNameOptionFlag(mountBundleCmd),
@@ -178,13 +275,22 @@ func init() {
 	addBundleFlag(mountBundleCmd)
 	addStreamFlag(mountBundleCmd)
 	addLabelNameFlag(mountBundleCmd)
+	addDiamondPreviewFlag(mountBundleCmd)
 	addConcurrencyFactorFlag(mountBundleCmd, 100)
 	// todo: #165 add --cpuprof to all commands via root
 	addCPUProfFlag(mountBundleCmd)
 	addDataPathFlag(mountBundleCmd)
 	addCacheSizeFlag(mountBundleCmd)
 	addPrefetchFlag(mountBundleCmd)
+	addReadAheadFlag(mountBundleCmd)
+	addMaxPrefetchesFlag(mountBundleCmd)
 	addVerifyHashFlag(mountBundleCmd)
+	addLazyFlag(mountBundleCmd)
+	addIndexDirFlag(mountBundleCmd)
+	addDiskCacheFlag(mountBundleCmd)
+	addDiskCacheSizeFlag(mountBundleCmd)
+	addFollowFlag(mountBundleCmd)
+	addFollowIntervalFlag(mountBundleCmd)
 
 	bundleCmd.AddCommand(mountBundleCmd)
 }
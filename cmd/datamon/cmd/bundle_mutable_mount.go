@@ -4,6 +4,7 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 
 	daemonizer "github.com/jacobsa/daemonize"
 
@@ -19,7 +20,12 @@ var mutableMountBundleCmd = &cobra.Command{
 	Use:   "new",
 	Short: "Create a bundle incrementally with filesystem operations",
 	Long: `Write directories and files to the mountpoint.  Unmount or send SIGINT to this process to save.
-The destination path is a temporary staging area for write operations.`,
+The destination path is a temporary staging area for write operations.
+
+The mount may start from the content of an existing bundle, specified by its ID or by a label.
+Files from this bundle are fetched when read, and only the changed files are uploaded when saving.
+The new bundle refers to this bundle as its parent.`,
+	Example: `% datamon bundle mount new --repo ritesh-test-repo --mount /tmp/mnt --message "edit" --from-label latest`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if datamonFlags.root.metrics.IsEnabled() {
 			// do not record timings or failures for long running or daemonized commands, do not wait for completion to report
@@ -27,7 +33,7 @@ The destination path is a temporary staging area for write operations.`,
 			metrics.Flush()
 		}
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 
 		// cf. comments on runDaemonized in bundle_mount.go
 		if datamonFlags.bundle.Daemonize {
@@ -55,6 +61,16 @@ The destination path is a temporary staging area for write operations.`,
 			onDaemonError("failed to initialize bundle options", err)
 			return
 		}
+
+		var source *core.Bundle
+		if datamonFlags.bundle.FromID != "" || datamonFlags.bundle.FromLabel != "" {
+			source, err = sourceBundle(ctx, bundleOpts)
+			if err != nil {
+				onDaemonError("resolve source bundle", err)
+				return
+			}
+		}
+
 		bundleOpts = append(bundleOpts, core.BundleDescriptor(bd))
 		bundleOpts = append(bundleOpts, core.Repo(datamonFlags.repo.RepoName))
 		bundleOpts = append(bundleOpts, core.ConsumableStore(consumableStore))
@@ -71,10 +87,20 @@ The destination path is a temporary staging area for write operations.`,
 
 		bundle := core.NewBundle(bundleOpts...)
 
+		leafCache, err := optionInputs.leafCache()
+		if err != nil {
+			onDaemonError("open disk cache", err)
+			return
+		}
+
 		var fsOpts []fuse.Option
 		fsOpts = append(fsOpts, fuse.Logger(logger))
 		fsOpts = append(fsOpts, fuse.WithMetrics(datamonFlags.root.metrics.IsEnabled()))
 		fsOpts = append(fsOpts, fuse.VerifyHash(datamonFlags.fs.WithVerifyHash))
+		if source != nil {
+			fsOpts = append(fsOpts, fuse.SourceBundle(source))
+			fsOpts = append(fsOpts, fuse.DiskCache(leafCache))
+		}
 
 		fs, err := fuse.NewMutableFS(bundle, fsOpts...)
 		if err != nil {
@@ -124,6 +150,36 @@ The destination path is a temporary staging area for write operations.`,
 	},
 }
 
+// sourceBundle resolves the existing bundle a mutable mount starts from
+func sourceBundle(ctx context.Context, bundleOpts []core.BundleOption) (*core.Bundle, error) {
+	if datamonFlags.bundle.FromID != "" && datamonFlags.bundle.FromLabel != "" {
+		return nil, fmt.Errorf("--%s and --%s flags are mutually exclusive",
+			addFromBundleFlag(nil),
+			addFromLabelFlag(nil))
+	}
+
+	opts := make([]core.BundleOption, 0, len(bundleOpts)+2)
+	opts = append(opts, bundleOpts...)
+	opts = append(opts, core.Repo(datamonFlags.repo.RepoName))
+
+	bundleID := datamonFlags.bundle.FromID
+	if datamonFlags.bundle.FromLabel != "" {
+		label := core.NewLabel(
+			core.LabelWithMetrics(datamonFlags.root.metrics.IsEnabled()),
+			core.LabelDescriptor(
+				model.NewLabelDescriptor(
+					model.LabelName(datamonFlags.bundle.FromLabel),
+				),
+			))
+		if err := label.DownloadDescriptor(ctx, core.NewBundle(opts...), true); err != nil {
+			return nil, err
+		}
+		bundleID = label.Descriptor.BundleID
+	}
+
+	return core.NewBundle(append(opts, core.BundleID(bundleID))...), nil
+}
+
 func init() {
 	requireFlags(mutableMountBundleCmd,
 		addRepoNameOptionFlag(mutableMountBundleCmd),
@@ -136,6 +192,10 @@ func init() {
 	addLabelNameFlag(mutableMountBundleCmd)
 	addVerifyHashFlag(mutableMountBundleCmd)
 	addVerifyBlobHashFlag(mutableMountBundleCmd)
+	addFromBundleFlag(mutableMountBundleCmd)
+	addFromLabelFlag(mutableMountBundleCmd)
+	addDiskCacheFlag(mutableMountBundleCmd)
+	addDiskCacheSizeFlag(mutableMountBundleCmd)
 
 	mountBundleCmd.AddCommand(mutableMountBundleCmd)
 }
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotBundle(t *testing.T) {
+	cleanup := setupTests(t)
+	defer cleanup()
+
+	const repo = "snapshot-test-repo"
+	runCmd(t, []string{"repo",
+		"create",
+		"--description", "testing",
+		"--repo", repo,
+	}, "create test repo", false)
+
+	source, err := os.MkdirTemp("", "test-snapshot-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(source)
+	}()
+
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write := func(file, content string, modTime time.Time) {
+		path := filepath.Join(source, filepath.FromSlash(file))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	// bundles uploaded within the same second are not ordered: bundles are found by message
+	hashes := func(message string) map[string]string {
+		bundles, err := listBundles(t, repo)
+		require.NoError(t, err)
+		var bundleID string
+		for _, bundle := range bundles {
+			if bundle.message == message {
+				bundleID = bundle.hash
+			}
+		}
+		require.NotEmpty(t, bundleID, message)
+
+		hashes := make(map[string]string)
+		for _, entry := range listBundleFiles(t, repo, bundleID) {
+			hashes[entry.name] = entry.hash
+		}
+		return hashes
+	}
+	for _, file := range []string{"a", "b/c", "b/d"} {
+		write(file, "content of "+file, mtime)
+	}
+
+	runCmd(t, []string{"bundle",
+		"snapshot",
+		"--path", source,
+		"--repo", repo,
+		"--message", "first snapshot",
+		"--label", "nightly",
+		"--concurrency-factor", concurrencyFactor,
+	}, "upload first snapshot", false)
+	first := hashes("first snapshot")
+	require.Len(t, first, 3)
+
+	write("b/d", "changed content of b/d", mtime.Add(time.Minute))
+	write("e", "content of e", mtime)
+
+	runCmd(t, []string{"bundle",
+		"snapshot",
+		"--path", source,
+		"--repo", repo,
+		"--message", "second snapshot",
+		"--from-label", "nightly",
+		"--readdir-workers", "2",
+		"--concurrency-factor", concurrencyFactor,
+	}, "upload second snapshot", false)
+	second := hashes("second snapshot")
+	require.Len(t, second, 4, "a snapshot is a full bundle")
+	assert.Equal(t, first["a"], second["a"])
+	assert.Equal(t, first["b/c"], second["b/c"])
+	assert.NotEqual(t, first["b/d"], second["b/d"])
+	assert.Contains(t, second, "e")
+
+	runCmd(t, []string{"bundle",
+		"snapshot",
+		"--path", source,
+		"--repo", repo,
+		"--message", "conflicting previous bundle",
+		"--from-label", "nightly",
+		"--from-bundle", "1INzQ5TV4vAAfU2PbRFgPfnzEwR",
+	}, "reject conflicting flags", true)
+}
@@ -3,7 +3,6 @@
 package cmd
 
 import (
-	"context"
 	"path/filepath"
 	"time"
 
@@ -25,7 +24,7 @@ var bundleUpdateCmd = &cobra.Command{
 			cliUsage(t0, "bundle update", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
 		if err != nil {
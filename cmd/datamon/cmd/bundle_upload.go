@@ -3,7 +3,6 @@ package cmd
 import (
 	"bufio"
 	"bytes"
-	"context"
 	"fmt"
 	"os"
 	"text/template"
@@ -42,7 +41,7 @@ set label 'init'
 			cliUsage(t0, "bundle upload", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		contributor, err := optionInputs.contributor()
@@ -81,6 +80,12 @@ set label 'init'
 		bundleOpts = append(bundleOpts, core.BundleWithRetry(datamonFlags.fs.WithRetry))
 		bundleOpts = append(bundleOpts, core.BundleWithVerifyHash(datamonFlags.fs.WithVerifyHash))
 		bundleOpts = append(bundleOpts, core.BundleWithVerifyBlobHash(datamonFlags.fs.WithVerifyBlobHash))
+		progress, err := optionInputs.progress()
+		if err != nil {
+			wrapFatalln("set up progress reporting", err)
+			return
+		}
+		bundleOpts = append(bundleOpts, core.BundleWithProgress(progress))
 
 		// feature guard
 		if enableBundlePreserve {
@@ -167,6 +172,7 @@ func init() {
 	addRetryFlag(uploadBundleCmd)
 	addVerifyHashFlag(uploadBundleCmd)
 	addVerifyBlobHashFlag(uploadBundleCmd)
+	addProgressFlag(uploadBundleCmd)
 
 	// feature guard
 	if enableBundlePreserve {
@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"github.com/oneconcern/datamon/pkg/errors"
+	"github.com/oneconcern/datamon/pkg/model"
 	"github.com/oneconcern/datamon/pkg/storage"
 	storagestatus "github.com/oneconcern/datamon/pkg/storage/status"
 	"github.com/spf13/cobra"
@@ -56,7 +57,9 @@ type CLIConfig struct {
 	Context    string `json:"context" yaml:"context"`       // Current context for datamon
 	logger     *zap.Logger
 	onceLogger sync.Once
-	Metrics    metricsFlags `json:"metrics,omitempty" yaml:"metrics,omitempty"`
+	Metrics    metricsFlags     `json:"metrics,omitempty" yaml:"metrics,omitempty"`
+	Tracing    tracingFlags     `json:"tracing,omitempty" yaml:"tracing,omitempty"`
+	Auth       model.AuthConfig `json:"auth,omitempty" yaml:"auth,omitempty"` // Auth provider used when the context doesn't specify any
 }
 
 // MarshalConfig produces a CLI config as a YAML document
@@ -51,6 +51,11 @@ config file created in /Users/ritesh/.config/.datamon/config.yaml
 			Context:    datamonFlags.context.Descriptor.Name,
 			Credential: datamonFlags.root.credFile,
 			Metrics:    datamonFlags.root.metrics,
+			Tracing:    datamonFlags.root.tracing,
+			// retain the identity settings of an existing config
+			Email: config.Email,
+			Name:  config.Name,
+			Auth:  config.Auth,
 		}
 
 		file := configFileLocation(true)
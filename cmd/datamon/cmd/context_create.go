@@ -18,7 +18,19 @@ import (
 var ContextCreateCommand = &cobra.Command{
 	Use:   "create",
 	Short: "Create a context",
-	Long:  "Create a context for Datamon",
+	Long: `Create a context for Datamon.
+
+A context may specify how contributors are identified, with the "--auth-provider" flag:
+  - google (default): the Google userinfo API, with the credentials from the local config
+  - oidc: an OIDC ID token, read from a file or from an environment variable
+  - aws: the AWS STS caller identity, with the credentials from the AWS SDK default chain
+  - static: the email and name from the local config, or an identity stored in the system keyring
+
+A context may be mirrored to a replica for disaster recovery, possibly hosted on another cloud provider,
+with the "--replica-blob", "--replica-meta" and "--replica-vmeta" flags.
+Writes to the blob and metadata buckets are duplicated to the replica. Failures to write to the replica are tolerated:
+use "datamon context sync" to bring the replica up to date.
+`,
 	Run: func(cmd *cobra.Command, args []string) {
 		var err error
 
@@ -42,7 +54,11 @@ func createContext() {
 	if err != nil {
 		wrapFatalln("failed to create config store", err)
 	}
-	err = context.CreateContext(context2.Background(), configStore, datamonFlags.context.Descriptor)
+	descriptor := datamonFlags.context.Descriptor
+	if replica := datamonFlags.context.Replica; replica.Blob != "" || replica.Metadata != "" || replica.VMetadata != "" {
+		descriptor.Replica = &replica
+	}
+	err = context.CreateContext(context2.Background(), configStore, descriptor)
 	if err != nil {
 		wrapFatalln("failed to create context: "+datamonFlags.context.Descriptor.Name, err)
 	}
@@ -57,6 +73,17 @@ func init() {
 		addReadLogBucket(ContextCreateCommand),
 		addContextFlag(ContextCreateCommand),
 	)
+	addAuthProviderFlag(ContextCreateCommand)
+	addAuthTokenFileFlag(ContextCreateCommand)
+	addAuthTokenEnvFlag(ContextCreateCommand)
+	addAuthRegionFlag(ContextCreateCommand)
+	addAuthDomainFlag(ContextCreateCommand)
+	addAuthKeyringFlag(ContextCreateCommand)
+	addReplicaBlobBucket(ContextCreateCommand)
+	addReplicaMetadataBucket(ContextCreateCommand)
+	addReplicaVMetadataBucket(ContextCreateCommand)
+	addReplicaBackendFlag(ContextCreateCommand)
+	addReplicaRegionFlag(ContextCreateCommand)
 
 	ContextCmd.AddCommand(ContextCreateCommand)
 }
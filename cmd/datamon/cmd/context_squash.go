@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"time"
 
 	context2 "github.com/oneconcern/datamon/pkg/context"
@@ -28,7 +27,7 @@ This command is equivalent to repeating "datamon repo squash" over all the repos
 			cliUsage(t0, "context squash", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		remoteStores, err := optionInputs.datamonContext(ctx)
 		if err != nil {
@@ -42,7 +41,7 @@ This command is equivalent to repeating "datamon repo squash" over all the repos
 		}
 
 		// retrieve all repos in this context
-		err = core.ListReposApply(remoteStores, applyRepoSquash(remoteStores, &datamonFlags, logger),
+		err = core.ListReposApply(remoteStores, applyRepoSquash(remoteStores, &datamonFlags, optionInputs.optionalContributor(), logger),
 			core.ConcurrentList(datamonFlags.core.ConcurrencyFactor),
 			core.BatchSize(datamonFlags.core.BatchSize),
 			core.WithMetrics(datamonFlags.root.metrics.IsEnabled()),
@@ -58,7 +57,7 @@ This command is equivalent to repeating "datamon repo squash" over all the repos
 	},
 }
 
-func applyRepoSquash(remoteStores context2.Stores, datamonFlags *flagsT, logger *zap.Logger) func(model.RepoDescriptor) error {
+func applyRepoSquash(remoteStores context2.Stores, datamonFlags *flagsT, contributor model.Contributor, logger *zap.Logger) func(model.RepoDescriptor) error {
 	return func(repo model.RepoDescriptor) error {
 		logger.Info("squashing repo",
 			zap.String("repo", repo.Name),
@@ -72,6 +71,7 @@ func applyRepoSquash(remoteStores context2.Stores, datamonFlags *flagsT, logger
 			core.WithRetainNLatest(datamonFlags.squash.RetainNLatest),
 			core.ConcurrentList(datamonFlags.core.ConcurrencyFactor),
 			core.BatchSize(datamonFlags.core.BatchSize),
+			core.WithContributor(contributor),
 		)
 	}
 }
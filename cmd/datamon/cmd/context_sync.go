@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	gcscontext "github.com/oneconcern/datamon/pkg/context/gcs"
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/oneconcern/datamon/pkg/storage/gcs"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var contextSync = &cobra.Command{
+	Use:   "sync",
+	Short: "Bring the replica of a context up to date",
+	Long: `Copy to the replica of a context any blob or metadata object missing on the replica.
+
+Writes to a context with a replica are duplicated to the replica, but failures to write to the replica are tolerated.
+This command scans the blob, metadata and versioned metadata buckets of the context, and copies to the replica all missing objects.
+Versioned metadata, such as labels, are copied again whenever the replica holds a different version.
+
+The command exits with a non-zero status whenever some objects could not be copied.
+`,
+	Example: `% datamon context sync --context dev
+
+# Report about missing objects only
+% datamon context sync --context dev --dry-run`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+
+		defer func(t0 time.Time) {
+			cliUsage(t0, "context sync", err)
+		}(time.Now())
+
+		ctx := cmd.Context()
+		optionInputs := newCliOptionInputs(config, &datamonFlags)
+		descriptor := datamonFlags.context.Descriptor
+		if descriptor.Replica == nil {
+			err = fmt.Errorf("context %q has no replica", descriptor.Name)
+			wrapFatalln("context sync", err)
+			return
+		}
+
+		remoteStores, err := optionInputs.datamonContext(ctx)
+		if err != nil {
+			wrapFatalln("create remote stores", err)
+			return
+		}
+		logger, err := optionInputs.getLogger()
+		if err != nil {
+			wrapFatalln("create logger", err)
+			return
+		}
+		replicaStores, err := gcscontext.MakeReplicaContext(ctx, descriptor, optionInputs.config.Credential,
+			gcs.Logger(logger),
+			gcs.WithRetry(datamonFlags.fs.WithRetry),
+		)
+		if err != nil {
+			wrapFatalln("create replica stores", err)
+			return
+		}
+
+		logger.Info("synchronizing replica",
+			zap.String("context", descriptor.Name),
+			zap.String("replica backend", string(descriptor.Replica.Backend)),
+			zap.String("replica BLOB bucket", descriptor.Replica.Blob),
+			zap.Bool("dry-run", datamonFlags.context.DryRun),
+		)
+
+		report, err := core.SyncContext(remoteStores, replicaStores,
+			core.WithSyncLogger(logger),
+			core.WithSyncParallel(datamonFlags.core.ConcurrencyFactor),
+			core.WithSyncBatchSize(datamonFlags.core.BatchSize),
+			core.WithSyncDryRun(datamonFlags.context.DryRun),
+		)
+		if err != nil {
+			wrapFatalln("context sync", err)
+			return
+		}
+
+		for _, store := range report.Stores {
+			log.Printf("%s -> %s: %d objects scanned, %d missing, %d copied, %d failed",
+				store.Store, store.Replica, store.Scanned, store.Missing, store.Copied, store.Failed,
+			)
+		}
+
+		if failed := report.Failed(); failed > 0 {
+			err = fmt.Errorf("%d objects could not be copied to the replica", failed)
+			wrapFatalln("context sync", err)
+		}
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+func init() {
+	requireFlags(contextSync,
+		addContextFlag(contextSync),
+	)
+	addContextSyncDryRunFlag(contextSync)
+	addCoreConcurrencyFactorFlag(contextSync, 10)
+	addBatchSizeFlag(contextSync)
+
+	ContextCmd.AddCommand(contextSync)
+}
@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"context"
-
 	"github.com/oneconcern/datamon/pkg/core"
 	"github.com/oneconcern/datamon/pkg/model"
 
@@ -15,7 +13,7 @@ var CancelDiamondCmd = &cobra.Command{
 	Short: "Cancels a diamond",
 	Long:  `Explicitly cancels a diamond: no commit operation will be accepted`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx := cmd.Context()
 		datamonFlagsPtr := &datamonFlags
 		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
 		remoteStores, err := optionInputs.datamonContext(ctx)
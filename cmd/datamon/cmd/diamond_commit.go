@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"bytes"
-	"context"
 	"fmt"
 
 	"github.com/oneconcern/datamon/pkg/core"
@@ -17,7 +16,7 @@ var CommitDiamondCmd = &cobra.Command{
 	Short: "Commits a diamond",
 	Long:  `Commits a diamond to create a bundle from multiple uploaded splits, with conflicts handling`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx := cmd.Context()
 		datamonFlagsPtr := &datamonFlags
 		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
 		remoteStores, err := optionInputs.datamonContext(ctx)
@@ -44,6 +43,12 @@ var CommitDiamondCmd = &cobra.Command{
 			wrapFatalln("incompatible conflict flags", err)
 		}
 
+		progress, err := optionInputs.progress()
+		if err != nil {
+			wrapFatalln("set up progress reporting", err)
+			return
+		}
+
 		d := core.NewDiamond(datamonFlags.repo.RepoName, remoteStores,
 			core.DiamondDescriptor(model.NewDiamondDescriptor(
 				model.DiamondClone(diamond),
@@ -55,6 +60,7 @@ var CommitDiamondCmd = &cobra.Command{
 			core.DiamondLogger(logger),
 			core.DiamondBundleID(datamonFlags.bundle.ID),
 			core.DiamondWithMetrics(datamonFlags.root.metrics.IsEnabled()),
+			core.DiamondWithProgress(progress),
 		)
 
 		err = d.Commit()
@@ -115,6 +121,7 @@ func init() {
 
 	addLabelNameFlag(CommitDiamondCmd)
 	addConcurrencyFactorFlag(CommitDiamondCmd, 100)
+	addProgressFlag(CommitDiamondCmd)
 
 	// feature guard
 	if enableBundlePreserve {
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+var conflictTemplate func(flagsT) *template.Template
+
+// ConflictsCmd is the root command for all conflicts related subcommands
+var ConflictsCmd = &cobra.Command{
+	Use:   "conflicts",
+	Short: "Commands to inspect and resolve conflicts in diamonds",
+	Long: `Conflicts occur whenever several splits of a diamond upload the same file with different content.
+
+When committing a diamond, the latest uploaded version of a file prevails.
+Other versions are kept in the .conflicts (or .checkpoints) folder of the resulting bundle.`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+func init() {
+	addSkipAuthFlag(ConflictsCmd)
+	DiamondCmd.AddCommand(ConflictsCmd)
+}
+
+func init() {
+	conflictTemplate = func(opts flagsT) *template.Template {
+		if opts.core.Template != "" {
+			t, err := template.New("conflict").Parse(datamonFlags.core.Template)
+			if err != nil {
+				wrapFatalln("invalid template", err)
+			}
+			return t
+		}
+		const conflictTemplateString = `{{.Path}}{{range .Versions}}
+  {{.SplitID}},{{.Hash}},{{.Size}},{{.Timestamp}}{{if .Latest}},latest{{end}}{{end}}`
+		return template.Must(template.New("conflict").Parse(conflictTemplateString))
+	}
+}
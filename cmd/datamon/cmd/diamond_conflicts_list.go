@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+
+	"github.com/oneconcern/datamon/pkg/core"
+
+	"github.com/spf13/cobra"
+)
+
+// ListConflictsCmd lists the conflicts detected on a diamond
+var ListConflictsCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List conflicts in a diamond",
+	Long: `List all files uploaded with a different content by several splits of a diamond.
+
+For every conflicting file, all competing versions are reported, with the split which uploaded it,
+the hash of its content, its size and its upload time. The version which prevails at commit time is marked "latest".
+
+If the diamond is not committed yet, all splits done so far are inspected.`,
+	Example: `% datamon diamond conflicts list --repo ritesh-test-repo --diamond 1ySIsDlHSX0ZG3ZtYByPqv7dgvU
+common/data.csv
+  1ySItWf1pVmhwGJ15f2RAfOx1S0,d6b2c61c...,1024,2021-01-06 11:46:31.1 +0100 CET
+  1ySItZ9aBWiRkUwGBNjxtEQhoCq,7f9c2ba4...,1032,2021-01-06 11:46:33.7 +0100 CET,latest`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		datamonFlagsPtr := &datamonFlags
+		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
+		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
+		if err != nil {
+			wrapFatalln("create remote stores", err)
+			return
+		}
+		logger, err := optionInputs.getLogger()
+		if err != nil {
+			wrapFatalln("get logger", err)
+			return
+		}
+
+		conflicts, err := core.ListConflicts(datamonFlags.repo.RepoName, datamonFlags.diamond.diamondID, remoteStores,
+			core.DiamondLogger(logger),
+			core.DiamondWithMetrics(datamonFlags.root.metrics.IsEnabled()))
+		if err != nil {
+			wrapFatalln("list conflicts", err)
+			return
+		}
+
+		tpl := conflictTemplate(datamonFlags)
+		for _, conflict := range conflicts {
+			var buf bytes.Buffer
+			if err = tpl.Execute(&buf, conflict); err != nil {
+				wrapFatalln("executing template", err)
+				return
+			}
+			log.Println(buf.String())
+		}
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+func init() {
+	requireFlags(ListConflictsCmd,
+		addRepoNameOptionFlag(ListConflictsCmd),
+		addDiamondFlag(ListConflictsCmd),
+	)
+
+	ConflictsCmd.AddCommand(ListConflictsCmd)
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/oneconcern/datamon/pkg/model"
+
+	"github.com/spf13/cobra"
+)
+
+// ResolveConflictsCmd resolves the conflicts of a committed diamond
+var ResolveConflictsCmd = &cobra.Command{
+	Use:   "resolve",
+	Short: "Resolve conflicts in a committed diamond",
+	Long: `Resolve the conflicts of a committed diamond by producing a new bundle.
+
+For every conflicting file, the new bundle retains the version uploaded by the split designated by --keep,
+or the latest uploaded version with --keep latest. Whenever the designated split did not upload a conflicting file,
+the latest version is retained.
+
+The new bundle does not contain any .conflicts or .checkpoints folder. It refers to the diamond bundle as its parent.`,
+	Example: `% datamon diamond conflicts resolve --repo ritesh-test-repo --diamond 1ySIsDlHSX0ZG3ZtYByPqv7dgvU --keep 1ySItWf1pVmhwGJ15f2RAfOx1S0
+Uploaded bundle id:1ySJ2rLFbQc6VuwQ0Ye1vU3qGoA`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		datamonFlagsPtr := &datamonFlags
+		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
+		remoteStores, err := optionInputs.datamonContext(ctx)
+		if err != nil {
+			wrapFatalln("create remote stores", err)
+			return
+		}
+		logger, err := optionInputs.getLogger()
+		if err != nil {
+			wrapFatalln("get logger", err)
+			return
+		}
+
+		bundle, err := core.ResolveConflicts(datamonFlags.repo.RepoName, datamonFlags.diamond.diamondID, datamonFlags.diamond.keep, remoteStores,
+			core.DiamondMessage(datamonFlags.bundle.Message),
+			core.DiamondLogger(logger),
+			core.DiamondWithMetrics(datamonFlags.root.metrics.IsEnabled()))
+		if err != nil {
+			wrapFatalln("resolve conflicts", err)
+			return
+		}
+
+		var labelSet string
+		defer func() {
+			var buf bytes.Buffer
+			if ert := uploadTemplate(datamonFlags).Execute(&buf, struct {
+				core.Bundle
+				Label string
+			}{Bundle: *bundle, Label: labelSet}); ert != nil {
+				wrapFatalln("executing template", ert)
+			}
+			log.Println(buf.String())
+		}()
+
+		if datamonFlags.label.Name != "" {
+			label := core.NewLabel(
+				core.LabelDescriptor(
+					model.NewLabelDescriptor(
+						model.LabelContributors(bundle.BundleDescriptor.Contributors),
+						model.LabelName(datamonFlags.label.Name),
+					),
+				))
+			err = label.UploadDescriptor(ctx, bundle)
+			if err != nil {
+				wrapFatalln("upload label", err)
+				return
+			}
+			labelSet = datamonFlags.label.Name
+		}
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+func init() {
+	requireFlags(ResolveConflictsCmd,
+		addRepoNameOptionFlag(ResolveConflictsCmd),
+		addDiamondFlag(ResolveConflictsCmd),
+		addConflictKeepFlag(ResolveConflictsCmd),
+	)
+
+	addCommitMessageFlag(ResolveConflictsCmd)
+	addLabelNameFlag(ResolveConflictsCmd)
+
+	ConflictsCmd.AddCommand(ResolveConflictsCmd)
+}
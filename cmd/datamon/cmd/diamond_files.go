@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/oneconcern/datamon/pkg/model"
+
+	"github.com/spf13/cobra"
+)
+
+// ListDiamondFilesCmd lists the files of a diamond
+var ListDiamondFilesCmd = &cobra.Command{
+	Use:   "files",
+	Short: "List files in a diamond",
+	Long: `List all the files in a diamond, as they would be committed in a bundle.
+
+Files from all splits done so far are merged the same way as a diamond commit does.
+Conflicting versions are reported in the .conflicts (or .checkpoints) folder, depending on the conflicts
+handling mode of the diamond.
+
+For a diamond which is already committed, the files merged at commit time are listed.`,
+	Example: `% datamon diamond files --repo ritesh-test-repo --diamond 1ySIsDlHSX0ZG3ZtYByPqv7dgvU
+name:.conflicts/1ySItWf1pVmhwGJ15f2RAfOx1S0/common/data.csv, size:1024, hash:d6b2c61c...
+name:common/data.csv, size:1032, hash:7f9c2ba4...
+...`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		datamonFlagsPtr := &datamonFlags
+		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
+		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
+		if err != nil {
+			wrapFatalln("create remote stores", err)
+			return
+		}
+		logger, err := optionInputs.getLogger()
+		if err != nil {
+			wrapFatalln("get logger", err)
+			return
+		}
+
+		entries, err := core.ListDiamondFiles(datamonFlags.repo.RepoName, datamonFlags.diamond.diamondID, remoteStores,
+			core.DiamondLogger(logger),
+			core.DiamondWithMetrics(datamonFlags.root.metrics.IsEnabled()))
+		if err != nil {
+			wrapFatalln("list diamond files", err)
+			return
+		}
+
+		if err = applyFileTemplate(entries); err != nil {
+			wrapFatalln("executing template", err)
+			return
+		}
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+func init() {
+	requireFlags(ListDiamondFilesCmd,
+		addRepoNameOptionFlag(ListDiamondFilesCmd),
+		addDiamondFlag(ListDiamondFilesCmd),
+	)
+
+	DiamondCmd.AddCommand(ListDiamondFilesCmd)
+}
+
+func applyFileTemplate(entries []model.BundleEntry) error {
+	tpl := fileLineTemplate(datamonFlags)
+	for _, entry := range entries {
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, entry); err != nil {
+			return fmt.Errorf("executing template: %w", err)
+		}
+		log.Println(buf.String())
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/oneconcern/datamon/pkg/core"
+
+	"github.com/spf13/cobra"
+)
+
+// GCDiamondCmd collects abandoned diamonds and splits
+var GCDiamondCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Collects abandoned diamonds and splits in a repo",
+	Long: `Cancels diamonds which have not been committed and have seen no activity for longer than the diamond TTL.
+
+Running splits started for longer than the split TTL, as well as running splits of committed or canceled diamonds,
+are marked orphaned.
+
+Index files uploaded by orphaned splits and by the splits of canceled diamonds are removed.
+Orphaned BLOB resources are then reclaimed by the next purge.
+`,
+	Example: `% datamon diamond gc --repo ritesh-test-repo --diamond-ttl 72h --dry-run`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		datamonFlagsPtr := &datamonFlags
+		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
+		remoteStores, err := optionInputs.datamonContext(ctx)
+		if err != nil {
+			wrapFatalln("create remote stores", err)
+			return
+		}
+
+		gc, err := core.GCDiamonds(datamonFlags.repo.RepoName, remoteStores,
+			core.WithDiamondTTL(datamonFlags.diamond.diamondTTL),
+			core.WithSplitTTL(datamonFlags.diamond.splitTTL),
+			core.WithDryRun(datamonFlags.diamond.dryRun),
+			core.ConcurrentList(datamonFlags.core.ConcurrencyFactor),
+			core.BatchSize(datamonFlags.core.BatchSize),
+		)
+		if err != nil {
+			wrapFatalln("diamond gc", err)
+			return
+		}
+
+		if gc.DryRun {
+			log.Println("dry-run: no change applied")
+		}
+		log.Printf("canceled diamonds: %s\n", strings.Join(gc.CanceledDiamonds, ", "))
+		log.Printf("orphaned splits: %s\n", strings.Join(gc.OrphanedSplits, ", "))
+		log.Printf("removed index files: %d\n", gc.DeletedIndexFiles)
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+func init() {
+	requireFlags(GCDiamondCmd,
+		addRepoNameOptionFlag(GCDiamondCmd),
+	)
+	addDiamondTTLFlag(GCDiamondCmd)
+	addSplitTTLFlag(GCDiamondCmd)
+	addDiamondDryRunFlag(GCDiamondCmd)
+	addCoreConcurrencyFactorFlag(GCDiamondCmd, 500)
+	addBatchSizeFlag(GCDiamondCmd)
+
+	DiamondCmd.AddCommand(GCDiamondCmd)
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"bytes"
-	"context"
 
 	"github.com/oneconcern/datamon/pkg/core"
 	status "github.com/oneconcern/datamon/pkg/core/status"
@@ -21,7 +20,7 @@ var GetDiamondCmd = &cobra.Command{
 Prints corresponding diamond metadata if the diamond exists,
 exits with ENOENT status otherwise.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx := cmd.Context()
 		datamonFlagsPtr := &datamonFlags
 		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
 		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
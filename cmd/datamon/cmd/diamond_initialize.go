@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"bytes"
-	"context"
 
 	"github.com/oneconcern/datamon/pkg/core"
 
@@ -20,7 +19,7 @@ datamon diamond initialize --repo my-repo
 304102BC687E087CC3A811F21D113CCF
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx := cmd.Context()
 		datamonFlagsPtr := &datamonFlags
 		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
 		remoteStores, err := optionInputs.datamonContext(ctx)
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	stdlog "log"
 	"os"
@@ -29,11 +30,16 @@ func wrapFatalln(msg string, err error) {
 	} else {
 		errlog.Printf("%v", fmt.Errorf(msg+": %w", err))
 	}
+	if err == nil {
+		err = errors.New(msg)
+	}
+	endCommandTrace(err)
 	osExit(1)
 }
 
 // wrapFatalWithCodef is equivalent to log.Fatalf but controls the exit code returned to the command
 func wrapFatalWithCodef(code int, format string, args ...interface{}) {
 	errlog.Printf(format, args...)
+	endCommandTrace(fmt.Errorf(format, args...))
 	osExit(code)
 }
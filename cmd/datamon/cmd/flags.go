@@ -6,17 +6,23 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/oneconcern/datamon/pkg/cafs"
 	context2 "github.com/oneconcern/datamon/pkg/context"
 	gcscontext "github.com/oneconcern/datamon/pkg/context/gcs"
 
 	"github.com/oneconcern/datamon/pkg/core"
 	"github.com/oneconcern/datamon/pkg/dlogger"
+	"github.com/oneconcern/datamon/pkg/metrics/exporters/otlp"
+	"github.com/oneconcern/datamon/pkg/tracing"
 
 	"github.com/docker/go-units"
 	"github.com/go-openapi/runtime/flagext"
 	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/sidecar"
 	"github.com/oneconcern/datamon/pkg/storage"
 	"github.com/oneconcern/datamon/pkg/storage/gcs"
 	"github.com/oneconcern/datamon/pkg/storage/localfs"
@@ -38,15 +44,26 @@ type flagsT struct {
 		ConcurrencyFactor int
 		NameFilter        string
 		ForceDest         bool
+		FromID            string
+		FromLabel         string
 	}
 	fs struct {
 		MountPath          string
 		Stream             bool
 		CacheSize          flagext.ByteSize
 		WithPrefetch       int
+		ReadAhead          int
+		MaxPrefetches      int
 		WithVerifyHash     bool
 		WithVerifyBlobHash bool
 		WithRetry          bool
+		Lazy               bool
+		IndexDir           string
+		DiskCache          string
+		DiskCacheSize      flagext.ByteSize
+		WithDiamonds       bool
+		Follow             bool
+		FollowInterval     time.Duration
 	}
 	web struct {
 		port      int
@@ -58,6 +75,8 @@ type flagsT struct {
 	}
 	context struct {
 		Descriptor model.Context
+		Replica    model.Replica
+		DryRun     bool
 	}
 	repo struct {
 		RepoName    string
@@ -70,6 +89,7 @@ type flagsT struct {
 		cpuProf  bool
 		upgrade  bool
 		metrics  metricsFlags
+		tracing  tracingFlags
 		skipAuth bool
 		forceYes bool
 	}
@@ -82,6 +102,7 @@ type flagsT struct {
 		BatchSize         int
 		Template          string
 		WithLabelVersions bool
+		Progress          string
 	}
 	split struct {
 		splitID string
@@ -94,6 +115,10 @@ type flagsT struct {
 		withCheckpoints bool
 		ignoreConflicts bool
 		noConflicts     bool
+		keep            string
+		diamondTTL      time.Duration
+		splitTTL        time.Duration
+		dryRun          bool
 	}
 	upgrade upgradeFlags
 	purge   struct {
@@ -104,6 +129,39 @@ type flagsT struct {
 		SingleContext  bool
 		ChunkIndex     int
 	}
+	fsck struct {
+		VerifyLeaves   bool
+		RepairFrom     string
+		Resume         bool
+		LocalStorePath string
+	}
+	sidecar struct {
+		Type               string
+		Params             string
+		CoordPoint         string
+		SleepInsteadOfExit bool
+		PollInterval       time.Duration
+		SleepTimeout       time.Duration
+		PGDataDir          string
+		PGVersionBinDir    string
+		Name               string
+		Names              []string
+		WaitFor            string
+		Timeout            time.Duration
+	}
+	acl struct {
+		email string
+		role  string
+	}
+	archive struct {
+		Out            string
+		ModifiedBefore string
+		ModifiedAfter  string
+		FollowSymlinks bool
+		Checkpoint     string
+		Unlink         bool
+		ReadDirWorkers int
+	}
 	squash struct {
 		RetainTags       bool
 		RetainSemverTags bool
@@ -121,6 +179,22 @@ func addBundleFlag(cmd *cobra.Command) string {
 	return bundleID
 }
 
+func addFromBundleFlag(cmd *cobra.Command) string {
+	const fromBundle = "from-bundle"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.bundle.FromID, fromBundle, "", "The hash id of an existing bundle to start from")
+	}
+	return fromBundle
+}
+
+func addFromLabelFlag(cmd *cobra.Command) string {
+	const fromLabel = "from-label"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.bundle.FromLabel, fromLabel, "", "The label of an existing bundle to start from")
+	}
+	return fromLabel
+}
+
 func addDataPathFlag(cmd *cobra.Command) string {
 	const destination = "destination"
 	if cmd != nil {
@@ -313,6 +387,55 @@ func addBlobBucket(cmd *cobra.Command) string {
 	return blob
 }
 
+func addAuthProviderFlag(cmd *cobra.Command) string {
+	const c = "auth-provider"
+	if cmd != nil {
+		cmd.Flags().StringVar((*string)(&datamonFlags.context.Descriptor.Auth.Provider), c, "",
+			`The provider used to identify contributors in this context: one of "google" (default), "oidc", "aws" or "static"`)
+	}
+	return c
+}
+
+func addAuthTokenFileFlag(cmd *cobra.Command) string {
+	const c = "auth-token-file"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.context.Descriptor.Auth.TokenFile, c, "", "The file holding an OIDC ID token (oidc auth provider)")
+	}
+	return c
+}
+
+func addAuthTokenEnvFlag(cmd *cobra.Command) string {
+	const c = "auth-token-env"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.context.Descriptor.Auth.TokenEnv, c, "", "The environment variable holding an OIDC ID token (oidc auth provider, defaults to DATAMON_OIDC_TOKEN)")
+	}
+	return c
+}
+
+func addAuthRegionFlag(cmd *cobra.Command) string {
+	const c = "auth-region"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.context.Descriptor.Auth.Region, c, "", "The AWS region used to reach STS (aws auth provider)")
+	}
+	return c
+}
+
+func addAuthDomainFlag(cmd *cobra.Command) string {
+	const c = "auth-email-domain"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.context.Descriptor.Auth.Domain, c, "", "The domain used to build the email of AWS principals (aws auth provider)")
+	}
+	return c
+}
+
+func addAuthKeyringFlag(cmd *cobra.Command) string {
+	const c = "auth-keyring"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.context.Descriptor.Auth.Keyring, c, "", "The keyring service holding the contributor identity (static auth provider)")
+	}
+	return c
+}
+
 func addMetadataBucket(cmd *cobra.Command) string {
 	const meta = "meta"
 	if cmd != nil {
@@ -345,6 +468,55 @@ func addReadLogBucket(cmd *cobra.Command) string {
 	return b
 }
 
+func addReplicaBackendFlag(cmd *cobra.Command) string {
+	const c = "replica-backend"
+	if cmd != nil {
+		cmd.Flags().StringVar((*string)(&datamonFlags.context.Replica.Backend), c, "",
+			`The cloud provider hosting the replica buckets: "gcs" (default) or "s3"`)
+	}
+	return c
+}
+
+func addReplicaRegionFlag(cmd *cobra.Command) string {
+	const c = "replica-region"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.context.Replica.Region, c, "", "The AWS region of the replica buckets (s3 replica backend)")
+	}
+	return c
+}
+
+func addReplicaBlobBucket(cmd *cobra.Command) string {
+	const b = "replica-blob"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.context.Replica.Blob, b, "", "The name of the bucket mirroring the datamon blobs")
+	}
+	return b
+}
+
+func addReplicaMetadataBucket(cmd *cobra.Command) string {
+	const b = "replica-meta"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.context.Replica.Metadata, b, "", "The name of the bucket mirroring the datamon metadata")
+	}
+	return b
+}
+
+func addReplicaVMetadataBucket(cmd *cobra.Command) string {
+	const b = "replica-vmeta"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.context.Replica.VMetadata, b, "", "The name of the bucket mirroring the datamon versioned metadata")
+	}
+	return b
+}
+
+func addContextSyncDryRunFlag(cmd *cobra.Command) string {
+	const c = "dry-run"
+	if cmd != nil {
+		cmd.Flags().BoolVar(&datamonFlags.context.DryRun, c, false, "Report about objects missing on the replica, but don't actually copy anything")
+	}
+	return c
+}
+
 func addCredentialFile(cmd *cobra.Command) string {
 	const credential = "credential"
 	if cmd != nil {
@@ -419,6 +591,79 @@ func addPrefetchFlag(cmd *cobra.Command) string {
 	return c
 }
 
+func addReadAheadFlag(cmd *cobra.Command) string {
+	const c = "readahead"
+	if cmd != nil {
+		cmd.Flags().IntVar(&datamonFlags.fs.ReadAhead, c, 0, "When greater than 0, enables adaptive prefetching of up to that many blobs ahead of sequential reads on a mounted file. Supersedes --prefetch (requires Stream enabled)")
+	}
+	return c
+}
+
+func addMaxPrefetchesFlag(cmd *cobra.Command) string {
+	const c = "max-prefetches"
+	if cmd != nil {
+		cmd.Flags().IntVar(&datamonFlags.fs.MaxPrefetches, c, cafs.DefaultMaxPrefetches, "The maximum number of blobs being prefetched at the same time on a mount (requires --readahead)")
+	}
+	return c
+}
+
+func addLazyFlag(cmd *cobra.Command) string {
+	const c = "lazy"
+	if cmd != nil {
+		cmd.Flags().BoolVar(&datamonFlags.fs.Lazy, c, false, "Populates the mount lazily, as directories are looked up or listed. Recommended for bundles with many files (requires Stream enabled)")
+	}
+	return c
+}
+
+func addWithDiamondsFlag(cmd *cobra.Command) string {
+	const c = "diamonds"
+	if cmd != nil {
+		cmd.Flags().BoolVar(&datamonFlags.fs.WithDiamonds, c, false, "Exposes previews of the diamonds which are not committed yet")
+	}
+	return c
+}
+
+func addFollowFlag(cmd *cobra.Command) string {
+	const c = "follow"
+	if cmd != nil {
+		cmd.Flags().BoolVar(&datamonFlags.fs.Follow, c, false, "Follows the label: the mount switches to the new bundle whenever the label moves (requires --label)")
+	}
+	return c
+}
+
+func addFollowIntervalFlag(cmd *cobra.Command) string {
+	const c = "follow-interval"
+	if cmd != nil {
+		cmd.Flags().DurationVar(&datamonFlags.fs.FollowInterval, c, time.Minute, "The delay between two checks of the label followed by the mount (requires --follow)")
+	}
+	return c
+}
+
+func addIndexDirFlag(cmd *cobra.Command) string {
+	const c = "index-dir"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.fs.IndexDir, c, "", "The local directory holding the file index of a lazily populated mount. Defaults to a temporary directory (requires --lazy for bundle mount)")
+	}
+	return c
+}
+
+func addDiskCacheFlag(cmd *cobra.Command) string {
+	const c = "disk-cache"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.fs.DiskCache, c, "", "A local directory used as a persistent cache for blobs, shared by all mounts and downloads on this host. Disabled by default")
+	}
+	return c
+}
+
+func addDiskCacheSizeFlag(cmd *cobra.Command) string {
+	const c = "disk-cache-size"
+	if cmd != nil {
+		datamonFlags.fs.DiskCacheSize = flagext.ByteSize(cafs.DefaultLeafCacheSize)
+		cmd.Flags().Var(&datamonFlags.fs.DiskCacheSize, c, "The maximum size of the persistent blob cache (in KB, MB, GB, ...) (requires --disk-cache)")
+	}
+	return c
+}
+
 func addVerifyHashFlag(cmd *cobra.Command) string {
 	const c = "verify-hash"
 	if cmd != nil {
@@ -469,6 +714,53 @@ func addMetricsPasswordFlag(cmd *cobra.Command) string {
 	return c
 }
 
+func addMetricsExporterFlag(cmd *cobra.Command) string {
+	const c = "metrics-exporter"
+	if cmd != nil {
+		cmd.PersistentFlags().StringVar(&datamonFlags.root.metrics.Exporter, c, "",
+			`The metrics exporter: "influxdb" (the default) pushes to --metrics-url, "prometheus" serves metrics on --metrics-port, `+
+				`"otlp" pushes to an OpenTelemetry collector at --metrics-url (defaults to `+otlp.DefaultEndpoint+`)`)
+	}
+	return c
+}
+
+func addMetricsPortFlag(cmd *cobra.Command) string {
+	const c = "metrics-port"
+	if cmd != nil {
+		cmd.PersistentFlags().IntVar(&datamonFlags.root.metrics.Port, c, 0,
+			fmt.Sprintf("The port serving metrics on /metrics to prometheus, with the prometheus exporter (defaults to %d)", defaultPrometheusPort))
+	}
+	return c
+}
+
+func addTraceFlag(cmd *cobra.Command) string {
+	const c = "trace"
+	if cmd != nil {
+		defaultTrace := false
+		datamonFlags.root.tracing.Enabled = &defaultTrace
+		cmd.PersistentFlags().BoolVar(datamonFlags.root.tracing.Enabled, c, defaultTrace, `Toggle tracing of the command with OpenTelemetry`)
+	}
+	return c
+}
+
+func addTraceURLFlag(cmd *cobra.Command) string {
+	const c = "trace-url"
+	if cmd != nil {
+		cmd.PersistentFlags().StringVar(&datamonFlags.root.tracing.URL, c, "",
+			`The endpoint of a Jaeger collector receiving traces (defaults to `+tracing.DefaultEndpoint+`)`)
+	}
+	return c
+}
+
+func addProgressFlag(cmd *cobra.Command) string {
+	const c = "progress"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.core.Progress, c, "",
+			`Progress reporting on stderr: "bar" shows a progress bar, "json" writes events as newline-delimited JSON, "none" disables reporting (default)`)
+	}
+	return c
+}
+
 func addDiamondFlag(cmd *cobra.Command) string {
 	const c = "diamond"
 	if cmd != nil {
@@ -477,6 +769,14 @@ func addDiamondFlag(cmd *cobra.Command) string {
 	return c
 }
 
+func addDiamondPreviewFlag(cmd *cobra.Command) string {
+	const c = "diamond"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.diamond.diamondID, c, "", `Use a preview of the files of an uncommitted diamond, merged from all its splits done so far, rather than a bundle`)
+	}
+	return c
+}
+
 func addSplitFlag(cmd *cobra.Command) string {
 	const c = "split"
 	if cmd != nil {
@@ -519,6 +819,38 @@ func addWithCheckpointFlag(cmd *cobra.Command) string {
 	return c
 }
 
+func addConflictKeepFlag(cmd *cobra.Command) string {
+	const c = "keep"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.diamond.keep, c, "", `The split ID which version prevails when resolving conflicts, or "latest" to keep the latest uploaded version`)
+	}
+	return c
+}
+
+func addDiamondTTLFlag(cmd *cobra.Command) string {
+	const c = "diamond-ttl"
+	if cmd != nil {
+		cmd.Flags().DurationVar(&datamonFlags.diamond.diamondTTL, c, model.DefaultDiamondTTL, "The duration after which an uncommitted diamond without any activity is considered abandoned")
+	}
+	return c
+}
+
+func addSplitTTLFlag(cmd *cobra.Command) string {
+	const c = "split-ttl"
+	if cmd != nil {
+		cmd.Flags().DurationVar(&datamonFlags.diamond.splitTTL, c, model.DefaultSplitTTL, "The duration after which a running split is considered orphaned")
+	}
+	return c
+}
+
+func addDiamondDryRunFlag(cmd *cobra.Command) string {
+	const c = "dry-run"
+	if cmd != nil {
+		cmd.Flags().BoolVar(&datamonFlags.diamond.dryRun, c, false, "Report about abandoned diamonds and splits, but don't actually change anything")
+	}
+	return c
+}
+
 func addSplitTagFlag(cmd *cobra.Command) string {
 	const c = "split-tag"
 	if cmd != nil {
@@ -636,6 +968,222 @@ func addPurgeChunkIndexFlag(cmd *cobra.Command) string {
 	return c
 }
 
+func addFsckVerifyLeavesFlag(cmd *cobra.Command) string {
+	const c = "verify-leaves"
+	if cmd != nil {
+		cmd.Flags().BoolVar(&datamonFlags.fsck.VerifyLeaves, c, false, "Retrieve every leaf blob and verify its hash, instead of merely checking its existence")
+	}
+	return c
+}
+
+func addFsckRepairFromFlag(cmd *cobra.Command) string {
+	const c = "repair-from"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.fsck.RepairFrom, c, "", "Repair missing or corrupted blobs by copying them from the blob store of this context")
+	}
+	return c
+}
+
+func addFsckResumeFlag(cmd *cobra.Command) string {
+	const c = "resume"
+	if cmd != nil {
+		cmd.Flags().BoolVar(&datamonFlags.fsck.Resume, c, false, "Resume an interrupted check: bundles already checked are skipped")
+	}
+	return c
+}
+
+func addFsckLocalPathFlag(cmd *cobra.Command) string {
+	const c = "local-work-dir"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.fsck.LocalStorePath, c, ".datamon-fsck", "Indicates the local folder that datamon will use to keep track of the progress of the check")
+	}
+	return c
+}
+
+func addSidecarTypeFlag(cmd *cobra.Command) string {
+	const c = "type"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.sidecar.Type, c, "fuse", `The type of sidecar to run: "fuse", "postgres" or "spec"`)
+	}
+	return c
+}
+
+func addSidecarParamsFlag(cmd *cobra.Command) string {
+	const c = "params"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.sidecar.Params, c, "",
+			"The path to a YAML or JSON file with the sidecar parameters, or the parameters document itself. "+
+				"Defaults to the $DATAMON_SIDECAR_PARAMS environment variable")
+	}
+	return c
+}
+
+func addSidecarCoordPointFlag(cmd *cobra.Command) string {
+	const c = "coord-point"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.sidecar.CoordPoint, c, "", "The coordination point shared by the sidecars and the application wrapper")
+	}
+	return c
+}
+
+func addSidecarNameFlag(cmd *cobra.Command) string {
+	const c = "name"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.sidecar.Name, c, "", "The name of the sidecar, which identifies its state on the coordination point. Defaults to the type of sidecar")
+	}
+	return c
+}
+
+func addSidecarNamesFlag(cmd *cobra.Command) string {
+	const c = "sidecar"
+	if cmd != nil {
+		cmd.Flags().StringSliceVar(&datamonFlags.sidecar.Names, c, nil, "The names of the sidecars to wait for. Defaults to all sidecars which have reported their state")
+	}
+	return c
+}
+
+func addSidecarWaitForFlag(cmd *cobra.Command) string {
+	const c = "for"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.sidecar.WaitFor, c, string(sidecar.StatusReady), `The status to wait for: "ready", "uploading" or "done"`)
+	}
+	return c
+}
+
+func addSidecarTimeoutFlag(cmd *cobra.Command) string {
+	const c = "timeout"
+	if cmd != nil {
+		cmd.Flags().DurationVar(&datamonFlags.sidecar.Timeout, c, 0, "Give up waiting after this duration. The default is to wait indefinitely")
+	}
+	return c
+}
+
+func addSidecarSleepInsteadOfExitFlag(cmd *cobra.Command) string {
+	const c = "sleep-instead-of-exit"
+	if cmd != nil {
+		cmd.Flags().BoolVar(&datamonFlags.sidecar.SleepInsteadOfExit, c, false, "Sleep when done instead of exiting, e.g. to keep the container around for debugging")
+	}
+	return c
+}
+
+func addSidecarPollIntervalFlag(cmd *cobra.Command) string {
+	const c = "poll-interval"
+	if cmd != nil {
+		cmd.Flags().DurationVar(&datamonFlags.sidecar.PollInterval, c, sidecar.DefaultPollInterval, "The interval between two checks for signaling files on the coordination point")
+	}
+	return c
+}
+
+func addSidecarSleepTimeoutFlag(cmd *cobra.Command) string {
+	const c = "sleep-timeout"
+	if cmd != nil {
+		cmd.Flags().DurationVar(&datamonFlags.sidecar.SleepTimeout, c, 0, "When sleeping instead of exiting, exit after this duration. The default is to sleep until interrupted")
+	}
+	return c
+}
+
+func addSidecarPGDataDirFlag(cmd *cobra.Command) string {
+	const c = "pg-data-dir"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.sidecar.PGDataDir, c, sidecar.DefaultPGDataDir, "The root directory for the files of database servers")
+	}
+	return c
+}
+
+func addSidecarPGVersionBinDirFlag(cmd *cobra.Command) string {
+	const c = "pg-version-bin-dir"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.sidecar.PGVersionBinDir, c, sidecar.DefaultPGVersionBinDir,
+			"The location of the command line tools of older postgres versions, used to migrate databases. "+
+				"The major version replaces %s")
+	}
+	return c
+}
+
+func addArchivePathFlag(cmd *cobra.Command) string {
+	const c = "path"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.bundle.DataPath, c, "", "The local directory to archive")
+	}
+	return c
+}
+
+func addArchiveOutFlag(cmd *cobra.Command) string {
+	const c = "out"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.archive.Out, c, "-", `The file to write the list of files to, or "-" for stdout`)
+	}
+	return c
+}
+
+func addArchiveModifiedBeforeFlag(cmd *cobra.Command) string {
+	const c = "modified-before"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.archive.ModifiedBefore, c, "",
+			`Only list files last modified before this local time, e.g. "2006-Jan-02", "060102150405", "2006-01-02" or RFC3339`)
+	}
+	return c
+}
+
+func addArchiveModifiedAfterFlag(cmd *cobra.Command) string {
+	const c = "modified-after"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.archive.ModifiedAfter, c, "",
+			`Only list files last modified after this local time, e.g. "2006-Jan-02", "060102150405", "2006-01-02" or RFC3339`)
+	}
+	return c
+}
+
+func addArchiveFollowSymlinksFlag(cmd *cobra.Command) string {
+	const c = "follow-symlinks"
+	if cmd != nil {
+		cmd.Flags().BoolVar(&datamonFlags.archive.FollowSymlinks, c, true, "Follow symbolic links while walking directories")
+	}
+	return c
+}
+
+func addReadDirWorkersFlag(cmd *cobra.Command, defaultWorkers int) string {
+	const c = "readdir-workers"
+	if cmd != nil {
+		cmd.Flags().IntVar(&datamonFlags.archive.ReadDirWorkers, c, defaultWorkers,
+			"The number of directories read concurrently while walking directories. Increase for large trees on network file systems")
+	}
+	return c
+}
+
+func addArchiveCheckpointFlag(cmd *cobra.Command) string {
+	const c = "checkpoint"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.archive.Checkpoint, c, "",
+			`The local file journaling uploaded files, to resume an interrupted upload. Defaults to the list of files, suffixed by ".checkpoint"`)
+	}
+	return c
+}
+
+func addArchiveUnlinkFlag(cmd *cobra.Command) string {
+	const c = "unlink"
+	if cmd != nil {
+		cmd.Flags().BoolVar(&datamonFlags.archive.Unlink, c, false, "Remove the archived files from the source, once the bundle is uploaded")
+	}
+	return c
+}
+
+func addACLEmailFlag(cmd *cobra.Command) string {
+	const c = "email"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.acl.email, c, "", `The email of the contributor, or "*" for any contributor`)
+	}
+	return c
+}
+
+func addACLRoleFlag(cmd *cobra.Command) string {
+	const c = "role"
+	if cmd != nil {
+		cmd.Flags().StringVar(&datamonFlags.acl.role, c, "", `The role granted to the contributor: one of "reader", "writer" or "admin"`)
+	}
+	return c
+}
+
 func addRetainTagsFlag(cmd *cobra.Command) string {
 	const c = "retain-tags"
 	if cmd != nil {
@@ -790,11 +1338,16 @@ func (in *cliOptionInputs) datamonContext(ctx context.Context, opts ...ContextOp
 		gcsOpts = append(gcsOpts, gcs.ReadOnly())
 	}
 	// here we select a 100% gcs backend strategy (more elaborate strategies could be defined by the context pkg)
-	return gcscontext.MakeContext(ctx,
+	stores, err := gcscontext.MakeContext(ctx,
 		in.params.context.Descriptor,
 		in.config.Credential,
 		gcsOpts...,
 	)
+	if err != nil || !in.params.root.tracing.IsEnabled() {
+		return stores, err
+	}
+
+	return traceStores(stores, logger), nil
 }
 
 func (in *cliOptionInputs) srcStore(ctx context.Context, create bool) (storage.Store, error) {
@@ -883,6 +1436,30 @@ func (in *cliOptionInputs) bundleOpts(ctx context.Context, opts ...ContextOption
 	return ops, nil
 }
 
+// leafCache opens the persistent blob cache, if any
+func (in *cliOptionInputs) leafCache() (*cafs.LeafCache, error) {
+	if in.params.fs.DiskCache == "" {
+		return nil, nil
+	}
+	logger, err := in.getLogger()
+	if err != nil {
+		return nil, err
+	}
+	return cafs.NewLeafCache(in.params.fs.DiskCache,
+		cafs.LeafCacheSize(int64(in.params.fs.DiskCacheSize)),
+		cafs.LeafCacheLogger(logger),
+	)
+}
+
+// progress builds a callback rendering progress events on stderr, if any
+func (in *cliOptionInputs) progress() (core.ProgressFunc, error) {
+	reporter, err := newProgressReporter(in.params.core.Progress, os.Stderr)
+	if err != nil || reporter == nil {
+		return nil, err
+	}
+	return reporter.report, nil
+}
+
 func (in *cliOptionInputs) getLogger() (*zap.Logger, error) {
 	var err error
 	in.config.onceLogger.Do(func() {
@@ -938,6 +1515,27 @@ func (in *cliOptionInputs) contributor() (model.Contributor, error) {
 			Name:  config.Name,
 		}, nil
 	}
+
+	cfg := in.authConfig()
+	if !cfg.Provider.IsValid() {
+		return model.Contributor{}, fmt.Errorf("unsupported auth provider: %q", cfg.Provider)
+	}
+
+	authenticator := authorizer
+	if authenticator == nil {
+		authenticator = newAuthorizer(cfg, in.config)
+	}
+
+	if cfg.Provider != model.AuthGoogle {
+		// credentials set by flag or config are for google: other providers use their own settings
+		contributor, err := authenticator.Principal("")
+		if err != nil {
+			return model.Contributor{},
+				fmt.Errorf("could not resolve contributor with auth provider %q: %w", cfg.Provider, err)
+		}
+		return contributor, nil
+	}
+
 	var credentials string
 	switch {
 	case flags.root.credFile != "":
@@ -946,7 +1544,7 @@ func (in *cliOptionInputs) contributor() (model.Contributor, error) {
 		credentials = config.Credential
 	}
 
-	contributor, err := authorizer.Principal(credentials)
+	contributor, err := authenticator.Principal(credentials)
 	if err != nil {
 		return model.Contributor{},
 			fmt.Errorf("could not resolve credentials: must be present as --credential flag, or in local config or as GOOGLE_APPLICATION_CREDENTIALS environment. err: %s", err)
@@ -954,6 +1552,20 @@ func (in *cliOptionInputs) contributor() (model.Contributor, error) {
 	return contributor, err
 }
 
+// optionalContributor resolves the identity of the contributor running a command which does not
+// require authentication, but is subject to access control lists.
+//
+// When no identity may be resolved, the command proceeds anonymously: it is then only permitted
+// if no access control list restricts it.
+func (in *cliOptionInputs) optionalContributor() model.Contributor {
+	contributor, err := in.contributor()
+	if err != nil {
+		infoLogger.Printf("warning: proceeding without identity: %v", err)
+		return model.Contributor{}
+	}
+	return contributor
+}
+
 func (in *cliOptionInputs) dumpContext() string {
 	return "using config:" + in.config.Config + " context:" + in.params.context.Descriptor.Name
 }
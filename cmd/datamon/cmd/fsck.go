@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+var fsckIssueTemplate func(flagsT) *template.Template
+
+func init() {
+	fsckIssueTemplate = func(opts flagsT) *template.Template {
+		if opts.core.Template != "" {
+			t, err := template.New("fsck issue").Parse(datamonFlags.core.Template)
+			if err != nil {
+				wrapFatalln("invalid template", err)
+			}
+			return t
+		}
+		const issueTemplateString = `{{.Kind}} , {{.Repo}} , {{.BundleID}} , {{.File}} , {{.Key}}{{if .Repaired}} , repaired{{end}}`
+		return template.Must(template.New("fsck issue").Parse(issueTemplateString))
+	}
+}
+
+// fsckCmd checks the integrity of the blobs used by a context
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Check the integrity of the blobs used by the bundles of a context",
+	Long: `Check that all the blobs referred to by the bundles of a context exist in its blob store.
+
+All bundles are scanned, and every file is exploded into the leaf blobs that make its content.
+By default, only the existence of every leaf is checked. With "--verify-leaves", leaves are retrieved
+and their hash is verified.
+
+Issues are reported one per line:
+  missing-leaf       a leaf blob is missing
+  corrupted-leaf     the content of a leaf blob doesn't match its key
+  dangling-root      the root key of a file is missing
+  corrupted-root     the root key of a file is invalid
+  unreadable-bundle  the file list of a bundle can't be retrieved
+
+With "--repair-from", missing or corrupted blobs are copied from the blob store of another context,
+provided the copy found there is valid.
+
+The progress of the check is kept in a local folder: an interrupted check may be resumed with "--resume".
+
+The command exits with a non-zero status whenever some issue could not be repaired.
+`,
+	Example: `# Check all repos in the current context
+% datamon fsck
+
+# Verify all leaves of a repo and repair it from a replica
+% datamon fsck --repo ritesh-test-repo --verify-leaves --repair-from replica`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+
+		defer func(t0 time.Time) {
+			cliUsage(t0, "fsck", err)
+		}(time.Now())
+
+		ctx := cmd.Context()
+		optionInputs := newCliOptionInputs(config, &datamonFlags)
+		remoteStores, err := optionInputs.datamonContext(ctx)
+		if err != nil {
+			wrapFatalln("create remote stores", err)
+			return
+		}
+		logger, err := optionInputs.getLogger()
+		if err != nil {
+			wrapFatalln("create logger", err)
+			return
+		}
+
+		opts := []core.FsckOption{
+			core.WithFsckLogger(logger),
+			core.WithFsckLocalStore(datamonFlags.fsck.LocalStorePath),
+			core.WithFsckParallel(datamonFlags.bundle.ConcurrencyFactor),
+			core.WithFsckResume(datamonFlags.fsck.Resume),
+			core.WithFsckVerifyHash(datamonFlags.fsck.VerifyLeaves),
+			core.WithFsckContributor(optionInputs.optionalContributor()),
+		}
+		if datamonFlags.repo.RepoName != "" {
+			opts = append(opts, core.WithFsckRepos(datamonFlags.repo.RepoName))
+		}
+
+		if datamonFlags.fsck.RepairFrom != "" {
+			repairStores, erc := storesForContext(ctx, mustGetConfigStore(), datamonFlags.fsck.RepairFrom)
+			if erc != nil {
+				err = erc
+				wrapFatalln("create remote stores to repair from", err)
+				return
+			}
+			opts = append(opts, core.WithFsckRepairFrom(repairStores))
+		}
+
+		logger.Info("checking blobs",
+			zap.String("context", datamonFlags.context.Descriptor.Name),
+			zap.String("context BLOB bucket", datamonFlags.context.Descriptor.Blob),
+			zap.String("repair from context", datamonFlags.fsck.RepairFrom),
+		)
+
+		report, err := core.Fsck(remoteStores, opts...)
+		if err != nil {
+			wrapFatalln("fsck", err)
+			return
+		}
+
+		var unrepaired int
+		for _, issue := range report.Issues {
+			var buf bytes.Buffer
+			if err = fsckIssueTemplate(datamonFlags).Execute(&buf, issue); err != nil {
+				wrapFatalln("executing template", err)
+				return
+			}
+			log.Println(buf.String())
+
+			if !issue.Repaired {
+				unrepaired++
+			}
+		}
+
+		// sending this out to stderr
+		infoLogger.Printf("checked %d repos, %d bundles (%d skipped), %d files, %d root keys, %d leaves: %d issues, %d blobs repaired",
+			report.Repos, report.Bundles, report.SkippedBundles, report.Files, report.RootKeys, report.Leaves,
+			len(report.Issues), report.Repaired,
+		)
+
+		if unrepaired > 0 {
+			err = fmt.Errorf("%d issues found", unrepaired)
+			wrapFatalln("fsck", err)
+		}
+	},
+}
+
+func init() {
+	addRepoNameOptionFlag(fsckCmd)
+	addConcurrencyFactorFlag(fsckCmd, 100)
+	addFsckVerifyLeavesFlag(fsckCmd)
+	addFsckRepairFromFlag(fsckCmd)
+	addFsckResumeFlag(fsckCmd)
+	addFsckLocalPathFlag(fsckCmd)
+	addTemplateFlag(fsckCmd)
+
+	rootCmd.AddCommand(fsckCmd)
+}
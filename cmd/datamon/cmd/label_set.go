@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"time"
 
@@ -26,7 +25,7 @@ Setting a label is analogous to the git command "git tag {label}".`,
 			cliUsage(t0, "label set", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		contributor, err := optionInputs.contributor()
@@ -1,9 +1,29 @@
 package cmd
 
 import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/oneconcern/datamon/pkg/metrics"
+	"github.com/oneconcern/datamon/pkg/metrics/exporters/influxdb"
+	"github.com/oneconcern/datamon/pkg/metrics/exporters/otlp"
+	"github.com/oneconcern/datamon/pkg/metrics/exporters/prometheus"
+	"go.opencensus.io/stats/view"
+)
+
+const (
+	// metrics exporters supported by the CLI
+	influxdbExporter   = "influxdb"
+	prometheusExporter = "prometheus"
+	otlpExporter       = "otlp"
+
+	defaultPrometheusPort = 9464
 )
 
 type metricsFlags struct {
@@ -11,6 +31,8 @@ type metricsFlags struct {
 	URL      string `json:"url,omitempty" yaml:"url,omitempty"`           // fully qualified metrics endpoint - may embed user/password
 	User     string `json:"user,omitempty" yaml:"user,omitempty"`         // overrides URL user, if any
 	Password string `json:"password,omitempty" yaml:"password,omitempty"` // overrides URL password, if any
+	Exporter string `json:"exporter,omitempty" yaml:"exporter,omitempty"` // one of influxdb (the default), prometheus or otlp
+	Port     int    `json:"port,omitempty" yaml:"port,omitempty"`         // port serving metrics to prometheus
 	m        *M
 }
 
@@ -35,3 +57,121 @@ func cliUsage(t0 time.Time, command string, err error) {
 		metrics.Flush()
 	}
 }
+
+// metricsExporter builds the exporter selected by the metrics configuration.
+//
+// All metrics are reported with the datamon version, the originating IP, the context and the user.
+func metricsExporter(user string) (view.Exporter, error) {
+	flags := datamonFlags.root.metrics
+
+	if flags.URL != "" {
+		if _, err := url.Parse(flags.URL); err != nil {
+			return nil, fmt.Errorf("the metrics URL should be a valid URL, e.g. https://[user:password@]host[:port]: %w", err)
+		}
+	}
+
+	tags := map[string]string{
+		"service": "datamon",
+		"version": NewVersionInfo().Version, // want to track datamon versions in all metrics
+		"ip":      getOutboundIP().String(), // want to track originating IP in all metrics
+		"context": datamonFlags.context.Descriptor.Name,
+		"user":    user,
+	}
+
+	var errorReported int64
+	errorHandler := func(e error) {
+		// only report first error on metrics
+		firstTime := atomic.CompareAndSwapInt64(&errorReported, 0, 1)
+		if firstTime {
+			errlog.Printf("warning: metrics export failed: %v", e)
+		}
+	}
+
+	switch flags.Exporter {
+	case influxdbExporter, "":
+		opts := []influxdb.StoreOption{
+			influxdb.WithDatabase("datamon"),
+			influxdb.WithURL(flags.URL),
+			influxdb.WithNameAsTag("metrics"), // use metric name as an influxdb tag, with unique time series "metrics"
+			influxdb.WithTimeout(30 * time.Second),
+			influxdb.WithTLSConfig(&tls.Config{
+				MinVersion: tls.VersionTLS13,
+				NextProtos: []string{"h2", "http/1.1"},
+			}),
+			influxdb.WithInsecureSkipVerify(true),
+		}
+
+		// override credentials for metrics backend
+		if flags.User != "" {
+			opts = append(opts, influxdb.WithUser(flags.User))
+		}
+		if flags.Password != "" {
+			opts = append(opts, influxdb.WithPassword(flags.Password))
+		}
+
+		sink, err := influxdb.NewStore(opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		return metrics.DefaultExporter(
+			influxdb.WithStore(sink),
+			influxdb.WithErrorHandler(errorHandler),
+			influxdb.WithTags(tags),
+		), nil
+
+	case prometheusExporter:
+		exporter := prometheus.NewExporter(
+			prometheus.WithErrorHandler(errorHandler),
+			prometheus.WithLabels(tags),
+		)
+		port := flags.Port
+		if port == 0 {
+			port = defaultPrometheusPort
+		}
+		serveMetrics(net.JoinHostPort("", strconv.Itoa(port)), exporter.Handler())
+
+		return exporter, nil
+
+	case otlpExporter:
+		attributes := make(map[string]string, len(tags))
+		for k, v := range tags {
+			attributes[k] = v
+		}
+		attributes["service.name"] = tags["service"]
+		attributes["service.version"] = tags["version"]
+
+		opts := []otlp.Option{
+			otlp.WithEndpoint(flags.URL),
+			otlp.WithTimeout(30 * time.Second),
+			otlp.WithErrorHandler(errorHandler),
+			otlp.WithAttributes(attributes),
+		}
+		if flags.User != "" {
+			opts = append(opts, otlp.WithBasicAuth(flags.User, flags.Password))
+		}
+
+		return otlp.NewExporter(opts...)
+
+	default:
+		return nil, fmt.Errorf("unsupported metrics exporter %q: expected one of %q, %q or %q",
+			flags.Exporter, influxdbExporter, prometheusExporter, otlpExporter)
+	}
+}
+
+// serveMetrics serves metrics to prometheus in the background, for as long as the command runs
+func serveMetrics(addr string, handler http.Handler) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler)
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errlog.Printf("warning: cannot serve metrics on %s: %v", addr, err)
+		}
+	}()
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/oneconcern/datamon/pkg/metrics/exporters/otlp"
+	"github.com/oneconcern/datamon/pkg/metrics/exporters/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsExporter(t *testing.T) {
+	saved := datamonFlags.root.metrics
+	defer func() {
+		datamonFlags.root.metrics = saved
+	}()
+
+	datamonFlags.root.metrics = metricsFlags{Exporter: otlpExporter, URL: "http://collector:4318"}
+	exporter, err := metricsExporter("test@example.com")
+	require.NoError(t, err)
+	assert.IsType(t, &otlp.Exporter{}, exporter)
+
+	datamonFlags.root.metrics = metricsFlags{Exporter: prometheusExporter, Port: 19464}
+	exporter, err = metricsExporter("test@example.com")
+	require.NoError(t, err)
+	assert.IsType(t, &prometheus.Exporter{}, exporter)
+
+	datamonFlags.root.metrics = metricsFlags{Exporter: "statsd"}
+	_, err = metricsExporter("test@example.com")
+	require.Error(t, err)
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/oneconcern/datamon/pkg/core"
+)
+
+const (
+	progressBar  = "bar"
+	progressJSON = "json"
+	progressNone = "none"
+
+	progressBarWidth     = 30
+	progressBarInterval  = 200 * time.Millisecond
+	progressJSONInterval = time.Second
+)
+
+// progressReporter renders the progress events of long-running operations.
+//
+// Events are throttled: phase changes, completions and errors are always reported.
+type progressReporter struct {
+	mx         sync.Mutex
+	out        io.Writer
+	mode       string
+	interval   time.Duration
+	reported   time.Time // when the last event was rendered
+	phaseStart time.Time
+	last       core.ProgressEvent
+	lineWidth  int // the width of the last line rendered by the progress bar
+}
+
+// newProgressReporter builds a reporter for a --progress mode. It yields nil when progress is not reported.
+//
+// Progress is not reported without an explicit mode: a progress bar would mix with the logs written on stderr.
+func newProgressReporter(mode string, out io.Writer) (*progressReporter, error) {
+	r := &progressReporter{
+		out:  out,
+		mode: mode,
+	}
+	switch mode {
+	case "", progressNone:
+		return nil, nil
+	case progressBar:
+		r.interval = progressBarInterval
+	case progressJSON:
+		r.interval = progressJSONInterval
+	default:
+		return nil, fmt.Errorf(`invalid progress reporting %q: expected "bar", "json" or "none"`, mode)
+	}
+	return r, nil
+}
+
+func (r *progressReporter) report(event core.ProgressEvent) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+
+	final := event.Phase == core.PhaseDone || event.Phase == core.PhaseFailed
+	newPhase := event.Phase != r.last.Phase || event.Operation != r.last.Operation
+	completed := event.TotalFiles > 0 && event.Files == event.TotalFiles
+	if newPhase {
+		r.phaseStart = event.Time
+	}
+	r.last = event
+	if !final && !newPhase && !completed && event.Time.Sub(r.reported) < r.interval {
+		return
+	}
+	r.reported = event.Time
+
+	if r.mode == progressJSON {
+		_ = json.NewEncoder(r.out).Encode(event)
+		return
+	}
+	r.renderBar(event, final)
+}
+
+// renderBar overwrites the current line of the terminal with the progress of the current phase
+func (r *progressReporter) renderBar(event core.ProgressEvent, final bool) {
+	var line strings.Builder
+	fmt.Fprintf(&line, "%s %-8s", event.Operation, event.Phase)
+
+	if fraction, ok := progressFraction(event); ok {
+		done := int(fraction * progressBarWidth)
+		fmt.Fprintf(&line, " [%s%s] %3.0f%%",
+			strings.Repeat("=", done), strings.Repeat(" ", progressBarWidth-done), fraction*100)
+	}
+
+	files := fmt.Sprintf("%d", event.Files)
+	if event.TotalFiles > 0 {
+		files += fmt.Sprintf("/%d", event.TotalFiles)
+	}
+	fmt.Fprintf(&line, " %s files", files)
+	if event.Bytes > 0 || event.TotalBytes > 0 {
+		size := units.HumanSize(float64(event.Bytes))
+		if event.TotalBytes > 0 {
+			size += "/" + units.HumanSize(float64(event.TotalBytes))
+		}
+		fmt.Fprintf(&line, " %s", size)
+	}
+
+	if eta, ok := progressETA(event, r.phaseStart); ok && !final {
+		fmt.Fprintf(&line, " ETA %v", eta)
+	}
+	if event.Error != "" {
+		fmt.Fprintf(&line, ": %s", event.Error)
+	}
+
+	rendered := line.String()
+	padding := r.lineWidth - len(rendered)
+	if padding < 0 {
+		padding = 0
+	}
+	r.lineWidth = len(rendered)
+	fmt.Fprintf(r.out, "\r%s%s", rendered, strings.Repeat(" ", padding))
+	if final {
+		fmt.Fprintln(r.out)
+		r.lineWidth = 0
+	}
+}
+
+// progressFraction tells which part of the current phase is done, measured in bytes whenever the total size is known
+func progressFraction(event core.ProgressEvent) (float64, bool) {
+	var fraction float64
+	switch {
+	case event.TotalBytes > 0:
+		fraction = float64(event.Bytes) / float64(event.TotalBytes)
+	case event.TotalFiles > 0:
+		fraction = float64(event.Files) / float64(event.TotalFiles)
+	default:
+		return 0, false
+	}
+	if fraction > 1 {
+		fraction = 1
+	}
+	return fraction, true
+}
+
+// progressETA extrapolates the remaining time of the current phase from its progress so far
+func progressETA(event core.ProgressEvent, phaseStart time.Time) (time.Duration, bool) {
+	fraction, ok := progressFraction(event)
+	if !ok || fraction == 0 || fraction == 1 {
+		return 0, false
+	}
+	elapsed := event.Time.Sub(phaseStart)
+	return time.Duration(float64(elapsed) * (1 - fraction) / fraction).Round(time.Second), true
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressReporter(t *testing.T) {
+	var buf bytes.Buffer
+
+	reporter, err := newProgressReporter("", &buf)
+	require.NoError(t, err)
+	assert.Nil(t, reporter, "progress is not reported by default")
+
+	_, err = newProgressReporter("xml", &buf)
+	require.Error(t, err)
+
+	t0 := time.Now()
+	events := []core.ProgressEvent{
+		{Operation: core.ProgressUpload, Phase: core.PhaseList, Time: t0},
+		{Operation: core.ProgressUpload, Phase: core.PhaseUpload, TotalFiles: 3, TotalBytes: 300, Time: t0},
+		{Operation: core.ProgressUpload, Phase: core.PhaseUpload, Files: 1, TotalFiles: 3, Bytes: 100, TotalBytes: 300, Time: t0.Add(time.Second)},
+		{Operation: core.ProgressUpload, Phase: core.PhaseUpload, Files: 2, TotalFiles: 3, Bytes: 200, TotalBytes: 300, Time: t0.Add(time.Second + time.Millisecond)},
+		{Operation: core.ProgressUpload, Phase: core.PhaseUpload, Files: 3, TotalFiles: 3, Bytes: 300, TotalBytes: 300, Time: t0.Add(time.Second + 2*time.Millisecond)},
+		{Operation: core.ProgressUpload, Phase: core.PhaseFailed, Error: "boom", Time: t0.Add(2 * time.Second)},
+	}
+
+	t.Run("json", func(t *testing.T) {
+		buf.Reset()
+		reporter, err := newProgressReporter(progressJSON, &buf)
+		require.NoError(t, err)
+		for _, event := range events {
+			reporter.report(event)
+		}
+
+		var reported []core.ProgressEvent
+		scanner := bufio.NewScanner(&buf)
+		for scanner.Scan() {
+			var event core.ProgressEvent
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &event), "each line is a JSON event")
+			reported = append(reported, event)
+		}
+		require.Len(t, reported, 5, "events are throttled, but not phase changes, completions or errors")
+		assert.EqualValues(t, 1, reported[2].Files)
+		assert.EqualValues(t, 3, reported[3].Files)
+		assert.Equal(t, core.PhaseFailed, reported[4].Phase)
+		assert.Equal(t, "boom", reported[4].Error)
+	})
+
+	t.Run("bar", func(t *testing.T) {
+		buf.Reset()
+		reporter, err := newProgressReporter(progressBar, &buf)
+		require.NoError(t, err)
+		for _, event := range events[:3] {
+			reporter.report(event)
+		}
+
+		lines := strings.Split(buf.String(), "\r")
+		current := lines[len(lines)-1]
+		assert.Contains(t, current, "upload upload")
+		assert.Contains(t, current, " 33%")
+		assert.Contains(t, current, "1/3 files")
+		assert.Contains(t, current, "ETA 2s")
+		assert.NotContains(t, buf.String(), "\n", "the bar is rendered on a single line")
+
+		reporter.report(core.ProgressEvent{Operation: core.ProgressUpload, Phase: core.PhaseDone, Time: t0.Add(2 * time.Second)})
+		assert.True(t, strings.HasSuffix(buf.String(), "\n"), "the bar ends with the operation")
+	})
+}
@@ -79,13 +79,7 @@ func metaForSharedContexts(currentContext string, blob storage.Store) ([]context
 			continue
 		}
 
-		datamonContext, err := context2.GetContext(ctx, configStore, contextName)
-		if err != nil {
-			return nil, err
-		}
-
-		optionInputs := newCliOptionInputs(config, &datamonFlags)
-		contextStore, err := gcscontext.MakeContext(ctx, *datamonContext, optionInputs.config.Credential)
+		contextStore, err := storesForContext(ctx, configStore, contextName)
 		if err != nil {
 			return nil, err
 		}
@@ -98,3 +92,15 @@ func metaForSharedContexts(currentContext string, blob storage.Store) ([]context
 
 	return result, nil
 }
+
+// storesForContext builds the stores of some other context than the current one
+func storesForContext(ctx context.Context, configStore storage.Store, contextName string) (context2.Stores, error) {
+	datamonContext, err := context2.GetContext(ctx, configStore, contextName)
+	if err != nil {
+		return nil, err
+	}
+
+	optionInputs := newCliOptionInputs(config, &datamonFlags)
+
+	return gcscontext.MakeContext(ctx, *datamonContext, optionInputs.config.Credential)
+}
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"time"
 
 	"github.com/oneconcern/datamon/pkg/core"
@@ -34,7 +33,7 @@ You MUST make sure that no concurrent build-reverse-lookup or delete job is stil
 			cliUsage(t0, "purge build-reverse-lookup", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		remoteStores, err := optionInputs.datamonContext(ctx)
 		if err != nil {
@@ -56,6 +55,7 @@ You MUST make sure that no concurrent build-reverse-lookup or delete job is stil
 		opts := []core.PurgeOption{
 			core.WithPurgeForce(datamonFlags.purge.Force),
 			core.WithPurgeLogger(logger),
+			core.WithPurgeContributor(optionInputs.optionalContributor()),
 		}
 
 		err = core.PurgeLock(remoteStores, opts...)
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"time"
 
 	"github.com/oneconcern/datamon/pkg/core"
@@ -37,7 +36,7 @@ You MUST make sure that no delete job is still running before doing that.
 			cliUsage(t0, "purge delete-unused", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		remoteStores, err := optionInputs.datamonContext(ctx)
 		if err != nil {
@@ -62,6 +61,7 @@ You MUST make sure that no delete job is still running before doing that.
 			core.WithPurgeLocalStore(datamonFlags.purge.LocalStorePath),
 			core.WithPurgeDryRun(datamonFlags.purge.DryRun),
 			core.WithPurgeParallel(datamonFlags.bundle.ConcurrencyFactor),
+			core.WithPurgeContributor(optionInputs.optionalContributor()),
 		}
 
 		err = core.PurgeLock(remoteStores, opts...)
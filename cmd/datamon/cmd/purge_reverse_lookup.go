@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"time"
 
 	"github.com/oneconcern/datamon/pkg/core"
@@ -20,6 +19,9 @@ Only ONE instance of this command may run: concurrent index building is not supp
 If a build-reverse-lookup OR delete-unused command was running and failed, an update of the index may be forced using the "--force" flag.
 
 You MUST make sure that no concurrent build-reverse-lookup or delete job is still running before doing that.
+
+BLOB resources used by diamonds which are not committed yet are retained, unless these diamonds or their splits
+are considered abandoned (see "--diamond-ttl" and "--split-ttl").
 `,
 	PreRun: func(cmd *cobra.Command, args []string) {
 		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
@@ -33,7 +35,7 @@ You MUST make sure that no concurrent build-reverse-lookup or delete job is stil
 			cliUsage(t0, "purge build-reverse-lookup", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		remoteStores, err := optionInputs.datamonContext(ctx)
 		if err != nil {
@@ -49,6 +51,11 @@ You MUST make sure that no concurrent build-reverse-lookup or delete job is stil
 		if datamonFlags.purge.Resume {
 			datamonFlags.purge.Force = true
 		}
+		progress, err := optionInputs.progress()
+		if err != nil {
+			wrapFatalln("set up progress reporting", err)
+			return
+		}
 
 		logger.Info("building reverse-lookup index",
 			zap.String("context", datamonFlags.context.Descriptor.Name),
@@ -65,6 +72,10 @@ You MUST make sure that no concurrent build-reverse-lookup or delete job is stil
 			core.WithPurgeParallel(datamonFlags.bundle.ConcurrencyFactor),
 			core.WithPurgeResumeIndex(datamonFlags.purge.Resume),
 			core.WithPurgeIndexChunkStart(datamonFlags.purge.ChunkIndex),
+			core.WithPurgeDiamondTTL(datamonFlags.diamond.diamondTTL),
+			core.WithPurgeSplitTTL(datamonFlags.diamond.splitTTL),
+			core.WithPurgeContributor(optionInputs.optionalContributor()),
+			core.WithPurgeProgress(progress),
 		}
 
 		if !datamonFlags.purge.SingleContext {
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/spf13/cobra"
+)
+
+var aclTemplate func(flagsT) *template.Template
+
+// repoACLCmd is the root command for all access control lists related subcommands
+var repoACLCmd = &cobra.Command{
+	Use:   "acl",
+	Short: "Commands to manage access control lists on repos",
+	Long: `Access control lists grant roles on a repo to contributors, identified by their email.
+
+Roles are:
+  - reader: may read the repo
+  - writer: may also upload bundles, set and delete labels
+  - admin: may also delete bundles, delete, rename or squash the repo, and update its access control lists
+
+When no repo is specified, the context-wide access control list is managed.
+Roles granted on the context apply to all repos. Creating repos requires the writer role on the context.
+Purging requires the admin role on the context.
+
+The special email "*" stands for any contributor.
+
+Access control lists are optional: when none is set, all operations are permitted.
+They are advisory and prevent unintended operations: they are not enforced by the storage backends.
+`,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+func init() {
+	repoCmd.AddCommand(repoACLCmd)
+}
+
+type aclEntry struct {
+	Email string
+	Role  model.ACLRole
+}
+
+func aclEntries(acl model.ACL) []aclEntry {
+	entries := make([]aclEntry, 0, len(acl.Admins)+len(acl.Writers)+len(acl.Readers))
+	for _, role := range []struct {
+		role   model.ACLRole
+		emails []string
+	}{
+		{role: model.ACLAdmin, emails: acl.Admins},
+		{role: model.ACLWriter, emails: acl.Writers},
+		{role: model.ACLReader, emails: acl.Readers},
+	} {
+		for _, email := range role.emails {
+			entries = append(entries, aclEntry{Email: email, Role: role.role})
+		}
+	}
+	return entries
+}
+
+func applyACLTemplate(acl model.ACL) error {
+	for _, entry := range aclEntries(acl) {
+		var buf bytes.Buffer
+		if err := aclTemplate(datamonFlags).Execute(&buf, entry); err != nil {
+			return fmt.Errorf("executing template: %w", err)
+		}
+		log.Println(buf.String())
+	}
+	return nil
+}
+
+func init() {
+	aclTemplate = func(opts flagsT) *template.Template {
+		if opts.core.Template != "" {
+			t, err := template.New("acl").Parse(datamonFlags.core.Template)
+			if err != nil {
+				wrapFatalln("invalid template", err)
+			}
+			return t
+		}
+		const aclTemplateString = `{{.Role}} , {{.Email}}`
+		return template.Must(template.New("acl").Parse(aclTemplateString))
+	}
+}
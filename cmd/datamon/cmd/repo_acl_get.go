@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"github.com/oneconcern/datamon/pkg/core"
+
+	"github.com/spf13/cobra"
+)
+
+// repoACLGet displays the access control lists on a repo
+var repoACLGet = &cobra.Command{
+	Use:   "get",
+	Short: "Get the access control lists on a repo",
+	Long: `Get the access control lists on a repo, or the context-wide access control list when no repo is specified.
+
+Nothing is displayed when no access control list is set.
+`,
+	Example: `% datamon repo acl get --repo ritesh-datamon-test-repo
+admin , ritesh@example.com
+writer , *`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		optionInputs := newCliOptionInputs(config, &datamonFlags)
+		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
+		if err != nil {
+			wrapFatalln("create remote stores", err)
+			return
+		}
+
+		acl, err := core.GetACL(datamonFlags.repo.RepoName, remoteStores)
+		if err != nil {
+			wrapFatalln("get ACL", err)
+			return
+		}
+
+		if err = applyACLTemplate(acl); err != nil {
+			wrapFatalln("display ACL", err)
+		}
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+func init() {
+	addRepoNameOptionFlag(repoACLGet)
+	addTemplateFlag(repoACLGet)
+	addSkipAuthFlag(repoACLGet)
+
+	repoACLCmd.AddCommand(repoACLGet)
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/oneconcern/datamon/pkg/model"
+
+	"github.com/spf13/cobra"
+)
+
+// repoACLGrant grants a role on a repo
+var repoACLGrant = &cobra.Command{
+	Use:   "grant",
+	Short: "Grant a role on a repo",
+	Long: `Grant a role on a repo to a contributor, or on the context when no repo is specified.
+
+Any role previously granted to this contributor is replaced.
+
+When some access control list is already in place, you must be an admin of the repo to perform this operation.
+An access control list must always retain at least one admin.
+`,
+	Example: `% datamon repo acl grant --repo ritesh-datamon-test-repo --email ritesh@example.com --role admin
+% datamon repo acl grant --repo ritesh-datamon-test-repo --email '*' --role writer`,
+	Run: func(cmd *cobra.Command, args []string) {
+		updateACL(func(acl *model.ACL) error {
+			return acl.Grant(datamonFlags.acl.email, model.ACLRole(datamonFlags.acl.role))
+		})
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+func init() {
+	requireFlags(repoACLGrant,
+		addACLEmailFlag(repoACLGrant),
+		addACLRoleFlag(repoACLGrant),
+	)
+	addRepoNameOptionFlag(repoACLGrant)
+
+	repoACLCmd.AddCommand(repoACLGrant)
+}
+
+// updateACL retrieves, updates then persists the access control lists on the repo or context
+func updateACL(update func(*model.ACL) error) {
+	ctx := context.Background()
+	optionInputs := newCliOptionInputs(config, &datamonFlags)
+	remoteStores, err := optionInputs.datamonContext(ctx)
+	if err != nil {
+		wrapFatalln("create remote stores", err)
+		return
+	}
+
+	contributor, err := optionInputs.contributor()
+	if err != nil {
+		wrapFatalln("populate contributor struct", err)
+		return
+	}
+
+	acl, err := core.GetACL(datamonFlags.repo.RepoName, remoteStores)
+	if err != nil {
+		wrapFatalln("get ACL", err)
+		return
+	}
+
+	if err = update(&acl); err != nil {
+		wrapFatalln("update ACL", err)
+		return
+	}
+
+	if err = core.SetACL(acl, remoteStores, core.WithContributor(contributor)); err != nil {
+		wrapFatalln("set ACL", err)
+		return
+	}
+
+	if err = applyACLTemplate(acl); err != nil {
+		wrapFatalln("display ACL", err)
+	}
+}
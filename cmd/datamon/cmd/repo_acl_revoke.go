@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/oneconcern/datamon/pkg/model"
+
+	"github.com/spf13/cobra"
+)
+
+// repoACLRevoke revokes any role on a repo
+var repoACLRevoke = &cobra.Command{
+	Use:   "revoke",
+	Short: "Revoke a role on a repo",
+	Long: `Revoke any role granted on a repo to a contributor, or on the context when no repo is specified.
+
+You must be an admin of the repo to perform this operation.
+An access control list must always retain at least one admin: revoke all other roles before the last admin.
+`,
+	Example: `% datamon repo acl revoke --repo ritesh-datamon-test-repo --email ritesh@example.com`,
+	Run: func(cmd *cobra.Command, args []string) {
+		updateACL(func(acl *model.ACL) error {
+			acl.Revoke(datamonFlags.acl.email)
+			return nil
+		})
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+func init() {
+	requireFlags(repoACLRevoke,
+		addACLEmailFlag(repoACLRevoke),
+	)
+	addRepoNameOptionFlag(repoACLRevoke)
+
+	repoACLCmd.AddCommand(repoACLRevoke)
+}
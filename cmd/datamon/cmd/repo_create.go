@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"time"
 
 	"github.com/oneconcern/datamon/pkg/core"
@@ -28,7 +27,7 @@ This is analogous to the "git init ..." command.`,
 			cliUsage(t0, "repo create", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		contributor, err := optionInputs.contributor()
 		if err != nil {
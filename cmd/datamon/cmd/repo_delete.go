@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -30,7 +29,7 @@ This command MUST NOT BE RUN concurrently.
 			cliUsage(t0, "repo delete", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		remoteStores, err := optionInputs.datamonContext(ctx)
 		if err != nil {
@@ -38,6 +37,15 @@ This command MUST NOT BE RUN concurrently.
 			return
 		}
 		logger, err := optionInputs.getLogger()
+		if err != nil {
+			wrapFatalln("get logger", err)
+			return
+		}
+		contributor, err := optionInputs.contributor()
+		if err != nil {
+			wrapFatalln("populate contributor struct", err)
+			return
+		}
 
 		if !datamonFlags.root.forceYes && !userConfirm("delete") {
 			wrapFatalln("user aborted", nil)
@@ -45,7 +53,7 @@ This command MUST NOT BE RUN concurrently.
 		}
 
 		logger.Info("deleting repo", zap.String("repo", datamonFlags.repo.RepoName))
-		err = core.DeleteRepo(datamonFlags.repo.RepoName, remoteStores)
+		err = core.DeleteRepo(datamonFlags.repo.RepoName, remoteStores, core.WithDeleteContributor(contributor))
 		if err != nil {
 			wrapFatalln("delete repo", err)
 			return
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"bufio"
-	"context"
 	"fmt"
 	"os"
 	"time"
@@ -35,7 +34,7 @@ This command MUST NOT BE RUN concurrently.
 			cliUsage(t0, "repo delete files", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		logger, err := optionInputs.getLogger()
 
@@ -31,7 +31,7 @@ exits with ENOENT status otherwise.`,
 			cliUsage(t0, "repo get", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		datamonFlagsPtr := &datamonFlags
 		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
 		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
@@ -64,6 +64,7 @@ exits with ENOENT status otherwise.`,
 			var grandTotal uint64
 			err = core.ListBundlesApply(datamonFlags.repo.RepoName, remoteStores,
 				retrieveFileSizes(
+					ctx,
 					datamonFlags.repo.RepoName,
 					remoteStores,
 					&datamonFlags,
@@ -103,9 +104,8 @@ func init() {
 	repoCmd.AddCommand(GetRepoCommand)
 }
 
-func retrieveFileSizes(repo string, stores context2.Stores, datamonFlags *flagsT, optionInputs *cliOptionInputs, grandTotal *uint64) func(model.BundleDescriptor) error {
+func retrieveFileSizes(ctx context.Context, repo string, stores context2.Stores, datamonFlags *flagsT, optionInputs *cliOptionInputs, grandTotal *uint64) func(model.BundleDescriptor) error {
 	return func(b model.BundleDescriptor) error {
-		ctx := context.Background()
 		bundleOpts, err := optionInputs.bundleOpts(ctx, ReadOnlyContext())
 		if err != nil {
 			wrapFatalln("failed to initialize bundle options", err)
@@ -123,7 +123,7 @@ func retrieveFileSizes(repo string, stores context2.Stores, datamonFlags *flagsT
 			bundleOpts...,
 		)
 
-		err = core.PopulateFiles(context.Background(), bundle)
+		err = core.PopulateFiles(ctx, bundle)
 		if err != nil {
 			return err
 		}
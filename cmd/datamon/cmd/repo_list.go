@@ -26,7 +26,7 @@ fred , test fred , Frédéric Bidon , frederic@oneconcern.com , 2019-12-05 14:01
 			cliUsage(t0, "repo list", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		datamonFlagsPtr := &datamonFlags
 		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
 		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
@@ -34,7 +34,7 @@ fred , test fred , Frédéric Bidon , frederic@oneconcern.com , 2019-12-05 14:01
 			wrapFatalln("create remote stores", err)
 			return
 		}
-		err = core.ListReposApply(remoteStores, applyRepoTemplate(remoteStores, optionInputs, datamonFlagsPtr.repo.withSize),
+		err = core.ListReposApply(remoteStores, applyRepoTemplate(ctx, remoteStores, optionInputs, datamonFlagsPtr.repo.withSize),
 			core.ConcurrentList(datamonFlags.core.ConcurrencyFactor),
 			core.BatchSize(datamonFlags.core.BatchSize),
 			core.WithMetrics(datamonFlags.root.metrics.IsEnabled()),
@@ -59,7 +59,7 @@ func init() {
 	repoCmd.AddCommand(repoList)
 }
 
-func applyRepoTemplate(stores context2.Stores, optionInputs *cliOptionInputs, withSize bool) func(model.RepoDescriptor) error {
+func applyRepoTemplate(ctx context.Context, stores context2.Stores, optionInputs *cliOptionInputs, withSize bool) func(model.RepoDescriptor) error {
 	return func(repo model.RepoDescriptor) error {
 		var buf bytes.Buffer
 		if err := repoDescriptorTemplate(datamonFlags).Execute(&buf, repo); err != nil {
@@ -74,6 +74,7 @@ func applyRepoTemplate(stores context2.Stores, optionInputs *cliOptionInputs, wi
 		var grandTotal uint64
 		err := core.ListBundlesApply(repo.Name, stores,
 			retrieveFileSizes(
+				ctx,
 				repo.Name,
 				stores,
 				&datamonFlags,
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	daemonizer "github.com/jacobsa/daemonize"
+
+	"github.com/oneconcern/datamon/pkg/fuse"
+	"github.com/oneconcern/datamon/pkg/metrics"
+
+	"github.com/spf13/cobra"
+)
+
+// mountRepoCmd mounts a read-only view of all the bundles in a repo
+var mountRepoCmd = &cobra.Command{
+	Use:   "mount",
+	Short: "Mount a repo as a browsable tree of labels and bundles",
+	Long: `Mount a readonly, non-interactive view of all the labels and bundles of a repo.
+
+The mount exposes:
+  /{repo}/labels/{label}/...
+  /{repo}/bundles/{bundle ID}/...
+
+With --diamonds, previews of the diamonds which are not committed yet are exposed as well:
+  /{repo}/diamonds/{diamond ID}/...
+
+When no repo is specified, all the repos in the context are exposed.
+
+Bundles are only retrieved when first visited, and their files are streamed.
+All bundles share the same blob cache, so comparing versions (e.g. diff -r) is cheap.`,
+	Example: `# Mount all the versions of a repo
+% datamon repo mount --repo ritesh-test-repo --mount /path/to/mount
+% diff -r /path/to/mount/ritesh-test-repo/labels/v1 /path/to/mount/ritesh-test-repo/labels/v2
+
+# Mount all the repos in the current context, with uncommitted diamonds
+% datamon repo mount --mount /path/to/mount --diamonds
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if datamonFlags.root.metrics.IsEnabled() {
+			// do not record timings or failures for long running or daemonized commands, do not wait for completion to report
+			datamonFlags.root.metrics.m.Usage.Inc("repo mount")
+			metrics.Flush()
+		}
+
+		ctx := cmd.Context()
+
+		// cf. comments on runDaemonized
+		if datamonFlags.bundle.Daemonize {
+			runDaemonized()
+			return
+		}
+		optionInputs := newCliOptionInputs(config, &datamonFlags)
+		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
+		if err != nil {
+			onDaemonError("create remote stores", err)
+			return
+		}
+		logger, err := optionInputs.getLogger()
+		if err != nil {
+			onDaemonError("get logger", err)
+			return
+		}
+		leafCache, err := optionInputs.leafCache()
+		if err != nil {
+			onDaemonError("open disk cache", err)
+			return
+		}
+
+		var repos []string
+		if datamonFlags.repo.RepoName != "" {
+			repos = append(repos, datamonFlags.repo.RepoName)
+		}
+
+		var fsOpts []fuse.Option
+		fsOpts = append(fsOpts, fuse.Logger(logger))
+		fsOpts = append(fsOpts, fuse.WithDiamonds(datamonFlags.fs.WithDiamonds))
+		fsOpts = append(fsOpts, fuse.CacheSize(int(datamonFlags.fs.CacheSize)))
+		fsOpts = append(fsOpts, fuse.Prefetch(datamonFlags.fs.WithPrefetch))
+		fsOpts = append(fsOpts, fuse.ReadAhead(datamonFlags.fs.ReadAhead))
+		fsOpts = append(fsOpts, fuse.MaxPrefetches(datamonFlags.fs.MaxPrefetches))
+		fsOpts = append(fsOpts, fuse.VerifyHash(datamonFlags.fs.WithVerifyHash))
+		fsOpts = append(fsOpts, fuse.IndexDir(datamonFlags.fs.IndexDir))
+		fsOpts = append(fsOpts, fuse.DiskCache(leafCache))
+		fsOpts = append(fsOpts, fuse.WithMetrics(datamonFlags.root.metrics.IsEnabled()))
+
+		fs, err := fuse.NewRepoFS(remoteStores, repos, fsOpts...)
+		if err != nil {
+			onDaemonError("create repo filesystem", err)
+			return
+		}
+		if err = fs.MountReadOnly(datamonFlags.fs.MountPath); err != nil {
+			onDaemonError("mount repo filesystem", err)
+			return
+		}
+
+		registerSIGINTHandlerMount(datamonFlags.fs.MountPath)
+		if err = daemonizer.SignalOutcome(nil); err != nil {
+			wrapFatalln("send event from possibly daemonized process", err)
+			return
+		}
+		if err = fs.JoinMount(ctx); err != nil {
+			wrapFatalln("block on os mount", err)
+			return
+		}
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+func init() {
+	requireFlags(mountRepoCmd,
+		addMountPathFlag(mountRepoCmd),
+	)
+
+	addRepoNameOptionFlag(mountRepoCmd)
+	addDaemonizeFlag(mountRepoCmd)
+	addWithDiamondsFlag(mountRepoCmd)
+	addCacheSizeFlag(mountRepoCmd)
+	addPrefetchFlag(mountRepoCmd)
+	addReadAheadFlag(mountRepoCmd)
+	addMaxPrefetchesFlag(mountRepoCmd)
+	addVerifyHashFlag(mountRepoCmd)
+	addIndexDirFlag(mountRepoCmd)
+	addDiskCacheFlag(mountRepoCmd)
+	addDiskCacheSizeFlag(mountRepoCmd)
+
+	repoCmd.AddCommand(mountRepoCmd)
+}
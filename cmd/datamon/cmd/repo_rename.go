@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"time"
 
 	"github.com/oneconcern/datamon/pkg/core"
@@ -30,7 +29,7 @@ This command MUST NOT BE RUN concurrently.
 
 		newName := args[0]
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		remoteStores, err := optionInputs.datamonContext(ctx)
 		if err != nil {
@@ -38,6 +37,15 @@ This command MUST NOT BE RUN concurrently.
 			return
 		}
 		logger, err := optionInputs.getLogger()
+		if err != nil {
+			wrapFatalln("get logger", err)
+			return
+		}
+		contributor, err := optionInputs.contributor()
+		if err != nil {
+			wrapFatalln("populate contributor struct", err)
+			return
+		}
 
 		if !datamonFlags.root.forceYes && !userConfirm("rename") {
 			wrapFatalln("user aborted", nil)
@@ -45,7 +53,7 @@ This command MUST NOT BE RUN concurrently.
 		}
 
 		logger.Info("renaming repo", zap.String("repo", datamonFlags.repo.RepoName), zap.String("new repo", newName))
-		err = core.RenameRepo(datamonFlags.repo.RepoName, newName, remoteStores)
+		err = core.RenameRepo(datamonFlags.repo.RepoName, newName, remoteStores, core.WithContributor(contributor))
 		if err != nil {
 			wrapFatalln("rename repo", err)
 			return
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"time"
 
 	"github.com/oneconcern/datamon/pkg/core"
@@ -23,7 +22,7 @@ Optionally, the squashing may also retain past tagged bundles, or only past tagg
 			cliUsage(t0, "repo squash", err)
 		}(time.Now())
 
-		ctx := context.Background()
+		ctx := cmd.Context()
 		optionInputs := newCliOptionInputs(config, &datamonFlags)
 		remoteStores, err := optionInputs.datamonContext(ctx)
 		if err != nil {
@@ -38,6 +37,7 @@ Optionally, the squashing may also retain past tagged bundles, or only past tagg
 			core.WithRetainNLatest(datamonFlags.squash.RetainNLatest),
 			core.ConcurrentList(datamonFlags.core.ConcurrencyFactor),
 			core.BatchSize(datamonFlags.core.BatchSize),
+			core.WithContributor(optionInputs.optionalContributor()),
 		)
 		if err != nil {
 			wrapFatalln("squash repo", err)
@@ -3,20 +3,14 @@
 package cmd
 
 import (
-	"crypto/tls"
 	"net"
-	"net/url"
 	"os"
 	"path/filepath"
 	"runtime/pprof"
-	"sync/atomic"
-	"time"
 
 	"github.com/spf13/viper"
 
-	gauth "github.com/oneconcern/datamon/pkg/auth/google"
 	"github.com/oneconcern/datamon/pkg/metrics"
-	"github.com/oneconcern/datamon/pkg/metrics/exporters/influxdb"
 	"github.com/spf13/cobra"
 )
 
@@ -56,47 +50,6 @@ your data buckets are organized in repositories of versioned and tagged bundles
 		}
 
 		if datamonFlags.root.metrics.IsEnabled() {
-			// validate config for metrics
-			var err error
-
-			if metricsURL := datamonFlags.root.metrics.URL; metricsURL != "" {
-				_, err = url.Parse(metricsURL)
-				if err != nil {
-					wrapFatalln("the metrics URL should be a valid URL, e.g. https://[user:password@]host[:port]", err)
-				}
-			}
-
-			version := NewVersionInfo()
-			ip := getOutboundIP()
-
-			opts := []influxdb.StoreOption{
-				influxdb.WithDatabase("datamon"),
-				influxdb.WithURL(datamonFlags.root.metrics.URL),
-				influxdb.WithNameAsTag("metrics"), // use metric name as an influxdb tag, with unique time series "metrics"
-				influxdb.WithTimeout(30 * time.Second),
-				influxdb.WithTLSConfig(&tls.Config{
-					MinVersion: tls.VersionTLS13,
-					NextProtos: []string{"h2", "http/1.1"},
-				}),
-				influxdb.WithInsecureSkipVerify(true),
-			}
-
-			// override credentials for metrics backend
-			user := datamonFlags.root.metrics.User
-			if user != "" {
-				opts = append(opts, influxdb.WithUser(user))
-			}
-
-			password := datamonFlags.root.metrics.Password
-			if password != "" {
-				opts = append(opts, influxdb.WithPassword(password))
-			}
-
-			sink, err := influxdb.NewStore(opts...)
-			if err != nil {
-				wrapFatalln("cannot register metrics store", err)
-			}
-
 			optionInputs := newCliOptionInputs(config, &datamonFlags)
 			contributor, err := optionInputs.contributor()
 			if err != nil {
@@ -104,24 +57,11 @@ your data buckets are organized in repositories of versioned and tagged bundles
 				return
 			}
 
-			var errorReported int64
-			exporter := metrics.DefaultExporter(
-				influxdb.WithStore(sink),
-				influxdb.WithErrorHandler(func(e error) {
-					// only report first error on metrics
-					firstTime := atomic.CompareAndSwapInt64(&errorReported, 0, 1)
-					if firstTime {
-						errlog.Printf("warning: metrics export failed: %v", e)
-					}
-				}),
-				influxdb.WithTags(map[string]string{
-					"service": "datamon",
-					"version": version.Version, // want to track datamon versions in all metrics
-					"ip":      ip.String(),     // want to track originating IP in all metrics
-					"context": datamonFlags.context.Descriptor.Name,
-					"user":    contributor.Email,
-				}),
-			)
+			exporter, err := metricsExporter(contributor.Email)
+			if err != nil {
+				wrapFatalln("cannot register metrics exporter", err)
+				return
+			}
 
 			metrics.Init(
 				metrics.WithBasePath("datamon"), // all metrics are organized in a tree rooted by "/datamon/..."
@@ -130,6 +70,13 @@ your data buckets are organized in repositories of versioned and tagged bundles
 			// register CLI specific metrics
 			datamonFlags.root.metrics.m = metrics.EnsureMetrics("cmd", &M{}).(*M)
 		}
+
+		if datamonFlags.root.tracing.IsEnabled() {
+			if err := startCommandTrace(cmd); err != nil {
+				wrapFatalln("cannot register trace exporter", err)
+				return
+			}
+		}
 	},
 	// upstream api note:  *PostRun functions aren't called in case of a panic() in Run
 	PersistentPostRun: func(cmd *cobra.Command, args []string) {
@@ -137,6 +84,7 @@ your data buckets are organized in repositories of versioned and tagged bundles
 			pprof.StopCPUProfile()
 		}
 
+		endCommandTrace(nil)
 	},
 }
 
@@ -153,7 +101,6 @@ func Execute() {
 
 func init() {
 	cobra.OnInitialize(initConfig)
-	authorizer = gauth.New()
 	addConfigFlag(rootCmd)
 	addContextFlag(rootCmd)
 	addUpgradeFlag(rootCmd)
@@ -163,6 +110,10 @@ func init() {
 	addMetricsURLFlag(rootCmd)
 	addMetricsUserFlag(rootCmd)
 	addMetricsPasswordFlag(rootCmd)
+	addMetricsExporterFlag(rootCmd)
+	addMetricsPortFlag(rootCmd)
+	addTraceFlag(rootCmd)
+	addTraceURLFlag(rootCmd)
 
 	addTemplateFlag(repoCmd)
 	rootCmd.AddCommand(repoCmd)
@@ -201,6 +152,9 @@ func init() {
 	addPurgeResumeFlag(reverseLookupCmd)
 	addPurgeSingleContextFlag(reverseLookupCmd)
 	addPurgeChunkIndexFlag(reverseLookupCmd)
+	addDiamondTTLFlag(reverseLookupCmd)
+	addSplitTTLFlag(reverseLookupCmd)
+	addProgressFlag(reverseLookupCmd)
 
 	purgeCmd.AddCommand(reverseLookupCmd)
 	purgeCmd.AddCommand(deleteUnusedCmd)
@@ -289,6 +243,31 @@ func initConfig() {
 		datamonFlags.root.metrics.Password = config.Metrics.Password
 	}
 
+	if datamonFlags.root.metrics.Exporter == "" {
+		datamonFlags.root.metrics.Exporter = viper.GetString("DATAMON_METRICS_EXPORTER")
+	}
+	if datamonFlags.root.metrics.Exporter == "" {
+		datamonFlags.root.metrics.Exporter = config.Metrics.Exporter
+	}
+
+	if datamonFlags.root.metrics.Port == 0 {
+		datamonFlags.root.metrics.Port = viper.GetInt("DATAMON_METRICS_PORT")
+	}
+	if datamonFlags.root.metrics.Port == 0 {
+		datamonFlags.root.metrics.Port = config.Metrics.Port
+	}
+
+	if config.Tracing.Enabled != nil && !rootCmd.PersistentFlags().Changed(addTraceFlag(nil)) {
+		datamonFlags.root.tracing.Enabled = config.Tracing.Enabled
+	}
+
+	if datamonFlags.root.tracing.URL == "" {
+		datamonFlags.root.tracing.URL = viper.GetString("DATAMON_TRACE_URL")
+	}
+	if datamonFlags.root.tracing.URL == "" {
+		datamonFlags.root.tracing.URL = config.Tracing.URL
+	}
+
 	datamonFlagsPtr := &datamonFlags
 	datamonFlagsPtr.setDefaultsFromConfig(config)
 
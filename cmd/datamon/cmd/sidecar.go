@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// sidecarCmd is a group of commands to run datamon as a sidecar container
+var sidecarCmd = &cobra.Command{
+	Use:   "sidecar",
+	Short: "Commands to run datamon as a sidecar",
+	Long: `Commands to run datamon as a sidecar container, alongside some application container in a kubernetes pod.
+
+The sidecar provides bundles as input to the application, then saves the output of the application as bundles.
+The sidecar and the application wrapper coordinate with signaling files on a coordination point shared by the containers of the pod.
+`,
+}
+
+func init() {
+	rootCmd.AddCommand(sidecarCmd)
+}
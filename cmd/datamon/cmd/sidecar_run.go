@@ -0,0 +1,331 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/sidecar"
+	"github.com/oneconcern/datamon/pkg/sidecar/param"
+	"github.com/oneconcern/datamon/pkg/sidecar/status"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// sidecarParamsEnv holds the sidecar parameters, when not set by flag
+	sidecarParamsEnv = "DATAMON_SIDECAR_PARAMS"
+
+	// legacy environment variables used by the sidecar scripts
+	legacyFUSEParamsEnv = "dm_fuse_params"
+	legacyPGParamsEnv   = "SIDECAR_CONFIG"
+)
+
+var sidecarRun = &cobra.Command{
+	Use:   "run",
+	Short: "Run a datamon sidecar",
+	Long: `Run a datamon sidecar, coordinating with the application wrapper.
+
+A "fuse" sidecar mounts the source bundles, signals "mountdone", waits for the application wrapper to signal "initupload",
+then uploads the destination bundles and signals "uploaddone".
+
+A "postgres" sidecar starts a database server for each database, either restored from a bundle or created from scratch,
+then signals "dbstarted". When the application wrapper signals "initdbupload", the database server is stopped
+and the database is uploaded as a bundle. The sidecar then signals "dbuploaddone".
+Events for databases are signaled in a subfolder of the coordination point named after the database.
+
+A "spec" sidecar runs a declarative specification of inputs and outputs, which may be mixed freely:
+  inputs:  mount (a read-only bundle), download (a bundle to a local folder), database (a postgres server,
+           restored from a bundle or created from scratch)
+  outputs: upload (a local folder as a new bundle), label (a label on an input or an existing bundle),
+           database (a database as a new bundle)
+Every input and output has an independent lifecycle, with events signaled in a subfolder of the coordination point
+named after it. An input is ready when it emits "mountdone", "downloaddone" or "dbstarted". Mounts are unmounted and
+database servers are stopped when the application wrapper emits "release". An output is saved when the application wrapper
+emits "initupload", then emits "uploaddone". The "release" and "initupload" events may be emitted at the root of
+the coordination point to address all inputs or outputs at once. Saving a database stops its server.
+
+Parameters are specified as a YAML or JSON document (see the sidecar_param package), either with "--params" or with
+the DATAMON_SIDECAR_PARAMS environment variable. For fuse and postgres sidecars, the legacy environment variables
+used by the sidecar scripts, dm_fuse_params and SIDECAR_CONFIG, are supported as a fallback.
+
+The coordination point set with "--coord-point" overrides the parameters.
+
+The sidecar publishes its state (starting, ready, uploading, done or failed) on the coordination point,
+so the application wrapper may stop whenever the sidecar fails (see "datamon sidecar wait").
+
+The command exits with a non-zero status whenever the sidecar fails:
+  1  unexpected failure
+  2  invalid parameters
+  3  a bundle could not be mounted or downloaded
+  4  a bundle could not be uploaded
+  5  a database server could not be operated
+  6  coordination with the application wrapper failed
+`,
+	Example: `% datamon sidecar run --type fuse --params /config/fuse-params.yaml
+
+% datamon sidecar run --type spec --params /config/sidecar.yaml
+
+% DATAMON_SIDECAR_PARAMS=/config/pg-params.yaml datamon sidecar run --type postgres --coord-point /tmp/coord`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+
+		defer func(t0 time.Time) {
+			cliUsage(t0, "sidecar run", err)
+		}(time.Now())
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		cancelOnSignal(cancel)
+
+		switch datamonFlags.sidecar.Type {
+		case sidecar.TypeFUSE:
+			err = runFUSESidecar(ctx, cmd)
+		case sidecar.TypePostgres:
+			err = runPGSidecar(ctx)
+		case sidecar.TypeSpec:
+			err = runSpecSidecar(ctx, cmd)
+		default:
+			err = status.ErrInvalidParams.WrapMessage("unsupported sidecar type %q: expect %q, %q or %q",
+				datamonFlags.sidecar.Type, sidecar.TypeFUSE, sidecar.TypePostgres, sidecar.TypeSpec)
+		}
+
+		if err != nil {
+			wrapFatalWithCodef(sidecar.ExitCode(err), "sidecar run: %v", err)
+		}
+	},
+}
+
+// readSidecarParams reads the sidecar parameters from a file or from the flag value itself,
+// with a fallback on environment variables
+func readSidecarParams(legacyEnv string) ([]byte, error) {
+	spec := datamonFlags.sidecar.Params
+	if spec == "" {
+		spec = os.Getenv(sidecarParamsEnv)
+	}
+	if spec == "" && legacyEnv != "" {
+		spec = os.Getenv(legacyEnv)
+	}
+	if spec == "" {
+		return nil, fmt.Errorf("sidecar parameters are required: set --%s or $%s", addSidecarParamsFlag(nil), sidecarParamsEnv)
+	}
+
+	if !strings.ContainsAny(spec, "\n{:") {
+		// a file
+		return ioutil.ReadFile(spec)
+	}
+
+	return []byte(spec), nil
+}
+
+// reportSidecarFailure publishes the state of a sidecar which failed before it could run, so the application wrapper
+// doesn't wait for it. The original error is returned.
+func reportSidecarFailure(coordPoint, kind string, err error) error {
+	if coordPoint == "" {
+		return err
+	}
+
+	name := datamonFlags.sidecar.Name
+	if name == "" {
+		name = kind
+	}
+
+	if erp := sidecar.NewCoordinator(coordPoint, 0).ReportFailure(name, kind, err); erp != nil {
+		infoLogger.Printf("warning: could not report sidecar failure: %v", erp)
+	}
+
+	return err
+}
+
+func sidecarOptions(optionInputs *cliOptionInputs) ([]sidecar.Option, error) {
+	logger, err := optionInputs.getLogger()
+	if err != nil {
+		return nil, err
+	}
+
+	return []sidecar.Option{
+		sidecar.WithLogger(logger),
+		sidecar.WithName(datamonFlags.sidecar.Name),
+		sidecar.WithPollInterval(datamonFlags.sidecar.PollInterval),
+		sidecar.WithSleepTimeout(datamonFlags.sidecar.SleepTimeout),
+		sidecar.WithPGDataDir(datamonFlags.sidecar.PGDataDir),
+		sidecar.WithPostgres(sidecar.NewPostgres(
+			sidecar.PostgresVersionBinDir(datamonFlags.sidecar.PGVersionBinDir),
+		)),
+	}, nil
+}
+
+func sidecarActions(ctx context.Context, optionInputs *cliOptionInputs, contributor model.Contributor) (sidecar.Actions, error) {
+	if err := optionInputs.populateRemoteConfig(); err != nil {
+		return nil, fmt.Errorf("populate remote config: %w", err)
+	}
+
+	remoteStores, err := optionInputs.datamonContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("create remote stores: %w", err)
+	}
+
+	logger, err := optionInputs.getLogger()
+	if err != nil {
+		return nil, err
+	}
+
+	return sidecar.NewActions(remoteStores,
+		sidecar.ActionsLogger(logger),
+		sidecar.ActionsContributor(contributor),
+	), nil
+}
+
+func runFUSESidecar(ctx context.Context, cmd *cobra.Command) error {
+	b, err := readSidecarParams(legacyFUSEParamsEnv)
+	if err != nil {
+		return reportSidecarFailure(datamonFlags.sidecar.CoordPoint, sidecar.TypeFUSE, status.ErrInvalidParams.Wrap(err))
+	}
+
+	params, err := param.UnmarshalFUSEParams(b)
+	if err != nil {
+		return reportSidecarFailure(datamonFlags.sidecar.CoordPoint, sidecar.TypeFUSE,
+			status.ErrInvalidParams.WrapMessage("fuse sidecar parameters: %v", err),
+		)
+	}
+
+	if datamonFlags.sidecar.CoordPoint != "" {
+		params.Globals.CoordPoint = datamonFlags.sidecar.CoordPoint
+	}
+	if datamonFlags.sidecar.SleepInsteadOfExit {
+		params.Globals.SleepInsteadOfExit = true
+	}
+
+	setSidecarContext(cmd, params.Globals.ConfigBucketName, params.Globals.ContextName)
+
+	optionInputs := newCliOptionInputs(config, &datamonFlags)
+	actions, err := sidecarActions(ctx, optionInputs, optionInputs.optionalContributor())
+	if err != nil {
+		return reportSidecarFailure(params.Globals.CoordPoint, sidecar.TypeFUSE, err)
+	}
+
+	opts, err := sidecarOptions(optionInputs)
+	if err != nil {
+		return reportSidecarFailure(params.Globals.CoordPoint, sidecar.TypeFUSE, err)
+	}
+
+	return sidecar.New(actions, opts...).RunFUSE(ctx, params)
+}
+
+func runPGSidecar(ctx context.Context) error {
+	b, err := readSidecarParams(legacyPGParamsEnv)
+	if err != nil {
+		return reportSidecarFailure(datamonFlags.sidecar.CoordPoint, sidecar.TypePostgres, status.ErrInvalidParams.Wrap(err))
+	}
+
+	params, err := param.UnmarshalPGParams(b)
+	if err != nil {
+		return reportSidecarFailure(datamonFlags.sidecar.CoordPoint, sidecar.TypePostgres,
+			status.ErrInvalidParams.WrapMessage("postgres sidecar parameters: %v", err),
+		)
+	}
+
+	if datamonFlags.sidecar.CoordPoint != "" {
+		params.Globals.CoordPoint = datamonFlags.sidecar.CoordPoint
+	}
+	if datamonFlags.sidecar.SleepInsteadOfExit {
+		params.Globals.SleepInsteadOfExit = true
+	}
+
+	optionInputs := newCliOptionInputs(config, &datamonFlags)
+	contributor := model.Contributor{
+		Name:  params.Globals.Contributor.Name,
+		Email: params.Globals.Contributor.Email,
+	}
+	if contributor.Email == "" {
+		contributor = optionInputs.optionalContributor()
+	}
+
+	actions, err := sidecarActions(ctx, optionInputs, contributor)
+	if err != nil {
+		return reportSidecarFailure(params.Globals.CoordPoint, sidecar.TypePostgres, err)
+	}
+
+	opts, err := sidecarOptions(optionInputs)
+	if err != nil {
+		return reportSidecarFailure(params.Globals.CoordPoint, sidecar.TypePostgres, err)
+	}
+
+	return sidecar.New(actions, opts...).RunPG(ctx, params)
+}
+
+func runSpecSidecar(ctx context.Context, cmd *cobra.Command) error {
+	b, err := readSidecarParams("")
+	if err != nil {
+		return reportSidecarFailure(datamonFlags.sidecar.CoordPoint, sidecar.TypeSpec, status.ErrInvalidParams.Wrap(err))
+	}
+
+	spec, err := param.UnmarshalSpec(b)
+	if err != nil {
+		return reportSidecarFailure(datamonFlags.sidecar.CoordPoint, sidecar.TypeSpec,
+			status.ErrInvalidParams.WrapMessage("sidecar specification: %v", err),
+		)
+	}
+
+	if datamonFlags.sidecar.CoordPoint != "" {
+		spec.Globals.CoordPoint = datamonFlags.sidecar.CoordPoint
+	}
+	if datamonFlags.sidecar.SleepInsteadOfExit {
+		spec.Globals.SleepInsteadOfExit = true
+	}
+
+	if err = spec.Validate(); err != nil {
+		return reportSidecarFailure(spec.Globals.CoordPoint, sidecar.TypeSpec, status.ErrInvalidParams.Wrap(err))
+	}
+
+	setSidecarContext(cmd, spec.Globals.ConfigBucketName, spec.Globals.ContextName)
+
+	optionInputs := newCliOptionInputs(config, &datamonFlags)
+	contributor := model.Contributor{
+		Name:  spec.Globals.Contributor.Name,
+		Email: spec.Globals.Contributor.Email,
+	}
+	if contributor.Email == "" {
+		contributor = optionInputs.optionalContributor()
+	}
+
+	actions, err := sidecarActions(ctx, optionInputs, contributor)
+	if err != nil {
+		return reportSidecarFailure(spec.Globals.CoordPoint, sidecar.TypeSpec, err)
+	}
+
+	opts, err := sidecarOptions(optionInputs)
+	if err != nil {
+		return reportSidecarFailure(spec.Globals.CoordPoint, sidecar.TypeSpec, err)
+	}
+
+	return sidecar.New(actions, opts...).Run(ctx, spec)
+}
+
+// setSidecarContext sets the config bucket and context specified by sidecar parameters.
+// The config and context set by flags take precedence over parameters.
+func setSidecarContext(cmd *cobra.Command, bucket, contextName string) {
+	if bucket != "" && !cmd.Flags().Changed(addConfigFlag(nil)) {
+		datamonFlags.core.Config = bucket
+	}
+	if contextName != "" && !cmd.Flags().Changed(addContextFlag(nil)) {
+		datamonFlags.context.Descriptor.Name = contextName
+	}
+}
+
+func init() {
+	addSidecarTypeFlag(sidecarRun)
+	addSidecarParamsFlag(sidecarRun)
+	addSidecarCoordPointFlag(sidecarRun)
+	addSidecarNameFlag(sidecarRun)
+	addSidecarSleepInsteadOfExitFlag(sidecarRun)
+	addSidecarPollIntervalFlag(sidecarRun)
+	addSidecarSleepTimeoutFlag(sidecarRun)
+	addSidecarPGDataDirFlag(sidecarRun)
+	addSidecarPGVersionBinDirFlag(sidecarRun)
+
+	sidecarCmd.AddCommand(sidecarRun)
+}
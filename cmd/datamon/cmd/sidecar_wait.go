@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/sidecar"
+	"github.com/spf13/cobra"
+)
+
+var sidecarWait = &cobra.Command{
+	Use:   "wait",
+	Short: "Wait for sidecars to reach some status",
+	Long: `Wait for sidecars to reach some status, as published in their state on the coordination point.
+
+This command is intended for the application wrapper: it fails fast whenever some sidecar has failed,
+instead of waiting indefinitely for signaling files.
+
+Sidecars are designated by name with "--sidecar". By default, the command waits for all sidecars which have published
+their state: at least one sidecar must have started.
+
+The statuses of a sidecar are, in order: starting, ready, uploading, done. A sidecar may fail at any stage.
+
+The command exits with:
+  0    all sidecars have reached the status
+  124  the wait timed out
+  the exit code of the first sidecar found failed, otherwise (see "datamon sidecar run")
+`,
+	Example: `# Wait for bundles to be mounted or databases to be started
+% datamon sidecar wait --coord-point /tmp/coord --sidecar fuse --sidecar postgres --for ready --timeout 30m
+
+# Wait for all uploads to complete
+% datamon sidecar wait --coord-point /tmp/coord --for done`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var err error
+
+		defer func(t0 time.Time) {
+			cliUsage(t0, "sidecar wait", err)
+		}(time.Now())
+
+		target := sidecar.Status(datamonFlags.sidecar.WaitFor)
+		if !target.IsValid() || target == sidecar.StatusFailed || target == sidecar.StatusStarting {
+			err = fmt.Errorf("invalid status to wait for: %q", target)
+			wrapFatalWithCodef(sidecar.ExitCodeInvalidParams, "sidecar wait: %v", err)
+			return
+		}
+
+		ctx, cancel := context.WithCancel(cmd.Context())
+		defer cancel()
+		cancelOnSignal(cancel)
+
+		if datamonFlags.sidecar.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, datamonFlags.sidecar.Timeout)
+			defer cancel()
+		}
+
+		coord := sidecar.NewCoordinator(datamonFlags.sidecar.CoordPoint, datamonFlags.sidecar.PollInterval)
+		states, err := coord.Wait(ctx, target, datamonFlags.sidecar.Names...)
+		for _, state := range states {
+			log.Printf("%s (%s): %s", state.Name, state.Type, state.Status)
+		}
+
+		if err != nil {
+			wrapFatalWithCodef(sidecar.ExitCode(err), "sidecar wait: %v", err)
+		}
+	},
+}
+
+func init() {
+	requireFlags(sidecarWait,
+		addSidecarCoordPointFlag(sidecarWait),
+	)
+	addSidecarNamesFlag(sidecarWait)
+	addSidecarWaitForFlag(sidecarWait)
+	addSidecarTimeoutFlag(sidecarWait)
+	addSidecarPollIntervalFlag(sidecarWait)
+
+	sidecarCmd.AddCommand(sidecarWait)
+}
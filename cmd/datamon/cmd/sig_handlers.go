@@ -3,8 +3,10 @@
 package cmd
 
 import (
+	"context"
 	"os"
 	"os/signal"
+	"syscall"
 
 	"github.com/jacobsa/fuse"
 )
@@ -29,3 +31,15 @@ func registerSIGINTHandlerMount(mountPoint string) {
 		}
 	}()
 }
+
+// cancelOnSignal cancels a context on SIGINT or SIGTERM, e.g. when a pod is terminated
+func cancelOnSignal(cancel context.CancelFunc) {
+	signalChan := make(chan os.Signal, 1)
+	signal.Notify(signalChan, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		sig := <-signalChan
+		infoLogger.Printf("received %v, interrupting...", sig)
+		cancel()
+	}()
+}
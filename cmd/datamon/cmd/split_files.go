@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"github.com/oneconcern/datamon/pkg/core"
+
+	"github.com/spf13/cobra"
+)
+
+// ListSplitFilesCmd lists the files uploaded by a split
+var ListSplitFilesCmd = &cobra.Command{
+	Use:   "files",
+	Short: "List files in a split",
+	Long: `List all the files uploaded by a split, before the diamond is committed.
+
+The split must be done. If a file has been uploaded several times by this split, only its latest version is listed.`,
+	Example: `% datamon diamond split files --repo ritesh-test-repo --diamond 1ySIsDlHSX0ZG3ZtYByPqv7dgvU --split 1ySItWf1pVmhwGJ15f2RAfOx1S0
+name:common/data.csv, size:1024, hash:d6b2c61c...
+...`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+		datamonFlagsPtr := &datamonFlags
+		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
+		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
+		if err != nil {
+			wrapFatalln("create remote stores", err)
+			return
+		}
+		logger, err := optionInputs.getLogger()
+		if err != nil {
+			wrapFatalln("get logger", err)
+			return
+		}
+
+		entries, err := core.ListSplitFiles(datamonFlags.repo.RepoName, datamonFlags.diamond.diamondID, datamonFlags.split.splitID, remoteStores,
+			core.DiamondLogger(logger),
+			core.DiamondWithMetrics(datamonFlags.root.metrics.IsEnabled()))
+		if err != nil {
+			wrapFatalln("list split files", err)
+			return
+		}
+
+		if err = applyFileTemplate(entries); err != nil {
+			wrapFatalln("executing template", err)
+			return
+		}
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		if err := newCliOptionInputs(config, &datamonFlags).populateRemoteConfig(); err != nil {
+			wrapFatalln("populate remote config", err)
+		}
+	},
+}
+
+func init() {
+	requireFlags(ListSplitFilesCmd,
+		addRepoNameOptionFlag(ListSplitFilesCmd),
+		addDiamondFlag(ListSplitFilesCmd),
+		addSplitFlag(ListSplitFilesCmd),
+	)
+
+	SplitCmd.AddCommand(ListSplitFilesCmd)
+}
@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"bytes"
-	"context"
 	"fmt"
 
 	"github.com/oneconcern/datamon/pkg/core"
@@ -17,7 +16,7 @@ var ListSplitCmd = &cobra.Command{
 	Short: "Lists splits in a diamond and in a repo",
 	Long:  `Lists splits in a diamond and in a repo, ordered by their start time`,
 	Run: func(cmd *cobra.Command, args []string) {
-		ctx := context.Background()
+		ctx := cmd.Context()
 		datamonFlagsPtr := &datamonFlags
 		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
 		remoteStores, err := optionInputs.datamonContext(ctx, ReadOnlyContext())
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/oneconcern/datamon/pkg/tracing"
+	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const traceFlushTimeout = 10 * time.Second
+
+type tracingFlags struct {
+	Enabled *bool  `json:"enabled,omitempty" yaml:"enabled,omitempty"` // pointer because we want to distinguish unset from false
+	URL     string `json:"url,omitempty" yaml:"url,omitempty"`         // endpoint of the Jaeger collector
+}
+
+func (t tracingFlags) IsEnabled() bool {
+	return t.Enabled != nil && *t.Enabled
+}
+
+// commandTrace holds the root span of the running command
+var commandTrace struct {
+	mx       sync.Mutex
+	span     trace.Span
+	shutdown func(context.Context) error
+}
+
+// startCommandTrace starts a new trace for a command.
+//
+// The context of the command carries the root span of the trace: all spans started
+// from this context belong to this trace.
+func startCommandTrace(cmd *cobra.Command) error {
+	shutdown, err := tracing.Init(
+		tracing.WithEndpoint(datamonFlags.root.tracing.URL),
+		tracing.WithAttributes(map[string]string{
+			"version": NewVersionInfo().Version,
+			"context": datamonFlags.context.Descriptor.Name,
+		}),
+	)
+	if err != nil {
+		return err
+	}
+
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, span := tracing.Tracer().Start(ctx, cmd.CommandPath(), trace.WithNewRoot())
+	cmd.SetContext(ctx)
+
+	commandTrace.mx.Lock()
+	defer commandTrace.mx.Unlock()
+	commandTrace.span = span
+	commandTrace.shutdown = shutdown
+
+	return nil
+}
+
+// endCommandTrace ends the trace of the command, if any, and flushes all spans to the collector.
+//
+// This is called when the command completes or exits on a fatal error.
+func endCommandTrace(err error) {
+	commandTrace.mx.Lock()
+	defer commandTrace.mx.Unlock()
+	if commandTrace.span == nil {
+		return
+	}
+
+	tracing.End(commandTrace.span, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), traceFlushTimeout)
+	defer cancel()
+	if e := commandTrace.shutdown(ctx); e != nil {
+		infoLogger.Printf("WARN: could not send traces: %v", e)
+	}
+
+	commandTrace.span = nil
+	commandTrace.shutdown = nil
+}
+
+// traceStores instruments all the stores of a context to trace their operations
+func traceStores(stores context2.Stores, logger *zap.Logger) context2.Stores {
+	instrument := func(store storage.Store) storage.Store {
+		if store == nil {
+			return nil
+		}
+		return storage.Instrument(tracing.Tracer(), *logger, store)
+	}
+
+	return context2.NewStores(
+		instrument(stores.Wal()),
+		instrument(stores.ReadLog()),
+		instrument(stores.Blob()),
+		instrument(stores.Metadata()),
+		instrument(stores.VMetadata()),
+	)
+}
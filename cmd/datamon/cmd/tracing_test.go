@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/oneconcern/datamon/pkg/tracing"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestCommandTrace(t *testing.T) {
+	saved := datamonFlags.root.tracing
+	defer func() {
+		datamonFlags.root.tracing = saved
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	}()
+
+	var posted int32
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/traces" {
+			atomic.AddInt32(&posted, 1)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer collector.Close()
+
+	datamonFlags.root.tracing = tracingFlags{URL: collector.URL + "/api/traces"}
+
+	parent := &cobra.Command{Use: "datamon"}
+	child := &cobra.Command{Use: "upload"}
+	parent.AddCommand(child)
+	child.SetContext(context.Background())
+
+	require.NoError(t, startCommandTrace(child))
+	root := trace.SpanContextFromContext(child.Context())
+	require.True(t, root.IsValid(), "the command context carries the root span")
+
+	_, span := tracing.Start(child.Context(), "core.UploadBundle")
+	assert.Equal(t, root.TraceID(), span.SpanContext().TraceID(), "spans started by the command belong to its trace")
+	tracing.End(span, nil)
+
+	endCommandTrace(nil)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&posted), "spans are flushed when the command ends")
+
+	// ending again is a no-op, e.g. on a fatal error after the command has completed
+	endCommandTrace(nil)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&posted))
+}
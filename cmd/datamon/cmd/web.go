@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"net"
 	"net/http"
 	"strconv"
@@ -27,7 +26,7 @@ var webSrv = &cobra.Command{
 		infoLogger.Println("begin webserver")
 		datamonFlagsPtr := &datamonFlags
 		optionInputs := newCliOptionInputs(config, datamonFlagsPtr)
-		stores, err := optionInputs.datamonContext(context.Background(), ReadOnlyContext())
+		stores, err := optionInputs.datamonContext(cmd.Context(), ReadOnlyContext())
 		if err != nil {
 			wrapFatalln("create remote stores", err)
 			return
@@ -0,0 +1,8 @@
+/*
+Package archive supports the archival of large file trees as datamon bundles.
+
+It generates the lists of files to archive, possibly filtered by modification time.
+Lists hold one path per line, relative to the archived directory, and are uploaded as a bundle with
+core.UploadSpecificKeys.
+*/
+package archive
@@ -0,0 +1,161 @@
+package archive
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/karrick/godirwalk"
+	"go.uber.org/zap"
+)
+
+// Option configures the generation of file lists
+type Option func(*walker)
+
+type walker struct {
+	filters        []Filter
+	followSymlinks bool
+	parallelism    int
+	l              *zap.Logger
+}
+
+// WithFilters selects the files which satisfy all filters
+func WithFilters(filters ...Filter) Option {
+	return func(w *walker) {
+		w.filters = append(w.filters, filters...)
+	}
+}
+
+// WithFollowSymlinks follows symbolic links while walking directories. It defaults to true.
+func WithFollowSymlinks(enabled bool) Option {
+	return func(w *walker) {
+		w.followSymlinks = enabled
+	}
+}
+
+// WithLogger sets a logger, reporting about the files which cannot be listed
+func WithLogger(l *zap.Logger) Option {
+	return func(w *walker) {
+		if l != nil {
+			w.l = l
+		}
+	}
+}
+
+// Summary reports about a generated file list
+type Summary struct {
+	Listed   int // files written to the list
+	Filtered int // files discarded by filters
+	Errors   int // files or directories which could not be read
+}
+
+// GenerateFileList walks a directory and writes the paths of the files it contains, one per line, relative to this directory.
+//
+// Files are listed in lexical order, so lists generated from the same tree are identical.
+// With WithParallelism, directories are read concurrently (see Scan).
+// Files or directories which cannot be read are skipped and reported by the summary.
+func GenerateFileList(root string, out io.Writer, opts ...Option) (Summary, error) {
+	var summary Summary
+	w := &walker{
+		followSymlinks: true,
+		l:              zap.NewNop(),
+	}
+	for _, apply := range opts {
+		apply(w)
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return summary, err
+	}
+	if !info.IsDir() {
+		return summary, fmt.Errorf("%s is not a directory", root)
+	}
+	root = filepath.Clean(root)
+
+	if w.parallelism > 1 {
+		return generateConcurrently(root, out, opts...)
+	}
+
+	var writeErr error
+	bw := bufio.NewWriter(out)
+	err = godirwalk.Walk(root, &godirwalk.Options{
+		Callback: func(osPathname string, de *godirwalk.Dirent) error {
+			isDir, erd := de.IsDirOrSymlinkToDir()
+			if erd != nil {
+				return erd
+			}
+			if isDir {
+				return nil
+			}
+
+			if len(w.filters) > 0 {
+				fileInfo, ers := os.Stat(osPathname)
+				if ers != nil {
+					return ers
+				}
+				if !w.selected(fileInfo) {
+					summary.Filtered++
+					return nil
+				}
+			}
+
+			rel, erl := filepath.Rel(root, osPathname)
+			if erl != nil {
+				return erl
+			}
+			if _, writeErr = bw.WriteString(filepath.ToSlash(rel) + "\n"); writeErr != nil {
+				return writeErr
+			}
+			summary.Listed++
+			return nil
+		},
+		ErrorCallback: func(osPathname string, erw error) godirwalk.ErrorAction {
+			if writeErr != nil {
+				return godirwalk.Halt
+			}
+			w.l.Warn("skipping file", zap.String("path", osPathname), zap.Error(erw))
+			summary.Errors++
+			return godirwalk.SkipNode
+		},
+		FollowSymbolicLinks: w.followSymlinks,
+		Unsorted:            false,
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	return summary, bw.Flush()
+}
+
+func generateConcurrently(root string, out io.Writer, opts ...Option) (Summary, error) {
+	files, summary, err := Scan(root, opts...)
+	if err != nil {
+		return summary, err
+	}
+
+	bw := bufio.NewWriter(out)
+	for _, file := range files {
+		if _, err = bw.WriteString(file.Name + "\n"); err != nil {
+			return summary, err
+		}
+	}
+
+	return summary, bw.Flush()
+}
+
+// ReadFileList reads a list of files, one per line. Empty lines are ignored.
+func ReadFileList(in io.Reader) ([]string, error) {
+	var files []string
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if file := strings.TrimRight(scanner.Text(), "\r"); file != "" {
+			files = append(files, file)
+		}
+	}
+
+	return files, scanner.Err()
+}
@@ -0,0 +1,80 @@
+package archive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTime(t *testing.T) {
+	for _, value := range []string{"2020-Mar-15", "2003151030", "200315103000", "2020-03-15", "2020-03-15T10:30:00Z"} {
+		parsed, err := ParseTime(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, 2020, parsed.Year())
+		assert.Equal(t, time.March, parsed.Month())
+		assert.Equal(t, 15, parsed.Day())
+	}
+
+	parsed, err := ParseTime("200315103000")
+	require.NoError(t, err)
+	assert.Equal(t, time.Local, parsed.Location())
+	assert.Equal(t, 10, parsed.Hour())
+	assert.Equal(t, 30, parsed.Minute())
+
+	_, err = ParseTime("last week")
+	require.Error(t, err)
+}
+
+func TestGenerateFileList(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-archive-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, file := range []string{"b", "a/2", "a/1", "a/sub/old", "c/old"} {
+		path := filepath.Join(tmp, filepath.FromSlash(file))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(file), 0600))
+		if strings.HasSuffix(file, "old") {
+			require.NoError(t, os.Chtimes(path, old, old))
+		}
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(tmp, "empty"), 0700))
+	require.NoError(t, os.Symlink(filepath.Join(tmp, "c"), filepath.Join(tmp, "d")))
+
+	generate := func(opts ...Option) ([]string, Summary) {
+		var buf bytes.Buffer
+		summary, err := GenerateFileList(tmp, &buf, opts...)
+		require.NoError(t, err)
+		files, err := ReadFileList(&buf)
+		require.NoError(t, err)
+		return files, summary
+	}
+
+	files, summary := generate()
+	assert.Equal(t, []string{"a/1", "a/2", "a/sub/old", "b", "c/old", "d/old"}, files)
+	assert.Equal(t, Summary{Listed: 6}, summary)
+
+	files, _ = generate(WithFollowSymlinks(false))
+	assert.Equal(t, []string{"a/1", "a/2", "a/sub/old", "b", "c/old"}, files, "links to directories are not listed")
+
+	yesterday := time.Now().Add(-24 * time.Hour)
+	files, summary = generate(WithFilters(ModifiedBefore(yesterday)))
+	assert.Equal(t, []string{"a/sub/old", "c/old", "d/old"}, files)
+	assert.Equal(t, Summary{Listed: 3, Filtered: 3}, summary)
+
+	files, _ = generate(WithFilters(ModifiedAfter(yesterday)))
+	assert.Equal(t, []string{"a/1", "a/2", "b"}, files)
+
+	_, err = GenerateFileList(filepath.Join(tmp, "b"), &bytes.Buffer{})
+	require.Error(t, err)
+}
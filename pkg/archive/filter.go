@@ -0,0 +1,47 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Filter selects the files to archive
+type Filter func(os.FileInfo) bool
+
+// ModifiedBefore selects the files last modified before some time
+func ModifiedBefore(t time.Time) Filter {
+	return func(info os.FileInfo) bool {
+		return info.ModTime().Before(t)
+	}
+}
+
+// ModifiedAfter selects the files last modified after some time
+func ModifiedAfter(t time.Time) Filter {
+	return func(info os.FileInfo) bool {
+		return info.ModTime().After(t)
+	}
+}
+
+// timeFormats are the formats accepted by ParseTime, in local time unless specified by the format
+var timeFormats = []string{
+	"2006-Jan-02",
+	"0601021504",
+	"060102150405",
+	"2006-01-02",
+	time.RFC3339,
+}
+
+// ParseTime parses the time used by modification time filters.
+//
+// Accepted formats are "2006-Jan-02", "0601021504", "060102150405", "2006-01-02" and RFC3339.
+func ParseTime(value string) (time.Time, error) {
+	for _, format := range timeFormats {
+		t, err := time.ParseInLocation(format, value, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("time %q doesn't match any valid format: %v", value, timeFormats)
+}
@@ -0,0 +1,258 @@
+package archive
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/karrick/godirwalk"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultParallelism = 10
+	readDirBufferSize  = 1024 * 1024
+)
+
+// File describes a file found by Scan
+type File struct {
+	Name    string // path relative to the scanned directory, with forward slashes
+	Size    int64
+	ModTime time.Time
+}
+
+// WithParallelism sets the number of directories read concurrently.
+//
+// Scan defaults to 10. GenerateFileList walks directories sequentially unless this option is set.
+func WithParallelism(parallelism int) Option {
+	return func(w *walker) {
+		if parallelism > 0 {
+			w.parallelism = parallelism
+		}
+	}
+}
+
+// scanDir is a directory to read, with the targets of the links followed to reach it
+type scanDir struct {
+	path  string
+	links []string
+}
+
+// dirQueue holds the directories remaining to read.
+//
+// Directories are pending until they are read, and reading a directory queues its subdirectories:
+// the walk is complete when no directory is pending anymore.
+type dirQueue struct {
+	mx      sync.Mutex
+	cond    *sync.Cond
+	dirs    []scanDir
+	pending int
+}
+
+func newDirQueue() *dirQueue {
+	q := &dirQueue{}
+	q.cond = sync.NewCond(&q.mx)
+	return q
+}
+
+func (q *dirQueue) push(dir scanDir) {
+	q.mx.Lock()
+	q.dirs = append(q.dirs, dir)
+	q.pending++
+	q.mx.Unlock()
+	q.cond.Signal()
+}
+
+// pop waits for a directory to read, and yields false when the walk is complete
+func (q *dirQueue) pop() (scanDir, bool) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	for len(q.dirs) == 0 && q.pending > 0 {
+		q.cond.Wait()
+	}
+	if len(q.dirs) == 0 {
+		return scanDir{}, false
+	}
+	dir := q.dirs[len(q.dirs)-1]
+	q.dirs = q.dirs[:len(q.dirs)-1]
+	return dir, true
+}
+
+// done signals that a directory has been read
+func (q *dirQueue) done() {
+	q.mx.Lock()
+	q.pending--
+	complete := q.pending == 0
+	q.mx.Unlock()
+	if complete {
+		q.cond.Broadcast()
+	}
+}
+
+// Scan walks a directory tree, reading directories concurrently, and yields the files it contains
+// with their size and modification time, sorted by name.
+//
+// Files or directories which cannot be read are skipped and reported by the summary.
+func Scan(root string, opts ...Option) ([]File, Summary, error) {
+	var summary Summary
+	w := &walker{
+		followSymlinks: true,
+		parallelism:    defaultParallelism,
+		l:              zap.NewNop(),
+	}
+	for _, apply := range opts {
+		apply(w)
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, summary, err
+	}
+	if !info.IsDir() {
+		return nil, summary, fmt.Errorf("%s is not a directory", root)
+	}
+	root = filepath.Clean(root)
+
+	var (
+		wg    sync.WaitGroup
+		mx    sync.Mutex
+		files []File
+	)
+	queue := newDirQueue()
+	queue.push(scanDir{path: root})
+
+	for i := 0; i < w.parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buffer := make([]byte, readDirBufferSize)
+			for {
+				dir, ok := queue.pop()
+				if !ok {
+					return
+				}
+				found, stats := w.readDir(root, dir, buffer, queue)
+				queue.done()
+
+				mx.Lock()
+				files = append(files, found...)
+				summary.Filtered += stats.Filtered
+				summary.Errors += stats.Errors
+				mx.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	summary.Listed = len(files)
+
+	return files, summary, nil
+}
+
+// readDir lists the files of a directory, and queues its subdirectories
+func (w *walker) readDir(root string, dir scanDir, buffer []byte, queue *dirQueue) ([]File, Summary) {
+	var summary Summary
+
+	dirents, err := godirwalk.ReadDirents(dir.path, buffer)
+	if err != nil {
+		w.l.Warn("skipping directory", zap.String("path", dir.path), zap.Error(err))
+		summary.Errors++
+		return nil, summary
+	}
+
+	files := make([]File, 0, len(dirents))
+	for _, dirent := range dirents {
+		pth := filepath.Join(dir.path, dirent.Name())
+		if dirent.IsDir() {
+			queue.push(scanDir{path: pth, links: dir.links})
+			continue
+		}
+		if dirent.IsSymlink() && !w.followSymlinks {
+			continue
+		}
+
+		info, err := os.Stat(pth)
+		if err != nil {
+			w.l.Warn("skipping file", zap.String("path", pth), zap.Error(err))
+			summary.Errors++
+			continue
+		}
+		if info.IsDir() {
+			// a link to a directory
+			if next, ok := w.follow(root, dir, pth); ok {
+				queue.push(next)
+			}
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		if !w.selected(info) {
+			summary.Filtered++
+			continue
+		}
+
+		rel, err := filepath.Rel(root, pth)
+		if err != nil {
+			summary.Errors++
+			continue
+		}
+		files = append(files, File{
+			Name:    filepath.ToSlash(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	return files, summary
+}
+
+// follow a link to a directory, unless it leads back to a directory already walked along this path.
+//
+// This prevents cycles of links from walking forever.
+func (w *walker) follow(root string, dir scanDir, link string) (scanDir, bool) {
+	target, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		return scanDir{}, false
+	}
+	current, err := filepath.EvalSymlinks(dir.path)
+	if err != nil {
+		return scanDir{}, false
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return scanDir{}, false
+	}
+
+	for _, visited := range append([]string{realRoot, current}, dir.links...) {
+		if isWithin(visited, target) {
+			w.l.Warn("skipping link to a parent directory", zap.String("path", link))
+			return scanDir{}, false
+		}
+	}
+
+	links := make([]string, 0, len(dir.links)+1)
+	links = append(links, dir.links...)
+	return scanDir{path: link, links: append(links, target)}, true
+}
+
+func isWithin(dir, parent string) bool {
+	return dir == parent || strings.HasPrefix(dir, parent+string(filepath.Separator))
+}
+
+func (w *walker) selected(info os.FileInfo) bool {
+	for _, selected := range w.filters {
+		if !selected(info) {
+			return false
+		}
+	}
+	return true
+}
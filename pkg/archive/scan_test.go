@@ -0,0 +1,57 @@
+package archive
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScan(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-scan-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	old := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	for i, file := range []string{"b", "a/2", "a/1", "a/sub/deep/file", "c/old"} {
+		path := filepath.Join(tmp, filepath.FromSlash(file))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, bytes.Repeat([]byte("x"), i), 0600))
+		require.NoError(t, os.Chtimes(path, old, old))
+	}
+	require.NoError(t, os.Symlink(filepath.Join(tmp, "c"), filepath.Join(tmp, "d")))
+	require.NoError(t, os.Symlink(tmp, filepath.Join(tmp, "c", "loop")))
+
+	files, summary, err := Scan(tmp, WithParallelism(3))
+	require.NoError(t, err)
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, []string{"a/1", "a/2", "a/sub/deep/file", "b", "c/old", "d/old"}, names,
+		"links to parent directories are not followed")
+	assert.Equal(t, Summary{Listed: len(files)}, summary)
+	assert.Equal(t, int64(2), files[0].Size)
+	assert.True(t, old.Equal(files[0].ModTime))
+
+	files, _, err = Scan(tmp, WithFollowSymlinks(false), WithFilters(ModifiedAfter(old.Add(-time.Hour))))
+	require.NoError(t, err)
+	assert.Len(t, files, 5)
+
+	var sequential, concurrent bytes.Buffer
+	_, err = GenerateFileList(tmp, &sequential, WithFollowSymlinks(false))
+	require.NoError(t, err)
+	_, err = GenerateFileList(tmp, &concurrent, WithFollowSymlinks(false), WithParallelism(4))
+	require.NoError(t, err)
+	assert.Equal(t, sequential.String(), concurrent.String())
+
+	_, _, err = Scan(filepath.Join(tmp, "b"))
+	require.Error(t, err)
+}
@@ -0,0 +1,124 @@
+package aws
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/oneconcern/datamon/pkg/auth/status"
+	"github.com/oneconcern/datamon/pkg/model"
+)
+
+const timeout = 60 * time.Second
+
+// Option configures the AWS provider
+type Option func(*Auth)
+
+// AWSConfig sets the AWS client configuration
+func AWSConfig(cfg *aws.Config) Option {
+	return func(a *Auth) {
+		a.awsConfig = cfg
+	}
+}
+
+// Region sets the AWS region used to reach STS
+func Region(region string) Option {
+	return func(a *Auth) {
+		if region != "" {
+			a.awsConfig = a.awsConfig.Copy().WithRegion(region)
+		}
+	}
+}
+
+// EmailDomain sets the domain used to build the email of principals which name is not an email
+func EmailDomain(domain string) Option {
+	return func(a *Auth) {
+		a.domain = strings.TrimPrefix(domain, "@")
+	}
+}
+
+// New returns a new instance of AWS Auth
+func New(opts ...Option) Auth {
+	a := Auth{
+		awsConfig: aws.NewConfig(),
+	}
+	for _, apply := range opts {
+		apply(&a)
+	}
+	return a
+}
+
+// Auth implements Authable for AWS credentials
+type Auth struct {
+	awsConfig *aws.Config
+	domain    string
+}
+
+// Principal queries AWS STS for the identity of the caller.
+//
+// By default, credentials are resolved by the AWS SDK default chain (environment, shared
+// credentials, instance or web identity roles). When credFile is specified, credentials
+// are taken from this shared credentials file, with the default profile.
+func (a Auth) Principal(credFile string) (model.Contributor, error) {
+	cfg := a.awsConfig.Copy()
+	if credFile != "" {
+		cfg = cfg.WithCredentials(credentials.NewSharedCredentials(credFile, ""))
+	}
+
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return model.Contributor{}, status.ErrAuthService.Wrap(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	identity, err := sts.New(sess).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return model.Contributor{}, status.ErrUserinfo.Wrap(err)
+	}
+
+	return a.contributor(aws.StringValue(identity.Arn))
+}
+
+func (a Auth) contributor(arn string) (model.Contributor, error) {
+	name := principalName(arn)
+	if name == "" {
+		return model.Contributor{}, status.ErrUserinfo.WrapMessage("unexpected caller ARN: %q", arn)
+	}
+
+	var email string
+	switch {
+	case strings.Contains(name, "@"):
+		email = name
+	case a.domain != "":
+		email = name + "@" + a.domain
+	}
+
+	return model.Contributor{
+		Email: email,
+		Name:  name,
+	}, nil
+}
+
+// principalName extracts the name of the principal from a caller ARN.
+//
+// Examples:
+//
+//	arn:aws:iam::123456789012:user/alice => alice
+//	arn:aws:sts::123456789012:assumed-role/developer/alice@example.com => alice@example.com
+func principalName(arn string) string {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+	resource := parts[5]
+	if resource == "root" {
+		return "root"
+	}
+	return resource[strings.LastIndex(resource, "/")+1:]
+}
@@ -0,0 +1,30 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContributor(t *testing.T) {
+	for _, toPin := range []struct {
+		arn, domain, name, email string
+	}{
+		{arn: "arn:aws:iam::123456789012:user/alice", name: "alice"},
+		{arn: "arn:aws:iam::123456789012:user/division/alice", domain: "example.com", name: "alice", email: "alice@example.com"},
+		{arn: "arn:aws:sts::123456789012:assumed-role/AWSReservedSSO_dev/bob@example.com", domain: "other.com", name: "bob@example.com", email: "bob@example.com"},
+		{arn: "arn:aws:iam::123456789012:root", name: "root"},
+	} {
+		fixture := toPin
+		t.Run(fixture.arn, func(t *testing.T) {
+			p, err := New(EmailDomain(fixture.domain)).contributor(fixture.arn)
+			require.NoError(t, err)
+			assert.Equal(t, fixture.name, p.Name)
+			assert.Equal(t, fixture.email, p.Email)
+		})
+	}
+
+	_, err := New().contributor("invalid")
+	assert.Error(t, err)
+}
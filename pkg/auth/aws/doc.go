@@ -0,0 +1,6 @@
+// Package aws supports authentication with the AWS STS caller identity.
+//
+// The principal name is extracted from the caller ARN. STS does not know about emails:
+// the contributor email is taken from the principal name whenever it looks like an email
+// (e.g. SSO session names), or built from a configured domain.
+package aws
@@ -0,0 +1,144 @@
+package oidc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/auth/status"
+	"github.com/oneconcern/datamon/pkg/model"
+)
+
+// DefaultTokenEnv is the default environment variable holding an ID token
+const DefaultTokenEnv = "DATAMON_OIDC_TOKEN"
+
+// Option configures the OIDC provider
+type Option func(*Auth)
+
+// TokenFile sets a file to read the ID token from
+func TokenFile(pth string) Option {
+	return func(a *Auth) {
+		a.tokenFile = pth
+	}
+}
+
+// TokenEnv sets the environment variable holding the ID token
+func TokenEnv(env string) Option {
+	return func(a *Auth) {
+		if env != "" {
+			a.tokenEnv = env
+		}
+	}
+}
+
+// New returns a new instance of OIDC Auth
+func New(opts ...Option) Auth {
+	a := Auth{
+		tokenEnv: DefaultTokenEnv,
+	}
+	for _, apply := range opts {
+		apply(&a)
+	}
+	return a
+}
+
+// Auth implements Authable for OIDC ID tokens
+type Auth struct {
+	tokenFile string
+	tokenEnv  string
+}
+
+type claims struct {
+	Email             string `json:"email"`
+	Name              string `json:"name"`
+	GivenName         string `json:"given_name"`
+	FamilyName        string `json:"family_name"`
+	PreferredUsername string `json:"preferred_username"`
+	Expiry            int64  `json:"exp"`
+}
+
+// Principal extracts user information from an ID token.
+//
+// The token is read from credFile when specified, then from the configured token file,
+// then from the configured environment variable.
+func (a Auth) Principal(credFile string) (model.Contributor, error) {
+	token, err := a.token(credFile)
+	if err != nil {
+		return model.Contributor{}, err
+	}
+
+	c, err := decodeClaims(token)
+	if err != nil {
+		return model.Contributor{}, err
+	}
+
+	if c.Expiry > 0 && time.Unix(c.Expiry, 0).Before(time.Now()) {
+		return model.Contributor{}, status.ErrInvalidCredentials.WrapMessage("ID token has expired")
+	}
+
+	if c.Email == "" {
+		return model.Contributor{}, status.ErrEmailScope
+	}
+
+	fullName := func() string {
+		switch {
+		case c.Name != "":
+			return c.Name
+		case c.GivenName != "" || c.FamilyName != "":
+			return strings.TrimSpace(c.GivenName + " " + c.FamilyName)
+		case c.PreferredUsername != "":
+			return c.PreferredUsername
+		default:
+			// fall back on email if no nominative attributes are set
+			return c.Email
+		}
+	}
+
+	return model.Contributor{
+		Email: c.Email,
+		Name:  fullName(),
+	}, nil
+}
+
+func (a Auth) token(credFile string) (string, error) {
+	pth := credFile
+	if pth == "" {
+		pth = a.tokenFile
+	}
+
+	if pth != "" {
+		b, err := ioutil.ReadFile(pth)
+		if err != nil {
+			return "", status.ErrInvalidCredentials.Wrap(err)
+		}
+		return strings.TrimSpace(string(b)), nil
+	}
+
+	token, ok := os.LookupEnv(a.tokenEnv)
+	if !ok || token == "" {
+		return "", status.ErrInvalidCredentials.WrapMessage("no ID token found in file or in environment variable %s", a.tokenEnv)
+	}
+	return strings.TrimSpace(token), nil
+}
+
+func decodeClaims(token string) (claims, error) {
+	var c claims
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return c, status.ErrInvalidCredentials.WrapMessage("ID token is not a JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return c, status.ErrInvalidCredentials.Wrap(err)
+	}
+
+	if err = json.Unmarshal(payload, &c); err != nil {
+		return c, status.ErrInvalidCredentials.Wrap(err)
+	}
+	return c, nil
+}
@@ -0,0 +1,85 @@
+package oidc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/auth/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testToken(t testing.TB, claims map[string]interface{}) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	return header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".signature"
+}
+
+func TestPrincipal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "oidc")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	t.Run("should read token from file", func(t *testing.T) {
+		tokenFile := filepath.Join(dir, "token")
+		require.NoError(t, ioutil.WriteFile(tokenFile, []byte(testToken(t, map[string]interface{}{
+			"email":       "jane@example.com",
+			"given_name":  "Jane",
+			"family_name": "Doe",
+			"exp":         time.Now().Add(time.Hour).Unix(),
+		})+"\n"), 0600))
+
+		p, err := New(TokenFile(tokenFile)).Principal("")
+		require.NoError(t, err)
+		assert.Equal(t, "jane@example.com", p.Email)
+		assert.Equal(t, "Jane Doe", p.Name)
+	})
+
+	t.Run("should read token from environment", func(t *testing.T) {
+		const env = "DATAMON_TEST_OIDC_TOKEN"
+		require.NoError(t, os.Setenv(env, testToken(t, map[string]interface{}{
+			"email": "john@example.com",
+		})))
+		defer func() { _ = os.Unsetenv(env) }()
+
+		p, err := New(TokenEnv(env)).Principal("")
+		require.NoError(t, err)
+		assert.Equal(t, "john@example.com", p.Email)
+		assert.Equal(t, "john@example.com", p.Name)
+	})
+
+	t.Run("should reject expired token", func(t *testing.T) {
+		tokenFile := filepath.Join(dir, "expired")
+		require.NoError(t, ioutil.WriteFile(tokenFile, []byte(testToken(t, map[string]interface{}{
+			"email": "jane@example.com",
+			"exp":   time.Now().Add(-time.Hour).Unix(),
+		})), 0600))
+
+		_, err := New().Principal(tokenFile)
+		assert.True(t, status.ErrInvalidCredentials.Is(err))
+	})
+
+	t.Run("should require email claim", func(t *testing.T) {
+		tokenFile := filepath.Join(dir, "noemail")
+		require.NoError(t, ioutil.WriteFile(tokenFile, []byte(testToken(t, map[string]interface{}{
+			"sub": "system:serviceaccount:default:datamon",
+		})), 0600))
+
+		_, err := New().Principal(tokenFile)
+		assert.Equal(t, status.ErrEmailScope, err)
+	})
+
+	t.Run("should reject malformed token", func(t *testing.T) {
+		_, err := New(TokenEnv("DATAMON_TEST_OIDC_NO_TOKEN")).Principal("")
+		assert.Error(t, err)
+
+		_, err = decodeClaims("not-a-jwt")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,8 @@
+// Package oidc supports authentication with an OpenID Connect ID token.
+//
+// The token is read from a file (e.g. a projected service account token) or from the environment.
+// Its claims are decoded to identify data contributors: the email claim is required.
+//
+// The signature of the token is not verified: the identity is merely used to sign contributions.
+// Access to data is authorized by the storage backends.
+package oidc
@@ -0,0 +1,127 @@
+package static
+
+import (
+	"io/ioutil"
+	"net/mail"
+	"os/user"
+	"strings"
+
+	"github.com/oneconcern/datamon/pkg/auth/status"
+	"github.com/oneconcern/datamon/pkg/model"
+	"gopkg.in/yaml.v2"
+)
+
+// Option configures the static provider
+type Option func(*Auth)
+
+// Email sets the email of the contributor
+func Email(email string) Option {
+	return func(a *Auth) {
+		a.email = email
+	}
+}
+
+// Name sets the name of the contributor
+func Name(name string) Option {
+	return func(a *Auth) {
+		a.name = name
+	}
+}
+
+// Keyring sets the keyring service holding the identity of the contributor
+func Keyring(service string) Option {
+	return func(a *Auth) {
+		a.keyring = service
+	}
+}
+
+// New returns a new instance of static Auth
+func New(opts ...Option) Auth {
+	var a Auth
+	for _, apply := range opts {
+		apply(&a)
+	}
+	return a
+}
+
+// Auth implements Authable for a static identity
+type Auth struct {
+	email   string
+	name    string
+	keyring string
+}
+
+// Principal yields a static identity.
+//
+// The identity is read from credFile when specified, as a YAML document with name and email.
+// Otherwise, configured values are used. When no email is configured, the identity is
+// looked up in the configured keyring service.
+func (a Auth) Principal(credFile string) (model.Contributor, error) {
+	var (
+		contributor model.Contributor
+		err         error
+	)
+
+	switch {
+	case credFile != "":
+		contributor, err = fromFile(credFile)
+	case a.email != "":
+		contributor = model.Contributor{Email: a.email, Name: a.name}
+	case a.keyring != "":
+		contributor, err = fromKeyring(a.keyring)
+	default:
+		err = status.ErrInvalidCredentials.WrapMessage("no static identity configured")
+	}
+	if err != nil {
+		return model.Contributor{}, err
+	}
+
+	if contributor.Email == "" {
+		return model.Contributor{}, status.ErrEmailScope
+	}
+	if contributor.Name == "" {
+		// fall back on email if no name is set
+		contributor.Name = contributor.Email
+	}
+	return contributor, nil
+}
+
+func fromFile(pth string) (model.Contributor, error) {
+	var contributor model.Contributor
+
+	b, err := ioutil.ReadFile(pth)
+	if err != nil {
+		return contributor, status.ErrInvalidCredentials.Wrap(err)
+	}
+
+	if err = yaml.Unmarshal(b, &contributor); err != nil {
+		return contributor, status.ErrInvalidCredentials.Wrap(err)
+	}
+	return contributor, nil
+}
+
+func fromKeyring(service string) (model.Contributor, error) {
+	u, err := user.Current()
+	if err != nil {
+		return model.Contributor{}, status.ErrUserinfo.Wrap(err)
+	}
+
+	secret, err := keyringGet(service, u.Username)
+	if err != nil {
+		return model.Contributor{}, status.ErrInvalidCredentials.Wrap(err)
+	}
+
+	return parseIdentity(secret)
+}
+
+// parseIdentity parses an identity like "Full Name <email>" or a bare email
+func parseIdentity(identity string) (model.Contributor, error) {
+	address, err := mail.ParseAddress(strings.TrimSpace(identity))
+	if err != nil {
+		return model.Contributor{}, status.ErrInvalidCredentials.Wrap(err)
+	}
+	return model.Contributor{
+		Email: address.Address,
+		Name:  address.Name,
+	}, nil
+}
@@ -0,0 +1,48 @@
+package static
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oneconcern/datamon/pkg/auth/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrincipal(t *testing.T) {
+	p, err := New(Email("jane@example.com"), Name("Jane Doe")).Principal("")
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", p.Email)
+	assert.Equal(t, "Jane Doe", p.Name)
+
+	dir, err := ioutil.TempDir("", "static")
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	credFile := filepath.Join(dir, "identity.yaml")
+	require.NoError(t, ioutil.WriteFile(credFile, []byte("email: john@example.com\n"), 0600))
+	p, err = New(Email("jane@example.com")).Principal(credFile)
+	require.NoError(t, err)
+	assert.Equal(t, "john@example.com", p.Email)
+	assert.Equal(t, "john@example.com", p.Name)
+
+	_, err = New(Name("Jane Doe")).Principal("")
+	assert.True(t, status.ErrInvalidCredentials.Is(err))
+}
+
+func TestParseIdentity(t *testing.T) {
+	p, err := parseIdentity(" Jane Doe <jane@example.com>\n")
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", p.Email)
+	assert.Equal(t, "Jane Doe", p.Name)
+
+	p, err = parseIdentity("john@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "john@example.com", p.Email)
+	assert.Empty(t, p.Name)
+
+	_, err = parseIdentity("not an identity")
+	assert.Error(t, err)
+}
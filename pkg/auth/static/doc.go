@@ -0,0 +1,7 @@
+// Package static supports a static identity for data contributors, when no identity provider is available.
+//
+// The identity is taken from configured values, from a YAML credential file, or from the
+// system keyring (macOS keychain or linux secret service).
+//
+// Keyring entries are looked up by service and OS user, and hold an identity like "Full Name <email>".
+package static
@@ -0,0 +1,15 @@
+package static
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// keyringGet looks up a secret from the macOS keychain
+func keyringGet(service, account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w").Output() //#nosec
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
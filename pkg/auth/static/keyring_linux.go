@@ -0,0 +1,15 @@
+package static
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// keyringGet looks up a secret from the secret service, using secret-tool
+func keyringGet(service, account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", service, "username", account).Output() //#nosec
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package static
+
+import "errors"
+
+func keyringGet(_, _ string) (string, error) {
+	return "", errors.New("keyring is not supported on this platform")
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/oneconcern/datamon/pkg/metrics"
 	"github.com/oneconcern/datamon/pkg/storage"
 	"github.com/oneconcern/datamon/pkg/storage/localfs"
+	"github.com/oneconcern/datamon/pkg/tracing"
 	"go.uber.org/zap"
 )
 
@@ -79,6 +80,7 @@ func defaultsForFs() *defaultFs {
 		withVerifyBlobHash:          false, // verify all written blobs
 		withPrefetch:                0,     // prefetching disabled by default
 		withRetry:                   true,  // retry on Put operations enabled by default
+		maxPrefetches:               DefaultMaxPrefetches,
 	}
 }
 
@@ -115,6 +117,17 @@ func New(opts ...Option) (Fs, error) {
 
 	f.pather = func(lks Key) string { return lks.StringWithPrefix(f.prefix) }
 
+	if f.readAheadWindow > 0 {
+		f.readahead, err = newReadahead(f.readAheadWindow, f.maxPrefetches, f.l)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if f.leafCache != nil && f.store.backend != nil {
+		f.store.backend = newLeafCachedStore(f.store.backend, f.leafCache)
+	}
+
 	if f.MetricsEnabled() {
 		f.m = f.EnsureMetrics("cafs", &M{}).(*M)
 		f.m.Volume.Cache.Sizing(cacheBuffers, cacheBuffers+buffersForparallelReaders, f.leafSize)
@@ -142,6 +155,12 @@ type defaultFs struct {
 	leafPool FreeList
 	lruSize  int
 
+	// adaptive prefetching shared by all readers
+	readahead *readahead
+
+	// persistent cache on local disk
+	leafCache *LeafCache
+
 	// root key cache of resolved leaf keys
 	keysCache     *lru.Cache // this holds leaf keys in cache to avoid resolving root keys again
 	keysCacheSize int
@@ -152,6 +171,8 @@ type defaultFs struct {
 	readerConcurrentChunkWrites int
 	deduplicationScheme         string
 	withPrefetch                int
+	readAheadWindow             int
+	maxPrefetches               int
 	withVerifyHash              bool
 	withVerifyBlobHash          bool
 	withRetry                   bool
@@ -171,6 +192,11 @@ func (d *defaultFs) Put(ctx context.Context, src io.Reader) (PutRes, error) {
 		empty   PutRes
 	)
 
+	ctx, span := tracing.Start(ctx, "cafs.Put")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	d.l.Debug("Start cafs Put")
 	defer func(t0 time.Time) {
 		if d.MetricsEnabled() {
@@ -180,7 +206,7 @@ func (d *defaultFs) Put(ctx context.Context, src io.Reader) (PutRes, error) {
 		d.l.Debug("End cafs Put")
 	}(time.Now())
 
-	w := d.writer()
+	w := d.writer(ctx)
 
 	// write leaf blobs
 	written, err = io.Copy(w, src)
@@ -290,7 +316,7 @@ func (d *defaultFs) Get(ctx context.Context, hash Key) (io.ReadCloser, error) {
 		d.l.Debug("End cafs Get")
 	}(time.Now())
 
-	r, err := d.reader(hash)
+	r, err := d.reader(ctx, hash)
 	return r, err
 }
 
@@ -305,11 +331,11 @@ func (d *defaultFs) GetAt(ctx context.Context, hash Key) (io.ReaderAt, error) {
 		d.l.Debug("End cafs GetAt")
 	}(time.Now())
 
-	r, err := d.reader(hash)
+	r, err := d.reader(ctx, hash)
 	return r, err
 }
 
-func (d *defaultFs) reader(hash Key) (Reader, error) {
+func (d *defaultFs) reader(ctx context.Context, hash Key) (Reader, error) {
 	var (
 		keys []Key
 		err  error
@@ -329,6 +355,12 @@ func (d *defaultFs) reader(hash Key) (Reader, error) {
 		_, _ = d.keysCache.ContainsOrAdd(hash, keys)
 	}
 
+	prefetch := d.withPrefetch
+	if d.readahead != nil {
+		// adaptive readahead supersedes fixed prefetching
+		prefetch = 0
+	}
+
 	d.l.Debug("cafs building reader", zap.Bool("verify_hash", d.withVerifyHash))
 	rdr, err := newReader(d.store.backend, hash, d.leafSize,
 		Keys(keys),
@@ -339,8 +371,10 @@ func (d *defaultFs) reader(hash Key) (Reader, error) {
 		SetLeafPool(d.leafPool),
 		ReaderPrefix(d.prefix),
 		ReaderLogger(d.l),
-		ReaderPrefetch(d.withPrefetch),
+		ReaderPrefetch(prefetch),
+		readerReadahead(d.readahead),
 		ReaderWithMetrics(d.MetricsEnabled()),
+		readerContext(ctx),
 	)
 	if err != nil {
 		return nil, err
@@ -349,7 +383,7 @@ func (d *defaultFs) reader(hash Key) (Reader, error) {
 	return rdr, nil
 }
 
-func (d *defaultFs) writer() Writer {
+func (d *defaultFs) writer(ctx context.Context) Writer {
 	return newWriter(d.store.backend, d.leafSize,
 		WriterPrefix(d.prefix),
 		WriterConcurrentFlushes(d.concurrentFlushes),
@@ -357,6 +391,7 @@ func (d *defaultFs) writer() Writer {
 		WriterPather(d.pather),
 		WriterWithMetrics(d.MetricsEnabled()),
 		WriterWithVerifyHash(d.withVerifyBlobHash),
+		writerContext(ctx),
 	)
 }
 
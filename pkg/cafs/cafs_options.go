@@ -92,6 +92,24 @@ func Prefetch(ahead int) Option {
 	}
 }
 
+// ReadAhead enables adaptive prefetching on read operations, fetching up to maxWindow leaves ahead of sequential reads.
+//
+// The prefetch window grows as reads are detected sequential, and shrinks on random access.
+// This supersedes the fixed Prefetch option. This is disabled by default.
+func ReadAhead(maxWindow int) Option {
+	return func(w *defaultFs) {
+		w.readAheadWindow = maxWindow
+	}
+}
+
+// MaxPrefetches caps the number of leaves being prefetched at the same time when ReadAhead is enabled.
+// Defaults to DefaultMaxPrefetches.
+func MaxPrefetches(n int) Option {
+	return func(w *defaultFs) {
+		w.maxPrefetches = n
+	}
+}
+
 // VerifyHash enables hash verification on read blob objects and written root keys.
 // This is enabled by default.
 func VerifyHash(enabled bool) Option {
@@ -131,3 +149,12 @@ func VerifyBlobHash(enabled bool) Option {
 		w.withVerifyBlobHash = enabled
 	}
 }
+
+// DiskCache sets a persistent leaf cache on local disk, which may be shared with other cafs instances and processes.
+//
+// Blobs retrieved from the backend store are kept in this cache and verified upon read.
+func DiskCache(cache *LeafCache) Option {
+	return func(w *defaultFs) {
+		w.leafCache = cache
+	}
+}
@@ -4,12 +4,17 @@ import (
 	"context"
 	"fmt"
 	"hash/crc32"
+	"io/ioutil"
 
 	"github.com/oneconcern/datamon/pkg/errors"
 	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/oneconcern/datamon/pkg/storage/status"
 	"go.uber.org/zap"
 )
 
+// ErrCorruptedLeaf indicates that the content of a leaf blob doesn't match its key
+var ErrCorruptedLeaf = errors.New("leaf content doesn't match its key")
+
 // existsAndValidBlob verifies a blob chunk against its expected size and CRC32C hash, for stores that support CRC.
 func existsAndValidBlob(ctx context.Context, store storage.Store, pth string, data []byte, lg *zap.Logger) (found bool, overwrite bool) {
 	attr, err := store.GetAttr(ctx, pth)
@@ -90,3 +95,52 @@ func verifyBlob(ctx context.Context, store storage.Store, pth string, data []byt
 
 	return nil
 }
+
+// CheckLeaf verifies that the leaf at position index among the leaves of a root key exists in store.
+//
+// With verifyHash, the content of the leaf is retrieved and checked against its key.
+// It returns status.ErrNotExists whenever the leaf is missing, and ErrCorruptedLeaf whenever its content doesn't match.
+func CheckLeaf(ctx context.Context, store storage.Store, leaves []Key, index int, leafSize uint32, prefix string, verifyHash bool) error {
+	pth := leaves[index].StringWithPrefix(prefix)
+	if !verifyHash {
+		found, err := store.Has(ctx, pth)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return status.ErrNotExists
+		}
+		return nil
+	}
+
+	rdr, err := store.Get(ctx, pth)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rdr.Close()
+	}()
+
+	data, err := ioutil.ReadAll(rdr)
+	if err != nil {
+		return err
+	}
+	return VerifyLeaf(leaves, index, data, leafSize)
+}
+
+// VerifyLeaf checks the content of the leaf at position index among the leaves of a root key against its key.
+func VerifyLeaf(leaves []Key, index int, data []byte, leafSize uint32) error {
+	// NOTE: we follow the checksumming scheme adopted by the writer (see chunkReader.verifyHash)
+	n, isLast := index+1, false
+	if index+1 == len(leaves) && uint32(len(data)) != leafSize {
+		n, isLast = index, true
+	}
+	key, err := KeyFromBytes(data, leafSize, uint64(n), isLast)
+	if err != nil {
+		return err
+	}
+	if key != leaves[index] {
+		return ErrCorruptedLeaf
+	}
+	return nil
+}
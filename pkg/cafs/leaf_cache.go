@@ -0,0 +1,340 @@
+package cafs
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	blake2b "github.com/minio/blake2b-simd"
+	"github.com/oneconcern/datamon/pkg/dlogger"
+	"github.com/oneconcern/datamon/pkg/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultLeafCacheSize is the default bound on the size of a persistent leaf cache (10 GB)
+	DefaultLeafCacheSize int64 = 10 * 1024 * 1024 * 1024
+
+	leafCacheTempPrefix = ".tmp-"
+	leafCacheTempMaxAge = time.Hour // leftovers from crashed writers are removed after this delay
+	leafCacheLowWater   = 90        // eviction frees space down to this percentage of the size bound
+	leafCacheSumSize    = 32        // size of the checksum appended to cached files
+)
+
+// LeafCache is a persistent, size-bounded cache of blobs on local disk.
+//
+// Blobs are content-addressed, hence immutable: cached files are never updated.
+// The cache may be shared by several processes running on the same host (e.g. several mounts and downloads):
+//   - files are written to a temporary location then atomically renamed
+//   - every cached file carries a checksum of its content, verified on read.
+//     Corrupted or truncated files are evicted and the blob is fetched again from the backend store.
+//   - least recently used files are evicted whenever the cache exceeds its size bound,
+//     based on file modification times which are refreshed on every hit.
+type LeafCache struct {
+	dir     string
+	maxSize int64
+	size    int64 // approximate size in bytes of the cache, since other processes may also write into it (atomic)
+	l       *zap.Logger
+
+	evictLatch sync.Mutex
+}
+
+// LeafCacheOption sets options on a persistent leaf cache
+type LeafCacheOption func(*LeafCache)
+
+// LeafCacheSize sets the size bound of the cache in bytes
+func LeafCacheSize(size int64) LeafCacheOption {
+	return func(c *LeafCache) {
+		if size > 0 {
+			c.maxSize = size
+		}
+	}
+}
+
+// LeafCacheLogger sets a logger for this cache
+func LeafCacheLogger(l *zap.Logger) LeafCacheOption {
+	return func(c *LeafCache) {
+		if l != nil {
+			c.l = l
+		}
+	}
+}
+
+// NewLeafCache opens a persistent leaf cache located in some directory, which is created if needed
+func NewLeafCache(dir string, opts ...LeafCacheOption) (*LeafCache, error) {
+	c := &LeafCache{
+		dir:     dir,
+		maxSize: DefaultLeafCacheSize,
+		l:       dlogger.MustGetLogger("info"),
+	}
+	for _, apply := range opts {
+		apply(c)
+	}
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	size, err := c.scan(func(string, os.FileInfo) {})
+	if err != nil {
+		return nil, err
+	}
+	c.size = size
+	c.l.Info("opened persistent leaf cache", zap.String("dir", c.dir), zap.Int64("size", size), zap.Int64("max size", c.maxSize))
+
+	return c, nil
+}
+
+// String representation of this cache
+func (c *LeafCache) String() string {
+	return "leaf cache@" + c.dir
+}
+
+// Size yields the approximate current size in bytes of this cache
+func (c *LeafCache) Size() int64 {
+	return atomic.LoadInt64(&c.size)
+}
+
+// Get retrieves a blob from the cache. It returns false if the blob is not cached or fails verification.
+func (c *LeafCache) Get(key string) ([]byte, bool) {
+	pth := c.pathFor(key)
+	content, err := ioutil.ReadFile(pth)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			c.l.Warn("leaf cache read failed", zap.String("key", key), zap.Error(err))
+		}
+		return nil, false
+	}
+
+	data, ok := unsealLeaf(content)
+	if !ok {
+		c.l.Warn("leaf cache verification failed: evicting corrupted entry", zap.String("key", key))
+		c.remove(pth, int64(len(content)))
+		return nil, false
+	}
+
+	// refresh the modification time, which drives evictions
+	now := time.Now()
+	_ = os.Chtimes(pth, now, now)
+
+	return data, true
+}
+
+// Put stores a blob in the cache, then evicts older entries if the cache exceeds its size bound
+func (c *LeafCache) Put(key string, data []byte) error {
+	pth := c.pathFor(key)
+	if _, err := os.Stat(pth); err == nil {
+		// already cached, possibly by another process
+		return nil
+	}
+
+	dir := filepath.Dir(pth)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, leafCacheTempPrefix)
+	if err != nil {
+		return err
+	}
+	content := sealLeaf(data)
+	_, err = tmp.Write(content)
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), pth)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if atomic.AddInt64(&c.size, int64(len(content))) > c.maxSize {
+		c.evict()
+	}
+	return nil
+}
+
+// pathFor yields the location of a cached blob.
+//
+// Keys are hashed to avoid issues with prefixed keys, then sharded in subdirectories.
+func (c *LeafCache) pathFor(key string) string {
+	sum := blake2b.Sum256([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, name[:2], name)
+}
+
+func (c *LeafCache) remove(pth string, size int64) {
+	if err := os.Remove(pth); err == nil {
+		atomic.AddInt64(&c.size, -size)
+	}
+}
+
+type leafCacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// evict removes least recently used entries, until the cache size gets below the low water mark
+func (c *LeafCache) evict() {
+	c.evictLatch.Lock()
+	defer c.evictLatch.Unlock()
+
+	if atomic.LoadInt64(&c.size) <= c.maxSize {
+		// another goroutine already did the job
+		return
+	}
+
+	files := make([]leafCacheFile, 0, 1024)
+	size, err := c.scan(func(pth string, info os.FileInfo) {
+		files = append(files, leafCacheFile{path: pth, size: info.Size(), modTime: info.ModTime()})
+	})
+	if err != nil {
+		c.l.Warn("leaf cache eviction failed", zap.Error(err))
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	target := c.maxSize / 100 * leafCacheLowWater
+	evicted := 0
+	for _, file := range files {
+		if size <= target {
+			break
+		}
+		// files concurrently removed by another process are just skipped
+		if e := os.Remove(file.path); e == nil || os.IsNotExist(e) {
+			size -= file.size
+			evicted++
+		}
+	}
+	atomic.StoreInt64(&c.size, size)
+	c.l.Debug("leaf cache eviction", zap.Int("evicted", evicted), zap.Int64("size", size))
+}
+
+// scan walks the cache, calling a function for every cached file, and returns the total size of the cache.
+//
+// Temporary files left over by interrupted writers are removed.
+func (c *LeafCache) scan(fn func(string, os.FileInfo)) (int64, error) {
+	var size int64
+	err := filepath.Walk(c.dir, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// concurrently removed
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), leafCacheTempPrefix) {
+			if time.Since(info.ModTime()) > leafCacheTempMaxAge {
+				_ = os.Remove(pth)
+			}
+			return nil
+		}
+		size += info.Size()
+		fn(pth, info)
+		return nil
+	})
+	return size, err
+}
+
+// sealLeaf appends a checksum to some content
+func sealLeaf(data []byte) []byte {
+	sum := blake2b.Sum256(data)
+	content := make([]byte, 0, len(data)+len(sum))
+	content = append(content, data...)
+	return append(content, sum[:]...)
+}
+
+// unsealLeaf verifies the checksum of some content and returns the original data
+func unsealLeaf(content []byte) ([]byte, bool) {
+	if len(content) < leafCacheSumSize {
+		return nil, false
+	}
+	data := content[:len(content)-leafCacheSumSize]
+	sum := blake2b.Sum256(data)
+	if !bytes.Equal(sum[:], content[len(data):]) {
+		return nil, false
+	}
+	return data, true
+}
+
+var _ storage.Store = &leafCachedStore{}
+
+// leafCachedStore wraps the backend store of a cafs with a persistent leaf cache.
+//
+// Only objects retrieved with Get are cached: all other operations are carried out by the backend.
+type leafCachedStore struct {
+	storage.Store
+	cache *LeafCache
+}
+
+func newLeafCachedStore(backend storage.Store, cache *LeafCache) storage.Store {
+	return &leafCachedStore{
+		Store: backend,
+		cache: cache,
+	}
+}
+
+func (s *leafCachedStore) String() string {
+	return s.Store.String() + " (" + s.cache.String() + ")"
+}
+
+// Get an object from the cache, or from the backend store
+func (s *leafCachedStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, err := s.get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// GetAt an object from the cache, or from the backend store
+func (s *leafCachedStore) GetAt(ctx context.Context, key string) (io.ReaderAt, error) {
+	data, err := s.get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (s *leafCachedStore) get(ctx context.Context, key string) ([]byte, error) {
+	if data, ok := s.cache.Get(key); ok {
+		return data, nil
+	}
+
+	rdr, err := s.Store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rdr.Close()
+	}()
+
+	data, err := ioutil.ReadAll(rdr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.cache.Put(key, data); err != nil {
+		// a failure to cache is not a failure to read
+		s.cache.l.Warn("leaf cache write failed", zap.String("key", key), zap.Error(err))
+	}
+	return data, nil
+}
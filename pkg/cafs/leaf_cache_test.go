@@ -0,0 +1,133 @@
+package cafs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeafCache(t *testing.T) {
+	td, err := ioutil.TempDir("", "tpt-cafs-leaf-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	cache, err := NewLeafCache(filepath.Join(td, "cache"), LeafCacheSize(1000))
+	require.NoError(t, err)
+
+	t.Run("should get what was put", func(t *testing.T) {
+		_, ok := cache.Get("key-1")
+		require.False(t, ok)
+
+		require.NoError(t, cache.Put("key-1", []byte("leaf content")))
+		data, ok := cache.Get("key-1")
+		require.True(t, ok)
+		assert.Equal(t, "leaf content", string(data))
+		assert.Equal(t, int64(len("leaf content")+leafCacheSumSize), cache.Size())
+
+		// reopening the cache retrieves existing entries
+		reopened, err := NewLeafCache(filepath.Join(td, "cache"))
+		require.NoError(t, err)
+		assert.Equal(t, cache.Size(), reopened.Size())
+		data, ok = reopened.Get("key-1")
+		require.True(t, ok)
+		assert.Equal(t, "leaf content", string(data))
+	})
+
+	t.Run("should evict corrupted entries", func(t *testing.T) {
+		require.NoError(t, cache.Put("key-2", []byte("other leaf content")))
+		pth := cache.pathFor("key-2")
+		content, err := ioutil.ReadFile(pth)
+		require.NoError(t, err)
+		content[0] ^= 0xff
+		require.NoError(t, ioutil.WriteFile(pth, content, 0600))
+
+		_, ok := cache.Get("key-2")
+		require.False(t, ok)
+		assert.NoFileExists(t, pth)
+	})
+
+	t.Run("should evict least recently used entries", func(t *testing.T) {
+		leaf := make([]byte, 200-leafCacheSumSize)
+		old := time.Now().Add(-time.Hour)
+		for _, key := range []string{"a", "b", "c", "d"} {
+			require.NoError(t, cache.Put(key, leaf))
+			require.NoError(t, os.Chtimes(cache.pathFor(key), old, old))
+			old = old.Add(time.Minute)
+		}
+		// "a" is the oldest entry, but has been used recently
+		_, ok := cache.Get("a")
+		require.True(t, ok)
+
+		require.NoError(t, cache.Put("e", leaf))
+		assert.LessOrEqual(t, cache.Size(), int64(900))
+
+		for _, key := range []string{"key-1", "a", "c", "d", "e"} {
+			_, ok := cache.Get(key)
+			assert.Truef(t, ok, "expected %q to remain in cache", key)
+		}
+		for _, key := range []string{"b"} {
+			_, ok := cache.Get(key)
+			assert.Falsef(t, ok, "expected %q to be evicted", key)
+		}
+	})
+}
+
+func TestCAFS_DiskCache(t *testing.T) {
+	td, err := ioutil.TempDir("", "tpt-cafs-disk-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	cache, err := NewLeafCache(filepath.Join(td, "cache"))
+	require.NoError(t, err)
+
+	blobs := localfs.New(afero.NewBasePathFs(afero.NewOsFs(), filepath.Join(destDir, "cafs")))
+	fs, err := New(
+		LeafSize(leafSize),
+		Backend(blobs),
+		DiskCache(cache),
+	)
+	require.NoError(t, err)
+
+	for _, tf := range testFiles(destDir) {
+		rdr, err := fs.Get(context.Background(), keyFromFile(t, tf.RootHash))
+		require.NoError(t, err)
+		assertReaderOriginal(t, tf.Original, rdr)
+	}
+	require.NotZero(t, cache.Size())
+
+	// a cafs with an empty backend is now served by the cache
+	empty := localfs.New(afero.NewBasePathFs(afero.NewOsFs(), filepath.Join(td, "empty")))
+	cached, err := New(
+		LeafSize(leafSize),
+		Backend(empty),
+		DiskCache(cache),
+	)
+	require.NoError(t, err)
+
+	for _, tf := range testFiles(destDir) {
+		rkey := keyFromFile(t, tf.RootHash)
+
+		rdr, err := cached.Get(context.Background(), rkey)
+		require.NoError(t, err)
+		assertReaderOriginal(t, tf.Original, rdr)
+
+		expected := readTextFile(t, tf.Original)
+		rat, err := cached.GetAt(context.Background(), rkey)
+		require.NoError(t, err)
+		actual := make([]byte, len(expected))
+		n, err := rat.ReadAt(actual, 0)
+		if err != nil {
+			require.Equal(t, "EOF", err.Error())
+		}
+		require.Equal(t, len(expected), n)
+		require.Equal(t, expected, actual)
+	}
+}
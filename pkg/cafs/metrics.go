@@ -58,6 +58,9 @@ type cacheUsage struct {
 	WastedLeaves     *stats.Int64Measure `metric:"leavesWasted" description:"number of leaves fetched and wasted because of fast cache recycling" tags:"leafsize,operation"`
 	CacheHits        *stats.Int64Measure `metric:"cacheHits" tags:"leafsize,operation"`
 	CacheMisses      *stats.Int64Measure `metric:"cacheMisses" tags:"leafsize,operation"`
+	ReadaheadWindow  *stats.Int64Measure `metric:"readaheadWindow" description:"number of leaves fetched ahead of a read, by access pattern" tags:"leafsize,operation"`
+	PrefetchedLeaves *stats.Int64Measure `metric:"leavesPrefetched" description:"number of leaves fetched ahead of reads" tags:"leafsize,operation"`
+	DroppedLeaves    *stats.Int64Measure `metric:"prefetchesDropped" description:"number of scheduled prefetches dropped because too many were pending" tags:"leafsize,operation"`
 }
 
 func (u *cacheUsage) tags(leafsize uint32, operation string) map[string]string {
@@ -83,3 +86,14 @@ func (u *cacheUsage) Capture(bytesToRead, readBytes int, requested, fetched, was
 	metrics.Int64(u.CacheHits, int64(cacheHits), tags)
 	metrics.Int64(u.CacheMisses, int64(cacheMisses), tags)
 }
+
+func (u *cacheUsage) Readahead(window int, sequential bool, prefetched, dropped uint64, leafsize uint32) {
+	pattern := "random"
+	if sequential {
+		pattern = "sequential"
+	}
+	metrics.Int64(u.ReadaheadWindow, int64(window), u.tags(leafsize, pattern))
+	tags := u.tags(leafsize, "readahead")
+	metrics.Int64(u.PrefetchedLeaves, int64(prefetched), tags)
+	metrics.Int64(u.DroppedLeaves, int64(dropped), tags)
+}
@@ -0,0 +1,288 @@
+package cafs
+
+import (
+	"sync"
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultMaxPrefetches is the default maximum number of leaves being prefetched at the same time by an Fs
+	DefaultMaxPrefetches = 16
+
+	maxStreamsPerFile = 4    // number of concurrent read streams tracked on the same file
+	maxTrackedFiles   = 1024 // number of files for which read streams are tracked
+	queuedPerPrefetch = 4    // number of pending prefetches allowed for each running one
+)
+
+// readahead schedules leaf prefetches on behalf of all the readers of a Fs, adapting to access patterns.
+//
+// Readers are short-lived (e.g. fuse mounts build a new reader for every read operation), so read streams
+// are tracked by root hash: each file may carry a few concurrent streams, which approximate the file handles
+// reading it.
+//
+// A read which continues a stream is deemed sequential and doubles the prefetch window of this stream,
+// up to maxWindow leaves. Any other read starts a new stream and shrinks the windows of the other streams
+// on this file.
+//
+// Foreground reads always take precedence: no new prefetch is started while some foreground fetch is in progress.
+// The number of running prefetches is capped by maxPrefetches. Pending prefetches beyond this are queued, and the oldest
+// ones dropped whenever the queue overflows.
+type readahead struct {
+	maxWindow     int
+	maxPrefetches int
+	maxQueued     int
+	l             *zap.Logger
+
+	lock       sync.Mutex
+	streams    *lru.Cache // read streams by root hash
+	queue      []*leafFetch
+	inflight   map[string]*leafFetch // leaves being fetched or queued, by path
+	running    int
+	foreground int
+
+	// metrics
+	prefetched uint64
+	dropped    uint64
+}
+
+type readStream struct {
+	last      int // index of the last leaf read
+	scheduled int // index of the next leaf to schedule
+	window    int // number of leaves to fetch ahead of the last one read
+}
+
+// leafFetch is a leaf fetched either in the background or in the foreground
+type leafFetch struct {
+	r       *chunkReader
+	key     Key
+	index   int
+	path    string
+	started bool
+	err     error
+	done    chan struct{}
+}
+
+func newReadahead(maxWindow, maxPrefetches int, l *zap.Logger) (*readahead, error) {
+	if maxPrefetches < 1 {
+		maxPrefetches = DefaultMaxPrefetches
+	}
+	streams, err := lru.New(maxTrackedFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &readahead{
+		maxWindow:     maxWindow,
+		maxPrefetches: maxPrefetches,
+		maxQueued:     maxPrefetches * queuedPerPrefetch,
+		l:             l,
+		streams:       streams,
+		inflight:      make(map[string]*leafFetch, maxPrefetches),
+	}, nil
+}
+
+// advance records a read on leaves first to last and schedules prefetches ahead of it.
+//
+// It returns the current prefetch window of the stream and whether this read is sequential.
+func (ra *readahead) advance(r *chunkReader, first, last int) (int, bool) {
+	ra.lock.Lock()
+	defer ra.lock.Unlock()
+
+	var streams []*readStream
+	if v, ok := ra.streams.Get(r.hash); ok {
+		streams = v.([]*readStream)
+	}
+
+	var stream *readStream
+	for _, s := range streams {
+		if first == s.last || first == s.last+1 {
+			stream = s
+			break
+		}
+	}
+
+	sequential := stream != nil
+	if sequential {
+		if last > stream.last {
+			// moving on to a new leaf
+			stream.window *= 2
+			if stream.window < 1 {
+				stream.window = 1
+			}
+			if stream.window > ra.maxWindow {
+				stream.window = ra.maxWindow
+			}
+		}
+	} else {
+		// random access: shrink other streams on this file and start a new one
+		for _, s := range streams {
+			s.window /= 2
+		}
+		stream = &readStream{scheduled: last + 1}
+		if first == 0 {
+			// reading from the start of a file is likely to be sequential
+			stream.window = 1
+		}
+		streams = append(streams, stream)
+		if len(streams) > maxStreamsPerFile {
+			streams = streams[1:]
+		}
+		ra.streams.Add(r.hash, streams)
+	}
+	stream.last = last
+
+	if stream.scheduled <= last {
+		stream.scheduled = last + 1
+	}
+	for ; stream.scheduled <= last+stream.window && stream.scheduled < len(r.keys); stream.scheduled++ {
+		ra.schedule(r, stream.scheduled)
+	}
+	ra.dispatch()
+
+	return stream.window, sequential
+}
+
+// schedule queues a leaf for prefetching, unless already available.
+//
+// This must be called under lock.
+func (ra *readahead) schedule(r *chunkReader, index int) {
+	key := r.keys[index]
+	pth := r.pather(key)
+	if _, ok := ra.inflight[pth]; ok || r.lru.Contains(pth) {
+		return
+	}
+
+	f := &leafFetch{r: r, key: key, index: index, path: pth, done: make(chan struct{})}
+	ra.inflight[pth] = f
+	ra.queue = append(ra.queue, f)
+
+	if len(ra.queue) > ra.maxQueued {
+		// too many pending prefetches: drop the oldest one
+		oldest := ra.queue[0]
+		ra.queue = ra.queue[1:]
+		delete(ra.inflight, oldest.path)
+		close(oldest.done)
+		atomic.AddUint64(&ra.dropped, 1)
+	}
+}
+
+// dispatch starts pending prefetches, unless some foreground fetch is in progress.
+//
+// This must be called under lock.
+func (ra *readahead) dispatch() {
+	for ra.foreground == 0 && ra.running < ra.maxPrefetches && len(ra.queue) > 0 {
+		f := ra.queue[0]
+		ra.queue = ra.queue[1:]
+		f.started = true
+		ra.running++
+		go ra.prefetch(f)
+	}
+}
+
+func (ra *readahead) prefetch(f *leafFetch) {
+	lb, _, err := f.r.readLeaf(f.r.background(), f.key, f.index, f.index, nil)
+	if err != nil {
+		ra.l.Debug("prefetch failed", zap.Stringer("key", f.key), zap.Int("index", f.index), zap.Error(err))
+	} else {
+		lb.Unpin()
+		f.r.addToCache(f.key, lb)
+		atomic.AddUint64(&ra.prefetched, 1)
+	}
+
+	ra.lock.Lock()
+	defer ra.lock.Unlock()
+
+	f.err = err
+	ra.running--
+	ra.done(f)
+	ra.dispatch()
+}
+
+// fetch retrieves a leaf missed from the cache on behalf of a foreground read.
+//
+// The returned buffer is pinned and already added to the cache.
+func (ra *readahead) fetch(r *chunkReader, key Key, index int) (LeafBuffer, error) {
+	pth := r.pather(key)
+
+	ra.lock.Lock()
+	ra.foreground++
+	f, ok := ra.inflight[pth]
+	if ok && f.started {
+		// the leaf is being fetched: wait for it
+		ra.lock.Unlock()
+		<-f.done
+
+		r.lruLatch.Lock()
+		b, found := r.lru.Get(pth)
+		if found {
+			lb := b.(LeafBuffer)
+			lb.Pin()
+			r.lruLatch.Unlock()
+			ra.endForeground(nil)
+			return lb, nil
+		}
+		r.lruLatch.Unlock()
+
+		if f.err == nil {
+			// prefetched leaf evicted before it could be used
+			atomic.AddUint64(&r.wasted, 1)
+		}
+		ra.lock.Lock()
+	}
+
+	if ok && !f.started {
+		// the leaf is still pending: take it over
+		ra.unqueue(f)
+	}
+
+	// other foreground reads of the same leaf wait for this one
+	f = &leafFetch{r: r, key: key, index: index, path: pth, started: true, done: make(chan struct{})}
+	ra.inflight[pth] = f
+	ra.lock.Unlock()
+
+	lb, _, err := r.readLeaf(r.ctx, key, index, index, nil)
+	if err == nil {
+		r.addToCache(key, lb)
+	}
+	f.err = err
+	ra.endForeground(f)
+
+	return lb, err
+}
+
+func (ra *readahead) endForeground(f *leafFetch) {
+	ra.lock.Lock()
+	defer ra.lock.Unlock()
+
+	ra.foreground--
+	if f != nil {
+		ra.done(f)
+	}
+	ra.dispatch()
+}
+
+// done signals the completion of a fetch. This must be called under lock.
+func (ra *readahead) done(f *leafFetch) {
+	if ra.inflight[f.path] == f {
+		delete(ra.inflight, f.path)
+	}
+	close(f.done)
+}
+
+// unqueue removes a pending prefetch from the queue. This must be called under lock.
+func (ra *readahead) unqueue(f *leafFetch) {
+	for i, queued := range ra.queue {
+		if queued == f {
+			ra.queue = append(ra.queue[:i], ra.queue[i+1:]...)
+			break
+		}
+	}
+	ra.done(f)
+}
+
+// totals yields the number of prefetched leaves and the number of dropped prefetches
+func (ra *readahead) totals() (uint64, uint64) {
+	return atomic.LoadUint64(&ra.prefetched), atomic.LoadUint64(&ra.dropped)
+}
@@ -0,0 +1,229 @@
+package cafs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// slowStore delays Get operations and keeps track of their concurrency.
+//
+// Like remote stores, it fails to Get objects with a cancelled context.
+type slowStore struct {
+	storage.Store
+	delay     time.Duration
+	gets      int64
+	running   int64
+	maxRun    int64
+	cancelled int64
+}
+
+func (s *slowStore) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	running := atomic.AddInt64(&s.running, 1)
+	defer atomic.AddInt64(&s.running, -1)
+	for {
+		hwm := atomic.LoadInt64(&s.maxRun)
+		if running <= hwm || atomic.CompareAndSwapInt64(&s.maxRun, hwm, running) {
+			break
+		}
+	}
+	atomic.AddInt64(&s.gets, 1)
+	time.Sleep(s.delay)
+	if err := ctx.Err(); err != nil {
+		atomic.AddInt64(&s.cancelled, 1)
+		return nil, err
+	}
+	return s.Store.Get(ctx, name)
+}
+
+func (s *slowStore) reset() {
+	atomic.StoreInt64(&s.gets, 0)
+	atomic.StoreInt64(&s.maxRun, 0)
+	atomic.StoreInt64(&s.cancelled, 0)
+}
+
+func TestReadahead(t *testing.T) {
+	const (
+		testLeafSize  = 32 * 1024
+		leaves        = 40
+		maxWindow     = 4
+		maxPrefetches = 2
+	)
+	td, err := ioutil.TempDir("", "tpt-cafs-readahead")
+	require.NoError(t, err)
+	defer os.RemoveAll(td)
+
+	blobs := &slowStore{
+		Store: localfs.New(afero.NewBasePathFs(afero.NewOsFs(), filepath.Join(td, "cafs"))),
+		delay: 5 * time.Millisecond,
+	}
+	fs, err := New(
+		LeafSize(testLeafSize),
+		Backend(blobs),
+		CacheSize(100*testLeafSize),
+		ReadAhead(maxWindow),
+		MaxPrefetches(maxPrefetches),
+	)
+	require.NoError(t, err)
+	ra := fs.(*defaultFs).readahead
+	require.NotNil(t, ra)
+
+	put := func(t testing.TB, seed byte) ([]byte, Key) {
+		content := make([]byte, leaves*testLeafSize)
+		for i := range content {
+			content[i] = byte(i/testLeafSize) + seed
+		}
+		// io.Copy writes by chunks aligned on leaves
+		res, err := fs.Put(context.Background(), struct{ io.Reader }{bytes.NewReader(content)})
+		require.NoError(t, err)
+		return content, res.Key
+	}
+	idle := func() {
+		for {
+			ra.lock.Lock()
+			busy := ra.running > 0 || ra.foreground > 0
+			ra.lock.Unlock()
+			if !busy {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	streamsOf := func(key Key) []*readStream {
+		ra.lock.Lock()
+		defer ra.lock.Unlock()
+		v, ok := ra.streams.Get(key)
+		if !ok {
+			return nil
+		}
+		return v.([]*readStream)
+	}
+
+	t.Run("should grow the window of sequential reads", func(t *testing.T) {
+		content, key := put(t, 0)
+		blobs.reset()
+		rdr, err := fs.GetAt(context.Background(), key)
+		require.NoError(t, err)
+
+		buf := make([]byte, testLeafSize/2)
+		for off := 0; off < len(content); off += len(buf) {
+			n, err := rdr.ReadAt(buf, int64(off))
+			require.NoError(t, err)
+			require.Equal(t, content[off:off+n], buf[:n])
+		}
+		idle()
+
+		streams := streamsOf(key)
+		require.Len(t, streams, 1)
+		assert.Equal(t, maxWindow, streams[0].window)
+		assert.Equal(t, leaves-1, streams[0].last)
+
+		prefetched, _ := ra.totals()
+		assert.True(t, prefetched > leaves/2, "expected most leaves to be prefetched, got %d", prefetched)
+		assert.True(t, atomic.LoadInt64(&blobs.gets) <= leaves, "leaves should be fetched only once")
+		// prefetches are capped, and may only overlap with one foreground fetch
+		assert.True(t, atomic.LoadInt64(&blobs.maxRun) <= maxPrefetches+1)
+	})
+
+	t.Run("should prefetch beyond the context of the read", func(t *testing.T) {
+		content, key := put(t, 50)
+		blobs.reset()
+		before, _ := ra.totals()
+
+		// like FUSE operations, each read has its own context, cancelled as soon as it returns
+		buf := make([]byte, testLeafSize)
+		for leaf := 0; leaf < leaves/2; leaf++ {
+			ctx, cancel := context.WithCancel(context.Background())
+			rdr, err := fs.GetAt(ctx, key)
+			require.NoError(t, err)
+			off := leaf * testLeafSize
+			_, err = rdr.ReadAt(buf, int64(off))
+			cancel()
+			require.NoError(t, err)
+			require.Equal(t, content[off:off+len(buf)], buf)
+		}
+		idle()
+
+		after, _ := ra.totals()
+		assert.True(t, after-before > leaves/4, "expected leaves to be prefetched, got %d", after-before)
+		assert.Zero(t, atomic.LoadInt64(&blobs.cancelled), "prefetches should not fail with the cancelled context of the read")
+	})
+
+	t.Run("should shrink the window on random access", func(t *testing.T) {
+		content, key := put(t, 100)
+		blobs.reset()
+		rdr, err := fs.GetAt(context.Background(), key)
+		require.NoError(t, err)
+		before, _ := ra.totals()
+
+		buf := make([]byte, 10)
+		for _, leaf := range []int{30, 10, 20, 5, 25} {
+			off := leaf*testLeafSize + 100
+			_, err := rdr.ReadAt(buf, int64(off))
+			require.NoError(t, err)
+			require.Equal(t, content[off:off+len(buf)], buf)
+		}
+		idle()
+
+		streams := streamsOf(key)
+		assert.Len(t, streams, maxStreamsPerFile)
+		for _, s := range streams {
+			assert.Equal(t, 0, s.window)
+		}
+		after, _ := ra.totals()
+		assert.Equal(t, before, after, "random reads should not prefetch")
+		assert.Equal(t, int64(5), atomic.LoadInt64(&blobs.gets))
+	})
+
+	t.Run("should take over pending prefetches and drop overflowing ones", func(t *testing.T) {
+		content, key := put(t, 200)
+		rdr, err := fs.GetAt(context.Background(), key)
+		require.NoError(t, err)
+		r := rdr.(*chunkReader)
+		_, droppedBefore := ra.totals()
+
+		// hold prefetches as if some foreground fetch was running
+		ra.lock.Lock()
+		ra.foreground++
+		for i := 0; i < leaves; i++ {
+			ra.schedule(r, i)
+		}
+		assert.Len(t, ra.queue, ra.maxQueued)
+		pending := ra.inflight[r.pather(r.keys[leaves-1])]
+		require.NotNil(t, pending)
+		ra.foreground--
+		ra.lock.Unlock()
+
+		_, dropped := ra.totals()
+		assert.Equal(t, uint64(leaves-ra.maxQueued), dropped-droppedBefore)
+
+		// a foreground read takes over the last pending leaf
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-pending.done
+		}()
+		buf := make([]byte, 10)
+		off := (leaves-1)*testLeafSize + 10
+		_, err = rdr.ReadAt(buf, int64(off))
+		require.NoError(t, err)
+		assert.Equal(t, content[off:off+len(buf)], buf)
+		wg.Wait()
+		assert.False(t, pending.started)
+		idle()
+	})
+}
@@ -14,6 +14,9 @@ import (
 	"github.com/oneconcern/datamon/pkg/dlogger"
 	"github.com/oneconcern/datamon/pkg/metrics"
 	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/oneconcern/datamon/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
@@ -46,6 +49,7 @@ func defaultChunkReader(blobs storage.Store, hash Key, leafSize uint32) *chunkRe
 		concurrentChunkWrites: defaultConcurrentWrite,
 		l:                     dlogger.MustGetLogger("info"),
 		maxFetchAhead:         defaultFetchAhead,
+		ctx:                   context.Background(),
 	}
 }
 
@@ -122,6 +126,7 @@ func newReader(blobs storage.Store, hash Key, leafSize uint32, opts ...ReaderOpt
 }
 
 type chunkReader struct {
+	ctx      context.Context // carries the trace of the operation reading
 	fs       storage.Store
 	leafSize uint32
 	hash     Key
@@ -138,7 +143,7 @@ type chunkReader struct {
 	l                     *zap.Logger
 	truncation            uint32
 	withVerifyHash        bool
-	readLeaf              func(Key, int, int, <-chan struct{}) (LeafBuffer, bool, error)
+	readLeaf              func(context.Context, Key, int, int, <-chan struct{}) (LeafBuffer, bool, error)
 	seekAhead             func(int, int) bool
 	pather                func(Key) string
 
@@ -156,6 +161,7 @@ type chunkReader struct {
 	fetchC        chan fetch
 	fetcherWg     sync.WaitGroup
 	prefetchDoneC chan struct{}
+	readahead     *readahead // adaptive readahead shared by all readers of a Fs
 
 	// metrics
 	fetched     uint64 // number of fetched leaf blocks
@@ -240,11 +246,14 @@ func (r *chunkReader) WriteTo(writer io.Writer) (n int64, err error) {
 		i := int64(index) * int64(r.leafSize-r.truncation)
 		concurrencyControl <- struct{}{}
 		go func(writeAt int64, writer io.WriterAt, key Key, cafs storage.Store, wg *sync.WaitGroup) {
+			ctx, span := tracing.Start(r.ctx, "cafs.FetchLeaf", attribute.String("key", r.pather(key)))
+			var err error
 			defer func() {
+				tracing.End(span, err)
 				<-concurrencyControl
 				wg.Done()
 			}()
-			rdr, err := cafs.Get(context.Background(), r.pather(key)) // thread safe
+			rdr, err := cafs.Get(ctx, r.pather(key)) // thread safe
 			if err != nil {
 				errC <- err
 				return
@@ -354,7 +363,7 @@ func calculateKeyAndOffset(off int64, leafSize uint32) (index int, offset int64)
 	return
 }
 
-func readLeafFunc(r *chunkReader) func(Key, int, int, <-chan struct{}) (LeafBuffer, bool, error) {
+func readLeafFunc(r *chunkReader) func(context.Context, Key, int, int, <-chan struct{}) (LeafBuffer, bool, error) {
 	// readLeafFunc returns a leaf fetching functio with hash key, key index as parameters.
 	//
 	// The input signalling channel is provided for completeness only or callers willing to interrupt
@@ -375,11 +384,16 @@ func readLeafFunc(r *chunkReader) func(Key, int, int, <-chan struct{}) (LeafBuff
 		zap.Uint32("leaf size", r.leafSize),
 		zap.Uint32("buffer size", r.leafPool.Size()),
 	)
-	return func(k Key, index, initiator int, doneC <-chan struct{}) (LeafBuffer, bool, error) {
+	return func(ctx context.Context, k Key, index, initiator int, doneC <-chan struct{}) (_ LeafBuffer, _ bool, err error) {
 		// readLeaf fetches an entire leaf from store
+		ctx, span := tracing.Start(ctx, "cafs.FetchLeaf", attribute.String("key", r.pather(k)), attribute.Int("index", index))
+		defer func() {
+			tracing.End(span, err)
+		}()
+
 		logger := r.l.With(zap.String("prefix", r.prefix), zap.Stringer("key", k), zap.Int("index", index))
 		logger.Debug("Start cafs reading leaf from store")
-		rdr, err := r.fs.Get(context.Background(), r.pather(k))
+		rdr, err := r.fs.Get(ctx, r.pather(k))
 		if err != nil {
 			return nil, false, err
 		}
@@ -500,6 +514,14 @@ func addToCacheFunc(r *chunkReader) func(Key, LeafBuffer) {
 	}
 }
 
+// background yields a context for fetches which may outlive the read that started them (e.g. prefetches).
+//
+// Such fetches keep the trace of the read, but not its cancellation: a FUSE operation cancels its context
+// as soon as it replies.
+func (r *chunkReader) background() context.Context {
+	return trace.ContextWithSpan(context.Background(), trace.SpanFromContext(r.ctx))
+}
+
 // seekAhead prefetches blobs ahead
 func seekAheadFunc(r *chunkReader) func(int, int) bool {
 	return func(index, initiator int) bool {
@@ -523,7 +545,7 @@ func seekAheadFunc(r *chunkReader) func(int, int) bool {
 			default:
 			}
 			r.l.Debug("prefetch started", zap.Int("new index", i), zap.Stringer("key", r.keys[i]))
-			b, fromCache, err := r.readLeaf(k, i, initiator, r.prefetchDoneC)
+			b, fromCache, err := r.readLeaf(r.background(), k, i, initiator, r.prefetchDoneC)
 			if err != nil {
 				outputC <- fetch{err: err}
 				return
@@ -565,6 +587,19 @@ func (r *chunkReader) ReadAt(data []byte, off int64) (readBytes int, err error)
 		return 0, nil
 	}
 
+	if r.readahead != nil && bytesToRead > 0 {
+		// detect the access pattern and fetch ahead accordingly
+		last, _ := calculateKeyAndOffset(off+int64(bytesToRead)-1, r.leafSize)
+		if last >= len(r.keys) {
+			last = len(r.keys) - 1
+		}
+		window, sequential := r.readahead.advance(r, index, last)
+		if r.MetricsEnabled() {
+			prefetched, dropped := r.readahead.totals()
+			r.m.Volume.Cache.Readahead(window, sequential, prefetched, dropped, r.leafSize)
+		}
+	}
+
 	for {
 		// fetch leaf blobs
 		var (
@@ -586,7 +621,13 @@ func (r *chunkReader) ReadAt(data []byte, off int64) (readBytes int, err error)
 			// collects some metrics
 			atomic.AddUint64(&r.cacheMisses, 1)
 			atomic.AddUint64(&r.requested, 1)
-			buffer, fromCache, err = r.readLeaf(key, index, index, r.prefetchDoneC) // readLeaf returns a pinned buffer
+			if r.readahead != nil {
+				// the buffer is pinned and already added to the cache
+				buffer, err = r.readahead.fetch(r, key, index)
+				fromCache = true
+			} else {
+				buffer, fromCache, err = r.readLeaf(r.ctx, key, index, index, r.prefetchDoneC) // readLeaf returns a pinned buffer
+			}
 			if err != nil {
 				return
 			}
@@ -627,7 +668,7 @@ func (r *chunkReader) Read(data []byte) (int, error) {
 	for {
 		key := r.keys[r.idx]
 		if r.rdr == nil {
-			rdr, err := r.fs.Get(context.Background(), r.pather(key))
+			rdr, err := r.fs.Get(r.ctx, r.pather(key))
 			if err != nil {
 				return r.readSoFar, err
 			}
@@ -1,6 +1,7 @@
 package cafs
 
 import (
+	"context"
 	"sync"
 
 	lru "github.com/hashicorp/golang-lru"
@@ -83,6 +84,22 @@ func ReaderPrefetch(ahead int) ReaderOption {
 	}
 }
 
+// readerReadahead shares the adaptive readahead of a Fs with the reader
+func readerReadahead(ra *readahead) ReaderOption {
+	return func(reader *chunkReader) {
+		reader.readahead = ra
+	}
+}
+
+// readerContext sets the context of the operation reading, to trace leaf fetches
+func readerContext(ctx context.Context) ReaderOption {
+	return func(reader *chunkReader) {
+		if ctx != nil {
+			reader.ctx = ctx
+		}
+	}
+}
+
 // ReaderPrefix sets a prefix for the keys used by this reader
 func ReaderPrefix(prefix string) ReaderOption {
 	return func(reader *chunkReader) {
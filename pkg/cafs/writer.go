@@ -11,6 +11,8 @@ import (
 	"github.com/oneconcern/datamon/pkg/dlogger"
 	"github.com/oneconcern/datamon/pkg/metrics"
 	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/oneconcern/datamon/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
@@ -25,6 +27,7 @@ type Writer interface {
 var _ Writer = &fsWriter{}
 
 type fsWriter struct {
+	ctx                 context.Context  // carries the trace of the operation writing
 	store               storage.Store    // CAFS backing store
 	prefix              string           // Prefix for store paths
 	leafSize            uint32           // Size of chunks
@@ -58,6 +61,7 @@ func defaultFsWriter(blobs storage.Store, leafSize uint32) *fsWriter {
 		blobFlushes:         make([]blobFlush, 0),
 		errors:              make([]error, 0),
 		l:                   dlogger.MustGetLogger("info"),
+		ctx:                 context.Background(),
 	}
 }
 
@@ -177,9 +181,12 @@ func (w *fsWriter) flush(isLastNode bool) (int, error) {
 	return n, nil
 }
 
-func (w *fsWriter) writeBlob(data []byte, key Key, n uint64) error {
+func (w *fsWriter) writeBlob(data []byte, key Key, n uint64) (err error) {
 	lg := w.l.With(zap.String("blob_key", w.pather(key)), zap.Uint64("offset", n))
-	ctx := context.TODO()
+	ctx, span := tracing.Start(w.ctx, "cafs.PutLeaf", attribute.String("key", w.pather(key)), attribute.Int("size", len(data)))
+	defer func() {
+		tracing.End(span, err)
+	}()
 
 	found, overwrite := existsAndValidBlob(ctx, w.store, w.pather(key), data, lg)
 	switch {
@@ -198,7 +205,6 @@ func (w *fsWriter) writeBlob(data []byte, key Key, n uint64) error {
 		lg.Info("blob is already in store, but it was found corrupted. Overwrite it")
 	}
 
-	var err error
 	switch d := w.store.(type) {
 	case storage.StoreCRC:
 		crc := crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))
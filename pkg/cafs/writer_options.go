@@ -1,6 +1,10 @@
 package cafs
 
-import "go.uber.org/zap"
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
 
 // WriterOption is a functor to provide the writer with options
 type WriterOption func(writer *fsWriter)
@@ -55,3 +59,12 @@ func WriterWithVerifyHash(enabled bool) WriterOption {
 		writer.withVerifyHash = enabled
 	}
 }
+
+// writerContext sets the context of the operation writing, to trace leaf uploads
+func writerContext(ctx context.Context) WriterOption {
+	return func(writer *fsWriter) {
+		if ctx != nil {
+			writer.ctx = ctx
+		}
+	}
+}
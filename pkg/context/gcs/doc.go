@@ -1,3 +1,5 @@
 // Package gcs is a gcs implementation of the datamon context, with
-// all context stores as gcs buckets
+// all context stores as gcs buckets.
+//
+// The blob and metadata stores of a context may be mirrored to a replica, hosted on gcs or AWS S3.
 package gcs
@@ -6,30 +6,42 @@ import (
 	context2 "github.com/oneconcern/datamon/pkg/context"
 	"github.com/oneconcern/datamon/pkg/context/status"
 	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage"
 	gcsstore "github.com/oneconcern/datamon/pkg/storage/gcs"
 )
 
-// MakeContext initializes all gcs stores in a context described by its model, with some gcs credentials
+// MakeContext initializes all gcs stores in a context described by its model, with some gcs credentials.
+//
+// Whenever the context defines a replica, writes to the blob, metadata and vmetadata stores are duplicated to the replica.
 func MakeContext(ctx context.Context, descriptor model.Context, creds string, opts ...gcsstore.Option) (context2.Stores, error) {
 	stores := context2.New()
 
+	var replica context2.Stores
+	if descriptor.Replica != nil {
+		r, err := MakeReplicaContext(ctx, descriptor, creds, opts...)
+		if err != nil {
+			return nil, err
+		}
+		replica = r
+	}
+
 	meta, err := gcsstore.New(ctx, descriptor.Metadata, creds, opts...)
 	if err != nil {
 		return nil, status.ErrInitMetadata.Wrap(err)
 	}
-	stores.SetMetadata(meta)
+	stores.SetMetadata(replicated(meta, replica, context2.Stores.Metadata))
 
 	blob, err := gcsstore.New(ctx, descriptor.Blob, creds, opts...)
 	if err != nil {
 		return nil, status.ErrInitBlob.Wrap(err)
 	}
-	stores.SetBlob(blob)
+	stores.SetBlob(replicated(blob, replica, context2.Stores.Blob))
 
 	v, err := gcsstore.New(ctx, descriptor.VMetadata, creds, opts...)
 	if err != nil {
 		return nil, status.ErrInitVMetadata.Wrap(err)
 	}
-	stores.SetVMetadata(v)
+	stores.SetVMetadata(replicated(v, replica, context2.Stores.VMetadata))
 
 	w, err := gcsstore.New(ctx, descriptor.WAL, creds, opts...)
 	if err != nil {
@@ -45,3 +57,10 @@ func MakeContext(ctx context.Context, descriptor model.Context, creds string, op
 
 	return stores, nil
 }
+
+func replicated(primary storage.Store, replica context2.Stores, store func(context2.Stores) storage.Store) storage.Store {
+	if replica == nil {
+		return primary
+	}
+	return storage.NewReplicated(primary, store(replica))
+}
@@ -0,0 +1,59 @@
+package gcs
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/context/status"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage"
+	gcsstore "github.com/oneconcern/datamon/pkg/storage/gcs"
+	"github.com/oneconcern/datamon/pkg/storage/sthree"
+)
+
+// MakeReplicaContext initializes the stores of the replica of a context.
+//
+// Only the blob, metadata and vmetadata stores are replicated. The replica may be hosted on AWS S3:
+// in that case, AWS credentials are resolved from the AWS SDK default chain.
+func MakeReplicaContext(ctx context.Context, descriptor model.Context, creds string, opts ...gcsstore.Option) (context2.Stores, error) {
+	if descriptor.Replica == nil {
+		return nil, status.ErrInitReplica.WrapMessage("context %q has no replica", descriptor.Name)
+	}
+
+	replica := *descriptor.Replica
+	stores := context2.New()
+
+	makeStore := func(bucket string) (storage.Store, error) {
+		switch replica.Backend {
+		case model.ReplicaS3:
+			cfg := aws.NewConfig()
+			if replica.Region != "" {
+				cfg = cfg.WithRegion(replica.Region)
+			}
+			return sthree.New(sthree.Bucket(bucket), sthree.AWSConfig(cfg)), nil
+		default:
+			return gcsstore.New(ctx, bucket, creds, opts...)
+		}
+	}
+
+	meta, err := makeStore(replica.Metadata)
+	if err != nil {
+		return nil, status.ErrInitReplica.Wrap(status.ErrInitMetadata.Wrap(err))
+	}
+	stores.SetMetadata(meta)
+
+	blob, err := makeStore(replica.Blob)
+	if err != nil {
+		return nil, status.ErrInitReplica.Wrap(status.ErrInitBlob.Wrap(err))
+	}
+	stores.SetBlob(blob)
+
+	v, err := makeStore(replica.VMetadata)
+	if err != nil {
+		return nil, status.ErrInitReplica.Wrap(status.ErrInitVMetadata.Wrap(err))
+	}
+	stores.SetVMetadata(v)
+
+	return stores, nil
+}
@@ -22,4 +22,7 @@ var (
 
 	// ErrInitRLog indicates that we could not initialize the read log for this context
 	ErrInitRLog = errors.New("failed to initialize read log store")
+
+	// ErrInitReplica indicates that we could not initialize the replica stores for this context
+	ErrInitReplica = errors.New("failed to initialize replica stores")
 )
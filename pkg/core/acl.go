@@ -0,0 +1,156 @@
+package core
+
+import (
+	"bytes"
+	"context"
+
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/core/status"
+	"github.com/oneconcern/datamon/pkg/errors"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage"
+	storagestatus "github.com/oneconcern/datamon/pkg/storage/status"
+	"gopkg.in/yaml.v2"
+)
+
+// GetACLStore tells which store holds access control lists
+func GetACLStore(stores context2.Stores) storage.Store {
+	return getVMetaStore(stores)
+}
+
+// GetACL retrieves the access control lists on a repo, or the context-wide ACL when repo is empty.
+//
+// An empty ACL is returned when no ACL has been set.
+func GetACL(repo string, stores context2.Stores) (model.ACL, error) {
+	acl := model.ACL{Repo: repo}
+
+	store := GetACLStore(stores)
+	if store == nil {
+		return acl, nil
+	}
+
+	rdr, err := store.Get(context.Background(), model.GetArchivePathToACL(repo))
+	if err != nil {
+		if errors.Is(err, storagestatus.ErrNotExists) {
+			return acl, nil
+		}
+		return acl, err
+	}
+	defer func() {
+		_ = rdr.Close()
+	}()
+
+	if err = yaml.NewDecoder(rdr).Decode(&acl); err != nil {
+		return acl, err
+	}
+	return acl, nil
+}
+
+// SetACL persists the access control lists on a repo, or the context-wide ACL when acl.Repo is empty.
+//
+// When some ACL is already in place, the contributor set with the WithContributor option must be
+// an admin of this repo.
+func SetACL(acl model.ACL, stores context2.Stores, opts ...Option) error {
+	settings := defaultSettings()
+	for _, apply := range opts {
+		apply(&settings)
+	}
+
+	if acl.Repo != "" {
+		if err := RepoExists(acl.Repo, stores); err != nil {
+			return err
+		}
+	}
+
+	if err := model.ValidateACL(acl); err != nil {
+		return err
+	}
+
+	if err := checkACL(acl.Repo, stores, model.ACLAdmin, settings.contributor); err != nil {
+		return err
+	}
+
+	acl.Timestamp = model.GetBundleTimeStamp()
+	acl.Contributor = settings.contributor
+
+	return putACL(acl, stores)
+}
+
+func putACL(acl model.ACL, stores context2.Stores) error {
+	buffer, err := yaml.Marshal(acl)
+	if err != nil {
+		return err
+	}
+	return GetACLStore(stores).Put(context.Background(), model.GetArchivePathToACL(acl.Repo), bytes.NewReader(buffer), storage.OverWrite)
+}
+
+func deleteACL(repo string, stores context2.Stores) error {
+	store := GetACLStore(stores)
+	if store == nil {
+		return nil
+	}
+
+	err := store.Delete(context.Background(), model.GetArchivePathToACL(repo))
+	if err != nil && !errors.Is(err, storagestatus.ErrNotExists) {
+		return err
+	}
+	return nil
+}
+
+// EffectiveRole yields the role of a contributor on a repo, or on the context when repo is empty.
+//
+// The effective role on a repo is the highest role granted by the repo ACL and the context-wide ACL.
+// When no ACL is set, every contributor is an admin.
+func EffectiveRole(repo string, stores context2.Stores, contributor model.Contributor) (model.ACLRole, error) {
+	contextACL, err := GetACL("", stores)
+	if err != nil {
+		return model.ACLNone, err
+	}
+
+	repoACL := model.ACL{}
+	if repo != "" {
+		repoACL, err = GetACL(repo, stores)
+		if err != nil {
+			return model.ACLNone, err
+		}
+	}
+
+	if contextACL.IsEmpty() && repoACL.IsEmpty() {
+		return model.ACLAdmin, nil
+	}
+
+	role := contextACL.RoleOf(contributor.Email)
+	if repoRole := repoACL.RoleOf(contributor.Email); !role.Includes(repoRole) {
+		role = repoRole
+	}
+	return role, nil
+}
+
+// checkACL verifies that some contributor is granted a role on a repo, or on the context when repo is empty.
+//
+// When several contributors are provided, it is sufficient that one of them is granted the role.
+func checkACL(repo string, stores context2.Stores, role model.ACLRole, contributors ...model.Contributor) error {
+	if len(contributors) == 0 {
+		contributors = []model.Contributor{{}}
+	}
+
+	for _, contributor := range contributors {
+		granted, err := EffectiveRole(repo, stores, contributor)
+		if err != nil {
+			return err
+		}
+		if granted.Includes(role) {
+			return nil
+		}
+	}
+
+	target := "context"
+	if repo != "" {
+		target = "repo " + repo
+	}
+	who := contributors[0].Email
+	if who == "" {
+		who = "anonymous contributor"
+	}
+	return status.ErrForbidden.WrapMessage("%s must be %s on %s", who, role, target)
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/core/status"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACL(t *testing.T) {
+	ev, cleanup := testDiamondEnv()
+	defer cleanup(t)()
+
+	ctx := mocks.FakeContext2(ev.MetaDir, ev.VmetaDir, ev.BlobDir)
+
+	alice := model.Contributor{Name: "alice", Email: "alice@example.com"}
+	bob := model.Contributor{Name: "bob", Email: "bob@example.com"}
+	carol := model.Contributor{Name: "carol", Email: "carol@example.com"}
+
+	require.NoError(t, CreateRepo(model.RepoDescriptor{Name: ev.Repo, Description: "test", Contributor: carol}, ctx))
+
+	setLabel := func(name string, contributor model.Contributor) error {
+		bundle := NewBundle(Repo(ev.Repo), ContextStores(ctx), BundleID("1ySIsDlHSX0ZG3ZtYByPqv7dgvU"))
+		label := NewLabel(LabelDescriptor(model.NewLabelDescriptor(
+			model.LabelName(name),
+			model.LabelContributor(contributor),
+		)))
+		return label.UploadDescriptor(context.Background(), bundle)
+	}
+
+	t.Run("should allow anyone without ACL", func(t *testing.T) {
+		role, err := EffectiveRole(ev.Repo, ctx, carol)
+		require.NoError(t, err)
+		assert.Equal(t, model.ACLAdmin, role)
+		require.NoError(t, setLabel("free", carol))
+	})
+
+	t.Run("should set repo ACL", func(t *testing.T) {
+		acl, err := GetACL(ev.Repo, ctx)
+		require.NoError(t, err)
+		require.True(t, acl.IsEmpty())
+
+		require.NoError(t, acl.Grant(bob.Email, model.ACLWriter))
+		require.Error(t, SetACL(acl, ctx, WithContributor(alice)), "an ACL without admin should be rejected")
+
+		require.NoError(t, acl.Grant(alice.Email, model.ACLAdmin))
+		require.NoError(t, SetACL(acl, ctx, WithContributor(alice)))
+
+		acl, err = GetACL(ev.Repo, ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{alice.Email}, acl.Admins)
+		assert.Equal(t, []string{bob.Email}, acl.Writers)
+		assert.Equal(t, alice, acl.Contributor)
+	})
+
+	t.Run("should enforce writer role", func(t *testing.T) {
+		err := setLabel("denied", carol)
+		require.Error(t, err)
+		assert.True(t, status.ErrForbidden.Is(err))
+
+		require.NoError(t, setLabel("granted", bob))
+		require.True(t, status.ErrForbidden.Is(DeleteLabel(ev.Repo, ctx, "granted", WithDeleteContributor(carol))))
+		require.NoError(t, DeleteLabel(ev.Repo, ctx, "granted", WithDeleteContributor(bob)))
+	})
+
+	t.Run("should enforce admin role", func(t *testing.T) {
+		acl, err := GetACL(ev.Repo, ctx)
+		require.NoError(t, err)
+		require.NoError(t, acl.Grant(carol.Email, model.ACLWriter))
+		require.True(t, status.ErrForbidden.Is(SetACL(acl, ctx, WithContributor(bob))))
+
+		require.True(t, status.ErrForbidden.Is(DeleteRepo(ev.Repo, ctx, WithDeleteContributor(bob))))
+		require.True(t, status.ErrForbidden.Is(RepoSquash(ctx, ev.Repo, WithContributor(bob))))
+		require.True(t, status.ErrForbidden.Is(RenameRepo(ev.Repo, "renamed", ctx, WithContributor(bob))))
+	})
+
+	t.Run("should enforce context ACL", func(t *testing.T) {
+		acl := model.ACL{}
+		require.NoError(t, acl.Grant(alice.Email, model.ACLAdmin))
+		require.NoError(t, acl.Grant(model.ACLEveryone, model.ACLReader))
+		require.NoError(t, SetACL(acl, ctx, WithContributor(alice)))
+
+		err := CreateRepo(model.RepoDescriptor{Name: "other", Description: "test", Contributor: bob}, ctx)
+		require.True(t, status.ErrForbidden.Is(err))
+
+		require.True(t, status.ErrForbidden.Is(PurgeDropReverseIndex(ctx, WithPurgeContributor(bob))))
+
+		// bob is a writer on the repo, and a reader on the context
+		role, err := EffectiveRole(ev.Repo, ctx, bob)
+		require.NoError(t, err)
+		assert.Equal(t, model.ACLWriter, role)
+
+		// context admins are admins on all repos
+		role, err = EffectiveRole(ev.Repo, ctx, model.Contributor{Email: "Alice@Example.com"})
+		require.NoError(t, err)
+		assert.Equal(t, model.ACLAdmin, role)
+	})
+
+	t.Run("should transfer ACL on rename", func(t *testing.T) {
+		require.NoError(t, RenameRepo(ev.Repo, "renamed", ctx, WithContributor(alice)))
+
+		acl, err := GetACL("renamed", ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "renamed", acl.Repo)
+		assert.Equal(t, []string{bob.Email}, acl.Writers)
+
+		acl, err = GetACL(ev.Repo, ctx)
+		require.NoError(t, err)
+		assert.True(t, acl.IsEmpty())
+	})
+
+	t.Run("should remove ACL on delete", func(t *testing.T) {
+		require.NoError(t, DeleteRepo("renamed", ctx, WithDeleteContributor(alice)))
+
+		acl, err := GetACL("renamed", ctx)
+		require.NoError(t, err)
+		assert.True(t, acl.IsEmpty())
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/oneconcern/datamon/pkg/cafs"
 	context2 "github.com/oneconcern/datamon/pkg/context"
 	"github.com/oneconcern/datamon/pkg/metrics"
 
@@ -19,6 +20,8 @@ import (
 	"github.com/oneconcern/datamon/pkg/core/status"
 	"github.com/oneconcern/datamon/pkg/model"
 	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/oneconcern/datamon/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Bundle represents a bundle in its archived state.
@@ -38,8 +41,11 @@ type Bundle struct {
 	concurrentFileUploads       int
 	concurrentFileDownloads     int
 	concurrentFilelistDownloads int
-	withVerifyHash              bool // When downloading file
-	withVerifyBlobHash          bool // When uploading files
+	withVerifyHash              bool            // When downloading file
+	withVerifyBlobHash          bool            // When uploading files
+	previewDiamondID            string          // When previewing the merged splits of an uncommitted diamond
+	leafCache                   *cafs.LeafCache // When downloading files
+	progressFn                  ProgressFunc    // When uploading or downloading files
 
 	metrics.Enable
 	m *M
@@ -66,6 +72,11 @@ func (b *Bundle) GetBundleEntries() []model.BundleEntry {
 	return b.BundleEntries
 }
 
+// PreviewDiamondID yields the ID of the diamond previewed by this bundle, if any
+func (b *Bundle) PreviewDiamondID() string {
+	return b.previewDiamondID
+}
+
 // BlobStore defines the blob storage (part of the context) for a bundle
 func (b *Bundle) BlobStore() storage.Store {
 	return getBlobStore(b.contextStores)
@@ -182,12 +193,23 @@ func PublishSelectBundleEntries(ctx context.Context, bundle *Bundle, selectionPr
 
 // implementation of Publish() with some additional parameters for test
 func implPublish(ctx context.Context, bundle *Bundle, entriesPerFile uint,
-	selectionPredicate func(string) (bool, error)) error {
-	err := implPublishMetadata(ctx, bundle, true, entriesPerFile)
+	selectionPredicate func(string) (bool, error)) (err error) {
+	ctx, span := tracing.Start(ctx, "core.DownloadBundle",
+		attribute.String("repo", bundle.RepoID),
+		attribute.String("bundle", bundle.BundleID),
+	)
+	p := newProgress(ProgressDownload, bundle.progressFn)
+	defer func() {
+		tracing.End(span, err)
+		p.finish(err)
+	}()
+
+	p.phase(PhaseMetadata, 0, 0)
+	err = implPublishMetadata(ctx, bundle, true, entriesPerFile)
 	if err != nil {
 		return status.ErrPublishMetadata.Wrap(err)
 	}
-	err = unpackDataFiles(ctx, bundle, nil, selectionPredicate)
+	err = unpackDataFiles(ctx, bundle, nil, selectionPredicate, p)
 	if err != nil {
 		return status.ErrPublishMetadata.Wrap(err)
 	}
@@ -220,6 +242,10 @@ func DownloadMetadata(ctx context.Context, bundle *Bundle) (err error) {
 
 // implementation of PublishMetadata() with some additional parameters for test
 func implPublishMetadata(ctx context.Context, bundle *Bundle, publish bool, entriesPerFile uint) error {
+	if bundle.previewDiamondID != "" {
+		// bundle metadata is built on the fly from splits: there is no bundle metadata to publish
+		return populatePreview(bundle)
+	}
 	if bundle.BundleID == "" {
 		if bundle.ConsumableStore != nil {
 			if err := setBundleIDFromConsumableStore(ctx, bundle); err != nil {
@@ -268,6 +294,9 @@ func implUpload(ctx context.Context, bundle *Bundle, entriesPerFile uint, getKey
 	if err := RepoExists(bundle.RepoID, bundle.contextStores); err != nil {
 		return err
 	}
+	if err := checkACL(bundle.RepoID, bundle.contextStores, model.ACLWriter, bundle.BundleDescriptor.Contributors...); err != nil {
+		return err
+	}
 	if bundle.BundleID != "" {
 		// case of bundleID preservation
 		id, err := ksuid.Parse(bundle.BundleID)
@@ -375,13 +404,19 @@ func Update(ctx context.Context, bundleSrc, bundleDest *Bundle) (err error) {
 		}
 	}(time.Now())
 
+	p := newProgress(ProgressDownload, bundleSrc.progressFn)
+	defer func() {
+		p.finish(err)
+	}()
+
+	p.phase(PhaseMetadata, 0, 0)
 	if err = implPublishMetadata(ctx, bundleSrc, false, defaultBundleEntriesPerFile); err != nil {
 		return err
 	}
 	if err = implPublishMetadata(ctx, bundleDest, false, defaultBundleEntriesPerFile); err != nil {
 		return err
 	}
-	if err = unpackDataFiles(ctx, bundleSrc, bundleDest, nil); err != nil {
+	if err = unpackDataFiles(ctx, bundleSrc, bundleDest, nil, p); err != nil {
 		return err
 	}
 	return nil
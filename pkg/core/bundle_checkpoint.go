@@ -0,0 +1,177 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/model"
+)
+
+// uploadCheckpoint journals the entries of files uploaded for a bundle, one JSON entry per line.
+//
+// When an upload is interrupted, the next upload with the same checkpoint skips the files already uploaded.
+// Blobs are content-addressed: the resumed upload produces the same bundle entries, under a new bundle ID.
+//
+// Entries are journaled with the size and modification time of their file before it was uploaded:
+// files changed since are uploaded again.
+type uploadCheckpoint struct {
+	path string
+	file *os.File
+	w    *bufio.Writer
+}
+
+// openUploadCheckpoint opens a checkpoint journal, and yields the entries recorded by previous attempts.
+//
+// A partially written last line, left over by an interrupted upload, is discarded.
+func openUploadCheckpoint(path string) (*uploadCheckpoint, []model.BundleEntry, error) {
+	entries, valid, err := readUploadCheckpoint(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = file.Truncate(valid); err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+	if _, err = file.Seek(valid, io.SeekStart); err != nil {
+		_ = file.Close()
+		return nil, nil, err
+	}
+
+	return &uploadCheckpoint{
+		path: path,
+		file: file,
+		w:    bufio.NewWriter(file),
+	}, entries, nil
+}
+
+func readUploadCheckpoint(path string) ([]model.BundleEntry, int64, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var (
+		entries []model.BundleEntry
+		valid   int64
+	)
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// an incomplete line is discarded
+			return entries, valid, nil
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var entry model.BundleEntry
+		if erj := json.Unmarshal(bytes.TrimSpace(line), &entry); erj != nil || entry.NameWithPath == "" {
+			// a corrupted line ends the valid part of the journal
+			return entries, valid, nil
+		}
+		entries = append(entries, entry)
+		valid += int64(len(line))
+	}
+}
+
+// record appends an uploaded entry to the journal
+func (c *uploadCheckpoint) record(entry model.BundleEntry) error {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err = c.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+
+	// the journal is flushed after every entry: an interrupted upload loses at most the file being written
+	return c.w.Flush()
+}
+
+func (c *uploadCheckpoint) close() error {
+	if c.file == nil {
+		return nil
+	}
+	err := c.w.Flush()
+	if erc := c.file.Close(); err == nil {
+		err = erc
+	}
+	c.file = nil
+	return err
+}
+
+// remove closes then removes the journal, once the bundle is completely uploaded
+func (c *uploadCheckpoint) remove() error {
+	if err := c.close(); err != nil {
+		return err
+	}
+	return os.Remove(c.path)
+}
+
+// resumeFrom yields the files remaining to upload, and the entries already uploaded for the listed files
+func resumeFrom(files []string, entries []model.BundleEntry) ([]string, []model.BundleEntry) {
+	if len(entries) == 0 {
+		return files, nil
+	}
+
+	listed := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		listed[file] = struct{}{}
+	}
+
+	done := make(map[string]struct{}, len(entries))
+	resumed := make([]model.BundleEntry, 0, len(entries))
+	for _, entry := range entries {
+		if _, ok := listed[entry.NameWithPath]; !ok {
+			continue
+		}
+		if _, ok := done[entry.NameWithPath]; ok {
+			continue
+		}
+		done[entry.NameWithPath] = struct{}{}
+		resumed = append(resumed, entry)
+	}
+
+	remaining := make([]string, 0, len(files)-len(resumed))
+	for _, file := range files {
+		if _, ok := done[file]; !ok {
+			remaining = append(remaining, file)
+		}
+	}
+
+	return remaining, resumed
+}
+
+// stampFiles retrieves the size and modification time of the files to upload, before they are read.
+//
+// Files which attributes can't be retrieved are not stamped: their journaled entries are never reused.
+func stampFiles(ctx context.Context, bundle *Bundle, files []string) ([]SnapshotFile, map[string]time.Time) {
+	stamped := make([]SnapshotFile, 0, len(files))
+	modTimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		attrs, err := bundle.ConsumableStore.GetAttr(ctx, file)
+		if err != nil {
+			continue
+		}
+		stamped = append(stamped, SnapshotFile{Name: file, Size: attrs.Size, ModTime: attrs.Updated})
+		modTimes[file] = attrs.Updated
+	}
+	return stamped, modTimes
+}
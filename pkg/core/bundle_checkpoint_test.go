@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadCheckpoint(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-checkpoint-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+	path := filepath.Join(tmp, "checkpoint")
+
+	checkpoint, entries, err := openUploadCheckpoint(path)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	require.NoError(t, checkpoint.record(model.BundleEntry{NameWithPath: "a", Hash: "ha", Size: 1}))
+	require.NoError(t, checkpoint.record(model.BundleEntry{NameWithPath: "b", Hash: "hb", Size: 2}))
+	require.NoError(t, checkpoint.close())
+
+	// simulate an upload interrupted while recording an entry
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"hash":"hc","na`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	checkpoint, entries, err = openUploadCheckpoint(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "b", entries[1].NameWithPath)
+	assert.Equal(t, "hb", entries[1].Hash)
+	require.NoError(t, checkpoint.record(model.BundleEntry{NameWithPath: "c", Hash: "hc", Size: 3}))
+	require.NoError(t, checkpoint.close())
+
+	entries, _, err = readUploadCheckpoint(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "c", entries[2].NameWithPath)
+
+	remaining, resumed := resumeFrom([]string{"b", "c", "d"}, append(entries, entries[1]))
+	assert.Equal(t, []string{"d"}, remaining)
+	require.Len(t, resumed, 2, "entries are deduped and limited to the listed files")
+	assert.Equal(t, "b", resumed[0].NameWithPath)
+}
+
+func TestUploadWithCheckpoint(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-checkpoint-upload-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	const repo = "checkpoint-test-repo"
+	sourceDir, metaDir, blobDir := filepath.Join(tmp, "source"), filepath.Join(tmp, "meta"), filepath.Join(tmp, "blob")
+	files := []string{"a", "b", "dir/c", "dir/d", "e"}
+	for _, file := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(sourceDir, file)), 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, file), []byte("content of "+file), 0600))
+	}
+	stores := mocks.FakeContext(metaDir, blobDir)
+	require.NoError(t, CreateRepo(mocks.FakeRepoDescriptor(repo), stores))
+
+	stamp := func(t testing.TB, file string) (uint64, time.Time) {
+		info, err := os.Stat(filepath.Join(sourceDir, file))
+		require.NoError(t, err)
+		return uint64(info.Size()), info.ModTime()
+	}
+
+	// a previous attempt recorded some files
+	path := filepath.Join(tmp, "checkpoint")
+	checkpoint, _, err := openUploadCheckpoint(path)
+	require.NoError(t, err)
+	size, modTime := stamp(t, "dir/c")
+	resumedEntry := model.BundleEntry{NameWithPath: "dir/c", Hash: "resumed-hash", Size: size, ModTime: modTime}
+	require.NoError(t, checkpoint.record(resumedEntry))
+	require.NoError(t, checkpoint.record(model.BundleEntry{NameWithPath: "not-listed", Hash: "other-hash"}))
+
+	// files modified since they were recorded, or recorded without modification time, are uploaded again
+	size, modTime = stamp(t, "dir/d")
+	require.NoError(t, checkpoint.record(model.BundleEntry{NameWithPath: "dir/d", Hash: "resumed-hash", Size: size, ModTime: modTime.Add(-time.Minute)}))
+	size, _ = stamp(t, "e")
+	require.NoError(t, checkpoint.record(model.BundleEntry{NameWithPath: "e", Hash: "resumed-hash", Size: size}))
+	require.NoError(t, checkpoint.close())
+
+	bundle := NewBundle(
+		Repo(repo),
+		ContextStores(stores),
+		ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), sourceDir))),
+		Logger(mocks.TestLogger()),
+	)
+	require.NoError(t, UploadSpecificKeys(context.Background(), bundle, func() ([]string, error) {
+		return files, nil
+	}, WithUploadCheckpoint(path)))
+	assert.NoFileExists(t, path, "the checkpoint is removed once the bundle is uploaded")
+
+	uploaded := NewBundle(
+		Repo(repo),
+		BundleID(bundle.BundleID),
+		ContextStores(stores),
+	)
+	require.NoError(t, PopulateFiles(context.Background(), uploaded))
+
+	names := make([]string, 0, len(uploaded.BundleEntries))
+	for _, entry := range uploaded.BundleEntries {
+		names = append(names, entry.NameWithPath)
+		assert.True(t, entry.ModTime.IsZero(), "modification times are only journaled")
+		if entry.NameWithPath == resumedEntry.NameWithPath {
+			assert.Equal(t, resumedEntry.Hash, entry.Hash, "files recorded in the checkpoint are not uploaded again")
+		} else {
+			assert.NotEqual(t, resumedEntry.Hash, entry.Hash)
+		}
+	}
+	sort.Strings(names)
+	assert.Equal(t, files, names)
+}
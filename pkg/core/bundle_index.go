@@ -0,0 +1,73 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/core/status"
+	"github.com/oneconcern/datamon/pkg/model"
+	"gopkg.in/yaml.v2"
+)
+
+// DownloadDescriptor retrieves the descriptor of a bundle, without its list of files.
+//
+// The file index may then be retrieved one chunk at a time with DownloadIndexChunk.
+//
+// When previewing a diamond, the list of files is built on the fly from the splits and set in BundleEntries.
+func DownloadDescriptor(ctx context.Context, bundle *Bundle) (err error) {
+	defer func(t0 time.Time) {
+		if bundle.MetricsEnabled() {
+			bundle.m.Usage.UsedAll(t0, "DownloadDescriptor")(err)
+		}
+	}(time.Now())
+
+	if bundle.previewDiamondID != "" {
+		return populatePreview(bundle)
+	}
+
+	if bundle.BundleID == "" {
+		if bundle.ConsumableStore == nil {
+			return status.ErrNoBundleIDWithConsumable
+		}
+		if err = setBundleIDFromConsumableStore(ctx, bundle); err != nil {
+			return err
+		}
+	}
+
+	return unpackBundleDescriptor(ctx, bundle, false)
+}
+
+// DownloadIndexChunk retrieves the file entries listed in one chunk of the file index of a bundle.
+//
+// Chunks are numbered from 0 to BundleDescriptor.BundleEntriesFileCount-1: the descriptor must be known
+// before calling this function.
+func DownloadIndexChunk(ctx context.Context, bundle *Bundle, chunk uint64) ([]model.BundleEntry, error) {
+	if chunk >= bundle.BundleDescriptor.BundleEntriesFileCount {
+		return nil, fmt.Errorf("index chunk %d out of range: bundle %s has %d index chunks",
+			chunk, bundle.BundleID, bundle.BundleDescriptor.BundleEntriesFileCount)
+	}
+
+	var (
+		rdr io.ReadCloser
+		err error
+	)
+	if bundle.MetaStore() != nil {
+		rdr, err = bundle.MetaStore().Get(ctx, model.GetArchivePathToBundleFileList(bundle.RepoID, bundle.BundleID, chunk))
+	} else {
+		rdr, err = bundle.ConsumableStore.Get(ctx, model.GetConsumablePathToBundleFileList(bundle.BundleID, chunk))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rdr.Close()
+	}()
+
+	var bundleEntries model.BundleEntries
+	if err = yaml.NewDecoder(rdr).Decode(&bundleEntries); err != nil {
+		return nil, err
+	}
+	return bundleEntries.BundleEntries, nil
+}
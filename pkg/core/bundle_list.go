@@ -357,6 +357,19 @@ func getBundleAsync(repo string, store storage.Store, input <-chan string, outpu
 
 // GetLatestBundle returns the latest bundle descriptor from a repo
 func GetLatestBundle(repo string, stores context2.Stores) (string, error) {
+	bundleID, err := latestBundleID(repo, stores)
+	if err != nil {
+		return "", err
+	}
+	if bundleID == "" {
+		return "", fmt.Errorf("no bundles uploaded to repo: %s", repo)
+	}
+
+	return bundleID, nil
+}
+
+// latestBundleID returns the ID of the latest bundle from a repo, or an empty ID if the repo has no bundles
+func latestBundleID(repo string, stores context2.Stores) (string, error) {
 	e := RepoExists(repo, stores)
 	if e != nil {
 		return "", e
@@ -366,7 +379,7 @@ func GetLatestBundle(repo string, stores context2.Stores) (string, error) {
 		return "", err
 	}
 	if len(ks) == 0 {
-		return "", fmt.Errorf("no bundles uploaded to repo: %s", repo)
+		return "", nil
 	}
 
 	apc, err := model.GetArchivePathComponents(ks[len(ks)-1])
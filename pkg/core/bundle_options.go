@@ -1,6 +1,7 @@
 package core
 
 import (
+	"github.com/oneconcern/datamon/pkg/cafs"
 	context2 "github.com/oneconcern/datamon/pkg/context"
 	"github.com/oneconcern/datamon/pkg/model"
 	"github.com/oneconcern/datamon/pkg/storage"
@@ -47,6 +48,15 @@ func BundleID(bID string) BundleOption {
 	}
 }
 
+// BundlePreviewDiamond builds a read-only preview of the bundle which would result from committing a diamond.
+//
+// The bundle entries are merged from all the splits done so far. Such a bundle has no ID and is not meant to be uploaded.
+func BundlePreviewDiamond(diamondID string) BundleOption {
+	return func(b *Bundle) {
+		b.previewDiamondID = diamondID
+	}
+}
+
 // SkipMissing indicates that bundle retrieval errors should be ignored. Currently not implemented.
 func SkipMissing(s bool) BundleOption {
 	return func(b *Bundle) {
@@ -103,6 +113,20 @@ func BundleWithVerifyHash(enabled bool) BundleOption {
 	}
 }
 
+// BundleWithLeafCache sets a persistent cache on local disk for blobs retrieved when downloading files.
+func BundleWithLeafCache(cache *cafs.LeafCache) BundleOption {
+	return func(b *Bundle) {
+		b.leafCache = cache
+	}
+}
+
+// BundleWithProgress reports the progress of file uploads and downloads as events sent to a callback.
+func BundleWithProgress(fn ProgressFunc) BundleOption {
+	return func(b *Bundle) {
+		b.progressFn = fn
+	}
+}
+
 // BundleWithVerifyBlob toggles root key verification when uploading (enabled by default).
 func BundleWithVerifyBlobHash(enabled bool) BundleOption {
 	return func(b *Bundle) {
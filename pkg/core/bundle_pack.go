@@ -18,6 +18,8 @@ import (
 
 	"github.com/oneconcern/datamon/pkg/cafs"
 	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -54,7 +56,15 @@ type uploadBundleChans struct {
 	concurrencyControl <-chan struct{}
 }
 
-func uploadBundleEntriesFileList(ctx context.Context, bundle *Bundle, fileList []model.BundleEntry) error {
+func uploadBundleEntriesFileList(ctx context.Context, bundle *Bundle, fileList []model.BundleEntry) (err error) {
+	ctx, span := tracing.Start(ctx, "core.UploadFileList",
+		attribute.Int("index", int(bundle.BundleDescriptor.BundleEntriesFileCount)),
+		attribute.Int("entries", len(fileList)),
+	)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	buffer, err := yaml.Marshal(model.BundleEntries{
 		BundleEntries: fileList,
 	})
@@ -120,14 +130,18 @@ func uploadBundleFile(
 		zap.String("filename", file),
 	)
 
+	ctx, span := tracing.Start(ctx, "core.UploadFile", attribute.String("file", file))
 	putRes, e := cafsArchive.Put(ctx, fileReader)
 	if e != nil {
+		tracing.End(span, e)
 		chans.error <- errorHit{
 			error: e,
 			file:  file,
 		}
 		return
 	}
+	span.SetAttributes(attribute.Int64("size", putRes.Written), attribute.Bool("duplicate", putRes.Found))
+	tracing.End(span, nil)
 
 	chans.filePacked <- filePacked{
 		hash:      putRes.Key.String(),
@@ -200,11 +214,20 @@ func uploadBundleFiles(
 	chans.doneOk <- struct{}{}
 }
 
-func uploadBundle(ctx context.Context, bundle *Bundle, bundleEntriesPerFile uint, getKeys func() ([]string, error), opts ...Option) error {
+func uploadBundle(ctx context.Context, bundle *Bundle, bundleEntriesPerFile uint, getKeys func() ([]string, error), opts ...Option) (err error) {
+	ctx, span := tracing.Start(ctx, "core.UploadBundle", attribute.String("repo", bundle.RepoID))
+	p := newProgress(ProgressUpload, bundle.progressFn)
+	defer func() {
+		span.SetAttributes(attribute.String("bundle", bundle.BundleID))
+		tracing.End(span, err)
+		p.finish(err)
+	}()
+
 	settings := defaultSettings()
 	for _, apply := range opts {
 		apply(&settings)
 	}
+	p.phase(PhaseList, 0, 0)
 
 	// Walk the entire tree
 	// TODO: #53 handle large file count
@@ -222,6 +245,47 @@ func uploadBundle(ctx context.Context, bundle *Bundle, bundleEntriesPerFile uint
 		bundle.l.Warn("Uploading bundle with 0 files")
 	}
 
+	// entries known from a previous bundle or a checkpoint are recorded without uploading their files again
+	var (
+		checkpoint *uploadCheckpoint
+		stamps     map[string]time.Time
+	)
+	resumed := settings.reused
+	if settings.checkpoint != "" {
+		var recorded []model.BundleEntry
+		checkpoint, recorded, err = openUploadCheckpoint(settings.checkpoint)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = checkpoint.close()
+		}()
+
+		// files changed since they were recorded are uploaded again
+		var current []SnapshotFile
+		current, stamps = stampFiles(ctx, bundle, files)
+		recorded = unchangedEntries(recorded, current)
+		for i := range recorded {
+			if settings.modTimes == nil {
+				// modification times are only journaled, unless uploading a snapshot
+				recorded[i].ModTime = time.Time{}
+			}
+		}
+
+		resumed = append(resumed[:len(resumed):len(resumed)], recorded...)
+	}
+
+	files, resumed = resumeFrom(files, resumed)
+	if len(resumed) > 0 {
+		bundle.l.Info("reusing entries of files already uploaded",
+			zap.String("checkpoint", settings.checkpoint),
+			zap.Int("uploaded files", len(resumed)),
+			zap.Int("remaining files", len(files)),
+		)
+	}
+
+	p.phase(PhaseUpload, len(files), uploadSize(ctx, bundle, files, p))
+
 	cafsArchive, err := cafs.New(
 		cafs.LeafSize(bundle.BundleDescriptor.LeafSize),
 		cafs.Backend(bundle.BlobStore()),
@@ -267,7 +331,27 @@ func uploadBundle(ctx context.Context, bundle *Bundle, bundleEntriesPerFile uint
 		totalSize          uint64
 	)
 
+	bundle.BundleDescriptor.FileCount, bundle.BundleDescriptor.Size = 0, 0
 	fileList := make([]model.BundleEntry, 0, bundleEntriesPerFile)
+	appendEntry := func(entry model.BundleEntry) error {
+		fileList = append(fileList, entry)
+		bundle.BundleDescriptor.FileCount++
+		bundle.BundleDescriptor.Size += entry.Size
+		// Write the bundle entry file if reached max or the last one
+		if len(fileList) < int(bundleEntriesPerFile) {
+			return nil
+		}
+		bundle.l.Debug("Uploading filelist (max entries reached)")
+		if erf := uploadBundleEntriesFileList(ctx, bundle, fileList); erf != nil {
+			bundle.l.Error("Bundle upload failed.  Failed to upload bundle entries list.",
+				zap.Error(erf),
+			)
+			return erf
+		}
+		numFileListUploads++
+		fileList = fileList[:0]
+		return nil
+	}
 
 	t0 := time.Now()
 	defer func() {
@@ -277,6 +361,13 @@ func uploadBundle(ctx context.Context, bundle *Bundle, bundleEntriesPerFile uint
 		}
 	}()
 
+	for _, entry := range resumed {
+		totalSize += entry.Size
+		if err = appendEntry(entry); err != nil {
+			return err
+		}
+	}
+
 	for {
 		var gotDoneSignal bool
 		select {
@@ -290,19 +381,20 @@ func uploadBundle(ctx context.Context, bundle *Bundle, bundleEntriesPerFile uint
 				zap.Int("idx", f.idx),
 			)
 			totalSize += f.size
-			fileList = append(fileList, filePacked2BundleEntry(f))
-			// Write the bundle entry file if reached max or the last one
-			if len(fileList) == int(bundleEntriesPerFile) {
-				bundle.l.Debug("Uploading filelist (max entries reached)")
-				err = uploadBundleEntriesFileList(ctx, bundle, fileList)
-				if err != nil {
-					bundle.l.Error("Bundle upload failed.  Failed to upload bundle entries list.",
-						zap.Error(err),
-					)
+			p.add(f.name, f.size)
+			entry := filePacked2BundleEntry(f)
+			if settings.modTimes != nil {
+				entry.ModTime = settings.modTimes[f.name]
+			}
+			if checkpoint != nil {
+				journaled := entry
+				journaled.ModTime = stamps[f.name]
+				if err = checkpoint.record(journaled); err != nil {
 					return err
 				}
-				numFileListUploads++
-				fileList = fileList[:0]
+			}
+			if err = appendEntry(entry); err != nil {
+				return err
 			}
 		case e := <-errorC:
 			bundle.l.Error("Bundle upload failed. Failed to upload file",
@@ -318,6 +410,7 @@ func uploadBundle(ctx context.Context, bundle *Bundle, bundleEntriesPerFile uint
 			break
 		}
 	}
+	p.phase(PhaseMetadata, 0, 0)
 	if len(fileList) != 0 {
 		bundle.l.Debug("Uploading filelist (final)")
 		err = uploadBundleEntriesFileList(ctx, bundle, fileList)
@@ -338,12 +431,35 @@ func uploadBundle(ctx context.Context, bundle *Bundle, bundleEntriesPerFile uint
 	if err != nil {
 		return err
 	}
+	if checkpoint != nil {
+		if err = checkpoint.remove(); err != nil {
+			bundle.l.Warn("could not remove upload checkpoint", zap.String("checkpoint", settings.checkpoint), zap.Error(err))
+		}
+	}
 	bundle.l.Info("Uploaded bundle id",
 		zap.String("BundleID", bundle.BundleID),
 	)
 	return nil
 }
 
+// uploadSize sums up the sizes of the files to upload, whenever progress is reported.
+//
+// The total is left unknown if the size of some file is not available.
+func uploadSize(ctx context.Context, bundle *Bundle, files []string, p *progress) uint64 {
+	if p == nil {
+		return 0
+	}
+	var total uint64
+	for _, file := range files {
+		attrs, err := bundle.ConsumableStore.GetAttr(ctx, file)
+		if err != nil {
+			return 0
+		}
+		total += uint64(attrs.Size)
+	}
+	return total
+}
+
 func validateBundle(bundle *Bundle) bool {
 	if bundle.BundleDescriptor.Deduplication == "" {
 		bundle.l.Error("failed to validate bundle, Deduplication scheme not set")
@@ -352,7 +468,15 @@ func validateBundle(bundle *Bundle) bool {
 	return true
 }
 
-func uploadBundleDescriptor(ctx context.Context, bundle *Bundle) error {
+func uploadBundleDescriptor(ctx context.Context, bundle *Bundle) (err error) {
+	ctx, span := tracing.Start(ctx, "core.UploadDescriptor",
+		attribute.String("repo", bundle.RepoID),
+		attribute.String("bundle", bundle.BundleID),
+	)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	if !validateBundle(bundle) {
 		return fmt.Errorf("failed to validate bundle")
 	}
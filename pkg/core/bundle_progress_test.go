@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type progressRecorder struct {
+	mx     sync.Mutex
+	events []ProgressEvent
+}
+
+func (r *progressRecorder) record(event ProgressEvent) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.events = append(r.events, event)
+}
+
+// phases lists the successive phases reported, and the last event of each phase
+func (r *progressRecorder) phases() ([]string, map[string]ProgressEvent) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	var phases []string
+	last := make(map[string]ProgressEvent)
+	for _, event := range r.events {
+		if len(phases) == 0 || phases[len(phases)-1] != event.Phase {
+			phases = append(phases, event.Phase)
+		}
+		last[event.Phase] = event
+	}
+	return phases, last
+}
+
+func TestBundleProgress(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-progress-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	const repo = "progress-test-repo"
+	sourceDir, destDir := filepath.Join(tmp, "source"), filepath.Join(tmp, "dest")
+	stores := mocks.FakeContext(filepath.Join(tmp, "meta"), filepath.Join(tmp, "blob"))
+	require.NoError(t, CreateRepo(mocks.FakeRepoDescriptor(repo), stores))
+	require.NoError(t, os.MkdirAll(sourceDir, 0700))
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, name), []byte("content of "+name), 0600))
+	}
+	require.NoError(t, os.MkdirAll(destDir, 0700))
+
+	var uploads progressRecorder
+	bundle := NewBundle(
+		Repo(repo),
+		ContextStores(stores),
+		ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), sourceDir))),
+		Logger(mocks.TestLogger()),
+		BundleWithProgress(uploads.record),
+	)
+	require.NoError(t, Upload(context.Background(), bundle))
+
+	phases, last := uploads.phases()
+	assert.Equal(t, []string{PhaseList, PhaseUpload, PhaseMetadata, PhaseDone}, phases)
+	upload := last[PhaseUpload]
+	assert.Equal(t, ProgressUpload, upload.Operation)
+	assert.EqualValues(t, 3, upload.Files)
+	assert.EqualValues(t, 3, upload.TotalFiles)
+	assert.EqualValues(t, 3*len("content of a"), upload.Bytes)
+	assert.Equal(t, upload.Bytes, upload.TotalBytes)
+
+	var downloads progressRecorder
+	published := NewBundle(
+		Repo(repo),
+		BundleID(bundle.BundleID),
+		ContextStores(stores),
+		ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), destDir))),
+		Logger(mocks.TestLogger()),
+		BundleWithProgress(downloads.record),
+	)
+	require.NoError(t, PublishSelectBundleEntries(context.Background(), published, func(name string) (bool, error) {
+		return name != "c", nil
+	}))
+
+	phases, last = downloads.phases()
+	assert.Equal(t, []string{PhaseMetadata, PhaseDownload, PhaseDone}, phases)
+	download := last[PhaseDownload]
+	assert.Equal(t, ProgressDownload, download.Operation)
+	assert.EqualValues(t, 2, download.Files, "only selected files are reported")
+	assert.EqualValues(t, 2, download.TotalFiles)
+	assert.EqualValues(t, 2*len("content of a"), download.TotalBytes)
+	assert.Equal(t, download.TotalBytes, download.Bytes)
+
+	var failed progressRecorder
+	missing := NewBundle(
+		Repo(repo),
+		BundleID("missing"),
+		ContextStores(stores),
+		ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), destDir))),
+		Logger(mocks.TestLogger()),
+		BundleWithProgress(failed.record),
+	)
+	require.Error(t, Publish(context.Background(), missing))
+
+	phases, last = failed.phases()
+	assert.Equal(t, []string{PhaseMetadata, PhaseFailed}, phases)
+	assert.NotEmpty(t, last[PhaseFailed].Error)
+}
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/model"
+	"go.uber.org/zap"
+)
+
+type (
+	// SnapshotFile describes a file found on the file system, to be compared with the entries of a previous bundle
+	SnapshotFile struct {
+		Name    string
+		Size    int64
+		ModTime time.Time
+	}
+
+	// SnapshotStats reports about the files of a snapshot
+	SnapshotStats struct {
+		Previous  string // the ID of the bundle compared with, if any
+		Unchanged int    // files which entries are taken from the previous bundle
+		Changed   int    // new or modified files, uploaded again
+	}
+)
+
+// Snapshot uploads a bundle holding all the listed files of a bundle's consumable store.
+//
+// Files are compared with the entries of a previous bundle: a file with the same size and modification time
+// as recorded in the previous bundle is not read again, and its entry is copied to the new bundle.
+// Only new or changed files are hashed and uploaded.
+//
+// Without a previous bundle ID, the latest bundle of the repo is used. All files are uploaded if the repo has no bundles yet.
+// Bundles not uploaded as snapshots record no modification time: all files are considered changed.
+func Snapshot(ctx context.Context, bundle *Bundle, previousID string, files []SnapshotFile, opts ...Option) (stats SnapshotStats, err error) {
+	defer func(t0 time.Time) {
+		if bundle.MetricsEnabled() {
+			bundle.m.Usage.UsedAll(t0, "Snapshot")(err)
+		}
+	}(time.Now())
+
+	if previousID == "" {
+		if previousID, err = latestBundleID(bundle.RepoID, bundle.contextStores); err != nil {
+			return stats, err
+		}
+	}
+	stats.Previous = previousID
+
+	var reused []model.BundleEntry
+	if previousID != "" {
+		previous := NewBundle(
+			Repo(bundle.RepoID),
+			BundleID(previousID),
+			ContextStores(bundle.contextStores),
+			Logger(bundle.l),
+		)
+		if err = PopulateFiles(ctx, previous); err != nil {
+			return stats, err
+		}
+		reused = unchangedEntries(previous.BundleEntries, files)
+	}
+
+	names := make([]string, 0, len(files))
+	modTimes := make(map[string]time.Time, len(files))
+	for _, file := range files {
+		names = append(names, file.Name)
+		modTimes[file.Name] = file.ModTime
+	}
+	stats.Unchanged = len(reused)
+	stats.Changed = len(files) - len(reused)
+
+	bundle.l.Info("uploading snapshot",
+		zap.String("previous bundle", previousID),
+		zap.Int("unchanged files", stats.Unchanged),
+		zap.Int("changed files", stats.Changed),
+	)
+
+	opts = append(opts, func(s *Settings) {
+		s.reused = reused
+		s.modTimes = modTimes
+	})
+	err = implUpload(ctx, bundle, defaultBundleEntriesPerFile, func() ([]string, error) {
+		return names, nil
+	}, opts...)
+
+	return stats, err
+}
+
+// unchangedEntries selects the entries of a previous bundle for files with the same size and modification time
+func unchangedEntries(entries []model.BundleEntry, files []SnapshotFile) []model.BundleEntry {
+	previous := make(map[string]model.BundleEntry, len(entries))
+	for _, entry := range entries {
+		if !entry.ModTime.IsZero() {
+			previous[entry.NameWithPath] = entry
+		}
+	}
+
+	unchanged := make([]model.BundleEntry, 0, len(previous))
+	for _, file := range files {
+		entry, ok := previous[file.Name]
+		if !ok || entry.Size != uint64(file.Size) || !entry.ModTime.Equal(file.ModTime) {
+			continue
+		}
+		unchanged = append(unchanged, entry)
+	}
+
+	return unchanged
+}
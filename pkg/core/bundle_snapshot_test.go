@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnchangedEntries(t *testing.T) {
+	mtime := time.Now().Truncate(time.Second)
+	entries := []model.BundleEntry{
+		{NameWithPath: "same", Size: 1, ModTime: mtime},
+		{NameWithPath: "resized", Size: 1, ModTime: mtime},
+		{NameWithPath: "touched", Size: 1, ModTime: mtime},
+		{NameWithPath: "no-mtime", Size: 1},
+		{NameWithPath: "removed", Size: 1, ModTime: mtime},
+	}
+	files := []SnapshotFile{
+		{Name: "same", Size: 1, ModTime: mtime.In(time.UTC)},
+		{Name: "resized", Size: 2, ModTime: mtime},
+		{Name: "touched", Size: 1, ModTime: mtime.Add(time.Second)},
+		{Name: "no-mtime", Size: 1},
+		{Name: "new", Size: 1, ModTime: mtime},
+	}
+
+	unchanged := unchangedEntries(entries, files)
+	require.Len(t, unchanged, 1)
+	assert.Equal(t, "same", unchanged[0].NameWithPath)
+}
+
+func TestSnapshot(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-snapshot-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	const repo = "snapshot-test-repo"
+	sourceDir, metaDir, blobDir := filepath.Join(tmp, "source"), filepath.Join(tmp, "meta"), filepath.Join(tmp, "blob")
+	stores := mocks.FakeContext(metaDir, blobDir)
+	require.NoError(t, CreateRepo(mocks.FakeRepoDescriptor(repo), stores))
+
+	mtime := time.Now().Add(-time.Hour).Truncate(time.Second)
+	write := func(name, content string, modTime time.Time) SnapshotFile {
+		path := filepath.Join(sourceDir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+		return SnapshotFile{Name: name, Size: int64(len(content)), ModTime: modTime}
+	}
+	// bundles uploaded within the same second are not ordered: the previous bundle is explicit, except for the first snapshot
+	var previous string
+	snapshot := func(files []SnapshotFile) (SnapshotStats, map[string]model.BundleEntry) {
+		bundle := NewBundle(
+			Repo(repo),
+			ContextStores(stores),
+			ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), sourceDir))),
+			Logger(mocks.TestLogger()),
+		)
+		stats, err := Snapshot(context.Background(), bundle, previous, files)
+		require.NoError(t, err)
+		assert.Equal(t, previous, stats.Previous)
+		previous = bundle.BundleID
+
+		uploaded := NewBundle(
+			Repo(repo),
+			BundleID(bundle.BundleID),
+			ContextStores(stores),
+		)
+		require.NoError(t, PopulateFiles(context.Background(), uploaded))
+		entries := make(map[string]model.BundleEntry, len(uploaded.BundleEntries))
+		for _, entry := range uploaded.BundleEntries {
+			entries[entry.NameWithPath] = entry
+		}
+		return stats, entries
+	}
+
+	files := []SnapshotFile{
+		write("a", "content of a", mtime),
+		write("dir/b", "content of b", mtime),
+		write("dir/c", "content of c", mtime),
+	}
+	stats, first := snapshot(files)
+	assert.Equal(t, SnapshotStats{Changed: 3}, stats, "the first snapshot uploads all files")
+	require.Len(t, first, 3)
+	assert.True(t, mtime.Equal(first["a"].ModTime), "entries record the modification time")
+
+	// a file with the same size and modification time is not read again: alter its content to prove it
+	files[1] = write("dir/b", "CONTENT of b", mtime)
+	files[2] = write("dir/c", "changed content of c", mtime.Add(time.Minute))
+	files = append(files, write("d", "content of d", mtime))
+	stats, second := snapshot(files)
+	assert.Equal(t, 2, stats.Unchanged)
+	assert.Equal(t, 2, stats.Changed)
+	require.Len(t, second, 4, "a snapshot is a full bundle")
+	assert.Equal(t, first["a"].Hash, second["a"].Hash)
+	assert.Equal(t, first["dir/b"].Hash, second["dir/b"].Hash, "unchanged files are not hashed again")
+	assert.NotEqual(t, first["dir/c"].Hash, second["dir/c"].Hash, "changed files are hashed again")
+	assert.True(t, mtime.Add(time.Minute).Equal(second["dir/c"].ModTime))
+	assert.Contains(t, second, "d")
+
+	// files removed from the file system are not part of the next snapshot
+	stats, third := snapshot(files[2:])
+	assert.Equal(t, 2, stats.Unchanged)
+	assert.Zero(t, stats.Changed)
+	assert.Len(t, third, 2)
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+	"github.com/oneconcern/datamon/pkg/tracing"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestBundleTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	tmp, err := ioutil.TempDir("", "test-tracing-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	const repo = "tracing-test-repo"
+	sourceDir, destDir := filepath.Join(tmp, "source"), filepath.Join(tmp, "dest")
+	stores := mocks.FakeContext(filepath.Join(tmp, "meta"), filepath.Join(tmp, "blob"))
+	require.NoError(t, CreateRepo(mocks.FakeRepoDescriptor(repo), stores))
+	for _, name := range []string{"a", "b"} {
+		require.NoError(t, os.MkdirAll(sourceDir, 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(sourceDir, name), []byte("content of "+name), 0600))
+	}
+	require.NoError(t, os.MkdirAll(destDir, 0700))
+
+	ctx, root := tracing.Start(context.Background(), "test")
+	bundle := NewBundle(
+		Repo(repo),
+		ContextStores(stores),
+		ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), sourceDir))),
+		Logger(mocks.TestLogger()),
+	)
+	require.NoError(t, Upload(ctx, bundle))
+
+	published := NewBundle(
+		Repo(repo),
+		BundleID(bundle.BundleID),
+		ContextStores(stores),
+		ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), destDir))),
+		Logger(mocks.TestLogger()),
+	)
+	require.NoError(t, Publish(ctx, published))
+	root.End()
+
+	// spans of concurrent tests are ignored
+	spans := make(map[string][]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() == root.SpanContext().TraceID() {
+			spans[span.Name()] = append(spans[span.Name()], span)
+		}
+	}
+	parentOf := func(span sdktrace.ReadOnlySpan) string {
+		for name, candidates := range spans {
+			for _, candidate := range candidates {
+				if candidate.SpanContext().SpanID() == span.Parent().SpanID() {
+					return name
+				}
+			}
+		}
+		return ""
+	}
+
+	require.Len(t, spans["core.UploadBundle"], 1)
+	assert.Equal(t, "test", parentOf(spans["core.UploadBundle"][0]))
+	require.Len(t, spans["core.UploadFile"], 2, "one span per uploaded file")
+	for _, span := range spans["core.UploadFile"] {
+		assert.Equal(t, "core.UploadBundle", parentOf(span), "the context is propagated to upload goroutines")
+	}
+	require.NotEmpty(t, spans["cafs.PutLeaf"])
+	assert.Equal(t, "cafs.Put", parentOf(spans["cafs.PutLeaf"][0]))
+	require.Len(t, spans["core.UploadFileList"], 1)
+	require.Len(t, spans["core.UploadDescriptor"], 1)
+
+	require.Len(t, spans["core.DownloadBundle"], 1)
+	require.Len(t, spans["core.DownloadFileList"], 1)
+	assert.Equal(t, "core.DownloadFileLists", parentOf(spans["core.DownloadFileList"][0]))
+	require.Len(t, spans["core.DownloadFile"], 2, "one span per downloaded file")
+	for _, span := range spans["core.DownloadFile"] {
+		assert.Equal(t, "core.DownloadFiles", parentOf(span), "the context is propagated to download goroutines")
+	}
+	require.NotEmpty(t, spans["cafs.FetchLeaf"])
+}
@@ -13,7 +13,9 @@ import (
 	"github.com/oneconcern/datamon/pkg/core/status"
 	"github.com/oneconcern/datamon/pkg/model"
 	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/oneconcern/datamon/pkg/tracing"
 
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 	"gopkg.in/yaml.v2"
 )
@@ -100,8 +102,15 @@ func getConsumableStoreMetadataKeysInfo(ctx context.Context, bundle *Bundle) (co
 	}, nil
 }
 
-func unpackBundleDescriptor(ctx context.Context, bundle *Bundle, publish bool) error {
-	var err error
+func unpackBundleDescriptor(ctx context.Context, bundle *Bundle, publish bool) (err error) {
+	ctx, span := tracing.Start(ctx, "core.DownloadDescriptor",
+		attribute.String("repo", bundle.RepoID),
+		attribute.String("bundle", bundle.BundleID),
+	)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	var bundleDescriptorBuffer []byte
 	var rdr io.Reader
 	switch {
@@ -176,7 +185,9 @@ func downloadBundleFileListFile(ctx context.Context, bundle *Bundle,
 	var bundleEntriesBuffer []byte
 	var rdr io.Reader
 
+	ctx, span := tracing.Start(ctx, "core.DownloadFileList", attribute.Int64("index", int64(i)))
 	sendErr := func(err error) {
+		tracing.End(span, err)
 		chans.error <- err
 	}
 	defer func() {
@@ -232,6 +243,8 @@ func downloadBundleFileListFile(ctx context.Context, bundle *Bundle,
 		sendErr(err)
 		return
 	}
+	span.SetAttributes(attribute.Int("entries", len(bundleEntries.BundleEntries)))
+	tracing.End(span, nil)
 	chans.bundleEntries <- bundleEntriesRes{bundleEntries: bundleEntries, idx: i}
 }
 
@@ -255,7 +268,13 @@ func downloadBundleFileList(ctx context.Context, bundle *Bundle,
 func unpackBundleFileList(ctx context.Context, bundle *Bundle,
 	publish bool,
 	bundleEntriesPerFile uint,
-) error {
+) (err error) {
+	ctx, span := tracing.Start(ctx, "core.DownloadFileLists",
+		attribute.Int64("filelists", int64(bundle.BundleDescriptor.BundleEntriesFileCount)),
+	)
+	defer func() {
+		tracing.End(span, err)
+	}()
 
 	bundle.l.Info("kicking off filelist download",
 		zap.Int("concurrent Filelist Downloads", bundle.concurrentFilelistDownloads),
@@ -317,12 +336,21 @@ type downloadBundleChans struct {
 	error              chan<- errorHit
 	doneOk             chan<- struct{}
 	concurrencyControl <-chan struct{}
+	progress           *progress
 }
 
 func downloadBundleEntrySyncMaybeOverwrite(ctx context.Context, bundleEntry model.BundleEntry,
 	bundle *Bundle,
 	fs cafs.Fs,
-	overwrite bool) error {
+	overwrite bool) (err error) {
+	ctx, span := tracing.Start(ctx, "core.DownloadFile",
+		attribute.String("file", bundleEntry.NameWithPath),
+		attribute.Int64("size", int64(bundleEntry.Size)),
+	)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	bundle.l.Info("starting bundle entry download",
 		zap.String("name", bundleEntry.NameWithPath))
 
@@ -399,6 +427,7 @@ func downloadBundleEntry(ctx context.Context, bundleEntry model.BundleEntry,
 		reportError(err)
 		return
 	}
+	chans.progress.add(bundleEntry.NameWithPath, bundleEntry.Size)
 }
 
 // dupe: deleteBundleEntry
@@ -420,6 +449,7 @@ func downloadBundleEntryOverwrite(ctx context.Context, bundleEntry model.BundleE
 		reportError(err)
 		return
 	}
+	chans.progress.add(bundleEntry.NameWithPath, bundleEntry.Size)
 }
 
 func deleteBundleEntry(ctx context.Context, bundleEntry model.BundleEntry,
@@ -483,26 +513,34 @@ func downloadBundleEntries(ctx context.Context, bundle *Bundle,
 		indices = int64(len(bundle.BundleEntries))
 		bundle.l.Info("downloading bundle entries",
 			zap.Int("num", len(bundle.BundleEntries)))
-		for _, b := range bundle.BundleEntries {
-			var selectionPredicateOk bool
-			if selectionPredicate != nil {
+		selected := bundle.BundleEntries
+		if selectionPredicate != nil {
+			selected = make([]model.BundleEntry, 0, len(bundle.BundleEntries))
+			for _, b := range bundle.BundleEntries {
+				var selectionPredicateOk bool
 				selectionPredicateOk, err = selectionPredicate(b.NameWithPath)
 				if err != nil {
 					reportError(err)
-					break
+					return
+				}
+				if selectionPredicateOk {
+					selected = append(selected, b)
 				}
-			}
-			if selectionPredicate == nil || selectionPredicateOk {
-				concurrencyControl <- struct{}{}
-				files++
-				totalSize += b.Size
-				go downloadBundleEntry(ctx, b, bundle, fs, chans)
 			}
 		}
+		chans.progress.phase(PhaseDownload, len(selected), entriesSize(selected))
+		for _, b := range selected {
+			concurrencyControl <- struct{}{}
+			files++
+			totalSize += b.Size
+			go downloadBundleEntry(ctx, b, bundle, fs, chans)
+		}
 	} else {
 		indices = int64(len(diff.Entries))
 		bundle.l.Info("downloading diff entries",
 			zap.Int("num", len(diff.Entries)))
+		downloads, downloadSize := diffDownloads(diff)
+		chans.progress.phase(PhaseDownload, downloads, downloadSize)
 		for _, de := range diff.Entries {
 			concurrencyControl <- struct{}{}
 			switch de.Type {
@@ -538,9 +576,39 @@ func downloadBundleEntries(ctx context.Context, bundle *Bundle,
 	chans.doneOk <- struct{}{}
 }
 
+// entriesSize sums up the sizes of bundle entries
+func entriesSize(entries []model.BundleEntry) uint64 {
+	var total uint64
+	for _, entry := range entries {
+		total += entry.Size
+	}
+	return total
+}
+
+// diffDownloads counts the files to download to update a bundle, and their total size
+func diffDownloads(diff BundleDiff) (int, uint64) {
+	var (
+		count int
+		total uint64
+	)
+	for _, de := range diff.Entries {
+		if de.Type == DiffEntryTypeAdd || de.Type == DiffEntryTypeDif {
+			count++
+			total += de.Additional.Size
+		}
+	}
+	return count, total
+}
+
 func unpackDataFiles(ctx context.Context, bundle *Bundle,
 	bundleDest *Bundle,
-	selectionPredicate func(string) (bool, error)) error {
+	selectionPredicate func(string) (bool, error),
+	p *progress) (err error) {
+	ctx, span := tracing.Start(ctx, "core.DownloadFiles")
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	fs, err := cafs.New(
 		cafs.LeafSize(bundle.BundleDescriptor.LeafSize),
 		cafs.LeafTruncation(bundle.BundleDescriptor.Version < 1),
@@ -549,6 +617,7 @@ func unpackDataFiles(ctx context.Context, bundle *Bundle,
 		cafs.Logger(bundle.l),
 		cafs.WithMetrics(bundle.MetricsEnabled()),
 		cafs.VerifyHash(bundle.withVerifyHash),
+		cafs.DiskCache(bundle.leafCache),
 	)
 	if err != nil {
 		return err
@@ -562,8 +631,9 @@ func unpackDataFiles(ctx context.Context, bundle *Bundle,
 		bundleDest,
 		fs,
 		downloadBundleChans{
-			error:  errC,
-			doneOk: doneOkC,
+			error:    errC,
+			doneOk:   doneOkC,
+			progress: p,
 		})
 	select {
 	case eh := <-errC:
@@ -573,6 +643,7 @@ func unpackDataFiles(ctx context.Context, bundle *Bundle,
 
 	// rewrite destination bundle metadata
 	if bundleDest != nil {
+		p.phase(PhaseMetadata, 0, 0)
 		info, err := getConsumableStoreMetadataKeysInfo(ctx, bundleDest)
 		if err != nil {
 			return err
@@ -612,6 +683,7 @@ func unpackDataFile(ctx context.Context, bundle *Bundle, file string) error {
 		cafs.ReaderConcurrentChunkWrites(bundle.concurrentFileDownloads/fileDownloadsPerConcurrentChunks),
 		cafs.WithMetrics(bundle.MetricsEnabled()),
 		cafs.VerifyHash(bundle.withVerifyHash),
+		cafs.DiskCache(bundle.leafCache),
 	)
 	if err != nil {
 		return err
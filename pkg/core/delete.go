@@ -22,6 +22,9 @@ func DeleteRepo(repo string, stores context2.Stores, opts ...DeleteOption) error
 		if err := RepoExists(repo, stores); err != nil {
 			return fmt.Errorf("cannot find repo: %s: %v", repo, err)
 		}
+		if err := checkACL(repo, stores, model.ACLAdmin, options.contributor); err != nil {
+			return err
+		}
 	}
 
 	// 1. remove all bundles in repo
@@ -60,6 +63,10 @@ func DeleteRepo(repo string, stores context2.Stores, opts ...DeleteOption) error
 		return fmt.Errorf("cannot delete repo: %s: %v", repo, err)
 	}
 
+	if err = deleteACL(repo, stores); err != nil {
+		return fmt.Errorf("cannot delete ACL for repo: %s: %v", repo, err)
+	}
+
 	return nil
 }
 
@@ -71,6 +78,9 @@ func DeleteBundle(repo string, stores context2.Stores, bundleID string, opts ...
 		if err := RepoExists(repo, stores); err != nil {
 			return fmt.Errorf("cannot find repo: %s: %v", repo, err)
 		}
+		if err := checkACL(repo, stores, model.ACLAdmin, options.contributor); err != nil {
+			return err
+		}
 	}
 
 	store := getMetaStore(stores)
@@ -130,6 +140,9 @@ func DeleteLabel(repo string, stores context2.Stores, name string, opts ...Delet
 		if err := RepoExists(repo, stores); err != nil {
 			return fmt.Errorf("cannot find repo: %s: %v", repo, err)
 		}
+		if err := checkACL(repo, stores, model.ACLWriter, options.contributor); err != nil {
+			return err
+		}
 	}
 
 	// TODO(fred): delete all versions???
@@ -1,5 +1,7 @@
 package core
 
+import "github.com/oneconcern/datamon/pkg/model"
+
 type (
 	DeleteOption func(*deleteOptions)
 
@@ -7,6 +9,7 @@ type (
 		skipCheckRepo     bool
 		skipDeleteLabel   bool
 		ignoreBundleError bool
+		contributor       model.Contributor
 	}
 )
 
@@ -37,3 +40,10 @@ func WithDeleteIgnoreBundleError(enabled bool) DeleteOption {
 		o.ignoreBundleError = enabled
 	}
 }
+
+// WithDeleteContributor sets the identity of the contributor performing the deletion, as checked against access control lists
+func WithDeleteContributor(contributor model.Contributor) DeleteOption {
+	return func(o *deleteOptions) {
+		o.contributor = contributor
+	}
+}
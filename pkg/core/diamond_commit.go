@@ -120,6 +120,11 @@ func (d *Diamond) implCommit(opts ...Option) (err error) {
 		}
 	}(time.Now())
 
+	p := newProgress(ProgressCommit, d.progressFn)
+	defer func() {
+		p.finish(err)
+	}()
+
 	// check if repo exists
 	if err = RepoExists(d.RepoID, d.contextStores); err != nil {
 		return err
@@ -155,6 +160,7 @@ func (d *Diamond) implCommit(opts ...Option) (err error) {
 	// walk all completed splits
 	// TODO(fred): nice - performances - should be piped to next stage asynchronously - at the moment, we start collecting index files
 	// only after metadata about all splits have been collected.
+	p.phase(PhaseList, 0, 0)
 	splits, err := d.collectSplits(opts...)
 	if err != nil {
 		return err
@@ -195,7 +201,8 @@ func (d *Diamond) implCommit(opts ...Option) (err error) {
 	// start merging split file lists
 	var wg sync.WaitGroup
 	wg.Add(1)
-	go d.mergeSplits(filePackedC, errorC, doneOkC, &wg)
+	p.phase(PhaseMerge, 0, 0)
+	go d.mergeSplits(filePackedC, errorC, doneOkC, &wg, p)
 	defer wg.Wait()
 
 	// hand out all files to merger goroutine
@@ -207,6 +214,7 @@ func (d *Diamond) implCommit(opts ...Option) (err error) {
 	// finalize bundle metadata
 	d.BundleDescriptor.BundleEntriesFileCount = count
 
+	p.phase(PhaseMetadata, 0, 0)
 	err = uploadBundleDescriptor(d.contexter(), d.Bundle)
 	if err != nil {
 		return err
@@ -232,7 +240,7 @@ type mergeEntry struct {
 //
 // TODO(fred): scalability - at the moment, the merger is carried out in memory. Use local storage to merge very large file lists
 // TODO(fred): nice - a more careful choice of type in lieu of filePacked could avoid some extra data copy => will tend to that when refactoring with bundle
-func (d *Diamond) mergeSplits(filePackedC chan<- filePacked, errorC chan<- errorHit, doneOkC chan<- struct{}, wgg *sync.WaitGroup) {
+func (d *Diamond) mergeSplits(filePackedC chan<- filePacked, errorC chan<- errorHit, doneOkC chan<- struct{}, wgg *sync.WaitGroup, p *progress) {
 	defer wgg.Done()
 
 	var (
@@ -262,6 +270,7 @@ func (d *Diamond) mergeSplits(filePackedC chan<- filePacked, errorC chan<- error
 				default:
 				}
 				merged++
+				p.add(file.NameWithPath, file.Size)
 				d.l.Debug("merge received file entry", zap.String("from split", splitID), zap.String("entry", file.NameWithPath))
 				key := []byte(file.NameWithPath)
 				obj, found := mergeIndex.Get(key)
@@ -343,13 +352,19 @@ func (d *Diamond) mergeSplits(filePackedC chan<- filePacked, errorC chan<- error
 
 		// now dump the merged index as output
 		t0 = time.Now()
+		p.phase(PhaseIndex, mergeIndex.Len(), 0)
+		var size uint64
 		iterator := mergeIndex.Root().Iterator()
 		for _, obj, ok := iterator.Next(); ok; _, obj, ok = iterator.Next() {
 			bundleEntries++
 			existing := obj.(mergeEntry)
+			size += existing.Size
 			d.l.Debug("merge sending", zap.String("entry", existing.NameWithPath))
 			output <- mergeEntryToFilePacked(existing)
+			p.add(existing.NameWithPath, existing.Size)
 		}
+		d.BundleDescriptor.FileCount = bundleEntries
+		d.BundleDescriptor.Size = size
 	}(d.splitIndexer.OutputChan(), filePackedC, interrupt, &wg)
 
 	// feed the process with some split input, collecting all splits to be merged
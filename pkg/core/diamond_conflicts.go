@@ -0,0 +1,264 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/errors"
+	"github.com/oneconcern/datamon/pkg/model"
+	"go.uber.org/zap"
+)
+
+// KeepLatest resolves conflicts by retaining the latest uploaded version of every conflicting file
+const KeepLatest = "latest"
+
+// ListConflicts reports about all the files uploaded with different content by distinct splits of a diamond.
+//
+// For a committed diamond, the splits merged at commit time are inspected. For a diamond which is not
+// committed yet, all splits currently done are inspected.
+//
+// Conflicts are detected regardless of the conflict handling mode used at commit time.
+func ListConflicts(repo, diamondID string, stores context2.Stores, opts ...DiamondOption) (model.FileConflicts, error) {
+	d := newDiamondForID(repo, diamondID, stores, opts...)
+
+	var err error
+	defer func(t0 time.Time) {
+		if d.MetricsEnabled() {
+			d.m.Usage.UsedAll(t0, "ListConflicts")(err)
+		}
+	}(time.Now())
+
+	if err = RepoExists(repo, stores); err != nil {
+		return nil, err
+	}
+
+	if err = d.downloadDescriptor(); err != nil {
+		return nil, err
+	}
+
+	conflicts, err := d.listConflicts()
+	return conflicts, err
+}
+
+// ResolveConflicts produces a new bundle from a committed diamond, retaining for every conflicting path
+// the version uploaded by the split designated by keep, or the latest version when keep is KeepLatest.
+//
+// When the designated split did not upload some conflicting path, the latest version is retained.
+//
+// Conflicts and checkpoints recorded in the committed bundle are removed from the new bundle, which
+// declares the committed bundle as its parent. No blob is uploaded.
+func ResolveConflicts(repo, diamondID, keep string, stores context2.Stores, opts ...DiamondOption) (*Bundle, error) {
+	d := newDiamondForID(repo, diamondID, stores, opts...)
+	message := d.BundleDescriptor.Message
+
+	var err error
+	defer func(t0 time.Time) {
+		if d.MetricsEnabled() {
+			d.m.Usage.UsedAll(t0, "ResolveConflicts")(err)
+		}
+	}(time.Now())
+
+	if keep == "" {
+		err = errors.New("a split ID or latest is required to resolve conflicts")
+		return nil, err
+	}
+
+	if err = RepoExists(repo, stores); err != nil {
+		return nil, err
+	}
+
+	if err = d.downloadDescriptor(); err != nil {
+		return nil, err
+	}
+
+	if d.DiamondDescriptor.State != model.DiamondDone || d.DiamondDescriptor.BundleID == "" {
+		err = errors.New("cannot resolve conflicts on a diamond which is not committed").
+			WrapMessage("diamond state: %v", d.DiamondDescriptor.State)
+		return nil, err
+	}
+
+	if keep != KeepLatest && !d.hasSplit(keep) {
+		err = errors.New("cannot resolve conflicts").
+			WrapMessage("split %s is not part of diamond %s", keep, diamondID)
+		return nil, err
+	}
+
+	conflicts, err := d.listConflicts()
+	if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]model.FileConflict, len(conflicts))
+	for _, conflict := range conflicts {
+		index[conflict.Path] = conflict
+	}
+
+	// retrieve the committed bundle
+	committed := NewBundle(
+		Repo(repo),
+		ContextStores(stores),
+		BundleID(d.DiamondDescriptor.BundleID),
+		Logger(d.l),
+	)
+	if err = PopulateFiles(d.contexter(), committed); err != nil {
+		return nil, err
+	}
+
+	if message == "" {
+		message = fmt.Sprintf("resolved conflicts for diamond %s, keeping %s", diamondID, keep)
+	}
+
+	descriptor := committed.BundleDescriptor
+	descriptor.ID = ""
+	descriptor.BundleEntriesFileCount = 0
+	descriptor.Timestamp = model.GetBundleTimeStamp()
+	descriptor.Message = message
+	descriptor.Parents = []string{committed.BundleID}
+	descriptor.FileCount, descriptor.Size = 0, 0 // recomputed from the resolved entries
+
+	resolved := NewBundle(
+		Repo(repo),
+		ContextStores(stores),
+		BundleDescriptor(&descriptor),
+		Logger(d.l),
+		BundleWithMetrics(d.MetricsEnabled()),
+	)
+
+	for _, entry := range committed.BundleEntries {
+		if model.IsGeneratedFile(entry.NameWithPath) {
+			// drop conflicts and checkpoints
+			continue
+		}
+
+		if conflict, isConflicting := index[entry.NameWithPath]; isConflicting {
+			version, found := conflict.Version(keep)
+			if !found {
+				version = conflict.Latest()
+			}
+			entry.Hash = version.Hash
+			entry.Size = version.Size
+		}
+		entry.Timestamp = time.Time{}
+		resolved.BundleEntries = append(resolved.BundleEntries, entry)
+		resolved.BundleDescriptor.FileCount++
+		resolved.BundleDescriptor.Size += entry.Size
+	}
+
+	if err = resolved.InitializeBundleID(); err != nil {
+		return nil, err
+	}
+
+	if err = resolved.UploadBundleEntries(d.contexter()); err != nil {
+		return nil, err
+	}
+
+	d.l.Info("resolved conflicts",
+		zap.String("diamond_id", diamondID),
+		zap.String("keep", keep),
+		zap.Int("conflicts", len(conflicts)),
+		zap.String("bundle_id", resolved.BundleID),
+	)
+	return resolved, nil
+}
+
+func newDiamondForID(repo, diamondID string, stores context2.Stores, opts ...DiamondOption) *Diamond {
+	getOpts := []DiamondOption{
+		DiamondDescriptor(
+			model.NewDiamondDescriptor(model.DiamondID(diamondID)),
+		),
+	}
+	getOpts = append(getOpts, opts...)
+
+	return NewDiamond(repo, stores, getOpts...)
+}
+
+func (d *Diamond) hasSplit(splitID string) bool {
+	for _, split := range d.DiamondDescriptor.Splits {
+		if split.SplitID == splitID {
+			return true
+		}
+	}
+	return false
+}
+
+// listConflicts walks the index files of all splits and collects the paths with competing versions.
+//
+// Several versions uploaded by the same split are not considered conflicting: only the latest of them is retained.
+func (d *Diamond) listConflicts() (model.FileConflicts, error) {
+	if err := d.ensureSplits(); err != nil {
+		return nil, err
+	}
+
+	d.splitIndexer = d.makeDownloadIndexer()
+
+	// path -> split -> latest version uploaded by this split
+	versions := make(map[string]map[string]model.FileVersion)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func(input <-chan bundleEntriesRes, wg *sync.WaitGroup) {
+		defer wg.Done()
+
+		for res := range input {
+			for _, file := range res.bundleEntries.BundleEntries {
+				bySplit, ok := versions[file.NameWithPath]
+				if !ok {
+					bySplit = make(map[string]model.FileVersion, 1)
+					versions[file.NameWithPath] = bySplit
+				}
+				if existing, ok := bySplit[res.id]; ok && existing.Timestamp.After(file.Timestamp) {
+					continue
+				}
+				bySplit[res.id] = model.FileVersion{
+					SplitID:   res.id,
+					Hash:      file.Hash,
+					Size:      file.Size,
+					Timestamp: file.Timestamp,
+				}
+			}
+		}
+	}(d.splitIndexer.OutputChan(), &wg)
+
+	err := d.splitIndexer.Download()
+	wg.Wait()
+	if err != nil {
+		return nil, errors.New("failed downloading list files for diamond").Wrap(err)
+	}
+
+	conflicts := make(model.FileConflicts, 0, len(versions)/100+1)
+	for pth, bySplit := range versions {
+		if len(bySplit) < 2 {
+			continue
+		}
+
+		hashes := make(map[string]struct{}, len(bySplit))
+		competing := make([]model.FileVersion, 0, len(bySplit))
+		for _, version := range bySplit {
+			hashes[version.Hash] = struct{}{}
+			competing = append(competing, version)
+		}
+		if len(hashes) < 2 {
+			// identical files do not conflict
+			continue
+		}
+
+		sort.Slice(competing, func(i, j int) bool {
+			if competing[i].Timestamp.Equal(competing[j].Timestamp) {
+				return competing[i].SplitID < competing[j].SplitID
+			}
+			return competing[i].Timestamp.Before(competing[j].Timestamp)
+		})
+		competing[len(competing)-1].Latest = true
+
+		conflicts = append(conflicts, model.FileConflict{
+			Path:     pth,
+			Versions: competing,
+		})
+	}
+	sort.Sort(conflicts)
+
+	return conflicts, nil
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oneconcern/datamon/pkg/cafs"
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiamondConflicts(t *testing.T) {
+	ev, cleanup := testDiamondEnv()
+	defer cleanup(t)()
+	t.Logf("test location: %s", ev.TestRoot)
+
+	ctx := mocks.FakeContext2(ev.MetaDir, ev.VmetaDir, ev.BlobDir)
+	pods := []string{"pod1", "pod2"}
+	conflicting := filepath.Join(commonLocation, "conflicting")
+	identical := filepath.Join(commonLocation, "identical")
+
+	for _, pod := range pods {
+		require.NoError(t, cafs.GenerateFile(filepath.Join(ev.Original, pod, "file"), 1024, ev.LeafSize))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(ev.Original, commonLocation), 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(ev.Original, identical), []byte("same content"), 0600))
+
+	require.NoError(t, CreateRepo(model.RepoDescriptor{Name: ev.Repo, Description: "test"}, ctx))
+
+	diamond := NewDiamond(ev.Repo, ctx,
+		DiamondLogger(mocks.TestLogger()),
+		DiamondDescriptor(model.NewDiamondDescriptor(model.DiamondMode(model.EnableConflicts))),
+	)
+	_, err := CreateDiamond(ev.Repo, ctx, DiamondDescriptor(&diamond.DiamondDescriptor))
+	require.NoError(t, err)
+	diamondID := diamond.DiamondDescriptor.DiamondID
+
+	// resolution is not possible before commit
+	_, err = ResolveConflicts(ev.Repo, diamondID, KeepLatest, ctx, DiamondLogger(mocks.TestLogger()))
+	require.Error(t, err)
+
+	for _, toPin := range pods {
+		pod := toPin
+		require.NoError(t, ioutil.WriteFile(filepath.Join(ev.Original, conflicting), []byte("version from "+pod), 0600))
+		testSplitAdd(t, diamondID, pod, ctx, ev, SplitKeyFilter(func(pth string) bool {
+			dir := filepath.Base(filepath.Dir(pth))
+			return dir == pod || dir == commonLocation
+		}))
+	}
+
+	splitIDs := make(map[string]string, len(pods))
+	require.NoError(t, ListSplitsApply(ev.Repo, diamondID, ctx, func(sd model.SplitDescriptor) error {
+		splitIDs[sd.Tag] = sd.SplitID
+		return nil
+	}))
+	require.Len(t, splitIDs, len(pods))
+
+	assertConflicts := func(t testing.TB, conflicts model.FileConflicts) {
+		require.Len(t, conflicts, 1)
+		conflict := conflicts[0]
+		assert.Equal(t, conflicting, conflict.Path)
+		require.Len(t, conflict.Versions, 2)
+		assert.Equal(t, splitIDs["pod1"], conflict.Versions[0].SplitID)
+		assert.False(t, conflict.Versions[0].Latest)
+		assert.Equal(t, splitIDs["pod2"], conflict.Latest().SplitID)
+		assert.True(t, conflict.Latest().Latest)
+		assert.NotEqual(t, conflict.Versions[0].Hash, conflict.Versions[1].Hash)
+	}
+
+	// conflicts are reported before commit
+	conflicts, err := ListConflicts(ev.Repo, diamondID, ctx, DiamondLogger(mocks.TestLogger()))
+	require.NoError(t, err)
+	assertConflicts(t, conflicts)
+
+	require.NoError(t, diamond.Commit())
+
+	// conflicts are reported after commit
+	conflicts, err = ListConflicts(ev.Repo, diamondID, ctx, DiamondLogger(mocks.TestLogger()))
+	require.NoError(t, err)
+	assertConflicts(t, conflicts)
+
+	_, err = ResolveConflicts(ev.Repo, diamondID, "wrong", ctx, DiamondLogger(mocks.TestLogger()))
+	require.Error(t, err)
+
+	for _, keep := range []string{splitIDs["pod1"], KeepLatest} {
+		resolved, err := ResolveConflicts(ev.Repo, diamondID, keep, ctx, DiamondLogger(mocks.TestLogger()), DiamondMessage("resolved "+keep))
+		require.NoError(t, err)
+
+		expected, ok := conflicts[0].Version(keep)
+		if !ok {
+			expected = conflicts[0].Latest()
+		}
+
+		bundle := NewBundle(Repo(ev.Repo), ContextStores(ctx), BundleID(resolved.BundleID), Logger(mocks.TestLogger()))
+		require.NoError(t, PopulateFiles(backgroundContexter(), bundle))
+
+		assert.Equal(t, []string{diamond.DiamondDescriptor.BundleID}, bundle.BundleDescriptor.Parents)
+		assert.Equal(t, "resolved "+keep, bundle.BundleDescriptor.Message)
+		assert.Len(t, bundle.BundleEntries, len(pods)+2)
+		for _, entry := range bundle.BundleEntries {
+			assert.Falsef(t, model.IsGeneratedFile(entry.NameWithPath), "unexpected generated file: %s", entry.NameWithPath)
+			if entry.NameWithPath == conflicting {
+				assert.Equal(t, expected.Hash, entry.Hash)
+			}
+		}
+
+		// totals exclude the dropped conflicts
+		assert.Equal(t, uint64(len(pods)+2), bundle.BundleDescriptor.FileCount)
+		assert.Equal(t, uint64(len(pods)*1024+len("same content")+len("version from pod1")), bundle.BundleDescriptor.Size)
+	}
+}
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/errors"
+	"github.com/oneconcern/datamon/pkg/model"
+	storagestatus "github.com/oneconcern/datamon/pkg/storage/status"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v2"
+)
+
+// DiamondsGC reports about the outcome of a garbage collection of abandoned diamonds
+type DiamondsGC struct {
+	CanceledDiamonds  []string
+	OrphanedSplits    []string
+	DeletedIndexFiles uint64
+	DryRun            bool
+}
+
+// GCDiamonds collects abandoned diamonds and splits in a repo.
+//
+// Diamonds which have not been committed and have not seen any activity for longer than their
+// time-to-live are canceled. Splits running for longer than their time-to-live are marked orphaned.
+// All running splits of a committed or canceled diamond are marked orphaned.
+//
+// The index files uploaded by orphaned splits and by the splits of canceled diamonds are removed.
+// Descriptors are retained, so diamonds and splits may still be listed. The blobs referred to by removed
+// index files are not deleted: this is left to the purge.
+//
+// TTLs are set with the WithDiamondTTL and WithSplitTTL options.
+func GCDiamonds(repo string, stores context2.Stores, opts ...Option) (*DiamondsGC, error) {
+	settings := defaultSettings()
+	for _, apply := range opts {
+		apply(&settings)
+	}
+
+	if err := RepoExists(repo, stores); err != nil {
+		return nil, err
+	}
+
+	diamonds, err := ListDiamonds(repo, stores, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	gc := &DiamondsGC{
+		CanceledDiamonds: make([]string, 0, len(diamonds)),
+		OrphanedSplits:   make([]string, 0, typicalSplitsNum),
+		DryRun:           settings.dryRun,
+	}
+
+	for _, diamond := range diamonds {
+		if err = gcDiamond(repo, diamond, stores, settings, now, gc); err != nil {
+			return gc, err
+		}
+	}
+
+	return gc, nil
+}
+
+func gcDiamond(repo string, diamond model.DiamondDescriptor, stores context2.Stores, settings Settings, now time.Time, gc *DiamondsGC) error {
+	d := newDiamondForID(repo, diamond.DiamondID, stores)
+	logger := d.l.With(zap.String("diamond_id", diamond.DiamondID), zap.Bool("dry_run", settings.dryRun))
+
+	splits, err := ListSplits(repo, diamond.DiamondID, stores)
+	if err != nil {
+		return err
+	}
+
+	if diamond.IsStale(splits, settings.diamondTTL, now) {
+		logger.Info("canceling stale diamond", zap.Time("last_activity", diamond.LastActivity(splits)))
+		if !settings.dryRun {
+			if err = d.Cancel(); err != nil {
+				return err
+			}
+		}
+		diamond.State = model.DiamondCanceled
+		gc.CanceledDiamonds = append(gc.CanceledDiamonds, diamond.DiamondID)
+	}
+
+	canceled := diamond.State == model.DiamondCanceled
+	terminated := diamond.State != model.DiamondInitialized
+
+	for _, split := range splits {
+		if split.State == model.SplitRunning && (terminated || split.IsStale(settings.splitTTL, now)) {
+			logger.Info("marking orphaned split", zap.String("split_id", split.SplitID), zap.Time("start_time", split.StartTime))
+			if !settings.dryRun {
+				orphaned := split
+				s := NewSplit(repo, diamond.DiamondID, stores, SplitDescriptor(&orphaned), SplitLogger(d.l))
+				if err = s.WithState(model.SplitOrphaned).uploadDescriptor(); err != nil {
+					if errors.Is(err, storagestatus.ErrExists) {
+						// the split has been completed in the meantime
+						continue
+					}
+					return err
+				}
+			}
+			split.State = model.SplitOrphaned
+			gc.OrphanedSplits = append(gc.OrphanedSplits, split.SplitID)
+		}
+
+		if !canceled && split.State != model.SplitOrphaned {
+			continue
+		}
+
+		// remove partial index files
+		keys, err := splitIndexKeys(repo, diamond.DiamondID, split.SplitID, stores, settings)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if !settings.dryRun {
+				if err = GetDiamondStore(stores).Delete(context.Background(), key); err != nil {
+					return err
+				}
+			}
+			gc.DeletedIndexFiles++
+		}
+	}
+
+	return nil
+}
+
+// splitIndexKeys lists the index files uploaded by a split, in any generation
+func splitIndexKeys(repo, diamondID, splitID string, stores context2.Stores, settings Settings) ([]string, error) {
+	store := GetDiamondStore(stores)
+	prefix := model.GetArchivePathPrefixToSplits(repo, diamondID) + splitID + "/"
+	keys := make([]string, 0, 10)
+
+	var next string
+	for {
+		batch, token, err := store.KeysPrefix(context.Background(), next, prefix, "", settings.batchSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range batch {
+			apc, err := model.GetArchivePathComponents(key)
+			if err != nil || apc.GenerationID == "" {
+				// not an index file
+				continue
+			}
+			keys = append(keys, key)
+		}
+
+		if token == "" || len(batch) == 0 {
+			return keys, nil
+		}
+		next = token
+	}
+}
+
+// liveDiamondsEntries collects all file entries uploaded by the splits of diamonds which are neither committed,
+// canceled nor stale.
+//
+// Index files are collected for every split which is not orphaned, including running splits.
+func liveDiamondsEntries(repo string, stores context2.Stores, opts ...Option) ([]model.BundleEntry, error) {
+	settings := defaultSettings()
+	for _, apply := range opts {
+		apply(&settings)
+	}
+
+	store := GetDiamondStore(stores)
+	if store == nil {
+		// no diamond in this context
+		return nil, nil
+	}
+
+	diamonds, err := ListDiamonds(repo, stores, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	entries := make([]model.BundleEntry, 0, defaultBundleEntriesPerFile)
+
+	for _, diamond := range diamonds {
+		if diamond.State != model.DiamondInitialized {
+			continue
+		}
+
+		splits, err := ListSplits(repo, diamond.DiamondID, stores)
+		if err != nil {
+			return nil, err
+		}
+
+		if diamond.IsStale(splits, settings.diamondTTL, now) {
+			continue
+		}
+
+		for _, split := range splits {
+			if split.State == model.SplitOrphaned || split.IsStale(settings.splitTTL, now) {
+				continue
+			}
+
+			keys, err := splitIndexKeys(repo, diamond.DiamondID, split.SplitID, stores, settings)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, key := range keys {
+				rdr, err := store.Get(context.Background(), key)
+				if err != nil {
+					if errors.Is(err, storagestatus.ErrNotExists) {
+						continue
+					}
+					return nil, err
+				}
+
+				var index model.BundleEntries
+				err = yaml.NewDecoder(rdr).Decode(&index)
+				_ = rdr.Close()
+				if err != nil {
+					return nil, err
+				}
+				entries = append(entries, index.BundleEntries...)
+			}
+		}
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/cafs"
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/segmentio/ksuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestGCDiamonds(t *testing.T) {
+	ev, cleanup := testDiamondEnv()
+	defer cleanup(t)()
+	t.Logf("test location: %s", ev.TestRoot)
+
+	ctx := mocks.FakeContext2(ev.MetaDir, ev.VmetaDir, ev.BlobDir)
+	for _, pod := range []string{"pod1", "pod2"} {
+		require.NoError(t, cafs.GenerateFile(filepath.Join(ev.Original, pod, "file"), 1024, ev.LeafSize))
+	}
+
+	require.NoError(t, CreateRepo(model.RepoDescriptor{Name: ev.Repo, Description: "test"}, ctx))
+
+	createDiamond := func(t testing.TB) string {
+		diamond, err := CreateDiamond(ev.Repo, ctx, DiamondLogger(mocks.TestLogger()))
+		require.NoError(t, err)
+		return diamond.DiamondID
+	}
+
+	// a running split, with some partially uploaded index file
+	createRunningSplit := func(t testing.TB, diamondID string) string {
+		split, err := CreateSplit(ev.Repo, diamondID, ctx, SplitLogger(mocks.TestLogger()))
+		require.NoError(t, err)
+
+		buffer, err := yaml.Marshal(model.BundleEntries{
+			BundleEntries: []model.BundleEntry{{Hash: "abcd", NameWithPath: "partial", Size: 10, Timestamp: time.Now()}},
+		})
+		require.NoError(t, err)
+		generation := ksuid.New().String()
+		require.NoError(t, GetDiamondStore(ctx).Put(context.Background(),
+			model.GetArchivePathToSplitFileList(ev.Repo, diamondID, split.SplitID, generation, 0),
+			bytes.NewReader(buffer), storage.NoOverWrite))
+		return split.SplitID
+	}
+
+	liveEntries := func(t testing.TB, opts ...Option) []string {
+		entries, err := liveDiamondsEntries(ev.Repo, ctx, opts...)
+		require.NoError(t, err)
+		return entryNames(entries)
+	}
+
+	// diamond with a done split and a running split
+	diamond1 := createDiamond(t)
+	testSplitAdd(t, diamond1, "pod1", ctx, ev)
+	running1 := createRunningSplit(t, diamond1)
+
+	// diamond with a done split only
+	diamond2 := createDiamond(t)
+	testSplitAdd(t, diamond2, "pod2", ctx, ev)
+
+	// committed diamond with a running split
+	diamond3 := createDiamond(t)
+	testSplitAdd(t, diamond3, "pod1", ctx, ev)
+	running3 := createRunningSplit(t, diamond3)
+	require.NoError(t, NewDiamond(ev.Repo, ctx,
+		DiamondDescriptor(model.NewDiamondDescriptor(model.DiamondID(diamond3))),
+		DiamondLogger(mocks.TestLogger()),
+	).Commit())
+
+	require.ElementsMatch(t, []string{"partial", filepath.Join("pod1", "file"), filepath.Join("pod2", "file")}, liveEntries(t))
+
+	time.Sleep(10 * time.Millisecond)
+
+	t.Run("should not change anything with dry-run", func(t *testing.T) {
+		gc, err := GCDiamonds(ev.Repo, ctx, WithSplitTTL(time.Millisecond), WithDryRun(true))
+		require.NoError(t, err)
+		assert.True(t, gc.DryRun)
+		assert.Empty(t, gc.CanceledDiamonds)
+		assert.ElementsMatch(t, []string{running1, running3}, gc.OrphanedSplits)
+		assert.Equal(t, uint64(2), gc.DeletedIndexFiles)
+
+		split, err := GetSplit(ev.Repo, diamond1, running1, ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.SplitRunning, split.State)
+	})
+
+	t.Run("should mark orphaned splits", func(t *testing.T) {
+		gc, err := GCDiamonds(ev.Repo, ctx, WithSplitTTL(time.Millisecond))
+		require.NoError(t, err)
+		assert.Empty(t, gc.CanceledDiamonds)
+		assert.ElementsMatch(t, []string{running1, running3}, gc.OrphanedSplits)
+		assert.Equal(t, uint64(2), gc.DeletedIndexFiles)
+
+		split, err := GetSplit(ev.Repo, diamond1, running1, ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.SplitOrphaned, split.State)
+		assert.False(t, split.EndTime.IsZero())
+
+		split, err = GetSplit(ev.Repo, diamond3, running3, ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.SplitOrphaned, split.State)
+
+		diamond, err := GetDiamond(ev.Repo, diamond1, ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.DiamondInitialized, diamond.State)
+
+		assert.ElementsMatch(t, []string{filepath.Join("pod1", "file"), filepath.Join("pod2", "file")}, liveEntries(t))
+	})
+
+	t.Run("should cancel stale diamonds", func(t *testing.T) {
+		gc, err := GCDiamonds(ev.Repo, ctx, WithDiamondTTL(time.Millisecond))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{diamond1, diamond2}, gc.CanceledDiamonds)
+		assert.Empty(t, gc.OrphanedSplits)
+		assert.Equal(t, uint64(2), gc.DeletedIndexFiles)
+
+		for _, diamondID := range []string{diamond1, diamond2} {
+			diamond, err := GetDiamond(ev.Repo, diamondID, ctx)
+			require.NoError(t, err)
+			assert.Equal(t, model.DiamondCanceled, diamond.State)
+		}
+
+		diamond, err := GetDiamond(ev.Repo, diamond3, ctx)
+		require.NoError(t, err)
+		assert.Equal(t, model.DiamondDone, diamond.State)
+
+		assert.Empty(t, liveEntries(t))
+	})
+
+	t.Run("should be idempotent", func(t *testing.T) {
+		gc, err := GCDiamonds(ev.Repo, ctx, WithDiamondTTL(time.Millisecond), WithSplitTTL(time.Millisecond))
+		require.NoError(t, err)
+		assert.Empty(t, gc.CanceledDiamonds)
+		assert.Empty(t, gc.OrphanedSplits)
+		assert.Zero(t, gc.DeletedIndexFiles)
+	})
+}
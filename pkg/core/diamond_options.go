@@ -86,3 +86,10 @@ func DiamondWithMetrics(enabled bool) DiamondOption {
 		b.EnableMetrics(enabled)
 	}
 }
+
+// DiamondWithProgress reports the progress of a diamond commit as events sent to a callback
+func DiamondWithProgress(fn ProgressFunc) DiamondOption {
+	return func(b *Diamond) {
+		b.progressFn = fn
+	}
+}
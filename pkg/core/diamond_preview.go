@@ -0,0 +1,188 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/errors"
+	"github.com/oneconcern/datamon/pkg/model"
+	"go.uber.org/zap"
+)
+
+// ListDiamondFiles lists the files of a diamond, as they would be committed in a bundle.
+//
+// Files from all splits done so far are merged the same way as when committing the diamond, including
+// conflicts or checkpoints. When conflicts are forbidden, conflicting versions are reported
+// as conflicts rather than failing.
+//
+// For a committed diamond, the splits merged at commit time are considered.
+func ListDiamondFiles(repo, diamondID string, stores context2.Stores, opts ...DiamondOption) ([]model.BundleEntry, error) {
+	d := newDiamondForID(repo, diamondID, stores, opts...)
+
+	var err error
+	defer func(t0 time.Time) {
+		if d.MetricsEnabled() {
+			d.m.Usage.UsedAll(t0, "ListDiamondFiles")(err)
+		}
+	}(time.Now())
+
+	if err = RepoExists(repo, stores); err != nil {
+		return nil, err
+	}
+
+	if err = d.downloadDescriptor(); err != nil {
+		return nil, err
+	}
+
+	if err = d.ensureSplits(); err != nil {
+		return nil, err
+	}
+
+	entries, err := d.mergePreview()
+	return entries, err
+}
+
+// ListSplitFiles lists the files uploaded by a split.
+//
+// The split must be done. Whenever several versions of a file have been uploaded by this split,
+// only the latest version is reported.
+func ListSplitFiles(repo, diamondID, splitID string, stores context2.Stores, opts ...DiamondOption) ([]model.BundleEntry, error) {
+	d := newDiamondForID(repo, diamondID, stores, opts...)
+
+	var err error
+	defer func(t0 time.Time) {
+		if d.MetricsEnabled() {
+			d.m.Usage.UsedAll(t0, "ListSplitFiles")(err)
+		}
+	}(time.Now())
+
+	if err = RepoExists(repo, stores); err != nil {
+		return nil, err
+	}
+
+	split, err := GetSplit(repo, diamondID, splitID, stores, SplitLogger(d.l))
+	if err != nil {
+		return nil, err
+	}
+
+	if split.State != model.SplitDone {
+		err = errors.New("no files available from this split yet").
+			WrapMessage("split state: %v", split.State)
+		return nil, err
+	}
+
+	d.DiamondDescriptor.Splits = model.SplitDescriptors{split}
+
+	entries, err := d.mergePreview()
+	return entries, err
+}
+
+// populatePreview fills a bundle with the files merged from the splits of a diamond
+func populatePreview(bundle *Bundle) error {
+	d := newDiamondForID(bundle.RepoID, bundle.previewDiamondID, bundle.contextStores,
+		DiamondLogger(bundle.l),
+	)
+
+	if err := RepoExists(bundle.RepoID, bundle.contextStores); err != nil {
+		return err
+	}
+
+	if err := d.downloadDescriptor(); err != nil {
+		return err
+	}
+
+	if err := d.ensureSplits(); err != nil {
+		return err
+	}
+
+	entries, err := d.mergePreview()
+	if err != nil {
+		return err
+	}
+
+	contributors := make([]model.Contributor, 0, len(d.DiamondDescriptor.Splits))
+	for _, split := range d.DiamondDescriptor.Splits {
+		contributors = append(contributors, split.Contributors...)
+	}
+
+	bundle.BundleDescriptor.Contributors = contributors
+	bundle.BundleDescriptor.Timestamp = d.DiamondDescriptor.StartTime
+	bundle.BundleDescriptor.Message = fmt.Sprintf("preview of diamond %s", bundle.previewDiamondID)
+	bundle.BundleEntries = entries
+
+	return nil
+}
+
+// ensureSplits sets the splits to be merged: splits merged at commit time for a committed diamond,
+// or all splits which are done so far.
+func (d *Diamond) ensureSplits() error {
+	if len(d.DiamondDescriptor.Splits) > 0 {
+		return nil
+	}
+
+	splits, err := d.collectSplits()
+	if err != nil {
+		return err
+	}
+	d.DiamondDescriptor.Splits = splits
+	return nil
+}
+
+// mergePreview merges the files from splits in memory, like a commit would do, but doesn't upload anything.
+func (d *Diamond) mergePreview() ([]model.BundleEntry, error) {
+	if d.DiamondDescriptor.Mode == model.ForbidConflicts {
+		// a preview reports about conflicts rather than failing
+		d.DiamondDescriptor.Mode = model.EnableConflicts
+		d.deconflicter = model.GenerateConflictPath
+	}
+
+	d.splitIndexer = d.makeDownloadIndexer()
+
+	filePackedC := make(chan filePacked, bufferingFactor)
+	errorC := make(chan errorHit)
+	doneOkC := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go d.mergeSplits(filePackedC, errorC, doneOkC, &wg, nil)
+
+	entries := make([]model.BundleEntry, 0, typicalSplitsNum*defaultBundleEntriesPerFile)
+	for {
+		select {
+		case file, isOpen := <-filePackedC:
+			if !isOpen {
+				wg.Wait()
+				d.l.Info("merged split files", zap.Int("num_entries", len(entries)))
+				return entries, nil
+			}
+			entries = append(entries, model.BundleEntry{
+				Hash:         file.hash,
+				NameWithPath: file.name,
+				Size:         file.size,
+			})
+		case hit := <-errorC:
+			drainMerge(filePackedC, errorC, &wg)
+			return nil, errors.New("failed merging split files").Wrap(hit.error)
+		}
+	}
+}
+
+// drainMerge discards the output of an interrupted merge, so the merging goroutines may terminate
+func drainMerge(filePackedC <-chan filePacked, errorC <-chan errorHit, wg *sync.WaitGroup) {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for {
+		select {
+		case <-filePackedC:
+		case <-errorC:
+		case <-done:
+			return
+		}
+	}
+}
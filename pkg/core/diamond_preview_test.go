@@ -0,0 +1,120 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/oneconcern/datamon/pkg/cafs"
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiamondPreview(t *testing.T) {
+	ev, cleanup := testDiamondEnv()
+	defer cleanup(t)()
+	t.Logf("test location: %s", ev.TestRoot)
+
+	ctx := mocks.FakeContext2(ev.MetaDir, ev.VmetaDir, ev.BlobDir)
+	pods := []string{"pod1", "pod2"}
+	conflicting := filepath.Join(commonLocation, "conflicting")
+
+	for _, pod := range pods {
+		require.NoError(t, cafs.GenerateFile(filepath.Join(ev.Original, pod, "file"), 1024, ev.LeafSize))
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(ev.Original, commonLocation), 0700))
+
+	require.NoError(t, CreateRepo(model.RepoDescriptor{Name: ev.Repo, Description: "test"}, ctx))
+
+	diamond := NewDiamond(ev.Repo, ctx,
+		DiamondLogger(mocks.TestLogger()),
+		DiamondDescriptor(model.NewDiamondDescriptor(model.DiamondMode(model.EnableConflicts))),
+	)
+	_, err := CreateDiamond(ev.Repo, ctx, DiamondDescriptor(&diamond.DiamondDescriptor))
+	require.NoError(t, err)
+	diamondID := diamond.DiamondDescriptor.DiamondID
+
+	for _, toPin := range pods {
+		pod := toPin
+		require.NoError(t, ioutil.WriteFile(filepath.Join(ev.Original, conflicting), []byte("version from "+pod), 0600))
+		testSplitAdd(t, diamondID, pod, ctx, ev, SplitKeyFilter(func(pth string) bool {
+			dir := filepath.Base(filepath.Dir(pth))
+			return dir == pod || dir == commonLocation
+		}))
+	}
+
+	splitIDs := make(map[string]string, len(pods))
+	require.NoError(t, ListSplitsApply(ev.Repo, diamondID, ctx, func(sd model.SplitDescriptor) error {
+		splitIDs[sd.Tag] = sd.SplitID
+		return nil
+	}))
+
+	t.Run("should list split files", func(t *testing.T) {
+		files, err := ListSplitFiles(ev.Repo, diamondID, splitIDs["pod1"], ctx, DiamondLogger(mocks.TestLogger()))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{conflicting, filepath.Join("pod1", "file")}, entryNames(files))
+	})
+
+	t.Run("should list diamond files before commit", func(t *testing.T) {
+		files, err := ListDiamondFiles(ev.Repo, diamondID, ctx, DiamondLogger(mocks.TestLogger()))
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			conflicting,
+			model.GenerateConflictPath(splitIDs["pod1"], conflicting),
+			filepath.Join("pod1", "file"),
+			filepath.Join("pod2", "file"),
+		}, entryNames(files))
+	})
+
+	t.Run("should download a preview", func(t *testing.T) {
+		destination := filepath.Join(ev.DestinationDir, "preview")
+		preview := NewBundle(
+			Repo(ev.Repo),
+			ContextStores(ctx),
+			ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), destination))),
+			BundlePreviewDiamond(diamondID),
+			Logger(mocks.TestLogger()),
+		)
+		require.NoError(t, Publish(backgroundContexter(), preview))
+		assert.Empty(t, preview.BundleID)
+		assert.Len(t, preview.BundleDescriptor.Contributors, len(pods))
+
+		content, err := ioutil.ReadFile(filepath.Join(destination, conflicting))
+		require.NoError(t, err)
+		assert.Equal(t, "version from pod2", string(content))
+
+		content, err = ioutil.ReadFile(filepath.Join(destination, model.GenerateConflictPath(splitIDs["pod1"], conflicting)))
+		require.NoError(t, err)
+		assert.Equal(t, "version from pod1", string(content))
+	})
+
+	t.Run("should list the same files after commit", func(t *testing.T) {
+		before, err := ListDiamondFiles(ev.Repo, diamondID, ctx, DiamondLogger(mocks.TestLogger()))
+		require.NoError(t, err)
+
+		require.NoError(t, diamond.Commit())
+
+		after, err := ListDiamondFiles(ev.Repo, diamondID, ctx, DiamondLogger(mocks.TestLogger()))
+		require.NoError(t, err)
+		assert.Equal(t, before, after)
+
+		committed := NewBundle(Repo(ev.Repo), ContextStores(ctx), BundleID(diamond.DiamondDescriptor.BundleID), Logger(mocks.TestLogger()))
+		require.NoError(t, PopulateFiles(backgroundContexter(), committed))
+		assert.ElementsMatch(t, entryNames(committed.BundleEntries), entryNames(after))
+	})
+}
+
+func entryNames(entries []model.BundleEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.NameWithPath)
+	}
+	sort.Strings(names)
+	return names
+}
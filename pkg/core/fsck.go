@@ -0,0 +1,593 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/oneconcern/datamon/pkg/cafs"
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/errors"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage"
+	storagestatus "github.com/oneconcern/datamon/pkg/storage/status"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/singleflight"
+)
+
+// FsckIssueKind qualifies an integrity issue found by Fsck
+type FsckIssueKind string
+
+const (
+	// FsckMissingLeaf indicates that a leaf blob referred to by a root key is missing from the blob store
+	FsckMissingLeaf FsckIssueKind = "missing-leaf"
+
+	// FsckCorruptedLeaf indicates that the content of a leaf blob doesn't match its key
+	FsckCorruptedLeaf FsckIssueKind = "corrupted-leaf"
+
+	// FsckDanglingRoot indicates that a root key referred to by a bundle is missing from the blob store
+	FsckDanglingRoot FsckIssueKind = "dangling-root"
+
+	// FsckCorruptedRoot indicates that a root key referred to by a bundle is invalid or can't be exploded into leaves
+	FsckCorruptedRoot FsckIssueKind = "corrupted-root"
+
+	// FsckUnreadableBundle indicates that the file list of a bundle can't be retrieved
+	FsckUnreadableBundle FsckIssueKind = "unreadable-bundle"
+)
+
+// keys used by the local KV store to keep track of the progress of the check
+const (
+	fsckRootPrefix   = "root/"
+	fsckBundlePrefix = "bundle/"
+	fsckIssuePrefix  = "issue/"
+)
+
+type (
+	// FsckIssue describes an integrity issue found on a blob
+	FsckIssue struct {
+		Kind     FsckIssueKind `json:"kind"`
+		Repo     string        `json:"repo"`
+		BundleID string        `json:"bundle_id"`
+		File     string        `json:"file,omitempty"`
+		Key      string        `json:"key,omitempty"`
+		Repaired bool          `json:"repaired,omitempty"`
+	}
+
+	// FsckReport summarizes the integrity check of a context
+	FsckReport struct {
+		Repos          uint64
+		Bundles        uint64
+		SkippedBundles uint64 // bundles already checked by a resumed check
+		Files          uint64
+		RootKeys       uint64
+		Leaves         uint64
+		Repaired       uint64
+		Issues         []FsckIssue
+	}
+
+	// fsckKeyIssue is an issue found on a root key or one of its leaves, which is shared by all the files referring to this root key
+	fsckKeyIssue struct {
+		Kind     FsckIssueKind `json:"kind"`
+		Key      string        `json:"key"`
+		Repaired bool          `json:"repaired,omitempty"`
+	}
+
+	// fsckJournalEntry records a checked bundle and its issues
+	fsckJournalEntry struct {
+		Repo     string      `json:"repo"`
+		BundleID string      `json:"bundle_id"`
+		Issues   []FsckIssue `json:"issues,omitempty"`
+	}
+
+	fsckChecker struct {
+		blob        storage.Store
+		repair      storage.Store
+		db          kvStore
+		journal     *os.File
+		journalLock sync.Mutex
+		roots       singleflight.Group // root keys being checked
+		report      *FsckReport
+		options     *fsckOptions
+	}
+)
+
+// Fsck checks the integrity of the blobs used by the bundles of a context.
+//
+// All the bundles are scanned, their root keys exploded into leaves, and the existence (and optionally the hash) of every leaf is checked.
+// Root keys are checked only once, even when shared by several files.
+//
+// Missing or corrupted blobs may be repaired from the blob store of another context, provided the copy found there is valid.
+//
+// The progress of the check is journaled in a local folder, so an interrupted check may be resumed.
+// This operation can take quite a long time: there is some extra logging to keep track of the progress.
+func Fsck(stores context2.Stores, opts ...FsckOption) (*FsckReport, error) {
+	options := defaultFsckOptions(opts)
+	ctx := context.Background() // no timeout here
+
+	if options.repairFrom != nil {
+		// repairing blobs requires write access to the blob store
+		if err := checkACL("", stores, model.ACLAdmin, options.contributor); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := openKV(filepath.Join(options.localStorePath, "kv"), &purgeOptions{kvType: KVTypePebble, kvOptions: defaultKVOptions()})
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	c := &fsckChecker{
+		blob:    getBlobStore(stores),
+		db:      db,
+		report:  &FsckReport{},
+		options: options,
+	}
+	if options.repairFrom != nil {
+		c.repair = getBlobStore(options.repairFrom)
+	}
+
+	logger := options.l.With(
+		zap.Stringer("blob_store", c.blob),
+		zap.String("local_store_path", options.localStorePath),
+	)
+	if err = c.openJournal(filepath.Join(options.localStorePath, "journal.json"), logger); err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = c.journal.Close()
+	}()
+
+	logger.Info("checking blobs for context",
+		zap.Stringer("context metadata", stores.Metadata()),
+		zap.Bool("resume?", options.resume),
+		zap.Bool("verify_hash?", options.verifyHash),
+		zap.Bool("repair?", c.repair != nil),
+	)
+
+	repos := options.repos
+	if len(repos) == 0 {
+		descriptors, erl := ListRepos(stores, ConcurrentList(max(1, options.maxParallel/4)))
+		if erl != nil {
+			return nil, erl
+		}
+		for _, repo := range descriptors {
+			repos = append(repos, repo.Name)
+		}
+	}
+
+	for i, repo := range repos {
+		logger.Info("in-progress percent of repos",
+			zap.String("repo", repo),
+			zap.Int("percent_repos_in_context", int(float64(i)/float64(len(repos))*100.00)),
+		)
+		if err = c.checkRepo(ctx, stores, repo, logger.With(zap.String("repo", repo))); err != nil {
+			logger.Error("failed to check repo", zap.String("repo", repo), zap.Error(err))
+
+			return nil, err
+		}
+		c.report.Repos++
+	}
+
+	if c.report.Issues, err = c.issues(); err != nil {
+		return nil, err
+	}
+
+	logger.Info("checked blobs for context",
+		zap.Uint64("bundles", c.report.Bundles),
+		zap.Uint64("skipped_bundles", c.report.SkippedBundles),
+		zap.Uint64("root_keys", c.report.RootKeys),
+		zap.Uint64("leaves", c.report.Leaves),
+		zap.Int("issues", len(c.report.Issues)),
+		zap.Uint64("repaired", c.report.Repaired),
+	)
+
+	return c.report, nil
+}
+
+func (c *fsckChecker) checkRepo(ctx context.Context, stores context2.Stores, repo string, logger *zap.Logger) error {
+	group, gctx := errgroup.WithContext(ctx) // goroutines checking bundles
+	group.SetLimit(c.options.maxParallel)
+
+	err := ListBundlesApply(repo, stores, func(toPin model.BundleDescriptor) error {
+		select {
+		case <-gctx.Done():
+			// early failure
+			return gctx.Err()
+		default:
+		}
+
+		bundle := toPin
+		done, erx := c.db.Exists([]byte(fsckBundlePrefix + repo + "/" + bundle.ID))
+		if erx != nil {
+			return erx
+		}
+		if done {
+			// already checked by a previous run
+			atomic.AddUint64(&c.report.SkippedBundles, 1)
+
+			return nil
+		}
+
+		group.Go(func() error {
+			return c.checkBundle(gctx, stores, repo, bundle, logger)
+		})
+
+		return nil
+	},
+		ConcurrentList(max(10, c.options.maxParallel/4)),
+		WithIgnoreCorruptedMetadata(true), // ignore when bundle.yaml is corrupted (e.g. empty file)
+	)
+
+	erg := group.Wait()
+	if err != nil {
+		return err
+	}
+
+	return erg
+}
+
+func (c *fsckChecker) checkBundle(ctx context.Context, stores context2.Stores, repo string, bundle model.BundleDescriptor, logger *zap.Logger) error {
+	lg := logger.With(zap.String("bundle_id", bundle.ID))
+	b := NewBundle(
+		BundleID(bundle.ID),
+		Repo(repo),
+		BundleDescriptor(&bundle),
+		ContextStores(stores),
+		Logger(zap.NewNop()), // mute verbosity on retrieving bundle details
+	)
+
+	if err := backoff.Retry(func() error {
+		return unpackBundleFileList(ctx, b, false, defaultBundleEntriesPerFile)
+	},
+		backoff.WithContext(defaultBackoff(), ctx),
+	); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		lg.Warn("the metadata for this bundle cannot be read", zap.Error(err))
+
+		return c.bundleDone(fsckJournalEntry{
+			Repo:     repo,
+			BundleID: bundle.ID,
+			Issues:   []FsckIssue{{Kind: FsckUnreadableBundle, Repo: repo, BundleID: bundle.ID}},
+		})
+	}
+
+	done := fsckJournalEntry{Repo: repo, BundleID: bundle.ID}
+	for _, entry := range b.BundleEntries {
+		select {
+		// interrupted
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		var issues []fsckKeyIssue
+		root, err := cafs.KeyFromString(entry.Hash)
+		if err != nil {
+			issues = []fsckKeyIssue{{Kind: FsckCorruptedRoot, Key: entry.Hash}}
+		} else {
+			issues, err = c.checkRoot(ctx, root, bundle.LeafSize, lg)
+			if err != nil {
+				lg.Error("could not check root key", zap.String("key", entry.Hash), zap.Error(err))
+
+				return err
+			}
+		}
+
+		for _, issue := range issues {
+			done.Issues = append(done.Issues, FsckIssue{
+				Kind:     issue.Kind,
+				Repo:     repo,
+				BundleID: bundle.ID,
+				File:     entry.NameWithPath,
+				Key:      issue.Key,
+				Repaired: issue.Repaired,
+			})
+		}
+		atomic.AddUint64(&c.report.Files, 1)
+	}
+
+	atomic.AddUint64(&c.report.Bundles, 1)
+
+	return c.bundleDone(done)
+}
+
+// checkRoot checks a root key and all its leaves, unless this root key has already been checked.
+//
+// Concurrent checks of the same root key, from bundles sharing this key, are carried out only once.
+func (c *fsckChecker) checkRoot(ctx context.Context, root cafs.Key, leafSize uint32, logger *zap.Logger) ([]fsckKeyIssue, error) {
+	issues, err, _ := c.roots.Do(root.String(), func() (interface{}, error) {
+		return c.checkRootOnce(ctx, root, leafSize, logger)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issues.([]fsckKeyIssue), nil
+}
+
+func (c *fsckChecker) checkRootOnce(ctx context.Context, root cafs.Key, leafSize uint32, logger *zap.Logger) ([]fsckKeyIssue, error) {
+	kvKey := []byte(fsckRootPrefix + root.String())
+	found, err := c.db.Exists(kvKey)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		value, erg := c.db.Get(kvKey)
+		if erg != nil {
+			return nil, erg
+		}
+		var issues []fsckKeyIssue
+
+		return issues, json.Unmarshal(value, &issues)
+	}
+
+	issues, err := c.checkLeaves(ctx, root, leafSize, logger)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddUint64(&c.report.RootKeys, 1)
+
+	value, err := json.Marshal(issues)
+	if err != nil {
+		return nil, err
+	}
+
+	return issues, c.db.Set(kvKey, value)
+}
+
+func (c *fsckChecker) checkLeaves(ctx context.Context, root cafs.Key, leafSize uint32, logger *zap.Logger) ([]fsckKeyIssue, error) {
+	var issues []fsckKeyIssue
+
+	leaves, err := cafs.LeavesForHash(c.blob, root, leafSize, "")
+	if err != nil {
+		issue := fsckKeyIssue{Kind: FsckCorruptedRoot, Key: root.String()}
+		if errors.Is(err, storagestatus.ErrNotExists) {
+			issue.Kind = FsckDanglingRoot
+		}
+		logger.Warn("root key can't be exploded", zap.String("key", issue.Key), zap.String("issue", string(issue.Kind)), zap.Error(err))
+
+		if c.repair == nil {
+			return append(issues, issue), nil
+		}
+
+		if leaves, err = c.repairRoot(ctx, root, leafSize, logger); err != nil {
+			// no leaves to check further
+			return append(issues, issue), nil
+		}
+		issue.Repaired = true
+		issues = append(issues, issue)
+	}
+
+	for i := range leaves {
+		err = cafs.CheckLeaf(ctx, c.blob, leaves, i, leafSize, "", c.options.verifyHash)
+		atomic.AddUint64(&c.report.Leaves, 1)
+
+		var issue fsckKeyIssue
+		switch {
+		case err == nil:
+			continue
+		case errors.Is(err, storagestatus.ErrNotExists):
+			issue = fsckKeyIssue{Kind: FsckMissingLeaf, Key: leaves[i].String()}
+		case errors.Is(err, cafs.ErrCorruptedLeaf):
+			issue = fsckKeyIssue{Kind: FsckCorruptedLeaf, Key: leaves[i].String()}
+		default:
+			// the blob store can't be reached: bail
+			return nil, err
+		}
+		logger.Warn("leaf is not valid", zap.String("key", issue.Key), zap.String("root_key", root.String()), zap.String("issue", string(issue.Kind)))
+
+		if c.repair != nil {
+			issue.Repaired = c.repairLeaf(ctx, leaves, i, leafSize, logger) == nil
+		}
+		issues = append(issues, issue)
+	}
+
+	return issues, nil
+}
+
+// repairRoot copies a root key from the repair store, provided it is valid there
+func (c *fsckChecker) repairRoot(ctx context.Context, root cafs.Key, leafSize uint32, logger *zap.Logger) ([]cafs.Key, error) {
+	data, err := c.fetchRepair(ctx, root.String())
+	if err != nil {
+		logger.Warn("cannot repair root key", zap.String("key", root.String()), zap.Error(err))
+
+		return nil, err
+	}
+
+	leaves, err := cafs.LeafKeys(root, data, leafSize)
+	if err != nil {
+		logger.Warn("cannot repair root key: the copy is not valid", zap.String("key", root.String()), zap.Error(err))
+
+		return nil, err
+	}
+
+	if err = c.putRepaired(ctx, root.String(), data, logger); err != nil {
+		return nil, err
+	}
+
+	return leaves, nil
+}
+
+// repairLeaf copies a leaf from the repair store, provided it is valid there
+func (c *fsckChecker) repairLeaf(ctx context.Context, leaves []cafs.Key, index int, leafSize uint32, logger *zap.Logger) error {
+	key := leaves[index].String()
+	data, err := c.fetchRepair(ctx, key)
+	if err != nil {
+		logger.Warn("cannot repair leaf", zap.String("key", key), zap.Error(err))
+
+		return err
+	}
+
+	if err = cafs.VerifyLeaf(leaves, index, data, leafSize); err != nil {
+		logger.Warn("cannot repair leaf: the copy is not valid", zap.String("key", key), zap.Error(err))
+
+		return err
+	}
+
+	return c.putRepaired(ctx, key, data, logger)
+}
+
+func (c *fsckChecker) fetchRepair(ctx context.Context, key string) ([]byte, error) {
+	rdr, err := c.repair.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rdr.Close()
+	}()
+
+	return ioutil.ReadAll(rdr)
+}
+
+func (c *fsckChecker) putRepaired(ctx context.Context, key string, data []byte, logger *zap.Logger) error {
+	if err := backoff.Retry(func() error {
+		return c.blob.Put(ctx, key, bytes.NewReader(data), storage.OverWrite)
+	},
+		backoff.WithContext(defaultBackoff(), ctx),
+	); err != nil {
+		logger.Error("cannot repair blob", zap.String("key", key), zap.Error(err))
+
+		return err
+	}
+
+	logger.Info("repaired blob", zap.String("key", key), zap.Stringer("repaired_from", c.repair))
+	atomic.AddUint64(&c.report.Repaired, 1)
+
+	return nil
+}
+
+// bundleDone appends a checked bundle to the journal, and marks it as done in the local KV store
+func (c *fsckChecker) bundleDone(entry fsckJournalEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	c.journalLock.Lock()
+	_, err = c.journal.Write(append(line, '\n'))
+	c.journalLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return c.markDone(entry)
+}
+
+// markDone records the issues of a checked bundle in the local KV store
+func (c *fsckChecker) markDone(entry fsckJournalEntry) error {
+	for _, issue := range entry.Issues {
+		value, err := json.Marshal(issue)
+		if err != nil {
+			return err
+		}
+		if err = c.db.Set([]byte(fsckIssuePrefix+issue.Repo+"/"+issue.BundleID+"/"+issue.File+"/"+issue.Key), value); err != nil {
+			return err
+		}
+	}
+
+	return c.db.Set([]byte(fsckBundlePrefix+entry.Repo+"/"+entry.BundleID), []byte{})
+}
+
+// openJournal opens the journal of checked bundles. When resuming, bundles already checked are reloaded into the local KV store.
+//
+// NOTE: the local KV store is scratched whenever opened, so the journal is the only persistent record of the progress.
+func (c *fsckChecker) openJournal(pth string, logger *zap.Logger) error {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !c.options.resume {
+		flags |= os.O_TRUNC
+	}
+
+	if c.options.resume {
+		if err := c.loadJournal(pth, logger); err != nil {
+			return err
+		}
+	}
+
+	journal, err := os.OpenFile(pth, flags, 0600)
+	if err != nil {
+		return err
+	}
+	c.journal = journal
+
+	return nil
+}
+
+func (c *fsckChecker) loadJournal(pth string, logger *zap.Logger) error {
+	journal, err := os.Open(pth)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logger.Warn("no journal found: the check starts from scratch", zap.String("journal", pth))
+
+			return nil
+		}
+
+		return err
+	}
+	defer func() {
+		_ = journal.Close()
+	}()
+
+	var bundles int
+	scanner := bufio.NewScanner(journal)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*MB)
+	for scanner.Scan() {
+		var entry fsckJournalEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// the last entry was interrupted while being written
+			logger.Warn("skipping truncated journal entry", zap.Error(err))
+
+			break
+		}
+		if err = c.markDone(entry); err != nil {
+			return err
+		}
+		bundles++
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+
+	logger.Info("reloaded bundles already checked", zap.Int("bundles", bundles))
+
+	return nil
+}
+
+// issues retrieves all the issues recorded in the local KV store, sorted by repo, bundle and file
+func (c *fsckChecker) issues() ([]FsckIssue, error) {
+	iterator := c.db.AllKeys()
+	defer func() {
+		_ = iterator.Close()
+	}()
+
+	issues := make([]FsckIssue, 0, 10)
+	for iterator.Next() {
+		key, value, err := iterator.Item()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(string(key), fsckIssuePrefix) {
+			continue
+		}
+
+		var issue FsckIssue
+		if err = json.Unmarshal(value, &issue); err != nil {
+			return nil, err
+		}
+		issues = append(issues, issue)
+	}
+
+	return issues, nil
+}
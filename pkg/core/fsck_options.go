@@ -0,0 +1,104 @@
+package core
+
+import (
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/dlogger"
+	"github.com/oneconcern/datamon/pkg/model"
+	"go.uber.org/zap"
+)
+
+type (
+	// FsckOption modifies the behavior of the integrity check of a context.
+	FsckOption func(*fsckOptions)
+
+	fsckOptions struct {
+		l              *zap.Logger
+		repos          []string
+		maxParallel    int
+		localStorePath string
+		resume         bool
+		verifyHash     bool
+		repairFrom     context2.Stores
+		contributor    model.Contributor
+	}
+)
+
+// WithFsckLogger sets the logger used by the integrity check
+func WithFsckLogger(zlg *zap.Logger) FsckOption {
+	return func(o *fsckOptions) {
+		if zlg != nil {
+			o.l = zlg
+		}
+	}
+}
+
+// WithFsckRepos restricts the integrity check to some repos. By default, all repos are checked.
+func WithFsckRepos(repos ...string) FsckOption {
+	return func(o *fsckOptions) {
+		o.repos = repos
+	}
+}
+
+// WithFsckParallel sets the number of bundles checked in parallel
+func WithFsckParallel(parallel int) FsckOption {
+	return func(o *fsckOptions) {
+		if parallel > 0 {
+			o.maxParallel = parallel
+		}
+	}
+}
+
+// WithFsckLocalStore sets the local folder used to keep track of the progress of the check
+func WithFsckLocalStore(pth string) FsckOption {
+	return func(o *fsckOptions) {
+		if pth != "" {
+			o.localStorePath = pth
+		}
+	}
+}
+
+// WithFsckResume resumes an interrupted integrity check: bundles already checked are skipped,
+// and the issues found so far are reported.
+func WithFsckResume(enabled bool) FsckOption {
+	return func(o *fsckOptions) {
+		o.resume = enabled
+	}
+}
+
+// WithFsckVerifyHash retrieves the content of every leaf and verifies its hash.
+//
+// By default, only the existence of leaves is checked.
+func WithFsckVerifyHash(enabled bool) FsckOption {
+	return func(o *fsckOptions) {
+		o.verifyHash = enabled
+	}
+}
+
+// WithFsckRepairFrom repairs missing or corrupted blobs by copying them from the blob store of another context.
+func WithFsckRepairFrom(stores context2.Stores) FsckOption {
+	return func(o *fsckOptions) {
+		o.repairFrom = stores
+	}
+}
+
+// WithFsckContributor sets the identity of the contributor running the integrity check, who must be an admin
+// of the context to repair blobs, whenever access control lists are set.
+func WithFsckContributor(contributor model.Contributor) FsckOption {
+	return func(o *fsckOptions) {
+		o.contributor = contributor
+	}
+}
+
+func defaultFsckOptions(opts []FsckOption) *fsckOptions {
+	o := &fsckOptions{
+		l:              dlogger.MustGetLogger("info"),
+		maxParallel:    10,
+		localStorePath: ".datamon-fsck",
+	}
+
+	for _, apply := range opts {
+		apply(o)
+	}
+
+	return o
+}
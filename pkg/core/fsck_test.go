@@ -0,0 +1,175 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oneconcern/datamon/pkg/cafs"
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFsck(t *testing.T) {
+	const repo = "fsck-test-repo"
+	tmp, err := ioutil.TempDir("", "test-fsck-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	dir := func(parts ...string) string {
+		pth := filepath.Join(append([]string{tmp}, parts...)...)
+		require.NoError(t, os.MkdirAll(pth, 0700))
+		return pth
+	}
+
+	stores := mocks.FakeContext2(dir("meta"), dir("vmeta"), dir("blob"))
+	require.NoError(t, CreateRepo(model.RepoDescriptor{Name: repo, Description: "test"}, stores))
+
+	original := dir("original")
+	for _, name := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(original, name), []byte("content of "+name), 0600))
+	}
+	bundle := NewBundle(
+		Repo(repo),
+		BundleDescriptor(model.NewBundleDescriptor(model.Message("fsck"))),
+		ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), original))),
+		ContextStores(stores),
+		Logger(mocks.TestLogger()),
+	)
+	require.NoError(t, Upload(context.Background(), bundle))
+	require.NoError(t, unpackBundleFileList(context.Background(), bundle, false, defaultBundleEntriesPerFile))
+
+	// keep a copy of all blobs in some other context, before damaging them
+	blob := stores.Blob()
+	backup := mocks.FakeContext2(dir("backup", "meta"), "", dir("backup", "blob"))
+	keys, err := blob.Keys(context.Background())
+	require.NoError(t, err)
+	for _, key := range keys {
+		rdr, erg := blob.Get(context.Background(), key)
+		require.NoError(t, erg)
+		data, erg := ioutil.ReadAll(rdr)
+		require.NoError(t, erg)
+		_ = rdr.Close()
+		require.NoError(t, backup.Blob().Put(context.Background(), key, bytes.NewReader(data), storage.NoOverWrite))
+	}
+
+	leafOf := func(t testing.TB, name string) (cafs.Key, string) {
+		for _, entry := range bundle.BundleEntries {
+			if entry.NameWithPath != name {
+				continue
+			}
+			root, erk := cafs.KeyFromString(entry.Hash)
+			require.NoError(t, erk)
+			leaves, erk := cafs.LeavesForHash(blob, root, bundle.BundleDescriptor.LeafSize, "")
+			require.NoError(t, erk)
+			require.Len(t, leaves, 1)
+			return root, leaves[0].String()
+		}
+		require.Failf(t, "entry not found", "name: %s", name)
+		return cafs.Key{}, ""
+	}
+
+	fsck := func(t testing.TB, opts ...FsckOption) *FsckReport {
+		report, erf := Fsck(stores, append([]FsckOption{
+			WithFsckLogger(mocks.TestLogger()),
+			WithFsckLocalStore(filepath.Join(tmp, "fsck-index")),
+			WithFsckParallel(2),
+		}, opts...)...)
+		require.NoError(t, erf)
+		return report
+	}
+	kinds := func(report *FsckReport) map[string]FsckIssueKind {
+		found := make(map[string]FsckIssueKind, len(report.Issues))
+		for _, issue := range report.Issues {
+			assert.Equal(t, repo, issue.Repo)
+			assert.Equal(t, bundle.BundleID, issue.BundleID)
+			found[issue.File] = issue.Kind
+		}
+		return found
+	}
+
+	t.Run("should report no issue on a sane context", func(t *testing.T) {
+		report := fsck(t, WithFsckVerifyHash(true))
+		assert.Empty(t, report.Issues)
+		assert.Equal(t, uint64(1), report.Repos)
+		assert.Equal(t, uint64(1), report.Bundles)
+		assert.Equal(t, uint64(4), report.Files)
+		assert.Equal(t, uint64(4), report.RootKeys)
+		assert.Equal(t, uint64(4), report.Leaves)
+	})
+
+	// damage blobs: a missing leaf, a corrupted leaf, a missing root key
+	_, missing := leafOf(t, "a")
+	require.NoError(t, blob.Delete(context.Background(), missing))
+	_, corrupted := leafOf(t, "b")
+	require.NoError(t, blob.Put(context.Background(), corrupted, bytes.NewReader([]byte("garbage")), storage.OverWrite))
+	dangling, _ := leafOf(t, "c")
+	require.NoError(t, blob.Delete(context.Background(), dangling.String()))
+
+	t.Run("should detect missing leaves and dangling root keys", func(t *testing.T) {
+		report := fsck(t)
+		assert.Equal(t, map[string]FsckIssueKind{
+			"a": FsckMissingLeaf,
+			"c": FsckDanglingRoot,
+		}, kinds(report))
+	})
+
+	t.Run("should detect corrupted leaves when verifying hashes", func(t *testing.T) {
+		report := fsck(t, WithFsckVerifyHash(true))
+		assert.Equal(t, map[string]FsckIssueKind{
+			"a": FsckMissingLeaf,
+			"b": FsckCorruptedLeaf,
+			"c": FsckDanglingRoot,
+		}, kinds(report))
+		assert.Equal(t, uint64(4), report.RootKeys)
+	})
+
+	t.Run("should resume and report issues found so far", func(t *testing.T) {
+		report := fsck(t, WithFsckVerifyHash(true), WithFsckResume(true))
+		assert.Equal(t, uint64(0), report.Bundles)
+		assert.Equal(t, uint64(1), report.SkippedBundles)
+		assert.Len(t, report.Issues, 3)
+	})
+
+	t.Run("should repair blobs from another context", func(t *testing.T) {
+		report := fsck(t, WithFsckVerifyHash(true), WithFsckRepairFrom(backup))
+		assert.Len(t, report.Issues, 3)
+		for _, issue := range report.Issues {
+			assert.True(t, issue.Repaired, "expected %s to be repaired", issue.File)
+		}
+		assert.Equal(t, uint64(3), report.Repaired)
+
+		report = fsck(t, WithFsckVerifyHash(true))
+		assert.Empty(t, report.Issues)
+	})
+
+	t.Run("should check root keys shared by bundles only once", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			copied := NewBundle(
+				Repo(repo),
+				BundleDescriptor(model.NewBundleDescriptor(model.Message("fsck copy"))),
+				ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), original))),
+				ContextStores(stores),
+				Logger(mocks.TestLogger()),
+			)
+			require.NoError(t, Upload(context.Background(), copied))
+		}
+
+		report := fsck(t, WithFsckParallel(4))
+		assert.Empty(t, report.Issues)
+		assert.Equal(t, uint64(4), report.Bundles)
+		assert.Equal(t, uint64(16), report.Files)
+		assert.Equal(t, uint64(4), report.RootKeys)
+		assert.Equal(t, uint64(4), report.Leaves)
+	})
+}
@@ -16,6 +16,8 @@ import (
 	"github.com/oneconcern/datamon/pkg/core/status"
 	"github.com/oneconcern/datamon/pkg/model"
 	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/oneconcern/datamon/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Label describes a bundle label.
@@ -61,6 +63,25 @@ func (label *Label) UploadDescriptor(ctx context.Context, bundle *Bundle) (err e
 	if err != nil {
 		return err
 	}
+
+	err = checkACL(bundle.RepoID, bundle.contextStores, model.ACLWriter, label.Descriptor.Contributors...)
+	if err != nil {
+		return err
+	}
+
+	return label.uploadDescriptor(ctx, bundle)
+}
+
+func (label *Label) uploadDescriptor(ctx context.Context, bundle *Bundle) (err error) {
+	ctx, span := tracing.Start(ctx, "core.UploadLabel",
+		attribute.String("repo", bundle.RepoID),
+		attribute.String("label", label.Descriptor.Name),
+		attribute.String("bundle", bundle.BundleID),
+	)
+	defer func() {
+		tracing.End(span, err)
+	}()
+
 	label.Descriptor.BundleID = bundle.BundleID
 	buffer, err := yaml.Marshal(label.Descriptor)
 	if err != nil {
@@ -2,8 +2,10 @@ package core
 
 import (
 	"runtime"
+	"time"
 
 	"github.com/oneconcern/datamon/pkg/metrics"
+	"github.com/oneconcern/datamon/pkg/model"
 )
 
 // Option sets options for listing core objects
@@ -26,6 +28,13 @@ type Settings struct {
 	retainSemverTags        bool
 	retainNLatest           int
 	withMinimalBundle       bool
+	diamondTTL              time.Duration
+	splitTTL                time.Duration
+	dryRun                  bool
+	contributor             model.Contributor
+	checkpoint              string
+	reused                  []model.BundleEntry  // entries recorded without uploading files again
+	modTimes                map[string]time.Time // modification times recorded with uploaded entries
 	// m *M // TODO(fred): enable metrics for list operations
 }
 
@@ -129,11 +138,60 @@ func WithMinimalBundle(enabled bool) Option {
 	}
 }
 
+// WithDiamondTTL sets the time-to-live of a diamond with no activity, before it is considered abandoned.
+//
+// It defaults to model.DefaultDiamondTTL.
+func WithDiamondTTL(ttl time.Duration) Option {
+	return func(s *Settings) {
+		if ttl > 0 {
+			s.diamondTTL = ttl
+		}
+	}
+}
+
+// WithSplitTTL sets the time-to-live of a running split, before it is considered abandoned.
+//
+// It defaults to model.DefaultSplitTTL.
+func WithSplitTTL(ttl time.Duration) Option {
+	return func(s *Settings) {
+		if ttl > 0 {
+			s.splitTTL = ttl
+		}
+	}
+}
+
+// WithDryRun reports about the actions to be carried out, but doesn't modify anything
+func WithDryRun(enabled bool) Option {
+	return func(s *Settings) {
+		s.dryRun = enabled
+	}
+}
+
+// WithContributor sets the identity of the contributor performing an operation, as checked against access control lists
+func WithContributor(contributor model.Contributor) Option {
+	return func(s *Settings) {
+		s.contributor = contributor
+	}
+}
+
+// WithUploadCheckpoint journals the files uploaded for a bundle in a local file.
+//
+// An interrupted upload resumes from the files recorded in this journal when run again with the same checkpoint.
+// Files with a size or modification time different from the recorded ones are uploaded again.
+// The journal is removed once the bundle is uploaded.
+func WithUploadCheckpoint(path string) Option {
+	return func(s *Settings) {
+		s.checkpoint = path
+	}
+}
+
 func defaultSettings() Settings {
 	return Settings{
 		concurrentList: defaultListConcurrency,
 		batchSize:      defaultBatchSize,
 		memProfDir:     ".",
 		retainNLatest:  1,
+		diamondTTL:     model.DefaultDiamondTTL,
+		splitTTL:       model.DefaultSplitTTL,
 	}
 }
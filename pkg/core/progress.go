@@ -0,0 +1,127 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// Operations reporting progress
+const (
+	ProgressUpload        = "upload"
+	ProgressDownload      = "download"
+	ProgressReverseLookup = "reverse-lookup"
+	ProgressCommit        = "commit"
+)
+
+// Phases of an operation reporting progress
+const (
+	PhaseList     = "list"     // listing the files to process
+	PhaseMetadata = "metadata" // retrieving or uploading bundle metadata
+	PhaseUpload   = "upload"   // uploading files
+	PhaseDownload = "download" // downloading files
+	PhaseScan     = "scan"     // scanning repos to index the blob keys they use
+	PhaseMerge    = "merge"    // merging the file lists of diamond splits
+	PhaseIndex    = "index"    // uploading file lists or index files
+	PhaseDone     = "done"     // the operation completed successfully
+	PhaseFailed   = "failed"   // the operation failed: the event reports the error
+)
+
+// ProgressEvent reports about the advancement of a long-running operation.
+//
+// Counters are reset at the start of each phase. Totals are zero whenever they are not known in advance.
+type ProgressEvent struct {
+	Operation  string    `json:"operation"`
+	Phase      string    `json:"phase"`
+	Files      int64     `json:"files"`
+	TotalFiles int64     `json:"total_files,omitempty"`
+	Bytes      uint64    `json:"bytes"`
+	TotalBytes uint64    `json:"total_bytes,omitempty"`
+	File       string    `json:"file,omitempty"` // the last file processed
+	Error      string    `json:"error,omitempty"`
+	Time       time.Time `json:"time"`
+}
+
+// ProgressFunc receives progress events.
+//
+// Events are delivered one at a time, but from the goroutines carrying out the operation:
+// a ProgressFunc should return quickly.
+type ProgressFunc func(ProgressEvent)
+
+// progress tracks the advancement of an operation and reports it as events.
+//
+// A nil progress reports nothing.
+type progress struct {
+	mx     sync.Mutex
+	fn     ProgressFunc
+	event  ProgressEvent
+	closed bool
+}
+
+func newProgress(operation string, fn ProgressFunc) *progress {
+	if fn == nil {
+		return nil
+	}
+	return &progress{
+		fn:    fn,
+		event: ProgressEvent{Operation: operation},
+	}
+}
+
+// phase starts a new phase, with the totals expected for this phase, if known
+func (p *progress) phase(phase string, totalFiles int, totalBytes uint64) {
+	if p == nil {
+		return
+	}
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.event.Phase = phase
+	p.event.Files = 0
+	p.event.TotalFiles = int64(totalFiles)
+	p.event.Bytes = 0
+	p.event.TotalBytes = totalBytes
+	p.event.File = ""
+	p.emit()
+}
+
+// add records one more file processed
+func (p *progress) add(file string, size uint64) {
+	if p == nil {
+		return
+	}
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	p.event.Files++
+	p.event.Bytes += size
+	p.event.File = file
+	p.emit()
+}
+
+// finish reports the completion of the operation, or its failure
+func (p *progress) finish(err error) {
+	if p == nil {
+		return
+	}
+	p.mx.Lock()
+	defer p.mx.Unlock()
+
+	if err != nil {
+		p.event.Phase = PhaseFailed
+		p.event.Error = err.Error()
+	} else {
+		p.event.Phase = PhaseDone
+	}
+	p.event.File = ""
+	p.emit()
+	p.closed = true
+}
+
+func (p *progress) emit() {
+	if p.closed {
+		// late events from goroutines still running after a failure
+		return
+	}
+	p.event.Time = time.Now().UTC()
+	p.fn(p.event)
+}
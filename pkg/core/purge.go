@@ -49,7 +49,7 @@ type (
 // of all used blob keys.
 //
 // This operation can take quite a long time: there is some extra logging to keep track of the progress.
-func PurgeBuildReverseIndex(stores context2.Stores, opts ...PurgeOption) (*PurgeIndex, error) {
+func PurgeBuildReverseIndex(stores context2.Stores, opts ...PurgeOption) (index *PurgeIndex, err error) {
 	// 1. scan all repos, all bundles
 	// 2. Fetch root key, explode root key
 	// 3. Add root key and children keys to index
@@ -58,6 +58,14 @@ func PurgeBuildReverseIndex(stores context2.Stores, opts ...PurgeOption) (*Purge
 	options := defaultPurgeOptions(opts)
 	ctx := context.Background() // no timeout here
 	indexTime := time.Now().UTC()
+	options.progress = newProgress(ProgressReverseLookup, options.progressFn)
+	defer func() {
+		options.progress.finish(err)
+	}()
+
+	if err = checkACL("", stores, model.ACLAdmin, options.contributor); err != nil {
+		return nil, err
+	}
 
 	db, err := openKV(options.localStorePath, options)
 	if err != nil {
@@ -144,6 +152,7 @@ func PurgeBuildReverseIndex(stores context2.Stores, opts ...PurgeOption) (*Purge
 
 	if err == nil {
 		close(doneScanning)
+		options.progress.phase(PhaseIndex, 0, 0)
 
 		logger.Info("all contexts successfully scanned. Waiting for all index keys to be uploaded",
 			zap.Uint64("unique_keys", uniqueKeys),
@@ -190,6 +199,7 @@ func scanContext(ctx context.Context, contextStore context2.Stores, indexStore s
 	lg.Info("scanning repos",
 		zap.Int("num_repos_in_context", len(repos)),
 	)
+	options.progress.phase(PhaseScan, len(repos), 0)
 
 	reposGroup, gctx := errgroup.WithContext(ctx) // goroutines scanning for keys in metadata
 	reposGroup.SetLimit(options.maxParallel)
@@ -331,9 +341,49 @@ func repoKeysScanner(ctx context.Context, contextStore context2.Stores, repo mod
 			return err
 		}
 
+		// files uploaded by live diamonds are not part of any bundle yet, but are used
+		entries, err := liveDiamondsEntries(repo.Name, contextStore,
+			WithDiamondTTL(options.diamondTTL),
+			WithSplitTTL(options.splitTTL),
+		)
+		if err != nil {
+			lg.Error("could not retrieve keys for diamonds in repo", zap.Error(err))
+
+			return err
+		}
+
+		if len(entries) > 0 {
+			b := NewBundle(
+				Repo(repo.Name),
+				ContextStores(contextStore),
+				Logger(zap.NewNop()),
+			)
+			b.BundleEntries = entries
+
+			keys, erk := entriesKeys(b, b.BundleDescriptor.LeafSize, db, lg)
+			if erk != nil {
+				return erk
+			}
+
+			for _, key := range keys {
+				if eru := db.SetIfNotExists([]byte(key), []byte{}); eru != nil {
+					return eru
+				}
+			}
+			atomic.AddUint64(countPtr, uint64(len(keys)))
+			atomic.AddUint64(countUniquePtr, uint64(len(keys)))
+			atomic.AddUint64(&repoCount, uint64(len(keys)))
+
+			lg.Info("scanned files uploaded by live diamonds",
+				zap.Int("num_entries", len(entries)),
+				zap.Int("num_keys", len(keys)),
+			)
+		}
+
 		lg.Info("finished scanning repo entries",
 			zap.Uint64("repo_keys", repoCount),
 		)
+		options.progress.add(repo.Name, 0)
 
 		return nil
 	}
@@ -524,6 +574,11 @@ func bundleKeys(ctx context.Context, b *Bundle, size uint32, db kvStore, logger
 		return nil, nil
 	}
 
+	return entriesKeys(b, size, db, logger)
+}
+
+// entriesKeys yields the root keys and leaf keys of the bundle entries which are not already in the KV store
+func entriesKeys(b *Bundle, size uint32, db kvStore, logger *zap.Logger) ([]string, error) {
 	keys := make([]string, 0, 1024)
 
 	logger.Debug("unpacked file entries for bundle", zap.Int("num_entries", len(b.BundleEntries)))
@@ -573,6 +628,10 @@ func bundleKeys(ctx context.Context, b *Bundle, size uint32, db kvStore, logger
 // PurgeDeleteUnused deletes blob entries that are not referenced by the reserve-lookup index.
 func PurgeDeleteUnused(stores context2.Stores, opts ...PurgeOption) (*PurgeBlobs, error) {
 	options := defaultPurgeOptions(opts)
+	if err := checkACL("", stores, model.ACLAdmin, options.contributor); err != nil {
+		return nil, err
+	}
+
 	indexStore := getMetaStore(stores)
 	indexPath := model.ReverseIndex()
 	logger := options.l.With(
@@ -999,6 +1058,10 @@ func PurgeDropReverseIndex(stores context2.Stores, opts ...PurgeOption) error {
 	)
 	ctx := context.Background()
 
+	if err := checkACL("", stores, model.ACLAdmin, options.contributor); err != nil {
+		return err
+	}
+
 	logger.Info("deleting index files", zap.String("index_prefix", indexPath))
 	iterator := func(next string) ([]string, string, error) {
 		return indexStore.KeysPrefix(ctx, next, model.ReverseIndexPrefix(), "", 1024)
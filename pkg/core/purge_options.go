@@ -5,6 +5,7 @@ import (
 
 	context2 "github.com/oneconcern/datamon/pkg/context"
 	"github.com/oneconcern/datamon/pkg/dlogger"
+	"github.com/oneconcern/datamon/pkg/model"
 	"go.uber.org/zap"
 )
 
@@ -36,6 +37,11 @@ type (
 		monitorInterval  time.Duration
 		indexStart       uint64
 		kvType           KVType
+		diamondTTL       time.Duration
+		splitTTL         time.Duration
+		contributor      model.Contributor
+		progressFn       ProgressFunc
+		progress         *progress // tracks the progress of the ongoing operation
 
 		kvOptions
 	}
@@ -114,6 +120,42 @@ func WithKVType(kvType KVType) PurgeOption {
 	}
 }
 
+// WithPurgeDiamondTTL sets the time-to-live of diamonds with no activity.
+//
+// Files uploaded by diamonds which are not committed yet are retained by the reverse-lookup index,
+// unless the diamond is canceled or stale.
+func WithPurgeDiamondTTL(ttl time.Duration) PurgeOption {
+	return func(o *purgeOptions) {
+		o.diamondTTL = ttl
+	}
+}
+
+// WithPurgeSplitTTL sets the time-to-live of running splits.
+//
+// Files uploaded by running splits are retained by the reverse-lookup index, unless the split is orphaned or stale.
+func WithPurgeSplitTTL(ttl time.Duration) PurgeOption {
+	return func(o *purgeOptions) {
+		o.splitTTL = ttl
+	}
+}
+
+// WithPurgeContributor sets the identity of the contributor running the purge, who must be an admin of the context
+// whenever access control lists are set.
+func WithPurgeContributor(contributor model.Contributor) PurgeOption {
+	return func(o *purgeOptions) {
+		o.contributor = contributor
+	}
+}
+
+// WithPurgeProgress reports the progress of the reverse-lookup index construction as events sent to a callback.
+//
+// The index reports the repos scanned in each context, then the upload of the remaining index files.
+func WithPurgeProgress(fn ProgressFunc) PurgeOption {
+	return func(o *purgeOptions) {
+		o.progressFn = fn
+	}
+}
+
 func defaultPurgeOptions(opts []PurgeOption) *purgeOptions {
 	o := &purgeOptions{
 		localStorePath:   ".datamon-index",
@@ -12,8 +12,14 @@ import (
 	"github.com/oneconcern/datamon/pkg/storage"
 )
 
-// RenameRepo renames a repository in metadata
-func RenameRepo(repo, newRepo string, stores context2.Stores) error {
+// RenameRepo renames a repository in metadata.
+//
+// Access control lists on the repo are transferred to the new repo.
+func RenameRepo(repo, newRepo string, stores context2.Stores, opts ...Option) error {
+	settings := defaultSettings()
+	for _, apply := range opts {
+		apply(&settings)
+	}
 
 	if err := RepoExists(repo, stores); err != nil {
 		return fmt.Errorf("cannot find repo: %s: %v", repo, err)
@@ -23,6 +29,14 @@ func RenameRepo(repo, newRepo string, stores context2.Stores) error {
 		return fmt.Errorf("new repo %s already exists", newRepo)
 	}
 
+	if err := checkACL(repo, stores, model.ACLAdmin, settings.contributor); err != nil {
+		return err
+	}
+
+	if err := checkACL("", stores, model.ACLWriter, settings.contributor); err != nil {
+		return err
+	}
+
 	// 1. create new repo
 	desc, err := GetRepo(repo, stores)
 	if err != nil {
@@ -31,7 +45,10 @@ func RenameRepo(repo, newRepo string, stores context2.Stores) error {
 
 	newDesc := *desc
 	newDesc.Name = newRepo
-	err = CreateRepo(newDesc, stores)
+	if err = model.ValidateRepo(newDesc); err != nil {
+		return err
+	}
+	err = createRepo(newDesc, stores)
 	if err != nil {
 		return fmt.Errorf("cannot create new repo %s: %v", newRepo, err)
 	}
@@ -84,13 +101,25 @@ func RenameRepo(repo, newRepo string, stores context2.Stores) error {
 	err = ListLabelsApply(repo, stores, func(label model.LabelDescriptor) error {
 		l := NewLabel(LabelDescriptor(&label))
 		b := NewBundle(ContextStores(stores), BundleID(label.BundleID), Repo(newRepo))
-		return l.UploadDescriptor(ctx, b)
+		return l.uploadDescriptor(ctx, b)
 	})
 	if err != nil {
 		return fmt.Errorf("cannot copy labels in repo %s: %v", repo, err)
 	}
 
-	err = DeleteRepo(repo, stores)
+	// 4. copy ACL to new repo
+	acl, err := GetACL(repo, stores)
+	if err != nil {
+		return fmt.Errorf("cannot retrieve ACL for repo %s: %v", repo, err)
+	}
+	if !acl.IsEmpty() {
+		acl.Repo = newRepo
+		if err = putACL(acl, stores); err != nil {
+			return fmt.Errorf("cannot copy ACL in repo %s: %v", repo, err)
+		}
+	}
+
+	err = DeleteRepo(repo, stores, WithDeleteContributor(settings.contributor))
 	if err != nil {
 		return fmt.Errorf("new repo has been created, but couldn't remove original repo: %v", err)
 	}
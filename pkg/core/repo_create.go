@@ -16,13 +16,21 @@ import (
 // CreateRepo persists a repository with a repo descriptor and some context's stores
 func CreateRepo(repo model.RepoDescriptor, stores context2.Stores) error {
 	// TODO(fred): refact options etc to expose a consistent interface, plus support metrics
-	store := GetRepoStore(stores) // TODO: Integrate with WAL.
 	err := model.ValidateRepo(repo)
 	if err != nil {
 		return err
 	}
-	r, e := yaml.Marshal(repo)
-	if e != nil {
+	err = checkACL("", stores, model.ACLWriter, repo.Contributor)
+	if err != nil {
+		return err
+	}
+	return createRepo(repo, stores)
+}
+
+func createRepo(repo model.RepoDescriptor, stores context2.Stores) error {
+	store := GetRepoStore(stores) // TODO: Integrate with WAL.
+	r, err := yaml.Marshal(repo)
+	if err != nil {
 		return err
 	}
 	path := model.GetArchivePathToRepoDescriptor(repo.Name)
@@ -15,11 +15,6 @@ func RepoSquash(stores context2.Stores, repoName string, opts ...Option) error {
 
 	opts = append(opts, WithMinimalBundle(true)) // limits I/Os with remote store: we only need keys
 
-	bundles, err := ListBundles(repoName, stores, opts...)
-	if err != nil {
-		return err
-	}
-
 	settings := defaultSettings()
 	for _, bApply := range opts {
 		bApply(&settings)
@@ -28,6 +23,15 @@ func RepoSquash(stores context2.Stores, repoName string, opts ...Option) error {
 		settings.retainNLatest = 1
 	}
 
+	if err := checkACL(repoName, stores, model.ACLAdmin, settings.contributor); err != nil {
+		return err
+	}
+
+	bundles, err := ListBundles(repoName, stores, opts...)
+	if err != nil {
+		return err
+	}
+
 	if len(bundles) < settings.retainNLatest+1 {
 		// nothing to be squashed
 		return nil
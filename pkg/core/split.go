@@ -107,6 +107,10 @@ func (s *Split) implUpload(opts ...Option) error {
 		return status.ErrSplitAlreadyDone
 	}
 
+	if err = checkACL(s.RepoID, s.contextStores, model.ACLWriter, s.SplitDescriptor.Contributors...); err != nil {
+		return err
+	}
+
 	settings := defaultSettings()
 	for _, apply := range opts {
 		apply(&settings)
@@ -209,7 +213,7 @@ func (s *Split) WithState(state model.SplitState) *Split {
 	case model.SplitRunning:
 		s.SplitDescriptor.StartTime = model.GetBundleTimeStamp()
 		s.SplitDescriptor.EndTime = time.Time{}
-	case model.SplitDone:
+	case model.SplitDone, model.SplitOrphaned:
 		s.SplitDescriptor.EndTime = model.GetBundleTimeStamp()
 	}
 	return s
@@ -63,6 +63,9 @@ var (
 	// ErrSplitUpdate tells there is an error when uploading a split descriptor the vmetadata store
 	ErrSplitUpdate = errors.New("cannot update split descriptor")
 
+	// ErrForbidden indicates that an operation is not permitted by the access control lists
+	ErrForbidden = errors.New("operation forbidden by access control lists")
+
 	// ErrVersionedStoreRequired indicates that a versioned store is required to operate on versioned objects (e.g. labels)
 	ErrVersionedStoreRequired = errors.New("versioned store is required")
 )
@@ -0,0 +1,295 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cenkalti/backoff/v4"
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/errors"
+	"github.com/oneconcern/datamon/pkg/storage"
+	storagestatus "github.com/oneconcern/datamon/pkg/storage/status"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+type (
+	// SyncStoreReport summarizes the synchronization of one store with its replica
+	SyncStoreReport struct {
+		Store   string `json:"store"`
+		Replica string `json:"replica"`
+		Scanned uint64 `json:"scanned"`
+		Missing uint64 `json:"missing"` // objects missing or outdated on the replica
+		Copied  uint64 `json:"copied"`
+		Failed  uint64 `json:"failed"`
+	}
+
+	// SyncReport summarizes the synchronization of a context with its replica
+	SyncReport struct {
+		Stores []SyncStoreReport
+	}
+
+	syncPair struct {
+		name    string
+		source  storage.Store
+		replica storage.Store
+		mutable bool
+	}
+)
+
+// Failed yields the total number of objects that could not be copied to the replica
+func (r SyncReport) Failed() uint64 {
+	var failed uint64
+	for _, s := range r.Stores {
+		failed += s.Failed
+	}
+	return failed
+}
+
+// SyncContext copies to the replica of a context any object from the blob, metadata and vmetadata stores
+// which is missing on the replica.
+//
+// Writes to a replicated context are duplicated to the replica, but failures on the replica are tolerated:
+// this brings the replica up to date.
+//
+// Blobs and metadata are immutable: only missing objects are copied. Versioned metadata such as labels
+// may be updated: objects are copied again whenever the replica holds a different version.
+//
+// Objects that could not be copied are logged and counted in the report.
+func SyncContext(stores, replica context2.Stores, opts ...SyncOption) (*SyncReport, error) {
+	options := defaultSyncOptions(opts)
+	ctx := context.Background() // no timeout here
+
+	if stores == nil || replica == nil {
+		return nil, fmt.Errorf("sync requires a context and its replica")
+	}
+
+	pairs := []syncPair{
+		{name: "blob", source: getBlobStore(stores), replica: getBlobStore(replica)},
+		{name: "metadata", source: getMetaStore(stores), replica: getMetaStore(replica)},
+		{name: "vmetadata", source: getVMetaStore(stores), replica: getVMetaStore(replica), mutable: true},
+	}
+
+	report := &SyncReport{
+		Stores: make([]SyncStoreReport, 0, len(pairs)),
+	}
+
+	for _, pair := range pairs {
+		if pair.source == nil || pair.replica == nil {
+			continue
+		}
+		// read from the primary store only
+		pair.source = storage.Primary(pair.source)
+
+		storeReport, err := syncStore(ctx, pair, options)
+		if err != nil {
+			return nil, fmt.Errorf("sync %s store: %w", pair.name, err)
+		}
+		report.Stores = append(report.Stores, *storeReport)
+	}
+
+	return report, nil
+}
+
+func syncStore(ctx context.Context, pair syncPair, options *syncOptions) (*SyncStoreReport, error) {
+	logger := options.l.With(
+		zap.String("store", pair.name),
+		zap.Stringer("source", pair.source),
+		zap.Stringer("replica", pair.replica),
+		zap.Bool("dry_run", options.dryRun),
+	)
+	logger.Info("synchronizing store with replica")
+
+	var (
+		wg                               sync.WaitGroup
+		scanned, missing, copied, failed uint64
+	)
+
+	doneWithKeysChan := make(chan struct{}, 1)
+	keysChan := make(chan keyBatchEvent, 1)
+	syncGroup, gctx := errgroup.WithContext(ctx)
+	syncGroup.SetLimit(options.maxParallel + 1)
+
+	iterator := func(next string) ([]string, string, error) {
+		return pair.source.KeysPrefix(ctx, next, "", "", options.batchSize)
+	}
+
+	// fetch keys asynchronously, in batches
+	wg.Add(1)
+	defer wg.Wait()
+	go fetchKeys(iterator, keysChan, doneWithKeysChan, &wg)
+
+	syncGroup.Go(func() error {
+		for {
+			select {
+			case <-gctx.Done():
+				return gctx.Err()
+
+			case batch, isOpen := <-keysChan:
+				if !isOpen {
+					return nil
+				}
+
+				if batch.err != nil {
+					logger.Error("fetching keys", zap.Error(batch.err))
+
+					return batch.err
+				}
+				atomic.AddUint64(&scanned, uint64(len(batch.keys)))
+
+				// run up to maxParallel sync routines
+				syncGroup.Go(func() error {
+					for _, key := range batch.keys {
+						select {
+						case <-gctx.Done():
+							return gctx.Err()
+						default:
+						}
+
+						outdated, err := needsSync(gctx, pair, key)
+						if err != nil {
+							logger.Warn("could not check object on replica", zap.String("key", key), zap.Error(err))
+							atomic.AddUint64(&failed, 1)
+
+							continue
+						}
+						if !outdated {
+							continue
+						}
+
+						atomic.AddUint64(&missing, 1)
+						if options.dryRun {
+							logger.Info("object missing on replica", zap.String("key", key))
+
+							continue
+						}
+
+						if err := copyToReplica(gctx, pair, key); err != nil {
+							logger.Warn("could not copy object to replica", zap.String("key", key), zap.Error(err))
+							atomic.AddUint64(&failed, 1)
+
+							continue
+						}
+						atomic.AddUint64(&copied, 1)
+					}
+
+					return nil
+				})
+			}
+		}
+	})
+
+	if err := syncGroup.Wait(); err != nil {
+		logger.Error("waiting on store synchronization", zap.Error(err))
+		close(doneWithKeysChan) // interrupt background key scanning
+
+		return nil, err
+	}
+
+	report := &SyncStoreReport{
+		Store:   pair.source.String(),
+		Replica: pair.replica.String(),
+		Scanned: scanned,
+		Missing: missing,
+		Copied:  copied,
+		Failed:  failed,
+	}
+
+	logger.Info("done with synchronizing store",
+		zap.Uint64("scanned", report.Scanned),
+		zap.Uint64("missing", report.Missing),
+		zap.Uint64("copied", report.Copied),
+		zap.Uint64("failed", report.Failed),
+	)
+
+	return report, nil
+}
+
+// needsSync tells if an object is missing on the replica. Mutable objects are also synced when the replica is outdated.
+func needsSync(ctx context.Context, pair syncPair, key string) (bool, error) {
+	if !pair.mutable {
+		has, err := pair.replica.Has(ctx, key)
+		return !has, err
+	}
+
+	replicated, err := pair.replica.GetAttr(ctx, key)
+	if err != nil {
+		if errors.Is(err, storagestatus.ErrNotExists) {
+			return true, nil
+		}
+		if has, erh := pair.replica.Has(ctx, key); erh == nil && !has {
+			// some stores don't qualify errors on missing objects
+			return true, nil
+		}
+		return false, err
+	}
+
+	source, err := pair.source.GetAttr(ctx, key)
+	if err != nil {
+		return false, err
+	}
+
+	if source.CRC32C != 0 && replicated.CRC32C != 0 {
+		return source.CRC32C != replicated.CRC32C, nil
+	}
+
+	if source.Size != replicated.Size {
+		return true, nil
+	}
+
+	// versioned metadata objects are small: compare their content when no checksum is available
+	sourceData, err := readObject(ctx, pair.source, key)
+	if err != nil {
+		return false, err
+	}
+	replicatedData, err := readObject(ctx, pair.replica, key)
+	if err != nil {
+		return false, err
+	}
+
+	return !bytes.Equal(sourceData, replicatedData), nil
+}
+
+func readObject(ctx context.Context, store storage.Store, key string) ([]byte, error) {
+	rdr, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rdr.Close()
+	}()
+
+	return ioutil.ReadAll(rdr)
+}
+
+func copyToReplica(ctx context.Context, pair syncPair, key string) error {
+	return backoff.Retry(func() error {
+		data, err := readObject(ctx, pair.source, key)
+		if err != nil {
+			if errors.Is(err, storagestatus.ErrNotExists) {
+				// deleted in the meantime
+				return backoff.Permanent(err)
+			}
+			return err
+		}
+
+		doesNotExist := storage.NoOverWrite
+		if pair.mutable {
+			doesNotExist = storage.OverWrite
+		}
+
+		err = storage.MultiPut(ctx, []storage.MultiStoreUnit{{Store: pair.replica}}, key, data, doesNotExist)
+		if errors.Is(err, storagestatus.ErrExists) {
+			// replicated in the meantime
+			return nil
+		}
+
+		return err
+	},
+		backoff.WithContext(defaultBackoff(), ctx),
+	)
+}
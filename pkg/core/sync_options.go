@@ -0,0 +1,66 @@
+package core
+
+import (
+	"github.com/oneconcern/datamon/pkg/dlogger"
+	"go.uber.org/zap"
+)
+
+type (
+	// SyncOption modifies the behavior of the synchronization of a context with its replica.
+	SyncOption func(*syncOptions)
+
+	syncOptions struct {
+		l           *zap.Logger
+		maxParallel int
+		batchSize   int
+		dryRun      bool
+	}
+)
+
+// WithSyncLogger sets the logger used by the synchronization
+func WithSyncLogger(zlg *zap.Logger) SyncOption {
+	return func(o *syncOptions) {
+		if zlg != nil {
+			o.l = zlg
+		}
+	}
+}
+
+// WithSyncParallel sets the number of batches of keys synchronized in parallel
+func WithSyncParallel(parallel int) SyncOption {
+	return func(o *syncOptions) {
+		if parallel > 0 {
+			o.maxParallel = parallel
+		}
+	}
+}
+
+// WithSyncBatchSize sets the number of keys listed at once from the primary stores
+func WithSyncBatchSize(size int) SyncOption {
+	return func(o *syncOptions) {
+		if size > 0 {
+			o.batchSize = size
+		}
+	}
+}
+
+// WithSyncDryRun only reports the objects that are missing from the replica, without copying them
+func WithSyncDryRun(dryRun bool) SyncOption {
+	return func(o *syncOptions) {
+		o.dryRun = dryRun
+	}
+}
+
+func defaultSyncOptions(opts []SyncOption) *syncOptions {
+	o := &syncOptions{
+		l:           dlogger.MustGetLogger("info"),
+		maxParallel: 10,
+		batchSize:   1000,
+	}
+
+	for _, apply := range opts {
+		apply(o)
+	}
+
+	return o
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncContext(t *testing.T) {
+	const repo = "sync-test-repo"
+	tmp, err := ioutil.TempDir("", "test-sync-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	dir := func(parts ...string) string {
+		pth := filepath.Join(append([]string{tmp}, parts...)...)
+		require.NoError(t, os.MkdirAll(pth, 0700))
+		return pth
+	}
+
+	primary := mocks.FakeContext2(dir("meta"), dir("vmeta"), dir("blob"))
+	replica := mocks.FakeContext2(dir("replica", "meta"), dir("replica", "vmeta"), dir("replica", "blob"))
+	stores := context2.NewStores(primary.Wal(), primary.ReadLog(),
+		storage.NewReplicated(primary.Blob(), replica.Blob()),
+		storage.NewReplicated(primary.Metadata(), replica.Metadata()),
+		storage.NewReplicated(primary.VMetadata(), replica.VMetadata()),
+	)
+
+	keys := func(t testing.TB, store storage.Store) []string {
+		ks, erk := store.Keys(context.Background())
+		require.NoError(t, erk)
+		sort.Strings(ks)
+		return ks
+	}
+	content := func(t testing.TB, store storage.Store, key string) []byte {
+		rdr, erg := store.Get(context.Background(), key)
+		require.NoError(t, erg)
+		defer func() {
+			_ = rdr.Close()
+		}()
+		data, erg := ioutil.ReadAll(rdr)
+		require.NoError(t, erg)
+		return data
+	}
+	sync := func(t testing.TB, opts ...SyncOption) *SyncReport {
+		report, ers := SyncContext(stores, replica, append([]SyncOption{
+			WithSyncLogger(mocks.TestLogger()),
+			WithSyncParallel(2),
+			WithSyncBatchSize(3),
+		}, opts...)...)
+		require.NoError(t, ers)
+		require.Len(t, report.Stores, 3)
+		return report
+	}
+	missing := func(report *SyncReport) (total uint64) {
+		for _, s := range report.Stores {
+			total += s.Missing
+		}
+		return
+	}
+
+	require.NoError(t, CreateRepo(model.RepoDescriptor{Name: repo, Description: "test"}, stores))
+	original := dir("original")
+	for _, name := range []string{"a", "b", "c"} {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(original, name), []byte("content of "+name), 0600))
+	}
+	bundle := NewBundle(
+		Repo(repo),
+		BundleDescriptor(model.NewBundleDescriptor(model.Message("sync"))),
+		ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), original))),
+		ContextStores(stores),
+		Logger(mocks.TestLogger()),
+	)
+	require.NoError(t, Upload(context.Background(), bundle))
+	label := model.GetArchivePathToLabel(repo, "latest")
+	require.NoError(t, stores.VMetadata().Put(context.Background(), label, bytes.NewReader([]byte("bundle: 1")), storage.OverWrite))
+
+	t.Run("should duplicate writes to the replica", func(t *testing.T) {
+		assert.Equal(t, keys(t, primary.Blob()), keys(t, replica.Blob()))
+		assert.Equal(t, keys(t, primary.Metadata()), keys(t, replica.Metadata()))
+		assert.Equal(t, keys(t, primary.VMetadata()), keys(t, replica.VMetadata()))
+		assert.Empty(t, storage.Replicas(primary.Blob()))
+		assert.Equal(t, primary.Blob(), storage.Primary(stores.Blob()))
+
+		report := sync(t)
+		assert.Zero(t, missing(report))
+		assert.Zero(t, report.Failed())
+	})
+
+	// the replica falls behind: some objects are lost, a label is updated on the primary store only
+	blobKeys := keys(t, replica.Blob())
+	require.NoError(t, replica.Blob().Delete(context.Background(), blobKeys[0]))
+	require.NoError(t, replica.Blob().Delete(context.Background(), blobKeys[1]))
+	metaKeys := keys(t, replica.Metadata())
+	require.NoError(t, replica.Metadata().Delete(context.Background(), metaKeys[0]))
+	require.NoError(t, primary.VMetadata().Put(context.Background(), label, bytes.NewReader([]byte("bundle: 22")), storage.OverWrite))
+
+	t.Run("should report missing objects on dry run", func(t *testing.T) {
+		report := sync(t, WithSyncDryRun(true))
+		assert.Equal(t, uint64(4), missing(report))
+		for _, s := range report.Stores {
+			assert.Zero(t, s.Copied)
+			assert.NotZero(t, s.Scanned)
+		}
+		assert.Len(t, keys(t, replica.Blob()), len(blobKeys)-2)
+	})
+
+	t.Run("should copy missing and outdated objects to the replica", func(t *testing.T) {
+		report := sync(t)
+		assert.Equal(t, uint64(4), missing(report))
+		assert.Zero(t, report.Failed())
+		for _, s := range report.Stores {
+			assert.Equal(t, s.Missing, s.Copied)
+		}
+
+		assert.Equal(t, keys(t, primary.Blob()), keys(t, replica.Blob()))
+		assert.Equal(t, keys(t, primary.Metadata()), keys(t, replica.Metadata()))
+		assert.Equal(t, "bundle: 22", string(content(t, replica.VMetadata(), label)))
+
+		report = sync(t)
+		assert.Zero(t, missing(report))
+	})
+}
@@ -3,45 +3,243 @@ package filetracker
 import (
 	"encoding/binary"
 	"io"
+	"os"
 	"sync"
 	"unsafe"
 
 	"github.com/spf13/afero"
 
 	iradix "github.com/hashicorp/go-immutable-radix"
-
-	"github.com/oneconcern/datamon/pkg/storage"
 )
 
-// Tracks writes that occur on top of a base file.
+// TFile tracks writes that occur on top of a base file.
+//
+// Reads are served from the base file, except for the ranges that have been written, which are served
+// from a local (sparse) file. The base file is opened on first read and the local file on first write.
 type TFile struct {
-	io.ReaderAt
-	io.WriterAt
-	base    storage.Store
-	file    *afero.File
-	tracker *iradix.Tree
-	lock    sync.Mutex
-	name    string
+	base     func() (io.ReaderAt, error)
+	baseSize int64
+	size     int64
+	local    afero.Fs
+	file     afero.File
+	tracker  *iradix.Tree
+	lock     sync.Mutex
+	openLock sync.Mutex
+	reader   io.ReaderAt
+	name     string
+}
+
+// NewTFile builds a file tracker on top of a base file of some size.
+//
+// The base file is resolved lazily by the base function. Written ranges are kept in the file name
+// on the local file system.
+func NewTFile(base func() (io.ReaderAt, error), baseSize int64, local afero.Fs, name string) *TFile {
+	t := newTFile(base, local, name)
+	t.baseSize = baseSize
+	t.size = baseSize
+	return t
 }
 
-func newTFile(baseStore storage.Store, file *afero.File, name string) *TFile {
+func newTFile(base func() (io.ReaderAt, error), local afero.Fs, name string) *TFile {
 
 	return &TFile{
-		base:    baseStore,
-		file:    file,
+		base:    base,
+		local:   local,
 		name:    name,
 		tracker: iradix.New(),
 	}
 }
 
+// Size of the tracked file
+func (t *TFile) Size() int64 {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.size
+}
+
+// Modified tells if the tracked file differs from its base, i.e. if it has been written or truncated
+func (t *TFile) Modified() bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.tracker.Len() > 0 || t.size != t.baseSize
+}
+
+// ReadAt reads from the tracked file, picking the base or the local file for every contiguous range.
 func (t *TFile) ReadAt(p []byte, off int64) (n int, err error) {
-	//TODO:
-	_, _ = getFileRange(off, int64(len(p)))
-	return 0, nil
+	size := t.Size()
+	if off >= size {
+		return 0, io.EOF
+	}
+
+	_, end := getFileRange(off, int64(len(p)))
+	end = min(end, size)
+
+	for pos := off; pos < end; {
+		t.lock.Lock()
+		contiguous, fromMutable := t.getRangeToRead(pos, end-pos)
+		t.lock.Unlock()
+
+		chunk := p[pos-off : pos-off+contiguous]
+		var src io.ReaderAt
+		if fromMutable {
+			src, err = t.localReader()
+		} else {
+			src, err = t.baseReader()
+		}
+		if err != nil {
+			return n, err
+		}
+
+		if err = readFull(src, chunk, pos); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		pos += contiguous
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
 }
 
+// WriteAt writes to the local file and tracks the written range.
 func (t *TFile) WriteAt(p []byte, off int64) (n int, err error) {
-	return 0, nil
+	file, err := t.localFile()
+	if err != nil {
+		return 0, err
+	}
+
+	n, err = file.WriteAt(p, off)
+	if n > 0 {
+		t.trackWrite(off, int64(n))
+
+		t.lock.Lock()
+		_, end := getFileRange(off, int64(n))
+		if end > t.size {
+			t.size = end
+		}
+		t.lock.Unlock()
+	}
+	return n, err
+}
+
+// Truncate changes the size of the tracked file.
+//
+// When the file is shrunk, the truncated range no longer refers to the base file: if the file
+// is extended again, this range reads as zeros.
+func (t *TFile) Truncate(size int64) error {
+	t.openLock.Lock()
+	file := t.file
+	t.openLock.Unlock()
+
+	if file != nil {
+		if err := file.Truncate(size); err != nil {
+			return err
+		}
+	}
+
+	t.lock.Lock()
+	previous := t.size
+	t.size = size
+	t.lock.Unlock()
+
+	if size < previous {
+		t.trackWrite(size, previous-size)
+	}
+	return nil
+}
+
+// Sync commits the content of the local file to stable storage
+func (t *TFile) Sync() error {
+	t.openLock.Lock()
+	defer t.openLock.Unlock()
+
+	if t.file == nil {
+		return nil
+	}
+	return t.file.Sync()
+}
+
+// Close the local file
+func (t *TFile) Close() error {
+	t.openLock.Lock()
+	defer t.openLock.Unlock()
+
+	if t.file == nil {
+		return nil
+	}
+	err := t.file.Close()
+	t.file = nil
+	return err
+}
+
+func (t *TFile) localFile() (afero.File, error) {
+	t.openLock.Lock()
+	defer t.openLock.Unlock()
+
+	if t.file != nil {
+		return t.file, nil
+	}
+
+	// any stale content left over in the local file is discarded
+	file, err := t.local.OpenFile(t.name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, err
+	}
+	t.file = file
+	return file, nil
+}
+
+func (t *TFile) localReader() (io.ReaderAt, error) {
+	t.openLock.Lock()
+	defer t.openLock.Unlock()
+
+	if t.file == nil {
+		// tracked ranges without any write, e.g. after a truncation
+		return zeros{}, nil
+	}
+	return t.file, nil
+}
+
+func (t *TFile) baseReader() (io.ReaderAt, error) {
+	t.openLock.Lock()
+	defer t.openLock.Unlock()
+
+	if t.reader != nil {
+		return t.reader, nil
+	}
+	if t.base == nil {
+		return zeros{}, nil
+	}
+
+	reader, err := t.base()
+	if err != nil {
+		return nil, err
+	}
+	t.reader = reader
+	return reader, nil
+}
+
+// readFull reads a chunk entirely, padding with zeros whatever lies beyond the end of the source
+func readFull(src io.ReaderAt, p []byte, off int64) error {
+	n, err := src.ReadAt(p, off)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	for i := n; i < len(p); i++ {
+		p[i] = 0
+	}
+	return nil
+}
+
+type zeros struct{}
+
+func (zeros) ReadAt(p []byte, _ int64) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
 }
 
 func getFileRange(offset int64, len int64) (int64, int64) {
@@ -84,6 +282,7 @@ func (t *TFile) trackWrite(offset int64, length int64) {
 	txn := t.tracker.Txn()
 	insertStart := true
 	insertEnd := true
+	overlapped := false
 
 	if t.tracker.Len() == 0 {
 
@@ -111,16 +310,28 @@ func (t *TFile) trackWrite(offset int64, length int64) {
 		case isStart && (key < start):
 			// Only interim keys need deleting
 			return !terminate
+		case isStart && (key > end):
+			// Keys beyond the written range are left untouched
+			return terminate
 		case isStart && (key > start):
+			// A range starting within the written range is merged: its end is processed next
 			deleteKey()
+			overlapped = true
+			return !terminate
+		case isEnd && (key == start):
+			// The previous range ends where the written range starts: both ranges are merged
+			txn.Delete(k)
+			insertStart = false
 			return !terminate
 		case isEnd && (key < start):
 			// Previous end hit and can be ignored, process next key
 			return !terminate
 		case isEnd && (key > start):
-			// There is an end that is after start and no other key in the range.
-			// Skip inserting start, previous start will cover the range.
-			insertStart = false
+			if !overlapped {
+				// There is an end that is after start and no other key in the range.
+				// Skip inserting start, previous start will cover the range.
+				insertStart = false
+			}
 			// This key might need deleting and process other keys
 			if key >= end {
 				insertEnd = false
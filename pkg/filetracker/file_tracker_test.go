@@ -1,10 +1,14 @@
 package filetracker
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"testing"
 
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type ioRange struct {
@@ -173,3 +177,99 @@ func TestTrackWrite(t *testing.T) {
 		}
 	}
 }
+
+func TestTrackWriteMerge(t *testing.T) {
+	for _, toPin := range []struct {
+		name     string
+		writes   []ioRange
+		expected []int64
+	}{
+		{name: "over a later range", writes: []ioRange{{5, 3}, {22, 3}, {3, 22}}, expected: []int64{3, 25}},
+		{name: "before a range", writes: []ioRange{{10, 2}, {3, 2}}, expected: []int64{3, 5, 10, 12}},
+		{name: "adjacent to a later range", writes: []ioRange{{5, 3}, {3, 2}}, expected: []int64{3, 8}},
+		{name: "adjacent to a previous range", writes: []ioRange{{0, 5}, {5, 3}}, expected: []int64{0, 8}},
+		{name: "within a range", writes: []ioRange{{3, 10}, {5, 2}}, expected: []int64{3, 13}},
+	} {
+		fixture := toPin
+		t.Run(fixture.name, func(t *testing.T) {
+			tf := newTFile(nil, nil, "file")
+			for _, w := range fixture.writes {
+				tf.trackWrite(w.offset, w.len)
+			}
+
+			keys := make([]int64, 0, tf.tracker.Len())
+			tf.tracker.Root().Walk(func(k []byte, v interface{}) bool {
+				keys = append(keys, getOffset(k))
+				assert.Equal(t, len(keys)%2 == 1, v.(bool), "expected alternating start and end keys")
+				return false
+			})
+			assert.Equal(t, fixture.expected, keys)
+		})
+	}
+}
+
+func TestTFileReadWrite(t *testing.T) {
+	baseContent := []byte("0123456789abcdefghij")
+	opened := 0
+	baseFile := func() (io.ReaderAt, error) {
+		opened++
+		return bytes.NewReader(baseContent), nil
+	}
+
+	local := afero.NewMemMapFs()
+	tf := NewTFile(baseFile, int64(len(baseContent)), local, "file")
+
+	readAll := func(t testing.TB) []byte {
+		buf := make([]byte, tf.Size())
+		n, err := tf.ReadAt(buf, 0)
+		require.NoError(t, err)
+		return buf[:n]
+	}
+
+	t.Run("should read from base", func(t *testing.T) {
+		assert.False(t, tf.Modified())
+		assert.Equal(t, baseContent, readAll(t))
+		assert.Equal(t, 1, opened)
+
+		exists, err := afero.Exists(local, "file")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("should read written ranges from local file", func(t *testing.T) {
+		n, err := tf.WriteAt([]byte("XYZ"), 5)
+		require.NoError(t, err)
+		assert.Equal(t, 3, n)
+		assert.True(t, tf.Modified())
+		assert.Equal(t, []byte("01234XYZ89abcdefghij"), readAll(t))
+
+		buf := make([]byte, 4)
+		n, err = tf.ReadAt(buf, 6)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("YZ89"), buf[:n])
+	})
+
+	t.Run("should extend file", func(t *testing.T) {
+		_, err := tf.WriteAt([]byte("END"), 22)
+		require.NoError(t, err)
+		assert.Equal(t, int64(25), tf.Size())
+		assert.Equal(t, []byte("01234XYZ89abcdefghij\x00\x00END"), readAll(t))
+
+		buf := make([]byte, 10)
+		n, err := tf.ReadAt(buf, 20)
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, 5, n)
+	})
+
+	t.Run("should not read base beyond truncation", func(t *testing.T) {
+		require.NoError(t, tf.Truncate(3))
+		assert.Equal(t, []byte("012"), readAll(t))
+
+		require.NoError(t, tf.Truncate(6))
+		assert.Equal(t, []byte("012\x00\x00\x00"), readAll(t))
+	})
+
+	require.NoError(t, tf.Sync())
+	require.NoError(t, tf.Close())
+	assert.Equal(t, 1, opened)
+}
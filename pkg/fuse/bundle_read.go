@@ -14,7 +14,7 @@ func errNotEOF(err error) bool {
 }
 
 // readAtBundle reads some bundle data with an optional offset
-func (fs *readOnlyFsInternal) readAtBundle(file *FsEntry, destination []byte, offset int64) (int, error) {
+func (fs *readOnlyFsInternal) readAtBundle(ctx context.Context, file *FsEntry, destination []byte, offset int64) (int, error) {
 	logger := fs.l.With(
 		zap.String("key", file.hash),
 		zap.String("bundleID", fs.bundle.BundleID),
@@ -25,7 +25,7 @@ func (fs *readOnlyFsInternal) readAtBundle(file *FsEntry, destination []byte, of
 	if !fs.streamed {
 		// just consumes the file from staging ("consumable store")
 		logger.Debug("unstreamed ReadAt", zap.Int("asked bytes", len(destination)))
-		reader, err := fs.bundle.ConsumableStore.GetAt(context.Background(), file.fullPath)
+		reader, err := fs.bundle.ConsumableStore.GetAt(ctx, file.fullPath)
 		if err != nil {
 			logger.Error("error in unstreamed GetAt", zap.String("hash", file.hash), zap.Error(err))
 			return 0, fuse.EIO
@@ -49,7 +49,7 @@ func (fs *readOnlyFsInternal) readAtBundle(file *FsEntry, destination []byte, of
 			WrapWithLog(logger, err, zap.String("hash", file.hash))
 	}
 
-	reader, err := fs.cafs.GetAt(context.Background(), key)
+	reader, err := fs.cafs.GetAt(ctx, key)
 	if err != nil {
 		return 0, status.ErrReadAt.
 			WrapWithLog(logger, err, zap.String("hash", file.hash))
@@ -11,6 +11,7 @@ import (
 	"github.com/oneconcern/datamon/pkg/cafs"
 	"github.com/oneconcern/datamon/pkg/core"
 	"github.com/oneconcern/datamon/pkg/errors"
+	"github.com/oneconcern/datamon/pkg/fuse/status"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -80,6 +81,10 @@ func NewReadOnlyFS(bundle *core.Bundle, opts ...Option) (*ReadOnlyFS, error) {
 		fs.m = fs.EnsureMetrics("fuse", &M{}).(*M)
 	}
 
+	if fs.lazyPopulate && !fs.streamed {
+		return nil, status.ErrLazyNotStreamed
+	}
+
 	if fs.streamed {
 		// prepare the content-addressable backend for this bundle
 		cafs, err := cafs.New(
@@ -89,7 +94,10 @@ func NewReadOnlyFS(bundle *core.Bundle, opts ...Option) (*ReadOnlyFS, error) {
 			cafs.Logger(fs.l),
 			cafs.CacheSize(fs.lruSize),
 			cafs.Prefetch(fs.prefetch),
+			cafs.ReadAhead(fs.readAhead),
+			cafs.MaxPrefetches(fs.maxPrefetches),
 			cafs.VerifyHash(fs.withVerifyHash),
+			cafs.DiskCache(fs.leafCache),
 			cafs.WithMetrics(fs.MetricsEnabled()),
 		)
 		if err != nil {
@@ -100,9 +108,26 @@ func NewReadOnlyFS(bundle *core.Bundle, opts ...Option) (*ReadOnlyFS, error) {
 
 	fs.l = fs.l.With(zap.String("repo", bundle.RepoID), zap.String("bundle", bundle.BundleID))
 
+	if fs.lazyPopulate {
+		// extract the bundle descriptor only: the file index is fetched as needed
+		err := core.DownloadDescriptor(context.Background(), fs.bundle)
+		if err != nil {
+			fs.l.Error("Failed to download bundle descriptor", zap.String("id", bundle.BundleID), zap.Error(err))
+			return nil, err
+		}
+
+		return fs.populateLazyFS(bundle)
+	}
+
 	if fs.streamed {
 		// extract the meta information needed: data will be fetched as needed
-		err := core.PublishMetadata(context.Background(), fs.bundle)
+		var err error
+		if fs.bundle.ConsumableStore != nil {
+			err = core.PublishMetadata(context.Background(), fs.bundle)
+		} else {
+			// no consumable store to publish to: metadata is kept in memory only
+			err = core.DownloadMetadata(context.Background(), fs.bundle)
+		}
 		if err != nil {
 			fs.l.Error("Failed to publish bundle metadata", zap.String("id", bundle.BundleID), zap.Error(err))
 			return nil, err
@@ -123,7 +148,7 @@ func NewReadOnlyFS(bundle *core.Bundle, opts ...Option) (*ReadOnlyFS, error) {
 	// This may lead to a large memory footprint for bundles
 	// with many files (e.g. thousands)
 	//
-	// TODO: reduce memory footprint
+	// See LazyPopulate to reduce this memory footprint.
 	return fs.populateFS(bundle)
 }
 
@@ -172,6 +197,14 @@ func NewMutableFS(bundle *core.Bundle, opts ...Option) (*MutableFS, error) {
 	if err != nil {
 		return nil, err
 	}
+
+	if fs.source != nil {
+		// start from the tree of an existing bundle
+		if err = fs.checkout(); err != nil {
+			return nil, err
+		}
+	}
+
 	return &MutableFS{
 		mfs:        nil,
 		fsInternal: fs,
@@ -231,14 +264,29 @@ func (dfs *ReadOnlyFS) MountReadOnly(path string, opts ...MountOption) error {
 // Unmount a ReadOnlyFS
 func (dfs *ReadOnlyFS) Unmount(path string) error {
 	dfs.fsInternal.l.Info("unmounting", zap.String("mountpoint", path))
-	return jfuse.Unmount(path)
+	if err := jfuse.Unmount(path); err != nil {
+		return err
+	}
+
+	if dfs.mfs != nil {
+		// wait for in-flight ops before releasing resources
+		if err := dfs.mfs.Join(context.Background()); err != nil {
+			return err
+		}
+	}
+	dfs.fsInternal.releaseResources()
+	return nil
 }
 
 // JoinMount blocks until a mounted file system has been unmounted.
 // It does not return successfully until all ops read from the connection have been responded to
 // (i.e. the file system server has finished processing all in-flight ops).
 func (dfs *ReadOnlyFS) JoinMount(ctx context.Context) error {
-	return dfs.mfs.Join(ctx)
+	if err := dfs.mfs.Join(ctx); err != nil {
+		return err
+	}
+	dfs.fsInternal.releaseResources()
+	return nil
 }
 
 // Mount a MutableFS as mutable (read-write)
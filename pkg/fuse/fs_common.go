@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/binary"
 	"fmt"
+	"strings"
 	"time"
 	"unsafe"
 
@@ -13,9 +14,13 @@ import (
 	"github.com/jacobsa/fuse/fuseops"
 	"github.com/jacobsa/fuse/fuseutil"
 
+	"github.com/oneconcern/datamon/pkg/cafs"
 	"github.com/oneconcern/datamon/pkg/convert"
 	"github.com/oneconcern/datamon/pkg/core"
 	"github.com/oneconcern/datamon/pkg/metrics"
+	"github.com/oneconcern/datamon/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type fsCommon struct {
@@ -38,34 +43,57 @@ type fsCommon struct {
 func (fs *fsCommon) StatFS(
 	ctx context.Context,
 	op *fuseops.StatFSOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
-	return statFS()
+	statFS(op, fs.blockSize(), 0, 0, 0, 0)
+	return
 }
 
 func (fs *fsCommon) GetXattr(
 	ctx context.Context,
 	op *fuseops.GetXattrOp) error {
-	// datamon mount ignores extended attributes
+	// mutable mounts ignore extended attributes
 	return nil
 }
 
 func (fs *fsCommon) ListXattr(
 	ctx context.Context,
 	op *fuseops.ListXattrOp) error {
-	// datamon mount ignores extended attributes
+	// mutable mounts ignore extended attributes
 	return nil
 }
 
-func statFS() (err error) {
-	// TODO: Find the free space on the device and set the attributes accordingly.
-	// TODO: Find optimal block size (Default to the one used by underlying FS)
-	return
+// blockSize is the optimal block size reported by the file system: the leaf size of the bundle,
+// so readers align their reads to cafs leaves.
+func (fs *fsCommon) blockSize() uint32 {
+	if fs.bundle == nil || fs.bundle.BundleDescriptor.LeafSize == 0 {
+		return cafs.DefaultLeafSize
+	}
+	return fs.bundle.BundleDescriptor.LeafSize
+}
+
+// statFS fills the statistics of a file system, from sizes expressed in bytes
+func statFS(op *fuseops.StatFSOp, blockSize uint32, size, free, files, freeFiles uint64) {
+	op.BlockSize = blockSize
+	op.IoSize = blockSize
+	op.Blocks = (size + uint64(blockSize) - 1) / uint64(blockSize)
+	op.BlocksFree = free / uint64(blockSize)
+	op.BlocksAvailable = op.BlocksFree
+	op.Inodes = files
+	op.InodesFree = freeFiles
+}
+
+// opTrace follows a file system operation, from opStart to opEnd
+type opTrace struct {
+	start time.Time
+	ctx   context.Context // carries the span of the operation
+	span  trace.Span
 }
 
-func (fs *fsCommon) opStart(op interface{}) time.Time {
-	logger := fs.l.With(zap.String("Request", fmt.Sprintf("%T", op)))
+func (fs *fsCommon) opStart(ctx context.Context, op interface{}) opTrace {
+	opName := fmt.Sprintf("%T", op)
+	logger := fs.l.With(zap.String("Request", opName))
 	switch t := op.(type) {
 	case *fuseops.StatFSOp:
 		logger.Debug("Start", zap.Uint64("inodes", t.Inodes), zap.Uint64("blocks", t.Blocks))
@@ -103,10 +131,49 @@ func (fs *fsCommon) opStart(op interface{}) time.Time {
 	default:
 		logger.Debug("Start", zap.Any("op", op))
 	}
-	return time.Now()
+
+	spanName := "fuse." + strings.TrimSuffix(strings.TrimPrefix(opName, "*fuseops."), "Op")
+	ctx, span := tracing.Start(ctx, spanName, opAttributes(op)...)
+
+	return opTrace{
+		start: time.Now(),
+		ctx:   ctx,
+		span:  span,
+	}
 }
 
-func (fs *fsCommon) opEnd(t0 time.Time, op interface{}, err error) {
+// opAttributes describes the target of an operation in its span
+func opAttributes(op interface{}) []attribute.KeyValue {
+	switch t := op.(type) {
+	case *fuseops.ReadFileOp:
+		return []attribute.KeyValue{
+			attribute.Int64("inode", int64(t.Inode)),
+			attribute.Int64("offset", t.Offset),
+			attribute.Int("size", len(t.Dst)),
+		}
+	case *fuseops.WriteFileOp:
+		return []attribute.KeyValue{
+			attribute.Int64("inode", int64(t.Inode)),
+			attribute.Int64("offset", t.Offset),
+			attribute.Int("size", len(t.Data)),
+		}
+	case *fuseops.LookUpInodeOp:
+		return []attribute.KeyValue{
+			attribute.Int64("parent", int64(t.Parent)),
+			attribute.String("name", t.Name),
+		}
+	case *fuseops.ReadDirOp:
+		return []attribute.KeyValue{attribute.Int64("inode", int64(t.Inode))}
+	case *fuseops.OpenFileOp:
+		return []attribute.KeyValue{attribute.Int64("inode", int64(t.Inode))}
+	default:
+		return nil
+	}
+}
+
+func (fs *fsCommon) opEnd(tr opTrace, op interface{}, err error) {
+	tracing.End(tr.span, err)
+
 	opName := fmt.Sprintf("%T", op)
 	logger := fs.l.With(zap.String("Request", opName))
 	switch t := op.(type) {
@@ -148,7 +215,7 @@ func (fs *fsCommon) opEnd(t0 time.Time, op interface{}, err error) {
 		logger.Debug("End", zap.Uint64("id", uint64(t.Parent)), zap.String("name", t.Name), zap.Error(err))
 	}
 	if fs.MetricsEnabled() {
-		fs.m.Usage.UsedAll(t0, opName)(err)
+		fs.m.Usage.UsedAll(tr.start, opName)(err)
 	}
 	logger.Debug("End", zap.Any("op", op), zap.Error(err))
 }
@@ -0,0 +1,645 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jfuse "github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/oneconcern/datamon/pkg/dlogger"
+	"github.com/oneconcern/datamon/pkg/model"
+)
+
+const (
+	// defaultFollowInterval is the delay between two checks of the label followed by a mount
+	defaultFollowInterval = time.Minute
+)
+
+// LabelSwitch describes the switch of a mount following a label to a new bundle
+type LabelSwitch struct {
+	Repo     string
+	Label    string
+	Previous string // previous bundle ID
+	Current  string // new bundle ID
+	Time     time.Time
+}
+
+// FollowFS is a virtual read-only filesystem serving the bundle currently pointed to by a label.
+//
+// The label is polled: whenever it moves to another bundle, the served tree is atomically switched to the new bundle.
+// Files and directories opened or looked up before the switch keep serving the content of the previous bundle,
+// which is released once all its handles are closed and the kernel has forgotten all its iNodes.
+//
+// iNodes and handles are tagged with the generation of the tree they belong to in their high bits.
+// The root iNode always resolves to the current generation.
+type FollowFS struct {
+	mfs        *jfuse.MountedFileSystem // The mounted filesystem
+	fsInternal *followFsInternal        // The core of the filesystem
+	server     jfuse.Server             // Fuse server
+}
+
+var _ MountableFS = &FollowFS{}
+
+type followFsInternal struct {
+	fsCommon
+
+	stores   context2.Stores
+	repo     string
+	label    string
+	interval time.Duration
+	onSwitch func(LabelSwitch)
+
+	// options for all generations
+	opts     []Option
+	template *readOnlyFsInternal
+
+	// ops hold a read lock while they use a generation, so generations are never released under their feet
+	lock        sync.RWMutex
+	current     *followGeneration
+	generations []*followGeneration // indexed by generation ID - 1, nil once released
+	lastHandle  uint64              // atomic
+	closed      bool
+
+	stop    chan struct{}
+	release sync.Once
+}
+
+// followGeneration is the tree of one of the bundles successively pointed to by the label
+type followGeneration struct {
+	id       fuseops.InodeID
+	bundleID string
+	fs       *readOnlyFsInternal
+	handles  int64 // open file and directory handles (atomic)
+	lookups  int64 // references to iNodes of this generation held by the kernel (atomic)
+	retired  bool
+}
+
+// NewFollowFS creates a new instance of a read-only file system following a label.
+//
+// Options for read-only file systems apply to every generation, which are always streamed.
+func NewFollowFS(stores context2.Stores, repo, label string, opts ...Option) (*FollowFS, error) {
+	if stores == nil {
+		return nil, fmt.Errorf("context stores are nil")
+	}
+	if label == "" {
+		return nil, fmt.Errorf("a label is required to follow")
+	}
+
+	fs := &followFsInternal{
+		fsCommon: fsCommon{
+			l: dlogger.MustGetLogger("info"),
+		},
+		stores:   stores,
+		repo:     repo,
+		label:    label,
+		interval: defaultFollowInterval,
+		opts:     opts,
+		template: defaultReadOnlyFS(nil),
+		stop:     make(chan struct{}),
+	}
+	for _, apply := range opts {
+		apply(fs)
+		apply(fs.template)
+	}
+	fs.l = fs.l.With(zap.String("repo", repo), zap.String("label", label))
+
+	if fs.MetricsEnabled() {
+		fs.m = fs.EnsureMetrics("fuse", &M{}).(*M)
+	}
+
+	bundleID, err := fs.labelBundleID()
+	if err != nil {
+		return nil, err
+	}
+	gen, err := fs.newGeneration(bundleID)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = fs.addGeneration(gen)
+
+	fs.l.Info("following label", zap.String("bundle", bundleID), zap.Duration("interval", fs.interval))
+
+	return &FollowFS{
+		fsInternal: fs,
+		server:     fuseutil.NewFileSystemServer(fs),
+	}, nil
+}
+
+// Mount a FollowFS (read-only)
+func (dfs *FollowFS) Mount(path string, opts ...MountOption) error {
+	return dfs.MountReadOnly(path, opts...)
+}
+
+// MountReadOnly a FollowFS, then start following the label
+func (dfs *FollowFS) MountReadOnly(path string, opts ...MountOption) error {
+	err := prepPath(path)
+	if err != nil {
+		return err
+	}
+
+	mountCfg := &jfuse.MountConfig{
+		Subtype:    "datamon",
+		ReadOnly:   true,
+		FSName:     dfs.fsInternal.repo,
+		VolumeName: dfs.fsInternal.label, // NOTE: OSX only option
+	}
+	for _, bapply := range opts {
+		bapply(mountCfg)
+	}
+
+	el, _ := zap.NewStdLogAt(dfs.fsInternal.l.
+		With(zap.String("fuse", "follow mount"), zap.String("mountpoint", path)), zapcore.ErrorLevel)
+	dl, _ := zap.NewStdLogAt(dfs.fsInternal.l.
+		With(zap.String("fuse-debug", "follow mount"), zap.String("mountpoint", path)), zapcore.DebugLevel)
+	mountCfg.ErrorLogger = el
+	mountCfg.DebugLogger = dl
+
+	dfs.mfs, err = jfuse.Mount(path, dfs.server, mountCfg)
+	if err != nil {
+		return err
+	}
+	dfs.fsInternal.l.Info("mounting", zap.String("mountpoint", path))
+	go dfs.fsInternal.follow()
+	return nil
+}
+
+// Unmount a FollowFS
+func (dfs *FollowFS) Unmount(path string) error {
+	dfs.fsInternal.l.Info("unmounting", zap.String("mountpoint", path))
+	if err := jfuse.Unmount(path); err != nil {
+		return err
+	}
+
+	if dfs.mfs != nil {
+		// wait for in-flight ops before releasing resources
+		if err := dfs.mfs.Join(context.Background()); err != nil {
+			return err
+		}
+	}
+	dfs.fsInternal.releaseResources()
+	return nil
+}
+
+// JoinMount blocks until a mounted file system has been unmounted.
+func (dfs *FollowFS) JoinMount(ctx context.Context) error {
+	if err := dfs.mfs.Join(ctx); err != nil {
+		return err
+	}
+	dfs.fsInternal.releaseResources()
+	return nil
+}
+
+func (fs *followFsInternal) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+
+	gen, local, err := fs.resolve(op.Parent)
+	if err != nil {
+		return
+	}
+	childEntry, err := gen.fs.lookup(local, op.Name)
+	if err != nil {
+		return
+	}
+
+	atomic.AddInt64(&gen.lookups, 1)
+	op.Entry.Child = gen.global(childEntry.iNode)
+	op.Entry.Attributes = childEntry.attributes
+	op.Entry.Generation = 1
+	op.Entry.AttributesExpiration = time.Now().Add(cacheYearLong)
+	op.Entry.EntryExpiration = op.Entry.AttributesExpiration
+	if op.Parent == fuseops.RootInodeID {
+		// entries at the root may be switched to another generation: they are cached by the kernel until the next check
+		op.Entry.EntryExpiration = time.Now().Add(fs.interval)
+	}
+	return
+}
+
+func (fs *followFsInternal) StatFS(ctx context.Context, op *fuseops.StatFSOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+
+	files, size := fs.current.fs.totals()
+	statFS(op, fs.current.fs.blockSize(), size, 0, files, 0)
+	return
+}
+
+func (fs *followFsInternal) GetInodeAttributes(ctx context.Context, op *fuseops.GetInodeAttributesOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+
+	gen, local, err := fs.resolve(op.Inode)
+	if err != nil {
+		return
+	}
+	fe, err := gen.fs.getFsEntry(local)
+	if err != nil {
+		return
+	}
+
+	op.Attributes = fe.attributes
+	op.AttributesExpiration = time.Now().Add(cacheYearLong)
+	if op.Inode == fuseops.RootInodeID {
+		op.AttributesExpiration = time.Now().Add(fs.interval)
+	}
+	return
+}
+
+func (fs *followFsInternal) ForgetInode(ctx context.Context, op *fuseops.ForgetInodeOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	fs.forget(op.Inode, op.N)
+	return
+}
+
+func (fs *followFsInternal) BatchForget(ctx context.Context, op *fuseops.BatchForgetOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	for _, entry := range op.Entries {
+		fs.forget(entry.Inode, entry.N)
+	}
+	return
+}
+
+func (fs *followFsInternal) OpenDir(ctx context.Context, op *fuseops.OpenDirOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+
+	gen, local, err := fs.resolve(op.Inode)
+	if err != nil {
+		return
+	}
+	fe, err := gen.fs.getFsEntry(local)
+	if err != nil {
+		return
+	}
+	if !fe.isDir() {
+		err = jfuse.ENOTDIR
+		return
+	}
+	op.Handle = fs.openHandle(gen)
+	return
+}
+
+func (fs *followFsInternal) ReadDir(ctx context.Context, op *fuseops.ReadDirOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+
+	// the directory is listed from the generation it was opened in
+	gen, err := fs.generation(fuseops.InodeID(op.Handle) >> repoSubtreeShift)
+	if err != nil {
+		return
+	}
+	local := op.Inode & repoLocalMask
+	if op.Inode == fuseops.RootInodeID {
+		local = fuseops.RootInodeID
+	}
+
+	return gen.fs.readDir(local, int(op.Offset), func(child fuseutil.Dirent) bool {
+		child.Inode = gen.global(child.Inode)
+		n := fuseutil.WriteDirent(op.Dst[op.BytesRead:], child)
+		op.BytesRead += n
+		return n > 0
+	})
+}
+
+func (fs *followFsInternal) ReleaseDirHandle(ctx context.Context, op *fuseops.ReleaseDirHandleOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	fs.releaseHandle(op.Handle)
+	return
+}
+
+func (fs *followFsInternal) OpenFile(ctx context.Context, op *fuseops.OpenFileOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+
+	gen, _, err := fs.resolve(op.Inode)
+	if err != nil {
+		return
+	}
+	op.Handle = fs.openHandle(gen)
+	return
+}
+
+func (fs *followFsInternal) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) (err error) {
+	var n int
+
+	tr := fs.opStart(ctx, op)
+	defer func() {
+		fs.opEnd(tr, op, err)
+		if fs.MetricsEnabled() {
+			fs.m.Volume.Files.Inc("read")
+			fs.m.Volume.Files.Size(int64(n), "read")
+			fs.m.Volume.IO.IORecord(tr.start, "read")(int64(n), err)
+		}
+	}()
+
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+
+	gen, local, err := fs.resolve(op.Inode)
+	if err != nil {
+		return
+	}
+	fe, err := gen.fs.getFsEntry(local)
+	if err != nil {
+		return
+	}
+	n, err = gen.fs.readAtBundle(tr.ctx, fe, op.Dst, op.Offset)
+	op.BytesRead = n
+	return err
+}
+
+func (fs *followFsInternal) ReleaseFileHandle(ctx context.Context, op *fuseops.ReleaseFileHandleOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	fs.releaseHandle(op.Handle)
+	return
+}
+
+func (fs *followFsInternal) FlushFile(ctx context.Context, op *fuseops.FlushFileOp) (err error) {
+	// noop
+	return
+}
+
+func (fs *followFsInternal) GetXattr(ctx context.Context, op *fuseops.GetXattrOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+
+	gen, local, err := fs.resolve(op.Inode)
+	if err != nil {
+		return
+	}
+	attrs, err := gen.fs.xattrs(local)
+	if err != nil {
+		return
+	}
+	return getXattr(op, attrs)
+}
+
+func (fs *followFsInternal) ListXattr(ctx context.Context, op *fuseops.ListXattrOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+
+	gen, local, err := fs.resolve(op.Inode)
+	if err != nil {
+		return
+	}
+	attrs, err := gen.fs.xattrs(local)
+	if err != nil {
+		return
+	}
+	return listXattr(op, attrs)
+}
+
+// resolve finds the generation of an iNode, and the iNode relative to this generation.
+//
+// This must be called while holding the lock.
+func (fs *followFsInternal) resolve(iNode fuseops.InodeID) (*followGeneration, fuseops.InodeID, error) {
+	if iNode == fuseops.RootInodeID {
+		return fs.current, fuseops.RootInodeID, nil
+	}
+	gen, err := fs.generation(iNode >> repoSubtreeShift)
+	if err != nil {
+		return nil, 0, err
+	}
+	return gen, iNode & repoLocalMask, nil
+}
+
+// generation retrieves a generation by ID, unless it has been released.
+//
+// This must be called while holding the lock.
+func (fs *followFsInternal) generation(id fuseops.InodeID) (*followGeneration, error) {
+	if id == 0 || int(id) > len(fs.generations) || fs.generations[id-1] == nil {
+		return nil, jfuse.ENOENT
+	}
+	return fs.generations[id-1], nil
+}
+
+// global yields the iNode exposed by the FS for an iNode local to a generation
+func (g *followGeneration) global(local fuseops.InodeID) fuseops.InodeID {
+	return g.id<<repoSubtreeShift | local
+}
+
+// openHandle allocates a handle tagged with a generation, which is retained until the handle is released
+func (fs *followFsInternal) openHandle(gen *followGeneration) fuseops.HandleID {
+	atomic.AddInt64(&gen.handles, 1)
+	seq := atomic.AddUint64(&fs.lastHandle, 1) & uint64(repoLocalMask)
+	return fuseops.HandleID(uint64(gen.id)<<repoSubtreeShift | seq)
+}
+
+// releaseHandle releases a handle, then releases its generation if it is retired and no longer used
+func (fs *followFsInternal) releaseHandle(handle fuseops.HandleID) {
+	fs.lock.RLock()
+	gen, err := fs.generation(fuseops.InodeID(handle) >> repoSubtreeShift)
+	fs.lock.RUnlock()
+	if err != nil {
+		return
+	}
+	if atomic.AddInt64(&gen.handles, -1) == 0 {
+		fs.retire(gen)
+	}
+}
+
+// forget drops references to an iNode held by the kernel, then releases its generation if it is retired and no longer used
+func (fs *followFsInternal) forget(iNode fuseops.InodeID, n uint64) {
+	if iNode == fuseops.RootInodeID {
+		return
+	}
+	fs.lock.RLock()
+	gen, err := fs.generation(iNode >> repoSubtreeShift)
+	fs.lock.RUnlock()
+	if err != nil {
+		return
+	}
+	if atomic.AddInt64(&gen.lookups, -int64(n)) <= 0 {
+		fs.retire(gen)
+	}
+}
+
+// retire releases a generation which is no longer current, once all its handles are released
+// and the kernel no longer refers to any of its iNodes
+func (fs *followFsInternal) retire(gen *followGeneration) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	if !gen.retired || atomic.LoadInt64(&gen.handles) > 0 || atomic.LoadInt64(&gen.lookups) > 0 || fs.generations[gen.id-1] == nil {
+		return
+	}
+	fs.generations[gen.id-1] = nil
+	gen.fs.releaseResources()
+	fs.l.Info("released previous bundle", zap.String("bundle", gen.bundleID))
+}
+
+// labelBundleID retrieves the bundle currently pointed to by the label
+func (fs *followFsInternal) labelBundleID() (string, error) {
+	label := core.NewLabel(core.LabelDescriptor(model.NewLabelDescriptor(model.LabelName(fs.label))))
+	bundle := core.NewBundle(core.Repo(fs.repo), core.ContextStores(fs.stores))
+	if err := label.DownloadDescriptor(context.Background(), bundle, false); err != nil {
+		return "", err
+	}
+	return label.Descriptor.BundleID, nil
+}
+
+// newGeneration prepares the tree of a bundle, before it is served
+func (fs *followFsInternal) newGeneration(bundleID string) (*followGeneration, error) {
+	bundle := core.NewBundle(
+		core.Repo(fs.repo),
+		core.BundleID(bundleID),
+		core.ContextStores(fs.stores),
+		core.Logger(fs.l),
+		core.BundleWithMetrics(fs.MetricsEnabled()),
+	)
+
+	opts := make([]Option, 0, len(fs.opts)+3)
+	opts = append(opts, fs.opts...)
+	opts = append(opts, Streaming(true), Label(fs.label))
+	if fs.template.indexDir != "" {
+		opts = append(opts, IndexDir(filepath.Join(fs.template.indexDir, bundleID)))
+	}
+
+	rofs, err := NewReadOnlyFS(bundle, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &followGeneration{
+		bundleID: bundleID,
+		fs:       rofs.fsInternal,
+	}, nil
+}
+
+// addGeneration makes a generation the current one, and retires the previous one
+func (fs *followFsInternal) addGeneration(gen *followGeneration) (*followGeneration, error) {
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	if fs.closed {
+		gen.fs.releaseResources()
+		return nil, fmt.Errorf("file system is released")
+	}
+
+	fs.generations = append(fs.generations, gen)
+	gen.id = fuseops.InodeID(len(fs.generations))
+	previous := fs.current
+	fs.current = gen
+	if previous != nil {
+		previous.retired = true
+	}
+	return previous, nil
+}
+
+// poll checks the label, and switches to a new generation whenever the label has moved
+func (fs *followFsInternal) poll() error {
+	bundleID, err := fs.labelBundleID()
+	if err != nil {
+		return err
+	}
+
+	fs.lock.RLock()
+	unchanged := bundleID == fs.current.bundleID
+	fs.lock.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	gen, err := fs.newGeneration(bundleID)
+	if err != nil {
+		return err
+	}
+	previous, err := fs.addGeneration(gen)
+	if err != nil {
+		return err
+	}
+	fs.retire(previous)
+
+	event := LabelSwitch{
+		Repo:     fs.repo,
+		Label:    fs.label,
+		Previous: previous.bundleID,
+		Current:  bundleID,
+		Time:     time.Now(),
+	}
+	fs.l.Info("label moved: switched to new bundle", zap.String("previous", event.Previous), zap.String("current", event.Current))
+	if fs.MetricsEnabled() {
+		fs.m.Usage.Inc("LabelSwitch")
+	}
+	if fs.onSwitch != nil {
+		fs.onSwitch(event)
+	}
+	return nil
+}
+
+// follow polls the label until the FS is released
+func (fs *followFsInternal) follow() {
+	ticker := time.NewTicker(fs.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-fs.stop:
+			return
+		case <-ticker.C:
+			if err := fs.poll(); err != nil {
+				// the current bundle keeps being served
+				fs.l.Warn("failed to follow label", zap.Error(err))
+			}
+		}
+	}
+}
+
+// releaseResources stops following the label and frees the resources held by all generations
+func (fs *followFsInternal) releaseResources() {
+	fs.release.Do(func() {
+		close(fs.stop)
+
+		fs.lock.Lock()
+		defer fs.lock.Unlock()
+
+		fs.closed = true
+		for i, gen := range fs.generations {
+			if gen != nil {
+				gen.fs.releaseResources()
+				fs.generations[i] = nil
+			}
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package fuse
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	jfuse "github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+)
+
+func TestFollowFS(t *testing.T) {
+	const (
+		repo  = "follow-fs-test-repo"
+		label = "production"
+	)
+	tmp, err := ioutil.TempDir("", "test-follow-fs-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	dir := func(parts ...string) string {
+		pth := filepath.Join(append([]string{tmp}, parts...)...)
+		require.NoError(t, os.MkdirAll(pth, 0700))
+		return pth
+	}
+	stores := mocks.FakeContext2(dir("meta"), dir("vmeta"), dir("blob"))
+	require.NoError(t, core.CreateRepo(model.RepoDescriptor{Name: repo, Description: "test"}, stores))
+
+	// upload a new version of a bundle, then move the label to this bundle
+	release := func(t testing.TB, version string, content string) string {
+		original := dir("original", version)
+		require.NoError(t, os.MkdirAll(filepath.Join(original, "dir"), 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(original, "dir", "file.txt"), []byte(content), 0600))
+
+		bundle := core.NewBundle(
+			core.Repo(repo),
+			core.BundleDescriptor(model.NewBundleDescriptor(model.Message(version))),
+			core.ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), original))),
+			core.ContextStores(stores),
+			core.Logger(mocks.TestLogger()),
+		)
+		require.NoError(t, core.Upload(context.Background(), bundle))
+
+		l := core.NewLabel(core.LabelDescriptor(model.NewLabelDescriptor(model.LabelName(label))))
+		require.NoError(t, l.UploadDescriptor(context.Background(), bundle))
+		return bundle.BundleID
+	}
+
+	_, err = NewFollowFS(stores, repo, label, Logger(mocks.TestLogger()))
+	require.Error(t, err, "expected a missing label to fail")
+
+	v1, content1 := release(t, "v1", "first version"), "first version"
+
+	for _, lazy := range []bool{false, true} {
+		lazy := lazy
+		name := "eagerly populated"
+		if lazy {
+			name = "lazily populated"
+		}
+
+		t.Run("should switch "+name+" bundles when the label moves", func(t *testing.T) {
+			var switches []LabelSwitch
+			index := filepath.Join(tmp, "index-"+name)
+			ffs, err := NewFollowFS(stores, repo, label,
+				Logger(mocks.TestLogger()),
+				LazyPopulate(lazy),
+				IndexDir(index),
+				OnLabelSwitch(func(event LabelSwitch) {
+					switches = append(switches, event)
+				}),
+			)
+			require.NoError(t, err)
+			fs := ffs.fsInternal
+			defer fs.releaseResources()
+
+			ctx := context.Background()
+			lookUpFile := func(t testing.TB) (fuseops.InodeID, fuseops.InodeID) {
+				d := &fuseops.LookUpInodeOp{Parent: fuseops.RootInodeID, Name: "dir"}
+				require.NoError(t, fs.LookUpInode(ctx, d))
+				f := &fuseops.LookUpInodeOp{Parent: d.Entry.Child, Name: "file.txt"}
+				require.NoError(t, fs.LookUpInode(ctx, f))
+				return d.Entry.Child, f.Entry.Child
+			}
+			read := func(t testing.TB, iNode fuseops.InodeID) string {
+				op := &fuseops.ReadFileOp{Inode: iNode, Dst: make([]byte, 100)}
+				require.NoError(t, fs.ReadFile(ctx, op))
+				return string(op.Dst[:op.BytesRead])
+			}
+			listRoot := func(t testing.TB) []string {
+				open := &fuseops.OpenDirOp{Inode: fuseops.RootInodeID}
+				require.NoError(t, fs.OpenDir(ctx, open))
+				defer func() {
+					require.NoError(t, fs.ReleaseDirHandle(ctx, &fuseops.ReleaseDirHandleOp{Handle: open.Handle}))
+				}()
+				op := &fuseops.ReadDirOp{Inode: fuseops.RootInodeID, Handle: open.Handle, Dst: make([]byte, 4096)}
+				require.NoError(t, fs.ReadDir(ctx, op))
+				return direntNames(t, op.Dst[:op.BytesRead])
+			}
+
+			// no switch while the label does not move
+			require.NoError(t, fs.poll())
+			assert.Empty(t, switches)
+
+			oldDir, old := lookUpFile(t)
+			assert.Equal(t, content1, read(t, old))
+			assert.Equal(t, []string{"dir"}, listRoot(t))
+			open := &fuseops.OpenFileOp{Inode: old}
+			require.NoError(t, fs.OpenFile(ctx, open))
+
+			content2 := "second version, " + name
+			v2 := release(t, "v2-"+name, content2)
+			require.NoError(t, fs.poll())
+			require.Len(t, switches, 1)
+			assert.Equal(t, v1, switches[0].Previous)
+			assert.Equal(t, v2, switches[0].Current)
+
+			// new opens are served by the new bundle
+			_, current := lookUpFile(t)
+			assert.NotEqual(t, old, current)
+			assert.Equal(t, content2, read(t, current))
+			op := &fuseops.GetXattrOp{Inode: current, Name: XattrBundle, Dst: make([]byte, 100)}
+			require.NoError(t, fs.GetXattr(ctx, op))
+			assert.Equal(t, v2, string(op.Dst[:op.BytesRead]))
+
+			stats := &fuseops.StatFSOp{}
+			require.NoError(t, fs.StatFS(ctx, stats))
+			assert.Equal(t, uint64(1), stats.Inodes)
+			assert.Equal(t, uint64(1), stats.Blocks)
+
+			// already open files keep serving the previous bundle
+			assert.Equal(t, content1, read(t, old))
+
+			// iNodes cached by the kernel keep resolving to the previous bundle
+			require.NoError(t, fs.ReleaseFileHandle(ctx, &fuseops.ReleaseFileHandleOp{Handle: open.Handle}))
+			attrs := &fuseops.GetInodeAttributesOp{Inode: old}
+			require.NoError(t, fs.GetInodeAttributes(ctx, attrs))
+			assert.Equal(t, uint64(len(content1)), attrs.Attributes.Size)
+			require.NoError(t, fs.GetInodeAttributes(ctx, &fuseops.GetInodeAttributesOp{Inode: oldDir}))
+
+			// the previous bundle is released once no longer used
+			require.NoError(t, fs.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: oldDir, N: 1}))
+			assert.Equal(t, content1, read(t, old))
+			require.NoError(t, fs.BatchForget(ctx, &fuseops.BatchForgetOp{Entries: []fuseops.BatchForgetEntry{{Inode: old, N: 1}}}))
+			err = fs.ReadFile(ctx, &fuseops.ReadFileOp{Inode: old, Dst: make([]byte, 100)})
+			assert.Equal(t, jfuse.ENOENT, err)
+			assert.Equal(t, content2, read(t, current))
+
+			if lazy {
+				assert.DirExists(t, filepath.Join(index, v2))
+			}
+			v1, content1 = v2, content2
+		})
+	}
+}
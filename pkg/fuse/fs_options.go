@@ -1,8 +1,12 @@
 package fuse
 
 import (
+	"time"
+
 	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fuseutil"
+	"github.com/oneconcern/datamon/pkg/cafs"
+	"github.com/oneconcern/datamon/pkg/core"
 	"go.uber.org/zap"
 )
 
@@ -20,6 +24,10 @@ func Logger(l *zap.Logger) Option {
 			fs.l = l
 		case *fsMutable:
 			fs.l = l
+		case *repoFsInternal:
+			fs.l = l
+		case *followFsInternal:
+			fs.l = l
 		}
 	}
 }
@@ -51,15 +59,124 @@ func Prefetch(ahead int) Option {
 	}
 }
 
+// ReadAhead enables adaptive prefetching on streamed FS operations, fetching up to maxWindow leaves
+// ahead of sequential reads (enabled when Streamed is true). This supersedes Prefetch.
+func ReadAhead(maxWindow int) Option {
+	return func(mfs fuseutil.FileSystem) {
+		if fs, ok := mfs.(*readOnlyFsInternal); ok {
+			fs.readAhead = maxWindow
+		}
+	}
+}
+
+// MaxPrefetches caps the number of leaves being prefetched at the same time by a streamed FS (enabled when ReadAhead is set).
+func MaxPrefetches(n int) Option {
+	return func(mfs fuseutil.FileSystem) {
+		if fs, ok := mfs.(*readOnlyFsInternal); ok {
+			fs.maxPrefetches = n
+		}
+	}
+}
+
+// LazyPopulate defers the loading of the bundle file index until directories are looked up or listed (RO mount only).
+//
+// Nodes are spilled to an embedded KV store, so the memory footprint remains bounded for bundles with many files.
+// This requires Streaming.
+func LazyPopulate(enabled bool) Option {
+	return func(mfs fuseutil.FileSystem) {
+		if fs, ok := mfs.(*readOnlyFsInternal); ok {
+			fs.lazyPopulate = enabled
+		}
+	}
+}
+
+// IndexDir sets the location of the KV store used by a lazily populated FS (enabled when LazyPopulate is true).
+//
+// Defaults to a temporary directory, removed when the FS is unmounted.
+func IndexDir(pth string) Option {
+	return func(mfs fuseutil.FileSystem) {
+		if fs, ok := mfs.(*readOnlyFsInternal); ok {
+			fs.indexDir = pth
+		}
+	}
+}
+
+// DiskCache sets a persistent leaf cache on local disk, shared with other mounts and downloads on the same host.
+//
+// On a mutable FS, this applies to files read from the source bundle.
+func DiskCache(cache *cafs.LeafCache) Option {
+	return func(mfs fuseutil.FileSystem) {
+		switch fs := mfs.(type) {
+		case *readOnlyFsInternal:
+			fs.leafCache = cache
+		case *fsMutable:
+			fs.leafCache = cache
+		}
+	}
+}
+
+// WithDiamonds exposes previews of the diamonds which are not committed yet (repo mount only).
+func WithDiamonds(enabled bool) Option {
+	return func(mfs fuseutil.FileSystem) {
+		if fs, ok := mfs.(*repoFsInternal); ok {
+			fs.withDiamonds = enabled
+		}
+	}
+}
+
+// FollowInterval sets the delay between two checks of the label followed by a mount (follow mount only).
+func FollowInterval(interval time.Duration) Option {
+	return func(mfs fuseutil.FileSystem) {
+		if fs, ok := mfs.(*followFsInternal); ok && interval > 0 {
+			fs.interval = interval
+		}
+	}
+}
+
+// OnLabelSwitch sets a callback invoked whenever a mount following a label switches to a new bundle (follow mount only).
+func OnLabelSwitch(fn func(LabelSwitch)) Option {
+	return func(mfs fuseutil.FileSystem) {
+		if fs, ok := mfs.(*followFsInternal); ok {
+			fs.onSwitch = fn
+		}
+	}
+}
+
+// Label records the label used to mount a bundle, exposed as an extended attribute (RO mount only).
+func Label(name string) Option {
+	return func(mfs fuseutil.FileSystem) {
+		if fs, ok := mfs.(*readOnlyFsInternal); ok {
+			fs.label = name
+		}
+	}
+}
+
 // VerifyHash enables hash verification on streamed FS read perations (enabled when Streamed is true).
+//
+// On a mutable FS, this applies to files read from the source bundle.
 func VerifyHash(enabled bool) Option {
 	return func(mfs fuseutil.FileSystem) {
-		if fs, ok := mfs.(*readOnlyFsInternal); ok {
+		switch fs := mfs.(type) {
+		case *readOnlyFsInternal:
+			fs.withVerifyHash = enabled
+		case *fsMutable:
 			fs.withVerifyHash = enabled
 		}
 	}
 }
 
+// SourceBundle starts a mutable FS from the content of an existing bundle (mutable mount only).
+//
+// Files from the source bundle are read lazily from the blob store. On commit, the new bundle
+// refers to the source bundle as its parent and only changed files are uploaded.
+func SourceBundle(source *core.Bundle) Option {
+	return func(mfs fuseutil.FileSystem) {
+		if fs, ok := mfs.(*fsMutable); ok {
+			fs.source = source
+		}
+	}
+}
+
 // WithMetrics toggles metrics on the fuse package
 func WithMetrics(enabled bool) Option {
 	return func(mfs fuseutil.FileSystem) {
@@ -68,6 +185,10 @@ func WithMetrics(enabled bool) Option {
 			fs.EnableMetrics(enabled)
 		case *fsMutable:
 			fs.EnableMetrics(enabled)
+		case *repoFsInternal:
+			fs.EnableMetrics(enabled)
+		case *followFsInternal:
+			fs.EnableMetrics(enabled)
 		}
 	}
 }
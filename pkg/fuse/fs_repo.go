@@ -0,0 +1,969 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	jfuse "github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/oneconcern/datamon/pkg/cafs"
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/oneconcern/datamon/pkg/dlogger"
+	"github.com/oneconcern/datamon/pkg/model"
+)
+
+const (
+	// iNodes of bundle subtrees are tagged with the ID of their subtree in their high bits.
+	//
+	// Top-level iNodes (repos, labels, bundles...) have no tag.
+	repoSubtreeShift                 = 40
+	repoLocalMask    fuseops.InodeID = 1<<repoSubtreeShift - 1
+
+	// defaultRepoListTTL is the delay after which a directory listing repos, labels, bundles or diamonds is refreshed
+	defaultRepoListTTL = time.Minute
+
+	repoLabelsDir   = "labels"
+	repoBundlesDir  = "bundles"
+	repoDiamondsDir = "diamonds"
+)
+
+type repoNodeKind uint8
+
+const (
+	repoNodeRoot repoNodeKind = iota
+	repoNodeRepo
+	repoNodeLabels
+	repoNodeBundles
+	repoNodeDiamonds
+	repoNodeBundle // root of a bundle subtree
+)
+
+// repoNode is a virtual directory in a repo FS: a repo, a list of labels, bundles or diamonds, or the root of a bundle
+type repoNode struct {
+	iNode    fuseops.InodeID
+	kind     repoNodeKind
+	fullPath string
+	repo     string
+	label    string // set for bundles exposed by label
+	target   string // the bundle ID or diamond ID a bundle node resolves to
+	ts       time.Time
+
+	lock sync.Mutex
+
+	// children of a listing node, sorted by name
+	children map[string]*repoNode
+	names    []string
+	listed   time.Time
+
+	// bundle subtree, instantiated on first access
+	bundleOpt core.BundleOption
+	sub       *readOnlyFsInternal
+	subID     fuseops.InodeID
+
+	// references to iNodes of the subtree held by the kernel (atomic).
+	// A superseded subtree (e.g. after its label moved) is released once the kernel no longer refers to it.
+	lookups    int64
+	superseded bool
+}
+
+// RepoFS is a virtual read-only filesystem exposing whole repositories as a browsable tree:
+//
+//	/{repo}/labels/{label}/...
+//	/{repo}/bundles/{bundle ID}/...
+//	/{repo}/diamonds/{diamond ID}/... (optional: a preview of a diamond which is not committed yet)
+//
+// Bundle subtrees are only instantiated when first visited, and share their content-addressable caches.
+// Subtrees which are no longer listed (e.g. after their label moved) are released once the kernel forgets their iNodes.
+type RepoFS struct {
+	mfs        *jfuse.MountedFileSystem // The mounted filesystem
+	fsInternal *repoFsInternal          // The core of the filesystem
+	server     jfuse.Server             // Fuse server
+}
+
+var _ MountableFS = &RepoFS{}
+
+type repoFsInternal struct {
+	fsCommon
+
+	stores       context2.Stores
+	repos        []string // when empty, all repos from the context are exposed
+	withDiamonds bool
+	listTTL      time.Duration
+
+	// options for all bundle subtrees
+	template *readOnlyFsInternal
+
+	lock      sync.RWMutex
+	nodes     map[fuseops.InodeID]*repoNode
+	lastINode fuseops.InodeID
+	subtrees  []*repoNode // indexed by subtree ID - 1
+
+	// content-addressable FS shared by all bundles with the same leaf layout
+	cafsLock sync.Mutex
+	cafs     map[string]cafs.Fs
+
+	release sync.Once
+}
+
+// NewRepoFS creates a new instance of a read-only file system exposing the labels, bundles and optionally
+// the diamonds of some repos.
+//
+// When no repo is specified, all repos available in the context are exposed.
+//
+// Options for read-only file systems apply to every bundle subtree, which are always streamed and lazily populated.
+func NewRepoFS(stores context2.Stores, repos []string, opts ...Option) (*RepoFS, error) {
+	if stores == nil {
+		return nil, fmt.Errorf("context stores are nil")
+	}
+
+	fs := &repoFsInternal{
+		fsCommon: fsCommon{
+			l: dlogger.MustGetLogger("info"),
+		},
+		stores:   stores,
+		repos:    repos,
+		listTTL:  defaultRepoListTTL,
+		template: defaultReadOnlyFS(nil),
+		nodes:    make(map[fuseops.InodeID]*repoNode),
+		cafs:     make(map[string]cafs.Fs),
+	}
+	for _, apply := range opts {
+		apply(fs)
+		apply(fs.template)
+	}
+
+	if fs.MetricsEnabled() {
+		fs.m = fs.EnsureMetrics("fuse", &M{}).(*M)
+	}
+
+	for _, repo := range repos {
+		if err := core.RepoExists(repo, stores); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	root := &repoNode{
+		iNode:    fuseops.RootInodeID,
+		kind:     repoNodeRoot,
+		fullPath: rootPath,
+		ts:       now,
+	}
+	fs.nodes[root.iNode] = root
+	fs.lastINode = firstINode
+
+	if len(repos) > 0 {
+		// the list of repos is fixed
+		descriptors := make([]model.RepoDescriptor, 0, len(repos))
+		for _, repo := range repos {
+			descriptors = append(descriptors, model.RepoDescriptor{Name: repo, Timestamp: now})
+		}
+		fs.setRepos(root, descriptors)
+	}
+
+	fs.l.Info("repo fs ready", zap.Strings("repos", repos), zap.Bool("diamonds", fs.withDiamonds))
+
+	return &RepoFS{
+		fsInternal: fs,
+		server:     fuseutil.NewFileSystemServer(fs),
+	}, nil
+}
+
+// Mount a RepoFS (read-only)
+func (dfs *RepoFS) Mount(path string, opts ...MountOption) error {
+	return dfs.MountReadOnly(path, opts...)
+}
+
+// MountReadOnly a RepoFS
+func (dfs *RepoFS) MountReadOnly(path string, opts ...MountOption) error {
+	err := prepPath(path)
+	if err != nil {
+		return err
+	}
+
+	mountCfg := &jfuse.MountConfig{
+		Subtype:    "datamon",
+		ReadOnly:   true,
+		FSName:     "datamon",
+		VolumeName: strings.Join(dfs.fsInternal.repos, ","), // NOTE: OSX only option
+	}
+	for _, bapply := range opts {
+		bapply(mountCfg)
+	}
+
+	el, _ := zap.NewStdLogAt(dfs.fsInternal.l.
+		With(zap.String("fuse", "repo mount"), zap.String("mountpoint", path)), zapcore.ErrorLevel)
+	dl, _ := zap.NewStdLogAt(dfs.fsInternal.l.
+		With(zap.String("fuse-debug", "repo mount"), zap.String("mountpoint", path)), zapcore.DebugLevel)
+	mountCfg.ErrorLogger = el
+	mountCfg.DebugLogger = dl
+
+	dfs.mfs, err = jfuse.Mount(path, dfs.server, mountCfg)
+	if err == nil {
+		dfs.fsInternal.l.Info("mounting", zap.String("mountpoint", path))
+	}
+	return err
+}
+
+// Unmount a RepoFS
+func (dfs *RepoFS) Unmount(path string) error {
+	dfs.fsInternal.l.Info("unmounting", zap.String("mountpoint", path))
+	if err := jfuse.Unmount(path); err != nil {
+		return err
+	}
+
+	if dfs.mfs != nil {
+		// wait for in-flight ops before releasing resources
+		if err := dfs.mfs.Join(context.Background()); err != nil {
+			return err
+		}
+	}
+	dfs.fsInternal.releaseResources()
+	return nil
+}
+
+// JoinMount blocks until a mounted file system has been unmounted.
+func (dfs *RepoFS) JoinMount(ctx context.Context) error {
+	if err := dfs.mfs.Join(ctx); err != nil {
+		return err
+	}
+	dfs.fsInternal.releaseResources()
+	return nil
+}
+
+func (fs *repoFsInternal) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	parent, local, err := fs.resolve(op.Parent)
+	if err != nil {
+		return
+	}
+
+	op.Entry.Generation = 1
+	op.Entry.AttributesExpiration = time.Now().Add(cacheYearLong)
+	op.Entry.EntryExpiration = op.Entry.AttributesExpiration
+
+	if parent.kind != repoNodeBundle {
+		child, e := fs.child(parent, op.Name)
+		if e != nil {
+			err = fs.repoError(e)
+			return
+		}
+		// listings may change: entries are cached by the kernel only until the listing is refreshed
+		op.Entry.EntryExpiration = time.Now().Add(fs.listTTL)
+		op.Entry.Child = child.iNode
+		op.Entry.Attributes = child.attributes()
+		return
+	}
+
+	var (
+		sub   *readOnlyFsInternal
+		subID fuseops.InodeID
+	)
+	for {
+		sub, subID, err = fs.subtree(parent)
+		if err != nil {
+			return
+		}
+		// the subtree is retained while looking up, and as long as the kernel refers to the child
+		if fs.retain(subID, sub) {
+			break
+		}
+	}
+	childEntry, e := sub.lazy.lookup(local, op.Name)
+	if e != nil {
+		fs.forget(subID<<repoSubtreeShift, 1)
+		err = sub.lazyError(e)
+		return
+	}
+	op.Entry.Child = subID<<repoSubtreeShift | childEntry.iNode
+	op.Entry.Attributes = childEntry.attributes
+	return
+}
+
+func (fs *repoFsInternal) GetInodeAttributes(ctx context.Context, op *fuseops.GetInodeAttributesOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	node, local, err := fs.resolve(op.Inode)
+	if err != nil {
+		return
+	}
+	op.AttributesExpiration = time.Now().Add(cacheYearLong)
+
+	if node.kind != repoNodeBundle || local == fuseops.RootInodeID {
+		op.Attributes = node.attributes()
+		return
+	}
+
+	fe, err := node.sub.getFsEntry(local)
+	if err != nil {
+		return
+	}
+	op.Attributes = fe.attributes
+	return
+}
+
+func (fs *repoFsInternal) ForgetInode(ctx context.Context, op *fuseops.ForgetInodeOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	fs.forget(op.Inode, op.N)
+	return
+}
+
+func (fs *repoFsInternal) BatchForget(ctx context.Context, op *fuseops.BatchForgetOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	for _, entry := range op.Entries {
+		fs.forget(entry.Inode, entry.N)
+	}
+	return
+}
+
+func (fs *repoFsInternal) OpenDir(ctx context.Context, op *fuseops.OpenDirOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	node, local, err := fs.resolve(op.Inode)
+	if err != nil || node.kind != repoNodeBundle {
+		return
+	}
+
+	sub, _, err := fs.subtree(node)
+	if err != nil {
+		return
+	}
+	fe, err := sub.getFsEntry(local)
+	if err != nil {
+		return
+	}
+	if !fe.isDir() {
+		err = jfuse.ENOTDIR
+	}
+	return
+}
+
+func (fs *repoFsInternal) ReadDir(ctx context.Context, op *fuseops.ReadDirOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	node, local, err := fs.resolve(op.Inode)
+	if err != nil {
+		return
+	}
+	offset := int(op.Offset)
+
+	if node.kind == repoNodeBundle {
+		sub, _, e := fs.subtree(node)
+		if e != nil {
+			err = e
+			return
+		}
+		e = sub.lazy.children(local, offset, func(child fuseutil.Dirent) bool {
+			child.Inode = node.global(child.Inode)
+			n := fuseutil.WriteDirent(op.Dst[op.BytesRead:], child)
+			op.BytesRead += n
+			return n > 0
+		})
+		err = sub.lazyError(e)
+		return
+	}
+
+	children, err := fs.list(node, offset == 0)
+	if err != nil {
+		err = fs.repoError(err)
+		return
+	}
+	if offset > len(children) {
+		err = jfuse.ENOENT
+		return
+	}
+
+	for i := offset; i < len(children); i++ {
+		n := fuseutil.WriteDirent(op.Dst[op.BytesRead:], fuseutil.Dirent{
+			Offset: fuseops.DirOffset(i + 1),
+			Inode:  children[i].iNode,
+			Name:   path.Base(children[i].fullPath),
+			Type:   fuseutil.DT_Directory,
+		})
+		if n == 0 {
+			break
+		}
+		op.BytesRead += n
+	}
+	return
+}
+
+func (fs *repoFsInternal) ReleaseDirHandle(ctx context.Context, op *fuseops.ReleaseDirHandleOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+	return
+}
+
+func (fs *repoFsInternal) OpenFile(ctx context.Context, op *fuseops.OpenFileOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+	return
+}
+
+func (fs *repoFsInternal) ReadFile(ctx context.Context, op *fuseops.ReadFileOp) (err error) {
+	var n int
+
+	tr := fs.opStart(ctx, op)
+	defer func() {
+		fs.opEnd(tr, op, err)
+		if fs.MetricsEnabled() {
+			fs.m.Volume.Files.Inc("read")
+			fs.m.Volume.Files.Size(int64(n), "read")
+			fs.m.Volume.IO.IORecord(tr.start, "read")(int64(n), err)
+		}
+	}()
+
+	node, local, err := fs.resolve(op.Inode)
+	if err != nil {
+		return
+	}
+	if node.kind != repoNodeBundle || node.sub == nil {
+		err = jfuse.EIO
+		return
+	}
+
+	fe, err := node.sub.getFsEntry(local)
+	if err != nil {
+		return
+	}
+	n, err = node.sub.readAtBundle(tr.ctx, fe, op.Dst, op.Offset)
+	op.BytesRead = n
+	return err
+}
+
+func (fs *repoFsInternal) ReleaseFileHandle(ctx context.Context, op *fuseops.ReleaseFileHandleOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+	return
+}
+
+func (fs *repoFsInternal) FlushFile(ctx context.Context, op *fuseops.FlushFileOp) (err error) {
+	// noop
+	return
+}
+
+// resolve finds the node for some iNode, and the iNode relative to its bundle subtree, if any
+func (fs *repoFsInternal) resolve(iNode fuseops.InodeID) (*repoNode, fuseops.InodeID, error) {
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+
+	subID := int(iNode >> repoSubtreeShift)
+	if subID == 0 {
+		node, found := fs.nodes[iNode]
+		if !found {
+			return nil, 0, jfuse.ENOENT
+		}
+		if node.kind == repoNodeBundle {
+			return node, fuseops.RootInodeID, nil
+		}
+		return node, 0, nil
+	}
+
+	if subID > len(fs.subtrees) || fs.subtrees[subID-1] == nil {
+		// released subtree
+		return nil, 0, jfuse.ENOENT
+	}
+	return fs.subtrees[subID-1], iNode & repoLocalMask, nil
+}
+
+// global yields the iNode exposed by the FS for an iNode local to a bundle subtree
+func (n *repoNode) global(local fuseops.InodeID) fuseops.InodeID {
+	if local == fuseops.RootInodeID {
+		return n.iNode
+	}
+	return n.subID<<repoSubtreeShift | local
+}
+
+func (n *repoNode) attributes() fuseops.InodeAttributes {
+	n.lock.Lock()
+	ts := n.ts
+	n.lock.Unlock()
+	return newFsEntry(newBundleEntry(n.fullPath), ts, n.iNode, dirLinkCount).attributes
+}
+
+// subtree instantiates the FS of a bundle on first access, and yields it with its ID.
+//
+// Only the bundle descriptor is retrieved at this stage: the file index is loaded lazily.
+func (fs *repoFsInternal) subtree(node *repoNode) (*readOnlyFsInternal, fuseops.InodeID, error) {
+	node.lock.Lock()
+	defer node.lock.Unlock()
+
+	if node.sub != nil {
+		return node.sub, node.subID, nil
+	}
+
+	logger := fs.l.With(zap.String("repo", node.repo), zap.String("path", node.fullPath))
+	bundle := core.NewBundle(
+		core.Repo(node.repo),
+		core.ContextStores(fs.stores),
+		node.bundleOpt,
+		core.Logger(fs.l),
+		core.BundleWithMetrics(fs.MetricsEnabled()),
+	)
+
+	err := core.DownloadDescriptor(context.Background(), bundle)
+	if err != nil {
+		logger.Info("failed to download bundle descriptor", zap.Error(err))
+		return nil, 0, jfuse.ENOENT
+	}
+
+	sub := defaultReadOnlyFS(bundle)
+	sub.l = logger.With(zap.String("bundle", bundle.BundleID))
+	sub.label = node.label
+	sub.streamed = true
+	sub.lazyPopulate = true
+	sub.withVerifyHash = fs.template.withVerifyHash
+	sub.EnableMetrics(fs.MetricsEnabled())
+	sub.m = fs.m
+	if fs.template.indexDir != "" {
+		sub.indexDir = filepath.Join(fs.template.indexDir, node.repo, strings.TrimPrefix(node.fullPath, "/"+node.repo))
+		if node.label != "" {
+			// a label may move to another bundle while the index of the former one is still in use
+			sub.indexDir = filepath.Join(sub.indexDir, bundle.BundleID)
+		}
+	}
+
+	sub.cafs, err = fs.sharedCafs(bundle)
+	if err != nil {
+		logger.Error("failed to create cafs", zap.Error(err))
+		return nil, 0, jfuse.EIO
+	}
+
+	if _, err = sub.populateLazyFS(bundle); err != nil {
+		return nil, 0, jfuse.EIO
+	}
+
+	node.sub = sub
+	fs.lock.Lock()
+	fs.subtrees = append(fs.subtrees, node)
+	node.subID = fuseops.InodeID(len(fs.subtrees))
+	fs.lock.Unlock()
+
+	return sub, node.subID, nil
+}
+
+// retain records a reference to an iNode of a bundle subtree, unless this subtree has been released meanwhile
+func (fs *repoFsInternal) retain(subID fuseops.InodeID, sub *readOnlyFsInternal) bool {
+	fs.lock.RLock()
+	defer fs.lock.RUnlock()
+
+	slot := fs.subtrees[subID-1]
+	if slot == nil || slot.sub != sub {
+		return false
+	}
+	atomic.AddInt64(&slot.lookups, 1)
+	return true
+}
+
+// forget drops references to an iNode of a bundle subtree, then releases this subtree if it is superseded and no longer used
+func (fs *repoFsInternal) forget(iNode fuseops.InodeID, n uint64) {
+	subID := iNode >> repoSubtreeShift
+	if subID == 0 {
+		return
+	}
+
+	fs.lock.RLock()
+	var remaining int64
+	var superseded bool
+	if int(subID) <= len(fs.subtrees) {
+		if slot := fs.subtrees[subID-1]; slot != nil {
+			remaining = atomic.AddInt64(&slot.lookups, -int64(n))
+			superseded = slot.superseded
+		}
+	}
+	fs.lock.RUnlock()
+
+	if superseded && remaining <= 0 {
+		fs.releaseSubtree(subID)
+	}
+}
+
+// supersede detaches the subtree of a node, which is no longer reachable by path.
+//
+// iNodes of the former subtree may still be cached by the kernel: they keep resolving to the former bundle
+// until the kernel forgets them.
+//
+// This must be called while holding the lock of the node.
+func (fs *repoFsInternal) supersede(node *repoNode) {
+	if node.sub == nil {
+		return
+	}
+
+	fs.lock.Lock()
+	detached := &repoNode{
+		iNode:      node.iNode,
+		kind:       node.kind,
+		fullPath:   node.fullPath,
+		repo:       node.repo,
+		label:      node.label,
+		sub:        node.sub,
+		subID:      node.subID,
+		lookups:    atomic.LoadInt64(&node.lookups),
+		superseded: true,
+	}
+	fs.subtrees[node.subID-1] = detached
+	fs.lock.Unlock()
+
+	node.sub = nil
+	node.subID = 0
+	atomic.StoreInt64(&node.lookups, 0)
+
+	fs.releaseSubtree(detached.subID)
+}
+
+// releaseSubtree frees the resources held by a superseded subtree, once the kernel no longer refers to it
+func (fs *repoFsInternal) releaseSubtree(subID fuseops.InodeID) {
+	fs.lock.Lock()
+	slot := fs.subtrees[subID-1]
+	if slot == nil || !slot.superseded || atomic.LoadInt64(&slot.lookups) > 0 {
+		fs.lock.Unlock()
+		return
+	}
+	fs.subtrees[subID-1] = nil
+	fs.lock.Unlock()
+
+	slot.sub.releaseResources()
+	fs.l.Info("released superseded bundle", zap.String("path", slot.fullPath), zap.String("bundle", slot.sub.bundle.BundleID))
+}
+
+// sharedCafs yields a content-addressable FS for a bundle, shared with all bundles with the same leaf layout
+func (fs *repoFsInternal) sharedCafs(bundle *core.Bundle) (cafs.Fs, error) {
+	fs.cafsLock.Lock()
+	defer fs.cafsLock.Unlock()
+
+	truncation := bundle.BundleDescriptor.Version < 1
+	key := fmt.Sprintf("%d-%t", bundle.BundleDescriptor.LeafSize, truncation)
+	if shared, ok := fs.cafs[key]; ok {
+		return shared, nil
+	}
+
+	shared, err := cafs.New(
+		cafs.LeafSize(bundle.BundleDescriptor.LeafSize),
+		cafs.LeafTruncation(truncation),
+		cafs.Backend(bundle.BlobStore()),
+		cafs.Logger(fs.l),
+		cafs.CacheSize(fs.template.lruSize),
+		cafs.Prefetch(fs.template.prefetch),
+		cafs.ReadAhead(fs.template.readAhead),
+		cafs.MaxPrefetches(fs.template.maxPrefetches),
+		cafs.VerifyHash(fs.template.withVerifyHash),
+		cafs.DiskCache(fs.template.leafCache),
+		cafs.WithMetrics(fs.MetricsEnabled()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	fs.cafs[key] = shared
+	return shared, nil
+}
+
+// child resolves a child of a virtual directory, from the latest listing or directly from the context if not listed yet
+func (fs *repoFsInternal) child(parent *repoNode, name string) (*repoNode, error) {
+	if _, err := fs.list(parent, false); err != nil {
+		return nil, err
+	}
+	parent.lock.Lock()
+	child, found := parent.children[name]
+	parent.lock.Unlock()
+	if found {
+		return child, nil
+	}
+
+	ctx := context.Background()
+	switch parent.kind {
+	case repoNodeRoot:
+		if len(fs.repos) > 0 {
+			return nil, jfuse.ENOENT
+		}
+		descriptor, e := core.GetRepoDescriptorByRepoName(fs.stores, name)
+		if e != nil {
+			return nil, jfuse.ENOENT
+		}
+		return fs.addChild(parent, name, repoNodeRepo, name, descriptor.Timestamp, ""), nil
+
+	case repoNodeLabels:
+		label := core.NewLabel(core.LabelDescriptor(model.NewLabelDescriptor(model.LabelName(name))))
+		bundle := core.NewBundle(core.Repo(parent.repo), core.ContextStores(fs.stores))
+		if e := label.DownloadDescriptor(ctx, bundle, false); e != nil {
+			return nil, jfuse.ENOENT
+		}
+		return fs.addChild(parent, name, repoNodeBundle, parent.repo, label.Descriptor.Timestamp, label.Descriptor.BundleID), nil
+
+	case repoNodeBundles:
+		child = fs.addChild(parent, name, repoNodeBundle, parent.repo, time.Now(), name)
+		if _, _, e := fs.subtree(child); e != nil {
+			fs.removeChild(parent, name)
+			return nil, e
+		}
+		return child, nil
+
+	case repoNodeDiamonds:
+		if !fs.withDiamonds {
+			return nil, jfuse.ENOENT
+		}
+		diamond, e := core.GetDiamond(parent.repo, name, fs.stores)
+		if e != nil {
+			return nil, jfuse.ENOENT
+		}
+		return fs.addChild(parent, name, repoNodeBundle, parent.repo, diamond.StartTime, name), nil
+
+	default:
+		return nil, jfuse.ENOENT
+	}
+}
+
+// list the children of a virtual directory.
+//
+// Listings are refreshed when they get older than the listing TTL, and a refresh is requested.
+func (fs *repoFsInternal) list(node *repoNode, refresh bool) ([]*repoNode, error) {
+	node.lock.Lock()
+	stale := node.listed.IsZero() || (refresh && time.Since(node.listed) > fs.listTTL)
+	node.lock.Unlock()
+
+	if stale {
+		if err := fs.refresh(node); err != nil {
+			return nil, err
+		}
+	}
+
+	node.lock.Lock()
+	defer node.lock.Unlock()
+
+	children := make([]*repoNode, 0, len(node.names))
+	for _, name := range node.names {
+		children = append(children, node.children[name])
+	}
+	return children, nil
+}
+
+func (fs *repoFsInternal) refresh(node *repoNode) error {
+	switch node.kind {
+	case repoNodeRoot:
+		if len(fs.repos) > 0 {
+			return nil
+		}
+		repos, err := core.ListRepos(fs.stores)
+		if err != nil {
+			return err
+		}
+		fs.setRepos(node, repos)
+
+		listed := make(map[string]bool, len(repos))
+		for _, repo := range repos {
+			listed[repo.Name] = true
+		}
+		fs.pruneChildren(node, listed)
+
+	case repoNodeRepo:
+		now := time.Now()
+		fs.addChild(node, repoLabelsDir, repoNodeLabels, node.repo, now, "")
+		fs.addChild(node, repoBundlesDir, repoNodeBundles, node.repo, now, "")
+		if fs.withDiamonds {
+			fs.addChild(node, repoDiamondsDir, repoNodeDiamonds, node.repo, now, "")
+		}
+
+	case repoNodeLabels:
+		labels, err := core.ListLabels(node.repo, fs.stores)
+		if err != nil {
+			return err
+		}
+		listed := make(map[string]bool, len(labels))
+		for _, label := range labels {
+			fs.addChild(node, label.Name, repoNodeBundle, node.repo, label.Timestamp, label.BundleID)
+			listed[label.Name] = true
+		}
+		fs.pruneChildren(node, listed)
+
+	case repoNodeBundles:
+		bundles, err := core.ListBundles(node.repo, fs.stores)
+		if err != nil {
+			return err
+		}
+		listed := make(map[string]bool, len(bundles))
+		for _, bundle := range bundles {
+			fs.addChild(node, bundle.ID, repoNodeBundle, node.repo, bundle.Timestamp, bundle.ID)
+			listed[bundle.ID] = true
+		}
+		fs.pruneChildren(node, listed)
+
+	case repoNodeDiamonds:
+		diamonds, err := core.ListDiamonds(node.repo, fs.stores)
+		if err != nil {
+			return err
+		}
+		listed := make(map[string]bool, len(diamonds))
+		for _, diamond := range diamonds {
+			if diamond.State != model.DiamondInitialized {
+				// committed diamonds are available as bundles
+				continue
+			}
+			fs.addChild(node, diamond.DiamondID, repoNodeBundle, node.repo, diamond.StartTime, diamond.DiamondID)
+			listed[diamond.DiamondID] = true
+		}
+		fs.pruneChildren(node, listed)
+	}
+
+	node.lock.Lock()
+	node.listed = time.Now()
+	node.lock.Unlock()
+	return nil
+}
+
+func (fs *repoFsInternal) setRepos(root *repoNode, repos []model.RepoDescriptor) {
+	for _, repo := range repos {
+		fs.addChild(root, repo.Name, repoNodeRepo, repo.Name, repo.Timestamp, "")
+	}
+	root.lock.Lock()
+	root.listed = time.Now()
+	root.lock.Unlock()
+}
+
+// addChild adds a child to a virtual directory, or updates an existing one.
+//
+// Existing children are retained, so their iNode remains stable. When a bundle node resolves to a different bundle
+// (e.g. a label has moved), the subtree of the former bundle is detached and the new one is instantiated on next access.
+func (fs *repoFsInternal) addChild(parent *repoNode, name string, kind repoNodeKind, repo string, ts time.Time, target string) *repoNode {
+	parent.lock.Lock()
+	defer parent.lock.Unlock()
+
+	if child, found := parent.children[name]; found {
+		fs.updateChild(child, ts, target, fs.bundleOption(parent.kind, target))
+		return child
+	}
+
+	fs.lock.Lock()
+	fs.lastINode++
+	child := &repoNode{
+		iNode:     fs.lastINode,
+		kind:      kind,
+		fullPath:  path.Join(parent.fullPath, name),
+		repo:      repo,
+		target:    target,
+		ts:        ts,
+		bundleOpt: fs.bundleOption(parent.kind, target),
+	}
+	if parent.kind == repoNodeLabels {
+		child.label = name
+	}
+	fs.nodes[child.iNode] = child
+	fs.lock.Unlock()
+
+	if parent.children == nil {
+		parent.children = make(map[string]*repoNode)
+	}
+	parent.children[name] = child
+
+	i := sort.SearchStrings(parent.names, name)
+	parent.names = append(parent.names, "")
+	copy(parent.names[i+1:], parent.names[i:])
+	parent.names[i] = name
+
+	return child
+}
+
+// updateChild refreshes the timestamp of a child, and the bundle it resolves to
+func (fs *repoFsInternal) updateChild(child *repoNode, ts time.Time, target string, bundleOpt core.BundleOption) {
+	child.lock.Lock()
+	defer child.lock.Unlock()
+
+	child.ts = ts
+	if child.target == target {
+		return
+	}
+	child.target = target
+	child.bundleOpt = bundleOpt
+	fs.supersede(child)
+}
+
+// bundleOption yields the option to retrieve the bundle a child resolves to
+func (fs *repoFsInternal) bundleOption(parentKind repoNodeKind, target string) core.BundleOption {
+	switch {
+	case target == "":
+		return nil
+	case parentKind == repoNodeDiamonds:
+		return core.BundlePreviewDiamond(target)
+	default:
+		return core.BundleID(target)
+	}
+}
+
+// pruneChildren removes the children of a virtual directory which are no longer listed
+func (fs *repoFsInternal) pruneChildren(parent *repoNode, listed map[string]bool) {
+	parent.lock.Lock()
+	names := make([]string, 0, len(parent.names))
+	for _, name := range parent.names {
+		if !listed[name] {
+			names = append(names, name)
+		}
+	}
+	parent.lock.Unlock()
+
+	for _, name := range names {
+		fs.removeChild(parent, name)
+	}
+}
+
+func (fs *repoFsInternal) removeChild(parent *repoNode, name string) {
+	parent.lock.Lock()
+	defer parent.lock.Unlock()
+
+	child, found := parent.children[name]
+	if !found {
+		return
+	}
+	delete(parent.children, name)
+	i := sort.SearchStrings(parent.names, name)
+	parent.names = append(parent.names[:i], parent.names[i+1:]...)
+
+	child.lock.Lock()
+	fs.supersede(child)
+	child.lock.Unlock()
+
+	fs.lock.Lock()
+	delete(fs.nodes, child.iNode)
+	fs.lock.Unlock()
+}
+
+// repoError converts errors from the context stores into fuse errors
+func (fs *repoFsInternal) repoError(err error) error {
+	if err == nil || err == jfuse.ENOENT {
+		return err
+	}
+	fs.l.Error("repo fs error", zap.Error(err))
+	return jfuse.EIO
+}
+
+// releaseResources frees the resources held by all bundle subtrees
+func (fs *repoFsInternal) releaseResources() {
+	fs.release.Do(func() {
+		fs.lock.RLock()
+		defer fs.lock.RUnlock()
+
+		for _, node := range fs.subtrees {
+			if node != nil {
+				node.sub.releaseResources()
+			}
+		}
+	})
+}
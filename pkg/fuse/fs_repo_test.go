@@ -0,0 +1,221 @@
+package fuse
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	jfuse "github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+)
+
+// direntNames decodes the names written by fuseutil.WriteDirent
+func direntNames(t testing.TB, buf []byte) []string {
+	const headerSize = 24 // inode (8) | offset (8) | name length (4) | type (4)
+	names := make([]string, 0, 10)
+	for len(buf) > 0 {
+		require.True(t, len(buf) >= headerSize)
+		nameLen := int(binary.LittleEndian.Uint32(buf[16:]))
+		names = append(names, string(buf[headerSize:headerSize+nameLen]))
+		recordLen := headerSize + nameLen
+		if rem := recordLen % 8; rem > 0 {
+			recordLen += 8 - rem
+		}
+		buf = buf[recordLen:]
+	}
+	return names
+}
+
+func TestRepoFS(t *testing.T) {
+	const repo = "repo-fs-test-repo"
+	tmp, err := ioutil.TempDir("", "test-repo-fs-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	dir := func(parts ...string) string {
+		pth := filepath.Join(append([]string{tmp}, parts...)...)
+		require.NoError(t, os.MkdirAll(pth, 0700))
+		return pth
+	}
+	stores := mocks.FakeContext2(dir("meta"), dir("vmeta"), dir("blob"))
+	require.NoError(t, core.CreateRepo(model.RepoDescriptor{Name: repo, Description: "test"}, stores))
+
+	upload := func(t testing.TB, version string, content string) string {
+		original := dir("original", version)
+		require.NoError(t, os.MkdirAll(filepath.Join(original, "dir"), 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(original, "dir", "file.txt"), []byte(content), 0600))
+
+		bundle := core.NewBundle(
+			core.Repo(repo),
+			core.BundleDescriptor(model.NewBundleDescriptor(model.Message(version))),
+			core.ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), original))),
+			core.ContextStores(stores),
+			core.Logger(mocks.TestLogger()),
+		)
+		require.NoError(t, core.Upload(context.Background(), bundle))
+
+		label := core.NewLabel(core.LabelDescriptor(model.NewLabelDescriptor(model.LabelName(version))))
+		require.NoError(t, label.UploadDescriptor(context.Background(), bundle))
+		return bundle.BundleID
+	}
+	v1 := upload(t, "v1", "first version")
+	v2 := upload(t, "v2", "second version")
+
+	_, err = NewRepoFS(stores, []string{"missing-repo"}, Logger(mocks.TestLogger()))
+	require.Error(t, err)
+
+	rfs, err := NewRepoFS(stores, nil, Logger(mocks.TestLogger()), IndexDir(dir("index")))
+	require.NoError(t, err)
+	fs := rfs.fsInternal
+	defer fs.releaseResources()
+
+	ctx := context.Background()
+	lookUp := func(t testing.TB, parent fuseops.InodeID, name string) fuseops.ChildInodeEntry {
+		op := &fuseops.LookUpInodeOp{Parent: parent, Name: name}
+		require.NoError(t, fs.LookUpInode(ctx, op))
+		return op.Entry
+	}
+	lookUpPath := func(t testing.TB, parts ...string) fuseops.ChildInodeEntry {
+		var entry fuseops.ChildInodeEntry
+		parent := fuseops.InodeID(fuseops.RootInodeID)
+		for _, part := range parts {
+			entry = lookUp(t, parent, part)
+			parent = entry.Child
+		}
+		return entry
+	}
+	list := func(t testing.TB, iNode fuseops.InodeID) []string {
+		require.NoError(t, fs.OpenDir(ctx, &fuseops.OpenDirOp{Inode: iNode}))
+		op := &fuseops.ReadDirOp{Inode: iNode, Dst: make([]byte, 4096)}
+		require.NoError(t, fs.ReadDir(ctx, op))
+		return direntNames(t, op.Dst[:op.BytesRead])
+	}
+	read := func(t testing.TB, iNode fuseops.InodeID) string {
+		op := &fuseops.ReadFileOp{Inode: iNode, Dst: make([]byte, 100)}
+		require.NoError(t, fs.ReadFile(ctx, op))
+		return string(op.Dst[:op.BytesRead])
+	}
+
+	t.Run("should list repos, labels and bundles", func(t *testing.T) {
+		assert.Equal(t, []string{repo}, list(t, fuseops.RootInodeID))
+		r := lookUp(t, fuseops.RootInodeID, repo)
+		assert.True(t, r.Attributes.Mode.IsDir())
+		assert.Equal(t, []string{"bundles", "labels"}, list(t, r.Child))
+
+		labels := lookUp(t, r.Child, "labels")
+		assert.Equal(t, []string{"v1", "v2"}, list(t, labels.Child))
+
+		bundles := lookUp(t, r.Child, "bundles")
+		assert.ElementsMatch(t, []string{v1, v2}, list(t, bundles.Child))
+
+		err := fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: r.Child, Name: "diamonds"})
+		assert.Equal(t, jfuse.ENOENT, err)
+		err = fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: labels.Child, Name: "missing"})
+		assert.Equal(t, jfuse.ENOENT, err)
+		err = fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: bundles.Child, Name: "missing"})
+		assert.Equal(t, jfuse.ENOENT, err)
+	})
+
+	t.Run("should browse bundle subtrees", func(t *testing.T) {
+		byLabel := lookUpPath(t, repo, "labels", "v1", "dir", "file.txt")
+		assert.Equal(t, uint64(len("first version")), byLabel.Attributes.Size)
+		assert.Equal(t, "first version", read(t, byLabel.Child))
+
+		byID := lookUpPath(t, repo, "bundles", v2, "dir", "file.txt")
+		assert.Equal(t, "second version", read(t, byID.Child))
+		assert.NotEqual(t, byLabel.Child, byID.Child)
+
+		d := lookUpPath(t, repo, "labels", "v2")
+		assert.Equal(t, []string{"dir"}, list(t, d.Child))
+		attrs := &fuseops.GetInodeAttributesOp{Inode: byID.Child}
+		require.NoError(t, fs.GetInodeAttributes(ctx, attrs))
+		assert.Equal(t, uint64(len("second version")), attrs.Attributes.Size)
+
+		// all subtrees share the same cafs, and get their own index
+		assert.Len(t, fs.cafs, 1)
+		assert.DirExists(t, filepath.Join(tmp, "index", repo, "labels", "v1"))
+	})
+
+	t.Run("should expose provenance as extended attributes", func(t *testing.T) {
+		getXattr := func(t testing.TB, iNode fuseops.InodeID, name string) string {
+			op := &fuseops.GetXattrOp{Inode: iNode, Name: name, Dst: make([]byte, 256)}
+			require.NoError(t, fs.GetXattr(ctx, op))
+			return string(op.Dst[:op.BytesRead])
+		}
+		listXattr := func(t testing.TB, iNode fuseops.InodeID) []string {
+			op := &fuseops.ListXattrOp{Inode: iNode, Dst: make([]byte, 200)}
+			require.NoError(t, fs.ListXattr(ctx, op))
+			return xattrNames(op.Dst[:op.BytesRead])
+		}
+
+		byLabel := lookUpPath(t, repo, "labels", "v1", "dir", "file.txt")
+		assert.ElementsMatch(t, []string{XattrRepo, XattrBundle, XattrLabel, XattrHash, XattrSize}, listXattr(t, byLabel.Child))
+		assert.Equal(t, repo, getXattr(t, byLabel.Child, XattrRepo))
+		assert.Equal(t, v1, getXattr(t, byLabel.Child, XattrBundle))
+		assert.Equal(t, "v1", getXattr(t, byLabel.Child, XattrLabel))
+		assert.Equal(t, "13", getXattr(t, byLabel.Child, XattrSize))
+		assert.NotEmpty(t, getXattr(t, byLabel.Child, XattrHash))
+
+		byID := lookUpPath(t, repo, "bundles", v1, "dir", "file.txt")
+		assert.ElementsMatch(t, []string{XattrRepo, XattrBundle, XattrHash, XattrSize}, listXattr(t, byID.Child))
+		assert.Equal(t, getXattr(t, byLabel.Child, XattrHash), getXattr(t, byID.Child, XattrHash))
+
+		root := lookUpPath(t, repo, "labels", "v2")
+		assert.ElementsMatch(t, []string{XattrRepo, XattrBundle, XattrLabel}, listXattr(t, root.Child))
+		assert.Equal(t, v2, getXattr(t, root.Child, XattrBundle))
+
+		assert.Empty(t, listXattr(t, lookUpPath(t, repo, "labels", "v2", "dir").Child))
+		assert.Empty(t, listXattr(t, lookUpPath(t, repo, "labels").Child))
+		err := fs.GetXattr(ctx, &fuseops.GetXattrOp{Inode: root.Child, Name: XattrHash})
+		assert.Equal(t, jfuse.ENOATTR, err)
+	})
+
+	t.Run("should refresh moved and deleted labels", func(t *testing.T) {
+		before := lookUpPath(t, repo, "labels", "v1")
+		file := lookUpPath(t, repo, "labels", "v1", "dir", "file.txt")
+		require.Equal(t, "first version", read(t, file.Child))
+
+		moved := core.NewLabel(core.LabelDescriptor(model.NewLabelDescriptor(model.LabelName("v1"))))
+		require.NoError(t, moved.UploadDescriptor(ctx, core.NewBundle(core.Repo(repo), core.BundleID(v2), core.ContextStores(stores))))
+		require.NoError(t, core.DeleteLabel(repo, stores, "v2", core.WithDeleteSkipCheckRepo(true)))
+
+		fs.listTTL = 0
+		defer func() {
+			fs.listTTL = defaultRepoListTTL
+		}()
+		labels := lookUpPath(t, repo, "labels")
+		assert.Equal(t, []string{"v1"}, list(t, labels.Child))
+		err := fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: labels.Child, Name: "v2"})
+		assert.Equal(t, jfuse.ENOENT, err)
+
+		after := lookUpPath(t, repo, "labels", "v1")
+		assert.Equal(t, before.Child, after.Child, "the iNode of a label is stable")
+		assert.Equal(t, "second version", read(t, lookUpPath(t, repo, "labels", "v1", "dir", "file.txt").Child))
+		assert.Equal(t, "first version", read(t, file.Child), "iNodes of the former bundle still resolve")
+
+		// the former bundle is released once the kernel forgets all its iNodes
+		former := file.Child >> repoSubtreeShift
+		lookups := fs.subtrees[former-1].lookups
+		require.True(t, lookups > 1)
+		require.NoError(t, fs.ForgetInode(ctx, &fuseops.ForgetInodeOp{Inode: file.Child, N: uint64(lookups - 1)}))
+		assert.Equal(t, "first version", read(t, file.Child))
+		require.NoError(t, fs.BatchForget(ctx, &fuseops.BatchForgetOp{Entries: []fuseops.BatchForgetEntry{{Inode: file.Child, N: 1}}}))
+		assert.Nil(t, fs.subtrees[former-1])
+		err = fs.ReadFile(ctx, &fuseops.ReadFileOp{Inode: file.Child, Dst: make([]byte, 100)})
+		assert.Equal(t, jfuse.ENOENT, err)
+		assert.Equal(t, "second version", read(t, lookUpPath(t, repo, "labels", "v1", "dir", "file.txt").Child))
+	})
+}
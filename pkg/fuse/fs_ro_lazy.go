@@ -0,0 +1,385 @@
+package fuse
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	lru "github.com/hashicorp/golang-lru"
+	jfuse "github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"go.uber.org/zap"
+
+	"github.com/oneconcern/datamon/pkg/model"
+)
+
+const (
+	// defaultLazyCacheEntries is the number of nodes kept in memory by a lazily populated FS
+	defaultLazyCacheEntries = 65536
+
+	lazyPrefixNode  byte = 'i' // iNode -> node
+	lazyPrefixDir   byte = 'd' // directory path -> iNode
+	lazyPrefixChild byte = 'c' // parent iNode + child name -> child iNode + dirent type
+)
+
+// lazyIndex holds the nodes of a read-only FS which is populated on demand.
+//
+// The index chunks of the bundle are loaded as directories are looked up or listed:
+// a lookup loads chunks until the child is found, a listing loads all remaining chunks.
+//
+// Nodes are spilled to an embedded KV store (pebble), and a bounded number of them are cached in memory.
+type lazyIndex struct {
+	db    *pebble.DB
+	dir   string
+	owned bool // the KV store is removed when closed
+
+	l  *zap.Logger
+	ts time.Time
+
+	// fetch retrieves the entries listed in some index chunk
+	chunks uint64
+	fetch  func(uint64) ([]model.BundleEntry, error)
+
+	lock      sync.Mutex // serializes the loading of index chunks
+	loaded    uint64     // number of index chunks loaded so far (atomic)
+	lastINode fuseops.InodeID
+
+	// totals of the bundle, when known before its index is loaded.
+	// Otherwise, the entries of the chunks loaded so far are counted (atomic).
+	files    uint64
+	size     uint64
+	counting bool
+
+	cache *lru.Cache // iNode -> FsEntry
+}
+
+// newLazyIndex opens a KV store to spill the nodes of a lazily populated FS.
+//
+// When no directory is specified, the KV store is created in a temporary directory and removed upon close.
+//
+// When the totals of the bundle are known, they are reported by statistics whatever the chunks loaded.
+// Otherwise, statistics only report about the chunks loaded so far.
+func newLazyIndex(dir string, chunks uint64, fetch func(uint64) ([]model.BundleEntry, error), totals lazyTotals, ts time.Time, l *zap.Logger) (*lazyIndex, error) {
+	x := &lazyIndex{
+		dir:       dir,
+		l:         l,
+		ts:        ts,
+		chunks:    chunks,
+		fetch:     fetch,
+		lastINode: firstINode,
+		files:     totals.files,
+		size:      totals.size,
+		counting:  !totals.known,
+	}
+
+	if x.dir == "" {
+		tmp, err := ioutil.TempDir("", "datamon-fuse-index-")
+		if err != nil {
+			return nil, err
+		}
+		x.dir = tmp
+		x.owned = true
+	} else if err := os.MkdirAll(x.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	cache, err := lru.New(defaultLazyCacheEntries)
+	if err != nil {
+		return nil, err
+	}
+	x.cache = cache
+
+	options := new(pebble.Options)
+	options.EnsureDefaults()
+	options.DisableWAL = true // the index may be rebuilt at any time from the bundle
+
+	db, err := pebble.Open(x.dir, options)
+	if err != nil {
+		return nil, err
+	}
+	x.db = db
+
+	// scratch any pre-existing index, then add the root
+	batch := x.db.NewBatch()
+	if err = batch.DeleteRange([]byte{0}, []byte{0xff}, nil); err != nil {
+		return nil, err
+	}
+	if err = x.setNode(batch, fuseops.RootInodeID, "", rootPath, 0); err != nil {
+		return nil, err
+	}
+	if err = batch.Commit(pebble.NoSync); err != nil {
+		return nil, err
+	}
+
+	return x, nil
+}
+
+func (x *lazyIndex) close() error {
+	err := x.db.Close()
+	if x.owned {
+		_ = os.RemoveAll(x.dir)
+	}
+	return err
+}
+
+func (x *lazyIndex) isComplete() bool {
+	return atomic.LoadUint64(&x.loaded) >= x.chunks
+}
+
+// loadNext loads the next index chunk into the KV store
+func (x *lazyIndex) loadNext() error {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	chunk := atomic.LoadUint64(&x.loaded)
+	if chunk >= x.chunks {
+		return nil
+	}
+
+	x.l.Debug("loading index chunk", zap.Uint64("chunk", chunk), zap.Uint64("chunks", x.chunks))
+	entries, err := x.fetch(chunk)
+	if err != nil {
+		x.l.Error("failed to load index chunk", zap.Uint64("chunk", chunk), zap.Error(err))
+		return err
+	}
+
+	// an indexed batch lets directories created by this chunk be resolved before commit
+	batch := x.db.NewIndexedBatch()
+	for _, entry := range entries {
+		if err = x.addEntry(batch, entry); err != nil {
+			_ = batch.Close()
+			return err
+		}
+	}
+	if err = batch.Commit(pebble.NoSync); err != nil {
+		return err
+	}
+
+	if x.counting {
+		var size uint64
+		for _, entry := range entries {
+			size += entry.Size
+		}
+		atomic.AddUint64(&x.files, uint64(len(entries)))
+		atomic.AddUint64(&x.size, size)
+	}
+	atomic.StoreUint64(&x.loaded, chunk+1)
+	x.l.Debug("index chunk loaded", zap.Uint64("chunk", chunk), zap.Int("entries", len(entries)))
+	return nil
+}
+
+// totals yields the number of files and the total size of the bundle, or of the chunks loaded so far if these are not known
+func (x *lazyIndex) totals() (uint64, uint64) {
+	return atomic.LoadUint64(&x.files), atomic.LoadUint64(&x.size)
+}
+
+// lazyTotals are the number of files and the total size of a bundle, if known without loading its index
+type lazyTotals struct {
+	files uint64
+	size  uint64
+	known bool
+}
+
+// bundleTotals determines the totals of a bundle from its descriptor, or from its entries if these are already known.
+//
+// Older bundles do not record their totals in their descriptor: these are left unknown rather than loading the whole index.
+func bundleTotals(descriptor model.BundleDescriptor, entries []model.BundleEntry) lazyTotals {
+	switch {
+	case len(entries) > 0:
+		totals := lazyTotals{files: uint64(len(entries)), known: true}
+		for _, entry := range entries {
+			totals.size += entry.Size
+		}
+		return totals
+	case descriptor.FileCount > 0 || descriptor.BundleEntriesFileCount == 0:
+		return lazyTotals{files: descriptor.FileCount, size: descriptor.Size, known: true}
+	default:
+		return lazyTotals{}
+	}
+}
+
+func (x *lazyIndex) loadAll() error {
+	for !x.isComplete() {
+		if err := x.loadNext(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *lazyIndex) nextINode() fuseops.InodeID {
+	x.lastINode++
+	return x.lastINode
+}
+
+func (x *lazyIndex) addEntry(batch *pebble.Batch, entry model.BundleEntry) error {
+	fullPath := strings.Trim(entry.NameWithPath, "/")
+	parent, err := x.ensureDir(batch, path.Dir(fullPath))
+	if err != nil {
+		return err
+	}
+
+	iNode := x.nextINode()
+	if err = x.setNode(batch, iNode, entry.Hash, entry.NameWithPath, entry.Size); err != nil {
+		return err
+	}
+	return batch.Set(childKey(parent, path.Base(fullPath)), childValue(iNode, fuseutil.DT_File), nil)
+}
+
+// ensureDir resolves the iNode of a directory, creating it as well as its missing parents if needed
+func (x *lazyIndex) ensureDir(batch *pebble.Batch, dirPath string) (fuseops.InodeID, error) {
+	if dirPath == "" || dirPath == "." || dirPath == "/" {
+		return fuseops.RootInodeID, nil
+	}
+
+	key := append([]byte{lazyPrefixDir}, dirPath...)
+	value, closer, err := batch.Get(key)
+	if err == nil {
+		iNode := fuseops.InodeID(binary.BigEndian.Uint64(value))
+		_ = closer.Close()
+		return iNode, nil
+	}
+	if !errors.Is(err, pebble.ErrNotFound) {
+		return 0, err
+	}
+
+	parent, err := x.ensureDir(batch, path.Dir(dirPath))
+	if err != nil {
+		return 0, err
+	}
+
+	iNode := x.nextINode()
+	if err = x.setNode(batch, iNode, "", dirPath, newBundleEntry(dirPath).Size); err != nil {
+		return 0, err
+	}
+	if err = batch.Set(key, formKey(iNode), nil); err != nil {
+		return 0, err
+	}
+	return iNode, batch.Set(childKey(parent, path.Base(dirPath)), childValue(iNode, fuseutil.DT_Directory), nil)
+}
+
+// setNode stores a node as: size (8 bytes) | hash length (2 bytes) | hash | full path
+func (x *lazyIndex) setNode(batch *pebble.Batch, iNode fuseops.InodeID, hash, fullPath string, size uint64) error {
+	value := make([]byte, 10, 10+len(hash)+len(fullPath))
+	binary.BigEndian.PutUint64(value, size)
+	binary.BigEndian.PutUint16(value[8:], uint16(len(hash)))
+	value = append(value, hash...)
+	value = append(value, fullPath...)
+
+	return batch.Set(append([]byte{lazyPrefixNode}, formKey(iNode)...), value, nil)
+}
+
+// node retrieves a node by iNode
+func (x *lazyIndex) node(iNode fuseops.InodeID) (*FsEntry, error) {
+	if v, ok := x.cache.Get(iNode); ok {
+		return v.(*FsEntry), nil
+	}
+
+	value, closer, err := x.db.Get(append([]byte{lazyPrefixNode}, formKey(iNode)...))
+	if err != nil {
+		if errors.Is(err, pebble.ErrNotFound) {
+			return nil, jfuse.ENOENT
+		}
+		return nil, err
+	}
+	defer func() {
+		_ = closer.Close()
+	}()
+
+	hashLen := int(binary.BigEndian.Uint16(value[8:]))
+	entry := &model.BundleEntry{
+		Size:         binary.BigEndian.Uint64(value),
+		Hash:         string(value[10 : 10+hashLen]),
+		NameWithPath: string(value[10+hashLen:]),
+	}
+
+	linkCount := fileLinkCount
+	if entry.Hash == "" {
+		linkCount = dirLinkCount
+	}
+	fe := newFsEntry(entry, x.ts, iNode, linkCount)
+	x.cache.Add(iNode, fe)
+
+	return fe, nil
+}
+
+// lookup resolves a child in a directory, loading index chunks until it is found
+func (x *lazyIndex) lookup(parent fuseops.InodeID, name string) (*FsEntry, error) {
+	key := childKey(parent, name)
+
+	for {
+		complete := x.isComplete()
+
+		value, closer, err := x.db.Get(key)
+		if err == nil {
+			iNode := fuseops.InodeID(binary.BigEndian.Uint64(value))
+			_ = closer.Close()
+			return x.node(iNode)
+		}
+		if !errors.Is(err, pebble.ErrNotFound) {
+			return nil, err
+		}
+
+		if complete {
+			return nil, jfuse.ENOENT
+		}
+
+		if err = x.loadNext(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// children lists the children of a directory, starting at some offset.
+//
+// All index chunks must be loaded to get the complete list.
+func (x *lazyIndex) children(parent fuseops.InodeID, offset int, apply func(fuseutil.Dirent) bool) error {
+	if err := x.loadAll(); err != nil {
+		return err
+	}
+
+	prefix := childKey(parent, "")
+	iterator := x.db.NewIter(&pebble.IterOptions{
+		LowerBound: prefix,
+		UpperBound: childKey(parent+1, ""),
+	})
+	defer func() {
+		_ = iterator.Close()
+	}()
+
+	i := 0
+	for valid := iterator.First(); valid; valid = iterator.Next() {
+		i++
+		if i <= offset {
+			continue
+		}
+
+		value := iterator.Value()
+		dirent := fuseutil.Dirent{
+			Offset: fuseops.DirOffset(i),
+			Inode:  fuseops.InodeID(binary.BigEndian.Uint64(value)),
+			Name:   string(iterator.Key()[len(prefix):]),
+			Type:   fuseutil.DirentType(value[intSize]),
+		}
+		if !apply(dirent) {
+			break
+		}
+	}
+	return iterator.Error()
+}
+
+func childKey(parent fuseops.InodeID, name string) []byte {
+	return append([]byte{lazyPrefixChild}, formLookupKey(parent, name)...)
+}
+
+func childValue(iNode fuseops.InodeID, direntType fuseutil.DirentType) []byte {
+	return append(formKey(iNode), byte(direntType))
+}
@@ -0,0 +1,249 @@
+package fuse
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	jfuse "github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+)
+
+func TestLazyIndex(t *testing.T) {
+	// 3 index chunks, with directories spread across chunks
+	chunks := [][]model.BundleEntry{
+		{
+			{NameWithPath: "/a.txt", Hash: "hash-a", Size: 1},
+			{NameWithPath: "/dir/b.txt", Hash: "hash-b", Size: 2},
+		},
+		{
+			{NameWithPath: "/dir/sub/c.txt", Hash: "hash-c", Size: 3},
+		},
+		{
+			{NameWithPath: "/dir/d.txt", Hash: "hash-d", Size: 4},
+			{NameWithPath: "/e.txt", Hash: "hash-e", Size: 5},
+		},
+	}
+	fetched := make([]uint64, 0, len(chunks))
+	fetch := func(chunk uint64) ([]model.BundleEntry, error) {
+		fetched = append(fetched, chunk)
+		return chunks[chunk], nil
+	}
+
+	x, err := newLazyIndex("", uint64(len(chunks)), fetch, lazyTotals{}, time.Now(), mocks.TestLogger())
+	require.NoError(t, err)
+	dir := x.dir
+	defer func() {
+		require.NoError(t, x.close())
+		_, err := os.Stat(dir)
+		assert.True(t, os.IsNotExist(err), "expected temporary index to be removed")
+	}()
+
+	root, err := x.node(fuseops.RootInodeID)
+	require.NoError(t, err)
+	assert.True(t, root.isDir())
+
+	t.Run("lookup should only load the chunks needed", func(t *testing.T) {
+		a, err := x.lookup(fuseops.RootInodeID, "a.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "hash-a", a.hash)
+		assert.Equal(t, uint64(1), a.attributes.Size)
+		assert.Equal(t, []uint64{0}, fetched)
+
+		d, err := x.lookup(fuseops.RootInodeID, "dir")
+		require.NoError(t, err)
+		assert.True(t, d.isDir())
+
+		sub, err := x.lookup(d.iNode, "sub")
+		require.NoError(t, err)
+		c, err := x.lookup(sub.iNode, "c.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "/dir/sub/c.txt", c.fullPath)
+		assert.Equal(t, []uint64{0, 1}, fetched)
+		assert.False(t, x.isComplete())
+
+		files, size := x.totals()
+		assert.Equal(t, uint64(3), files, "unknown totals only account for the chunks loaded so far")
+		assert.Equal(t, uint64(6), size)
+	})
+
+	t.Run("lookup should fail on missing entries, once all chunks are loaded", func(t *testing.T) {
+		_, err := x.lookup(fuseops.RootInodeID, "missing")
+		assert.Equal(t, jfuse.ENOENT, err)
+		assert.True(t, x.isComplete())
+		assert.Equal(t, []uint64{0, 1, 2}, fetched)
+
+		_, err = x.node(fuseops.InodeID(1000))
+		assert.Equal(t, jfuse.ENOENT, err)
+	})
+
+	t.Run("listing should resume from offset", func(t *testing.T) {
+		d, err := x.lookup(fuseops.RootInodeID, "dir")
+		require.NoError(t, err)
+
+		list := func(offset int) []fuseutil.Dirent {
+			var dirents []fuseutil.Dirent
+			require.NoError(t, x.children(d.iNode, offset, func(child fuseutil.Dirent) bool {
+				dirents = append(dirents, child)
+				return true
+			}))
+			return dirents
+		}
+
+		all := list(0)
+		names := make([]string, 0, len(all))
+		for _, dirent := range all {
+			names = append(names, dirent.Name)
+		}
+		assert.Equal(t, []string{"b.txt", "d.txt", "sub"}, names)
+		assert.Equal(t, fuseutil.DT_Directory, all[2].Type)
+
+		rest := list(int(all[0].Offset))
+		require.Len(t, rest, 2)
+		assert.Equal(t, all[1:], rest)
+	})
+}
+
+func TestReadOnlyFSLazy(t *testing.T) {
+	const repo = "lazy-test-repo"
+	tmp, err := ioutil.TempDir("", "test-lazy-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	dir := func(parts ...string) string {
+		pth := filepath.Join(append([]string{tmp}, parts...)...)
+		require.NoError(t, os.MkdirAll(pth, 0700))
+		return pth
+	}
+	stores := mocks.FakeContext2(dir("meta"), dir("vmeta"), dir("blob"))
+	require.NoError(t, core.CreateRepo(model.RepoDescriptor{Name: repo, Description: "test"}, stores))
+
+	original := dir("original")
+	files := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("dir%d/file%d.txt", i%3, i)] = fmt.Sprintf("content of file %d", i)
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(original, name)), 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(original, name), []byte(content), 0600))
+	}
+
+	source := core.NewBundle(
+		core.Repo(repo),
+		core.BundleDescriptor(model.NewBundleDescriptor(model.Message("lazy bundle"))),
+		core.ConsumableStore(localfs.New(afero.NewBasePathFs(afero.NewOsFs(), original))),
+		core.ContextStores(stores),
+		core.Logger(mocks.TestLogger()),
+	)
+	require.NoError(t, core.Upload(context.Background(), source))
+
+	mounted := core.NewBundle(core.Repo(repo), core.BundleID(source.BundleID), core.ContextStores(stores))
+
+	_, err = NewReadOnlyFS(mounted, LazyPopulate(true), Logger(mocks.TestLogger()))
+	require.Error(t, err, "expected lazy mode to require streaming")
+
+	index := filepath.Join(tmp, "index")
+	rfs, err := NewReadOnlyFS(mounted,
+		Streaming(true),
+		LazyPopulate(true),
+		IndexDir(index),
+		Logger(mocks.TestLogger()),
+	)
+	require.NoError(t, err)
+	fs := rfs.fsInternal
+	defer fs.releaseResources()
+	require.NotNil(t, fs.lazy)
+	assert.Empty(t, fs.fsEntryStore.Len())
+	assert.DirExists(t, index)
+
+	ctx := context.Background()
+	lookUp := func(t testing.TB, parent fuseops.InodeID, name string) fuseops.ChildInodeEntry {
+		op := &fuseops.LookUpInodeOp{Parent: parent, Name: name}
+		require.NoError(t, fs.LookUpInode(ctx, op))
+		return op.Entry
+	}
+
+	t.Run("should report bundle statistics before loading the index", func(t *testing.T) {
+		require.False(t, fs.lazy.isComplete())
+		var size uint64
+		for _, content := range files {
+			size += uint64(len(content))
+		}
+
+		op := &fuseops.StatFSOp{}
+		require.NoError(t, fs.StatFS(ctx, op))
+		assert.Equal(t, mounted.BundleDescriptor.LeafSize, op.BlockSize)
+		assert.Equal(t, uint64(len(files)), op.Inodes)
+		assert.Equal(t, (size+uint64(op.BlockSize)-1)/uint64(op.BlockSize), op.Blocks)
+		assert.Zero(t, op.BlocksFree)
+		assert.Zero(t, op.InodesFree)
+	})
+
+	t.Run("should look up and read files", func(t *testing.T) {
+		d := lookUp(t, fuseops.RootInodeID, "dir1")
+		f := lookUp(t, d.Child, "file4.txt")
+		assert.Equal(t, uint64(len(files["dir1/file4.txt"])), f.Attributes.Size)
+
+		attrs := &fuseops.GetInodeAttributesOp{Inode: f.Child}
+		require.NoError(t, fs.GetInodeAttributes(ctx, attrs))
+		assert.Equal(t, f.Attributes.Size, attrs.Attributes.Size)
+
+		op := &fuseops.ReadFileOp{Inode: f.Child, Dst: make([]byte, 100)}
+		require.NoError(t, fs.ReadFile(ctx, op))
+		assert.Equal(t, files["dir1/file4.txt"], string(op.Dst[:op.BytesRead]))
+
+		err := fs.LookUpInode(ctx, &fuseops.LookUpInodeOp{Parent: d.Child, Name: "file5.txt"})
+		assert.Equal(t, jfuse.ENOENT, err)
+	})
+
+	t.Run("should list directories", func(t *testing.T) {
+		require.NoError(t, fs.OpenDir(ctx, &fuseops.OpenDirOp{Inode: fuseops.RootInodeID}))
+
+		op := &fuseops.ReadDirOp{Inode: fuseops.RootInodeID, Dst: make([]byte, 4096)}
+		require.NoError(t, fs.ReadDir(ctx, op))
+		assert.NotZero(t, op.BytesRead)
+
+		d := lookUp(t, fuseops.RootInodeID, "dir0")
+		names := make([]string, 0, 4)
+		require.NoError(t, fs.lazy.children(d.Child, 0, func(child fuseutil.Dirent) bool {
+			names = append(names, child.Name)
+			return true
+		}))
+		sort.Strings(names)
+		assert.Equal(t, []string{"file0.txt", "file3.txt", "file6.txt", "file9.txt"}, names)
+	})
+}
+
+func TestBundleTotals(t *testing.T) {
+	entries := []model.BundleEntry{
+		{NameWithPath: "a", Size: 10}, {NameWithPath: "b", Size: 20}, {NameWithPath: "c", Size: 30},
+	}
+
+	totals := bundleTotals(model.BundleDescriptor{FileCount: 3, Size: 60, BundleEntriesFileCount: 2}, nil)
+	assert.Equal(t, lazyTotals{files: 3, size: 60, known: true}, totals, "totals recorded by the descriptor are used as is")
+
+	totals = bundleTotals(model.BundleDescriptor{BundleEntriesFileCount: 2}, nil)
+	assert.False(t, totals.known, "totals of older bundles are unknown until their index is loaded")
+
+	totals = bundleTotals(model.BundleDescriptor{BundleEntriesFileCount: 2}, entries)
+	assert.Equal(t, lazyTotals{files: 3, size: 60, known: true}, totals, "known entries are summed up")
+
+	totals = bundleTotals(model.BundleDescriptor{}, nil)
+	assert.Equal(t, lazyTotals{known: true}, totals, "an empty bundle has no files")
+}
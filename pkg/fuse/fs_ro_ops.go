@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"path"
+	"sync"
 	"time"
 
 	"github.com/oneconcern/datamon/pkg/cafs"
@@ -42,6 +43,13 @@ type readOnlyFsInternal struct {
 	// readonly
 	isReadOnly bool
 
+	// label used to mount the bundle, if any
+	label string
+
+	// number of files and total logical size of the bundle (eager mode)
+	files uint64
+	size  uint64
+
 	cafs     cafs.Fs
 	streamed bool
 
@@ -49,6 +57,15 @@ type readOnlyFsInternal struct {
 	withVerifyHash bool
 	lruSize        int
 	prefetch       int
+	readAhead      int
+	maxPrefetches  int
+	leafCache      *cafs.LeafCache
+
+	// Lazy mode options: nodes are loaded on demand and spilled to a KV store located in indexDir
+	lazyPopulate bool
+	indexDir     string
+	lazy         *lazyIndex
+	release      sync.Once
 }
 
 func defaultReadOnlyFS(bundle *core.Bundle) *readOnlyFsInternal {
@@ -70,42 +87,44 @@ func asFsEntry(p interface{}) *FsEntry {
 }
 
 func (fs *readOnlyFsInternal) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
-
-	lookupKey := formLookupKey(op.Parent, op.Name)
-	val, found := fs.lookupTree.Get(lookupKey)
-
-	if found {
-		childEntry := asFsEntry(val)
-		op.Entry.Attributes = childEntry.attributes
-		if fs.isReadOnly {
-			op.Entry.AttributesExpiration = time.Now().Add(cacheYearLong)
-			op.Entry.EntryExpiration = op.Entry.AttributesExpiration
-		}
-		op.Entry.Child = childEntry.iNode
-		op.Entry.Generation = 1
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
-	} else {
-		err = jfuse.ENOENT
+	childEntry, err := fs.lookup(op.Parent, op.Name)
+	if err != nil {
 		return
 	}
+	op.Entry.Attributes = childEntry.attributes
+	if fs.isReadOnly {
+		op.Entry.AttributesExpiration = time.Now().Add(cacheYearLong)
+		op.Entry.EntryExpiration = op.Entry.AttributesExpiration
+	}
+	op.Entry.Child = childEntry.iNode
+	op.Entry.Generation = 1
 	return nil
 }
 
+func (fs *readOnlyFsInternal) StatFS(
+	ctx context.Context,
+	op *fuseops.StatFSOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	files, size := fs.totals()
+	statFS(op, fs.blockSize(), size, 0, files, 0)
+	return
+}
+
 func (fs *readOnlyFsInternal) GetInodeAttributes(
 	ctx context.Context,
 	op *fuseops.GetInodeAttributesOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
-	key := formKey(op.Inode)
-	e, found := fs.fsEntryStore.Get(key)
-	if !found {
-		err = jfuse.ENOENT
+	fe, err := fs.getFsEntry(op.Inode)
+	if err != nil {
 		return
 	}
-	fe := asFsEntry(e)
 	op.AttributesExpiration = time.Now().Add(cacheYearLong)
 	op.Attributes = fe.attributes
 	return nil
@@ -114,21 +133,19 @@ func (fs *readOnlyFsInternal) GetInodeAttributes(
 func (fs *readOnlyFsInternal) ForgetInode(
 	ctx context.Context,
 	op *fuseops.ForgetInodeOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 	return
 }
 
 func (fs *readOnlyFsInternal) OpenDir(ctx context.Context, op *fuseops.OpenDirOp) (err error) {
-	t0 := fs.opStart(op)
-	fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	fs.opEnd(tr, op, err)
 
-	p, found := fs.fsEntryStore.Get(formKey(op.Inode))
-	if !found {
-		err = jfuse.ENOENT
+	fe, err := fs.getFsEntry(op.Inode)
+	if err != nil {
 		return
 	}
-	fe := asFsEntry(p)
 	if !fe.isDir() {
 		err = jfuse.ENOENT
 		return
@@ -137,47 +154,29 @@ func (fs *readOnlyFsInternal) OpenDir(ctx context.Context, op *fuseops.OpenDirOp
 }
 
 func (fs *readOnlyFsInternal) ReadDir(ctx context.Context, op *fuseops.ReadDirOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
-
-	offset := int(op.Offset)
-	iNode := op.Inode
-
-	children, found := fs.readDirMap[iNode]
-
-	if !found {
-		err = jfuse.ENOENT
-		return
-	}
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
-	if offset > len(children) {
-		err = jfuse.ENOENT
-		return
-	}
-
-	for i := offset; i < len(children); i++ {
-		n := fuseutil.WriteDirent(op.Dst[op.BytesRead:], children[i])
-		if n == 0 {
-			break
-		}
+	return fs.readDir(op.Inode, int(op.Offset), func(child fuseutil.Dirent) bool {
+		n := fuseutil.WriteDirent(op.Dst[op.BytesRead:], child)
 		op.BytesRead += n
-	}
-	return nil
+		return n > 0
+	})
 }
 
 func (fs *readOnlyFsInternal) ReleaseDirHandle(
 	ctx context.Context,
 	op *fuseops.ReleaseDirHandleOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 	return
 }
 
 func (fs *readOnlyFsInternal) OpenFile(
 	ctx context.Context,
 	op *fuseops.OpenFileOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 	return
 }
 
@@ -186,27 +185,25 @@ func (fs *readOnlyFsInternal) ReadFile(
 	op *fuseops.ReadFileOp) (err error) {
 	var n int
 
-	t0 := fs.opStart(op)
+	tr := fs.opStart(ctx, op)
 	defer func() {
-		fs.opEnd(t0, op, err)
+		fs.opEnd(tr, op, err)
 		if fs.MetricsEnabled() {
 			fs.m.Volume.Files.Inc("read")
 			fs.m.Volume.Files.Size(int64(n), "read")
-			fs.m.Volume.IO.IORecord(t0, "read")(int64(n), err)
+			fs.m.Volume.IO.IORecord(tr.start, "read")(int64(n), err)
 		}
 	}()
 
 	// If file has not been mutated.
-	p, found := fs.fsEntryStore.Get(formKey(op.Inode))
-	if !found {
-		err = jfuse.ENOENT
+	fe, err := fs.getFsEntry(op.Inode)
+	if err != nil {
 		return
 	}
-	fe := asFsEntry(p)
 	fs.l.Debug("reading file", zap.String("file", fe.fullPath), zap.Uint64("inode", uint64(fe.iNode)))
 
 	// now consumes the file from the bundle
-	n, err = fs.readAtBundle(fe, op.Dst, op.Offset)
+	n, err = fs.readAtBundle(tr.ctx, fe, op.Dst, op.Offset)
 	op.BytesRead = n
 	return err
 }
@@ -214,8 +211,8 @@ func (fs *readOnlyFsInternal) ReadFile(
 func (fs *readOnlyFsInternal) ReleaseFileHandle(
 	ctx context.Context,
 	op *fuseops.ReleaseFileHandleOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 	return
 }
 
@@ -226,6 +223,122 @@ func (fs *readOnlyFsInternal) FlushFile(
 	return
 }
 
+// getFsEntry retrieves the node for an iNode
+func (fs *readOnlyFsInternal) getFsEntry(iNode fuseops.InodeID) (*FsEntry, error) {
+	if fs.lazy != nil {
+		fe, err := fs.lazy.node(iNode)
+		return fe, fs.lazyError(err)
+	}
+
+	p, found := fs.fsEntryStore.Get(formKey(iNode))
+	if !found {
+		return nil, jfuse.ENOENT
+	}
+	return asFsEntry(p), nil
+}
+
+// totals yields the number of files and the total logical size of the bundle.
+//
+// Lazily populated FS of older bundles, which do not record their totals, only report about the index loaded so far.
+func (fs *readOnlyFsInternal) totals() (uint64, uint64) {
+	if fs.lazy != nil {
+		return fs.lazy.totals()
+	}
+	return fs.files, fs.size
+}
+
+// lookup retrieves the child of a directory by name
+func (fs *readOnlyFsInternal) lookup(parent fuseops.InodeID, name string) (*FsEntry, error) {
+	if fs.lazy != nil {
+		fe, err := fs.lazy.lookup(parent, name)
+		return fe, fs.lazyError(err)
+	}
+
+	val, found := fs.lookupTree.Get(formLookupKey(parent, name))
+	if !found {
+		return nil, jfuse.ENOENT
+	}
+	return asFsEntry(val), nil
+}
+
+// readDir iterates over the children of a directory, starting at some offset, until apply returns false
+func (fs *readOnlyFsInternal) readDir(iNode fuseops.InodeID, offset int, apply func(fuseutil.Dirent) bool) error {
+	if fs.lazy != nil {
+		return fs.lazyError(fs.lazy.children(iNode, offset, apply))
+	}
+
+	children, found := fs.readDirMap[iNode]
+	if !found {
+		return jfuse.ENOENT
+	}
+	if offset > len(children) {
+		return jfuse.ENOENT
+	}
+
+	for i := offset; i < len(children); i++ {
+		if !apply(children[i]) {
+			break
+		}
+	}
+	return nil
+}
+
+// lazyError converts errors from the lazy index into fuse errors
+func (fs *readOnlyFsInternal) lazyError(err error) error {
+	if err == nil || err == jfuse.ENOENT {
+		return err
+	}
+	fs.l.Error("lazy index error", zap.Error(err))
+	return jfuse.EIO
+}
+
+// populateLazyFS initializes a file system which is populated on demand, as directories are looked up or listed.
+//
+// Only the bundle descriptor is retrieved at this stage.
+func (fs *readOnlyFsInternal) populateLazyFS(bundle *core.Bundle) (*ReadOnlyFS, error) {
+	chunks := bundle.BundleDescriptor.BundleEntriesFileCount
+	fetch := func(chunk uint64) ([]model.BundleEntry, error) {
+		return core.DownloadIndexChunk(context.Background(), bundle, chunk)
+	}
+
+	entries := bundle.GetBundleEntries()
+	if len(entries) > 0 {
+		// entries are already known (e.g. diamond preview): they are loaded as one single chunk
+		chunks = 1
+		fetch = func(uint64) ([]model.BundleEntry, error) {
+			return entries, nil
+		}
+	}
+
+	totals := bundleTotals(bundle.BundleDescriptor, entries)
+	lazy, err := newLazyIndex(fs.indexDir, chunks, fetch, totals, bundle.BundleDescriptor.Timestamp, fs.l)
+	if err != nil {
+		fs.l.Error("failed to create lazy index", zap.String("index", fs.indexDir), zap.Error(err))
+		return nil, err
+	}
+	fs.lazy = lazy
+	fs.isReadOnly = true
+
+	fs.l.Info("lazily populated fs", zap.Uint64("index chunks", chunks), zap.String("index", lazy.dir))
+
+	return &ReadOnlyFS{
+		fsInternal: fs,
+		server:     fuseutil.NewFileSystemServer(fs),
+	}, nil
+}
+
+// releaseResources frees the resources held by the file system, once it is no longer served
+func (fs *readOnlyFsInternal) releaseResources() {
+	fs.release.Do(func() {
+		if fs.lazy == nil {
+			return
+		}
+		if err := fs.lazy.close(); err != nil {
+			fs.l.Warn("failed to close lazy index", zap.Error(err))
+		}
+	})
+}
+
 type fsNodeToAdd struct {
 	parentINode fuseops.InodeID
 	FsEntry     FsEntry
@@ -468,6 +581,10 @@ func (fs *readOnlyFsInternal) populateFS(bundle *core.Bundle) (*ReadOnlyFS, erro
 
 	txns.commitToFS(fs)
 
+	for _, entry := range bundle.BundleEntries {
+		fs.files++
+		fs.size += entry.Size
+	}
 	fs.isReadOnly = true
 
 	// free this resource: it used only during FS setup
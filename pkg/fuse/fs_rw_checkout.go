@@ -0,0 +1,194 @@
+package fuse
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"go.uber.org/zap"
+
+	"github.com/oneconcern/datamon/pkg/cafs"
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/oneconcern/datamon/pkg/filetracker"
+	"github.com/oneconcern/datamon/pkg/fuse/status"
+	"github.com/oneconcern/datamon/pkg/model"
+)
+
+// checkout populates a mutable FS with the tree of its source bundle.
+//
+// Only the bundle metadata is retrieved at this stage: the content of files is fetched from cafs
+// upon read, and local copies are only kept for the ranges which are written.
+func (fs *fsMutable) checkout() error {
+	source := fs.source
+	logger := fs.l.With(zap.String("source", source.BundleID))
+
+	if err := core.DownloadMetadata(context.Background(), source); err != nil {
+		logger.Error("failed to download source bundle metadata", zap.Error(err))
+		return err
+	}
+
+	if source.BundleDescriptor.Version != fs.bundle.BundleDescriptor.Version {
+		return status.ErrSourceVersion.WrapMessage("source bundle %s has version %d, expected %d",
+			source.BundleID, source.BundleDescriptor.Version, fs.bundle.BundleDescriptor.Version)
+	}
+
+	caFs, err := cafs.New(
+		cafs.LeafSize(source.BundleDescriptor.LeafSize),
+		cafs.LeafTruncation(source.BundleDescriptor.Version < 1),
+		cafs.Backend(source.BlobStore()),
+		cafs.Logger(fs.l),
+		cafs.VerifyHash(fs.withVerifyHash),
+		cafs.DiskCache(fs.leafCache),
+		cafs.WithMetrics(fs.MetricsEnabled()),
+	)
+	if err != nil {
+		return err
+	}
+	fs.cafs = caFs
+
+	// unchanged files retain their hash in the new bundle, which thus inherits the leaf size of its parent
+	fs.bundle.BundleDescriptor.LeafSize = source.BundleDescriptor.LeafSize
+	fs.bundle.BundleDescriptor.Parents = []string{source.BundleID}
+
+	fs.lock.Lock()
+	defer fs.lock.Unlock()
+
+	entries := source.GetBundleEntries()
+	for i := range entries {
+		if err := fs.checkoutEntry(entries[i], source.BundleDescriptor.Timestamp); err != nil {
+			logger.Error("failed to check out source bundle entry", zap.String("file", entries[i].NameWithPath), zap.Error(err))
+			return err
+		}
+	}
+
+	logger.Info("checked out source bundle", zap.Int("files", len(entries)))
+	return nil
+}
+
+// checkoutEntry adds a file from the source bundle, with all its missing parent directories. Need to hold the locks before calling.
+func (fs *fsMutable) checkoutEntry(entry model.BundleEntry, ts time.Time) error {
+	parts := strings.Split(strings.Trim(entry.NameWithPath, "/"), "/")
+	var parentINode fuseops.InodeID = fuseops.RootInodeID
+
+	for _, dir := range parts[:len(parts)-1] {
+		le, found, lk := fs.lookup(parentINode, dir)
+		if found {
+			parentINode = le.iNode
+			continue
+		}
+
+		var child fuseops.ChildInodeEntry
+		if err := fs.createNode(lk, parentINode, dir, &child, fuseutil.DT_Directory, false); err != nil {
+			return err
+		}
+		parentINode = child.Child
+	}
+
+	name := parts[len(parts)-1]
+	if _, found, _ := fs.lookup(parentINode, name); found {
+		return status.ErrSourceDuplicate.WrapMessage("%s", entry.NameWithPath)
+	}
+
+	if !entry.Timestamp.IsZero() {
+		ts = entry.Timestamp
+	}
+
+	iNodeID := fs.iNodeGenerator.allocINode()
+	fs.insertLookupEntry(parentINode, name, lookupEntry{iNode: iNodeID, mode: fileDefaultMode})
+	fs.insertReadDirEntry(parentINode, &fuseutil.Dirent{
+		Inode: iNodeID,
+		Name:  name,
+		Type:  fuseutil.DT_File,
+	})
+
+	base := entry
+	fs.iNodeStore, _, _ = fs.iNodeStore.Insert(formKey(iNodeID), &nodeEntry{
+		lock:              sync.Mutex{},
+		pathToBackingFile: getPathToBackingFile(iNodeID),
+		attr: fuseops.InodeAttributes{
+			Size:   entry.Size,
+			Nlink:  fileLinkCount,
+			Mode:   fileDefaultMode,
+			Atime:  ts,
+			Mtime:  ts,
+			Ctime:  ts,
+			Crtime: ts,
+			Uid:    defaultGID,
+			Gid:    defaultUID,
+		},
+		// the backing file is only created upon the first write
+		tracker: filetracker.NewTFile(fs.sourceReader(entry.Hash), int64(entry.Size), fs.localCache, getPathToBackingFile(iNodeID)),
+		base:    &base,
+	})
+	return nil
+}
+
+// sourceReader resolves lazily the content of a file from the source bundle
+func (fs *fsMutable) sourceReader(hash string) func() (io.ReaderAt, error) {
+	return func() (io.ReaderAt, error) {
+		key, err := cafs.KeyFromString(hash)
+		if err != nil {
+			return nil, err
+		}
+		return fs.cafs.GetAt(context.Background(), key)
+	}
+}
+
+// trackedNode yields the node of a file checked out from the source bundle, or nil
+func (fs *fsMutable) trackedNode(iNode fuseops.InodeID) *nodeEntry {
+	nodeStore, _ := fs.atomicGetReferences()
+	e, found := nodeStore.Get(formKey(iNode))
+	if !found {
+		return nil
+	}
+
+	n := e.(*nodeEntry)
+	if n.tracker == nil {
+		return nil
+	}
+	return n
+}
+
+// commitTrackedFile uploads a file checked out from the source bundle, only if it has been changed
+func commitTrackedFile(
+	ctx context.Context,
+	fs *fsMutable,
+	chans commitChans,
+	caFs cafs.Fs,
+	node *nodeEntry,
+	uploadTask commitUploadTask) {
+	var be model.BundleEntry
+
+	if !node.tracker.Modified() {
+		// unchanged files refer to the blobs of the source bundle
+		be = *node.base
+		be.NameWithPath = uploadTask.name
+	} else {
+		putRes, err := caFs.Put(ctx, io.NewSectionReader(node.tracker, 0, node.tracker.Size()))
+		if err != nil {
+			select {
+			case chans.error <- err:
+				fs.l.Error("Commit: cafs Put() error on changed file upload",
+					zap.Error(err),
+					zap.String("filename", uploadTask.name))
+			case <-chans.done:
+			}
+			return
+		}
+		be = model.BundleEntry{
+			Hash:         putRes.Key.String(),
+			NameWithPath: uploadTask.name,
+			FileMode:     0, // #TODO: #35 file mode support
+			Size:         uint64(putRes.Written),
+		}
+	}
+
+	select {
+	case chans.bundleEntry <- be:
+	case <-chans.done:
+	}
+}
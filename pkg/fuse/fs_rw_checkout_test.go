@@ -0,0 +1,164 @@
+package fuse
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/oneconcern/datamon/pkg/core/mocks"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+)
+
+func TestMutableFSFromBundle(t *testing.T) {
+	const repo = "checkout-test-repo"
+	tmp, err := ioutil.TempDir("", "test-checkout-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	dir := func(parts ...string) string {
+		pth := filepath.Join(append([]string{tmp}, parts...)...)
+		require.NoError(t, os.MkdirAll(pth, 0700))
+		return pth
+	}
+	localStore := func(pth string) storage.Store {
+		return localfs.New(afero.NewBasePathFs(afero.NewOsFs(), pth))
+	}
+	stores := mocks.FakeContext2(dir("meta"), dir("vmeta"), dir("blob"))
+	require.NoError(t, core.CreateRepo(model.RepoDescriptor{Name: repo, Description: "test"}, stores))
+
+	// source bundle
+	original := dir("original")
+	files := map[string]string{
+		"a.txt":     "unchanged content",
+		"dir/b.txt": "content to be changed",
+		"dir/c.txt": "content to be removed",
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(original, name)), 0700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(original, name), []byte(content), 0600))
+	}
+
+	source := core.NewBundle(
+		core.Repo(repo),
+		core.BundleDescriptor(model.NewBundleDescriptor(model.Message("source bundle"))),
+		core.ConsumableStore(localStore(original)),
+		core.ContextStores(stores),
+		core.Logger(mocks.TestLogger()),
+	)
+	require.NoError(t, core.Upload(context.Background(), source))
+
+	// mutable FS on top of the source bundle
+	child := core.NewBundle(
+		core.Repo(repo),
+		core.BundleDescriptor(model.NewBundleDescriptor(model.Message("child bundle"))),
+		core.ConsumableStore(localStore(dir("staging"))),
+		core.ContextStores(stores),
+		core.Logger(mocks.TestLogger()),
+	)
+	checkedOut := core.NewBundle(core.Repo(repo), core.BundleID(source.BundleID), core.ContextStores(stores))
+	mfs, err := NewMutableFS(child,
+		SourceBundle(checkedOut),
+		Logger(mocks.TestLogger()),
+	)
+	require.NoError(t, err)
+	fs := mfs.fsInternal
+	ctx := context.Background()
+
+	lookUp := func(t testing.TB, parent fuseops.InodeID, name string) fuseops.ChildInodeEntry {
+		op := &fuseops.LookUpInodeOp{Parent: parent, Name: name}
+		require.NoError(t, fs.LookUpInode(ctx, op))
+		return op.Entry
+	}
+	read := func(t testing.TB, iNode fuseops.InodeID, offset int64, size int) string {
+		op := &fuseops.ReadFileOp{Inode: iNode, Offset: offset, Dst: make([]byte, size)}
+		require.NoError(t, fs.ReadFile(ctx, op))
+		return string(op.Dst[:op.BytesRead])
+	}
+
+	a := lookUp(t, fuseops.RootInodeID, "a.txt")
+	d := lookUp(t, fuseops.RootInodeID, "dir")
+	b := lookUp(t, d.Child, "b.txt")
+
+	t.Run("should read files from the source bundle", func(t *testing.T) {
+		assert.Equal(t, uint64(len(files["a.txt"])), a.Attributes.Size)
+		assert.True(t, d.Attributes.Mode.IsDir())
+		assert.Equal(t, files["a.txt"], read(t, a.Child, 0, 100))
+		assert.Equal(t, "changed", read(t, b.Child, 14, 7))
+	})
+
+	t.Run("should report the capacity of the staging area", func(t *testing.T) {
+		op := &fuseops.StatFSOp{}
+		require.NoError(t, fs.StatFS(ctx, op))
+		assert.Equal(t, child.BundleDescriptor.LeafSize, op.BlockSize)
+		assert.NotZero(t, op.Blocks)
+		assert.LessOrEqual(t, op.BlocksFree, op.Blocks)
+		assert.LessOrEqual(t, op.BlocksAvailable, op.BlocksFree)
+	})
+
+	t.Run("should track writes on files from the source bundle", func(t *testing.T) {
+		require.NoError(t, fs.WriteFile(ctx, &fuseops.WriteFileOp{Inode: b.Child, Offset: 14, Data: []byte("CHANGED, then extended")}))
+		assert.Equal(t, "content to be CHANGED, then extended", read(t, b.Child, 0, 100))
+
+		attrs := &fuseops.GetInodeAttributesOp{Inode: b.Child}
+		require.NoError(t, fs.GetInodeAttributes(ctx, attrs))
+		assert.Equal(t, uint64(36), attrs.Attributes.Size)
+	})
+
+	require.NoError(t, fs.Unlink(ctx, &fuseops.UnlinkOp{Parent: d.Child, Name: "c.txt"}))
+	created := &fuseops.CreateFileOp{Parent: d.Child, Name: "d.txt"}
+	require.NoError(t, fs.CreateFile(ctx, created))
+	require.NoError(t, fs.WriteFile(ctx, &fuseops.WriteFileOp{Inode: created.Entry.Child, Data: []byte("new content")}))
+
+	require.NoError(t, mfs.Commit())
+
+	t.Run("should commit a child bundle", func(t *testing.T) {
+		require.NotEmpty(t, child.BundleID)
+		assert.Equal(t, []string{source.BundleID}, child.BundleDescriptor.Parents)
+
+		committed := core.NewBundle(core.Repo(repo), core.BundleID(child.BundleID), core.ContextStores(stores))
+		require.NoError(t, core.DownloadMetadata(ctx, committed))
+		assert.Equal(t, []string{source.BundleID}, committed.BundleDescriptor.Parents)
+
+		hashes := make(map[string]string)
+		for _, entry := range checkedOut.GetBundleEntries() {
+			hashes[strings.TrimPrefix(entry.NameWithPath, "/")] = entry.Hash
+		}
+
+		committedHashes := make(map[string]string)
+		for _, entry := range committed.GetBundleEntries() {
+			committedHashes[strings.TrimPrefix(entry.NameWithPath, "/")] = entry.Hash
+		}
+		require.Len(t, committedHashes, 3)
+		assert.Equal(t, hashes["a.txt"], committedHashes["a.txt"])
+		assert.NotEqual(t, hashes["dir/b.txt"], committedHashes["dir/b.txt"])
+		assert.NotContains(t, committedHashes, "dir/c.txt")
+		assert.Contains(t, committedHashes, "dir/d.txt")
+
+		destination := dir("destination")
+		committed.ConsumableStore = localStore(destination)
+		require.NoError(t, core.Publish(ctx, committed))
+
+		for name, expected := range map[string]string{
+			"a.txt":     "unchanged content",
+			"dir/b.txt": "content to be CHANGED, then extended",
+			"dir/d.txt": "new content",
+		} {
+			content, err := ioutil.ReadFile(filepath.Join(destination, name))
+			require.NoError(t, err)
+			assert.Equal(t, expected, string(content))
+		}
+	})
+}
@@ -3,9 +3,11 @@ package fuse
 import (
 	"context"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"sync"
+	"syscall"
 	"time"
 
 	"go.uber.org/zap"
@@ -46,7 +48,14 @@ type fsMutable struct {
 	iNodeGenerator iNodeGenerator
 
 	// local fs cache that mirrors the files.
-	localCache afero.Fs
+	localCache    afero.Fs
+	pathToStaging string
+
+	// source bundle the FS starts from, if any: source files are read lazily from cafs
+	source         *core.Bundle
+	cafs           cafs.Fs
+	withVerifyHash bool
+	leafCache      *cafs.LeafCache
 }
 
 func defaultMutableFS(bundle *core.Bundle, pathToStaging string) *fsMutable {
@@ -65,7 +74,8 @@ func defaultMutableFS(bundle *core.Bundle, pathToStaging string) *fsMutable {
 			highestInode: firstINode,
 			freeInodes:   make([]fuseops.InodeID, 0, 65536),
 		},
-		localCache: afero.NewBasePathFs(afero.NewOsFs(), pathToStaging),
+		localCache:    afero.NewBasePathFs(afero.NewOsFs(), pathToStaging),
+		pathToStaging: pathToStaging,
 	}
 }
 
@@ -134,9 +144,27 @@ func (fs *fsMutable) deleteNSEntry(p fuseops.InodeID, c string) error {
 	return nil
 }
 
+// StatFS reports the capacity of the staging area, where written files are stored
+func (fs *fsMutable) StatFS(ctx context.Context, op *fuseops.StatFSOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	var st syscall.Statfs_t
+	if e := syscall.Statfs(fs.pathToStaging, &st); e != nil {
+		fs.l.Error("failed to stat staging area", zap.String("path", fs.pathToStaging), zap.Error(e))
+		err = jfuse.EIO
+		return
+	}
+
+	bsize := uint64(st.Bsize)
+	statFS(op, fs.blockSize(), uint64(st.Blocks)*bsize, uint64(st.Bfree)*bsize, uint64(st.Files), uint64(st.Ffree))
+	op.BlocksAvailable = uint64(st.Bavail) * bsize / uint64(op.BlockSize)
+	return
+}
+
 func (fs *fsMutable) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
 	nodeStore, lookupTree := fs.atomicGetReferences()
 
@@ -163,8 +191,8 @@ func (fs *fsMutable) LookUpInode(ctx context.Context, op *fuseops.LookUpInodeOp)
 }
 
 func (fs *fsMutable) GetInodeAttributes(ctx context.Context, op *fuseops.GetInodeAttributesOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
 	nodeStore, _ := fs.atomicGetReferences()
 
@@ -185,8 +213,8 @@ func (fs *fsMutable) GetInodeAttributes(ctx context.Context, op *fuseops.GetInod
 }
 
 func (fs *fsMutable) SetInodeAttributes(ctx context.Context, op *fuseops.SetInodeAttributesOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
 	if op.Mode != nil { // File permissions not supported
 		fs.l.Debug("setting permissions mode is not supported", zap.Uint32("mode", uint32(*op.Mode)))
@@ -210,19 +238,28 @@ func (fs *fsMutable) SetInodeAttributes(ctx context.Context, op *fuseops.SetInod
 
 	// Set the values
 	if op.Size != nil {
-		// File size can be truncated.
-		file, err := fs.localCache.OpenFile(fmt.Sprint(op.Inode), os.O_WRONLY|os.O_SYNC, fileDefaultMode)
-		if err != nil {
-			return jfuse.EIO
-		}
 		if *op.Size > math.MaxInt64 {
 			fs.l.Error("Received size greater than MaxInt64", zap.Uint64("size", *op.Size), zap.Uint64("inode", uint64(op.Inode)))
 			return jfuse.EINVAL
 		}
-		err = file.Truncate(int64(*op.Size))
-		if err != nil {
-			fs.l.Error("truncate error", zap.Error(err))
-			return jfuse.EIO
+
+		if n.tracker != nil {
+			// File checked out from the source bundle
+			if err := n.tracker.Truncate(int64(*op.Size)); err != nil {
+				fs.l.Error("truncate error", zap.Error(err))
+				return jfuse.EIO
+			}
+		} else {
+			// File size can be truncated.
+			file, err := fs.localCache.OpenFile(fmt.Sprint(op.Inode), os.O_WRONLY|os.O_SYNC, fileDefaultMode)
+			if err != nil {
+				return jfuse.EIO
+			}
+			err = file.Truncate(int64(*op.Size))
+			if err != nil {
+				fs.l.Error("truncate error", zap.Error(err))
+				return jfuse.EIO
+			}
 		}
 		n.attr.Size = *op.Size
 	}
@@ -245,8 +282,8 @@ func (fs *fsMutable) SetInodeAttributes(ctx context.Context, op *fuseops.SetInod
 func (fs *fsMutable) ForgetInode(
 	ctx context.Context,
 	op *fuseops.ForgetInodeOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
 	// Check reference count for iNode and remove from iNodeStore
 	// Get the node.
@@ -340,8 +377,8 @@ func (fs *fsMutable) CreateFile(
 // If newpath exists but the operation fails for some reason, rename() guarantees to leave an instance of newpath in place.
 // oldpath can specify a directory.  In this case, newpath must either not exist, or it must specify an empty directory.
 func (fs *fsMutable) Rename(ctx context.Context, op *fuseops.RenameOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
 	fs.lock.Lock()
 	defer fs.lock.Unlock()
@@ -384,8 +421,8 @@ func (fs *fsMutable) Rename(ctx context.Context, op *fuseops.RenameOp) (err erro
 func (fs *fsMutable) RmDir(
 	ctx context.Context,
 	op *fuseops.RmDirOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
 	fs.lock.Lock()
 	defer fs.lock.Unlock()
@@ -396,8 +433,8 @@ func (fs *fsMutable) RmDir(
 func (fs *fsMutable) Unlink(
 	ctx context.Context,
 	op *fuseops.UnlinkOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
 	fs.lock.Lock()
 	defer fs.lock.Unlock()
@@ -409,8 +446,8 @@ func (fs *fsMutable) Unlink(
 func (fs *fsMutable) OpenDir(
 	ctx context.Context,
 	op *fuseops.OpenDirOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
 	return
 }
@@ -418,8 +455,8 @@ func (fs *fsMutable) OpenDir(
 func (fs *fsMutable) ReadDir(
 	ctx context.Context,
 	op *fuseops.ReadDirOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
 	offset := int(op.Offset)
 	iNode := op.Inode
@@ -457,32 +494,42 @@ func (fs *fsMutable) ReadDir(
 func (fs *fsMutable) ReleaseDirHandle(
 	ctx context.Context,
 	op *fuseops.ReleaseDirHandleOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 	return
 }
 
 func (fs *fsMutable) OpenFile(
 	ctx context.Context,
 	op *fuseops.OpenFileOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 	return
 }
 
 func (fs *fsMutable) ReadFile(
 	ctx context.Context,
 	op *fuseops.ReadFileOp) (err error) {
-	t0 := fs.opStart(op)
+	tr := fs.opStart(ctx, op)
 	defer func() {
-		fs.opEnd(t0, op, err)
+		fs.opEnd(tr, op, err)
 		if fs.MetricsEnabled() {
 			fs.m.Volume.Files.Inc("read")
 			fs.m.Volume.Files.Size(int64(op.BytesRead), "read")
-			fs.m.Volume.IO.IORecord(t0, "read")(int64(op.BytesRead), err)
+			fs.m.Volume.IO.IORecord(tr.start, "read")(int64(op.BytesRead), err)
 		}
 	}()
 
+	if node := fs.trackedNode(op.Inode); node != nil {
+		// File checked out from the source bundle
+		op.BytesRead, err = node.tracker.ReadAt(op.Dst, op.Offset)
+		if err != nil && err != io.EOF {
+			fs.l.Error("read error", zap.Uint64("inode", uint64(op.Inode)), zap.Error(err))
+			return jfuse.EIO
+		}
+		return nil
+	}
+
 	file, err := fs.localCache.OpenFile(getPathToBackingFile(op.Inode), os.O_RDONLY|os.O_SYNC, fileDefaultMode)
 	if err != nil {
 		return jfuse.EIO
@@ -502,16 +549,29 @@ func (fs *fsMutable) WriteFile(
 	ctx context.Context,
 	op *fuseops.WriteFileOp) (err error) {
 	var n int
-	t0 := fs.opStart(op)
+	tr := fs.opStart(ctx, op)
 	defer func() {
-		fs.opEnd(t0, op, err)
+		fs.opEnd(tr, op, err)
 		if fs.MetricsEnabled() {
 			fs.m.Volume.Files.Inc("write")
 			fs.m.Volume.Files.Size(int64(n), "write")
-			fs.m.Volume.IO.IORecord(t0, "write")(int64(n), err)
+			fs.m.Volume.IO.IORecord(tr.start, "write")(int64(n), err)
 		}
 	}()
 
+	if node := fs.trackedNode(op.Inode); node != nil {
+		// File checked out from the source bundle: the written range is tracked
+		n, err = node.tracker.WriteAt(op.Data, op.Offset)
+		if err != nil {
+			fs.l.Error("write error", zap.Uint64("inode", uint64(op.Inode)), zap.Error(err))
+			return jfuse.EIO
+		}
+		node.lock.Lock()
+		node.attr.Size = uint64(node.tracker.Size())
+		node.lock.Unlock()
+		return nil
+	}
+
 	file, err := fs.localCache.OpenFile(getPathToBackingFile(op.Inode), os.O_WRONLY|os.O_SYNC, fileDefaultMode)
 	if err != nil {
 		return jfuse.EIO
@@ -540,14 +600,22 @@ func (fs *fsMutable) WriteFile(
 func (fs *fsMutable) SyncFile(
 	ctx context.Context,
 	op *fuseops.SyncFileOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	if node := fs.trackedNode(op.Inode); node != nil {
+		if err := node.tracker.Sync(); err != nil {
+			return jfuse.EIO
+		}
+		return
+	}
 
 	fs.lockBackingFiles.RLock()
 	defer fs.lockBackingFiles.RUnlock()
 
-	file := *fs.backingFiles[op.Inode]
-	if file != nil {
+	f := fs.backingFiles[op.Inode]
+	if f != nil {
+		file := *f
 		err := file.Sync()
 		if err != nil {
 			return jfuse.EIO
@@ -559,8 +627,15 @@ func (fs *fsMutable) SyncFile(
 func (fs *fsMutable) FlushFile(
 	ctx context.Context,
 	op *fuseops.FlushFileOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	if node := fs.trackedNode(op.Inode); node != nil {
+		if err := node.tracker.Sync(); err != nil {
+			return jfuse.EIO
+		}
+		return
+	}
 
 	fs.lockBackingFiles.RLock()
 	defer fs.lockBackingFiles.RUnlock()
@@ -579,8 +654,8 @@ func (fs *fsMutable) FlushFile(
 func (fs *fsMutable) ReleaseFileHandle(
 	ctx context.Context,
 	op *fuseops.ReleaseFileHandleOp) (err error) {
-	t0 := fs.opStart(op)
-	defer fs.opEnd(t0, op, err)
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
 
 	return
 }
@@ -771,6 +846,10 @@ func commitFileUpload(
 	caFs cafs.Fs,
 	uploadTask commitUploadTask) {
 	defer bundleUploadWaitGroup.Done()
+	if node := fs.trackedNode(uploadTask.inodeID); node != nil {
+		commitTrackedFile(ctx, fs, chans, caFs, node, uploadTask)
+		return
+	}
 	file, err := fs.localCache.OpenFile(getPathToBackingFile(uploadTask.inodeID),
 		os.O_RDONLY|os.O_SYNC, fileDefaultMode)
 	if err != nil {
@@ -0,0 +1,179 @@
+package fuse
+
+import (
+	"context"
+	"strconv"
+	"syscall"
+
+	jfuse "github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+)
+
+// Extended attributes exposed by read-only mounts, so tools may record the provenance of the files they read
+const (
+	// XattrHash is the cafs root hash of a file
+	XattrHash = "user.datamon.hash"
+	// XattrSize is the size in bytes of a file
+	XattrSize = "user.datamon.size"
+	// XattrBundle is the ID of the bundle a file belongs to
+	XattrBundle = "user.datamon.bundle"
+	// XattrRepo is the repo of the bundle a file belongs to
+	XattrRepo = "user.datamon.repo"
+	// XattrLabel is the label used to mount the bundle, if any
+	XattrLabel = "user.datamon.label"
+	// XattrDiamond is the diamond previewed by the bundle, if any
+	XattrDiamond = "user.datamon.diamond"
+)
+
+type xattr struct {
+	name  string
+	value string
+}
+
+func (fs *readOnlyFsInternal) GetXattr(
+	ctx context.Context,
+	op *fuseops.GetXattrOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	attrs, err := fs.xattrs(op.Inode)
+	if err != nil {
+		return
+	}
+	return getXattr(op, attrs)
+}
+
+func (fs *readOnlyFsInternal) ListXattr(
+	ctx context.Context,
+	op *fuseops.ListXattrOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	attrs, err := fs.xattrs(op.Inode)
+	if err != nil {
+		return
+	}
+	return listXattr(op, attrs)
+}
+
+// xattrs yields the extended attributes of a node.
+//
+// Files carry their hash and size, as well as the attributes of their bundle.
+// The root carries the attributes of the bundle. Other directories carry none.
+func (fs *readOnlyFsInternal) xattrs(iNode fuseops.InodeID) ([]xattr, error) {
+	fe, err := fs.getFsEntry(iNode)
+	if err != nil {
+		return nil, err
+	}
+	if fe.isDir() && iNode != fuseops.RootInodeID {
+		return nil, nil
+	}
+
+	attrs := fs.bundleXattrs()
+	if !fe.isDir() {
+		attrs = append(attrs,
+			xattr{name: XattrHash, value: fe.hash},
+			xattr{name: XattrSize, value: strconv.FormatUint(fe.attributes.Size, 10)},
+		)
+	}
+	return attrs, nil
+}
+
+func (fs *readOnlyFsInternal) bundleXattrs() []xattr {
+	attrs := make([]xattr, 0, 6)
+	attrs = append(attrs, xattr{name: XattrRepo, value: fs.bundle.RepoID})
+	if diamondID := fs.bundle.PreviewDiamondID(); diamondID != "" {
+		attrs = append(attrs, xattr{name: XattrDiamond, value: diamondID})
+	} else {
+		attrs = append(attrs, xattr{name: XattrBundle, value: fs.bundle.BundleID})
+	}
+	if fs.label != "" {
+		attrs = append(attrs, xattr{name: XattrLabel, value: fs.label})
+	}
+	return attrs
+}
+
+func (fs *repoFsInternal) GetXattr(
+	ctx context.Context,
+	op *fuseops.GetXattrOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	attrs, err := fs.xattrs(op.Inode)
+	if err != nil {
+		return
+	}
+	return getXattr(op, attrs)
+}
+
+func (fs *repoFsInternal) ListXattr(
+	ctx context.Context,
+	op *fuseops.ListXattrOp) (err error) {
+	tr := fs.opStart(ctx, op)
+	defer fs.opEnd(tr, op, err)
+
+	attrs, err := fs.xattrs(op.Inode)
+	if err != nil {
+		return
+	}
+	return listXattr(op, attrs)
+}
+
+// xattrs yields the extended attributes of a node: only bundle subtrees carry extended attributes.
+func (fs *repoFsInternal) xattrs(iNode fuseops.InodeID) ([]xattr, error) {
+	node, local, err := fs.resolve(iNode)
+	if err != nil {
+		return nil, err
+	}
+	if node.kind != repoNodeBundle {
+		return nil, nil
+	}
+	sub, _, err := fs.subtree(node)
+	if err != nil {
+		return nil, err
+	}
+	return sub.xattrs(local)
+}
+
+// getXattr copies the value of an extended attribute, following getxattr(2) semantics:
+// an empty destination buffer only queries the size of the value.
+func getXattr(op *fuseops.GetXattrOp, attrs []xattr) error {
+	for _, attr := range attrs {
+		if attr.name != op.Name {
+			continue
+		}
+		op.BytesRead = len(attr.value)
+		if len(op.Dst) == 0 {
+			return nil
+		}
+		if len(op.Dst) < len(attr.value) {
+			return syscall.ERANGE
+		}
+		copy(op.Dst, attr.value)
+		return nil
+	}
+	return jfuse.ENOATTR
+}
+
+// listXattr copies the NUL-terminated names of extended attributes, following listxattr(2) semantics:
+// an empty destination buffer only queries the size of the list.
+func listXattr(op *fuseops.ListXattrOp, attrs []xattr) error {
+	size := 0
+	for _, attr := range attrs {
+		size += len(attr.name) + 1
+	}
+	op.BytesRead = size
+	if len(op.Dst) == 0 {
+		return nil
+	}
+	if len(op.Dst) < size {
+		return syscall.ERANGE
+	}
+	offset := 0
+	for _, attr := range attrs {
+		offset += copy(op.Dst[offset:], attr.name)
+		op.Dst[offset] = 0
+		offset++
+	}
+	return nil
+}
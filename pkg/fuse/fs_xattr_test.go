@@ -0,0 +1,65 @@
+package fuse
+
+import (
+	"bytes"
+	"syscall"
+	"testing"
+
+	jfuse "github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXattr(t *testing.T) {
+	attrs := []xattr{
+		{name: XattrRepo, value: "repo"},
+		{name: XattrHash, value: "abcdef"},
+	}
+
+	t.Run("should get attribute values", func(t *testing.T) {
+		op := &fuseops.GetXattrOp{Name: XattrHash}
+		require.NoError(t, getXattr(op, attrs))
+		assert.Equal(t, len("abcdef"), op.BytesRead)
+
+		op = &fuseops.GetXattrOp{Name: XattrHash, Dst: make([]byte, 10)}
+		require.NoError(t, getXattr(op, attrs))
+		assert.Equal(t, "abcdef", string(op.Dst[:op.BytesRead]))
+
+		op = &fuseops.GetXattrOp{Name: XattrHash, Dst: make([]byte, 2)}
+		assert.Equal(t, syscall.ERANGE, getXattr(op, attrs))
+
+		op = &fuseops.GetXattrOp{Name: XattrLabel, Dst: make([]byte, 10)}
+		assert.Equal(t, jfuse.ENOATTR, getXattr(op, attrs))
+	})
+
+	t.Run("should list attribute names", func(t *testing.T) {
+		expected := XattrRepo + "\x00" + XattrHash + "\x00"
+
+		op := &fuseops.ListXattrOp{}
+		require.NoError(t, listXattr(op, attrs))
+		assert.Equal(t, len(expected), op.BytesRead)
+
+		op = &fuseops.ListXattrOp{Dst: make([]byte, 100)}
+		require.NoError(t, listXattr(op, attrs))
+		assert.Equal(t, expected, string(op.Dst[:op.BytesRead]))
+
+		op = &fuseops.ListXattrOp{Dst: make([]byte, 10)}
+		assert.Equal(t, syscall.ERANGE, listXattr(op, attrs))
+
+		op = &fuseops.ListXattrOp{Dst: make([]byte, 10)}
+		require.NoError(t, listXattr(op, nil))
+		assert.Zero(t, op.BytesRead)
+	})
+}
+
+// xattrNames decodes the NUL-terminated names returned by ListXattr
+func xattrNames(buf []byte) []string {
+	names := make([]string, 0, 6)
+	for _, name := range bytes.Split(buf, []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names
+}
@@ -5,6 +5,8 @@ import (
 	"sync"
 
 	"github.com/jacobsa/fuse/fuseops"
+	"github.com/oneconcern/datamon/pkg/filetracker"
+	"github.com/oneconcern/datamon/pkg/model"
 )
 
 type iNodeGenerator struct {
@@ -24,6 +26,10 @@ type nodeEntry struct {
 	refCount          int
 	attr              fuseops.InodeAttributes
 	pathToBackingFile string // empty for directory
+
+	// files checked out from a source bundle
+	tracker *filetracker.TFile // tracks writes on top of the source file
+	base    *model.BundleEntry // entry of the file in the source bundle
 }
 
 func (g *iNodeGenerator) allocINode() fuseops.InodeID {
@@ -11,4 +11,13 @@ var (
 
 	// ErrReadAt is an error while performing a ReadAt operation on a bundle
 	ErrReadAt = errors.New("error in bundle ReadAt")
+
+	// ErrSourceVersion indicates that a mutable FS cannot start from a bundle with a different metadata version
+	ErrSourceVersion = errors.New("cannot start a mutable mount from a bundle with a different metadata version")
+
+	// ErrSourceDuplicate indicates that a source bundle for a mutable FS contains duplicate file entries
+	ErrSourceDuplicate = errors.New("duplicate file entry in source bundle")
+
+	// ErrLazyNotStreamed indicates that a lazily populated FS requires streaming
+	ErrLazyNotStreamed = errors.New("a lazily populated read-only mount must be streamed")
 )
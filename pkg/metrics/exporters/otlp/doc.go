@@ -0,0 +1,5 @@
+// Package otlp exposes an opencensus exporter for OpenTelemetry collectors.
+//
+// Metrics are pushed to the collector with the OTLP/HTTP protocol, using the JSON encoding.
+// Opencensus views aggregate data since the process started: metrics are exported with a cumulative temporality.
+package otlp
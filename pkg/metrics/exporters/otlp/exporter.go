@@ -0,0 +1,242 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+const (
+	// DefaultEndpoint is the default OTLP/HTTP metrics endpoint of a local collector
+	DefaultEndpoint = "http://localhost:4318/v1/metrics"
+
+	metricsPath    = "/v1/metrics"
+	scopeName      = "github.com/oneconcern/datamon/pkg/metrics"
+	defaultTimeout = 10 * time.Second
+)
+
+var _ view.Exporter = &Exporter{}
+
+func defaultExporter() *Exporter {
+	return &Exporter{
+		endpoint:     DefaultEndpoint,
+		client:       http.DefaultClient,
+		timeout:      defaultTimeout,
+		errorHandler: func(_ error) {},
+		headers:      make(map[string]string),
+		attributes:   make(map[string]string),
+	}
+}
+
+// NewExporter creates a new OTLP exporter.
+//
+// Use options to configure:
+//   - the collector endpoint, credentials and extra headers
+//   - an error handler. If set to nil, a no-op handler is set by default
+//   - a map of resource attributes reported with all metrics (may be nil)
+func NewExporter(opts ...Option) (*Exporter, error) {
+	e := defaultExporter()
+	for _, apply := range opts {
+		apply(e)
+	}
+
+	u, err := url.Parse(e.endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: %w", e.endpoint, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: expected an http or https URL", e.endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = metricsPath
+	}
+	if u.User != nil && e.user == "" {
+		// credentials embedded in the URL
+		e.user = u.User.Username()
+		e.password, _ = u.User.Password()
+	}
+	u.User = nil
+	e.endpoint = u.String()
+
+	return e, nil
+}
+
+// Exporter is an opencensus exporter for OpenTelemetry collectors
+type Exporter struct {
+	endpoint     string
+	user         string
+	password     string
+	headers      map[string]string
+	client       *http.Client
+	timeout      time.Duration
+	errorHandler func(error)
+	attributes   map[string]string
+}
+
+// ExportView sends collected metrics to the collector
+func (e *Exporter) ExportView(viewData *view.Data) {
+	if viewData == nil || viewData.View == nil || len(viewData.Rows) == 0 {
+		return
+	}
+
+	m, err := toMetric(viewData)
+	if err != nil {
+		e.errorHandler(err)
+		return
+	}
+
+	request := exportRequest{
+		ResourceMetrics: []resourceMetrics{
+			{
+				Resource: resource{Attributes: toAttributes(e.attributes)},
+				ScopeMetrics: []scopeMetrics{
+					{
+						Scope:   scope{Name: scopeName},
+						Metrics: []metric{m},
+					},
+				},
+			},
+		},
+	}
+
+	if err := e.post(request); err != nil {
+		e.errorHandler(err)
+	}
+}
+
+func (e *Exporter) post(request exportRequest) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	if e.user != "" {
+		req.SetBasicAuth(e.user, e.password)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("OTLP collector at %s responded with status %s", e.endpoint, resp.Status)
+	}
+	return nil
+}
+
+func toMetric(viewData *view.Data) (metric, error) {
+	m := metric{
+		Name:        viewData.View.Name,
+		Description: viewData.View.Description,
+		Unit:        viewData.View.Measure.Unit(),
+	}
+	start, end := unixNano(viewData.Start), unixNano(viewData.End)
+
+	for _, row := range viewData.Rows {
+		attributes := toAttributes(convertTags(row.Tags))
+
+		switch d := row.Data.(type) {
+		case *view.CountData:
+			if m.Sum == nil {
+				m.Sum = &sum{AggregationTemporality: cumulativeTemporality, IsMonotonic: true}
+			}
+			value := strconv.FormatInt(d.Value, 10)
+			m.Sum.DataPoints = append(m.Sum.DataPoints, numberDataPoint{
+				Attributes: attributes, StartTimeUnixNano: start, TimeUnixNano: end, AsInt: &value,
+			})
+		case *view.SumData:
+			if m.Sum == nil {
+				m.Sum = &sum{AggregationTemporality: cumulativeTemporality}
+			}
+			value := d.Value
+			m.Sum.DataPoints = append(m.Sum.DataPoints, numberDataPoint{
+				Attributes: attributes, StartTimeUnixNano: start, TimeUnixNano: end, AsDouble: &value,
+			})
+		case *view.LastValueData:
+			if m.Gauge == nil {
+				m.Gauge = &gauge{}
+			}
+			value := d.Value
+			m.Gauge.DataPoints = append(m.Gauge.DataPoints, numberDataPoint{
+				Attributes: attributes, StartTimeUnixNano: start, TimeUnixNano: end, AsDouble: &value,
+			})
+		case *view.DistributionData:
+			if m.Histogram == nil {
+				m.Histogram = &histogram{AggregationTemporality: cumulativeTemporality}
+			}
+			counts := make([]string, 0, len(d.CountPerBucket))
+			for _, count := range d.CountPerBucket {
+				counts = append(counts, strconv.FormatInt(count, 10))
+			}
+			m.Histogram.DataPoints = append(m.Histogram.DataPoints, histogramDataPoint{
+				Attributes:        attributes,
+				StartTimeUnixNano: start,
+				TimeUnixNano:      end,
+				Count:             strconv.FormatInt(d.Count, 10),
+				Sum:               d.Sum(),
+				BucketCounts:      counts,
+				ExplicitBounds:    viewData.View.Aggregation.Buckets,
+				Min:               d.Min,
+				Max:               d.Max,
+			})
+		default:
+			return metric{}, fmt.Errorf("unknown AggregationData type: %T", row.Data)
+		}
+	}
+
+	return m, nil
+}
+
+func unixNano(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// toAttributes converts a map of strings to OTLP attributes, sorted by key
+func toAttributes(values map[string]string) []keyValue {
+	attributes := make([]keyValue, 0, len(values))
+	for k, v := range values {
+		attributes = append(attributes, keyValue{Key: k, Value: anyValue{StringValue: v}})
+	}
+	sort.Slice(attributes, func(i, j int) bool {
+		return attributes[i].Key < attributes[j].Key
+	})
+	return attributes
+}
+
+func convertTags(tags []tag.Tag) map[string]string {
+	res := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		res[tag.Key.Name()] = tag.Value
+	}
+	return res
+}
@@ -0,0 +1,85 @@
+package otlp
+
+// The OTLP metrics data model, encoded as JSON.
+//
+// See https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/metrics/v1/metrics.proto.
+// The JSON mapping of protobuf encodes 64 bits integers as strings.
+
+const cumulativeTemporality = 2 // AGGREGATION_TEMPORALITY_CUMULATIVE
+
+type (
+	exportRequest struct {
+		ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+	}
+
+	resourceMetrics struct {
+		Resource     resource       `json:"resource"`
+		ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+	}
+
+	resource struct {
+		Attributes []keyValue `json:"attributes,omitempty"`
+	}
+
+	scopeMetrics struct {
+		Scope   scope    `json:"scope"`
+		Metrics []metric `json:"metrics"`
+	}
+
+	scope struct {
+		Name string `json:"name"`
+	}
+
+	keyValue struct {
+		Key   string   `json:"key"`
+		Value anyValue `json:"value"`
+	}
+
+	anyValue struct {
+		StringValue string `json:"stringValue"`
+	}
+
+	metric struct {
+		Name        string     `json:"name"`
+		Description string     `json:"description,omitempty"`
+		Unit        string     `json:"unit,omitempty"`
+		Sum         *sum       `json:"sum,omitempty"`
+		Gauge       *gauge     `json:"gauge,omitempty"`
+		Histogram   *histogram `json:"histogram,omitempty"`
+	}
+
+	sum struct {
+		DataPoints             []numberDataPoint `json:"dataPoints"`
+		AggregationTemporality int               `json:"aggregationTemporality"`
+		IsMonotonic            bool              `json:"isMonotonic"`
+	}
+
+	gauge struct {
+		DataPoints []numberDataPoint `json:"dataPoints"`
+	}
+
+	histogram struct {
+		DataPoints             []histogramDataPoint `json:"dataPoints"`
+		AggregationTemporality int                  `json:"aggregationTemporality"`
+	}
+
+	numberDataPoint struct {
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		TimeUnixNano      string     `json:"timeUnixNano"`
+		AsInt             *string    `json:"asInt,omitempty"`
+		AsDouble          *float64   `json:"asDouble,omitempty"`
+	}
+
+	histogramDataPoint struct {
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		TimeUnixNano      string     `json:"timeUnixNano"`
+		Count             string     `json:"count"`
+		Sum               float64    `json:"sum"`
+		BucketCounts      []string   `json:"bucketCounts"`
+		ExplicitBounds    []float64  `json:"explicitBounds"`
+		Min               float64    `json:"min"`
+		Max               float64    `json:"max"`
+	}
+)
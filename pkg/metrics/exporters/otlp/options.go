@@ -0,0 +1,73 @@
+package otlp
+
+import (
+	"net/http"
+	"time"
+)
+
+// Option configures an exporter
+type Option func(*Exporter)
+
+// WithEndpoint sets the URL of the OTLP/HTTP metrics endpoint of the collector.
+//
+// The default is http://localhost:4318/v1/metrics. A URL without a path is completed with the default metrics path.
+func WithEndpoint(endpoint string) Option {
+	return func(e *Exporter) {
+		if endpoint != "" {
+			e.endpoint = endpoint
+		}
+	}
+}
+
+// WithBasicAuth sets credentials to authenticate with the collector
+func WithBasicAuth(user, password string) Option {
+	return func(e *Exporter) {
+		e.user = user
+		e.password = password
+	}
+}
+
+// WithHeaders sets or adds some headers to the requests posted to the collector
+func WithHeaders(headers map[string]string) Option {
+	return func(e *Exporter) {
+		for k, v := range headers {
+			e.headers[k] = v
+		}
+	}
+}
+
+// WithHTTPClient sets the http client used to post metrics
+func WithHTTPClient(client *http.Client) Option {
+	return func(e *Exporter) {
+		if client != nil {
+			e.client = client
+		}
+	}
+}
+
+// WithTimeout sets the timeout to post metrics. The default is 10s
+func WithTimeout(timeout time.Duration) Option {
+	return func(e *Exporter) {
+		if timeout > 0 {
+			e.timeout = timeout
+		}
+	}
+}
+
+// WithErrorHandler sets an error handler for this exporter
+func WithErrorHandler(h func(error)) Option {
+	return func(e *Exporter) {
+		if h != nil {
+			e.errorHandler = h
+		}
+	}
+}
+
+// WithAttributes sets or adds some attributes to the resource reported with all metrics
+func WithAttributes(attributes map[string]string) Option {
+	return func(e *Exporter) {
+		for k, v := range attributes {
+			e.attributes[k] = v
+		}
+	}
+}
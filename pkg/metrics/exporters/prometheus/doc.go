@@ -0,0 +1,5 @@
+// Package prometheus exposes an opencensus exporter for prometheus.
+//
+// Unlike other exporters, metrics are not pushed to a backend: the exporter keeps the latest
+// data collected by views, which is pulled by a prometheus server scraping the exporter's HTTP handler.
+package prometheus
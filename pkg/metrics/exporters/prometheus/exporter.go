@@ -0,0 +1,168 @@
+package prometheus
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	client "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opencensus.io/stats/view"
+)
+
+var (
+	_ view.Exporter    = &Exporter{}
+	_ client.Collector = &Exporter{}
+)
+
+func defaultExporter() *Exporter {
+	return &Exporter{
+		errorHandler: func(_ error) {},
+		constLabels:  make(client.Labels),
+		views:        make(map[string]*view.Data),
+	}
+}
+
+// NewExporter creates a new prometheus exporter.
+//
+// Use options to configure:
+//   - a namespace to prefix metric names
+//   - an error handler. If set to nil, a no-op handler is set by default
+//   - a map of constant labels for all exported metrics (may be nil)
+func NewExporter(opts ...Option) *Exporter {
+	e := defaultExporter()
+	for _, apply := range opts {
+		apply(e)
+	}
+	e.registry = client.NewRegistry()
+	e.registry.MustRegister(e)
+
+	return e
+}
+
+// Exporter is an opencensus exporter for prometheus.
+//
+// The exporter is also a prometheus collector, with its own registry.
+type Exporter struct {
+	namespace    string
+	errorHandler func(error)
+	constLabels  client.Labels
+	registry     *client.Registry
+
+	mx    sync.Mutex
+	views map[string]*view.Data
+}
+
+// ExportView retains the latest data collected for a view, until it is scraped
+func (e *Exporter) ExportView(viewData *view.Data) {
+	if viewData == nil || viewData.View == nil {
+		return
+	}
+
+	e.mx.Lock()
+	e.views[viewData.View.Name] = viewData
+	e.mx.Unlock()
+}
+
+// Handler serves the exported metrics to a prometheus server
+func (e *Exporter) Handler() http.Handler {
+	return promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{})
+}
+
+// Describe does not describe metrics in advance: the exporter is an unchecked collector,
+// which metrics are only known once views have been exported.
+func (e *Exporter) Describe(_ chan<- *client.Desc) {}
+
+// Collect the latest data of all exported views as prometheus metrics
+func (e *Exporter) Collect(ch chan<- client.Metric) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+
+	for _, viewData := range e.views {
+		keys := make([]string, 0, len(viewData.View.TagKeys))
+		for _, key := range viewData.View.TagKeys {
+			keys = append(keys, sanitize(key.Name()))
+		}
+		desc := client.NewDesc(
+			e.metricName(viewData.View.Name),
+			viewData.View.Description,
+			keys,
+			e.constLabels,
+		)
+
+		for _, row := range viewData.Rows {
+			metric, err := e.toMetric(desc, viewData.View, row)
+			if err != nil {
+				e.errorHandler(err)
+				continue
+			}
+			ch <- metric
+		}
+	}
+}
+
+func (e *Exporter) toMetric(desc *client.Desc, v *view.View, row *view.Row) (client.Metric, error) {
+	values := labelValues(v, row)
+
+	switch d := row.Data.(type) {
+	case *view.CountData:
+		return client.NewConstMetric(desc, client.CounterValue, float64(d.Value), values...)
+	case *view.SumData:
+		return client.NewConstMetric(desc, client.UntypedValue, d.Value, values...)
+	case *view.LastValueData:
+		return client.NewConstMetric(desc, client.GaugeValue, d.Value, values...)
+	case *view.DistributionData:
+		// prometheus buckets are cumulative
+		buckets := make(map[float64]uint64, len(v.Aggregation.Buckets))
+		var cumulated uint64
+		for i, bound := range v.Aggregation.Buckets {
+			if i < len(d.CountPerBucket) {
+				cumulated += uint64(d.CountPerBucket[i])
+			}
+			buckets[bound] = cumulated
+		}
+		return client.NewConstHistogram(desc, uint64(d.Count), d.Sum(), buckets, values...)
+	default:
+		return nil, fmt.Errorf("unknown AggregationData type: %T", row.Data)
+	}
+}
+
+// labelValues yields the values of the row's tags, in the order of the view's tag keys
+func labelValues(v *view.View, row *view.Row) []string {
+	values := make([]string, len(v.TagKeys))
+	for i, key := range v.TagKeys {
+		for _, tag := range row.Tags {
+			if tag.Key == key {
+				values[i] = tag.Value
+				break
+			}
+		}
+	}
+	return values
+}
+
+func (e *Exporter) metricName(name string) string {
+	name = sanitize(name)
+	if e.namespace != "" {
+		name = e.namespace + "_" + name
+	}
+	return name
+}
+
+// sanitize a metric or label name to the characters supported by prometheus
+func sanitize(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, strings.Trim(name, "/"))
+
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
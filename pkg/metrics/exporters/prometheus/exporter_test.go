@@ -0,0 +1,71 @@
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+func TestExporter(t *testing.T) {
+	key := tag.MustNewKey("command")
+	measure := stats.Int64("datamon/test/duration", "duration of commands", "ms")
+	views := []*view.View{
+		{Name: "datamon/test/count", Description: "number of commands", Measure: measure, Aggregation: view.Count(), TagKeys: []tag.Key{key}},
+		{Name: "datamon/test/last", Measure: measure, Aggregation: view.LastValue()},
+		{Name: "datamon/test/sum", Measure: measure, Aggregation: view.Sum()},
+		{Name: "datamon/test/duration", Measure: measure, Aggregation: view.Distribution(10, 100), TagKeys: []tag.Key{key}},
+	}
+
+	now := time.Now()
+	row := func(data view.AggregationData, tags ...tag.Tag) []*view.Row {
+		return []*view.Row{{Tags: tags, Data: data}}
+	}
+	upload := tag.Tag{Key: key, Value: "bundle upload"}
+
+	e := NewExporter(WithNamespace("test"), WithLabels(map[string]string{"service": "datamon"}))
+	e.ExportView(&view.Data{View: views[0], Start: now, End: now, Rows: row(&view.CountData{Value: 3}, upload)})
+	e.ExportView(&view.Data{View: views[1], Start: now, End: now, Rows: row(&view.LastValueData{Value: 42})})
+	e.ExportView(&view.Data{View: views[2], Start: now, End: now, Rows: row(&view.SumData{Value: 60})})
+	e.ExportView(&view.Data{View: views[3], Start: now, End: now, Rows: row(&view.DistributionData{
+		Count:          3,
+		Mean:           20,
+		CountPerBucket: []int64{1, 2, 0},
+	}, upload)})
+	// the latest data of a view is retained
+	e.ExportView(&view.Data{View: views[0], Start: now, End: now, Rows: row(&view.CountData{Value: 4}, upload)})
+
+	server := httptest.NewServer(e.Handler())
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL)
+	require.NoError(t, err)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	scraped := string(body)
+
+	assert.Contains(t, scraped, "# HELP test_datamon_test_count number of commands")
+	assert.Contains(t, scraped, "# TYPE test_datamon_test_count counter")
+	assert.Contains(t, scraped, `test_datamon_test_count{command="bundle upload",service="datamon"} 4`)
+	assert.Contains(t, scraped, `test_datamon_test_last{service="datamon"} 42`)
+	assert.Contains(t, scraped, `test_datamon_test_sum{service="datamon"} 60`)
+	assert.Contains(t, scraped, "# TYPE test_datamon_test_duration histogram")
+	assert.Contains(t, scraped, `test_datamon_test_duration_bucket{command="bundle upload",service="datamon",le="10"} 1`)
+	assert.Contains(t, scraped, `test_datamon_test_duration_bucket{command="bundle upload",service="datamon",le="100"} 3`)
+	assert.Contains(t, scraped, `test_datamon_test_duration_bucket{command="bundle upload",service="datamon",le="+Inf"} 3`)
+	assert.Contains(t, scraped, `test_datamon_test_duration_sum{command="bundle upload",service="datamon"} 60`)
+}
+
+func TestSanitize(t *testing.T) {
+	assert.Equal(t, "datamon_cmd_telemetry_usage", sanitize("/datamon/cmd/telemetry/usage"))
+	assert.Equal(t, "_2xx_count", sanitize("2xx-count"))
+}
@@ -0,0 +1,29 @@
+package prometheus
+
+// Option configures an exporter
+type Option func(*Exporter)
+
+// WithNamespace sets a prefix to all exported metric names
+func WithNamespace(namespace string) Option {
+	return func(e *Exporter) {
+		e.namespace = sanitize(namespace)
+	}
+}
+
+// WithErrorHandler sets an error handler for this exporter
+func WithErrorHandler(h func(error)) Option {
+	return func(e *Exporter) {
+		if h != nil {
+			e.errorHandler = h
+		}
+	}
+}
+
+// WithLabels sets or adds some constant labels to every exported metric
+func WithLabels(labels map[string]string) Option {
+	return func(e *Exporter) {
+		for k, v := range labels {
+			e.constLabels[sanitize(k)] = v
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package model
+
+import (
+	"fmt"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ACLRole is a role granted to a contributor by an access control list
+type ACLRole string
+
+const (
+	// ACLNone is granted to contributors who are not listed in an ACL
+	ACLNone ACLRole = ""
+	// ACLReader may read a repo
+	ACLReader ACLRole = "reader"
+	// ACLWriter may read a repo, upload bundles and set labels
+	ACLWriter ACLRole = "writer"
+	// ACLAdmin may perform any operation on a repo, including deletions and ACL updates
+	ACLAdmin ACLRole = "admin"
+
+	// ACLEveryone matches any contributor in an ACL
+	ACLEveryone = "*"
+)
+
+func (r ACLRole) rank() int {
+	switch r {
+	case ACLReader:
+		return 1
+	case ACLWriter:
+		return 2
+	case ACLAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// IsValid checks if a role is supported
+func (r ACLRole) IsValid() bool {
+	return r.rank() > 0
+}
+
+// Includes tells if this role grants at least the privileges of another role
+func (r ACLRole) Includes(other ACLRole) bool {
+	return r.rank() >= other.rank()
+}
+
+// ACL describes the access control lists on a repo, or on a whole context when Repo is empty.
+//
+// Contributors are identified by their email.
+//
+// ACLs are advisory: they prevent unintended operations, but are not enforced by the storage backends.
+type ACL struct {
+	Repo        string      `json:"repo,omitempty" yaml:"repo,omitempty"`
+	Readers     []string    `json:"readers,omitempty" yaml:"readers,omitempty"`
+	Writers     []string    `json:"writers,omitempty" yaml:"writers,omitempty"`
+	Admins      []string    `json:"admins,omitempty" yaml:"admins,omitempty"`
+	Timestamp   time.Time   `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`     // documentary: last update time
+	Contributor Contributor `json:"contributor,omitempty" yaml:"contributor,omitempty"` // documentary: last update author
+	_           struct{}
+}
+
+// IsEmpty tells if no role is granted by this ACL, i.e. no restriction applies
+func (a ACL) IsEmpty() bool {
+	return len(a.Readers) == 0 && len(a.Writers) == 0 && len(a.Admins) == 0
+}
+
+// RoleOf yields the role granted to some contributor email
+func (a ACL) RoleOf(email string) ACLRole {
+	email = normalizeEmail(email)
+	switch {
+	case email != "" && aclContains(a.Admins, email), aclContains(a.Admins, ACLEveryone):
+		return ACLAdmin
+	case email != "" && aclContains(a.Writers, email), aclContains(a.Writers, ACLEveryone):
+		return ACLWriter
+	case email != "" && aclContains(a.Readers, email), aclContains(a.Readers, ACLEveryone):
+		return ACLReader
+	default:
+		return ACLNone
+	}
+}
+
+// Grant a role to some contributor email. Any previously granted role is replaced.
+func (a *ACL) Grant(email string, role ACLRole) error {
+	if !role.IsValid() {
+		return fmt.Errorf("invalid role: %q", role)
+	}
+	email = normalizeEmail(email)
+	if email != ACLEveryone {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return fmt.Errorf("invalid email: %q: %v", email, err)
+		}
+	}
+
+	a.Revoke(email)
+	switch role {
+	case ACLAdmin:
+		a.Admins = append(a.Admins, email)
+		sort.Strings(a.Admins)
+	case ACLWriter:
+		a.Writers = append(a.Writers, email)
+		sort.Strings(a.Writers)
+	case ACLReader:
+		a.Readers = append(a.Readers, email)
+		sort.Strings(a.Readers)
+	}
+	return nil
+}
+
+// Revoke any role granted to some contributor email
+func (a *ACL) Revoke(email string) {
+	email = normalizeEmail(email)
+	a.Readers = aclRemove(a.Readers, email)
+	a.Writers = aclRemove(a.Writers, email)
+	a.Admins = aclRemove(a.Admins, email)
+}
+
+// ValidateACL checks that an ACL may be administered, i.e. that a non-empty ACL declares at least one admin
+func ValidateACL(acl ACL) error {
+	if !acl.IsEmpty() && len(acl.Admins) == 0 {
+		return fmt.Errorf("validation failed: an ACL must declare at least one admin")
+	}
+	return nil
+}
+
+// GetArchivePathToACL yields the path to the ACL of a repo, or to the context-wide ACL when repo is empty.
+//
+// Example:
+//
+//	acls/repos/{repo}/acl.yaml
+//	acls/context/acl.yaml
+func GetArchivePathToACL(repo string) string {
+	if repo == "" {
+		return fmt.Sprint("acls/context/", aclDescriptorFile)
+	}
+	return fmt.Sprint("acls/repos/", repo, "/", aclDescriptorFile)
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func aclContains(list []string, email string) bool {
+	for _, e := range list {
+		if e == email {
+			return true
+		}
+	}
+	return false
+}
+
+func aclRemove(list []string, email string) []string {
+	filtered := list[:0]
+	for _, e := range list {
+		if e != email {
+			filtered = append(filtered, e)
+		}
+	}
+	if len(filtered) == 0 {
+		return nil
+	}
+	return filtered
+}
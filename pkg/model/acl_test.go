@@ -0,0 +1,49 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACL(t *testing.T) {
+	var acl ACL
+	assert.True(t, acl.IsEmpty())
+	assert.NoError(t, ValidateACL(acl))
+	assert.Equal(t, ACLNone, acl.RoleOf("jane@example.com"))
+
+	require.NoError(t, acl.Grant("Jane@Example.com", ACLWriter))
+	assert.Error(t, ValidateACL(acl))
+	require.NoError(t, acl.Grant("john@example.com", ACLAdmin))
+	assert.NoError(t, ValidateACL(acl))
+
+	assert.Equal(t, ACLWriter, acl.RoleOf("jane@example.com"))
+	assert.Equal(t, ACLAdmin, acl.RoleOf(" JOHN@example.com"))
+	assert.Equal(t, ACLNone, acl.RoleOf("other@example.com"))
+	assert.Equal(t, ACLNone, acl.RoleOf(""))
+
+	// a new grant replaces the former role
+	require.NoError(t, acl.Grant("jane@example.com", ACLReader))
+	assert.Equal(t, ACLReader, acl.RoleOf("jane@example.com"))
+	assert.Empty(t, acl.Writers)
+
+	require.NoError(t, acl.Grant(ACLEveryone, ACLWriter))
+	assert.Equal(t, ACLWriter, acl.RoleOf("jane@example.com"))
+	assert.Equal(t, ACLWriter, acl.RoleOf("other@example.com"))
+	assert.Equal(t, ACLAdmin, acl.RoleOf("john@example.com"))
+
+	acl.Revoke(ACLEveryone)
+	acl.Revoke("jane@example.com")
+	assert.Equal(t, ACLNone, acl.RoleOf("jane@example.com"))
+
+	assert.Error(t, acl.Grant("jane@example.com", "owner"))
+	assert.Error(t, acl.Grant("not an email", ACLReader))
+
+	assert.True(t, ACLAdmin.Includes(ACLWriter))
+	assert.False(t, ACLReader.Includes(ACLWriter))
+	assert.True(t, ACLReader.Includes(ACLNone))
+
+	assert.Equal(t, "acls/repos/my-repo/acl.yaml", GetArchivePathToACL("my-repo"))
+	assert.Equal(t, "acls/context/acl.yaml", GetArchivePathToACL(""))
+}
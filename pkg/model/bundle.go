@@ -17,16 +17,19 @@ import (
 
 // BundleDescriptor represents a commit which is a file tree with the changes to the repository.
 type BundleDescriptor struct {
-	LeafSize               uint32        `json:"leafSize" yaml:"leafSize"`                               // Bundles blobs are independently generated
-	ID                     string        `json:"id" yaml:"id"`                                           // Unique ID for the bundle.
-	Message                string        `json:"message" yaml:"message"`                                 // Message for the commit/bundle
-	Parents                []string      `json:"parents,omitempty" yaml:"parents,omitempty"`             // Bundles with parent child relation
-	Timestamp              time.Time     `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`         // Local wall clock time
-	Contributors           []Contributor `json:"contributors" yaml:"contributors"`                       // Contributor for the bundle
-	BundleEntriesFileCount uint64        `json:"count" yaml:"count"`                                     // Number of file index files in this bundle
-	Version                uint64        `json:"version,omitempty" yaml:"version,omitempty"`             // Version for the metadata model used for this bundle
-	Deduplication          string        `json:"deduplication,omitempty" yaml:"deduplication,omitempty"` // Deduplication scheme used
-	RunStage               string        `json:"runstage,omitempty" yaml:"runstage,omitempty"`           // Path to the run stage
+	LeafSize               uint32            `json:"leafSize" yaml:"leafSize"`                               // Bundles blobs are independently generated
+	ID                     string            `json:"id" yaml:"id"`                                           // Unique ID for the bundle.
+	Message                string            `json:"message" yaml:"message"`                                 // Message for the commit/bundle
+	Parents                []string          `json:"parents,omitempty" yaml:"parents,omitempty"`             // Bundles with parent child relation
+	Timestamp              time.Time         `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`         // Local wall clock time
+	Contributors           []Contributor     `json:"contributors" yaml:"contributors"`                       // Contributor for the bundle
+	BundleEntriesFileCount uint64            `json:"count" yaml:"count"`                                     // Number of file index files in this bundle
+	Version                uint64            `json:"version,omitempty" yaml:"version,omitempty"`             // Version for the metadata model used for this bundle
+	Deduplication          string            `json:"deduplication,omitempty" yaml:"deduplication,omitempty"` // Deduplication scheme used
+	RunStage               string            `json:"runstage,omitempty" yaml:"runstage,omitempty"`           // Path to the run stage
+	Annotations            map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`     // Free-form metadata about the content of the bundle
+	FileCount              uint64            `json:"fileCount,omitempty" yaml:"fileCount,omitempty"`         // Number of files in this bundle (not recorded by older bundles)
+	Size                   uint64            `json:"size,omitempty" yaml:"size,omitempty"`                   // Total size of the files in this bundle (not recorded by older bundles)
 	_                      struct{}
 }
 
@@ -79,6 +82,7 @@ type BundleEntry struct {
 	FileMode     os.FileMode `json:"mode" yaml:"mode"`
 	Size         uint64      `json:"size" yaml:"size"`
 	Timestamp    time.Time   `json:"timestamp,omitempty" yaml:"timestamp,omitempty"` // time the file was uploaded. Only serialized with entries uploaded by splits (not bundles)
+	ModTime      time.Time   `json:"mtime,omitempty" yaml:"mtime,omitempty"`         // modification time of the file when uploaded. Only serialized with entries uploaded by snapshots
 	_            struct{}
 }
 
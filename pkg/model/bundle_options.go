@@ -35,3 +35,10 @@ func Deduplication(d string) BundleDescriptorOption {
 		b.Deduplication = d
 	}
 }
+
+// BundleAnnotations defines free-form metadata for a bundle descriptor, e.g. the version of the tool used to produce its content
+func BundleAnnotations(a map[string]string) BundleDescriptorOption {
+	return func(b *BundleDescriptor) {
+		b.Annotations = a
+	}
+}
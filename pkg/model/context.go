@@ -19,13 +19,83 @@ const (
 
 // Context defines the details for a datamon context.
 type Context struct {
-	Name      string `json:"name" yaml:"name"`           // Name for the context
-	WAL       string `json:"wal" yaml:"wal"`             // WAL is the location for the log
-	ReadLog   string `json:"readlog" yaml:"readlog"`     // Read log is the location for read log.
-	Blob      string `json:"blob" yaml:"blob"`           // Blob is the location for the data blobs
-	Metadata  string `json:"metadata" yaml:"metadata"`   // Metadata is the location for the immutable metadata
-	VMetadata string `json:"vmetadata" yaml:"vmetadata"` // VMetadata is the location for the mutable versioned metadata.
-	Version   uint64 `json:"version" yaml:"version"`     // Version for the
+	Name      string     `json:"name" yaml:"name"`                           // Name for the context
+	WAL       string     `json:"wal" yaml:"wal"`                             // WAL is the location for the log
+	ReadLog   string     `json:"readlog" yaml:"readlog"`                     // Read log is the location for read log.
+	Blob      string     `json:"blob" yaml:"blob"`                           // Blob is the location for the data blobs
+	Metadata  string     `json:"metadata" yaml:"metadata"`                   // Metadata is the location for the immutable metadata
+	VMetadata string     `json:"vmetadata" yaml:"vmetadata"`                 // VMetadata is the location for the mutable versioned metadata.
+	Version   uint64     `json:"version" yaml:"version"`                     // Version for the
+	Auth      AuthConfig `json:"auth,omitempty" yaml:"auth,omitempty"`       // Auth selects how contributors are identified
+	Replica   *Replica   `json:"replica,omitempty" yaml:"replica,omitempty"` // Replica is an optional mirror of blobs and metadata
+	_         struct{}
+}
+
+// ReplicaBackend names the cloud provider hosting the stores of a replica
+type ReplicaBackend string
+
+const (
+	// ReplicaGCS hosts a replica on Google Cloud Storage. This is the default.
+	ReplicaGCS ReplicaBackend = "gcs"
+	// ReplicaS3 hosts a replica on AWS S3
+	ReplicaS3 ReplicaBackend = "s3"
+)
+
+// IsValid checks if the replica backend is supported. The empty backend stands for the default one.
+func (b ReplicaBackend) IsValid() bool {
+	switch b {
+	case "", ReplicaGCS, ReplicaS3:
+		return true
+	default:
+		return false
+	}
+}
+
+// Replica describes the secondary stores mirroring the blobs and metadata of a context, for disaster recovery.
+//
+// Writes to the context are duplicated to the replica. Failures to write to the replica are tolerated:
+// a replica is brought up to date with "datamon context sync".
+type Replica struct {
+	Backend   ReplicaBackend `json:"backend,omitempty" yaml:"backend,omitempty"` // Backend is the cloud provider hosting the replica
+	Region    string         `json:"region,omitempty" yaml:"region,omitempty"`   // Region is the AWS region of the replica buckets
+	Blob      string         `json:"blob" yaml:"blob"`                           // Blob is the location for the replicated data blobs
+	Metadata  string         `json:"metadata" yaml:"metadata"`                   // Metadata is the location for the replicated immutable metadata
+	VMetadata string         `json:"vmetadata" yaml:"vmetadata"`                 // VMetadata is the location for the replicated versioned metadata
+	_         struct{}
+}
+
+// AuthProvider names a provider used to identify contributors
+type AuthProvider string
+
+const (
+	// AuthGoogle identifies contributors with the Google userinfo API. This is the default.
+	AuthGoogle AuthProvider = "google"
+	// AuthOIDC identifies contributors from an OIDC ID token, read from a file or from the environment
+	AuthOIDC AuthProvider = "oidc"
+	// AuthAWS identifies contributors with the AWS STS caller identity
+	AuthAWS AuthProvider = "aws"
+	// AuthStatic identifies contributors from the local config or from the system keyring
+	AuthStatic AuthProvider = "static"
+)
+
+// IsValid checks if the auth provider is supported. The empty provider stands for the default one.
+func (p AuthProvider) IsValid() bool {
+	switch p {
+	case "", AuthGoogle, AuthOIDC, AuthAWS, AuthStatic:
+		return true
+	default:
+		return false
+	}
+}
+
+// AuthConfig describes the authentication provider used by a context, with its provider-specific settings.
+type AuthConfig struct {
+	Provider  AuthProvider `json:"provider,omitempty" yaml:"provider,omitempty"`   // Provider used to identify contributors
+	TokenFile string       `json:"tokenFile,omitempty" yaml:"tokenFile,omitempty"` // TokenFile is the location of an OIDC ID token
+	TokenEnv  string       `json:"tokenEnv,omitempty" yaml:"tokenEnv,omitempty"`   // TokenEnv is the environment variable holding an OIDC ID token
+	Region    string       `json:"region,omitempty" yaml:"region,omitempty"`       // Region is the AWS region used to reach STS
+	Domain    string       `json:"domain,omitempty" yaml:"domain,omitempty"`       // Domain completes AWS principal names without an email
+	Keyring   string       `json:"keyring,omitempty" yaml:"keyring,omitempty"`     // Keyring is the keyring service holding a static identity
 	_         struct{}
 }
 
@@ -124,8 +194,31 @@ func ValidateContext(context Context) error {
 	case context.Version > ContextVersion:
 		cause += "Version higher than supported version"
 	}
+	if !context.Auth.Provider.IsValid() {
+		cause += fmt.Sprintf("Unsupported auth provider: %q", context.Auth.Provider)
+	}
+	if context.Replica != nil {
+		cause += validateReplica(*context.Replica)
+	}
 	if cause != "" {
 		return fmt.Errorf("validation failed, cause = %s", cause)
 	}
 	return nil
 }
+
+func validateReplica(replica Replica) string {
+	var cause string
+	if !replica.Backend.IsValid() {
+		cause += fmt.Sprintf("Unsupported replica backend: %q. ", replica.Backend)
+	}
+	if replica.Blob == "" {
+		cause += "Replica Blob is empty. "
+	}
+	if replica.Metadata == "" {
+		cause += "Replica Metadata is empty. "
+	}
+	if replica.VMetadata == "" {
+		cause += "Replica VMetadata is empty. "
+	}
+	return cause
+}
@@ -130,6 +130,66 @@ func TestValidateContext(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "success auth",
+			args: args{
+				context: Context{
+					Name:      "context1",
+					WAL:       "wal",
+					ReadLog:   "read",
+					Blob:      "blob",
+					Metadata:  "md",
+					VMetadata: "vmd",
+					Auth:      AuthConfig{Provider: AuthOIDC, TokenEnv: "TOKEN"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "fail auth",
+			args: args{
+				context: Context{
+					Name:      "context1",
+					WAL:       "wal",
+					ReadLog:   "read",
+					Blob:      "blob",
+					Metadata:  "md",
+					VMetadata: "vmd",
+					Auth:      AuthConfig{Provider: "ldap"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "success replica",
+			args: args{
+				context: Context{
+					Name:      "context1",
+					WAL:       "wal",
+					ReadLog:   "read",
+					Blob:      "blob",
+					Metadata:  "md",
+					VMetadata: "vmd",
+					Replica:   &Replica{Backend: ReplicaS3, Region: "us-west-2", Blob: "blob-dr", Metadata: "md-dr", VMetadata: "vmd-dr"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "fail replica",
+			args: args{
+				context: Context{
+					Name:      "context1",
+					WAL:       "wal",
+					ReadLog:   "read",
+					Blob:      "blob",
+					Metadata:  "md",
+					VMetadata: "vmd",
+					Replica:   &Replica{Backend: "azure", Blob: "blob-dr"},
+				},
+			},
+			wantErr: true,
+		},
 	}
 	for _, tts := range tests {
 		tt := tts
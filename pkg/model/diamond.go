@@ -74,12 +74,24 @@ const (
 
 	// SplitRunning is the state of running split
 	SplitRunning SplitState = running
+
+	// SplitOrphaned is the state of a split which has been running for too long and is considered abandoned.
+	// This is a terminal state.
+	SplitOrphaned SplitState = "orphaned"
+)
+
+const (
+	// DefaultDiamondTTL is the default time-to-live of a diamond with no activity, before it is considered abandoned
+	DefaultDiamondTTL = 7 * 24 * time.Hour
+
+	// DefaultSplitTTL is the default time-to-live of a running split, before it is considered abandoned
+	DefaultSplitTTL = 24 * time.Hour
 )
 
 // IsValid checks the value of a diamond state
 func (s SplitState) IsValid() bool {
 	switch s {
-	case SplitDone, SplitRunning:
+	case SplitDone, SplitRunning, SplitOrphaned:
 		return true
 	default:
 		return false
@@ -105,6 +117,28 @@ type DiamondDescriptor struct {
 	_              struct{}
 }
 
+// LastActivity yields the latest time some activity has been recorded on this diamond or on the given splits
+func (d DiamondDescriptor) LastActivity(splits []SplitDescriptor) time.Time {
+	last := d.StartTime
+	if d.EndTime.After(last) {
+		last = d.EndTime
+	}
+	for _, split := range splits {
+		if split.StartTime.After(last) {
+			last = split.StartTime
+		}
+		if split.EndTime.After(last) {
+			last = split.EndTime
+		}
+	}
+	return last
+}
+
+// IsStale tells if an initialized diamond has not seen any activity for longer than the time-to-live ttl
+func (d DiamondDescriptor) IsStale(splits []SplitDescriptor, ttl time.Duration, now time.Time) bool {
+	return d.State == DiamondInitialized && now.Sub(d.LastActivity(splits)) > ttl
+}
+
 // DiamondDescriptors is a sortable slice of DiamondDescriptor
 type DiamondDescriptors []DiamondDescriptor
 
@@ -162,6 +196,11 @@ type SplitDescriptor struct {
 	_ struct{}
 }
 
+// IsStale tells if a running split has been running for longer than the time-to-live ttl
+func (s SplitDescriptor) IsStale(ttl time.Duration, now time.Time) bool {
+	return s.State == SplitRunning && now.Sub(s.StartTime) > ttl
+}
+
 // SplitDescriptors is a sortable slice of SplitDescriptor
 type SplitDescriptors []SplitDescriptor
 
@@ -313,3 +352,48 @@ func GenerateConflictPath(splitID, pth string) string {
 func GenerateCheckpointPath(splitID, pth string) string {
 	return path.Join(".checkpoints", splitID, pth)
 }
+
+// FileVersion describes one version of a file, as uploaded by a split
+type FileVersion struct {
+	SplitID   string    `json:"splitID" yaml:"splitID"`
+	Hash      string    `json:"hash" yaml:"hash"`
+	Size      uint64    `json:"size" yaml:"size"`
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`               // the time of upload of this version
+	Latest    bool      `json:"latest,omitempty" yaml:"latest,omitempty"` // this version wins when merging splits
+	_         struct{}
+}
+
+// FileConflict describes a path uploaded with different content by several splits of a diamond
+type FileConflict struct {
+	Path     string        `json:"path" yaml:"path"`
+	Versions []FileVersion `json:"versions" yaml:"versions"` // competing versions, ordered by upload time
+	_        struct{}
+}
+
+// FileConflicts is a sortable slice of FileConflict
+type FileConflicts []FileConflict
+
+func (b FileConflicts) Swap(i, j int) {
+	b[i], b[j] = b[j], b[i]
+}
+func (b FileConflicts) Len() int {
+	return len(b)
+}
+func (b FileConflicts) Less(i, j int) bool {
+	return b[i].Path < b[j].Path
+}
+
+// Latest version of a conflicting file
+func (c FileConflict) Latest() FileVersion {
+	return c.Versions[len(c.Versions)-1]
+}
+
+// Version yields the version of a conflicting file uploaded by some split
+func (c FileConflict) Version(splitID string) (FileVersion, bool) {
+	for _, v := range c.Versions {
+		if v.SplitID == splitID {
+			return v, true
+		}
+	}
+	return FileVersion{}, false
+}
@@ -15,6 +15,7 @@ const (
 	labelDescriptorFile   = "label.yaml"
 	bundleDescriptorFile  = "bundle.yaml"
 	contextDescriptorFile = "context.yaml"
+	aclDescriptorFile     = "acl.yaml"
 	reverseIndexFile      = "reverse-index"
 	purgeLockFile         = "purge.lock"
 
@@ -0,0 +1,275 @@
+package sidecar
+
+import (
+	"context"
+	"os"
+
+	context2 "github.com/oneconcern/datamon/pkg/context"
+	"github.com/oneconcern/datamon/pkg/core"
+	"github.com/oneconcern/datamon/pkg/dlogger"
+	"github.com/oneconcern/datamon/pkg/fuse"
+	"github.com/oneconcern/datamon/pkg/model"
+	"github.com/oneconcern/datamon/pkg/sidecar/status"
+	"github.com/oneconcern/datamon/pkg/storage"
+	"github.com/oneconcern/datamon/pkg/storage/localfs"
+	"github.com/spf13/afero"
+	"go.uber.org/zap"
+)
+
+type (
+	// Source designates a bundle to retrieve: by ID, by label or the latest bundle of a repo
+	Source struct {
+		Repo     string
+		Label    string
+		BundleID string
+	}
+
+	// Destination describes a bundle to save
+	Destination struct {
+		Repo        string
+		Message     string
+		Label       string
+		Annotations map[string]string
+	}
+
+	// Actions knows how to retrieve and save bundles.
+	//
+	// Actions are performed by datamon, and may be mocked for tests.
+	Actions interface {
+		// Resolve the bundle ID of a source
+		Resolve(ctx context.Context, source Source) (string, error)
+
+		// Mount a bundle as a read-only file system. The returned function unmounts it.
+		Mount(ctx context.Context, path string, source Source) (func() error, error)
+
+		// Download a bundle to some local folder
+		Download(ctx context.Context, path string, source Source) error
+
+		// Upload the content of some local folder as a new bundle, and yields its ID
+		Upload(ctx context.Context, path string, destination Destination) (string, error)
+
+		// Label sets a label on an existing bundle
+		Label(ctx context.Context, repo, label, bundleID string) error
+	}
+
+	// ActionsOption configures the datamon actions
+	ActionsOption func(*datamonActions)
+
+	datamonActions struct {
+		stores      context2.Stores
+		l           *zap.Logger
+		contributor model.Contributor
+		bundleOpts  []core.BundleOption
+		fuseOpts    []fuse.Option
+	}
+)
+
+// ActionsLogger sets a logger for datamon actions
+func ActionsLogger(l *zap.Logger) ActionsOption {
+	return func(a *datamonActions) {
+		if l != nil {
+			a.l = l
+		}
+	}
+}
+
+// ActionsContributor sets the contributor of uploaded bundles
+func ActionsContributor(contributor model.Contributor) ActionsOption {
+	return func(a *datamonActions) {
+		a.contributor = contributor
+	}
+}
+
+// ActionsBundleOptions sets extra options for all bundles, e.g. concurrency or metrics
+func ActionsBundleOptions(opts ...core.BundleOption) ActionsOption {
+	return func(a *datamonActions) {
+		a.bundleOpts = append(a.bundleOpts, opts...)
+	}
+}
+
+// ActionsFuseOptions sets extra options for mounted bundles, e.g. caching
+func ActionsFuseOptions(opts ...fuse.Option) ActionsOption {
+	return func(a *datamonActions) {
+		a.fuseOpts = append(a.fuseOpts, opts...)
+	}
+}
+
+// NewActions builds the datamon actions on the stores of a context
+func NewActions(stores context2.Stores, opts ...ActionsOption) Actions {
+	a := &datamonActions{
+		stores: stores,
+		l:      dlogger.MustGetLogger("info"),
+	}
+
+	for _, apply := range opts {
+		apply(a)
+	}
+
+	return a
+}
+
+func localStore(path string) storage.Store {
+	return localfs.New(afero.NewBasePathFs(afero.NewOsFs(), path))
+}
+
+func (a *datamonActions) bundle(repo string, opts ...core.BundleOption) *core.Bundle {
+	bundleOpts := []core.BundleOption{
+		core.ContextStores(a.stores),
+		core.Repo(repo),
+		core.Logger(a.l),
+	}
+	bundleOpts = append(bundleOpts, a.bundleOpts...)
+	bundleOpts = append(bundleOpts, opts...)
+
+	return core.NewBundle(bundleOpts...)
+}
+
+func (a *datamonActions) Resolve(ctx context.Context, source Source) (string, error) {
+	switch {
+	case source.BundleID != "":
+		return source.BundleID, nil
+	case source.Label != "":
+		label := core.NewLabel(
+			core.LabelDescriptor(
+				model.NewLabelDescriptor(
+					model.LabelName(source.Label),
+				),
+			))
+		if err := label.DownloadDescriptor(ctx, a.bundle(source.Repo), true); err != nil {
+			return "", err
+		}
+		return label.Descriptor.BundleID, nil
+	default:
+		return core.GetLatestBundle(source.Repo, a.stores)
+	}
+}
+
+func (a *datamonActions) Mount(ctx context.Context, path string, source Source) (func() error, error) {
+	bundleID, err := a.Resolve(ctx, source)
+	if err != nil {
+		return nil, status.ErrMount.Wrap(err)
+	}
+
+	consumable, err := afero.TempDir(afero.NewOsFs(), "", "datamon-mount-destination")
+	if err != nil {
+		return nil, status.ErrMount.Wrap(err)
+	}
+
+	bundle := a.bundle(source.Repo,
+		core.BundleID(bundleID),
+		core.ConsumableStore(localStore(consumable)),
+	)
+
+	fsOpts := []fuse.Option{
+		fuse.Streaming(true),
+		fuse.Logger(a.l),
+		fuse.Label(source.Label),
+	}
+	fsOpts = append(fsOpts, a.fuseOpts...)
+
+	fs, err := fuse.NewReadOnlyFS(bundle, fsOpts...)
+	if err != nil {
+		return nil, status.ErrMount.Wrap(err)
+	}
+
+	if err = fs.MountReadOnly(path); err != nil {
+		return nil, status.ErrMount.Wrap(err)
+	}
+
+	a.l.Info("mounted bundle",
+		zap.String("repo", source.Repo),
+		zap.String("bundle_id", bundleID),
+		zap.String("mountpoint", path),
+	)
+
+	return func() error {
+		defer func() {
+			_ = os.RemoveAll(consumable)
+		}()
+		return fs.Unmount(path)
+	}, nil
+}
+
+func (a *datamonActions) Download(ctx context.Context, path string, source Source) error {
+	bundleID, err := a.Resolve(ctx, source)
+	if err != nil {
+		return status.ErrDownload.Wrap(err)
+	}
+
+	if err = os.MkdirAll(path, 0755); err != nil {
+		return status.ErrDownload.Wrap(err)
+	}
+
+	bundle := a.bundle(source.Repo,
+		core.BundleID(bundleID),
+		core.ConsumableStore(localStore(path)),
+	)
+
+	if err = core.Publish(ctx, bundle); err != nil {
+		return status.ErrDownload.Wrap(err)
+	}
+
+	a.l.Info("downloaded bundle",
+		zap.String("repo", source.Repo),
+		zap.String("bundle_id", bundleID),
+		zap.String("path", path),
+	)
+
+	return nil
+}
+
+func (a *datamonActions) Upload(ctx context.Context, path string, destination Destination) (string, error) {
+	bundle := a.bundle(destination.Repo,
+		core.BundleDescriptor(model.NewBundleDescriptor(
+			model.Message(destination.Message),
+			model.BundleContributor(a.contributor),
+			model.BundleAnnotations(destination.Annotations),
+		)),
+		core.ConsumableStore(localStore(path)),
+	)
+
+	if err := core.Upload(ctx, bundle); err != nil {
+		return "", status.ErrUpload.Wrap(err)
+	}
+
+	if destination.Label != "" {
+		if err := a.label(ctx, bundle, destination.Label); err != nil {
+			return "", status.ErrUpload.Wrap(err)
+		}
+	}
+
+	a.l.Info("uploaded bundle",
+		zap.String("repo", destination.Repo),
+		zap.String("bundle_id", bundle.BundleID),
+		zap.String("label", destination.Label),
+		zap.String("path", path),
+	)
+
+	return bundle.BundleID, nil
+}
+
+func (a *datamonActions) Label(ctx context.Context, repo, label, bundleID string) error {
+	if err := a.label(ctx, a.bundle(repo, core.BundleID(bundleID)), label); err != nil {
+		return status.ErrUpload.Wrap(err)
+	}
+
+	a.l.Info("labelled bundle",
+		zap.String("repo", repo),
+		zap.String("bundle_id", bundleID),
+		zap.String("label", label),
+	)
+
+	return nil
+}
+
+func (a *datamonActions) label(ctx context.Context, bundle *core.Bundle, name string) error {
+	label := core.NewLabel(
+		core.LabelDescriptor(
+			model.NewLabelDescriptor(
+				model.LabelContributor(a.contributor),
+				model.LabelName(name),
+			),
+		))
+
+	return label.UploadDescriptor(ctx, bundle)
+}
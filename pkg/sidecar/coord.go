@@ -0,0 +1,131 @@
+package sidecar
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/sidecar/status"
+)
+
+// Events exchanged with the application wrapper, as signaling files on the coordination point
+const (
+	// EventMountDone is emitted by a fuse sidecar when all bundles are mounted
+	EventMountDone = "mountdone"
+
+	// EventInitUpload is emitted by the application wrapper when the output is ready to be uploaded by a fuse sidecar
+	EventInitUpload = "initupload"
+
+	// EventUploadDone is emitted by a fuse sidecar when all bundles are uploaded
+	EventUploadDone = "uploaddone"
+
+	// EventDBStarted is emitted by a postgres sidecar when a database server is started
+	EventDBStarted = "dbstarted"
+
+	// EventInitDBUpload is emitted by the application wrapper when a database is ready to be uploaded
+	EventInitDBUpload = "initdbupload"
+
+	// EventDBUploadDone is emitted by a postgres sidecar when a database is uploaded
+	EventDBUploadDone = "dbuploaddone"
+
+	// EventDownloadDone is emitted by a sidecar when a bundle is downloaded
+	EventDownloadDone = "downloaddone"
+
+	// EventRelease is emitted by the application wrapper when the application no longer needs an input
+	EventRelease = "release"
+)
+
+// DefaultPollInterval is the default interval between two checks for signaling files
+const DefaultPollInterval = time.Second
+
+// Coordinator exchanges events with the application wrapper, as signaling files
+// on a coordination point shared by the containers of a pod.
+//
+// Events may be scoped, e.g. by database: scoped events are signaled in a subfolder of the coordination point.
+type Coordinator struct {
+	point        string
+	pollInterval time.Duration
+}
+
+// NewCoordinator builds a coordinator on a coordination point
+func NewCoordinator(point string, pollInterval time.Duration) *Coordinator {
+	if pollInterval <= 0 {
+		pollInterval = DefaultPollInterval
+	}
+	return &Coordinator{
+		point:        point,
+		pollInterval: pollInterval,
+	}
+}
+
+// Point yields the coordination point
+func (c *Coordinator) Point() string {
+	return c.point
+}
+
+func (c *Coordinator) path(event string, scope []string) string {
+	return filepath.Join(append(append([]string{c.point}, scope...), event)...)
+}
+
+// Emit signals an event
+func (c *Coordinator) Emit(event string, scope ...string) error {
+	pth := c.path(event, scope)
+	if err := os.MkdirAll(filepath.Dir(pth), 0755); err != nil {
+		return status.ErrCoordination.Wrap(err)
+	}
+
+	file, err := os.OpenFile(pth, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return status.ErrCoordination.Wrap(err)
+	}
+
+	if err := file.Close(); err != nil {
+		return status.ErrCoordination.Wrap(err)
+	}
+
+	return nil
+}
+
+// Has tells if an event has been signaled
+func (c *Coordinator) Has(event string, scope ...string) bool {
+	_, err := os.Stat(c.path(event, scope))
+	return err == nil
+}
+
+// Await blocks until an event is signaled, or the context is canceled
+func (c *Coordinator) Await(ctx context.Context, event string, scope ...string) error {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if c.Has(event, scope...) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.ErrCoordination.WrapMessage("waiting for event %q: %v", event, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// AwaitScoped blocks until an event is signaled for a scope, or for all scopes (i.e. without scope),
+// or the context is canceled
+func (c *Coordinator) AwaitScoped(ctx context.Context, event, scope string) error {
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		if c.Has(event, scope) || c.Has(event) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.ErrCoordination.WrapMessage("waiting for event %q on %q: %v", event, scope, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
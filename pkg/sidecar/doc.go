@@ -0,0 +1,17 @@
+// Package sidecar implements datamon sidecars, which coordinate with an application wrapper
+// to provide data to an application and save its results.
+//
+// A fuse sidecar mounts bundles for the application to read, then uploads bundles from the
+// output of the application. A postgres sidecar starts database servers, possibly restored from bundles,
+// then saves the databases as bundles once the application is done.
+//
+// A sidecar may also run a declarative specification, mixing inputs (mounted bundles, downloaded bundles
+// or databases) and outputs (uploaded bundles, labels or saved databases), each with an independent lifecycle.
+//
+// Sidecars and the application wrapper communicate with signaling files on a shared coordination point:
+// the sidecar emits an event when data is ready, then waits for the application to signal that its output
+// may be uploaded.
+//
+// Datamon operations and postgres commands are abstracted behind interfaces, so sidecars may be tested
+// without fuse, postgres or any cloud storage.
+package sidecar
@@ -4,6 +4,7 @@ package param
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io/ioutil"
 
 	"gopkg.in/yaml.v2"
@@ -83,23 +84,27 @@ func (fuseParams *FUSEParams) FirstCutSidecarFmt(destPath string) error {
 }
 
 type pgParamsDBParams struct {
-	Name         string `json:"name" yaml:"name"`
-	Port         int    `json:"pgPort" yaml:"pgPort"`
-	DestRepo     string `json:"destRepo" yaml:"destRepo"`
-	DestMessage  string `json:"destMessage" yaml:"destMessage"`
-	DestLabel    string `json:"destLabel" yaml:"destLabel"`
-	DestBundleID string `json:"destBundleID" yaml:"destBundleID"`
-	SrcRepo      string `json:"srcRepo" yaml:"srcRepo"`
-	SrcLabel     string `json:"srcLabel" yaml:"srcLabel"`
-	SrcBundle    string `json:"srcBundle" yaml:"srcBundle"`
+	Name         string   `json:"name" yaml:"name"`
+	Port         int      `json:"pgPort" yaml:"pgPort"`
+	DestRepo     string   `json:"destRepo" yaml:"destRepo"`
+	DestMessage  string   `json:"destMessage" yaml:"destMessage"`
+	DestLabel    string   `json:"destLabel" yaml:"destLabel"`
+	DestBundleID string   `json:"destBundleID" yaml:"destBundleID"`
+	SrcRepo      string   `json:"srcRepo" yaml:"srcRepo"`
+	SrcLabel     string   `json:"srcLabel" yaml:"srcLabel"`
+	SrcBundle    string   `json:"srcBundle" yaml:"srcBundle"`
+	DataDir      string   `json:"dataDir,omitempty" yaml:"dataDir,omitempty"`
+	Owner        string   `json:"owner,omitempty" yaml:"owner,omitempty"`
+	DestFormat   PGFormat `json:"destFormat,omitempty" yaml:"destFormat,omitempty"`
 	_            struct{}
 }
 
 type PGParams struct {
 	Globals struct {
-		SleepInsteadOfExit      bool   `json:"sleepInsteadOfExit" yaml:"sleepInsteadOfExit"`
-		IgnorePGVersionMismatch bool   `json:"ignorePGVersionMismatch" yaml:"ignorePGVersionMismatch"`
-		CoordPoint              string `json:"coordPoint" yaml:"coordPoint"`
+		SleepInsteadOfExit      bool        `json:"sleepInsteadOfExit" yaml:"sleepInsteadOfExit"`
+		IgnorePGVersionMismatch bool        `json:"ignorePGVersionMismatch" yaml:"ignorePGVersionMismatch"`
+		PGMigration             PGMigration `json:"pgMigration,omitempty" yaml:"pgMigration,omitempty"`
+		CoordPoint              string      `json:"coordPoint" yaml:"coordPoint"`
 		Contributor             struct {
 			Name  string `json:"name" yaml:"name"`
 			Email string `json:"email" yaml:"email"`
@@ -259,6 +264,12 @@ func PGContributor(name string, email string) PGParamsOption {
 	}
 }
 
+func PGMigrationMethod(method PGMigration) PGParamsOption {
+	return func(pgParams *PGParams) {
+		pgParams.Globals.PGMigration = method
+	}
+}
+
 func NewPGParams(pgOpts ...PGParamsOption) (PGParams, error) {
 	pgParams := PGParams{}
 	for _, apply := range pgOpts {
@@ -267,6 +278,9 @@ func NewPGParams(pgOpts ...PGParamsOption) (PGParams, error) {
 	if pgParams.Globals.CoordPoint == "" {
 		return pgParams, errors.New("coordination point not set")
 	}
+	if !pgParams.Globals.PGMigration.IsValid() {
+		return pgParams, fmt.Errorf("unsupported postgres migration method %q", pgParams.Globals.PGMigration)
+	}
 	return pgParams, nil
 }
 
@@ -306,12 +320,32 @@ func DBSrcByBundle(repo string, bundle string) PGParamsDBOption {
 	}
 }
 
+func DBDestFormat(format PGFormat) PGParamsDBOption {
+	return func(dbParams *pgParamsDBParams) {
+		dbParams.DestFormat = format
+	}
+}
+
 func DBDestBundleIDFile(bundleIDFile string) PGParamsDBOption {
 	return func(dbParams *pgParamsDBParams) {
 		dbParams.DestBundleID = bundleIDFile
 	}
 }
 
+// DBDataDir sets the root directory hosting the database files
+func DBDataDir(dataDir string) PGParamsDBOption {
+	return func(dbParams *pgParamsDBParams) {
+		dbParams.DataDir = dataDir
+	}
+}
+
+// DBOwner sets the role owning a newly created database server
+func DBOwner(owner string) PGParamsDBOption {
+	return func(dbParams *pgParamsDBParams) {
+		dbParams.Owner = owner
+	}
+}
+
 func (pgParams *PGParams) AddDatabase(dbOpts ...PGParamsDBOption) error {
 	dbParams := pgParamsDBParams{}
 	for _, apply := range dbOpts {
@@ -332,6 +366,27 @@ func (pgParams *PGParams) AddDatabase(dbOpts ...PGParamsDBOption) error {
 	if dbParams.SrcLabel != "" && dbParams.SrcBundle != "" {
 		return errors.New("specifying source by bundle and label is mutually exclusive")
 	}
+	if !dbParams.DestFormat.IsValid() {
+		return fmt.Errorf("unsupported database format %q", dbParams.DestFormat)
+	}
 	pgParams.Databases = append(pgParams.Databases, dbParams)
 	return nil
 }
+
+// UnmarshalFUSEParams reads fuse sidecar parameters from a YAML or JSON document
+func UnmarshalFUSEParams(b []byte) (FUSEParams, error) {
+	var fuseParams FUSEParams
+	if err := yaml.Unmarshal(b, &fuseParams); err != nil {
+		return fuseParams, err
+	}
+	return fuseParams, nil
+}
+
+// UnmarshalPGParams reads postgres sidecar parameters from a YAML or JSON document
+func UnmarshalPGParams(b []byte) (PGParams, error) {
+	var pgParams PGParams
+	if err := yaml.Unmarshal(b, &pgParams); err != nil {
+		return pgParams, err
+	}
+	return pgParams, nil
+}
@@ -0,0 +1,412 @@
+package param
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// InputKind tells how a data set is provided to the application
+type InputKind string
+
+// OutputKind tells how the output of the application is saved
+type OutputKind string
+
+// Kinds of inputs
+const (
+	// InputMount mounts a bundle as a read-only file system
+	InputMount InputKind = "mount"
+
+	// InputDownload downloads a bundle to some local folder
+	InputDownload InputKind = "download"
+
+	// InputDatabase starts a postgres database server, restored from a bundle or created from scratch
+	InputDatabase InputKind = "database"
+)
+
+// Kinds of outputs
+const (
+	// OutputUpload uploads the content of some local folder as a new bundle
+	OutputUpload OutputKind = "upload"
+
+	// OutputLabel sets a label on an existing bundle, e.g. to promote an input
+	OutputLabel OutputKind = "label"
+
+	// OutputDatabase saves a database as a new bundle
+	OutputDatabase OutputKind = "database"
+)
+
+// IsValid tells if an input kind is supported
+func (k InputKind) IsValid() bool {
+	switch k {
+	case InputMount, InputDownload, InputDatabase:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsValid tells if an output kind is supported
+func (k OutputKind) IsValid() bool {
+	switch k {
+	case OutputUpload, OutputLabel, OutputDatabase:
+		return true
+	default:
+		return false
+	}
+}
+
+// PGMigration tells how a database restored from a bundle created by an older major version of postgres
+// is migrated to the running version
+type PGMigration string
+
+// Postgres migration methods
+const (
+	// PGMigrationNone does not migrate databases: restoring a database created by another major version fails
+	PGMigrationNone PGMigration = ""
+
+	// PGMigrationUpgrade migrates the data directory with pg_upgrade
+	PGMigrationUpgrade PGMigration = "upgrade"
+
+	// PGMigrationDump dumps all databases with the older version, then restores them with the running version
+	PGMigrationDump PGMigration = "dump"
+)
+
+// IsValid tells if a migration method is supported
+func (m PGMigration) IsValid() bool {
+	switch m {
+	case PGMigrationNone, PGMigrationUpgrade, PGMigrationDump:
+		return true
+	default:
+		return false
+	}
+}
+
+// PGFormat tells how a database is stored in a bundle
+type PGFormat string
+
+// Database formats
+const (
+	// PGFormatFiles stores the files of the data directory of the database server. This is the default.
+	PGFormatFiles PGFormat = "files"
+
+	// PGFormatDump stores logical dumps of all databases, with the content of every table split in chunks
+	PGFormatDump PGFormat = "dump"
+)
+
+// IsValid tells if a database format is supported
+func (f PGFormat) IsValid() bool {
+	switch f {
+	case "", PGFormatFiles, PGFormatDump:
+		return true
+	default:
+		return false
+	}
+}
+
+// SpecInput declares a data set provided to the application
+type SpecInput struct {
+	Name string    `json:"name" yaml:"name"`
+	Kind InputKind `json:"kind" yaml:"kind"`
+
+	// Path is the mount point or the download folder. For databases, this is the root of the data directory.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+
+	// source bundle: by bundle id, by label or the latest bundle of a repo.
+	// A database without a repo is created from scratch.
+	Repo   string `json:"repo,omitempty" yaml:"repo,omitempty"`
+	Label  string `json:"label,omitempty" yaml:"label,omitempty"`
+	Bundle string `json:"bundle,omitempty" yaml:"bundle,omitempty"`
+
+	// database server settings
+	Port  int    `json:"pgPort,omitempty" yaml:"pgPort,omitempty"`
+	Owner string `json:"owner,omitempty" yaml:"owner,omitempty"`
+}
+
+// SpecOutput declares how some output of the application is saved
+type SpecOutput struct {
+	Name string     `json:"name" yaml:"name"`
+	Kind OutputKind `json:"kind" yaml:"kind"`
+
+	// Path is the folder to upload
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+
+	// Database is the name of the database input to save
+	Database string `json:"database,omitempty" yaml:"database,omitempty"`
+
+	// Input is the name of a mount or download input, which bundle is labelled
+	Input string `json:"input,omitempty" yaml:"input,omitempty"`
+
+	// destination: the repo, commit message and optional label of the new bundle.
+	// For labels, Bundle designates the bundle to label, unless an input is specified.
+	Repo         string `json:"repo,omitempty" yaml:"repo,omitempty"`
+	Message      string `json:"message,omitempty" yaml:"message,omitempty"`
+	Label        string `json:"label,omitempty" yaml:"label,omitempty"`
+	Bundle       string `json:"bundle,omitempty" yaml:"bundle,omitempty"`
+	BundleIDFile string `json:"bundleIDFile,omitempty" yaml:"bundleIDFile,omitempty"`
+
+	// Format tells how a database is stored
+	Format PGFormat `json:"format,omitempty" yaml:"format,omitempty"`
+}
+
+// SpecGlobals holds the settings shared by all inputs and outputs
+type SpecGlobals struct {
+	SleepInsteadOfExit      bool        `json:"sleepInsteadOfExit" yaml:"sleepInsteadOfExit"`
+	IgnorePGVersionMismatch bool        `json:"ignorePGVersionMismatch" yaml:"ignorePGVersionMismatch"`
+	PGMigration             PGMigration `json:"pgMigration,omitempty" yaml:"pgMigration,omitempty"`
+	CoordPoint              string      `json:"coordPoint" yaml:"coordPoint"`
+	ConfigBucketName        string      `json:"configBucketName,omitempty" yaml:"configBucketName,omitempty"`
+	ContextName             string      `json:"contextName,omitempty" yaml:"contextName,omitempty"`
+	Contributor             struct {
+		Name  string `json:"name" yaml:"name"`
+		Email string `json:"email" yaml:"email"`
+	} `json:"contributor" yaml:"contributor"`
+}
+
+// Spec is a declarative specification of the data sets consumed and produced by an application.
+//
+// Inputs and outputs are independent: a single sidecar may mount, download or restore several data sets,
+// and publish several results, mixing files and databases.
+type Spec struct {
+	Globals SpecGlobals  `json:"globalOpts" yaml:"globalOpts"`
+	Inputs  []SpecInput  `json:"inputs" yaml:"inputs"`
+	Outputs []SpecOutput `json:"outputs" yaml:"outputs"`
+}
+
+// UnmarshalSpec reads a sidecar specification from a YAML or JSON document
+func UnmarshalSpec(b []byte) (Spec, error) {
+	var spec Spec
+	if err := yaml.Unmarshal(b, &spec); err != nil {
+		return spec, err
+	}
+	return spec, nil
+}
+
+// Input yields a declared input by name
+func (spec Spec) Input(name string) (SpecInput, bool) {
+	for _, input := range spec.Inputs {
+		if input.Name == name {
+			return input, true
+		}
+	}
+	return SpecInput{}, false
+}
+
+// Validate the consistency of a specification
+func (spec Spec) Validate() error {
+	if spec.Globals.CoordPoint == "" {
+		return fmt.Errorf("a coordination point is required")
+	}
+	if !spec.Globals.PGMigration.IsValid() {
+		return fmt.Errorf("unsupported postgres migration method %q", spec.Globals.PGMigration)
+	}
+
+	names := make(map[string]struct{}, len(spec.Inputs)+len(spec.Outputs))
+	checkName := func(name string) error {
+		if name == "" || strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("invalid name %q", name)
+		}
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("duplicate name %q: inputs and outputs must have unique names", name)
+		}
+		names[name] = struct{}{}
+		return nil
+	}
+
+	ports := make(map[int]struct{})
+	for _, input := range spec.Inputs {
+		if err := checkName(input.Name); err != nil {
+			return err
+		}
+		if err := input.validate(ports); err != nil {
+			return fmt.Errorf("input %q: %w", input.Name, err)
+		}
+	}
+
+	saved := make(map[string]struct{})
+	for _, output := range spec.Outputs {
+		if err := checkName(output.Name); err != nil {
+			return err
+		}
+		if err := output.validate(spec, saved); err != nil {
+			return fmt.Errorf("output %q: %w", output.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (input SpecInput) validate(ports map[int]struct{}) error {
+	if !input.Kind.IsValid() {
+		return fmt.Errorf("unsupported input kind %q", input.Kind)
+	}
+
+	if input.Label != "" && input.Bundle != "" {
+		return fmt.Errorf("source may be specified by label or by bundle id, but not both")
+	}
+
+	if input.Kind == InputDatabase {
+		if input.Repo == "" && (input.Label != "" || input.Bundle != "") {
+			return fmt.Errorf("a source repo is required")
+		}
+		if input.Port <= 0 {
+			return fmt.Errorf("a port is required")
+		}
+		if _, ok := ports[input.Port]; ok {
+			return fmt.Errorf("port %d is already used", input.Port)
+		}
+		ports[input.Port] = struct{}{}
+		return nil
+	}
+
+	if input.Path == "" {
+		return fmt.Errorf("a path is required")
+	}
+	if input.Repo == "" {
+		return fmt.Errorf("a source repo is required")
+	}
+
+	return nil
+}
+
+func (output SpecOutput) validate(spec Spec, saved map[string]struct{}) error {
+	if !output.Kind.IsValid() {
+		return fmt.Errorf("unsupported output kind %q", output.Kind)
+	}
+
+	if output.Format != "" && output.Kind != OutputDatabase {
+		return fmt.Errorf("a format may only be specified for databases")
+	}
+	if !output.Format.IsValid() {
+		return fmt.Errorf("unsupported database format %q", output.Format)
+	}
+
+	switch output.Kind {
+	case OutputLabel:
+		if output.Label == "" {
+			return fmt.Errorf("a label is required")
+		}
+		if output.Input == "" {
+			if output.Repo == "" || output.Bundle == "" {
+				return fmt.Errorf("a repo and a bundle are required, unless an input is labelled")
+			}
+			return nil
+		}
+		input, ok := spec.Input(output.Input)
+		if !ok {
+			return fmt.Errorf("unknown input %q", output.Input)
+		}
+		if input.Kind == InputDatabase {
+			return fmt.Errorf("cannot label database input %q", output.Input)
+		}
+		return nil
+
+	case OutputDatabase:
+		input, ok := spec.Input(output.Database)
+		if !ok || input.Kind != InputDatabase {
+			return fmt.Errorf("unknown database input %q", output.Database)
+		}
+		if _, ok := saved[output.Database]; ok {
+			return fmt.Errorf("database %q is saved more than once", output.Database)
+		}
+		saved[output.Database] = struct{}{}
+
+	default:
+		if output.Path == "" {
+			return fmt.Errorf("a path is required")
+		}
+		for _, input := range spec.Inputs {
+			if input.Kind == InputMount && input.Path == output.Path {
+				return fmt.Errorf("path %q is already a mount point for input %q", output.Path, input.Name)
+			}
+		}
+	}
+
+	if output.Repo == "" || output.Message == "" {
+		return fmt.Errorf("a destination repo and message are required")
+	}
+
+	return nil
+}
+
+// Spec converts fuse sidecar parameters into a sidecar specification
+func (fuseParams FUSEParams) Spec() Spec {
+	spec := Spec{
+		Globals: SpecGlobals{
+			SleepInsteadOfExit: fuseParams.Globals.SleepInsteadOfExit,
+			CoordPoint:         fuseParams.Globals.CoordPoint,
+			ConfigBucketName:   fuseParams.Globals.ConfigBucketName,
+			ContextName:        fuseParams.Globals.ContextName,
+		},
+	}
+
+	for _, bundle := range fuseParams.Bundles {
+		if bundle.SrcPath != "" {
+			spec.Inputs = append(spec.Inputs, SpecInput{
+				Name:   bundle.Name,
+				Kind:   InputMount,
+				Path:   bundle.SrcPath,
+				Repo:   bundle.SrcRepo,
+				Label:  bundle.SrcLabel,
+				Bundle: bundle.SrcBundle,
+			})
+		}
+		if bundle.DestPath != "" {
+			spec.Outputs = append(spec.Outputs, SpecOutput{
+				Name:         bundle.Name,
+				Kind:         OutputUpload,
+				Path:         bundle.DestPath,
+				Repo:         bundle.DestRepo,
+				Message:      bundle.DestMessage,
+				Label:        bundle.DestLabel,
+				BundleIDFile: bundle.DestBundleID,
+			})
+		}
+	}
+
+	return spec
+}
+
+// Spec converts postgres sidecar parameters into a sidecar specification.
+//
+// Every database is declared as an input, and as an output named "<database>-save" whenever it has a destination.
+func (pgParams PGParams) Spec() Spec {
+	spec := Spec{
+		Globals: SpecGlobals{
+			SleepInsteadOfExit:      pgParams.Globals.SleepInsteadOfExit,
+			IgnorePGVersionMismatch: pgParams.Globals.IgnorePGVersionMismatch,
+			PGMigration:             pgParams.Globals.PGMigration,
+			CoordPoint:              pgParams.Globals.CoordPoint,
+		},
+	}
+	spec.Globals.Contributor.Name = pgParams.Globals.Contributor.Name
+	spec.Globals.Contributor.Email = pgParams.Globals.Contributor.Email
+
+	for _, db := range pgParams.Databases {
+		spec.Inputs = append(spec.Inputs, SpecInput{
+			Name:   db.Name,
+			Kind:   InputDatabase,
+			Path:   db.DataDir,
+			Repo:   db.SrcRepo,
+			Label:  db.SrcLabel,
+			Bundle: db.SrcBundle,
+			Port:   db.Port,
+			Owner:  db.Owner,
+		})
+		if db.DestRepo != "" {
+			spec.Outputs = append(spec.Outputs, SpecOutput{
+				Name:         db.Name + "-save",
+				Kind:         OutputDatabase,
+				Database:     db.Name,
+				Repo:         db.DestRepo,
+				Message:      db.DestMessage,
+				Label:        db.DestLabel,
+				BundleIDFile: db.DestBundleID,
+				Format:       db.DestFormat,
+			})
+		}
+	}
+
+	return spec
+}
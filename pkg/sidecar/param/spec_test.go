@@ -0,0 +1,178 @@
+package param
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSpec = `globalOpts:
+  coordPoint: /tmp/coord
+  contributor:
+    name: tester
+    email: tester@example.com
+inputs:
+- name: images
+  kind: mount
+  path: /data/images
+  repo: images
+  label: latest
+- name: annotations
+  kind: download
+  path: /data/annotations
+  repo: annotations
+- name: features
+  kind: database
+  pgPort: 5430
+  repo: features
+  bundle: "1234"
+- name: scratch
+  kind: database
+  pgPort: 5431
+  owner: app
+outputs:
+- name: model
+  kind: upload
+  path: /data/model
+  repo: models
+  message: trained model
+  label: candidate
+  bundleIDFile: /tmp/ids/model
+- name: features-save
+  kind: database
+  database: features
+  repo: features
+  message: updated features
+  format: dump
+- name: validated
+  kind: label
+  input: images
+  label: validated
+`
+
+func TestSpec(t *testing.T) {
+	spec, err := UnmarshalSpec([]byte(testSpec))
+	require.NoError(t, err)
+	require.NoError(t, spec.Validate())
+
+	require.Len(t, spec.Inputs, 4)
+	require.Len(t, spec.Outputs, 3)
+	assert.Equal(t, "tester@example.com", spec.Globals.Contributor.Email)
+	assert.Equal(t, InputDatabase, spec.Inputs[2].Kind)
+	assert.Equal(t, 5430, spec.Inputs[2].Port)
+	assert.Equal(t, "features", spec.Outputs[1].Database)
+	assert.Equal(t, PGFormatDump, spec.Outputs[1].Format)
+
+	input, ok := spec.Input("annotations")
+	require.True(t, ok)
+	assert.Equal(t, InputDownload, input.Kind)
+}
+
+func TestSpecValidate(t *testing.T) {
+	for _, toPin := range []struct {
+		name   string
+		mutate func(*Spec)
+	}{
+		{
+			name:   "missing coordination point",
+			mutate: func(s *Spec) { s.Globals.CoordPoint = "" },
+		},
+		{
+			name:   "unsupported migration",
+			mutate: func(s *Spec) { s.Globals.PGMigration = "copy" },
+		},
+		{
+			name:   "duplicate name",
+			mutate: func(s *Spec) { s.Outputs[0].Name = "images" },
+		},
+		{
+			name:   "unsupported kind",
+			mutate: func(s *Spec) { s.Inputs[0].Kind = "copy" },
+		},
+		{
+			name:   "label and bundle",
+			mutate: func(s *Spec) { s.Inputs[0].Bundle = "1234" },
+		},
+		{
+			name:   "mount without path",
+			mutate: func(s *Spec) { s.Inputs[0].Path = "" },
+		},
+		{
+			name:   "duplicate port",
+			mutate: func(s *Spec) { s.Inputs[3].Port = 5430 },
+		},
+		{
+			name:   "upload to mount point",
+			mutate: func(s *Spec) { s.Outputs[0].Path = "/data/images" },
+		},
+		{
+			name:   "upload without message",
+			mutate: func(s *Spec) { s.Outputs[0].Message = "" },
+		},
+		{
+			name:   "unknown database",
+			mutate: func(s *Spec) { s.Outputs[1].Database = "images" },
+		},
+		{
+			name: "database saved twice",
+			mutate: func(s *Spec) {
+				s.Outputs = append(s.Outputs, s.Outputs[1])
+				s.Outputs[len(s.Outputs)-1].Name = "again"
+			},
+		},
+		{
+			name:   "format for files",
+			mutate: func(s *Spec) { s.Outputs[0].Format = PGFormatDump },
+		},
+		{
+			name:   "unsupported format",
+			mutate: func(s *Spec) { s.Outputs[1].Format = "csv" },
+		},
+		{
+			name:   "label unknown input",
+			mutate: func(s *Spec) { s.Outputs[2].Input = "unknown" },
+		},
+	} {
+		testCase := toPin
+		t.Run(testCase.name, func(t *testing.T) {
+			spec, err := UnmarshalSpec([]byte(testSpec))
+			require.NoError(t, err)
+			testCase.mutate(&spec)
+			require.Error(t, spec.Validate())
+		})
+	}
+}
+
+func TestParamsToSpec(t *testing.T) {
+	spec := createFUSEParams(t).Spec()
+	require.NoError(t, spec.Validate())
+	require.Len(t, spec.Inputs, 1)
+	require.Len(t, spec.Outputs, 1)
+	assert.Equal(t, SpecInput{
+		Name:  "src",
+		Kind:  InputMount,
+		Path:  "/tmp/mount",
+		Repo:  "ransom-datamon-test-repo",
+		Label: "testlabel",
+	}, spec.Inputs[0])
+	assert.Equal(t, "/tmp/bundleid.txt", spec.Outputs[0].BundleIDFile)
+	assert.Equal(t, "datamon-sidecar-test", spec.Globals.ContextName)
+
+	pgParams, err := NewPGParams(PGCoordPoint("/tmp/coord"), PGContributor("tester", "tester@example.com"))
+	require.NoError(t, err)
+	require.NoError(t, pgParams.AddDatabase(
+		DBNameAndPort("db", 5432),
+		DBSrcByLabel("src-repo", "latest"),
+		DBDest("dest-repo", "saved"),
+	))
+
+	spec = pgParams.Spec()
+	require.NoError(t, spec.Validate())
+	require.Len(t, spec.Inputs, 1)
+	require.Len(t, spec.Outputs, 1)
+	assert.Equal(t, OutputDatabase, spec.Outputs[0].Kind)
+	assert.Equal(t, "db", spec.Outputs[0].Database)
+	assert.Equal(t, "db-save", spec.Outputs[0].Name)
+	assert.Equal(t, "tester", spec.Globals.Contributor.Name)
+}
@@ -0,0 +1,361 @@
+package sidecar
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/oneconcern/datamon/pkg/sidecar/status"
+	"gopkg.in/yaml.v2"
+)
+
+// pgDumpManifest is saved at the root of database bundles stored as logical dumps
+const pgDumpManifest = "pg_dump.yaml"
+
+// content-defined chunking of table dumps
+const (
+	dumpChunkMinSize = 64 * 1024
+	dumpChunkMaxSize = 4 * 1024 * 1024
+	dumpChunkBits    = 10 // cut after about 1 row in 1024
+)
+
+// dumpFieldSeparator separates the fields of catalog queries
+const dumpFieldSeparator = "\x1f"
+
+// dumpTablesQuery lists the tables of a database: their qualified name, a name usable as a path,
+// and the columns of their primary key, used to dump rows in a stable order
+const dumpTablesQuery = `SELECT format('%I.%I', n.nspname, c.relname), n.nspname || '.' || c.relname,
+  coalesce((SELECT string_agg(quote_ident(a.attname), ',' ORDER BY array_position(i.indkey::int2[], a.attnum))
+    FROM pg_index i JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+    WHERE i.indrelid = c.oid AND i.indisprimary), '')
+FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind = 'r' AND n.nspname NOT IN ('pg_catalog', 'information_schema') AND n.nspname NOT LIKE 'pg_toast%'
+  AND NOT EXISTS (SELECT 1 FROM pg_depend d WHERE d.objid = c.oid AND d.deptype = 'e')
+ORDER BY 1;`
+
+type (
+	// dumpManifest describes the content of a database bundle stored as logical dumps.
+	//
+	// A bundle holds:
+	//   pg_dump.yaml                               this manifest
+	//   globals.sql                                roles
+	//   <database>/pre-data.sql                    the database and its schema, without indexes and constraints
+	//   <database>/tables/<schema.table>/*.copy    chunks of table rows, in the COPY text format
+	//   <database>/sequences.sql                   the current values of sequences
+	//   <database>/post-data.sql                   indexes, constraints and triggers
+	dumpManifest struct {
+		Version   string         `json:"version" yaml:"version"`
+		Databases []dumpDatabase `json:"databases" yaml:"databases"`
+	}
+
+	dumpDatabase struct {
+		Name   string      `json:"name" yaml:"name"`
+		Path   string      `json:"path" yaml:"path"`
+		Tables []dumpTable `json:"tables,omitempty" yaml:"tables,omitempty"`
+	}
+
+	dumpTable struct {
+		Name   string `json:"name" yaml:"name"` // the qualified, quoted name of the table
+		Path   string `json:"path" yaml:"path"`
+		Chunks int    `json:"chunks" yaml:"chunks"`
+	}
+)
+
+// isDump tells if a folder holds a database stored as logical dumps
+func isDump(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, pgDumpManifest))
+	return err == nil
+}
+
+// readDumpManifest reads the manifest of logical dumps
+func readDumpManifest(dir string) (dumpManifest, error) {
+	var manifest dumpManifest
+	b, err := ioutil.ReadFile(filepath.Join(dir, pgDumpManifest))
+	if err != nil {
+		return manifest, status.ErrPostgres.Wrap(err)
+	}
+	if err = yaml.Unmarshal(b, &manifest); err != nil {
+		return manifest, status.ErrPostgres.WrapMessage("invalid database dump manifest: %v", err)
+	}
+	return manifest, nil
+}
+
+func (p *pgTools) Dump(ctx context.Context, port int, dir string) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return status.ErrPostgres.Wrap(err)
+	}
+
+	version, err := p.Version(ctx)
+	if err != nil {
+		return err
+	}
+	manifest := dumpManifest{Version: version}
+
+	conn := []string{"-h", "localhost", "-p", strconv.Itoa(port), "-U", p.superUser}
+	if _, err = p.cmd(ctx, "pg_dumpall", append(conn, "--globals-only", "-f", filepath.Join(dir, "globals.sql"))...); err != nil {
+		return err
+	}
+
+	out, err := p.psql(ctx, port, "", "SELECT datname FROM pg_database WHERE NOT datistemplate AND datname != 'postgres' ORDER BY 1;")
+	if err != nil {
+		return err
+	}
+
+	for _, name := range splitLines(out) {
+		db := dumpDatabase{
+			Name: name,
+			Path: url.PathEscape(name),
+		}
+		dbDir := filepath.Join(dir, db.Path)
+		if err = os.MkdirAll(dbDir, 0750); err != nil {
+			return status.ErrPostgres.Wrap(err)
+		}
+
+		for _, section := range []struct {
+			file string
+			args []string
+		}{
+			{file: "pre-data.sql", args: []string{"--create", "--section=pre-data"}},
+			{file: "post-data.sql", args: []string{"--section=post-data"}},
+			{file: "sequences.sql", args: []string{"--section=data", "--exclude-table-data=*.*"}},
+		} {
+			args := append(append(conn, section.args...), "-f", filepath.Join(dbDir, section.file), name)
+			if _, err = p.cmd(ctx, "pg_dump", args...); err != nil {
+				return err
+			}
+		}
+
+		if db.Tables, err = p.dumpTables(ctx, port, name, dbDir); err != nil {
+			return err
+		}
+		for i := range db.Tables {
+			db.Tables[i].Path = path.Join(db.Path, db.Tables[i].Path)
+		}
+
+		manifest.Databases = append(manifest.Databases, db)
+	}
+
+	b, err := yaml.Marshal(manifest)
+	if err != nil {
+		return status.ErrPostgres.Wrap(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, pgDumpManifest), b, 0600); err != nil {
+		return status.ErrPostgres.Wrap(err)
+	}
+
+	return nil
+}
+
+// dumpTables dumps the rows of all tables of a database, ordered by primary key, and splits them in chunks
+func (p *pgTools) dumpTables(ctx context.Context, port int, db, dbDir string) ([]dumpTable, error) {
+	out, err := p.cmd(ctx, "psql", "-h", "localhost", "-p", strconv.Itoa(port), "-U", p.superUser,
+		"-t", "-A", "-F", dumpFieldSeparator, "-c", dumpTablesQuery, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var tables []dumpTable
+	for _, line := range splitLines(out) {
+		fields := strings.Split(line, dumpFieldSeparator)
+		if len(fields) != 3 {
+			return nil, status.ErrPostgres.WrapMessage("unexpected table description: %q", line)
+		}
+
+		table := dumpTable{
+			Name: fields[0],
+			Path: path.Join("tables", url.PathEscape(fields[1])),
+		}
+		tableDir := filepath.Join(dbDir, filepath.FromSlash(table.Path))
+		if err = os.MkdirAll(tableDir, 0750); err != nil {
+			return nil, status.ErrPostgres.Wrap(err)
+		}
+
+		query := table.Name
+		if pk := fields[2]; pk != "" {
+			query = fmt.Sprintf("(SELECT * FROM %s ORDER BY %s)", table.Name, pk)
+		}
+		rows := tableDir + ".copy"
+		if _, err = p.psql(ctx, port, db, fmt.Sprintf(`\copy %s TO %s`, query, quoteLiteral(rows))); err != nil {
+			return nil, err
+		}
+
+		table.Chunks, err = splitChunks(rows, tableDir)
+		_ = os.Remove(rows)
+		if err != nil {
+			return nil, status.ErrPostgres.Wrap(err)
+		}
+
+		tables = append(tables, table)
+	}
+
+	return tables, nil
+}
+
+func (p *pgTools) Restore(ctx context.Context, port int, dir string) error {
+	manifest, err := readDumpManifest(dir)
+	if err != nil {
+		return err
+	}
+
+	conn := []string{"-h", "localhost", "-p", strconv.Itoa(port), "-U", p.superUser, "-X", "-q"}
+	script := func(db, file string) error {
+		_, err := p.cmd(ctx, "psql", append(conn, "-v", "ON_ERROR_STOP=1", "-d", db, "-f", file)...)
+		return err
+	}
+
+	// the dump recreates the super user role, which already exists: errors are not fatal
+	if _, err = p.cmd(ctx, "psql", append(conn, "-d", "postgres", "-f", filepath.Join(dir, "globals.sql"))...); err != nil {
+		return err
+	}
+
+	for _, db := range manifest.Databases {
+		dbDir := filepath.Join(dir, filepath.FromSlash(db.Path))
+
+		if err = script("postgres", filepath.Join(dbDir, "pre-data.sql")); err != nil {
+			return err
+		}
+
+		load := filepath.Join(dbDir, "load.sql")
+		if err = writeLoadScript(load, dir, db.Tables); err != nil {
+			return status.ErrPostgres.Wrap(err)
+		}
+		err = script(db.Name, load)
+		_ = os.Remove(load)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range []string{"sequences.sql", "post-data.sql"} {
+			if err = script(db.Name, filepath.Join(dbDir, file)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeLoadScript writes a psql script loading all chunks of table rows
+func writeLoadScript(file, dir string, tables []dumpTable) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+
+	for _, table := range tables {
+		for i := 0; i < table.Chunks; i++ {
+			chunk := filepath.Join(dir, filepath.FromSlash(table.Path), chunkName(i))
+			if _, err = fmt.Fprintf(w, "\\copy %s FROM %s\n", table.Name, quoteLiteral(chunk)); err != nil {
+				_ = f.Close()
+				return err
+			}
+		}
+	}
+
+	if err = w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+func chunkName(i int) string {
+	return fmt.Sprintf("%06d.copy", i)
+}
+
+// splitChunks splits the rows of a table dumped in the COPY text format into chunks, and yields the number of chunks.
+//
+// Chunk boundaries depend on the content of rows: inserting, updating or deleting rows only changes the chunks holding them,
+// so unchanged chunks dedupe as identical blobs.
+func splitChunks(source, dir string) (int, error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	var (
+		chunks int
+		size   int
+		out    *os.File
+		w      *bufio.Writer
+	)
+	closeChunk := func() error {
+		if out == nil {
+			return nil
+		}
+		if err := w.Flush(); err != nil {
+			_ = out.Close()
+			return err
+		}
+		err := out.Close()
+		out, size = nil, 0
+		return err
+	}
+
+	r := bufio.NewReader(in)
+	for {
+		// with the text format, every row is a line: newlines in values are escaped
+		row, readErr := r.ReadBytes('\n')
+		if len(row) > 0 {
+			if out == nil {
+				if out, err = os.Create(filepath.Join(dir, chunkName(chunks))); err != nil {
+					return chunks, err
+				}
+				w = bufio.NewWriter(out)
+				chunks++
+			}
+			if _, err = w.Write(row); err != nil {
+				_ = out.Close()
+				return chunks, err
+			}
+			size += len(row)
+
+			// the high bits of FNV hashes are better distributed than the low bits
+			h := fnv.New32a()
+			_, _ = h.Write(row)
+			if size >= dumpChunkMaxSize || (size >= dumpChunkMinSize && h.Sum32()>>(32-dumpChunkBits) == 0) {
+				if err = closeChunk(); err != nil {
+					return chunks, err
+				}
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			_ = closeChunk()
+			return chunks, readErr
+		}
+	}
+
+	return chunks, closeChunk()
+}
+
+func splitLines(out []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// quoteLiteral quotes a string as a SQL literal
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,222 @@
+package sidecar
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRows(from, to int) []byte {
+	var buf bytes.Buffer
+	for i := from; i < to; i++ {
+		fmt.Fprintf(&buf, "%d\tsome value for row %d\t%s\n", i, i, strings.Repeat("x", i%100))
+	}
+	return buf.Bytes()
+}
+
+func readChunks(t *testing.T, dir string, chunks int) []string {
+	contents := make([]string, 0, chunks)
+	for i := 0; i < chunks; i++ {
+		b, err := ioutil.ReadFile(filepath.Join(dir, chunkName(i)))
+		require.NoError(t, err)
+		contents = append(contents, string(b))
+	}
+	return contents
+}
+
+func TestSplitChunks(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-pg-chunks-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	split := func(name string, rows []byte) []string {
+		source := filepath.Join(tmp, name+".copy")
+		require.NoError(t, ioutil.WriteFile(source, rows, 0600))
+		dir := filepath.Join(tmp, name)
+		require.NoError(t, os.MkdirAll(dir, 0700))
+
+		chunks, err := splitChunks(source, dir)
+		require.NoError(t, err)
+		contents := readChunks(t, dir, chunks)
+		assert.Equal(t, string(rows), strings.Join(contents, ""), "chunks should hold all rows")
+		return contents
+	}
+
+	rows := testRows(0, 100000)
+	original := split("original", rows)
+	require.True(t, len(original) > 5, "expected several chunks, got %d", len(original))
+	for _, chunk := range original[:len(original)-1] {
+		assert.True(t, len(chunk) >= dumpChunkMinSize && len(chunk) <= dumpChunkMaxSize+200)
+	}
+
+	// inserting a row only changes the chunk holding it
+	middle := bytes.Index(rows, []byte("\n50000\t")) + 1
+	inserted := append(append(append([]byte{}, rows[:middle]...), []byte("inserted\trow\t\n")...), rows[middle:]...)
+	updated := split("inserted", inserted)
+
+	unchanged := make(map[string]struct{}, len(original))
+	for _, chunk := range original {
+		unchanged[chunk] = struct{}{}
+	}
+	var changed int
+	for _, chunk := range updated {
+		if _, ok := unchanged[chunk]; !ok {
+			changed++
+		}
+	}
+	assert.True(t, changed <= 2, "expected at most 2 changed chunks, got %d out of %d", changed, len(updated))
+
+	assert.Empty(t, split("empty", nil))
+}
+
+func TestPGDumpRestore(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-pg-dump-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	rows := testRows(0, 50000)
+	var (
+		calls []string
+		load  string
+	)
+	fileArg := func(args []string) string {
+		for i, arg := range args {
+			if arg == "-f" {
+				return args[i+1]
+			}
+		}
+		return ""
+	}
+
+	pg := NewPostgres(PostgresCommandRunner(func(_ context.Context, name string, args ...string) ([]byte, error) {
+		command := name + " " + strings.Join(args, " ")
+		calls = append(calls, command)
+
+		switch {
+		case name == "pg_config":
+			return []byte("PostgreSQL 12.3\n"), nil
+		case name == "pg_dumpall" || name == "pg_dump":
+			return nil, ioutil.WriteFile(fileArg(args), []byte("-- "+command), 0600)
+		case strings.Contains(command, "FROM pg_database"):
+			return []byte("app\n"), nil
+		case strings.Contains(command, "FROM pg_class"):
+			return []byte("public.items" + dumpFieldSeparator + "public.items" + dumpFieldSeparator + "id\n" +
+				`"my schema"."Notes"` + dumpFieldSeparator + "my schema.Notes" + dumpFieldSeparator + "\n"), nil
+		case strings.Contains(command, `\copy`) && strings.Contains(command, " TO "):
+			file := strings.Trim(command[strings.LastIndex(command, " TO ")+4:strings.LastIndex(command, " ")], "'")
+			if strings.Contains(command, "items") {
+				return nil, ioutil.WriteFile(file, rows, 0600)
+			}
+			return nil, ioutil.WriteFile(file, nil, 0600)
+		case strings.HasSuffix(fileArg(args), "load.sql"):
+			b, err := ioutil.ReadFile(fileArg(args))
+			load = string(b)
+			return nil, err
+		}
+		return nil, nil
+	}))
+
+	dir := filepath.Join(tmp, "db.dump")
+	require.NoError(t, pg.Dump(context.Background(), 5430, dir))
+
+	assert.Contains(t, calls, "psql -h localhost -p 5430 -U postgres -t -A -c "+
+		`\copy (SELECT * FROM public.items ORDER BY id) TO '`+filepath.Join(dir, "app", "tables", "public.items.copy")+"' app")
+	assert.Contains(t, calls, "psql -h localhost -p 5430 -U postgres -t -A -c "+
+		`\copy "my schema"."Notes" TO '`+filepath.Join(dir, "app", "tables", "my%20schema.Notes.copy")+"' app")
+
+	manifest, err := readDumpManifest(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "12.3", manifest.Version)
+	require.Len(t, manifest.Databases, 1)
+	db := manifest.Databases[0]
+	assert.Equal(t, "app", db.Name)
+	require.Len(t, db.Tables, 2)
+	assert.Equal(t, "public.items", db.Tables[0].Name)
+	assert.Equal(t, "app/tables/public.items", db.Tables[0].Path)
+	assert.Equal(t, `"my schema"."Notes"`, db.Tables[1].Name)
+	assert.Equal(t, 0, db.Tables[1].Chunks)
+	assert.Equal(t, string(rows), strings.Join(readChunks(t, filepath.Join(dir, "app", "tables", "public.items"), db.Tables[0].Chunks), ""))
+	for _, file := range []string{"globals.sql", "app/pre-data.sql", "app/post-data.sql", "app/sequences.sql"} {
+		assert.FileExists(t, filepath.Join(dir, filepath.FromSlash(file)))
+	}
+	assert.NoFileExists(t, filepath.Join(dir, "app", "tables", "public.items.copy"))
+
+	calls = nil
+	require.NoError(t, pg.Restore(context.Background(), 5430, dir))
+
+	script := "psql -h localhost -p 5430 -U postgres -X -q -v ON_ERROR_STOP=1 -d "
+	assert.Equal(t, []string{
+		"psql -h localhost -p 5430 -U postgres -X -q -d postgres -f " + filepath.Join(dir, "globals.sql"),
+		script + "postgres -f " + filepath.Join(dir, "app", "pre-data.sql"),
+		script + "app -f " + filepath.Join(dir, "app", "load.sql"),
+		script + "app -f " + filepath.Join(dir, "app", "sequences.sql"),
+		script + "app -f " + filepath.Join(dir, "app", "post-data.sql"),
+	}, calls)
+
+	lines := strings.Split(strings.TrimSpace(load), "\n")
+	require.Len(t, lines, db.Tables[0].Chunks)
+	assert.Equal(t, `\copy public.items FROM '`+filepath.Join(dir, "app", "tables", "public.items", chunkName(0))+"'", lines[0])
+	assert.NoFileExists(t, filepath.Join(dir, "app", "load.sql"))
+}
+
+// TestPGDumpWithBinaries dumps then restores a database with local postgres binaries.
+//
+// The test runs when DATAMON_TEST_PG_BINARIES is set, with the postgres command line tools in the PATH:
+//
+//	DATAMON_TEST_PG_BINARIES=1 go test -run TestPGDumpWithBinaries ./pkg/sidecar
+func TestPGDumpWithBinaries(t *testing.T) {
+	if os.Getenv("DATAMON_TEST_PG_BINARIES") == "" {
+		t.Skip("DATAMON_TEST_PG_BINARIES is not set: skipping dump test with local postgres binaries")
+	}
+
+	tmp, err := ioutil.TempDir("", "test-pg-dump-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	const port = 54328
+	ctx := context.Background()
+	pg := NewPostgres()
+	logFile := filepath.Join(tmp, "pg.log")
+
+	psql := func(db, sql string) string {
+		out, erp := exec.Command("psql", "-h", "localhost", "-p", fmt.Sprint(port), "-U", DefaultPGSuperUser,
+			"-t", "-A", "-c", sql, db).Output()
+		require.NoError(t, erp)
+		return strings.TrimSpace(string(out))
+	}
+
+	source, restored, dumpDir := filepath.Join(tmp, "source"), filepath.Join(tmp, "restored"), filepath.Join(tmp, "dump")
+	require.NoError(t, pg.InitDB(ctx, source))
+	require.NoError(t, pg.Start(ctx, source, port, logFile))
+	psql("postgres", "CREATE DATABASE app;")
+	psql("app", `CREATE TABLE items (id serial PRIMARY KEY, label text); CREATE INDEX items_label ON items (label);
+INSERT INTO items (label) SELECT 'item ' || i FROM generate_series(1, 10000) i;`)
+	require.NoError(t, pg.Dump(ctx, port, dumpDir))
+	require.NoError(t, pg.Stop(ctx, source))
+
+	require.NoError(t, pg.InitDB(ctx, restored))
+	require.NoError(t, pg.Start(ctx, restored, port, logFile))
+	defer func() {
+		_ = pg.Stop(ctx, restored)
+	}()
+	require.NoError(t, pg.Restore(ctx, port, dumpDir))
+
+	assert.Equal(t, "10000", psql("app", "SELECT count(*) FROM items;"))
+	assert.Equal(t, "10001", psql("app", "SELECT nextval('items_id_seq');"))
+	assert.Equal(t, "items_label", psql("app", "SELECT indexname FROM pg_indexes WHERE indexname = 'items_label';"))
+}
@@ -0,0 +1,394 @@
+package sidecar
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/oneconcern/datamon/pkg/sidecar/param"
+	"github.com/oneconcern/datamon/pkg/sidecar/status"
+)
+
+// DefaultPGSuperUser is the postgres super user used to operate database servers
+const DefaultPGSuperUser = "postgres"
+
+// DefaultPGVersionBinDir locates the command line tools of some major version of postgres, e.g. for debian packages
+const DefaultPGVersionBinDir = "/usr/lib/postgresql/%s/bin"
+
+// PGFormatAnnotation is the annotation of database bundles recording how databases are stored, e.g. "files" or "dump"
+const PGFormatAnnotation = "pg_format"
+
+// PGVersionAnnotation is the annotation of database bundles recording the major version of postgres which created them
+const PGVersionAnnotation = "pg_version"
+
+// pgVersionFile is saved with database bundles, to recognize the postgres version a bundle was created with
+const pgVersionFile = "pg_version"
+
+// pgEmptyDirs are the directories required by a postgres data directory, which are not saved in bundles when empty
+var pgEmptyDirs = []string{
+	"pg_commit_ts",
+	"pg_dynshmem",
+	"pg_logical/mappings",
+	"pg_logical/snapshots",
+	"pg_notify",
+	"pg_replslot",
+	"pg_serial",
+	"pg_snapshots",
+	"pg_stat",
+	"pg_stat_tmp",
+	"pg_tblspc",
+	"pg_twophase",
+	"pg_wal/archive_status",
+}
+
+var pgVersionRex = regexp.MustCompile(`\s([0-9]+(?:\.[0-9]+)*)`)
+
+type (
+	// Postgres knows how to operate a postgres database server.
+	//
+	// The default implementation runs the postgres command line tools, and may be mocked for tests.
+	Postgres interface {
+		// Version yields the version of the postgres server, e.g. "12.3"
+		Version(ctx context.Context) (string, error)
+
+		// InitDB creates a new database server in a data directory
+		InitDB(ctx context.Context, dataDir string) error
+
+		// Start a database server listening on a port
+		Start(ctx context.Context, dataDir string, port int, logFile string) error
+
+		// CreateOwner creates a super user role, e.g. to own the databases of a new server.
+		// This is a no-op for the postgres super user.
+		CreateOwner(ctx context.Context, port int, owner string) error
+
+		// Quiesce terminates client connections, then vacuums and checkpoints all databases
+		Quiesce(ctx context.Context, port int) error
+
+		// Stop a database server
+		Stop(ctx context.Context, dataDir string) error
+
+		// Migrate the data directory created by an older major version of postgres to the running version.
+		// The command line tools of the older version are required.
+		Migrate(ctx context.Context, migration Migration) error
+
+		// Dump all databases of a running server to a folder, as logical dumps
+		Dump(ctx context.Context, port int, dir string) error
+
+		// Restore the logical dumps saved in a folder to a running server
+		Restore(ctx context.Context, port int, dir string) error
+	}
+
+	// Migration describes how to migrate a data directory to the running version of postgres
+	Migration struct {
+		Method      param.PGMigration
+		FromVersion string // the major version of postgres which created OldDataDir
+		OldDataDir  string
+		NewDataDir  string // an empty data directory, initialized by the running version of postgres
+		Port        int    // the port used by temporary servers
+		LogFile     string
+	}
+
+	// PostgresOption configures the postgres command line tools
+	PostgresOption func(*pgTools)
+
+	// CommandRunner runs a command and yields its standard output
+	CommandRunner func(ctx context.Context, name string, args ...string) ([]byte, error)
+
+	pgTools struct {
+		superUser     string
+		binDir        string
+		versionBinDir string
+		run           CommandRunner
+	}
+)
+
+// PostgresSuperUser sets the postgres super user used to operate database servers
+func PostgresSuperUser(user string) PostgresOption {
+	return func(p *pgTools) {
+		if user != "" {
+			p.superUser = user
+		}
+	}
+}
+
+// PostgresBinDir sets the location of the postgres command line tools. By default, tools are found in the PATH.
+func PostgresBinDir(dir string) PostgresOption {
+	return func(p *pgTools) {
+		p.binDir = dir
+	}
+}
+
+// PostgresVersionBinDir sets the location of the command line tools of older major versions of postgres,
+// used to migrate databases. The pattern is formatted with the major version, e.g. "/usr/lib/postgresql/%s/bin".
+func PostgresVersionBinDir(pattern string) PostgresOption {
+	return func(p *pgTools) {
+		if pattern != "" {
+			p.versionBinDir = pattern
+		}
+	}
+}
+
+// PostgresCommandRunner overrides how postgres commands are run
+func PostgresCommandRunner(runner CommandRunner) PostgresOption {
+	return func(p *pgTools) {
+		if runner != nil {
+			p.run = runner
+		}
+	}
+}
+
+// NewPostgres builds a postgres driver running the postgres command line tools
+func NewPostgres(opts ...PostgresOption) Postgres {
+	p := &pgTools{
+		superUser:     DefaultPGSuperUser,
+		versionBinDir: DefaultPGVersionBinDir,
+		run:           runCommand,
+	}
+
+	for _, apply := range opts {
+		apply(p)
+	}
+
+	return p
+}
+
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return out, fmt.Errorf("%s %s: %w: %s", name, strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
+
+func (p *pgTools) cmd(ctx context.Context, name string, args ...string) ([]byte, error) {
+	if p.binDir != "" {
+		name = filepath.Join(p.binDir, name)
+	}
+
+	out, err := p.run(ctx, name, args...)
+	if err != nil {
+		return out, status.ErrPostgres.Wrap(err)
+	}
+
+	return out, nil
+}
+
+// versionCmd runs a command line tool of some major version of postgres
+func (p *pgTools) versionCmd(ctx context.Context, version, name string, args ...string) ([]byte, error) {
+	out, err := p.run(ctx, filepath.Join(fmt.Sprintf(p.versionBinDir, version), name), args...)
+	if err != nil {
+		return out, status.ErrPostgres.Wrap(err)
+	}
+
+	return out, nil
+}
+
+func (p *pgTools) psql(ctx context.Context, port int, db, sql string) ([]byte, error) {
+	args := []string{"-h", "localhost", "-p", strconv.Itoa(port), "-U", p.superUser, "-t", "-A", "-c", sql}
+	if db != "" {
+		args = append(args, db)
+	}
+
+	return p.cmd(ctx, "psql", args...)
+}
+
+func (p *pgTools) Version(ctx context.Context) (string, error) {
+	out, err := p.cmd(ctx, "pg_config", "--version")
+	if err != nil {
+		return "", err
+	}
+
+	// e.g. "PostgreSQL 12.3"
+	matches := pgVersionRex.FindStringSubmatch(string(out))
+	if len(matches) < 2 {
+		return "", status.ErrPostgres.WrapMessage("unexpected postgres version: %q", strings.TrimSpace(string(out)))
+	}
+
+	return matches[1], nil
+}
+
+func (p *pgTools) InitDB(ctx context.Context, dataDir string) error {
+	_, err := p.cmd(ctx, "initdb", "--no-locale", "--encoding", "UTF8", "-D", dataDir, "-U", p.superUser)
+	return err
+}
+
+func (p *pgTools) Start(ctx context.Context, dataDir string, port int, logFile string) error {
+	_, err := p.cmd(ctx, "pg_ctl", "-D", dataDir, "--options", "-p "+strconv.Itoa(port), "--log", logFile, "--wait", "start")
+	return err
+}
+
+func (p *pgTools) CreateOwner(ctx context.Context, port int, owner string) error {
+	if owner == "" || owner == p.superUser {
+		return nil
+	}
+
+	_, err := p.cmd(ctx, "createuser", "-h", "localhost", "-p", strconv.Itoa(port), "-s", "-U", p.superUser, owner)
+	return err
+}
+
+func (p *pgTools) Quiesce(ctx context.Context, port int) error {
+	if _, err := p.psql(ctx, port, "",
+		"SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE datname != 'postgres' AND pid != pg_backend_pid();",
+	); err != nil {
+		return err
+	}
+
+	out, err := p.psql(ctx, port, "", "SELECT datname FROM pg_database WHERE NOT datistemplate AND datname != 'postgres';")
+	if err != nil {
+		return err
+	}
+
+	for _, db := range strings.Fields(string(out)) {
+		// VACUUM is a postgres-specific SQL addition, which shrinks the files of a database
+		if _, err := p.psql(ctx, port, db, "VACUUM;"); err != nil {
+			return err
+		}
+		if _, err := p.psql(ctx, port, db, "CHECKPOINT;"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *pgTools) Stop(ctx context.Context, dataDir string) error {
+	_, err := p.cmd(ctx, "pg_ctl", "-D", dataDir, "-m", "immediate", "--wait", "stop")
+	return err
+}
+
+func (p *pgTools) Migrate(ctx context.Context, migration Migration) error {
+	from := migration.FromVersion
+	port := strconv.Itoa(migration.Port)
+
+	// start then stop the older server, so the data directory is cleanly shut down, as required by pg_upgrade
+	startOld := func() error {
+		_, err := p.versionCmd(ctx, from, "pg_ctl", "-D", migration.OldDataDir, "--options", "-p "+port,
+			"--log", migration.LogFile, "--wait", "start")
+		return err
+	}
+	stopOld := func() error {
+		_, err := p.versionCmd(ctx, from, "pg_ctl", "-D", migration.OldDataDir, "-m", "fast", "--wait", "stop")
+		return err
+	}
+
+	if err := startOld(); err != nil {
+		return err
+	}
+
+	if migration.Method == param.PGMigrationUpgrade {
+		if err := stopOld(); err != nil {
+			return err
+		}
+
+		args := []string{
+			"--old-bindir", fmt.Sprintf(p.versionBinDir, from),
+			"--old-datadir", migration.OldDataDir,
+			"--new-datadir", migration.NewDataDir,
+			"--old-port", port,
+			"--new-port", port,
+			"--username", p.superUser,
+		}
+		if p.binDir != "" {
+			args = append(args, "--new-bindir", p.binDir)
+		}
+		_, err := p.cmd(ctx, "pg_upgrade", args...)
+		return err
+	}
+
+	// dump all databases and roles with the tools of the running version, which know how to read older servers
+	dump := migration.NewDataDir + ".dump.sql"
+	defer func() {
+		_ = os.Remove(dump)
+	}()
+
+	_, err := p.cmd(ctx, "pg_dumpall", "-h", "localhost", "-p", port, "-U", p.superUser, "-f", dump)
+	if ers := stopOld(); err == nil {
+		err = ers
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = p.Start(ctx, migration.NewDataDir, migration.Port, migration.LogFile); err != nil {
+		return err
+	}
+
+	// the dump recreates the super user role, which already exists: errors are not fatal
+	_, err = p.cmd(ctx, "psql", "-h", "localhost", "-p", port, "-U", p.superUser, "-X", "-q", "-d", "postgres", "-f", dump)
+	_, ers := p.cmd(ctx, "pg_ctl", "-D", migration.NewDataDir, "-m", "fast", "--wait", "stop")
+	if err == nil {
+		err = ers
+	}
+
+	return err
+}
+
+// restorePGDataDir recreates the empty directories required by postgres, which are not saved in bundles,
+// and restricts the permissions of a data directory as required by postgres
+func restorePGDataDir(dataDir string) error {
+	for _, dir := range pgEmptyDirs {
+		if err := os.MkdirAll(filepath.Join(dataDir, filepath.FromSlash(dir)), 0700); err != nil {
+			return err
+		}
+	}
+
+	return filepath.Walk(dataDir, func(pth string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chmod(pth, info.Mode().Perm()&^0007)
+	})
+}
+
+// storedPGVersion yields the major version of postgres which created a data directory.
+//
+// The PG_VERSION file maintained by postgres takes precedence over the version saved by the sidecar.
+func storedPGVersion(dataDir string) (string, error) {
+	saved, err := ioutil.ReadFile(filepath.Join(dataDir, "PG_VERSION"))
+	if os.IsNotExist(err) {
+		saved, err = ioutil.ReadFile(filepath.Join(dataDir, pgVersionFile))
+	}
+	if err != nil {
+		return "", status.ErrPGVersion.WrapMessage("could not read the postgres version of the database bundle: %v", err)
+	}
+
+	return pgMajorVersion(string(saved)), nil
+}
+
+// pgMajorVersion yields the major version from a postgres version, e.g. "12" for "12.3".
+//
+// Major versions prior to postgres 10 have two components, e.g. "9.6" for "9.6.17".
+func pgMajorVersion(version string) string {
+	parts := strings.SplitN(strings.TrimSpace(version), ".", 3)
+	if len(parts) > 1 {
+		if major, err := strconv.Atoi(parts[0]); err == nil && major < 10 {
+			return parts[0] + "." + parts[1]
+		}
+	}
+	return parts[0]
+}
+
+// pgOlderVersion tells if a major version of postgres is older than another one
+func pgOlderVersion(version, than string) bool {
+	v, err := strconv.ParseFloat(version, 64)
+	if err != nil {
+		return false
+	}
+	w, err := strconv.ParseFloat(than, 64)
+	if err != nil {
+		return false
+	}
+	return v < w
+}
@@ -0,0 +1,156 @@
+package sidecar
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/oneconcern/datamon/pkg/sidecar/param"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPGMajorVersion(t *testing.T) {
+	assert.Equal(t, "12", pgMajorVersion("12.3\n"))
+	assert.Equal(t, "13", pgMajorVersion("13"))
+	assert.Equal(t, "9.6", pgMajorVersion("9.6.17"))
+
+	assert.True(t, pgOlderVersion("9.6", "12"))
+	assert.True(t, pgOlderVersion("11", "12"))
+	assert.False(t, pgOlderVersion("13", "12"))
+	assert.False(t, pgOlderVersion("unknown", "12"))
+}
+
+func TestPGMigrate(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	pg := NewPostgres(
+		PostgresVersionBinDir("/pg/%s/bin"),
+		PostgresCommandRunner(func(_ context.Context, name string, args ...string) ([]byte, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls = append(calls, name+" "+strings.Join(args, " "))
+			return nil, nil
+		}),
+	)
+
+	migration := Migration{
+		FromVersion: "11",
+		OldDataDir:  "/data/db.pg11",
+		NewDataDir:  "/data/db",
+		Port:        5430,
+		LogFile:     "/logs/migrate.log",
+	}
+
+	t.Run("with pg_upgrade", func(t *testing.T) {
+		calls = nil
+		migration.Method = param.PGMigrationUpgrade
+		require.NoError(t, pg.Migrate(context.Background(), migration))
+		assert.Equal(t, []string{
+			"/pg/11/bin/pg_ctl -D /data/db.pg11 --options -p 5430 --log /logs/migrate.log --wait start",
+			"/pg/11/bin/pg_ctl -D /data/db.pg11 -m fast --wait stop",
+			"pg_upgrade --old-bindir /pg/11/bin --old-datadir /data/db.pg11 --new-datadir /data/db " +
+				"--old-port 5430 --new-port 5430 --username postgres",
+		}, calls)
+	})
+
+	t.Run("with dump and restore", func(t *testing.T) {
+		calls = nil
+		migration.Method = param.PGMigrationDump
+		require.NoError(t, pg.Migrate(context.Background(), migration))
+		assert.Equal(t, []string{
+			"/pg/11/bin/pg_ctl -D /data/db.pg11 --options -p 5430 --log /logs/migrate.log --wait start",
+			"pg_dumpall -h localhost -p 5430 -U postgres -f /data/db.dump.sql",
+			"/pg/11/bin/pg_ctl -D /data/db.pg11 -m fast --wait stop",
+			"pg_ctl -D /data/db --options -p 5430 --log /logs/migrate.log --wait start",
+			"psql -h localhost -p 5430 -U postgres -X -q -d postgres -f /data/db.dump.sql",
+			"pg_ctl -D /data/db -m fast --wait stop",
+		}, calls)
+	})
+}
+
+// TestPGMigrateWithBinaries migrates a database with local postgres binaries.
+//
+// The test runs when DATAMON_TEST_PG_MIGRATE_FROM is set to some older major version of postgres,
+// which command line tools are installed as well as the tools of the running version, e.g. for debian packages:
+//
+//	DATAMON_TEST_PG_MIGRATE_FROM=11 go test -run TestPGMigrateWithBinaries ./pkg/sidecar
+func TestPGMigrateWithBinaries(t *testing.T) {
+	from := os.Getenv("DATAMON_TEST_PG_MIGRATE_FROM")
+	if from == "" {
+		t.Skip("DATAMON_TEST_PG_MIGRATE_FROM is not set: skipping migration test with local postgres binaries")
+	}
+
+	pattern := os.Getenv("DATAMON_TEST_PG_VERSION_BIN_DIR")
+	if pattern == "" {
+		pattern = DefaultPGVersionBinDir
+	}
+	oldBinDir := fmt.Sprintf(pattern, from)
+	if _, err := os.Stat(filepath.Join(oldBinDir, "pg_ctl")); err != nil {
+		t.Skipf("postgres %s is not installed in %s", from, oldBinDir)
+	}
+
+	const port = 54329
+	ctx := context.Background()
+	pg := NewPostgres(PostgresVersionBinDir(pattern))
+
+	for _, method := range []param.PGMigration{param.PGMigrationUpgrade, param.PGMigrationDump} {
+		t.Run(string(method), func(t *testing.T) {
+			tmp, err := ioutil.TempDir("", "test-pg-migrate-")
+			require.NoError(t, err)
+			defer func() {
+				_ = os.RemoveAll(tmp)
+			}()
+			oldDir, newDir, logFile := filepath.Join(tmp, "old"), filepath.Join(tmp, "new"), filepath.Join(tmp, "pg.log")
+
+			// create a database with the older version
+			psql := func(bin, sql string) string {
+				out, erp := exec.Command(filepath.Join(bin, "psql"),
+					"-h", "localhost", "-p", fmt.Sprint(port), "-U", DefaultPGSuperUser, "-t", "-A", "-c", sql).Output()
+				require.NoError(t, erp)
+				return strings.TrimSpace(string(out))
+			}
+			pgCtl := func(bin, dir string, args ...string) {
+				out, erc := exec.Command(filepath.Join(bin, "pg_ctl"), append([]string{"-D", dir}, args...)...).CombinedOutput()
+				require.NoError(t, erc, string(out))
+			}
+
+			out, err := exec.Command(filepath.Join(oldBinDir, "initdb"), "-D", oldDir, "-U", DefaultPGSuperUser).CombinedOutput()
+			require.NoError(t, err, string(out))
+			pgCtl(oldBinDir, oldDir, "--options", fmt.Sprintf("-p %d", port), "--log", logFile, "--wait", "start")
+			psql(oldBinDir, "CREATE TABLE migrated (id int); INSERT INTO migrated VALUES (42);")
+			pgCtl(oldBinDir, oldDir, "-m", "immediate", "--wait", "stop")
+
+			// migrate to the running version
+			require.NoError(t, pg.InitDB(ctx, newDir))
+			require.NoError(t, pg.Migrate(ctx, Migration{
+				Method:      method,
+				FromVersion: from,
+				OldDataDir:  oldDir,
+				NewDataDir:  newDir,
+				Port:        port,
+				LogFile:     logFile,
+			}))
+
+			version, err := pg.Version(ctx)
+			require.NoError(t, err)
+			stored, err := storedPGVersion(newDir)
+			require.NoError(t, err)
+			assert.Equal(t, pgMajorVersion(version), stored)
+
+			require.NoError(t, pg.Start(ctx, newDir, port, logFile))
+			defer func() {
+				_ = pg.Stop(ctx, newDir)
+			}()
+			assert.Equal(t, "42", psql("", "SELECT id FROM migrated;"))
+		})
+	}
+}
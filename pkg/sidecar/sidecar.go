@@ -0,0 +1,810 @@
+package sidecar
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/dlogger"
+	"github.com/oneconcern/datamon/pkg/sidecar/param"
+	"github.com/oneconcern/datamon/pkg/sidecar/status"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// Types of sidecars
+const (
+	TypeFUSE     = "fuse"
+	TypePostgres = "postgres"
+	TypeSpec     = "spec" // inputs and outputs declared by a specification
+)
+
+const (
+	// DefaultPGDataDir is the default root directory for the files of database servers
+	DefaultPGDataDir = "/pg_stage"
+
+	// DefaultLogDir is the default directory for the logs of database servers
+	DefaultLogDir = "/tmp/sidecar-logs"
+)
+
+type (
+	// Sidecar coordinates with an application wrapper to provide bundles as input, and save the output
+	// of the application as bundles.
+	Sidecar struct {
+		name         string
+		actions      Actions
+		pg           Postgres
+		l            *zap.Logger
+		pollInterval time.Duration
+		pgDataDir    string
+		logDir       string
+		sleepTimeout time.Duration
+	}
+
+	// Option configures the sidecar
+	Option func(*Sidecar)
+)
+
+// WithLogger sets a logger for the sidecar
+func WithLogger(l *zap.Logger) Option {
+	return func(s *Sidecar) {
+		if l != nil {
+			s.l = l
+		}
+	}
+}
+
+// WithName sets the name of the sidecar, which identifies its state file on the coordination point.
+//
+// The default name is the type of sidecar: "fuse" or "postgres".
+func WithName(name string) Option {
+	return func(s *Sidecar) {
+		s.name = name
+	}
+}
+
+// WithPollInterval sets the interval between two checks for signaling files
+func WithPollInterval(interval time.Duration) Option {
+	return func(s *Sidecar) {
+		if interval > 0 {
+			s.pollInterval = interval
+		}
+	}
+}
+
+// WithPostgres sets the driver operating database servers
+func WithPostgres(pg Postgres) Option {
+	return func(s *Sidecar) {
+		if pg != nil {
+			s.pg = pg
+		}
+	}
+}
+
+// WithPGDataDir sets the root directory for the files of database servers, when not specified by a database
+func WithPGDataDir(dir string) Option {
+	return func(s *Sidecar) {
+		if dir != "" {
+			s.pgDataDir = dir
+		}
+	}
+}
+
+// WithLogDir sets the directory for the logs of database servers
+func WithLogDir(dir string) Option {
+	return func(s *Sidecar) {
+		if dir != "" {
+			s.logDir = dir
+		}
+	}
+}
+
+// WithSleepTimeout limits how long the sidecar sleeps when instructed to sleep instead of exiting.
+//
+// The default (0) is to sleep until the context is canceled.
+func WithSleepTimeout(timeout time.Duration) Option {
+	return func(s *Sidecar) {
+		s.sleepTimeout = timeout
+	}
+}
+
+// New builds a sidecar carrying out datamon actions
+func New(actions Actions, opts ...Option) *Sidecar {
+	s := &Sidecar{
+		actions:      actions,
+		l:            dlogger.MustGetLogger("info"),
+		pollInterval: DefaultPollInterval,
+		pgDataDir:    DefaultPGDataDir,
+		logDir:       DefaultLogDir,
+	}
+
+	for _, apply := range opts {
+		apply(s)
+	}
+
+	if s.pg == nil {
+		s.pg = NewPostgres()
+	}
+
+	return s
+}
+
+// RunFUSE mounts the source bundles, then waits for the application to complete before uploading
+// the destination bundles.
+//
+// The sequence of events on the coordination point is:
+//   - the sidecar emits "mountdone" when all source bundles are mounted
+//   - the application wrapper emits "initupload" when the application is done
+//   - the sidecar emits "uploaddone" when all destination bundles are uploaded
+//
+// The state of the sidecar is published on the coordination point (see Coordinator.Wait).
+func (s *Sidecar) RunFUSE(ctx context.Context, params param.FUSEParams) error {
+	if params.Globals.CoordPoint == "" {
+		return status.ErrInvalidParams.WrapMessage("a coordination point is required")
+	}
+
+	coord := NewCoordinator(params.Globals.CoordPoint, s.pollInterval)
+	tracker := newStateTracker(coord, s.nameOr(TypeFUSE), TypeFUSE)
+	if err := tracker.advance(StatusStarting); err != nil {
+		return err
+	}
+
+	if err := s.runFUSE(ctx, coord, tracker, params); err != nil {
+		return tracker.fail(err)
+	}
+
+	if err := tracker.advance(StatusDone); err != nil {
+		return err
+	}
+
+	s.l.Info("fuse sidecar done")
+
+	if params.Globals.SleepInsteadOfExit {
+		s.sleep(ctx)
+	}
+
+	return nil
+}
+
+func (s *Sidecar) runFUSE(ctx context.Context, coord *Coordinator, tracker *stateTracker, params param.FUSEParams) error {
+	if err := validateFUSEParams(params); err != nil {
+		return err
+	}
+
+	for _, bundle := range params.Bundles {
+		if err := checkBundleIDFile(bundle.DestBundleID); err != nil {
+			return err
+		}
+	}
+
+	unmounters := make([]func() error, 0, len(params.Bundles))
+	unmountAll := func() error {
+		var firstErr error
+		for _, unmount := range unmounters {
+			if err := unmount(); err != nil {
+				s.l.Warn("could not unmount bundle", zap.Error(err))
+				if firstErr == nil {
+					firstErr = status.ErrMount.Wrap(err)
+				}
+			}
+		}
+		unmounters = unmounters[:0]
+		return firstErr
+	}
+	defer func() {
+		_ = unmountAll()
+	}()
+
+	for _, bundle := range params.Bundles {
+		if bundle.SrcPath == "" {
+			continue
+		}
+
+		s.l.Info("mounting bundle", zap.String("name", bundle.Name), zap.String("path", bundle.SrcPath))
+		unmount, err := s.actions.Mount(ctx, bundle.SrcPath, Source{
+			Repo:     bundle.SrcRepo,
+			Label:    bundle.SrcLabel,
+			BundleID: bundle.SrcBundle,
+		})
+		if err != nil {
+			return err
+		}
+		unmounters = append(unmounters, unmount)
+	}
+
+	if err := coord.Emit(EventMountDone); err != nil {
+		return err
+	}
+
+	if err := tracker.advance(StatusReady); err != nil {
+		return err
+	}
+
+	s.l.Info("waiting for application to complete", zap.String("coord_point", coord.Point()))
+	if err := coord.Await(ctx, EventInitUpload); err != nil {
+		return err
+	}
+
+	if err := tracker.advance(StatusUploading); err != nil {
+		return err
+	}
+
+	if err := unmountAll(); err != nil {
+		return err
+	}
+
+	for _, bundle := range params.Bundles {
+		if bundle.DestPath == "" {
+			continue
+		}
+
+		s.l.Info("uploading bundle", zap.String("name", bundle.Name), zap.String("path", bundle.DestPath))
+		bundleID, err := s.actions.Upload(ctx, bundle.DestPath, Destination{
+			Repo:    bundle.DestRepo,
+			Message: bundle.DestMessage,
+			Label:   bundle.DestLabel,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := writeBundleIDFile(bundle.DestBundleID, bundleID); err != nil {
+			return err
+		}
+	}
+
+	return coord.Emit(EventUploadDone)
+}
+
+// RunPG starts database servers, from source bundles or from scratch, then waits for the application to complete
+// before uploading the databases as bundles.
+//
+// Databases are operated independently. For each database, the sequence of events
+// in the database folder of the coordination point is:
+//   - the sidecar emits "dbstarted" when the database server is started
+//   - the application wrapper emits "initdbupload" when the application is done with the database
+//   - the sidecar emits "dbuploaddone" when the database is uploaded
+//
+// The state of the sidecar is published on the coordination point (see Coordinator.Wait). The sidecar is ready
+// when all database servers are started.
+func (s *Sidecar) RunPG(ctx context.Context, params param.PGParams) error {
+	if params.Globals.CoordPoint == "" {
+		return status.ErrInvalidParams.WrapMessage("a coordination point is required")
+	}
+
+	coord := NewCoordinator(params.Globals.CoordPoint, s.pollInterval)
+	tracker := newStateTracker(coord, s.nameOr(TypePostgres), TypePostgres)
+	if err := tracker.advance(StatusStarting); err != nil {
+		return err
+	}
+
+	if err := s.runPG(ctx, coord, tracker, params); err != nil {
+		return tracker.fail(err)
+	}
+
+	if err := tracker.advance(StatusDone); err != nil {
+		return err
+	}
+
+	s.l.Info("postgres sidecar done")
+
+	if params.Globals.SleepInsteadOfExit {
+		s.sleep(ctx)
+	}
+
+	return nil
+}
+
+func (s *Sidecar) runPG(ctx context.Context, coord *Coordinator, tracker *stateTracker, params param.PGParams) error {
+	if err := validatePGParams(params); err != nil {
+		return err
+	}
+
+	for _, db := range params.Databases {
+		if err := checkBundleIDFile(db.DestBundleID); err != nil {
+			return err
+		}
+	}
+
+	version, err := s.pg.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	group, gctx := errgroup.WithContext(ctx)
+	progress := &dbProgress{
+		tracker: tracker,
+		total:   int32(len(params.Databases)),
+	}
+	if progress.total == 0 {
+		if err = tracker.advance(StatusReady); err != nil {
+			return err
+		}
+	}
+
+	for _, toPin := range params.Databases {
+		db := database{
+			name:         toPin.Name,
+			port:         toPin.Port,
+			dataDir:      toPin.DataDir,
+			owner:        toPin.Owner,
+			bundleIDFile: toPin.DestBundleID,
+			format:       toPin.DestFormat,
+			source: Source{
+				Repo:     toPin.SrcRepo,
+				Label:    toPin.SrcLabel,
+				BundleID: toPin.SrcBundle,
+			},
+			destination: Destination{
+				Repo:    toPin.DestRepo,
+				Message: toPin.DestMessage,
+				Label:   toPin.DestLabel,
+			},
+		}
+
+		settings := pgSettings{
+			version:        version,
+			ignoreMismatch: params.Globals.IgnorePGVersionMismatch,
+			migration:      params.Globals.PGMigration,
+		}
+		group.Go(func() error {
+			return s.runDB(gctx, coord, progress, db, settings)
+		})
+	}
+
+	return group.Wait()
+}
+
+// dbProgress tracks the state of a sidecar operating several database servers
+type dbProgress struct {
+	tracker *stateTracker
+	total   int32
+	started int32
+}
+
+// start reports a started database server. The sidecar is ready when all servers are started.
+func (p *dbProgress) start() error {
+	if atomic.AddInt32(&p.started, 1) < p.total {
+		return nil
+	}
+	return p.tracker.advance(StatusReady)
+}
+
+// upload reports a database being uploaded
+func (p *dbProgress) upload() error {
+	if atomic.LoadInt32(&p.started) < p.total {
+		return nil
+	}
+	return p.tracker.advance(StatusUploading)
+}
+
+// pgSettings tells how to operate database servers
+type pgSettings struct {
+	version        string // the version of the running postgres
+	ignoreMismatch bool
+	migration      param.PGMigration
+}
+
+// annotations of database bundles, recording the major version of postgres which created them and their format
+func (p pgSettings) annotations(dump bool) map[string]string {
+	format := param.PGFormatFiles
+	if dump {
+		format = param.PGFormatDump
+	}
+
+	return map[string]string{
+		PGVersionAnnotation: pgMajorVersion(p.version),
+		PGFormatAnnotation:  string(format),
+	}
+}
+
+type database struct {
+	name         string
+	port         int
+	dataDir      string
+	owner        string
+	bundleIDFile string
+	format       param.PGFormat
+	source       Source
+	destination  Destination
+}
+
+func (s *Sidecar) runDB(ctx context.Context, coord *Coordinator, progress *dbProgress, db database, settings pgSettings) (err error) {
+	logger := s.l.With(zap.String("database", db.name), zap.Int("port", db.port))
+
+	dataDir, err := s.startDB(ctx, logger, db, settings)
+	if err != nil {
+		return err
+	}
+	started := true
+	defer func() {
+		if !started {
+			return
+		}
+		// stop the server on failure
+		if ers := s.pg.Stop(context.Background(), dataDir); ers != nil {
+			logger.Warn("could not stop database server", zap.Error(ers))
+		}
+	}()
+
+	if err = coord.Emit(EventDBStarted, db.name); err != nil {
+		return err
+	}
+
+	if err = progress.start(); err != nil {
+		return err
+	}
+
+	logger.Info("waiting for application to complete", zap.String("coord_point", coord.Point()))
+	if err = coord.Await(ctx, EventInitDBUpload, db.name); err != nil {
+		return err
+	}
+
+	if err = progress.upload(); err != nil {
+		return err
+	}
+
+	started = false
+	dump := db.destination.Repo != "" && db.format == param.PGFormatDump
+	saveDir, err := s.stopDB(ctx, db, dataDir, dump)
+	if err != nil {
+		return err
+	}
+
+	if db.destination.Repo != "" {
+		logger.Info("uploading database", zap.String("repo", db.destination.Repo))
+		destination := db.destination
+		destination.Annotations = settings.annotations(dump)
+		bundleID, eru := s.actions.Upload(ctx, saveDir, destination)
+		if eru != nil {
+			return eru
+		}
+
+		if err = writeBundleIDFile(db.bundleIDFile, bundleID); err != nil {
+			return err
+		}
+	}
+
+	return coord.Emit(EventDBUploadDone, db.name)
+}
+
+// startDB prepares the data directory of a database, restored from a bundle or created from scratch,
+// then starts a database server. It yields the data directory.
+func (s *Sidecar) startDB(ctx context.Context, logger *zap.Logger, db database, settings pgSettings) (string, error) {
+	root := db.dataDir
+	if root == "" {
+		root = s.pgDataDir
+	}
+	dataDir := filepath.Join(root, db.name)
+
+	if err := os.RemoveAll(dataDir); err != nil {
+		return "", status.ErrPostgres.Wrap(err)
+	}
+	if err := os.MkdirAll(dataDir, 0750); err != nil {
+		return "", status.ErrPostgres.Wrap(err)
+	}
+
+	var dumpDir string // the logical dumps to restore
+	if db.source.Repo != "" {
+		logger.Info("restoring database from bundle", zap.String("repo", db.source.Repo))
+		if err := s.actions.Download(ctx, dataDir, db.source); err != nil {
+			return "", err
+		}
+
+		if isDump(dataDir) {
+			// logical dumps are restored to a new server, once started
+			dumpDir = dataDir + ".dump"
+			if err := os.RemoveAll(dumpDir); err != nil {
+				return "", status.ErrPostgres.Wrap(err)
+			}
+			if err := os.Rename(dataDir, dumpDir); err != nil {
+				return "", status.ErrPostgres.Wrap(err)
+			}
+			if err := os.MkdirAll(dataDir, 0750); err != nil {
+				return "", status.ErrPostgres.Wrap(err)
+			}
+		} else {
+			if err := restorePGDataDir(dataDir); err != nil {
+				return "", status.ErrPostgres.Wrap(err)
+			}
+
+			if err := s.checkDB(ctx, logger, db, dataDir, settings); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if db.source.Repo == "" || dumpDir != "" {
+		logger.Info("creating new database")
+		if err := s.pg.InitDB(ctx, dataDir); err != nil {
+			return "", err
+		}
+
+		if err := ioutil.WriteFile(filepath.Join(dataDir, pgVersionFile), []byte(settings.version+"\n"), 0600); err != nil {
+			return "", status.ErrPostgres.Wrap(err)
+		}
+	}
+
+	if err := os.MkdirAll(s.logDir, 0755); err != nil {
+		return "", status.ErrPostgres.Wrap(err)
+	}
+
+	if err := s.pg.Start(ctx, dataDir, db.port, filepath.Join(s.logDir, "pg."+db.name+".log")); err != nil {
+		return "", err
+	}
+
+	var err error
+	switch {
+	case dumpDir != "":
+		logger.Info("restoring logical dumps")
+		if err = s.pg.Restore(ctx, db.port, dumpDir); err == nil {
+			err = os.RemoveAll(dumpDir)
+			if err != nil {
+				err = status.ErrPostgres.Wrap(err)
+			}
+		}
+	case db.source.Repo == "":
+		err = s.pg.CreateOwner(ctx, db.port, db.owner)
+	}
+
+	if err != nil {
+		if ers := s.pg.Stop(context.Background(), dataDir); ers != nil {
+			logger.Warn("could not stop database server", zap.Error(ers))
+		}
+		return "", err
+	}
+
+	return dataDir, nil
+}
+
+// checkDB checks that a restored data directory was created by the running major version of postgres,
+// and migrates it when configured to do so
+func (s *Sidecar) checkDB(ctx context.Context, logger *zap.Logger, db database, dataDir string, settings pgSettings) error {
+	stored, err := storedPGVersion(dataDir)
+	if err != nil {
+		return err
+	}
+
+	running := pgMajorVersion(settings.version)
+	if stored == running {
+		return nil
+	}
+
+	if settings.migration == param.PGMigrationNone || !pgOlderVersion(stored, running) {
+		err = status.ErrPGVersion.WrapMessage("database bundle created with postgres %s, running postgres %s", stored, running)
+		if !settings.ignoreMismatch {
+			return err
+		}
+		logger.Warn("ignoring postgres version mismatch", zap.Error(err))
+		return nil
+	}
+
+	logger.Info("migrating database",
+		zap.String("from_version", stored),
+		zap.String("to_version", running),
+		zap.String("method", string(settings.migration)),
+	)
+
+	oldDataDir := dataDir + ".pg" + stored
+	if err = os.RemoveAll(oldDataDir); err != nil {
+		return status.ErrPostgres.Wrap(err)
+	}
+	if err = os.Rename(dataDir, oldDataDir); err != nil {
+		return status.ErrPostgres.Wrap(err)
+	}
+	if err = os.MkdirAll(dataDir, 0750); err != nil {
+		return status.ErrPostgres.Wrap(err)
+	}
+	if err = os.MkdirAll(s.logDir, 0755); err != nil {
+		return status.ErrPostgres.Wrap(err)
+	}
+
+	if err = s.pg.InitDB(ctx, dataDir); err != nil {
+		return err
+	}
+
+	if err = s.pg.Migrate(ctx, Migration{
+		Method:      settings.migration,
+		FromVersion: stored,
+		OldDataDir:  oldDataDir,
+		NewDataDir:  dataDir,
+		Port:        db.port,
+		LogFile:     filepath.Join(s.logDir, "pg."+db.name+".migrate.log"),
+	}); err != nil {
+		return status.ErrPGVersion.WrapMessage("could not migrate database from postgres %s to %s: %v", stored, running, err)
+	}
+
+	if err = ioutil.WriteFile(filepath.Join(dataDir, pgVersionFile), []byte(settings.version+"\n"), 0600); err != nil {
+		return status.ErrPostgres.Wrap(err)
+	}
+
+	if err = os.RemoveAll(oldDataDir); err != nil {
+		return status.ErrPostgres.Wrap(err)
+	}
+
+	return nil
+}
+
+// stopDB quiesces then stops a database server, so its data directory may be saved.
+// When dumped, databases are saved as logical dumps before the server is stopped.
+// It yields the folder to save.
+func (s *Sidecar) stopDB(ctx context.Context, db database, dataDir string, dump bool) (string, error) {
+	saveDir := dataDir
+
+	err := s.pg.Quiesce(ctx, db.port)
+	if err == nil && dump {
+		saveDir = dataDir + ".dump"
+		if err = os.RemoveAll(saveDir); err != nil {
+			err = status.ErrPostgres.Wrap(err)
+		} else {
+			err = s.pg.Dump(ctx, db.port, saveDir)
+		}
+	}
+	if err != nil {
+		if ers := s.pg.Stop(context.Background(), dataDir); ers != nil {
+			s.l.Warn("could not stop database server", zap.String("database", db.name), zap.Error(ers))
+		}
+		return "", err
+	}
+
+	if err = s.pg.Stop(ctx, dataDir); err != nil {
+		return "", err
+	}
+
+	return saveDir, nil
+}
+
+func (s *Sidecar) nameOr(kind string) string {
+	if s.name != "" {
+		return s.name
+	}
+	return kind
+}
+
+func (s *Sidecar) sleep(ctx context.Context) {
+	s.l.Info("sleeping instead of exiting", zap.Duration("timeout", s.sleepTimeout))
+
+	if s.sleepTimeout <= 0 {
+		<-ctx.Done()
+		return
+	}
+
+	timer := time.NewTimer(s.sleepTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+func validateFUSEParams(params param.FUSEParams) error {
+	if params.Globals.CoordPoint == "" {
+		return status.ErrInvalidParams.WrapMessage("a coordination point is required")
+	}
+
+	for _, bundle := range params.Bundles {
+		isSource := bundle.SrcPath != ""
+		isDest := bundle.DestPath != ""
+
+		switch {
+		case isSource && isDest:
+			return status.ErrInvalidParams.WrapMessage("bundle %q may not be both a source and a destination", bundle.Name)
+		case !isSource && !isDest:
+			return status.ErrInvalidParams.WrapMessage("bundle %q must be either a source or a destination", bundle.Name)
+		case isSource:
+			if err := validateSource(bundle.Name, bundle.SrcRepo, bundle.SrcLabel, bundle.SrcBundle); err != nil {
+				return err
+			}
+			if _, err := os.Stat(bundle.SrcPath); err != nil {
+				return status.ErrInvalidParams.WrapMessage("mount point for bundle %q: %v", bundle.Name, err)
+			}
+		default:
+			if bundle.DestRepo == "" || bundle.DestMessage == "" {
+				return status.ErrInvalidParams.WrapMessage("destination bundle %q requires a repo and a message", bundle.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validatePGParams(params param.PGParams) error {
+	if params.Globals.CoordPoint == "" {
+		return status.ErrInvalidParams.WrapMessage("a coordination point is required")
+	}
+
+	names := make(map[string]struct{}, len(params.Databases))
+	ports := make(map[int]struct{}, len(params.Databases))
+
+	for _, db := range params.Databases {
+		if db.Name == "" || strings.ContainsRune(db.Name, filepath.Separator) {
+			return status.ErrInvalidParams.WrapMessage("invalid database name %q", db.Name)
+		}
+		if _, ok := names[db.Name]; ok {
+			return status.ErrInvalidParams.WrapMessage("duplicate database name %q", db.Name)
+		}
+		names[db.Name] = struct{}{}
+
+		if db.Port <= 0 {
+			return status.ErrInvalidParams.WrapMessage("database %q requires a port", db.Name)
+		}
+		if _, ok := ports[db.Port]; ok {
+			return status.ErrInvalidParams.WrapMessage("database %q: port %d is already used", db.Name, db.Port)
+		}
+		ports[db.Port] = struct{}{}
+
+		if db.SrcRepo != "" || db.SrcLabel != "" || db.SrcBundle != "" {
+			if err := validateSource(db.Name, db.SrcRepo, db.SrcLabel, db.SrcBundle); err != nil {
+				return err
+			}
+		}
+
+		if db.DestRepo != "" && db.DestMessage == "" {
+			return status.ErrInvalidParams.WrapMessage("destination database %q requires a message", db.Name)
+		}
+
+		if !db.DestFormat.IsValid() {
+			return status.ErrInvalidParams.WrapMessage("database %q: unsupported format %q", db.Name, db.DestFormat)
+		}
+	}
+
+	if !params.Globals.PGMigration.IsValid() {
+		return status.ErrInvalidParams.WrapMessage("unsupported postgres migration method %q", params.Globals.PGMigration)
+	}
+
+	return nil
+}
+
+func validateSource(name, repo, label, bundleID string) error {
+	if repo == "" {
+		return status.ErrInvalidParams.WrapMessage("source %q requires a repo", name)
+	}
+	if label != "" && bundleID != "" {
+		return status.ErrInvalidParams.WrapMessage("source %q may be specified by label or by bundle id, but not both", name)
+	}
+	return nil
+}
+
+// checkBundleIDFile verifies that the file receiving the ID of an uploaded bundle does not exist yet
+func checkBundleIDFile(file string) error {
+	if file == "" {
+		return nil
+	}
+
+	if _, err := os.Stat(file); err == nil {
+		return status.ErrBundleIDFile.WrapMessage("file %q already exists", file)
+	}
+
+	return nil
+}
+
+func writeBundleIDFile(file, bundleID string) error {
+	if file == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return status.ErrBundleIDFile.Wrap(err)
+	}
+
+	out, err := os.OpenFile(file, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return status.ErrBundleIDFile.Wrap(err)
+	}
+
+	if _, err = out.WriteString(bundleID + "\n"); err != nil {
+		_ = out.Close()
+		return status.ErrBundleIDFile.Wrap(err)
+	}
+
+	if err = out.Close(); err != nil {
+		return status.ErrBundleIDFile.Wrap(err)
+	}
+
+	return nil
+}
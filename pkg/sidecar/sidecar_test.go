@@ -0,0 +1,483 @@
+package sidecar
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/errors"
+	"github.com/oneconcern/datamon/pkg/sidecar/param"
+	"github.com/oneconcern/datamon/pkg/sidecar/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testPoll = 10 * time.Millisecond
+
+type fakeActions struct {
+	mu        sync.Mutex
+	mounted   map[string]Source
+	unmounted []string
+	downloads map[string]Source
+	uploads   map[string]Destination
+	labels    map[string]string // label -> bundle id
+	uploadErr error
+	content   map[string]string // files downloaded to restored folders
+}
+
+func newFakeActions() *fakeActions {
+	return &fakeActions{
+		mounted:   make(map[string]Source),
+		downloads: make(map[string]Source),
+		uploads:   make(map[string]Destination),
+		labels:    make(map[string]string),
+		content:   make(map[string]string),
+	}
+}
+
+func (f *fakeActions) Resolve(_ context.Context, source Source) (string, error) {
+	if source.BundleID != "" {
+		return source.BundleID, nil
+	}
+	return "resolved-" + source.Repo, nil
+}
+
+func (f *fakeActions) Label(_ context.Context, repo, label, bundleID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.labels[repo+":"+label] = bundleID
+	return nil
+}
+
+func (f *fakeActions) Mount(_ context.Context, path string, source Source) (func() error, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.mounted[path] = source
+	return func() error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.unmounted = append(f.unmounted, path)
+		return nil
+	}, nil
+}
+
+func (f *fakeActions) Download(_ context.Context, path string, source Source) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.downloads[path] = source
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return err
+	}
+	for name, content := range f.content {
+		if err := ioutil.WriteFile(filepath.Join(path, name), []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeActions) Upload(_ context.Context, path string, destination Destination) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.uploadErr != nil {
+		return "", f.uploadErr
+	}
+	f.uploads[path] = destination
+	return "bundle-" + filepath.Base(path), nil
+}
+
+type fakePostgres struct {
+	mu      sync.Mutex
+	version string
+	calls   []string
+}
+
+func (f *fakePostgres) record(call string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, call)
+}
+
+func (f *fakePostgres) Version(_ context.Context) (string, error) {
+	return f.version, nil
+}
+
+func (f *fakePostgres) InitDB(_ context.Context, dataDir string) error {
+	f.record("initdb " + filepath.Base(dataDir))
+	return nil
+}
+
+func (f *fakePostgres) Start(_ context.Context, dataDir string, _ int, _ string) error {
+	f.record("start " + filepath.Base(dataDir))
+	return nil
+}
+
+func (f *fakePostgres) CreateOwner(_ context.Context, _ int, owner string) error {
+	f.record("createuser " + owner)
+	return nil
+}
+
+func (f *fakePostgres) Quiesce(_ context.Context, _ int) error {
+	f.record("quiesce")
+	return nil
+}
+
+func (f *fakePostgres) Stop(_ context.Context, dataDir string) error {
+	f.record("stop " + filepath.Base(dataDir))
+	return nil
+}
+
+func (f *fakePostgres) Dump(_ context.Context, _ int, dir string) error {
+	f.record("dump " + filepath.Base(dir))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, pgDumpManifest), []byte("version: \""+f.version+"\"\n"), 0600)
+}
+
+func (f *fakePostgres) Restore(_ context.Context, _ int, dir string) error {
+	f.record("restore " + filepath.Base(dir))
+	return nil
+}
+
+func (f *fakePostgres) Migrate(_ context.Context, migration Migration) error {
+	f.record("migrate " + string(migration.Method) + " from " + migration.FromVersion + " " + filepath.Base(migration.OldDataDir))
+	return nil
+}
+
+// application simulates the application wrapper: it waits for an event, then signals another one
+func application(coord *Coordinator, await, emit string, scope ...string) chan error {
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := coord.Await(ctx, await, scope...); err != nil {
+			done <- err
+			return
+		}
+		done <- coord.Emit(emit, scope...)
+	}()
+	return done
+}
+
+func TestRunFUSE(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-sidecar-fuse-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	coordPoint := filepath.Join(tmp, "coord")
+	mountPoint := filepath.Join(tmp, "input")
+	outputPath := filepath.Join(tmp, "output")
+	bundleIDFile := filepath.Join(tmp, "ids", "output")
+	require.NoError(t, os.MkdirAll(mountPoint, 0755))
+
+	params, err := param.NewFUSEParams(param.FUSECoordPoint(coordPoint))
+	require.NoError(t, err)
+	require.NoError(t, params.AddBundle(
+		param.BDName("input"),
+		param.BDSrcByLabel(mountPoint, "repo-in", "latest"),
+	))
+	require.NoError(t, params.AddBundle(
+		param.BDName("output"),
+		param.BDDest("repo-out", "result", outputPath),
+		param.BDDestLabel("done"),
+		param.BDDestBundleIDFile(bundleIDFile),
+	))
+
+	actions := newFakeActions()
+	s := New(actions, WithLogger(zap.NewNop()), WithPollInterval(testPoll))
+	coord := NewCoordinator(coordPoint, testPoll)
+	app := application(coord, EventMountDone, EventInitUpload)
+
+	require.NoError(t, s.RunFUSE(context.Background(), params))
+	require.NoError(t, <-app)
+
+	assert.Equal(t, Source{Repo: "repo-in", Label: "latest"}, actions.mounted[mountPoint])
+	assert.Equal(t, []string{mountPoint}, actions.unmounted)
+	assert.Equal(t, Destination{Repo: "repo-out", Message: "result", Label: "done"}, actions.uploads[outputPath])
+	assert.True(t, coord.Has(EventUploadDone))
+
+	state, err := coord.ReadState(TypeFUSE)
+	require.NoError(t, err)
+	assert.Equal(t, StatusDone, state.Status)
+	assert.NotNil(t, state.FinishedAt)
+
+	id, err := ioutil.ReadFile(bundleIDFile)
+	require.NoError(t, err)
+	assert.Equal(t, "bundle-output\n", string(id))
+
+	t.Run("should not overwrite a bundle id file", func(t *testing.T) {
+		err := New(newFakeActions(), WithLogger(zap.NewNop()), WithName("again")).RunFUSE(context.Background(), params)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, status.ErrBundleIDFile))
+
+		state, err := coord.ReadState("again")
+		require.NoError(t, err)
+		assert.Equal(t, StatusFailed, state.Status)
+		assert.Equal(t, ExitCodeOutput, state.ExitCode)
+		assert.Contains(t, state.Error, bundleIDFile)
+	})
+
+	t.Run("should stop waiting when canceled", func(t *testing.T) {
+		canceled, err := param.NewFUSEParams(param.FUSECoordPoint(filepath.Join(tmp, "other")))
+		require.NoError(t, err)
+		require.NoError(t, canceled.AddBundle(
+			param.BDName("input"),
+			param.BDSrcByBundleID(mountPoint, "repo-in", "1234"),
+		))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*testPoll)
+		defer cancel()
+		fake := newFakeActions()
+		err = New(fake, WithLogger(zap.NewNop()), WithPollInterval(testPoll)).RunFUSE(ctx, canceled)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, status.ErrCoordination))
+		assert.Equal(t, []string{mountPoint}, fake.unmounted)
+	})
+}
+
+func TestValidateFUSEParams(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-sidecar-validate-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	for _, toPin := range []struct {
+		name   string
+		bundle string
+	}{
+		{
+			name:   "source and destination",
+			bundle: `{name: b, srcPath: %[1]s, srcRepo: repo, srcLabel: label, destPath: %[1]s, destRepo: repo, destMessage: message}`,
+		},
+		{
+			name:   "neither source nor destination",
+			bundle: `{name: b}`,
+		},
+		{
+			name:   "source without repo",
+			bundle: `{name: b, srcPath: %[1]s, srcLabel: label}`,
+		},
+		{
+			name:   "label and bundle id",
+			bundle: `{name: b, srcPath: %[1]s, srcRepo: repo, srcLabel: label, srcBundle: "1234"}`,
+		},
+		{
+			name:   "missing mount point",
+			bundle: `{name: b, srcPath: %[1]s/missing, srcRepo: repo}`,
+		},
+		{
+			name:   "destination without message",
+			bundle: `{name: b, destPath: %[1]s, destRepo: repo}`,
+		},
+	} {
+		testCase := toPin
+		t.Run(testCase.name, func(t *testing.T) {
+			params, err := param.UnmarshalFUSEParams([]byte(fmt.Sprintf(
+				"globalOpts: {coordPoint: %[1]s}\nbundles: ["+testCase.bundle+"]\n", tmp,
+			)))
+			require.NoError(t, err)
+			require.Len(t, params.Bundles, 1)
+
+			err = validateFUSEParams(params)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, status.ErrInvalidParams))
+		})
+	}
+}
+
+func TestRunPG(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-sidecar-pg-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	coordPoint := filepath.Join(tmp, "coord")
+	dataDir := filepath.Join(tmp, "pg_stage")
+
+	params, err := param.NewPGParams(param.PGCoordPoint(coordPoint))
+	require.NoError(t, err)
+	require.NoError(t, params.AddDatabase(
+		param.DBNameAndPort("newdb", 5430),
+		param.DBDest("repo-out", "new database"),
+		param.DBOwner("owner"),
+		param.DBDestBundleIDFile(filepath.Join(tmp, "ids", "newdb")),
+	))
+	require.NoError(t, params.AddDatabase(
+		param.DBNameAndPort("restored", 5431),
+		param.DBSrcByLabel("repo-in", "latest"),
+		param.DBDest("repo-out", "restored database"),
+		param.DBDestLabel("restored"),
+	))
+
+	run := func(t *testing.T, params param.PGParams, version string, actions *fakeActions) (*fakePostgres, error) {
+		pg := &fakePostgres{version: version}
+		s := New(actions,
+			WithLogger(zap.NewNop()),
+			WithPollInterval(testPoll),
+			WithPostgres(pg),
+			WithPGDataDir(dataDir),
+			WithLogDir(filepath.Join(tmp, "logs")),
+		)
+		coord := NewCoordinator(coordPoint, testPoll)
+		apps := make([]chan error, 0, len(params.Databases))
+		for _, db := range params.Databases {
+			apps = append(apps, application(coord, EventDBStarted, EventInitDBUpload, db.Name))
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := s.RunPG(ctx, params)
+		if err == nil {
+			for _, app := range apps {
+				require.NoError(t, <-app)
+			}
+		}
+		return pg, err
+	}
+
+	actions := newFakeActions()
+	actions.content[pgVersionFile] = "12.1\n"
+	pg, err := run(t, params, "12.3", actions)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		"initdb newdb", "start newdb", "createuser owner", "quiesce", "stop newdb",
+		"start restored", "quiesce", "stop restored",
+	}, pg.calls)
+
+	newDir := filepath.Join(dataDir, "newdb")
+	restoredDir := filepath.Join(dataDir, "restored")
+	assert.Equal(t, Source{Repo: "repo-in", Label: "latest"}, actions.downloads[restoredDir])
+	annotations := map[string]string{PGVersionAnnotation: "12", PGFormatAnnotation: "files"}
+	assert.Equal(t, Destination{Repo: "repo-out", Message: "new database", Annotations: annotations}, actions.uploads[newDir])
+	assert.Equal(t, Destination{Repo: "repo-out", Message: "restored database", Label: "restored", Annotations: annotations},
+		actions.uploads[restoredDir])
+
+	version, err := ioutil.ReadFile(filepath.Join(newDir, pgVersionFile))
+	require.NoError(t, err)
+	assert.Equal(t, "12.3\n", string(version))
+	assert.DirExists(t, filepath.Join(restoredDir, "pg_wal", "archive_status"))
+
+	coord := NewCoordinator(coordPoint, testPoll)
+	assert.True(t, coord.Has(EventDBUploadDone, "newdb"))
+	assert.True(t, coord.Has(EventDBUploadDone, "restored"))
+
+	id, err := ioutil.ReadFile(filepath.Join(tmp, "ids", "newdb"))
+	require.NoError(t, err)
+	assert.Equal(t, "bundle-newdb\n", string(id))
+
+	t.Run("should detect a postgres version mismatch", func(t *testing.T) {
+		require.NoError(t, os.RemoveAll(coordPoint))
+		mismatch, err := param.NewPGParams(param.PGCoordPoint(coordPoint))
+		require.NoError(t, err)
+		require.NoError(t, mismatch.AddDatabase(
+			param.DBNameAndPort("restored", 5431),
+			param.DBSrcByBundle("repo-in", "1234"),
+			param.DBDest("repo-out", "restored database"),
+		))
+
+		actions := newFakeActions()
+		actions.content[pgVersionFile] = "11.7\n"
+		_, err = run(t, mismatch, "12.3", actions)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, status.ErrPGVersion))
+
+		require.NoError(t, os.RemoveAll(coordPoint))
+		mismatch.Globals.IgnorePGVersionMismatch = true
+		_, err = run(t, mismatch, "12.3", actions)
+		require.NoError(t, err)
+	})
+
+	t.Run("should migrate a database created by an older postgres", func(t *testing.T) {
+		require.NoError(t, os.RemoveAll(coordPoint))
+		migrated, err := param.NewPGParams(param.PGCoordPoint(coordPoint), param.PGMigrationMethod(param.PGMigrationUpgrade))
+		require.NoError(t, err)
+		require.NoError(t, migrated.AddDatabase(
+			param.DBNameAndPort("restored", 5431),
+			param.DBSrcByBundle("repo-in", "1234"),
+			param.DBDest("repo-out", "migrated database"),
+		))
+
+		actions := newFakeActions()
+		actions.content[pgVersionFile] = "11.7\n"
+		actions.content["PG_VERSION"] = "11\n"
+		pg, err := run(t, migrated, "12.3", actions)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"initdb restored", "migrate upgrade from 11 restored.pg11", "start restored", "quiesce", "stop restored",
+		}, pg.calls)
+		assert.NoDirExists(t, restoredDir+".pg11")
+		assert.Equal(t, map[string]string{PGVersionAnnotation: "12", PGFormatAnnotation: "files"}, actions.uploads[restoredDir].Annotations)
+
+		version, err := ioutil.ReadFile(filepath.Join(restoredDir, pgVersionFile))
+		require.NoError(t, err)
+		assert.Equal(t, "12.3\n", string(version))
+	})
+
+	t.Run("should save and restore logical dumps", func(t *testing.T) {
+		require.NoError(t, os.RemoveAll(coordPoint))
+		dumped, err := param.NewPGParams(param.PGCoordPoint(coordPoint))
+		require.NoError(t, err)
+		require.NoError(t, dumped.AddDatabase(
+			param.DBNameAndPort("restored", 5431),
+			param.DBSrcByBundle("repo-in", "1234"),
+			param.DBDest("repo-out", "dumped database"),
+			param.DBDestFormat(param.PGFormatDump),
+		))
+
+		// the source bundle is a logical dump created by another version of postgres
+		actions := newFakeActions()
+		actions.content[pgDumpManifest] = "version: \"11.7\"\n"
+		pg, err := run(t, dumped, "12.3", actions)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"initdb restored", "start restored", "restore restored.dump", "quiesce", "dump restored.dump", "stop restored",
+		}, pg.calls)
+
+		dumpDir := restoredDir + ".dump"
+		assert.FileExists(t, filepath.Join(dumpDir, pgDumpManifest))
+		assert.NoFileExists(t, filepath.Join(restoredDir, pgDumpManifest))
+		assert.Equal(t, Destination{
+			Repo:        "repo-out",
+			Message:     "dumped database",
+			Annotations: map[string]string{PGVersionAnnotation: "12", PGFormatAnnotation: "dump"},
+		}, actions.uploads[dumpDir])
+	})
+
+	t.Run("should not migrate a database created by a newer postgres", func(t *testing.T) {
+		require.NoError(t, os.RemoveAll(coordPoint))
+		migrated, err := param.NewPGParams(param.PGCoordPoint(coordPoint), param.PGMigrationMethod(param.PGMigrationDump))
+		require.NoError(t, err)
+		require.NoError(t, migrated.AddDatabase(
+			param.DBNameAndPort("restored", 5431),
+			param.DBSrcByBundle("repo-in", "1234"),
+			param.DBDest("repo-out", "migrated database"),
+		))
+
+		actions := newFakeActions()
+		actions.content["PG_VERSION"] = "13\n"
+		_, err = run(t, migrated, "12.3", actions)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, status.ErrPGVersion))
+	})
+
+	t.Run("should reject duplicate ports", func(t *testing.T) {
+		params.Databases[1].Port = params.Databases[0].Port
+		err := validatePGParams(params)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, status.ErrInvalidParams))
+	})
+}
@@ -0,0 +1,352 @@
+package sidecar
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/errors"
+	"github.com/oneconcern/datamon/pkg/sidecar/status"
+)
+
+// Status of a sidecar, as reported in its state file
+type Status string
+
+// Statuses of a sidecar, in the order of their lifecycle
+const (
+	// StatusStarting is reported while a sidecar prepares its input
+	StatusStarting Status = "starting"
+
+	// StatusReady is reported when the input is ready for the application: bundles are mounted or database servers are started
+	StatusReady Status = "ready"
+
+	// StatusUploading is reported while a sidecar saves the output of the application
+	StatusUploading Status = "uploading"
+
+	// StatusDone is reported when a sidecar has completed successfully
+	StatusDone Status = "done"
+
+	// StatusFailed is reported when a sidecar has failed. This status is final.
+	StatusFailed Status = "failed"
+)
+
+// Exit codes of a sidecar, reported in the state file of failed sidecars
+const (
+	ExitCodeFailure       = 1
+	ExitCodeInvalidParams = 2
+	ExitCodeInput         = 3 // a bundle could not be mounted or downloaded
+	ExitCodeOutput        = 4 // a bundle could not be uploaded
+	ExitCodePostgres      = 5
+	ExitCodeCoordination  = 6
+
+	// ExitCodeTimeout is returned when waiting on sidecars times out, like with timeout(1)
+	ExitCodeTimeout = 124
+)
+
+// stateDir is the folder of the coordination point holding the state files of sidecars
+const stateDir = "sidecars"
+
+var statusRank = map[Status]int{
+	StatusStarting:  1,
+	StatusReady:     2,
+	StatusUploading: 3,
+	StatusDone:      4,
+}
+
+// IsValid tells if a status is known
+func (s Status) IsValid() bool {
+	_, ok := statusRank[s]
+	return ok || s == StatusFailed
+}
+
+// Reached tells if a status has reached some stage of the lifecycle. A failed status never reaches any stage.
+func (s Status) Reached(target Status) bool {
+	if s == StatusFailed || target == StatusFailed {
+		return s == target
+	}
+	return statusRank[s] >= statusRank[target]
+}
+
+// State of a sidecar, shared with the application wrapper as a JSON file on the coordination point
+type State struct {
+	Name       string     `json:"name"`
+	Type       string     `json:"type,omitempty"`
+	Status     Status     `json:"status"`
+	Error      string     `json:"error,omitempty"`
+	ExitCode   int        `json:"exitCode,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// FailedError reports a sidecar which has failed
+type FailedError struct {
+	State State
+}
+
+func (e *FailedError) Error() string {
+	return "sidecar " + e.State.Name + " failed: " + e.State.Error
+}
+
+// ExitCode yields the exit code to report for an error produced by a sidecar
+func ExitCode(err error) int {
+	var failed *FailedError
+
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &failed):
+		if failed.State.ExitCode != 0 {
+			return failed.State.ExitCode
+		}
+		return ExitCodeFailure
+	case errors.Is(err, status.ErrTimeout):
+		return ExitCodeTimeout
+	case errors.Is(err, status.ErrInvalidParams):
+		return ExitCodeInvalidParams
+	case errors.Is(err, status.ErrMount), errors.Is(err, status.ErrDownload):
+		return ExitCodeInput
+	case errors.Is(err, status.ErrUpload), errors.Is(err, status.ErrBundleIDFile):
+		return ExitCodeOutput
+	case errors.Is(err, status.ErrPostgres), errors.Is(err, status.ErrPGVersion):
+		return ExitCodePostgres
+	case errors.Is(err, status.ErrCoordination):
+		return ExitCodeCoordination
+	default:
+		return ExitCodeFailure
+	}
+}
+
+func (c *Coordinator) statePath(name string) string {
+	return filepath.Join(c.point, stateDir, name+".json")
+}
+
+// WriteState publishes the state of a sidecar. The state file is replaced atomically.
+func (c *Coordinator) WriteState(state State) error {
+	if state.Name == "" || strings.ContainsRune(state.Name, filepath.Separator) {
+		return status.ErrCoordination.WrapMessage("invalid sidecar name %q", state.Name)
+	}
+
+	pth := c.statePath(state.Name)
+	if err := os.MkdirAll(filepath.Dir(pth), 0755); err != nil {
+		return status.ErrCoordination.Wrap(err)
+	}
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return status.ErrCoordination.Wrap(err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(pth), "."+state.Name+"-")
+	if err != nil {
+		return status.ErrCoordination.Wrap(err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err = tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return status.ErrCoordination.Wrap(err)
+	}
+	if err = tmp.Close(); err != nil {
+		return status.ErrCoordination.Wrap(err)
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return status.ErrCoordination.Wrap(err)
+	}
+
+	if err = os.Rename(tmp.Name(), pth); err != nil {
+		return status.ErrCoordination.Wrap(err)
+	}
+
+	return nil
+}
+
+// ReadState retrieves the state of a sidecar.
+//
+// An error wrapping os.ErrNotExist is returned when the sidecar has not published any state yet.
+func (c *Coordinator) ReadState(name string) (State, error) {
+	var state State
+
+	b, err := ioutil.ReadFile(c.statePath(name))
+	if err != nil {
+		return state, err
+	}
+
+	if err = json.Unmarshal(b, &state); err != nil {
+		return state, status.ErrCoordination.WrapMessage("invalid state for sidecar %q: %v", name, err)
+	}
+
+	return state, nil
+}
+
+// States retrieves the states of all sidecars which have published one, sorted by name
+func (c *Coordinator) States() ([]State, error) {
+	matches, err := filepath.Glob(filepath.Join(c.point, stateDir, "*.json"))
+	if err != nil {
+		return nil, status.ErrCoordination.Wrap(err)
+	}
+
+	states := make([]State, 0, len(matches))
+	for _, match := range matches {
+		state, err := c.ReadState(strings.TrimSuffix(filepath.Base(match), ".json"))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		states = append(states, state)
+	}
+
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+
+	return states, nil
+}
+
+// Wait blocks until all named sidecars have reached a status. When no sidecar is named, Wait blocks on all sidecars
+// which have published a state.
+//
+// Wait fails fast: a *FailedError is returned as soon as any sidecar has failed.
+// Sidecars which have not published any state yet are waited for.
+//
+// Use a context with a timeout or deadline to limit the wait.
+func (c *Coordinator) Wait(ctx context.Context, target Status, names ...string) ([]State, error) {
+	if !target.IsValid() || target == StatusFailed {
+		return nil, status.ErrCoordination.WrapMessage("cannot wait for status %q", target)
+	}
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		states, reached, err := c.check(target, names)
+		if err != nil {
+			return states, err
+		}
+		if reached {
+			return states, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return states, status.ErrTimeout.WrapMessage("waiting for sidecars to be %s", target)
+			}
+			return states, status.ErrCoordination.WrapMessage("waiting for sidecars to be %s: %v", target, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Coordinator) check(target Status, names []string) ([]State, bool, error) {
+	if len(names) == 0 {
+		states, err := c.States()
+		if err != nil {
+			return nil, false, err
+		}
+		for _, state := range states {
+			if state.Status == StatusFailed {
+				return states, false, &FailedError{State: state}
+			}
+		}
+		if len(states) == 0 {
+			return states, false, nil
+		}
+		for _, state := range states {
+			if !state.Status.Reached(target) {
+				return states, false, nil
+			}
+		}
+		return states, true, nil
+	}
+
+	states := make([]State, 0, len(names))
+	reached := true
+	for _, name := range names {
+		state, err := c.ReadState(name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				reached = false
+				continue
+			}
+			return states, false, err
+		}
+		states = append(states, state)
+
+		if state.Status == StatusFailed {
+			return states, false, &FailedError{State: state}
+		}
+		if !state.Status.Reached(target) {
+			reached = false
+		}
+	}
+
+	return states, reached, nil
+}
+
+// ReportFailure publishes the state of a sidecar which failed before it could run,
+// e.g. because its parameters are invalid or its context is not reachable
+func (c *Coordinator) ReportFailure(name, kind string, err error) error {
+	t := newStateTracker(c, name, kind)
+	t.state.Error = err.Error()
+	t.state.ExitCode = ExitCode(err)
+
+	return t.advance(StatusFailed)
+}
+
+// stateTracker publishes the state of a running sidecar
+type stateTracker struct {
+	mx    sync.Mutex
+	coord *Coordinator
+	state State
+}
+
+func newStateTracker(coord *Coordinator, name, kind string) *stateTracker {
+	return &stateTracker{
+		coord: coord,
+		state: State{
+			Name:      name,
+			Type:      kind,
+			StartedAt: time.Now().UTC(),
+		},
+	}
+}
+
+// advance publishes a new status. A status never goes back to an earlier stage of the lifecycle.
+func (t *stateTracker) advance(s Status) error {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+
+	if t.state.Status == StatusFailed || (t.state.Status != "" && t.state.Status.Reached(s)) {
+		return nil
+	}
+
+	t.state.Status = s
+	t.state.UpdatedAt = time.Now().UTC()
+	if s == StatusDone || s == StatusFailed {
+		finished := t.state.UpdatedAt
+		t.state.FinishedAt = &finished
+	}
+
+	return t.coord.WriteState(t.state)
+}
+
+// fail reports a failed sidecar. The original error is returned.
+func (t *stateTracker) fail(err error) error {
+	t.mx.Lock()
+	t.state.Error = err.Error()
+	t.state.ExitCode = ExitCode(err)
+	t.mx.Unlock()
+
+	_ = t.advance(StatusFailed)
+
+	return err
+}
@@ -0,0 +1,84 @@
+package sidecar
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/errors"
+	"github.com/oneconcern/datamon/pkg/sidecar/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWait(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-sidecar-state-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	coord := NewCoordinator(tmp, testPoll)
+	fuse := newStateTracker(coord, "fuse", TypeFUSE)
+	pg := newStateTracker(coord, "pg", TypePostgres)
+	require.NoError(t, fuse.advance(StatusStarting))
+
+	t.Run("should time out on sidecars which are not ready", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*testPoll)
+		defer cancel()
+
+		states, err := coord.Wait(ctx, StatusReady, "fuse", "pg")
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, status.ErrTimeout))
+		assert.Equal(t, ExitCodeTimeout, ExitCode(err))
+		require.Len(t, states, 1)
+		assert.Equal(t, StatusStarting, states[0].Status)
+	})
+
+	t.Run("should wait until all sidecars are ready", func(t *testing.T) {
+		go func() {
+			time.Sleep(3 * testPoll)
+			_ = fuse.advance(StatusReady)
+			_ = pg.advance(StatusUploading)
+		}()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		states, err := coord.Wait(ctx, StatusReady, "fuse", "pg")
+		require.NoError(t, err)
+		require.Len(t, states, 2)
+
+		// a status never goes back
+		require.NoError(t, pg.advance(StatusReady))
+		state, err := coord.ReadState("pg")
+		require.NoError(t, err)
+		assert.Equal(t, StatusUploading, state.Status)
+	})
+
+	t.Run("should fail fast when a sidecar fails", func(t *testing.T) {
+		failure := status.ErrUpload.WrapMessage("bucket not found")
+		assert.Equal(t, failure, pg.fail(failure))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		_, err := coord.Wait(ctx, StatusDone)
+		require.Error(t, err)
+
+		var failed *FailedError
+		require.True(t, errors.As(err, &failed))
+		assert.Equal(t, "pg", failed.State.Name)
+		assert.Equal(t, TypePostgres, failed.State.Type)
+		assert.Contains(t, failed.State.Error, "bucket not found")
+		assert.NotNil(t, failed.State.FinishedAt)
+		assert.Equal(t, ExitCodeOutput, ExitCode(err))
+
+		states, err := coord.States()
+		require.NoError(t, err)
+		require.Len(t, states, 2)
+		assert.Equal(t, "fuse", states[0].Name)
+	})
+}
@@ -0,0 +1,35 @@
+// Package status exports errors produced by the sidecar package.
+package status
+
+import (
+	"github.com/oneconcern/datamon/pkg/errors"
+)
+
+var (
+	// ErrInvalidParams indicates that the sidecar parameters are inconsistent
+	ErrInvalidParams = errors.New("invalid sidecar parameters")
+
+	// ErrMount indicates that a bundle could not be mounted
+	ErrMount = errors.New("failed to mount bundle")
+
+	// ErrDownload indicates that a bundle could not be downloaded
+	ErrDownload = errors.New("failed to download bundle")
+
+	// ErrUpload indicates that a bundle could not be uploaded
+	ErrUpload = errors.New("failed to upload bundle")
+
+	// ErrBundleIDFile indicates that the file receiving the ID of an uploaded bundle could not be written
+	ErrBundleIDFile = errors.New("failed to write bundle id file")
+
+	// ErrPostgres indicates a failure to operate a postgres database server
+	ErrPostgres = errors.New("postgres operation failed")
+
+	// ErrPGVersion indicates that a database bundle has been created with another major version of postgres
+	ErrPGVersion = errors.New("postgres major version mismatch")
+
+	// ErrCoordination indicates a failure to exchange events with the application wrapper
+	ErrCoordination = errors.New("coordination with application wrapper failed")
+
+	// ErrTimeout indicates that sidecars did not reach the expected status in time
+	ErrTimeout = errors.New("timed out waiting for sidecars")
+)
@@ -0,0 +1,414 @@
+package sidecar
+
+import (
+	"context"
+	"sync"
+
+	"github.com/oneconcern/datamon/pkg/sidecar/param"
+	"github.com/oneconcern/datamon/pkg/sidecar/status"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+type (
+	// workflow runs the inputs and outputs of a sidecar specification
+	workflow struct {
+		*Sidecar
+		coord    *Coordinator
+		spec     param.Spec
+		settings pgSettings
+		inputs   map[string]*inputHandle
+		trackers map[string]*stateTracker
+	}
+
+	// inputHandle shares the progress of an input with the outputs which depend on it
+	inputHandle struct {
+		ready    chan struct{} // closed when the input is ready
+		bundleID string        // the bundle mounted or downloaded
+		dataDir  string        // the data directory of a database
+		dump     bool          // a database is saved as logical dumps
+		saveDir  string        // the folder saved for a database, once stopped
+
+		stopOnce sync.Once
+		stop     chan struct{} // closed to request a database server to stop
+		stopped  chan struct{} // closed when a database server is stopped
+		stopErr  error
+	}
+)
+
+func newInputHandle() *inputHandle {
+	return &inputHandle{
+		ready:   make(chan struct{}),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+}
+
+func (h *inputHandle) requestStop() {
+	h.stopOnce.Do(func() {
+		close(h.stop)
+	})
+}
+
+func waitFor(ctx context.Context, ch <-chan struct{}) error {
+	select {
+	case <-ctx.Done():
+		return status.ErrCoordination.WrapMessage("%v", ctx.Err())
+	case <-ch:
+		return nil
+	}
+}
+
+// Run provides the inputs declared by a specification to the application, and saves its outputs.
+//
+// Every input and output has an independent lifecycle, and signals events in a subfolder of the coordination point
+// named after it:
+//   - a mount input emits "mountdone" when its bundle is mounted, and is unmounted when the application wrapper emits "release"
+//   - a download input emits "downloaddone" when its bundle is downloaded
+//   - a database input emits "dbstarted" when its database server is started, which is stopped when the application wrapper
+//     emits "release", or when the database is saved
+//   - an output is saved when the application wrapper emits "initupload", then emits "uploaddone"
+//
+// The "release" and "initupload" events may also be emitted at the root of the coordination point, for all inputs or outputs.
+//
+// Every input and output publishes its state on the coordination point, named after it (see Coordinator.Wait).
+// Whenever any input or output fails, all others are interrupted.
+func (s *Sidecar) Run(ctx context.Context, spec param.Spec) error {
+	if err := spec.Validate(); err != nil {
+		return status.ErrInvalidParams.Wrap(err)
+	}
+
+	w := &workflow{
+		Sidecar: s,
+		coord:   NewCoordinator(spec.Globals.CoordPoint, s.pollInterval),
+		spec:    spec,
+		settings: pgSettings{
+			ignoreMismatch: spec.Globals.IgnorePGVersionMismatch,
+			migration:      spec.Globals.PGMigration,
+		},
+		inputs:   make(map[string]*inputHandle, len(spec.Inputs)),
+		trackers: make(map[string]*stateTracker, len(spec.Inputs)+len(spec.Outputs)),
+	}
+
+	if err := w.init(ctx); err != nil {
+		return err
+	}
+
+	group, gctx := errgroup.WithContext(ctx)
+	for _, toPin := range spec.Inputs {
+		input := toPin
+		group.Go(func() error {
+			return w.track(input.Name, w.runInput(gctx, input))
+		})
+	}
+	for _, toPin := range spec.Outputs {
+		output := toPin
+		group.Go(func() error {
+			return w.track(output.Name, w.runOutput(gctx, output))
+		})
+	}
+
+	if err := group.Wait(); err != nil {
+		return err
+	}
+
+	s.l.Info("sidecar done")
+
+	if spec.Globals.SleepInsteadOfExit {
+		s.sleep(ctx)
+	}
+
+	return nil
+}
+
+// init publishes the initial state of all inputs and outputs, then carries out the checks required before starting
+func (w *workflow) init(ctx context.Context) error {
+	var needsPG bool
+
+	for _, input := range w.spec.Inputs {
+		w.inputs[input.Name] = newInputHandle()
+		w.trackers[input.Name] = newStateTracker(w.coord, input.Name, "input/"+string(input.Kind))
+		needsPG = needsPG || input.Kind == param.InputDatabase
+	}
+	for _, output := range w.spec.Outputs {
+		w.trackers[output.Name] = newStateTracker(w.coord, output.Name, "output/"+string(output.Kind))
+		if output.Kind == param.OutputDatabase {
+			w.inputs[output.Database].dump = output.Format == param.PGFormatDump
+		}
+	}
+
+	for _, tracker := range w.trackers {
+		if err := tracker.advance(StatusStarting); err != nil {
+			return err
+		}
+	}
+
+	fail := func(err error) error {
+		for _, tracker := range w.trackers {
+			_ = tracker.fail(err)
+		}
+		return err
+	}
+
+	for _, output := range w.spec.Outputs {
+		if err := checkBundleIDFile(output.BundleIDFile); err != nil {
+			return fail(err)
+		}
+	}
+
+	if needsPG {
+		version, err := w.pg.Version(ctx)
+		if err != nil {
+			return fail(err)
+		}
+		w.settings.version = version
+	}
+
+	return nil
+}
+
+// track publishes the final state of an input or output
+func (w *workflow) track(name string, err error) error {
+	tracker := w.trackers[name]
+	if err != nil {
+		return tracker.fail(err)
+	}
+
+	return tracker.advance(StatusDone)
+}
+
+func (w *workflow) runInput(ctx context.Context, input param.SpecInput) error {
+	switch input.Kind {
+	case param.InputMount:
+		return w.runMount(ctx, input)
+	case param.InputDownload:
+		return w.runDownload(ctx, input)
+	default:
+		return w.runDatabase(ctx, input)
+	}
+}
+
+func (w *workflow) runMount(ctx context.Context, input param.SpecInput) error {
+	handle, tracker := w.inputs[input.Name], w.trackers[input.Name]
+	logger := w.l.With(zap.String("input", input.Name))
+
+	bundleID, err := w.actions.Resolve(ctx, Source{Repo: input.Repo, Label: input.Label, BundleID: input.Bundle})
+	if err != nil {
+		return status.ErrMount.Wrap(err)
+	}
+
+	logger.Info("mounting bundle", zap.String("path", input.Path))
+	unmount, err := w.actions.Mount(ctx, input.Path, Source{Repo: input.Repo, Label: input.Label, BundleID: bundleID})
+	if err != nil {
+		return err
+	}
+	mounted := true
+	defer func() {
+		if mounted {
+			_ = unmount()
+		}
+	}()
+
+	handle.bundleID = bundleID
+	close(handle.ready)
+
+	if err = w.coord.Emit(EventMountDone, input.Name); err != nil {
+		return err
+	}
+	if err = tracker.advance(StatusReady); err != nil {
+		return err
+	}
+
+	if err = w.coord.AwaitScoped(ctx, EventRelease, input.Name); err != nil {
+		return err
+	}
+
+	mounted = false
+	if err = unmount(); err != nil {
+		return status.ErrMount.Wrap(err)
+	}
+
+	logger.Info("released input")
+
+	return nil
+}
+
+func (w *workflow) runDownload(ctx context.Context, input param.SpecInput) error {
+	handle, tracker := w.inputs[input.Name], w.trackers[input.Name]
+
+	bundleID, err := w.actions.Resolve(ctx, Source{Repo: input.Repo, Label: input.Label, BundleID: input.Bundle})
+	if err != nil {
+		return status.ErrDownload.Wrap(err)
+	}
+
+	if err = w.actions.Download(ctx, input.Path, Source{Repo: input.Repo, BundleID: bundleID}); err != nil {
+		return err
+	}
+
+	handle.bundleID = bundleID
+	close(handle.ready)
+
+	if err = w.coord.Emit(EventDownloadDone, input.Name); err != nil {
+		return err
+	}
+
+	return tracker.advance(StatusReady)
+}
+
+func (w *workflow) runDatabase(ctx context.Context, input param.SpecInput) (err error) {
+	handle, tracker := w.inputs[input.Name], w.trackers[input.Name]
+	logger := w.l.With(zap.String("input", input.Name), zap.Int("port", input.Port))
+
+	db := database{
+		name:    input.Name,
+		port:    input.Port,
+		dataDir: input.Path,
+		owner:   input.Owner,
+		source:  Source{Repo: input.Repo, Label: input.Label, BundleID: input.Bundle},
+	}
+
+	defer func() {
+		// unblock any output waiting on this database, whenever the server could not be started
+		select {
+		case <-handle.stopped:
+		default:
+			handle.stopErr = err
+			if handle.stopErr == nil {
+				handle.stopErr = status.ErrPostgres.WrapMessage("database %q is not available", input.Name)
+			}
+			close(handle.stopped)
+		}
+	}()
+
+	dataDir, err := w.startDB(ctx, logger, db, w.settings)
+	if err != nil {
+		return err
+	}
+	started := true
+	defer func() {
+		if !started {
+			return
+		}
+		if ers := w.pg.Stop(context.Background(), dataDir); ers != nil {
+			logger.Warn("could not stop database server", zap.Error(ers))
+		}
+	}()
+
+	handle.dataDir = dataDir
+	close(handle.ready)
+
+	if err = w.coord.Emit(EventDBStarted, input.Name); err != nil {
+		return err
+	}
+	if err = tracker.advance(StatusReady); err != nil {
+		return err
+	}
+
+	// the server is stopped when the database is released by the application, or when an output saves it
+	releaseCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	released := make(chan error, 1)
+	go func() {
+		released <- w.coord.AwaitScoped(releaseCtx, EventRelease, input.Name)
+	}()
+
+	select {
+	case <-handle.stop:
+	case err = <-released:
+		if err != nil {
+			return err
+		}
+	}
+
+	// a database saved as logical dumps is dumped before its server stops, whichever event stopped it
+	started = false
+	handle.saveDir, err = w.stopDB(ctx, db, dataDir, handle.dump)
+	handle.stopErr = err
+	close(handle.stopped)
+
+	if err == nil {
+		logger.Info("released input")
+	}
+
+	return err
+}
+
+func (w *workflow) runOutput(ctx context.Context, output param.SpecOutput) error {
+	tracker := w.trackers[output.Name]
+	logger := w.l.With(zap.String("output", output.Name))
+
+	if err := tracker.advance(StatusReady); err != nil {
+		return err
+	}
+
+	if err := w.coord.AwaitScoped(ctx, EventInitUpload, output.Name); err != nil {
+		return err
+	}
+
+	if err := tracker.advance(StatusUploading); err != nil {
+		return err
+	}
+
+	destination := Destination{
+		Repo:    output.Repo,
+		Message: output.Message,
+		Label:   output.Label,
+	}
+
+	switch output.Kind {
+	case param.OutputLabel:
+		repo, bundleID := output.Repo, output.Bundle
+		if output.Input != "" {
+			handle := w.inputs[output.Input]
+			if err := waitFor(ctx, handle.ready); err != nil {
+				return err
+			}
+			bundleID = handle.bundleID
+			if repo == "" {
+				input, _ := w.spec.Input(output.Input)
+				repo = input.Repo
+			}
+		}
+
+		logger.Info("labelling bundle", zap.String("repo", repo), zap.String("bundle_id", bundleID))
+		if err := w.actions.Label(ctx, repo, output.Label, bundleID); err != nil {
+			return err
+		}
+
+	case param.OutputDatabase:
+		handle := w.inputs[output.Database]
+		if err := waitFor(ctx, handle.ready); err != nil {
+			return err
+		}
+
+		handle.requestStop()
+		if err := waitFor(ctx, handle.stopped); err != nil {
+			return err
+		}
+		if handle.stopErr != nil {
+			return handle.stopErr
+		}
+
+		logger.Info("uploading database", zap.String("database", output.Database))
+		destination.Annotations = w.settings.annotations(handle.dump)
+		bundleID, err := w.actions.Upload(ctx, handle.saveDir, destination)
+		if err != nil {
+			return err
+		}
+		if err = writeBundleIDFile(output.BundleIDFile, bundleID); err != nil {
+			return err
+		}
+
+	default:
+		logger.Info("uploading bundle", zap.String("path", output.Path))
+		bundleID, err := w.actions.Upload(ctx, output.Path, destination)
+		if err != nil {
+			return err
+		}
+		if err = writeBundleIDFile(output.BundleIDFile, bundleID); err != nil {
+			return err
+		}
+	}
+
+	return w.coord.Emit(EventUploadDone, output.Name)
+}
@@ -0,0 +1,167 @@
+package sidecar
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oneconcern/datamon/pkg/errors"
+	"github.com/oneconcern/datamon/pkg/sidecar/param"
+	"github.com/oneconcern/datamon/pkg/sidecar/status"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func testWorkflowSpec(t *testing.T, tmp string) param.Spec {
+	spec, err := param.UnmarshalSpec([]byte(`
+inputs:
+- {name: images, kind: mount, path: ` + filepath.Join(tmp, "images") + `, repo: images, label: latest}
+- {name: annotations, kind: download, path: ` + filepath.Join(tmp, "annotations") + `, repo: annotations, bundle: "42"}
+- {name: features, kind: database, pgPort: 5430, repo: features, label: latest}
+- {name: scratch, kind: database, pgPort: 5431, owner: app}
+outputs:
+- {name: model, kind: upload, path: ` + filepath.Join(tmp, "model") + `, repo: models, message: trained, bundleIDFile: ` + filepath.Join(tmp, "ids", "model") + `}
+- {name: features-save, kind: database, database: features, repo: features, message: updated, format: dump}
+- {name: validated, kind: label, input: images, label: validated}
+`))
+	require.NoError(t, err)
+	spec.Globals.CoordPoint = filepath.Join(tmp, "coord")
+	return spec
+}
+
+func TestRunSpec(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-sidecar-spec-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	spec := testWorkflowSpec(t, tmp)
+	actions := newFakeActions()
+	actions.content[pgVersionFile] = "12.1\n"
+	pg := &fakePostgres{version: "12.3"}
+	s := New(actions,
+		WithLogger(zap.NewNop()),
+		WithPollInterval(testPoll),
+		WithPostgres(pg),
+		WithPGDataDir(filepath.Join(tmp, "pg_stage")),
+		WithLogDir(filepath.Join(tmp, "logs")),
+	)
+
+	coord := NewCoordinator(spec.Globals.CoordPoint, testPoll)
+	app := make(chan error, 1)
+	go func() {
+		// the application wrapper waits for all inputs, then signals that outputs are ready and inputs may be released
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := coord.Wait(ctx, StatusReady, "images", "annotations", "features", "scratch"); err != nil {
+			app <- err
+			return
+		}
+		if !coord.Has(EventMountDone, "images") || !coord.Has(EventDownloadDone, "annotations") || !coord.Has(EventDBStarted, "scratch") {
+			app <- errors.New("missing events")
+			return
+		}
+		if err := coord.Emit(EventInitUpload); err != nil {
+			app <- err
+			return
+		}
+		app <- coord.Emit(EventRelease)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, s.Run(ctx, spec))
+	require.NoError(t, <-app)
+
+	assert.Equal(t, Source{Repo: "images", Label: "latest", BundleID: "resolved-images"}, actions.mounted[filepath.Join(tmp, "images")])
+	assert.Equal(t, []string{filepath.Join(tmp, "images")}, actions.unmounted)
+	assert.Equal(t, Source{Repo: "annotations", BundleID: "42"}, actions.downloads[filepath.Join(tmp, "annotations")])
+	assert.Equal(t, "resolved-images", actions.labels["images:validated"])
+
+	featuresDump := filepath.Join(tmp, "pg_stage", "features.dump")
+	assert.Equal(t, Destination{Repo: "features", Message: "updated", Annotations: map[string]string{PGVersionAnnotation: "12", PGFormatAnnotation: "dump"}},
+		actions.uploads[featuresDump])
+	assert.Equal(t, Destination{Repo: "models", Message: "trained"}, actions.uploads[filepath.Join(tmp, "model")])
+	assert.Len(t, actions.uploads, 2, "the scratch database is read-only")
+	assert.ElementsMatch(t, []string{
+		"start features", "quiesce", "dump features.dump", "stop features",
+		"initdb scratch", "start scratch", "createuser app", "quiesce", "stop scratch",
+	}, pg.calls)
+
+	id, err := ioutil.ReadFile(filepath.Join(tmp, "ids", "model"))
+	require.NoError(t, err)
+	assert.Equal(t, "bundle-model\n", string(id))
+
+	states, err := coord.States()
+	require.NoError(t, err)
+	require.Len(t, states, 7)
+	for _, state := range states {
+		assert.Equalf(t, StatusDone, state.Status, "unexpected status for %s", state.Name)
+		if state.Name == "features-save" {
+			assert.Equal(t, "output/database", state.Type)
+		}
+	}
+	for _, output := range spec.Outputs {
+		assert.True(t, coord.Has(EventUploadDone, output.Name))
+	}
+}
+
+func TestRunSpecFailure(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "test-sidecar-spec-")
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(tmp)
+	}()
+
+	spec := testWorkflowSpec(t, tmp)
+	actions := newFakeActions()
+	actions.content[pgVersionFile] = "12.1\n"
+	actions.uploadErr = status.ErrUpload.WrapMessage("bucket not found")
+	pg := &fakePostgres{version: "12.3"}
+	s := New(actions,
+		WithLogger(zap.NewNop()),
+		WithPollInterval(testPoll),
+		WithPostgres(pg),
+		WithPGDataDir(filepath.Join(tmp, "pg_stage")),
+		WithLogDir(filepath.Join(tmp, "logs")),
+	)
+
+	coord := NewCoordinator(spec.Globals.CoordPoint, testPoll)
+	app := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := coord.Wait(ctx, StatusReady, "images"); err != nil {
+			app <- err
+			return
+		}
+		// only the model is ready: inputs are not released
+		if err := coord.Emit(EventInitUpload, "model"); err != nil {
+			app <- err
+			return
+		}
+		_, err := coord.Wait(ctx, StatusDone, "model")
+		app <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = s.Run(ctx, spec)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, status.ErrUpload))
+
+	err = <-app
+	require.Error(t, err)
+	assert.Equal(t, ExitCodeOutput, ExitCode(err), "the application wrapper fails fast")
+
+	state, err := coord.ReadState("images")
+	require.NoError(t, err)
+	assert.Equal(t, StatusFailed, state.Status, "other inputs and outputs are interrupted")
+	assert.Equal(t, []string{filepath.Join(tmp, "images")}, actions.unmounted)
+	assert.Contains(t, pg.calls, "stop scratch")
+}
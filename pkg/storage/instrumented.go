@@ -7,28 +7,58 @@ import (
 	"io"
 	"strings"
 
-	opentracing "github.com/opentracing/opentracing-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+
+	"github.com/oneconcern/datamon/pkg/tracing"
 )
 
-func Instrument(tr opentracing.Tracer, logs zap.Logger, store Store) Store {
-	return &instrumentedStore{
+// Instrument wraps a store to trace all its operations.
+//
+// The instrumented store keeps the capabilities of the wrapped store: it implements StoreCRC
+// and VersionedStore whenever the wrapped store does.
+func Instrument(tr trace.Tracer, logs zap.Logger, store Store) Store {
+	i := &instrumentedStore{
 		tr:    tr,
 		store: store,
 		logs:  logs,
 	}
+
+	crc, isCRC := store.(StoreCRC)
+	versioned, isVersioned := store.(VersionedStore)
+	switch {
+	case isCRC && isVersioned:
+		return struct {
+			*instrumentedStore
+			instrumentedCRC
+			instrumentedVersioned
+		}{i, instrumentedCRC{i, crc}, instrumentedVersioned{i, versioned}}
+	case isCRC:
+		return struct {
+			*instrumentedStore
+			instrumentedCRC
+		}{i, instrumentedCRC{i, crc}}
+	case isVersioned:
+		return struct {
+			*instrumentedStore
+			instrumentedVersioned
+		}{i, instrumentedVersioned{i, versioned}}
+	default:
+		return i
+	}
 }
 
 type instrumentedStore struct {
 	store Store
-	tr    opentracing.Tracer
+	tr    trace.Tracer
 	logs  zap.Logger
 }
 
-func (i *instrumentedStore) KeysPrefix(ctx context.Context, token, prefix, delimiter string, count int) ([]string, string, error) {
-	span := i.spanFromContext(ctx, i.opName("KeysPrefix"))
-	defer span.Finish()
-	i.logs.Info("storage keys with Prefix")
+func (i *instrumentedStore) KeysPrefix(ctx context.Context, token, prefix, delimiter string, count int) (keys []string, next string, err error) {
+	ctx, span := i.start(ctx, "KeysPrefix", attribute.String("prefix", prefix))
+	defer func() { tracing.End(span, err) }()
+	i.logs.Debug("storage keys with Prefix")
 
 	return i.store.KeysPrefix(ctx, token, prefix, delimiter, count)
 }
@@ -37,61 +67,55 @@ func (i *instrumentedStore) opName(name string) string {
 	return strings.Join([]string{"storage", i.String(), name}, ".")
 }
 
-func (i *instrumentedStore) spanFromContext(ctx context.Context, name string) opentracing.Span {
-	parent := opentracing.SpanFromContext(ctx)
-	var span opentracing.Span
-	if parent != nil {
-		span = i.tr.StartSpan(name, opentracing.ChildOf(parent.Context()))
-	} else {
-		span = i.tr.StartSpan(name)
-	}
-	return span
+// start a span, child of the span carried by the context, if any
+func (i *instrumentedStore) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return i.tr.Start(ctx, i.opName(name), trace.WithAttributes(attrs...))
 }
 
-func (i *instrumentedStore) Has(ctx context.Context, key string) (bool, error) {
-	span := i.spanFromContext(ctx, i.opName("Has"))
-	defer span.Finish()
-	i.logs.Info("storage has", zap.String("key", key))
+func (i *instrumentedStore) Has(ctx context.Context, key string) (has bool, err error) {
+	ctx, span := i.start(ctx, "Has", attribute.String("key", key))
+	defer func() { tracing.End(span, err) }()
+	i.logs.Debug("storage has", zap.String("key", key))
 
 	return i.store.Has(ctx, key)
 }
 
-func (i *instrumentedStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
-	span := i.spanFromContext(ctx, i.opName("Get"))
-	defer span.Finish()
+func (i *instrumentedStore) Get(ctx context.Context, key string) (rdr io.ReadCloser, err error) {
+	ctx, span := i.start(ctx, "Get", attribute.String("key", key))
+	defer func() { tracing.End(span, err) }()
 
-	i.logs.Info("storage get", zap.String("key", key))
+	i.logs.Debug("storage get", zap.String("key", key))
 	return i.store.Get(ctx, key)
 }
 
-func (i *instrumentedStore) Put(ctx context.Context, key string, rdr io.Reader, c bool) error {
-	span := i.spanFromContext(ctx, i.opName("Put"))
-	defer span.Finish()
+func (i *instrumentedStore) Put(ctx context.Context, key string, rdr io.Reader, c bool) (err error) {
+	ctx, span := i.start(ctx, "Put", attribute.String("key", key))
+	defer func() { tracing.End(span, err) }()
 
-	i.logs.Info("storage put", zap.String("key", key))
+	i.logs.Debug("storage put", zap.String("key", key))
 	return i.store.Put(ctx, key, rdr, c)
 }
 
-func (i *instrumentedStore) Delete(ctx context.Context, key string) error {
-	span := i.spanFromContext(ctx, i.opName("Delete"))
-	defer span.Finish()
+func (i *instrumentedStore) Delete(ctx context.Context, key string) (err error) {
+	ctx, span := i.start(ctx, "Delete", attribute.String("key", key))
+	defer func() { tracing.End(span, err) }()
 
-	i.logs.Info("storage delete", zap.String("key", key))
+	i.logs.Debug("storage delete", zap.String("key", key))
 	return i.store.Delete(ctx, key)
 }
 
-func (i *instrumentedStore) Keys(ctx context.Context) ([]string, error) {
-	span := i.spanFromContext(ctx, i.opName("Keys"))
-	defer span.Finish()
-	i.logs.Info("storage keys")
+func (i *instrumentedStore) Keys(ctx context.Context) (keys []string, err error) {
+	ctx, span := i.start(ctx, "Keys")
+	defer func() { tracing.End(span, err) }()
+	i.logs.Debug("storage keys")
 
 	return i.store.Keys(ctx)
 }
 
-func (i *instrumentedStore) Clear(ctx context.Context) error {
-	span := i.spanFromContext(ctx, i.opName("Clear"))
-	defer span.Finish()
-	i.logs.Info("storage clear")
+func (i *instrumentedStore) Clear(ctx context.Context) (err error) {
+	ctx, span := i.start(ctx, "Clear")
+	defer func() { tracing.End(span, err) }()
+	i.logs.Debug("storage clear")
 
 	return i.store.Clear(ctx)
 }
@@ -100,23 +124,64 @@ func (i *instrumentedStore) String() string {
 	return i.store.String()
 }
 
-func (i *instrumentedStore) GetAt(ctx context.Context, objectName string) (io.ReaderAt, error) {
-	span := i.spanFromContext(ctx, i.opName("GetAt"))
-	defer span.Finish()
-	i.logs.Info("get a offset reader")
+func (i *instrumentedStore) GetAt(ctx context.Context, objectName string) (rdr io.ReaderAt, err error) {
+	ctx, span := i.start(ctx, "GetAt", attribute.String("key", objectName))
+	defer func() { tracing.End(span, err) }()
+	i.logs.Debug("get a offset reader")
 	return i.store.GetAt(ctx, objectName)
 }
 
-func (i *instrumentedStore) GetAttr(ctx context.Context, object string) (Attributes, error) {
-	span := i.spanFromContext(ctx, i.opName("GetAttr"))
-	defer span.Finish()
-	i.logs.Info("get attributes for an object")
+func (i *instrumentedStore) GetAttr(ctx context.Context, object string) (attrs Attributes, err error) {
+	ctx, span := i.start(ctx, "GetAttr", attribute.String("key", object))
+	defer func() { tracing.End(span, err) }()
+	i.logs.Debug("get attributes for an object")
 	return i.store.GetAttr(ctx, object)
 }
 
-func (i *instrumentedStore) Touch(ctx context.Context, object string) error {
-	span := i.spanFromContext(ctx, i.opName("Touch"))
-	defer span.Finish()
-	i.logs.Info("touch an object")
+func (i *instrumentedStore) Touch(ctx context.Context, object string) (err error) {
+	ctx, span := i.start(ctx, "Touch", attribute.String("key", object))
+	defer func() { tracing.End(span, err) }()
+	i.logs.Debug("touch an object")
 	return i.store.Touch(ctx, object)
 }
+
+type instrumentedCRC struct {
+	i     *instrumentedStore
+	store StoreCRC
+}
+
+func (c instrumentedCRC) PutCRC(ctx context.Context, key string, rdr io.Reader, doesNotExist bool, crc uint32) (err error) {
+	ctx, span := c.i.start(ctx, "PutCRC", attribute.String("key", key))
+	defer func() { tracing.End(span, err) }()
+
+	c.i.logs.Debug("storage put with CRC", zap.String("key", key))
+	return c.store.PutCRC(ctx, key, rdr, doesNotExist, crc)
+}
+
+type instrumentedVersioned struct {
+	i     *instrumentedStore
+	store VersionedStore
+}
+
+func (v instrumentedVersioned) IsVersioned(ctx context.Context) (versioned bool, err error) {
+	ctx, span := v.i.start(ctx, "IsVersioned")
+	defer func() { tracing.End(span, err) }()
+
+	return v.store.IsVersioned(ctx)
+}
+
+func (v instrumentedVersioned) KeyVersions(ctx context.Context, key string) (versions []string, err error) {
+	ctx, span := v.i.start(ctx, "KeyVersions", attribute.String("key", key))
+	defer func() { tracing.End(span, err) }()
+
+	v.i.logs.Debug("storage key versions", zap.String("key", key))
+	return v.store.KeyVersions(ctx, key)
+}
+
+func (v instrumentedVersioned) GetVersion(ctx context.Context, key, version string) (rdr io.ReadCloser, err error) {
+	ctx, span := v.i.start(ctx, "GetVersion", attribute.String("key", key), attribute.String("version", version))
+	defer func() { tracing.End(span, err) }()
+
+	v.i.logs.Debug("storage get version", zap.String("key", key), zap.String("version", version))
+	return v.store.GetVersion(ctx, key, version)
+}
@@ -286,7 +286,12 @@ func (l *localFS) KeysPrefix(_ context.Context, token, prefix, delimiter string,
 	defer l.exclusive.Unlock()
 
 	noRoot := !strings.HasPrefix(prefix, "/")
+	isDir := strings.HasSuffix(prefix, "/")
 	prefix = path.Clean("/" + prefix)
+	if isDir && prefix != "/" {
+		// retain the trailing delimiter, so the first delimiter after the prefix is not the trailing one
+		prefix += "/"
+	}
 
 	// we cache the result for the duration of the fetch loop: during this period, localfs updates are not seen
 	search, ok := l.glob[prefix]
@@ -294,7 +299,7 @@ func (l *localFS) KeysPrefix(_ context.Context, token, prefix, delimiter string,
 		// NOTE: Glob is not workable, fall back to Walk
 		matches := make([]string, 0, 50)
 		err := afero.Walk(l.fs, path.Dir(prefix), func(pth string, info os.FileInfo, err error) error {
-			if info.IsDir() || err != nil {
+			if err != nil || info.IsDir() {
 				return nil
 			}
 			if strings.HasPrefix(pth, prefix) {
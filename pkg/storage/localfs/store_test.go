@@ -215,6 +215,13 @@ func TestKeysPrefixWithDelimiter(t *testing.T) {
 	assert.Empty(t, next)
 	assert.NoError(t, err)
 
+	// with a search in a missing directory
+	search = "/z/y"
+	keys, next, err = store.KeysPrefix(context.Background(), "", search, "", 5)
+	assert.Len(t, keys, 0)
+	assert.Empty(t, next)
+	assert.NoError(t, err)
+
 	// with invalid next
 	search = aSearch
 	_, next, err = store.KeysPrefix(context.Background(), "", search, "", 5)
@@ -260,4 +267,11 @@ func TestKeysPrefixWithDelimiter(t *testing.T) {
 	require.NoError(t, err)
 	assert.Lenf(t, keys, 1, "got keys %v", keys)
 	assert.Equal(t, i, 2)
+
+	// with a trailing delimiter in the prefix
+	search = "a/"
+	keys, next, err = store.KeysPrefix(context.Background(), "", search, "/", 10)
+	require.NoError(t, err)
+	assert.Empty(t, next)
+	assert.ElementsMatch(t, []string{"a/b-1/", "a/b/", "a/d/"}, keys)
 }
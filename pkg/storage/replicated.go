@@ -0,0 +1,115 @@
+// Copyright © 2018 One Concern
+
+package storage
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+)
+
+// NewReplicated builds a store that writes to a primary store and to some replicas.
+//
+// Writes are duplicated with MultiPut: failures on replicas are tolerated, so replicas may lag behind
+// the primary store and need to be synced from time to time.
+//
+// Deletions are propagated to replicas on a best effort basis. All other operations are carried out by the primary store.
+//
+// If the primary store supports versioning, so does the replicated store.
+func NewReplicated(primary Store, replicas ...Store) Store {
+	if len(replicas) == 0 {
+		return primary
+	}
+
+	r := &replicatedStore{
+		Store:    primary,
+		replicas: replicas,
+	}
+
+	if versioned, ok := primary.(VersionedStore); ok {
+		return &versionedReplicatedStore{
+			replicatedStore: r,
+			VersionedStore:  versioned,
+		}
+	}
+
+	return r
+}
+
+// Replicas yields the replicas of a replicated store, or nil for a regular store
+func Replicas(store Store) []Store {
+	switch r := store.(type) {
+	case *replicatedStore:
+		return r.replicas
+	case *versionedReplicatedStore:
+		return r.replicas
+	default:
+		return nil
+	}
+}
+
+// Primary yields the primary store of a replicated store, or the store itself for a regular store
+func Primary(store Store) Store {
+	switch r := store.(type) {
+	case *replicatedStore:
+		return r.Store
+	case *versionedReplicatedStore:
+		return r.Store
+	default:
+		return store
+	}
+}
+
+// type safeguards
+var (
+	_ StoreCRC       = &replicatedStore{}
+	_ VersionedStore = &versionedReplicatedStore{}
+)
+
+type replicatedStore struct {
+	Store
+	replicas []Store
+}
+
+type versionedReplicatedStore struct {
+	*replicatedStore
+	VersionedStore
+}
+
+func (r *replicatedStore) units() []MultiStoreUnit {
+	units := make([]MultiStoreUnit, 0, len(r.replicas)+1)
+	units = append(units, MultiStoreUnit{Store: r.Store, TolerateFailure: false})
+	for _, replica := range r.replicas {
+		units = append(units, MultiStoreUnit{Store: replica, TolerateFailure: true})
+	}
+	return units
+}
+
+func (r *replicatedStore) String() string {
+	return r.Store.String()
+}
+
+// Put writes to the primary store and to all replicas
+func (r *replicatedStore) Put(ctx context.Context, key string, rdr io.Reader, doesNotExist bool) error {
+	buffer, err := ioutil.ReadAll(rdr)
+	if err != nil {
+		return err
+	}
+	return MultiPut(ctx, r.units(), key, buffer, doesNotExist)
+}
+
+// PutCRC writes to the primary store and to all replicas. The CRC is computed again for every store that supports it.
+func (r *replicatedStore) PutCRC(ctx context.Context, key string, rdr io.Reader, doesNotExist bool, _ uint32) error {
+	return r.Put(ctx, key, rdr, doesNotExist)
+}
+
+// Delete removes an object from the primary store, then from the replicas, ignoring failures on replicas
+func (r *replicatedStore) Delete(ctx context.Context, key string) error {
+	if err := r.Store.Delete(ctx, key); err != nil {
+		return err
+	}
+	for _, replica := range r.replicas {
+		_ = replica.Delete(ctx, key)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+// Package tracing exposes OpenTelemetry traces for datamon operations.
+//
+// Spans are started with Start and ended with End. They are not recorded unless a tracer provider
+// has been installed with Init: by default, traces are sent to a Jaeger collector.
+//
+// Traces follow the context: functions running concurrently must be passed the context of the
+// span they belong to.
+package tracing
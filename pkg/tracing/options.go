@@ -0,0 +1,59 @@
+package tracing
+
+import (
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Option configures the tracer provider
+type Option func(*settings)
+
+type settings struct {
+	endpoint    string
+	serviceName string
+	attributes  map[string]string
+	exporter    sdktrace.SpanExporter
+}
+
+func defaultSettings() *settings {
+	return &settings{
+		endpoint:    DefaultEndpoint,
+		serviceName: DefaultServiceName,
+		attributes:  make(map[string]string),
+	}
+}
+
+// WithEndpoint sets the URL of the Jaeger collector receiving traces.
+//
+// The default is http://localhost:14268/api/traces.
+func WithEndpoint(endpoint string) Option {
+	return func(s *settings) {
+		if endpoint != "" {
+			s.endpoint = endpoint
+		}
+	}
+}
+
+// WithServiceName sets the name of the service reported with all traces. The default is "datamon"
+func WithServiceName(name string) Option {
+	return func(s *settings) {
+		if name != "" {
+			s.serviceName = name
+		}
+	}
+}
+
+// WithAttributes sets or adds some attributes to the resource reported with all traces
+func WithAttributes(attributes map[string]string) Option {
+	return func(s *settings) {
+		for k, v := range attributes {
+			s.attributes[k] = v
+		}
+	}
+}
+
+// WithExporter exports spans with another exporter than the Jaeger collector
+func WithExporter(exporter sdktrace.SpanExporter) Option {
+	return func(s *settings) {
+		s.exporter = exporter
+	}
+}
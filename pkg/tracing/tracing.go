@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultEndpoint is the default URL of the Jaeger collector
+	DefaultEndpoint = "http://localhost:14268/api/traces"
+
+	// DefaultServiceName is the default service name reported with traces
+	DefaultServiceName = "datamon"
+
+	instrumentationName = "github.com/oneconcern/datamon"
+)
+
+// Init installs a global tracer provider, which exports spans in batches.
+//
+// The returned function flushes the remaining spans and stops exporting: it should be called before the program exits.
+func Init(opts ...Option) (func(context.Context) error, error) {
+	s := defaultSettings()
+	for _, apply := range opts {
+		apply(s)
+	}
+
+	exporter := s.exporter
+	if exporter == nil {
+		var err error
+		exporter, err = jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(s.endpoint)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(s.attributes)+1)
+	attrs = append(attrs, semconv.ServiceNameKey.String(s.serviceName))
+	for k, v := range s.attributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, attrs...)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer for datamon spans
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// Start a span, child of the span carried by the context, if any.
+//
+// The returned context carries the new span.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End a span, recording the error of the traced operation, if any
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+type recorder struct {
+	mx    sync.Mutex
+	spans []sdktrace.ReadOnlySpan
+}
+
+func (r *recorder) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	r.spans = append(r.spans, spans...)
+	return nil
+}
+
+func (r *recorder) Shutdown(context.Context) error {
+	return nil
+}
+
+func TestTracing(t *testing.T) {
+	defer otel.SetTracerProvider(otel.GetTracerProvider())
+
+	// spans are not recorded until a provider is installed
+	_, span := Start(context.Background(), "ignored")
+	assert.False(t, span.IsRecording())
+	End(span, nil)
+
+	exporter := &recorder{}
+	shutdown, err := Init(WithExporter(exporter), WithServiceName("test"), WithAttributes(map[string]string{"host": "here"}))
+	require.NoError(t, err)
+
+	ctx, parent := Start(context.Background(), "parent", attribute.String("repo", "my-repo"))
+	_, child := Start(ctx, "child")
+	End(child, errors.New("failed"))
+	End(parent, nil)
+
+	require.NoError(t, shutdown(context.Background()))
+
+	require.Len(t, exporter.spans, 2)
+	c, p := exporter.spans[0], exporter.spans[1]
+	assert.Equal(t, "child", c.Name())
+	assert.Equal(t, "parent", p.Name())
+	assert.Equal(t, p.SpanContext().SpanID(), c.Parent().SpanID())
+	assert.Equal(t, p.SpanContext().TraceID(), c.SpanContext().TraceID())
+	assert.Equal(t, codes.Error, c.Status().Code)
+	assert.Len(t, c.Events(), 1, "the error is recorded")
+	assert.Equal(t, codes.Unset, p.Status().Code)
+	assert.Contains(t, p.Attributes(), attribute.String("repo", "my-repo"))
+	assert.Contains(t, p.Resource().Attributes(), attribute.String("service.name", "test"))
+	assert.Contains(t, p.Resource().Attributes(), attribute.String("host", "here"))
+}